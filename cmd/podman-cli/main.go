@@ -4,6 +4,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
@@ -12,6 +14,12 @@ import (
 
 func main() {
 	remoteCLI, err := cli.NewRemoteCLI(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if errors.Is(err, cli.ErrReported) {
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "failed to initialize CLI:", err)
 		os.Exit(1)
@@ -3,8 +3,15 @@ module github.com/alexjch/podman-cli
 go 1.24.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/kevinburke/ssh_config v1.4.0
+	github.com/pkg/sftp v1.13.10
 	golang.org/x/crypto v0.48.0
+	golang.org/x/term v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.41.0 // indirect
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
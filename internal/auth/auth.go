@@ -0,0 +1,262 @@
+// Package auth manages container registry credentials for the remote Podman
+// client. Credentials are stored locally in the containers-auth.json format
+// used by Podman, Buildah and Skopeo, and are passed to the remote host in
+// the X-Registry-Auth header so that private registries can be used without
+// storing credentials on the remote host.
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexjch/podman-cli/internal/config"
+)
+
+// DefaultRegistry is the registry assumed for image references without an
+// explicit registry host.
+const DefaultRegistry = "docker.io"
+
+// Header is the HTTP header used to pass registry credentials to the Podman
+// API.
+const Header = "X-Registry-Auth"
+
+// ConfigHeader is the HTTP header used to pass the credentials of every
+// registry to the Podman API, for builds, which may pull from several.
+const ConfigHeader = "X-Registry-Config"
+
+// File holds the contents of a containers-auth.json file. Fields other
+// than auths, such as credHelpers, are kept as read and written back
+// unchanged, as the file is shared with the other container tools.
+type File struct {
+	Auths map[string]Entry `json:"auths"`
+
+	other map[string]json.RawMessage
+}
+
+// Entry holds the credentials for a single registry. Auth is the base64
+// encoding of "username:password". Other fields, such as identitytoken,
+// are kept as read.
+type Entry struct {
+	Auth string `json:"auth,omitempty"`
+
+	other map[string]json.RawMessage
+}
+
+func (f *File) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if auths, ok := fields["auths"]; ok {
+		if err := json.Unmarshal(auths, &f.Auths); err != nil {
+			return err
+		}
+		delete(fields, "auths")
+	}
+	f.other = fields
+	return nil
+}
+
+func (f File) MarshalJSON() ([]byte, error) {
+	return marshalWithOther(f.other, map[string]any{"auths": f.Auths})
+}
+
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if auth, ok := fields["auth"]; ok {
+		if err := json.Unmarshal(auth, &e.Auth); err != nil {
+			return err
+		}
+		delete(fields, "auth")
+	}
+	e.other = fields
+	return nil
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{}
+	if e.Auth != "" {
+		fields["auth"] = e.Auth
+	}
+	return marshalWithOther(e.other, fields)
+}
+
+// marshalWithOther returns the JSON object of fields, with those of other
+// that fields does not set.
+func marshalWithOther(other map[string]json.RawMessage, fields map[string]any) ([]byte, error) {
+	for k, v := range other {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// DefaultFilePath returns the path of the auth file: $REGISTRY_AUTH_FILE if
+// set, otherwise containers/auth.json under the user's configuration
+// directory ($XDG_CONFIG_HOME or ~/.config).
+func DefaultFilePath() string {
+	if path := os.Getenv("REGISTRY_AUTH_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(config.ConfigHome(), "containers", "auth.json")
+}
+
+// Load reads the auth file at path. A missing file yields an empty File.
+func Load(path string) (*File, error) {
+	f := &File{Auths: map[string]Entry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Auths == nil {
+		f.Auths = map[string]Entry{}
+	}
+	return f, nil
+}
+
+// Save writes f to path with permissions restricted to the current user,
+// creating the parent directory if needed. The file is written under a
+// temporary name and renamed over path, so that a failed write leaves the
+// previous file intact and a file readable by others is replaced.
+func (f *File) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// CreateTemp creates the file with mode 0600
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Set stores the credentials for registry.
+func (f *File) Set(registry, username, password string) {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	f.Auths[normalizeRegistry(registry)] = Entry{Auth: auth}
+}
+
+// Remove deletes the credentials for registry, reporting whether any were
+// stored.
+func (f *File) Remove(registry string) bool {
+	registry = normalizeRegistry(registry)
+	_, ok := f.Auths[registry]
+	delete(f.Auths, registry)
+	return ok
+}
+
+// Get returns the credentials stored for registry.
+func (f *File) Get(registry string) (username, password string, ok bool) {
+	entry, ok := f.Auths[normalizeRegistry(registry)]
+	if !ok {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok = strings.Cut(string(decoded), ":")
+	return username, password, ok
+}
+
+// HeaderValue returns the X-Registry-Auth header value carrying the
+// credentials stored for registry, or "" if there are none.
+func (f *File) HeaderValue(registry string) (string, error) {
+	username, password, ok := f.Get(registry)
+	if !ok {
+		return "", nil
+	}
+	return EncodeHeader(registry, username, password)
+}
+
+// ConfigHeaderValue returns the X-Registry-Config header value carrying
+// the credentials stored for every registry, or "" if there are none.
+func (f *File) ConfigHeaderValue() (string, error) {
+	configs := map[string]headerAuth{}
+	for registry := range f.Auths {
+		if username, password, ok := f.Get(registry); ok {
+			configs[registry] = headerAuth{username, password, registry}
+		}
+	}
+	if len(configs) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// headerAuth is the credentials of a registry as the Podman API expects
+// them in the X-Registry-Auth and X-Registry-Config headers.
+type headerAuth struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	ServerAddress string `json:"serveraddress"`
+}
+
+// EncodeHeader returns the X-Registry-Auth header value for the given
+// credentials: base64url-encoded JSON as expected by the Podman API.
+func EncodeHeader(registry, username, password string) (string, error) {
+	data, err := json.Marshal(headerAuth{username, password, normalizeRegistry(registry)})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// RegistryFromImage returns the registry host of an image reference, or
+// DefaultRegistry if the reference does not name one.
+func RegistryFromImage(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found {
+		return DefaultRegistry
+	}
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return DefaultRegistry
+}
+
+// normalizeRegistry strips any scheme and path from a registry address so
+// that "https://quay.io/v2/" and "quay.io" refer to the same entry.
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry, _, _ = strings.Cut(registry, "/")
+	return registry
+}
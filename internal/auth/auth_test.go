@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestRegistryFromImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"alpine", "docker.io"},
+		{"library/alpine:3.19", "docker.io"},
+		{"quay.io/podman/stable", "quay.io"},
+		{"localhost/myimage", "localhost"},
+		{"registry.local:5000/team/app:v1", "registry.local:5000"},
+	}
+
+	for _, tt := range tests {
+		if got := RegistryFromImage(tt.image); got != tt.want {
+			t.Errorf("RegistryFromImage(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestFile_SetGetRemove(t *testing.T) {
+	f := &File{Auths: map[string]Entry{}}
+
+	f.Set("https://quay.io/v2/", "user", "pa:ss")
+
+	username, password, ok := f.Get("quay.io")
+	if !ok || username != "user" || password != "pa:ss" {
+		t.Errorf("Get() = (%q, %q, %v), want (user, pa:ss, true)", username, password, ok)
+	}
+
+	if !f.Remove("quay.io") {
+		t.Error("Remove() = false, want true for stored registry")
+	}
+	if f.Remove("quay.io") {
+		t.Error("Remove() = true, want false for removed registry")
+	}
+}
+
+func TestLoadSave_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "containers", "auth.json")
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of missing file unexpected error = %v", err)
+	}
+	if len(f.Auths) != 0 {
+		t.Errorf("Load() of missing file returned %d entries, want 0", len(f.Auths))
+	}
+
+	f.Set("docker.io", "alice", "secret")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save() unexpected error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() unexpected error = %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Save() permissions = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if _, _, ok := loaded.Get("docker.io"); !ok {
+		t.Error("Load() did not return the saved credentials")
+	}
+}
+
+func TestSave_ReplacesReadableFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	if err := os.WriteFile(path, []byte(`{"auths":{}}`), 0644); err != nil {
+		t.Fatalf("Failed to write auth file: %v", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	f.Set("docker.io", "alice", "secret")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save() unexpected error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() unexpected error = %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Save() permissions = %v, want 0600 for a file that was 0644", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory has %d entries after Save(), want only the auth file", len(entries))
+	}
+}
+
+func TestLoadSave_KeepsOtherFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	data := `{
+	"auths": {
+		"ghcr.io": {"auth": "dTpw", "identitytoken": "token"},
+		"quay.io": {"auth": "dTpw"}
+	},
+	"credHelpers": {"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+	"credsStore": "desktop"
+}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write auth file: %v", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	f.Set("docker.io", "alice", "secret")
+	f.Remove("quay.io")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save() unexpected error = %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() unexpected error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(saved, &got); err != nil {
+		t.Fatalf("saved file is not JSON: %v", err)
+	}
+	want := map[string]any{
+		"auths": map[string]any{
+			"docker.io": map[string]any{"auth": "YWxpY2U6c2VjcmV0"},
+			"ghcr.io":   map[string]any{"auth": "dTpw", "identitytoken": "token"},
+		},
+		"credHelpers": map[string]any{"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+		"credsStore":  "desktop",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("saved file = %s, want %v", saved, want)
+	}
+}
+
+func TestLoad_InvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatalf("Failed to write auth file: %v", err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load() expected error for invalid JSON, got nil")
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	value, err := EncodeHeader("quay.io", "bob", "hunter2")
+	if err != nil {
+		t.Fatalf("EncodeHeader() unexpected error = %v", err)
+	}
+
+	data, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("EncodeHeader() value is not base64url: %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("EncodeHeader() value is not JSON: %v", err)
+	}
+	if got["username"] != "bob" || got["password"] != "hunter2" || got["serveraddress"] != "quay.io" {
+		t.Errorf("EncodeHeader() = %v, want bob/hunter2@quay.io", got)
+	}
+}
+
+func TestFile_ConfigHeaderValue(t *testing.T) {
+	f := &File{Auths: map[string]Entry{}}
+	if value, err := f.ConfigHeaderValue(); value != "" || err != nil {
+		t.Errorf("ConfigHeaderValue() of an empty file = %q, %v, want \"\"", value, err)
+	}
+
+	f.Set("quay.io", "bob", "hunter2")
+	f.Set("docker.io", "alice", "s3cret")
+	value, err := f.ConfigHeaderValue()
+	if err != nil {
+		t.Fatalf("ConfigHeaderValue() unexpected error = %v", err)
+	}
+	data, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("ConfigHeaderValue() value is not base64url: %v", err)
+	}
+	var got map[string]map[string]string
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("ConfigHeaderValue() value is not JSON: %v", err)
+	}
+	want := map[string]map[string]string{
+		"quay.io":   {"username": "bob", "password": "hunter2", "serveraddress": "quay.io"},
+		"docker.io": {"username": "alice", "password": "s3cret", "serveraddress": "docker.io"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigHeaderValue() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultFilePath_EnvOverride(t *testing.T) {
+	t.Setenv("REGISTRY_AUTH_FILE", "/tmp/custom-auth.json")
+
+	if got := DefaultFilePath(); got != "/tmp/custom-auth.json" {
+		t.Errorf("DefaultFilePath() = %q, want %q", got, "/tmp/custom-auth.json")
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexjch/podman-cli/internal/commands"
+	"github.com/alexjch/podman-cli/internal/config"
+)
+
+// builtinAliases maps the command names podman and docker users are used
+// to onto the commands of podman-cli. ps is not listed as it is a command
+// of its own.
+var builtinAliases = map[string]string{
+	"images":  "list_images",
+	"load":    "load_image",
+	"mount":   "mount_container",
+	"pull":    "pull_image",
+	"push":    "push_image",
+	"rmi":     "rm_image",
+	"save":    "save_image",
+	"unmount": "unmount_container",
+}
+
+// isCommand reports whether name is a local or API command.
+func isCommand(name string) bool {
+	_, ok := localCommands[name]
+	return ok || commands.IsCommand(name) != nil
+}
+
+// expandAlias returns args, the command name followed by its arguments,
+// with an alias in place of the name replaced by what it stands for.
+// Commands take precedence over aliases, and the aliases of the
+// configuration file, given by userAliases, over the built-in ones. A user
+// alias may stand for a built-in alias but not for another user alias.
+func expandAlias(args []string, userAliases map[string]string) ([]string, error) {
+	name := args[0]
+	if isCommand(name) {
+		return args, nil
+	}
+	if value, ok := userAliases[name]; ok {
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("alias %q is empty", name)
+		}
+		if _, ok := userAliases[fields[0]]; ok && !isCommand(fields[0]) {
+			return nil, fmt.Errorf("alias %q stands for alias %q, which is not allowed", name, fields[0])
+		}
+		args = append(fields, args[1:]...)
+		name = args[0]
+	}
+	if target, ok := builtinAliases[name]; ok && !isCommand(name) {
+		args = append([]string{target}, args[1:]...)
+	}
+	return args, nil
+}
+
+// userAliases returns the aliases of the configuration file. Only the
+// -config flag given before the command name, found in fs, is taken into
+// account, as the command's flags cannot be parsed before it is known.
+func (o *globalOptions) userAliases(fs *flag.FlagSet) (map[string]string, error) {
+	path := o.configFile
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == "config" })
+	if env, ok := os.LookupEnv(envName("config")); ok && !set {
+		path = env
+	}
+	f, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Aliases, nil
+}
+
+// aliasesOf returns the sorted built-in aliases of the named command.
+func aliasesOf(name string) []string {
+	var aliases []string
+	for alias, target := range builtinAliases {
+		if target == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
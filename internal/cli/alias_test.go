@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandAlias(t *testing.T) {
+	userAliases := map[string]string{
+		"tail":   "logs -f -tail 50",
+		"lsi":    "images",
+		"ps":     "list_containers",
+		"empty":  " ",
+		"nested": "tail web",
+	}
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"rmi", "-force", "nginx"}, want: "rm_image -force nginx"},
+		{args: []string{"images"}, want: "list_images"},
+		{args: []string{"tail", "web"}, want: "logs -f -tail 50 web"},
+		// A user alias may stand for a built-in alias
+		{args: []string{"lsi"}, want: "list_images"},
+		// Commands cannot be redefined
+		{args: []string{"ps", "-a"}, want: "ps -a"},
+		{args: []string{"unknown"}, want: "unknown"},
+		{args: []string{"empty"}, wantErr: true},
+		{args: []string{"nested"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expandAlias(tt.args, userAliases)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandAlias(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && strings.Join(got, " ") != tt.want {
+			t.Errorf("expandAlias(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinAliases_Targets(t *testing.T) {
+	for alias, target := range builtinAliases {
+		if isCommand(alias) {
+			t.Errorf("built-in alias %q hides the command of that name", alias)
+		}
+		if !isCommand(target) {
+			t.Errorf("built-in alias %q stands for unknown command %q", alias, target)
+		}
+	}
+}
+
+func TestNewRemoteCLI_Alias(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	configFile := filepath.Join(tmpDir, "config.toml")
+	if err := os.WriteFile(configFile, []byte("[aliases]\ntail = \"logs -f -tail 50\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cli, err := NewRemoteCLI([]string{"-host", "testhost", "-config", configFile, "tail", "web"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if cli.name != "logs" || strings.Join(cli.args, " ") != "web" {
+		t.Errorf("NewRemoteCLI() = %s %q, want logs web", cli.name, cli.args)
+	}
+
+	cli, err = NewRemoteCLI([]string{"-host", "testhost", "images"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if cli.command.Path != "/v3.0.0/images/json" {
+		t.Errorf("NewRemoteCLI() images path = %q, want the list_images endpoint", cli.command.Path)
+	}
+}
+
+func TestPrintCommandHelp_Alias(t *testing.T) {
+	var out bytes.Buffer
+	if err := printCommandHelp(&out, "rmi"); err != nil {
+		t.Fatalf("printCommandHelp() unexpected error = %v", err)
+	}
+	for _, want := range []string{"rmi is an alias for rm_image", "Usage: podman-cli [global flags] rm_image", "Aliases: rmi"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printCommandHelp(rmi) missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// apiRequest sends a request for path, with the given query parameters and
+// body, to the Podman API and returns the response. Non-2xx responses are
+// returned as errors that include Podman's error message when available.
+func apiRequest(ctx context.Context, httpClient *http.Client, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	req, err := newAPIRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return doAPIRequest(httpClient, req)
+}
+
+// newAPIRequest builds a request for path on the Podman API. Callers that
+// need to set headers use it together with doAPIRequest.
+//
+// Path segments taken from user input must be escaped with url.PathEscape
+// by the caller; path is sent as given.
+func newAPIRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: unescaped, RawPath: path, RawQuery: query.Encode()}
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// doAPIRequest sends req using httpClient. Non-2xx responses are returned
+// as errors as described for apiRequest, except 101 Switching Protocols,
+// which answers requests upgrading the connection (see hijack).
+func doAPIRequest(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &apiError{StatusCode: resp.StatusCode, Status: resp.Status}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// apiError is a non-2xx response from the Podman API.
+type apiError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+// isStatus reports whether err is an API error with the given status code.
+func isStatus(err error, code int) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// transfer connects to the remote host, sends a request with body to path
+// and streams the response body to out.
+func (rc *RemoteCLI) transfer(method, path string, query url.Values, body io.Reader, out io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sshClient, httpClient, err := rc.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	resp, err := apiRequest(ctx, httpClient, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// getJSON performs a GET request for path and decodes the JSON response
+// into v.
+func getJSON(ctx context.Context, httpClient *http.Client, path string, query url.Values, v any) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// filterUsage is the usage of the -filter flag of list commands.
+const filterUsage = "Filter the list as key=value, such as label=owner=fleet or status=exited (repeatable)"
+
+// encodeFilters encodes -filter values of the form key=value as the JSON
+// filters query parameter of the list endpoints, such as
+// {"label":["owner=fleet"]}. Values given for the same key are combined
+// as Podman does: labels must all match, other keys any of the values.
+func encodeFilters(filters []string) (string, error) {
+	m := map[string][]string{}
+	for _, f := range filters {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" || value == "" {
+			return "", fmt.Errorf("invalid -filter %q: want key=value, such as label=owner=fleet", f)
+		}
+		m[key] = append(m[key], value)
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
+
+// demuxStream copies a multiplexed container output stream, as returned by
+// the logs and attach endpoints for containers without a TTY, writing the
+// payload of stdout frames to stdout and stderr frames to stderr.
+//
+// Each frame starts with an 8-byte header: the stream type in the first
+// byte and the big-endian payload length in the last four. Output of
+// containers with a TTY is not multiplexed; it is detected from the first
+// header and copied to stdout unchanged.
+func demuxStream(stdout, stderr io.Writer, src io.Reader) error {
+	var header [8]byte
+	for first := true; ; first = false {
+		n, err := io.ReadFull(src, header[:])
+		if first && (n > 0 && !isStreamHeader(header[:n])) {
+			if _, err := stdout.Write(header[:n]); err != nil {
+				return err
+			}
+			_, err = io.Copy(stdout, src)
+			return err
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(dst, src, size); err != nil {
+			return err
+		}
+	}
+}
+
+// isStreamHeader reports whether b looks like the start of a multiplexed
+// stream frame header: a stdin, stdout or stderr stream type followed by
+// three zero bytes.
+func isStreamHeader(b []byte) bool {
+	if len(b) < 4 || b[0] > 2 {
+		return false
+	}
+	return b[1] == 0 && b[2] == 0 && b[3] == 0
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/client"
+)
+
+// frame builds a multiplexed stream frame for the given stream type.
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// newTestHTTPClient returns an HTTP client whose requests are served by
+// handler, in the same way requests are sent over the SSH tunnel.
+func newTestHTTPClient(t *testing.T, handler http.Handler) *http.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return client.NewHTTPClient(func() (net.Conn, error) {
+		return net.Dial("tcp", server.Listener.Addr().String())
+	})
+}
+
+func TestDemuxStream_Multiplexed(t *testing.T) {
+	var src bytes.Buffer
+	src.Write(frame(1, "out1\n"))
+	src.Write(frame(2, "err1\n"))
+	src.Write(frame(1, "out2\n"))
+
+	var stdout, stderr bytes.Buffer
+	if err := demuxStream(&stdout, &stderr, &src); err != nil {
+		t.Fatalf("demuxStream() unexpected error = %v", err)
+	}
+
+	if stdout.String() != "out1\nout2\n" {
+		t.Errorf("demuxStream() stdout = %q, want %q", stdout.String(), "out1\nout2\n")
+	}
+	if stderr.String() != "err1\n" {
+		t.Errorf("demuxStream() stderr = %q, want %q", stderr.String(), "err1\n")
+	}
+}
+
+func TestDemuxStream_Raw(t *testing.T) {
+	raw := "plain tty output\nmore\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := demuxStream(&stdout, &stderr, strings.NewReader(raw)); err != nil {
+		t.Fatalf("demuxStream() unexpected error = %v", err)
+	}
+
+	if stdout.String() != raw {
+		t.Errorf("demuxStream() stdout = %q, want %q", stdout.String(), raw)
+	}
+}
+
+func TestDemuxStream_Truncated(t *testing.T) {
+	data := frame(1, "complete")
+	data = append(data, frame(1, "partial")[:10]...)
+
+	var stdout, stderr bytes.Buffer
+	if err := demuxStream(&stdout, &stderr, bytes.NewReader(data)); err == nil {
+		t.Error("demuxStream() expected error for truncated frame, got nil")
+	}
+}
+
+func TestAPIRequest_ErrorMessage(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"cause":"no such container","message":"no container with name or ID \"web\" found","response":404}`))
+	}))
+
+	_, err := apiRequest(context.Background(), httpClient, http.MethodGet, "/v3.0.0/containers/web/json", nil, nil)
+	if err == nil {
+		t.Fatal("apiRequest() expected error for 404, got nil")
+	}
+	if !strings.Contains(err.Error(), `no container with name or ID "web" found`) {
+		t.Errorf("apiRequest() error = %v, want Podman message", err)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all") != "true" {
+			t.Errorf("getJSON() query all = %q, want %q", r.URL.Query().Get("all"), "true")
+		}
+		w.Write([]byte(`[{"Id":"abc"}]`))
+	}))
+
+	var got []struct{ Id string }
+	err := getJSON(context.Background(), httpClient, "/v3.0.0/containers/json", map[string][]string{"all": {"true"}}, &got)
+	if err != nil {
+		t.Fatalf("getJSON() unexpected error = %v", err)
+	}
+	if len(got) != 1 || got[0].Id != "abc" {
+		t.Errorf("getJSON() = %+v, want one container with Id abc", got)
+	}
+}
+
+// newTestCommand builds a local command with newCmd and parses args as its
+// flags.
+func newTestCommand(t *testing.T, newCmd func(fs *flag.FlagSet) runFunc, args ...string) runFunc {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := newCmd(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	return run
+}
+
+func TestEncodeFilters(t *testing.T) {
+	got, err := encodeFilters([]string{"label=owner=fleet", "status=exited", "label=tier", "status=created"})
+	if err != nil {
+		t.Fatalf("encodeFilters() unexpected error = %v", err)
+	}
+	if want := `{"label":["owner=fleet","tier"],"status":["exited","created"]}`; got != want {
+		t.Errorf("encodeFilters() = %s, want %s", got, want)
+	}
+
+	for _, f := range []string{"label", "=web", "name="} {
+		if _, err := encodeFilters([]string{f}); err == nil || !strings.Contains(err.Error(), "want key=value") {
+			t.Errorf("encodeFilters(%q) error = %v, want key=value error", f, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// newAttachCommand returns the "attach" command, which connects the local
+// stdin, stdout and stderr to a running container. For a container
+// created with a TTY, the local terminal is put into raw mode and its
+// size is forwarded to the container, as for exec -t. Detaching with the
+// detach keys (Ctrl-P Ctrl-Q by default) leaves the container running.
+//
+// As with podman attach, SIGINT, SIGTERM and SIGQUIT received while
+// attached are sent to the container, so that Ctrl-C stops the workload
+// rather than only the CLI; with -sig-proxy=false they end the command.
+func newAttachCommand(fs *flag.FlagSet) runFunc {
+	var noStdin, sigProxy bool
+	fs.BoolVar(&noStdin, "no-stdin", false, "Do not send stdin to the container")
+	fs.BoolVar(&sigProxy, "sig-proxy", true, "Send SIGINT, SIGTERM and SIGQUIT received to the container instead of detaching")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("attach: usage: attach [-no-stdin] [-sig-proxy=false] <container>")
+			return 1
+		}
+		name := rc.args[0]
+
+		ctx, stop := context.Background(), func() {}
+		if !sigProxy {
+			ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		}
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		var ctr models.InspectContainerData
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr); err != nil {
+			slog.Error("attach", "target", name, "err", err)
+			return 1
+		}
+
+		terminal, err := openTerminal(ctr.Config.Tty)
+		if err != nil {
+			slog.Error("attach", "err", err)
+			return 1
+		}
+		defer terminal.restore()
+
+		if sigProxy {
+			defer proxySignals(ctx, httpClient, name)()
+		}
+
+		var stdin io.Reader
+		if !noStdin && ctr.Config.OpenStdin {
+			stdin = os.Stdin
+		}
+		code, err := runAttach(ctx, httpClient, name, ctr, terminal, stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			terminal.restore()
+			slog.Error("attach", "target", name, "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// runAttach attaches to the named container, inspected as ctr, streaming
+// stdin to it and its output to stdout and stderr until it exits or the
+// session is detached. It returns the exit code of the container, or 0 if
+// it is still running.
+func runAttach(ctx context.Context, httpClient *http.Client, name string, ctr models.InspectContainerData, terminal *localTerminal, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if !ctr.State.Running {
+		return 0, fmt.Errorf("container is not running")
+	}
+
+	path := "/v3.0.0/libpod/containers/" + url.PathEscape(name)
+	query := url.Values{
+		"stream": {"true"},
+		"stdin":  {strconv.FormatBool(stdin != nil)},
+		"stdout": {"true"},
+		"stderr": {"true"},
+	}
+	output, w, err := hijack(ctx, httpClient, path+"/attach", query, nil)
+	if err != nil {
+		return 0, err
+	}
+	stopResize := terminal.forwardResize(ctx, func(ctx context.Context, width, height int) error {
+		return resizeTTY(ctx, httpClient, path+"/resize", width, height)
+	})
+	err = streamSession(ctx, output, w, stdin, ctr.Config.Tty, stdout, stderr)
+	stopResize()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := getJSON(ctx, httpClient, path+"/json", nil, &ctr); err != nil {
+		return 0, err
+	}
+	if ctr.State.Running {
+		return 0, nil
+	}
+	return ctr.State.ExitCode, nil
+}
+
+// proxiedSignals are the signals sent on to an attached container, with
+// the names the kill endpoint takes.
+var proxiedSignals = map[os.Signal]string{
+	os.Interrupt:    "SIGINT",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGQUIT: "SIGQUIT",
+}
+
+// proxySignals sends the signals in proxiedSignals received by the CLI to
+// the named container until the returned function is called.
+func proxySignals(ctx context.Context, httpClient *http.Client, name string) func() {
+	received := make(chan os.Signal, 1)
+	for sig := range proxiedSignals {
+		signal.Notify(received, sig)
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-received:
+				if err := killContainer(ctx, httpClient, name, proxiedSignals[sig]); err != nil {
+					slog.Warn("Forwarding signal failed", "target", name, "signal", proxiedSignals[sig], "err", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(received)
+		close(done)
+	}
+}
+
+// killContainer sends the named signal, such as SIGINT, to the main
+// process of the named container.
+func killContainer(ctx context.Context, httpClient *http.Client, name, sig string) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/kill", url.Values{"signal": {sig}}, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
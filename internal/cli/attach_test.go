@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestRunAttach(t *testing.T) {
+	inspections := 0
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/web/attach":
+			if q := r.URL.Query(); q.Get("stream") != "true" || q.Get("stdin") != "false" {
+				t.Errorf("attach query = %s, want a stream without stdin", r.URL.RawQuery)
+			}
+			serveSession(t, w, r, func(conn net.Conn, in *bufio.Reader) {
+				conn.Write(frame(1, "serving\n"))
+			})
+		case "/v3.0.0/libpod/containers/web/json":
+			inspections++
+			w.Write([]byte(`{"State":{"Running":false,"ExitCode":137}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+
+	var ctr models.InspectContainerData
+	ctr.State.Running = true
+	var stdout bytes.Buffer
+	code, err := runAttach(context.Background(), httpClient, "web", ctr, nil, nil, &stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("runAttach() unexpected error = %v", err)
+	}
+	if code != 137 || stdout.String() != "serving\n" {
+		t.Errorf("runAttach() = %d with output %q, want 137 and the container output", code, stdout.String())
+	}
+	if inspections != 1 {
+		t.Errorf("container inspected %d times after detaching, want 1", inspections)
+	}
+}
+
+func TestRunAttach_NotRunning(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}))
+
+	if _, err := runAttach(context.Background(), httpClient, "web", models.InspectContainerData{}, nil, nil, io.Discard, io.Discard); err == nil {
+		t.Error("runAttach() expected error for a stopped container, got nil")
+	}
+}
+
+func TestProxySignals(t *testing.T) {
+	killed := make(chan string, 1)
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3.0.0/libpod/containers/web/kill" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		killed <- r.URL.Query().Get("signal")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	stop := proxySignals(context.Background(), httpClient, "web")
+	defer stop()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("FindProcess() unexpected error = %v", err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Signal() unexpected error = %v", err)
+	}
+
+	select {
+	case sig := <-killed:
+		if sig != "SIGTERM" {
+			t.Errorf("container killed with %q, want SIGTERM", sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not forwarded to the container")
+	}
+}
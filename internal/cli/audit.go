@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditEnvFlags are the flags setting environment variables, as KEY=VALUE,
+// whose values are left out of the audit log as they often hold secrets.
+var auditEnvFlags = map[string]bool{"e": true, "env": true}
+
+// auditEntry is a line of the audit log: a command that sent mutating
+// requests to a remote host, with the requests and its outcome.
+type auditEntry struct {
+	Time       time.Time        `json:"time"`
+	User       string           `json:"user"`
+	Host       string           `json:"host,omitempty"`
+	Command    string           `json:"command"`
+	Args       []string         `json:"args"`
+	Operations []auditOperation `json:"operations"`
+	ExitCode   int              `json:"exitCode"`
+	Success    bool             `json:"success"`
+}
+
+// auditOperation is a mutating API request sent by a command, or an
+// operation run over SSH outside of the API (see auditExec).
+type auditOperation struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	Method string    `json:"method"`
+	URI    string    `json:"uri"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// auditExec and auditSFTPWrite are the methods of the operations run over
+// SSH rather than through the Podman API, whose URI is the command line run
+// in an exec session or the remote path of the file written over SFTP.
+const (
+	auditExec      = "EXEC"
+	auditSFTPWrite = "SFTP_WRITE"
+)
+
+// auditLog collects the mutating requests of a command, on any host, and
+// appends the command to an append-only JSONL file once it completes.
+type auditLog struct {
+	f     *os.File
+	entry auditEntry
+
+	mu  sync.Mutex
+	ops []auditOperation
+}
+
+// openAuditLog opens the audit log at path, creating it if needed, for the
+// named command run with args. The file is opened before the command runs
+// so that a command is not run unless it can be audited.
+func openAuditLog(path, name string, args []string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("-audit-log: %w", err)
+	}
+	return &auditLog{f: f, entry: auditEntry{
+		Time:    time.Now(),
+		User:    currentUser(),
+		Command: name,
+		Args:    redactArgs(args),
+	}}, nil
+}
+
+// transport returns an http.RoundTripper sending requests to host with rt
+// and recording the mutating ones.
+func (a *auditLog) transport(rt http.RoundTripper, host string) http.RoundTripper {
+	return &auditTransport{rt: rt, log: a, host: host}
+}
+
+// finish appends the command, if it sent mutating requests, with its exit
+// code to the log and closes it.
+func (a *auditLog) finish(host string, code int) error {
+	defer a.f.Close()
+	a.mu.Lock()
+	entry := a.entry
+	entry.Operations = a.ops
+	a.mu.Unlock()
+	if len(entry.Operations) == 0 {
+		return nil
+	}
+
+	entry.Host = host
+	entry.ExitCode = code
+	entry.Success = code == 0
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// A single write keeps concurrent commands from interleaving lines
+	_, err = a.f.Write(append(line, '\n'))
+	return err
+}
+
+// auditTransport is an http.RoundTripper recording the requests that may
+// change the state of the remote host in an auditLog.
+type auditTransport struct {
+	rt   http.RoundTripper
+	log  *auditLog
+	host string
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.rt.RoundTrip(req)
+	}
+
+	op := auditOperation{Time: time.Now(), Host: t.host, Method: req.Method, URI: req.URL.RequestURI()}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		op.Error = err.Error()
+	} else {
+		op.Status = resp.StatusCode
+	}
+	t.log.record(op)
+	return resp, err
+}
+
+// record adds op to the operations of the command.
+func (a *auditLog) record(op auditOperation) {
+	a.mu.Lock()
+	a.ops = append(a.ops, op)
+	a.mu.Unlock()
+}
+
+// auditSSH records, if the audit log is enabled, an operation started at
+// start on the remote host over SSH: method is auditExec or auditSFTPWrite
+// and target the command line or remote path. err, unless nil, is what the
+// operation failed with, such as the exit status of a command.
+func (rc *RemoteCLI) auditSSH(method, target string, start time.Time, err error) {
+	if rc.audit == nil {
+		return
+	}
+	op := auditOperation{Time: start, Host: rc.host, Method: method, URI: target}
+	if err != nil {
+		op.Error = err.Error()
+	}
+	rc.audit.record(op)
+}
+
+// finishAudit writes the command's entry to the audit log, if enabled.
+func (rc *RemoteCLI) finishAudit(code int) {
+	if rc.audit == nil {
+		return
+	}
+	if err := rc.audit.finish(rc.host, code); err != nil {
+		slog.Error("Failed to write the audit log", "err", err)
+	}
+}
+
+// currentUser returns the name of the local user running the command.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// redactArgs returns args with the values of environment variables set
+// with -e or -env, given as separate or -name=KEY=VALUE arguments with one
+// or two dashes, replaced.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	redact := func(kv string) string {
+		if key, _, ok := strings.Cut(kv, "="); ok {
+			return key + "=REDACTED"
+		}
+		// KEY alone passes the local value, which is not recorded
+		return kv
+	}
+	for i := 0; i < len(redacted); i++ {
+		arg := redacted[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !auditEnvFlags[name] {
+			continue
+		}
+		if hasValue {
+			redacted[i] = strings.TrimSuffix(arg, value) + redact(value)
+		} else if i+1 < len(redacted) {
+			i++
+			redacted[i] = redact(redacted[i])
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := openAuditLog(path, "rm", []string{"-f", "web"})
+	if err != nil {
+		t.Fatalf("openAuditLog() unexpected error = %v", err)
+	}
+	httpClient.Transport = a.transport(httpClient.Transport, "myserver")
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/v3.0.0/libpod/containers/web/json"},
+		{http.MethodPost, "/v3.0.0/libpod/containers/web/stop?t=10"},
+		{http.MethodDelete, "/v3.0.0/libpod/containers/web"},
+	} {
+		r, _ := http.NewRequest(req.method, "http://d"+req.path, nil)
+		resp, err := httpClient.Do(r)
+		if err != nil {
+			t.Fatalf("%s %s: %v", req.method, req.path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if err := a.finish("myserver", 1); err != nil {
+		t.Fatalf("finish() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != "{}" {
+		t.Fatalf("audit log = %q, want the previous line and a new one", data)
+	}
+	var entry auditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Failed to decode entry %q: %v", lines[1], err)
+	}
+	if entry.Command != "rm" || entry.Host != "myserver" || entry.ExitCode != 1 || entry.Success || entry.User == "" {
+		t.Errorf("entry = %+v, want rm on myserver by the current user failing with 1", entry)
+	}
+	if want := []string{"-f", "web"}; !reflect.DeepEqual(entry.Args, want) {
+		t.Errorf("entry args = %q, want %q", entry.Args, want)
+	}
+	var got []string
+	for _, op := range entry.Operations {
+		got = append(got, op.Method+" "+op.URI+" "+http.StatusText(op.Status))
+	}
+	want := []string{
+		"POST /v3.0.0/libpod/containers/web/stop?t=10 OK",
+		"DELETE /v3.0.0/libpod/containers/web Not Found",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("operations = %q, want %q", got, want)
+	}
+}
+
+func TestAuditLog_ReadOnly(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	a, err := openAuditLog(path, "ps", nil)
+	if err != nil {
+		t.Fatalf("openAuditLog() unexpected error = %v", err)
+	}
+	httpClient.Transport = a.transport(httpClient.Transport, "myserver")
+	resp, err := httpClient.Get("http://d/v3.0.0/libpod/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := a.finish("myserver", 0); err != nil {
+		t.Fatalf("finish() unexpected error = %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("audit log = %q, %v, want an empty file", data, err)
+	}
+}
+
+func TestAuditSSH(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := openAuditLog(path, "ssh_exec", []string{"systemctl", "restart", "podman.socket"})
+	if err != nil {
+		t.Fatalf("openAuditLog() unexpected error = %v", err)
+	}
+	rc := &RemoteCLI{host: "myserver", audit: a}
+	rc.auditSSH(auditExec, "systemctl restart podman.socket", time.Now(), errors.New("Process exited with status 1"))
+	rc.auditSSH(auditSFTPWrite, "app/.env", time.Now(), nil)
+	(&RemoteCLI{host: "myserver"}).auditSSH(auditExec, "true", time.Now(), nil)
+	if err := a.finish("myserver", 1); err != nil {
+		t.Fatalf("finish() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry auditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Failed to decode entry %q: %v", data, err)
+	}
+	var got []string
+	for _, op := range entry.Operations {
+		got = append(got, op.Host+" "+op.Method+" "+op.URI+" "+op.Error)
+	}
+	want := []string{
+		"myserver EXEC systemctl restart podman.socket Process exited with status 1",
+		"myserver SFTP_WRITE app/.env ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("operations = %q, want %q", got, want)
+	}
+}
+
+func TestOpenAuditLog_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "audit.jsonl")
+	if _, err := openAuditLog(path, "rm", nil); err == nil {
+		t.Error("openAuditLog() error = nil, want an error")
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{"-e", "TOKEN=s3cret", "--env=DB_PASSWORD=hunter2", "-env", "HOME", "-name", "web", "alpine", "--", "env", "-e", "A=b"}
+	want := []string{"-e", "TOKEN=REDACTED", "--env=DB_PASSWORD=REDACTED", "-env", "HOME", "-name", "web", "alpine", "--", "env", "-e", "A=b"}
+	if got := redactArgs(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("redactArgs() = %q, want %q", got, want)
+	}
+	if args[1] != "TOKEN=s3cret" {
+		t.Error("redactArgs() modified its argument")
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// defaultMaxParallel is the default number of targets a command acting on
+// several of them handles at the same time.
+const defaultMaxParallel = 4
+
+// maxParallelFlag registers the -max-parallel flag of a command acting on
+// several targets over a single SSH connection, for use with
+// forEachTarget.
+func maxParallelFlag(fs *flag.FlagSet) *int {
+	return fs.Int("max-parallel", defaultMaxParallel, "Number of targets handled at the same time")
+}
+
+// newBatchCommand returns a command that applies fn to every target given
+// as an argument, concurrently over a single SSH connection. The targets
+// that succeeded are printed and failures are logged; the exit code is 1
+// if any target failed.
+func newBatchCommand(fs *flag.FlagSet, cmd string, fn func(ctx context.Context, httpClient *http.Client, target string) error) runFunc {
+	maxParallel := maxParallelFlag(fs)
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error(cmd + ": at least one container name or ID is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		return forEachTarget(ctx, cmd, rc.args, *maxParallel, os.Stdout, func(ctx context.Context, target string) error {
+			return fn(ctx, httpClient, target)
+		})
+	}
+}
+
+// forEachTarget runs fn for all targets concurrently, with at most
+// maxParallel calls at the same time. Once all of them have finished, the
+// names of the targets that succeeded are written to out and failures are
+// logged, in the order the targets were given. It returns 1 if any target
+// failed or maxParallel is less than 1.
+func forEachTarget(ctx context.Context, cmd string, targets []string, maxParallel int, out io.Writer, fn func(ctx context.Context, target string) error) int {
+	if maxParallel < 1 {
+		slog.Error(cmd + ": -max-parallel must be at least 1")
+		return 1
+	}
+
+	errs := make([]error, len(targets))
+	forEachHost(targets, maxParallel, func(i int, target string) {
+		errs[i] = fn(ctx, target)
+	})
+
+	code := 0
+	for i, target := range targets {
+		if errs[i] != nil {
+			slog.Error(cmd, "target", target, "err", errs[i])
+			code = 1
+			continue
+		}
+		fmt.Fprintln(out, target)
+	}
+	return code
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachTarget(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	fn := func(ctx context.Context, target string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if target == "bad" {
+			return errors.New("no such container")
+		}
+		return nil
+	}
+
+	var out strings.Builder
+	code := forEachTarget(context.Background(), "stop", []string{"web", "bad", "db"}, defaultMaxParallel, &out, fn)
+
+	if code != 1 {
+		t.Errorf("forEachTarget() = %d, want 1", code)
+	}
+	if got, want := out.String(), "web\ndb\n"; got != want {
+		t.Errorf("forEachTarget() output = %q, want %q", got, want)
+	}
+	if maxRunning.Load() < 2 {
+		t.Errorf("forEachTarget() ran at most %d targets at once, want concurrent execution", maxRunning.Load())
+	}
+}
+
+func TestForEachTarget_AllSucceed(t *testing.T) {
+	var out strings.Builder
+	code := forEachTarget(context.Background(), "start", []string{"a", "b"}, defaultMaxParallel, &out, func(ctx context.Context, target string) error {
+		return nil
+	})
+
+	if code != 0 {
+		t.Errorf("forEachTarget() = %d, want 0", code)
+	}
+	if got, want := out.String(), "a\nb\n"; got != want {
+		t.Errorf("forEachTarget() output = %q, want %q", got, want)
+	}
+}
+
+func TestForEachTarget_MaxParallel(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	fn := func(ctx context.Context, target string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	var out strings.Builder
+	if code := forEachTarget(context.Background(), "rm", []string{"a", "b", "c", "d", "e"}, 2, &out, fn); code != 0 {
+		t.Errorf("forEachTarget() = %d, want 0", code)
+	}
+	if maxRunning.Load() != 2 {
+		t.Errorf("forEachTarget() ran at most %d targets at once, want 2", maxRunning.Load())
+	}
+
+	out.Reset()
+	if code := forEachTarget(context.Background(), "rm", []string{"a"}, 0, &out, fn); code != 1 || out.Len() > 0 {
+		t.Errorf("forEachTarget() with -max-parallel 0 = %d with output %q, want 1 and no output", code, out.String())
+	}
+}
+
+func TestBatchCommand_NoTargets(t *testing.T) {
+	rc := &RemoteCLI{}
+	run := newTestCommand(t, newLifecycleCommand("stop"))
+
+	if code := run(rc); code != 1 {
+		t.Errorf("stop without targets exit code = %d, want 1", code)
+	}
+}
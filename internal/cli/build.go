@@ -0,0 +1,631 @@
+package cli
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+)
+
+// Outcomes of building on a host of a farm, as reported by build -farm.
+const (
+	farmPushed      = "pushed"
+	farmFailed      = "failed"
+	farmUnreachable = "unreachable"
+)
+
+// buildIgnoreFiles are the files listing the paths left out of the build
+// context, in order of precedence.
+var buildIgnoreFiles = []string{".containerignore", ".dockerignore"}
+
+// buildFlags holds the flags of the build command.
+type buildFlags struct {
+	tags      stringsFlag
+	file      string
+	buildArgs stringsFlag
+	noCache   bool
+	farm      string
+	manifest  string
+	authFile  string
+	tlsVerify bool
+}
+
+// newBuildCommand returns the "build" command, which builds an image on
+// the remote host from a local context directory, sent as a tar archive.
+// With -farm, the same build runs on several hosts, of differing
+// platforms, whose images are pushed to a registry and assembled into the
+// multi-platform manifest list named by -manifest, as "podman farm build"
+// does.
+func newBuildCommand(fs *flag.FlagSet) runFunc {
+	var flags buildFlags
+	fs.Var(&flags.tags, "t", "Name the image, as name[:tag] (repeatable)")
+	fs.StringVar(&flags.file, "f", "", "Containerfile, relative to the context directory (default Containerfile, or Dockerfile)")
+	fs.Var(&flags.buildArgs, "build-arg", "Set a build argument as KEY=value (repeatable)")
+	fs.BoolVar(&flags.noCache, "no-cache", false, "Do not use cached layers")
+	fs.StringVar(&flags.farm, "farm", "", "Build on these comma separated hosts, or @<group> of the configuration file, one per platform")
+	fs.StringVar(&flags.manifest, "manifest", "", "With -farm, the manifest list to push the images of all hosts as, such as registry.example.com/myapp:latest")
+	fs.StringVar(&flags.authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.BoolVar(&flags.tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when contacting the registry")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("build: usage: build [flags] <context-dir>")
+			return 1
+		}
+		contextDir := rc.args[0]
+		dockerfile, err := buildContainerfile(contextDir, flags.file)
+		if err != nil {
+			slog.Error("build", "err", err)
+			return 1
+		}
+		query, err := flags.query(dockerfile)
+		if err != nil {
+			slog.Error("build", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if flags.farm != "" {
+			return runFarmBuild(ctx, rc, flags, contextDir, query)
+		}
+		if flags.manifest != "" {
+			slog.Error("build: -manifest requires -farm")
+			return 1
+		}
+		if rc.host == "" {
+			slog.Error("build: no host given: use -host <host>, set " + envName("host") + " or use -farm")
+			return 1
+		}
+
+		configHeader, err := registryConfig(flags.authFile)
+		if err != nil {
+			slog.Error("build", "err", err)
+			return 1
+		}
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		id, err := buildImage(ctx, httpClient, contextDir, query, configHeader, os.Stderr)
+		if err != nil {
+			slog.Error("build", "target", contextDir, "err", err)
+			return 1
+		}
+		fmt.Println(id)
+		return 0
+	}
+}
+
+// query returns the query parameters of the build of dockerfile, naming
+// the image with the -t tags.
+func (f *buildFlags) query(dockerfile string) (url.Values, error) {
+	query := url.Values{"dockerfile": {dockerfile}, "nocache": {strconv.FormatBool(f.noCache)}}
+	for _, tag := range f.tags {
+		query.Add("t", tag)
+	}
+	if len(f.buildArgs) > 0 {
+		args := map[string]string{}
+		for _, arg := range f.buildArgs {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid -build-arg %q: want KEY=value", arg)
+			}
+			args[key] = value
+		}
+		data, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("buildargs", string(data))
+	}
+	return query, nil
+}
+
+// buildContainerfile returns the path, relative to contextDir and with
+// slashes, of the Containerfile given with -f as file, or of the
+// Containerfile or Dockerfile of contextDir. It must be in the context.
+func buildContainerfile(contextDir, file string) (string, error) {
+	info, err := os.Stat(contextDir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", contextDir)
+	}
+
+	if file == "" {
+		for _, name := range []string{"Containerfile", "Dockerfile"} {
+			if _, err := os.Stat(filepath.Join(contextDir, name)); err == nil {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("no Containerfile or Dockerfile in %s: use -f", contextDir)
+	}
+	rel := filepath.ToSlash(filepath.Clean(file))
+	if filepath.IsAbs(file) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("-f %s: the Containerfile must be in the context directory", file)
+	}
+	if _, err := os.Stat(filepath.Join(contextDir, file)); err != nil {
+		return "", fmt.Errorf("-f: %w", err)
+	}
+	return rel, nil
+}
+
+// buildImage builds an image on the remote host from the context
+// directory contextDir with the parameters of query and, unless empty, the
+// X-Registry-Config header configHeader for pulling base images, writing
+// the build output to out, and returns the ID of the image.
+func buildImage(ctx context.Context, httpClient *http.Client, contextDir string, query url.Values, configHeader string, out io.Writer) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeBuildContext(pw, contextDir, query.Get("dockerfile")))
+	}()
+	defer pr.Close()
+
+	req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/libpod/build", query, pr)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	if configHeader != "" {
+		req.Header.Set(auth.ConfigHeader, configHeader)
+	}
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of JSON objects carrying either output, an
+	// error, or the ID of the image built
+	var id, last string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var report struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+			Aux    struct {
+				ID string `json:"ID"`
+			} `json:"aux"`
+		}
+		if err := dec.Decode(&report); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if report.Error != "" {
+			return "", errors.New(strings.TrimSpace(report.Error))
+		}
+		if report.Aux.ID != "" {
+			id = report.Aux.ID
+		}
+		if line := strings.TrimSpace(report.Stream); line != "" {
+			last = line
+		}
+		io.WriteString(out, report.Stream)
+	}
+	// Podman ends the output with the ID when it does not report it apart
+	if id == "" {
+		id = last
+	}
+	return strings.TrimPrefix(id, "sha256:"), nil
+}
+
+// writeBuildContext writes the files of contextDir to w as a tar archive,
+// leaving out those matched by its ignore file, except dockerfile.
+func writeBuildContext(w io.Writer, contextDir, dockerfile string) error {
+	ignore, err := readBuildIgnore(contextDir)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	err = filepath.WalkDir(contextDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contextDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != dockerfile && ignore.matches(rel) {
+			// A directory may hold files included again by a later pattern
+			if d.IsDir() && !ignore.negated {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			// Sockets and other special files have no place in a context
+			return nil
+		}
+		hdr.Name = rel
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// buildIgnore holds the patterns of the ignore file of a build context.
+type buildIgnore struct {
+	patterns []ignorePattern
+	// negated is set when a pattern includes paths again, so that the
+	// contents of ignored directories must still be walked
+	negated bool
+}
+
+type ignorePattern struct {
+	pattern string
+	exclude bool
+}
+
+// readBuildIgnore reads the first of buildIgnoreFiles found in contextDir:
+// lines of path.Match patterns, relative to the context, of which the last
+// one matching a path, or one of its parent directories, decides whether
+// it is left out. Patterns starting with "!" include paths again, and
+// lines starting with "#" are comments.
+func readBuildIgnore(contextDir string) (*buildIgnore, error) {
+	ignore := &buildIgnore{}
+	for _, name := range buildIgnoreFiles {
+		f, err := os.Open(filepath.Join(contextDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			p := ignorePattern{exclude: true}
+			if rest, ok := strings.CutPrefix(line, "!"); ok {
+				p.exclude = false
+				ignore.negated = true
+				line = strings.TrimSpace(rest)
+			}
+			p.pattern = strings.TrimPrefix(path.Clean("/"+line), "/")
+			if _, err := path.Match(p.pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q", name, line)
+			}
+			ignore.patterns = append(ignore.patterns, p)
+		}
+		return ignore, scanner.Err()
+	}
+	return ignore, nil
+}
+
+// matches reports whether rel, a slash separated path relative to the
+// context, is left out of it.
+func (b *buildIgnore) matches(rel string) bool {
+	excluded := false
+	for _, p := range b.patterns {
+		for dir := rel; dir != "."; dir = path.Dir(dir) {
+			if ok, _ := path.Match(p.pattern, dir); ok {
+				excluded = p.exclude
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// farmHost is a host of a farm build, connected to for the whole build.
+type farmHost struct {
+	name       string
+	httpClient *http.Client
+	close      func() error
+	platform   string // as os/arch[/variant]
+	image      string // reference the image is pushed as
+	result     string
+}
+
+// runFarmBuild builds the context on each host of flags.farm, pushes the
+// images, tagged after the manifest list with their platform, to its
+// registry and pushes the manifest list of them all from the first host.
+// Progress is written to stderr with lines prefixed by host, and the
+// outcome per host to stdout.
+func runFarmBuild(ctx context.Context, rc *RemoteCLI, flags buildFlags, contextDir string, query url.Values) int {
+	if flags.manifest == "" {
+		slog.Error("build: -farm requires -manifest")
+		return 1
+	}
+	if len(flags.tags) > 0 {
+		slog.Error("build: -t cannot be used with -farm, whose images are named after -manifest")
+		return 1
+	}
+	if strings.Contains(flags.manifest, "@") {
+		slog.Error("build: -manifest must be a name, not a digest", "manifest", flags.manifest)
+		return 1
+	}
+	names, err := resolveHosts(flags.farm, rc.opts.configFile)
+	if err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+	authHeader, err := registryAuth(flags.authFile, flags.manifest)
+	if err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+	configHeader, err := registryConfig(flags.authFile)
+	if err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+
+	hosts := make([]*farmHost, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		hosts[i] = &farmHost{name: name, result: farmUnreachable}
+		wg.Add(1)
+		go func(h *farmHost) {
+			defer wg.Done()
+			hostRC, err := rc.forHost(h.name)
+			if err != nil {
+				slog.Error("build", "err", err)
+				return
+			}
+			sshClient, httpClient, err := hostRC.connect(ctx)
+			if err != nil {
+				slog.Error("build: failed to connect", "host", h.name, "err", err)
+				return
+			}
+			h.httpClient, h.close = httpClient, sshClient.Close
+			if h.platform, err = hostPlatform(ctx, httpClient); err != nil {
+				slog.Error("build", "host", h.name, "err", err)
+				return
+			}
+			h.result = ""
+		}(hosts[i])
+	}
+	wg.Wait()
+	defer func() {
+		for _, h := range hosts {
+			if h.close != nil {
+				h.close()
+			}
+		}
+	}()
+
+	if err := assignFarmImages(hosts, flags.manifest); err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+
+	width := 0
+	for _, h := range hosts {
+		width = max(width, len(h.name))
+	}
+	var mu sync.Mutex
+	for i, h := range hosts {
+		if h.result != "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var progress io.Writer = io.Discard
+			if showProgress {
+				prefix := fmt.Sprintf("%-*s | ", width, h.name)
+				if colorEnabled(os.Stderr) {
+					prefix = logColors[i%len(logColors)] + prefix + ansiReset
+				}
+				pw := &prefixWriter{w: os.Stderr, mu: &mu, prefix: prefix}
+				defer pw.flush()
+				progress = pw
+			}
+			h.result = farmPushed
+			if err := buildAndPush(ctx, h, contextDir, query, flags.tlsVerify, authHeader, configHeader, progress); err != nil {
+				slog.Error("build", "host", h.name, "err", err)
+				h.result = farmFailed
+			}
+		}()
+	}
+	wg.Wait()
+
+	code := 0
+	rows := make([]tuiRow, len(hosts))
+	for i, h := range hosts {
+		rows[i] = tuiRow{id: h.name, cols: []string{h.name, h.platform, h.image, h.result}}
+		if h.result != farmPushed {
+			code = 1
+		}
+	}
+	if err := writeTable(os.Stdout, rc.opts.format, []string{"HOST", "PLATFORM", "IMAGE", "RESULT"}, rows); err != nil {
+		slog.Error("build", "err", err)
+		return 1
+	}
+	if code != 0 {
+		slog.Error("build: not all hosts pushed their image, so the manifest list was not pushed", "manifest", flags.manifest)
+		return code
+	}
+
+	images := make([]string, len(hosts))
+	for i, h := range hosts {
+		images[i] = h.image
+	}
+	if err := pushManifestList(ctx, hosts[0].httpClient, flags.manifest, images, flags.tlsVerify, authHeader); err != nil {
+		slog.Error("build", "manifest", flags.manifest, "err", err)
+		return 1
+	}
+	slog.Info("Pushed the manifest list", "manifest", flags.manifest, "platforms", len(hosts))
+	return 0
+}
+
+// assignFarmImages names the image of each connected host after manifest
+// and its platform, failing if two hosts share a platform, as the manifest
+// list could only hold one of their images.
+func assignFarmImages(hosts []*farmHost, manifest string) error {
+	repo, tag := splitImageTag(manifest)
+	if tag == "" {
+		tag = "latest"
+	}
+	seen := map[string]string{}
+	for _, h := range hosts {
+		if h.result != "" {
+			continue
+		}
+		if other, ok := seen[h.platform]; ok {
+			return fmt.Errorf("hosts %s and %s are both %s: a farm needs one host per platform", other, h.name, h.platform)
+		}
+		seen[h.platform] = h.name
+		h.image = repo + ":" + tag + "-" + strings.ReplaceAll(h.platform, "/", "-")
+	}
+	return nil
+}
+
+// buildAndPush builds the context on h, naming the image h.image, and
+// pushes it, writing the output to out.
+func buildAndPush(ctx context.Context, h *farmHost, contextDir string, query url.Values, tlsVerify bool, authHeader, configHeader string, out io.Writer) error {
+	hostQuery := url.Values{}
+	for key, values := range query {
+		hostQuery[key] = values
+	}
+	hostQuery.Set("t", h.image)
+	if _, err := buildImage(ctx, h.httpClient, contextDir, hostQuery, configHeader, out); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Pushing %s\n", h.image)
+	return pushImage(ctx, h.httpClient, h.image, h.image, tlsVerify, authHeader, newLayerProgress(out, false))
+}
+
+// hostPlatform returns the platform of the remote host, as os/arch, with
+// the variant of ARM architectures if Podman reports it.
+func hostPlatform(ctx context.Context, httpClient *http.Client) (string, error) {
+	var info struct {
+		Host struct {
+			OS      string `json:"os"`
+			Arch    string `json:"arch"`
+			Variant string `json:"variant"`
+		} `json:"host"`
+	}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/info", nil, &info); err != nil {
+		return "", err
+	}
+	if info.Host.OS == "" || info.Host.Arch == "" {
+		return "", errors.New("the host does not report its platform")
+	}
+	platform := info.Host.OS + "/" + info.Host.Arch
+	if info.Host.Variant != "" {
+		platform += "/" + info.Host.Variant
+	}
+	return platform, nil
+}
+
+// pushManifestList creates the manifest list name on the remote host, in
+// place of any existing one, adds images, pulled from their registry, to
+// it and pushes it to the registry as name.
+func pushManifestList(ctx context.Context, httpClient *http.Client, name string, images []string, tlsVerify bool, authHeader string) error {
+	exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/manifests/"+url.PathEscape(name)+"/exists")
+	if err != nil {
+		return err
+	}
+	if exists {
+		resp, err := apiRequest(ctx, httpClient, http.MethodDelete, "/v3.0.0/libpod/images/"+url.PathEscape(name), nil, nil)
+		if err != nil {
+			return fmt.Errorf("remove the previous manifest list: %w", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/manifests/create", url.Values{"name": {name}}, nil)
+	if err != nil {
+		return fmt.Errorf("create the manifest list: %w", err)
+	}
+	resp.Body.Close()
+
+	for _, image := range images {
+		body, err := json.Marshal(map[string]any{"images": []string{"docker://" + image}})
+		if err != nil {
+			return err
+		}
+		req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/libpod/manifests/"+url.PathEscape(name)+"/add", nil, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if authHeader != "" {
+			req.Header.Set(auth.Header, authHeader)
+		}
+		resp, err := doAPIRequest(httpClient, req)
+		if err != nil {
+			return fmt.Errorf("add %s: %w", image, err)
+		}
+		resp.Body.Close()
+	}
+
+	query := url.Values{"destination": {name}, "all": {"true"}, "tlsVerify": {strconv.FormatBool(tlsVerify)}}
+	req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/libpod/manifests/"+url.PathEscape(name)+"/push", query, nil)
+	if err != nil {
+		return err
+	}
+	if authHeader != "" {
+		req.Header.Set(auth.Header, authHeader)
+	}
+	resp, err = doAPIRequest(httpClient, req)
+	if err != nil {
+		return fmt.Errorf("push the manifest list: %w", err)
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
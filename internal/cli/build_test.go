@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+)
+
+// writeContext writes files, keyed by slash separated path, into a new
+// context directory and returns it.
+func writeContext(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuildContainerfile(t *testing.T) {
+	dir := writeContext(t, map[string]string{"Dockerfile": "FROM alpine\n", "build/Containerfile.prod": "FROM alpine\n"})
+
+	if got, err := buildContainerfile(dir, ""); err != nil || got != "Dockerfile" {
+		t.Errorf("buildContainerfile() = %q, %v, want Dockerfile", got, err)
+	}
+	if got, err := buildContainerfile(dir, "build/./Containerfile.prod"); err != nil || got != "build/Containerfile.prod" {
+		t.Errorf("buildContainerfile(-f) = %q, %v, want build/Containerfile.prod", got, err)
+	}
+	for _, file := range []string{"../Containerfile", "missing"} {
+		if _, err := buildContainerfile(dir, file); err == nil {
+			t.Errorf("buildContainerfile(%q) error = nil, want an error", file)
+		}
+	}
+	if _, err := buildContainerfile(t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "no Containerfile") {
+		t.Errorf("buildContainerfile() without one error = %v, want no Containerfile", err)
+	}
+}
+
+func TestBuildFlagsQuery(t *testing.T) {
+	f := buildFlags{tags: stringsFlag{"myapp:dev", "myapp:latest"}, buildArgs: stringsFlag{"VERSION=1.2", "EMPTY="}, noCache: true}
+	query, err := f.query("Containerfile")
+	if err != nil {
+		t.Fatalf("query() unexpected error = %v", err)
+	}
+	want := `buildargs={"EMPTY":"","VERSION":"1.2"}&dockerfile=Containerfile&nocache=true&t=myapp:dev&t=myapp:latest`
+	if got, _ := url.QueryUnescape(query.Encode()); got != want {
+		t.Errorf("query() = %s, want %s", got, want)
+	}
+
+	f.buildArgs = stringsFlag{"VERSION"}
+	if _, err := f.query("Containerfile"); err == nil {
+		t.Error("query() with an invalid -build-arg error = nil, want an error")
+	}
+}
+
+func TestBuildImage(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"Containerfile":    "FROM alpine\nCOPY . /app\n",
+		".containerignore": "# build outputs\n*.log\n/tmp\n!keep.log\nContainerfile\n",
+		"main.go":          "package main\n",
+		"debug.log":        "noise",
+		"keep.log":         "kept",
+		"tmp/cache":        "noise",
+		"src/app.log":      "kept, as *.log only matches at the top",
+	})
+
+	var names []string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3.0.0/libpod/build" || r.URL.Query().Get("t") != "myapp:dev" {
+			t.Errorf("request = %s %s, want a build of myapp:dev", r.Method, r.URL)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-tar" {
+			t.Errorf("Content-Type = %q, want application/x-tar", ct)
+		}
+		if config := r.Header.Get(auth.ConfigHeader); config != "credentials" {
+			t.Errorf("%s = %q, want the registry credentials", auth.ConfigHeader, config)
+		}
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("invalid context archive: %v", err)
+				return
+			}
+			names = append(names, hdr.Name)
+		}
+		w.Write([]byte(`{"stream":"STEP 1/2: FROM alpine\n"}` + "\n"))
+		w.Write([]byte(`{"stream":"COMMIT myapp:dev\n"}` + "\n"))
+		w.Write([]byte(`{"aux":{"ID":"sha256:0123abcd"}}` + "\n"))
+		w.Write([]byte(`{"stream":"0123abcd\n"}` + "\n"))
+	}))
+
+	var out strings.Builder
+	id, err := buildImage(context.Background(), httpClient, dir, url.Values{"dockerfile": {"Containerfile"}, "t": {"myapp:dev"}}, "credentials", &out)
+	if err != nil {
+		t.Fatalf("buildImage() unexpected error = %v", err)
+	}
+	if id != "0123abcd" {
+		t.Errorf("buildImage() = %q, want 0123abcd", id)
+	}
+	if !strings.HasPrefix(out.String(), "STEP 1/2: FROM alpine\nCOMMIT myapp:dev\n") {
+		t.Errorf("output = %q, want the build output", out.String())
+	}
+	sort.Strings(names)
+	// The Containerfile is sent even though ignored
+	want := []string{".containerignore", "Containerfile", "keep.log", "main.go", "src/", "src/app.log"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("context = %q, want %q", names, want)
+	}
+}
+
+func TestBuildImage_Error(t *testing.T) {
+	dir := writeContext(t, map[string]string{"Containerfile": "FROM missing\n"})
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"stream":"STEP 1/1: FROM missing\n"}` + "\n" + `{"error":"missing: image not known\n"}` + "\n"))
+	}))
+
+	_, err := buildImage(context.Background(), httpClient, dir, url.Values{"dockerfile": {"Containerfile"}}, "", io.Discard)
+	if err == nil || err.Error() != "missing: image not known" {
+		t.Errorf("buildImage() error = %v, want the build error", err)
+	}
+}
+
+func TestAssignFarmImages(t *testing.T) {
+	hosts := []*farmHost{
+		{name: "x86", platform: "linux/amd64"},
+		{name: "pi", platform: "linux/arm64/v8"},
+		{name: "down", result: farmUnreachable},
+	}
+	if err := assignFarmImages(hosts, "registry.example.com:5000/myapp"); err != nil {
+		t.Fatalf("assignFarmImages() unexpected error = %v", err)
+	}
+	for i, want := range []string{"registry.example.com:5000/myapp:latest-linux-amd64", "registry.example.com:5000/myapp:latest-linux-arm64-v8", ""} {
+		if hosts[i].image != want {
+			t.Errorf("%s image = %q, want %q", hosts[i].name, hosts[i].image, want)
+		}
+	}
+
+	hosts = []*farmHost{{name: "a", platform: "linux/amd64"}, {name: "b", platform: "linux/amd64"}}
+	if err := assignFarmImages(hosts, "myapp:v2"); err == nil || !strings.Contains(err.Error(), "one host per platform") {
+		t.Errorf("assignFarmImages() error = %v, want a shared platform error", err)
+	}
+}
+
+func TestHostPlatform(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"host": map[string]string{"os": "linux", "arch": "arm64"}})
+	}))
+	if got, err := hostPlatform(context.Background(), httpClient); err != nil || got != "linux/arm64" {
+		t.Errorf("hostPlatform() = %q, %v, want linux/arm64", got, err)
+	}
+}
+
+func TestPushManifestList(t *testing.T) {
+	var got []string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.EscapedPath() + "?" + r.URL.RawQuery
+		if strings.HasSuffix(r.URL.Path, "/add") {
+			body, _ := io.ReadAll(r.Body)
+			call += " " + string(body)
+		}
+		if r.Header.Get("X-Registry-Auth") != "" {
+			call += " (auth)"
+		}
+		got = append(got, call)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/exists"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+
+	images := []string{"quay.io/me/app:v1-linux-amd64", "quay.io/me/app:v1-linux-arm64"}
+	if err := pushManifestList(context.Background(), httpClient, "quay.io/me/app:v1", images, false, "token"); err != nil {
+		t.Fatalf("pushManifestList() unexpected error = %v", err)
+	}
+	want := []string{
+		"GET /v3.0.0/libpod/manifests/quay.io%2Fme%2Fapp:v1/exists?",
+		"DELETE /v3.0.0/libpod/images/quay.io%2Fme%2Fapp:v1?",
+		"POST /v3.0.0/libpod/manifests/create?name=quay.io%2Fme%2Fapp%3Av1",
+		`POST /v3.0.0/libpod/manifests/quay.io%2Fme%2Fapp:v1/add? {"images":["docker://quay.io/me/app:v1-linux-amd64"]} (auth)`,
+		`POST /v3.0.0/libpod/manifests/quay.io%2Fme%2Fapp:v1/add? {"images":["docker://quay.io/me/app:v1-linux-arm64"]} (auth)`,
+		"POST /v3.0.0/libpod/manifests/quay.io%2Fme%2Fapp:v1/push?all=true&destination=quay.io%2Fme%2Fapp%3Av1&tlsVerify=false (auth)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
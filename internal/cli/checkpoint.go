@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// newCheckpointCommand returns the "checkpoint" command, which checkpoints a
+// running container. With -export the checkpoint tarball is streamed to a
+// local file (or stdout for "-") so it can be restored on another host.
+func newCheckpointCommand(fs *flag.FlagSet) runFunc {
+	var export string
+	var keep, leaveRunning, tcpEstablished bool
+	fs.StringVar(&export, "export", "", "Export the checkpoint to a local tar file (- for stdout)")
+	fs.BoolVar(&keep, "keep", false, "Keep checkpoint files and logs on the remote host")
+	fs.BoolVar(&leaveRunning, "leave-running", false, "Leave the container running after checkpointing")
+	fs.BoolVar(&tcpEstablished, "tcp-established", false, "Checkpoint established TCP connections")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("checkpoint: exactly one container name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		query := url.Values{
+			"keep":           {strconv.FormatBool(keep)},
+			"leaveRunning":   {strconv.FormatBool(leaveRunning)},
+			"tcpEstablished": {strconv.FormatBool(tcpEstablished)},
+		}
+		if export != "" {
+			query.Set("export", "true")
+		}
+
+		var out io.Writer = os.Stdout
+		if export != "" && export != "-" {
+			f, err := os.Create(export)
+			if err != nil {
+				slog.Error("checkpoint", "err", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+
+		path := "/v3.0.0/libpod/containers/" + url.PathEscape(name) + "/checkpoint"
+		if err := rc.transfer(http.MethodPost, path, query, nil, out); err != nil {
+			slog.Error("checkpoint", "target", name, "err", err)
+			if export != "" && export != "-" {
+				os.Remove(export)
+			}
+			return 1
+		}
+		return 0
+	}
+}
+
+// newRestoreCommand returns the "restore" command, which restores a
+// checkpointed container. With -import a checkpoint tarball is streamed from
+// a local file (or stdin for "-") to the remote host.
+func newRestoreCommand(fs *flag.FlagSet) runFunc {
+	var imp, name string
+	var keep, tcpEstablished bool
+	fs.StringVar(&imp, "import", "", "Import the checkpoint from a local tar file (- for stdin)")
+	fs.StringVar(&name, "name", "", "Name for the restored container (with -import)")
+	fs.BoolVar(&keep, "keep", false, "Keep checkpoint files and logs on the remote host")
+	fs.BoolVar(&tcpEstablished, "tcp-established", false, "Restore established TCP connections")
+
+	return func(rc *RemoteCLI) int {
+		query := url.Values{
+			"keep":           {strconv.FormatBool(keep)},
+			"tcpEstablished": {strconv.FormatBool(tcpEstablished)},
+		}
+
+		// When importing, the container is identified by the archive and
+		// the path only needs a placeholder
+		target := "import"
+		var body io.Reader
+		if imp != "" {
+			if len(rc.args) != 0 {
+				slog.Error("restore: a container name cannot be combined with -import")
+				return 1
+			}
+			query.Set("import", "true")
+			if name != "" {
+				query.Set("name", name)
+			}
+
+			if imp == "-" {
+				body = os.Stdin
+			} else {
+				f, err := os.Open(imp)
+				if err != nil {
+					slog.Error("restore", "err", err)
+					return 1
+				}
+				defer f.Close()
+				body = f
+			}
+		} else {
+			if len(rc.args) != 1 {
+				slog.Error("restore: exactly one container name or ID is required")
+				return 1
+			}
+			if name != "" {
+				slog.Error("restore: -name can only be used with -import")
+				return 1
+			}
+			target = rc.args[0]
+		}
+
+		path := "/v3.0.0/libpod/containers/" + url.PathEscape(target) + "/restore"
+		if err := rc.transfer(http.MethodPost, path, query, body, os.Stdout); err != nil {
+			slog.Error("restore", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckpointCommand_RequiresContainer(t *testing.T) {
+	run := newTestCommand(t, newCheckpointCommand, "-export", "out.tar")
+
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("checkpoint without container exit code = %d, want 1", code)
+	}
+}
+
+func TestRestoreCommand_ArgumentValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+	}{
+		{"no container", nil, nil},
+		{"import with container", []string{"-import", "ckpt.tar"}, []string{"web"}},
+		{"name without import", []string{"-name", "web2"}, []string{"web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newTestCommand(t, newRestoreCommand, tt.flags...)
+			if code := run(&RemoteCLI{args: tt.args}); code != 1 {
+				t.Errorf("restore exit code = %d, want 1", code)
+			}
+		})
+	}
+}
+
+func TestRestoreCommand_MissingImportFile(t *testing.T) {
+	run := newTestCommand(t, newRestoreCommand, "-import", t.TempDir()+"/missing.tar")
+
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("restore with missing archive exit code = %d, want 1", code)
+	}
+}
+
+// runCheckpointCommand runs podman-cli with args against a host whose
+// Podman API is handler, returning the exit code.
+func runCheckpointCommand(t *testing.T, handler http.Handler, args ...string) int {
+	t.Helper()
+	sshConfig := startMockHostHandler(t, handler)
+	cli, err := NewRemoteCLI(append([]string{"-ssh-config", sshConfig, "-host", mockHost}, args...))
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	return cli.Run()
+}
+
+func TestCheckpointCommand_Export(t *testing.T) {
+	export := filepath.Join(t.TempDir(), "web.tar")
+	var query url.Values
+	code := runCheckpointCommand(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3.0.0/libpod/containers/web/checkpoint" {
+			t.Errorf("request = %s %s, want POST of the web checkpoint", r.Method, r.URL.Path)
+		}
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Write([]byte("first "))
+		w.(http.Flusher).Flush()
+
+		// The archive reaches the file as it is received, not once complete
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if data, _ := os.ReadFile(export); string(data) == "first " {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Error("the start of the archive was not written to the file before its end was sent")
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Write([]byte("second"))
+	}), "checkpoint", "-export", export, "-leave-running", "web")
+	if code != 0 {
+		t.Fatalf("checkpoint exit code = %d, want 0", code)
+	}
+
+	want := url.Values{"export": {"true"}, "keep": {"false"}, "leaveRunning": {"true"}, "tcpEstablished": {"false"}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("checkpoint query = %v, want %v", query, want)
+	}
+	if data, _ := os.ReadFile(export); string(data) != "first second" {
+		t.Errorf("exported archive = %q, want %q", data, "first second")
+	}
+}
+
+func TestCheckpointCommand_ExportFailure(t *testing.T) {
+	export := filepath.Join(t.TempDir(), "web.tar")
+	code := runCheckpointCommand(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Podman fails the checkpoint after the export file was created
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"cause":"criu failed","message":"checkpoint failed","response":500}`))
+	}), "checkpoint", "-export", export, "web")
+	if code != 1 {
+		t.Errorf("checkpoint exit code = %d, want 1", code)
+	}
+	if _, err := os.Stat(export); !os.IsNotExist(err) {
+		t.Errorf("partial archive left behind: Stat() error = %v", err)
+	}
+}
+
+func TestRestoreCommand_Import(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "web.tar")
+	if err := os.WriteFile(archive, []byte("checkpoint archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var path, body string
+	var query url.Values
+	code := runCheckpointCommand(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.Query()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"Id":"abc"}`))
+	}), "restore", "-import", archive, "-name", "web2", "-keep")
+	if code != 0 {
+		t.Fatalf("restore exit code = %d, want 0", code)
+	}
+
+	if path != "/v3.0.0/libpod/containers/import/restore" {
+		t.Errorf("restore path = %s, want the import placeholder", path)
+	}
+	want := url.Values{"import": {"true"}, "name": {"web2"}, "keep": {"true"}, "tcpEstablished": {"false"}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("restore query = %v, want %v", query, want)
+	}
+	if body != "checkpoint archive" {
+		t.Errorf("restore body = %q, want the archive", body)
+	}
+}
+
+func TestRestoreCommand_Container(t *testing.T) {
+	var path string
+	var query url.Values
+	code := runCheckpointCommand(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.Query()
+		w.Write([]byte(`{"Id":"abc"}`))
+	}), "restore", "-tcp-established", "web")
+	if code != 0 {
+		t.Fatalf("restore exit code = %d, want 0", code)
+	}
+	if path != "/v3.0.0/libpod/containers/web/restore" || query.Has("import") || query.Has("name") || query.Get("tcpEstablished") != "true" {
+		t.Errorf("restore request = %s?%s, want web restored with tcpEstablished", path, query.Encode())
+	}
+}
@@ -5,10 +5,14 @@ package cli
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,83 +21,633 @@ import (
 
 	"github.com/alexjch/podman-cli/internal/client"
 	"github.com/alexjch/podman-cli/internal/commands"
+	"github.com/alexjch/podman-cli/internal/config"
+	"github.com/alexjch/podman-cli/internal/models"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 )
 
 // RemoteCLI represents a configured remote Podman CLI session.
 // It holds the SSH connection details and command to be executed.
 type RemoteCLI struct {
+	name            string
+	host            string
 	addr            string
+	socketPath      string
+	keepAlive       time.Duration
+	requestTimeout  time.Duration // limit for each API request, 0 for none
+	retry           client.RetryPolicy
 	command         commands.Command
+	args            []string
+	run             runFunc
 	sshClientConfig *ssh.ClientConfig
+	tracer          *client.Tracer
+	proxy           *client.Proxy     // proxy of the SSH connection, nil to connect directly
+	telemetry       *client.Telemetry // OpenTelemetry spans, nil unless an OTLP endpoint is set
+	dryRun          *dryRunTransport
+	recorder        *recording       // records API exchanges for -record
+	replay          *replayTransport // answers API requests for -replay
+	audit           *auditLog        // records mutating API requests for -audit-log
+	dump            *responseDump    // saves API responses for -output-dir
+	pool            *client.Pool     // SSH connections shared by the commands of the process, nil to dial each
+	output          string           // file receiving API command responses, if set
+	query           url.Values       // query parameters of the API command, such as -filter
+	errors          *errorLog        // error records held back for the JSON formats
+	opts            globalOptions
+	globalArgs      []string // global flags given, but -host, as -name=value
+}
+
+// globalOptions holds the flags shared by every command. They are accepted
+// both before and after the command name.
+type globalOptions struct {
+	host             string
+	timeout          time.Duration
+	requestTimeout   time.Duration
+	keepAlive        time.Duration
+	retries          int
+	retryDelay       time.Duration
+	insecure         bool
+	level            string
+	quiet            bool
+	verbose          bool
+	debug            bool
+	dryRun           bool
+	record           string
+	replay           string
+	auditLog         string
+	outputDir        string
+	profile          string
+	socket           string
+	format           string
+	configFile       string
+	sshConfig        string
+	knownHosts       string
+	identityCmd      string
+	credentialHelper string
+	vaultAddr        string
+	vaultRole        string
+	vaultMount       string
+	ensureService    bool
+	color            string
+	yes              bool
+	proxy            string
+}
+
+// appendGlobalArg appends the global flag f, as given, to args, unless it
+// is -host.
+func appendGlobalArg(args []string, f *flag.Flag) []string {
+	if f.Name == "host" {
+		return args
+	}
+	return append(args, "-"+f.Name+"="+f.Value.String())
+}
+
+// defaultGlobalOptions returns the global options used when no flags are
+// given.
+func defaultGlobalOptions() globalOptions {
+	return globalOptions{
+		timeout:        30 * time.Second,
+		requestTimeout: defaultRequestTimeout,
+		keepAlive:      client.DefaultKeepAliveInterval,
+		retryDelay:     time.Second,
+		level:          "info",
+		socket:         client.DefaultSocketPath,
+		format:         formatText,
+		color:          colorAuto,
+		configFile:     config.DefaultPath(),
+		vaultAddr:      os.Getenv("VAULT_ADDR"),
+		vaultMount:     client.DefaultVaultMount,
+	}
+}
+
+// register adds the global flags to fs, using the current option values as
+// defaults so that values parsed by an earlier flag set are preserved.
+// Flags already defined on fs by a command take precedence over global
+// flags of the same name, which then remain available before the command.
+func (o *globalOptions) register(fs *flag.FlagSet) {
+	global := flag.NewFlagSet("global", flag.ContinueOnError)
+	global.StringVar(&o.host, "host", o.host, "Host to connect")
+	global.DurationVar(&o.timeout, "timeout", o.timeout, "SSH connection timeout")
+	global.DurationVar(&o.requestTimeout, "request-timeout", o.requestTimeout, "Time limit for each API request, including reading the response (0 disables; streaming commands are not limited)")
+	global.DurationVar(&o.keepAlive, "keepalive", o.keepAlive, "Interval between SSH keepalive requests (0 disables)")
+	global.IntVar(&o.retries, "retries", o.retries, "Number of times to retry on transient connection errors")
+	global.DurationVar(&o.retryDelay, "retry-delay", o.retryDelay, "Initial delay between retries, doubled after each attempt")
+	global.BoolVar(&o.insecure, "no-host-validation", o.insecure, "Do not verify host")
+	global.StringVar(&o.level, "log-level", o.level, "Minimum level of diagnostics written to stderr: debug, info, warn or error")
+	global.BoolVar(&o.quiet, "quiet", o.quiet, "Only report errors on stderr")
+	global.BoolVar(&o.verbose, "verbose", o.verbose, "Report debug diagnostics on stderr")
+	global.BoolVar(&o.debug, "debug", o.debug, "Trace SSH connection setup and HTTP requests, with timings, on stderr")
+	global.BoolVar(&o.dryRun, "dry-run", o.dryRun, "Print the API requests that would be sent instead of connecting")
+	global.StringVar(&o.record, "record", o.record, "Record the API requests and responses to this file, for -replay")
+	global.StringVar(&o.replay, "replay", o.replay, "Answer API requests from a file written by -record instead of connecting")
+	global.StringVar(&o.auditLog, "audit-log", o.auditLog, "Append a JSON line for each command changing anything on the remote host to this file")
+	global.StringVar(&o.outputDir, "output-dir", o.outputDir, "Save each API response to a file of this directory, by host, command and time, with checksums")
+	global.StringVar(&o.profile, "profile", o.profile, "Configuration file profile providing defaults for these flags")
+	global.StringVar(&o.socket, "socket", o.socket, "Path of the Podman API socket on the remote host")
+	global.StringVar(&o.format, "format", o.format, "Output format: text, json, or json-stream for one JSON event per line")
+	global.StringVar(&o.configFile, "config", o.configFile, "Path of the podman-cli configuration file")
+	global.StringVar(&o.sshConfig, "ssh-config", o.sshConfig, "Path of the SSH client configuration file (default ~/.ssh/config)")
+	global.StringVar(&o.knownHosts, "known-hosts", o.knownHosts, "Path of the SSH known_hosts file (default ~/.ssh/known_hosts)")
+	global.StringVar(&o.identityCmd, "identity-cmd", o.identityCmd, "Shell command printing the SSH private key, or the identity file's passphrase")
+	global.StringVar(&o.credentialHelper, "credential-helper", o.credentialHelper, "Look up the identity file's passphrase in keychain (macOS) or libsecret")
+	global.StringVar(&o.vaultRole, "vault-role", o.vaultRole, "Authenticate with a short-lived certificate signed by this Vault SSH role")
+	global.StringVar(&o.vaultAddr, "vault-addr", o.vaultAddr, "Address of the Vault server signing certificates (default $VAULT_ADDR)")
+	global.StringVar(&o.vaultMount, "vault-mount", o.vaultMount, "Mount path of Vault's SSH secrets engine")
+	global.BoolVar(&o.ensureService, "ensure-service", o.ensureService, "Start the Podman API service on the remote host if its socket is missing, without asking")
+	global.BoolVar(&o.yes, "yes", o.yes, "Do not ask before removing anything on the remote host")
+	global.BoolVar(&o.yes, "y", o.yes, "Shorthand for -yes")
+	global.StringVar(&o.proxy, "proxy", o.proxy, "Connect to the SSH server through this SOCKS5 or HTTP CONNECT proxy, such as socks5://127.0.0.1:1080 (default $ALL_PROXY)")
+	global.StringVar(&o.color, "color", o.color, "Color statuses, diagnostics and log prefixes: auto (on terminals, unless NO_COLOR is set), always or never")
+
+	global.VisitAll(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
 }
 
 // NewRemoteCLI creates a new RemoteCLI instance by parsing command-line arguments.
 // It validates the arguments, loads SSH configuration, and prepares the command for execution.
 //
 // Required arguments:
-//   - -host: the SSH host to connect to (as defined in ~/.ssh/config)
+//   - -host: the SSH host to connect to (as defined in ~/.ssh/config);
+//     commands taking no arguments also accept it as their only argument
 //   - command: the Podman command to execute (e.g., "list_containers")
 //
 // Optional arguments:
 //   - -timeout: SSH connection timeout (default: 30s)
+//   - -request-timeout: time limit for each API request (default: 2m)
+//   - -keepalive: interval between SSH keepalive requests (default: 30s)
+//   - -retries: retries on transient connection errors (default: 0)
+//   - -retry-delay: initial delay between retries (default: 1s)
 //   - -no-host-validation: skip SSH host key verification (not recommended)
+//   - -log-level: minimum level of diagnostics (default: info)
+//   - -quiet, -verbose: only report errors, or also report debug messages
+//   - -debug: trace SSH negotiation and HTTP exchanges with timings
+//   - -dry-run: print the API requests instead of connecting
+//   - -record, -replay: record the API exchanges to a file, or answer
+//     requests from such a file instead of connecting
+//   - -audit-log: append the commands changing the remote host to a file
+//   - -output-dir: save the API responses to files of a directory
+//   - -profile: configuration file profile to take defaults from
+//   - -socket: path of the remote Podman API socket
+//   - -format: output format, text, json or json-stream (default: text)
+//   - -config, -ssh-config, -known-hosts: paths of the configuration files
+//   - -identity-cmd, -credential-helper: external source of the SSH key
+//     or its passphrase
+//   - -vault-role, -vault-addr, -vault-mount: sign a short-lived certificate
+//     with Vault's SSH secrets engine
+//   - -ensure-service: start the remote Podman API service if not running
+//   - -proxy: SOCKS5 or HTTP CONNECT proxy of the SSH connection (default:
+//     $ALL_PROXY)
+//   - -color: color output auto (on terminals), always or never
+//   - -yes, -y: do not ask before destructive commands
+//
+// Global flags may appear either before or after the command name. Local
+// commands (see localCommands) may define additional flags of their own.
+//
+// Global flags that are not given default to their environment variable
+// (see envName), then to the values in the -config configuration file, with those of the -profile profile taking precedence
+// over the top-level ones.
 //
 // Returns an error if required arguments are missing, the command is invalid,
-// or SSH configuration cannot be loaded.
-func NewRemoteCLI(args []string) (*RemoteCLI, error) {
+// or SSH configuration cannot be loaded. If -h or -help is given, the usage
+// is printed and flag.ErrHelp is returned. With a JSON output format, the
+// error is written to stderr as a failure report, as for failed commands,
+// and wraps ErrReported.
+func NewRemoteCLI(args []string) (_ *RemoteCLI, err error) {
 
-	var host string
-	var timeout time.Duration
-	var insecure bool
+	opts := defaultGlobalOptions()
+	var name string
+	var errLog *errorLog
+	defer func() {
+		if err != nil && !errors.Is(err, flag.ErrHelp) && (opts.format == formatJSON || opts.format == formatJSONStream) {
+			err = reportSetupFailure(name, opts.host, errLog, err)
+		}
+	}()
 
 	fs := flag.NewFlagSet("remote-cli", flag.ContinueOnError)
-
-	fs.StringVar(&host, "host", "", "Host to connect")
-	fs.DurationVar(&timeout, "timeout", 30*time.Second, "SSH connection timeout")
-	fs.BoolVar(&insecure, "no-host-validation", false, "Do not verify host")
+	fs.Usage = usageFunc(fs, "")
+	opts.register(fs)
 
 	if err := fs.Parse(args); err != nil {
-		log.Printf("Failed to parse arguments: %v", err)
+		if err != flag.ErrHelp {
+			slog.Error("Failed to parse arguments", "err", err)
+		}
 		return nil, err
 	}
 
 	if fs.NArg() < 1 {
-		return nil, fmt.Errorf("at least one command must be provided")
+		return nil, fmt.Errorf("at least one command must be provided (run \"help\" for usage)")
 	}
 
-	if host == "" {
-		fs.PrintDefaults()
-		return nil, errors.New("-host is required (use -host to specify the remote host)")
+	cmds := fs.Args()
+	if !isCommand(cmds[0]) {
+		aliases, err := opts.userAliases(fs)
+		if err != nil {
+			return nil, err
+		}
+		if cmds, err = expandAlias(cmds, aliases); err != nil {
+			return nil, err
+		}
 	}
+	name = cmds[0]
 
-	cmds := fs.Args()
-	command := commands.IsCommand(cmds[0])
-	if command == nil {
-		return nil, fmt.Errorf("invalid command: %s", cmds[0])
+	// Each command gets its own flag set so that command-specific flags, as
+	// well as the global ones, can follow the command name.
+	cmdFlags := flag.NewFlagSet(name, flag.ContinueOnError)
+	cmdFlags.Usage = usageFunc(cmdFlags, name)
+
+	var run runFunc
+	var command commands.Command
+	var output string
+	var filters stringsFlag
+	var plugin string
+	local, isLocal := localCommands[name]
+	if isLocal {
+		run = local.setup(cmdFlags)
+	} else if c := commands.IsCommand(name); c != nil {
+		command = *c
+		apiCommandFlags(cmdFlags, command, &output, &filters)
+	} else if path, err := lookPlugin(name); err == nil {
+		plugin = path
+		local, isLocal = pluginCommand, true
+		run = newPluginCommand(path)
+	} else {
+		return nil, fmt.Errorf("invalid command: %s (run \"commands\" for a list)", name)
 	}
+	own := map[string]bool{}
+	cmdFlags.VisitAll(func(f *flag.Flag) { own[f.Name] = true })
+	opts.register(cmdFlags)
 
-	userConfig, err := client.NewUserConfig(host)
-	if err != nil {
+	cmdArgs := cmds[1:]
+	if plugin != "" {
+		// Everything after the name is for the plugin to parse
+		cmdArgs = append([]string{"--"}, cmdArgs...)
+	}
+	if err := cmdFlags.Parse(cmdArgs); err != nil {
+		if err != flag.ErrHelp {
+			slog.Error("Failed to parse arguments", "err", err)
+		}
 		return nil, err
 	}
 
-	sshClientConfig, err := client.NewSSHClientConfig(timeout, insecure, userConfig)
+	set := map[string]bool{}
+	markSet := func(f *flag.Flag) { set[f.Name] = true }
+	fs.Visit(markSet)
+	cmdFlags.Visit(markSet)
+	var globalArgs []string
+	fs.Visit(func(f *flag.Flag) { globalArgs = appendGlobalArg(globalArgs, f) })
+	cmdFlags.Visit(func(f *flag.Flag) {
+		if !own[f.Name] {
+			globalArgs = appendGlobalArg(globalArgs, f)
+		}
+	})
+	// Commands taking no arguments accept the host as one, as in "ps myhost"
+	hostArg := !isLocal || local.hostArg
+	cmdArgs = cmdFlags.Args()
+	if len(cmdArgs) == 1 && hostArg {
+		if set["host"] {
+			return nil, fmt.Errorf("%s takes no arguments, got %q as well as -host", name, cmdArgs[0])
+		}
+		opts.host, cmdArgs = cmdArgs[0], nil
+		set["host"] = true
+	}
+	if err := opts.applyEnv(set); err != nil {
+		return nil, err
+	}
+	if err := opts.applyConfig(opts.configFile, set, isLocal && local.noHost && plugin == ""); err != nil {
+		return nil, err
+	}
+
+	level, err := opts.logLevel()
 	if err != nil {
 		return nil, err
 	}
+	if opts.format != formatText && opts.format != formatJSON && opts.format != formatJSONStream {
+		return nil, fmt.Errorf("invalid -format %q (use %s, %s or %s)", opts.format, formatText, formatJSON, formatJSONStream)
+	}
+	if err := setColorMode(opts.color); err != nil {
+		return nil, err
+	}
+	var logOut io.Writer = logWriter{}
+	if colorEnabled(os.Stderr) {
+		logOut = colorLogWriter{logOut}
+	}
+	logger := newLogger(logOut, level)
+	if opts.format != formatText {
+		// Failures are reported as a single JSON object once the command
+		// has finished (see reportFailure), and other diagnostics as JSON
+		errLog = &errorLog{}
+		logger = slog.New(errorHandler{newJSONLogger(logWriter{}, level).Handler(), errLog})
+	}
+	if opts.dryRun {
+		if isLocal && local.noDryRun {
+			return nil, fmt.Errorf("%s does not support -dry-run", name)
+		}
+		logger = slog.New(dryRunHandler{logger.Handler()})
+	}
+	slog.SetDefault(logger)
+	showProgress = !opts.quiet
+
+	if opts.retries < 0 {
+		return nil, fmt.Errorf("-retries must not be negative")
+	}
+	if opts.replay != "" && (opts.record != "" || opts.dryRun) {
+		return nil, errors.New("-replay cannot be combined with -record or -dry-run")
+	}
+	if opts.record != "" && opts.dryRun {
+		return nil, errors.New("-record cannot be combined with -dry-run")
+	}
+	if opts.outputDir != "" && (opts.dryRun || opts.replay != "") {
+		return nil, errors.New("-output-dir cannot be combined with -dry-run or -replay")
+	}
+	if opts.requestTimeout < 0 {
+		return nil, fmt.Errorf("-request-timeout must not be negative")
+	}
+	var query url.Values
+	if len(filters) > 0 {
+		encoded, err := encodeFilters(filters)
+		if err != nil {
+			return nil, err
+		}
+		query = url.Values{"filters": {encoded}}
+	}
+	sources := 0
+	for _, v := range []string{opts.identityCmd, opts.credentialHelper, opts.vaultRole} {
+		if v != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, errors.New("-identity-cmd, -credential-helper and -vault-role are mutually exclusive")
+	}
+	if opts.vaultRole != "" && opts.vaultAddr == "" {
+		return nil, errors.New("-vault-role requires -vault-addr or VAULT_ADDR")
+	}
 
 	cli := &RemoteCLI{
-		addr:            userConfig.Addr(),
-		command:         *command,
-		sshClientConfig: sshClientConfig,
+		name:       name,
+		socketPath: opts.socket,
+		keepAlive:  opts.keepAlive,
+		retry:      client.RetryPolicy{Retries: opts.retries, Delay: opts.retryDelay},
+		command:    command,
+		args:       cmdArgs,
+		run:        run,
+		output:     output,
+		query:      query,
+		errors:     errLog,
+		opts:       opts,
+		globalArgs: globalArgs,
+	}
+	proxy := opts.proxy
+	if proxy == "" {
+		proxy = client.ProxyFromEnv()
+	}
+	if cli.proxy, err = client.ParseProxy(proxy); err != nil {
+		return nil, err
+	}
+	if opts.debug {
+		cli.tracer = client.NewTracer(logWriter{})
+	}
+	if cli.telemetry, err = client.NewTelemetryFromEnv("podman-cli " + name); err != nil {
+		return nil, err
+	}
+	if !isLocal || !local.streaming {
+		cli.requestTimeout = opts.requestTimeout
+	}
+	if opts.dryRun {
+		cli.dryRun = &dryRunTransport{out: os.Stdout, socketPath: cli.socketPath}
+	}
+	if opts.replay != "" {
+		if cli.replay, err = newReplayTransport(opts.replay); err != nil {
+			return nil, err
+		}
+		// No connection is made, so the host need not be known
+		cli.host = opts.host
+		return cli, nil
+	}
+	if opts.record != "" {
+		if cli.recorder, err = newRecording(opts.record); err != nil {
+			return nil, err
+		}
+	}
+	if opts.outputDir != "" {
+		if cli.dump, err = newResponseDump(opts.outputDir, name); err != nil {
+			return nil, err
+		}
+	}
+	if opts.auditLog != "" && !opts.dryRun {
+		if cli.audit, err = openAuditLog(opts.auditLog, name, cmds[1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.host == "" {
+		if isLocal && local.noHost {
+			return cli, nil
+		}
+		how := "-host <host>"
+		if hostArg {
+			how += " or " + name + " <host>"
+		}
+		return nil, fmt.Errorf("no host given: use %s, set %s or set host in %s", how, envName("host"), opts.configFile)
+	}
+
+	if err := cli.setHost(opts.host); err != nil {
+		return nil, err
 	}
 
 	return cli, nil
 }
 
+// apiCommandFlags defines the flags of the API command on fs, storing the
+// -output path in output and, for commands accepting them, the -filter
+// values in filters.
+func apiCommandFlags(fs *flag.FlagSet, command commands.Command, output *string, filters *stringsFlag) {
+	fs.StringVar(output, "output", "", "Write the response body to this file instead of stdout")
+	if command.Filters {
+		fs.Var(filters, "filter", filterUsage)
+	}
+}
+
+// writeBody streams the body of resp to out, followed by a newline, or to
+// the -output file with a progress meter, so that large responses are
+// never held in memory.
+func (rc *RemoteCLI) writeBody(resp *http.Response, out io.Writer) error {
+	if rc.output == "" {
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out)
+		return err
+	}
+
+	f, err := os.Create(rc.output)
+	if err != nil {
+		return err
+	}
+	pw := newProgressWriter(f, "Receiving", max(resp.ContentLength, 0))
+	_, err = io.Copy(pw, resp.Body)
+	pw.Close()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(rc.output)
+	}
+	return err
+}
+
+// defaultRequestTimeout is the default -request-timeout, long enough for
+// slow operations such as stopping containers.
+const defaultRequestTimeout = 2 * time.Minute
+
+// Output formats selected with -format.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	// formatJSONStream writes streaming output as one JSON event per line
+	formatJSONStream = "json-stream"
+)
+
+// envPrefix starts the name of the environment variables that provide
+// defaults for the global flags.
+const envPrefix = "PODMAN_CLI_"
+
+// envName returns the environment variable for the named global flag, such
+// as PODMAN_CLI_RETRY_DELAY for -retry-delay.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets the options whose flags are not in set from their
+// environment variables, and adds them to set.
+func (o *globalOptions) applyEnv(set map[string]bool) error {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	o.register(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || set[f.Name] || err != nil {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid %s: %w", envName(f.Name), setErr)
+			return
+		}
+		set[f.Name] = true
+	})
+	return err
+}
+
+// applyConfig sets the options whose flags are not in set from the
+// configuration file at path, using the profile selected by -profile. The
+// configured host is ignored when ignoreHost is set, for commands that do
+// not need one.
+func (o *globalOptions) applyConfig(path string, set map[string]bool, ignoreHost bool) error {
+	f, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	s, err := f.Resolve(o.profile)
+	if err != nil {
+		return err
+	}
+
+	if !set["host"] && !ignoreHost && s.Host != "" {
+		o.host = s.Host
+	}
+	if !set["timeout"] && s.Timeout != 0 {
+		o.timeout = s.Timeout
+	}
+	if !set["format"] && s.Format != "" {
+		o.format = s.Format
+	}
+	if !set["socket"] && s.Socket != "" {
+		o.socket = s.Socket
+	}
+	if !set["audit-log"] && s.AuditLog != "" {
+		o.auditLog = s.AuditLog
+	}
+	return nil
+}
+
+// setHost resolves host through the SSH configuration and prepares rc to
+// connect to it.
+func (rc *RemoteCLI) setHost(host string) error {
+	if rc.dryRun != nil {
+		// Nothing is dialed, so no key material is loaded
+		userConfig, err := client.LoadUserConfig(host, rc.opts.clientOptions(nil).Paths)
+		if err != nil {
+			return err
+		}
+		rc.host = host
+		rc.addr = userConfig.Addr()
+		rc.sshClientConfig = &ssh.ClientConfig{User: userConfig.User()}
+		return nil
+	}
+
+	creds, err := rc.opts.credentialProvider()
+	if err != nil {
+		return err
+	}
+
+	target, err := rc.opts.clientOptions(creds).Resolve(host)
+	if err != nil {
+		return err
+	}
+
+	rc.host = host
+	rc.addr = target.Addr
+	rc.sshClientConfig = target.Config
+	return nil
+}
+
+// clientOptions returns the connection settings given by the global flags,
+// taking key material from creds.
+func (o *globalOptions) clientOptions(creds client.CredentialProvider) client.Options {
+	return client.Options{
+		Paths:    client.Paths{Config: o.sshConfig, KnownHosts: o.knownHosts},
+		Timeout:  o.timeout,
+		Insecure: o.insecure,
+		Creds:    creds,
+	}
+}
+
+// credentialProvider returns the source of SSH key material selected by
+// -identity-cmd, -credential-helper or -vault-role, or nil to read the
+// identity file.
+func (o *globalOptions) credentialProvider() (client.CredentialProvider, error) {
+	switch {
+	case o.identityCmd != "":
+		return client.CommandProvider{Command: o.identityCmd}, nil
+	case o.credentialHelper != "":
+		return client.NewCredentialHelper(o.credentialHelper)
+	case o.vaultRole != "":
+		token, err := client.VaultToken()
+		if err != nil {
+			return nil, err
+		}
+		return client.VaultProvider{Addr: o.vaultAddr, Mount: o.vaultMount, Role: o.vaultRole, Token: token}, nil
+	}
+	return nil, nil
+}
+
+// forHost returns a copy of rc that connects to host instead, for commands
+// operating on more than one remote host.
+func (rc *RemoteCLI) forHost(host string) (*RemoteCLI, error) {
+	other := *rc
+	if err := other.setHost(host); err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	return &other, nil
+}
+
 // Run executes the configured Podman command on the remote host.
-// It establishes an SSH connection, tunnels to the Podman Unix socket,
+// Local commands are delegated to their own run function; otherwise Run
+// establishes an SSH connection, tunnels to the Podman Unix socket,
 // sends an HTTP request, and prints the response.
 //
 // The function returns an exit code:
@@ -101,65 +655,330 @@ func NewRemoteCLI(args []string) (*RemoteCLI, error) {
 //   - 1: failure (connection error, HTTP error, or non-2xx response)
 //
 // The response status and body are printed to stdout.
-// Errors are logged to stderr.
+// Errors are logged to stderr. With the JSON formats, a failure is reported
+// there as a single JSON object with the error, exit code, host and command.
+//
+// With -dry-run, the requests are printed to stdout instead, and the exit
+// code is 0 once the command got as far as its first request.
+//
+// The SSH connections of the command are shared through a pool, so that a
+// host connected to more than once is only dialed once, and are closed
+// when it returns. The hooks of the configuration file are then notified
+// of the outcome.
 func (rc *RemoteCLI) Run() int {
+	start := time.Now()
+	rc.pool = client.NewPool(0)
+	code := rc.runCommand(context.Background())
+	rc.pool.Close()
+	if rc.dryRunSent() {
+		code = 0
+	}
+	rc.finishRecording()
+	rc.finishAudit(code)
+	rc.reportFailure(code)
+	rc.exportTelemetry(code)
+	rc.notifyHooks(start, code)
+	return code
+}
+
+// exportTelemetry records the outcome of the command on its span and
+// exports the spans of the run, if tracing is enabled. Export failures
+// are logged, as they do not affect the command.
+func (rc *RemoteCLI) exportTelemetry(code int) {
+	if rc.telemetry == nil {
+		return
+	}
+	root := rc.telemetry.Root()
+	root.SetAttr("podman_cli.command", rc.name)
+	root.SetAttr("server.address", rc.host)
+	root.SetAttr("process.exit.code", code)
+	if code != 0 {
+		root.End(fmt.Errorf("exit code %d", code))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := rc.telemetry.Shutdown(ctx); err != nil {
+		slog.Warn("Failed to export traces", "err", err)
+	}
+}
+
+// runCommand runs the local or API command and returns its exit code.
+// API commands stop once ctx is done; local commands handle interrupts
+// themselves.
+func (rc *RemoteCLI) runCommand(ctx context.Context) int {
+	if rc.run != nil {
+		return rc.run(rc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Establish SSH connection to the remote host
-	sshClient, err := client.NewSSHClient(rc.addr, rc.sshClientConfig)
+	sshClient, httpClient, err := rc.connect(ctx)
 	if err != nil {
-		log.Printf("Failed while connecting to client: %v", err)
+		slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
 		return 1
 	}
 	defer sshClient.Close()
 
-	// Dial the remote Podman Unix socket through the SSH tunnel
-	remoteSocket := "/run/user/1000/podman/podman.sock"
-	conn, err := sshClient.Dial("unix", remoteSocket)
+	return rc.execute(ctx, httpClient, rc.command, rc.query, os.Stdout)
+}
+
+// connect establishes the SSH connection to the remote host and returns it
+// together with an HTTP client that sends requests to the remote Podman
+// Unix socket through the tunnel. Keepalives are sent on the connection
+// until it is closed.
+//
+// The connection is taken from rc.pool, so that the commands of a shell
+// session or the hosts of a multi-host command visited more than once
+// share a handshake; closing it returns it to the pool.
+func (rc *RemoteCLI) connect(ctx context.Context) (*client.Lease, *http.Client, error) {
+	if rc.dryRun != nil {
+		return client.NewLease(newDryRunClient()), &http.Client{Transport: rc.dryRun}, nil
+	}
+	if rc.replay != nil {
+		return client.NewLease(newDryRunClient()), &http.Client{Transport: rc.replay}, nil
+	}
+
+	key := client.PoolKey(rc.sshClientConfig.User, rc.addr, rc.proxy)
+	sshClient, err := rc.pool.Get(key, func() (*ssh.Client, error) {
+		sshClient, err := rc.dialSSH(ctx)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("SSH connection established", "addr", rc.addr)
+		// Keepalives run once per connection, for as long as it is open,
+		// as the pool hands it to later callers
+		keepAliveCtx, cancel := context.WithCancel(context.Background())
+		go func() {
+			sshClient.Wait()
+			cancel()
+		}()
+		go client.KeepAlive(keepAliveCtx, sshClient, rc.keepAlive)
+		return sshClient, nil
+	})
 	if err != nil {
-		log.Printf("dial remote socket: %v", err)
-		return 1
+		return nil, nil, err
+	}
+
+	if err := rc.ensureService(ctx, sshClient.Client); err != nil {
+		sshClient.Close()
+		return nil, nil, err
+	}
+
+	return sshClient, rc.apiClient(func() (net.Conn, error) {
+		return sshClient.Dial("unix", rc.socketPath)
+	}), nil
+}
+
+// apiClient returns an HTTP client sending requests to the Podman API over
+// the connections to the socket returned by dial, traced, recorded and
+// audited as the global flags ask.
+func (rc *RemoteCLI) apiClient(dial func() (net.Conn, error)) *http.Client {
+	if rc.tracer != nil {
+		dial = rc.tracer.Dial(rc.socketPath, dial)
+	}
+	if rc.telemetry != nil {
+		dial = rc.telemetry.Dial(rc.socketPath, dial)
+	}
+
+	httpClient := client.NewHTTPClient(dial)
+	httpClient.Timeout = rc.requestTimeout
+	if rc.recorder != nil {
+		httpClient.Transport = rc.recorder.transport(httpClient.Transport)
+	}
+	if rc.dump != nil {
+		httpClient.Transport = rc.dump.transport(httpClient.Transport, rc.host)
 	}
-	defer conn.Close()
+	if rc.audit != nil {
+		httpClient.Transport = rc.audit.transport(httpClient.Transport, rc.host)
+	}
+	if rc.tracer != nil {
+		httpClient.Transport = rc.tracer.Transport(httpClient.Transport)
+	}
+	if rc.telemetry != nil {
+		httpClient.Transport = rc.telemetry.Transport(httpClient.Transport)
+	}
+	return httpClient
+}
 
-	// Build the HTTP request for the Podman API
-	// Note: The Host header is required by http.ReadResponse, but the actual
-	// communication happens through the Unix socket over SSH
-	u := &url.URL{Scheme: "http", Host: "localhost", Path: rc.command.Path}
-	req := &http.Request{
-		Method: rc.command.Method,
-		URL:    u,
-		Host:   "localhost",
-		Header: make(http.Header),
+// ensureService checks that the Podman socket exists on the remote host,
+// as the API service is not enabled by default on many hosts. If it is
+// missing, the service is started when -ensure-service is given or the
+// user agrees to it at the terminal.
+func (rc *RemoteCLI) ensureService(ctx context.Context, sshClient *ssh.Client) error {
+	conn, err := sshClient.Dial("unix", rc.socketPath)
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+	if !client.IsSocketMissing(err) {
+		// Reported by the first request
+		return nil
 	}
 
-	// Write request to the connection
-	if err := req.Write(conn); err != nil {
-		log.Printf("Error with request: %v\n", err)
+	if !rc.opts.ensureService && !confirm(fmt.Sprintf("Podman API socket %s not found on %s. Start the Podman service?", rc.socketPath, rc.host)) {
+		return fmt.Errorf("podman API socket %s not found (start it with -ensure-service or \"systemctl --user enable --now podman.socket\" on the host)", rc.socketPath)
+	}
+	slog.Info("Starting Podman service", "host", rc.host, "socket", rc.socketPath)
+	return client.StartService(ctx, sshClient, rc.socketPath, os.Stderr)
+}
+
+// confirm asks question at the terminal and reports whether the user
+// answered yes. It returns false without asking if stdin is not a
+// terminal.
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprint(os.Stderr, question+" [y/N] ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// confirmDestructive asks whether to go ahead with action, such as
+// removing containers, naming the remote host it affects. It only asks
+// when stdin is a terminal, so that scripts are not held up, and not with
+// -yes or -dry-run.
+func (rc *RemoteCLI) confirmDestructive(action string) bool {
+	if rc.opts.yes || rc.opts.dryRun || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return true
+	}
+	return confirm(fmt.Sprintf("%s on %s. Are you sure?", action, rc.host))
+}
+
+// execute sends command to the Podman API using httpClient, with the given
+// query parameters, and writes the response status and body to out. It
+// returns the exit code for the command: 0 for a 2xx response and 1
+// otherwise.
+func (rc *RemoteCLI) execute(ctx context.Context, httpClient *http.Client, command commands.Command, query url.Values, out io.Writer) int {
+
+	// The Host header is required by HTTP but otherwise unused, since the
+	// request travels over the tunneled Unix socket
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: command.Path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, command.Method, u.String(), nil)
+	if err != nil {
+		slog.Error("Error with request", "err", err)
 		return 1
 	}
 
-	// Read response
-	br := bufio.NewReader(conn)
-	resp, err := http.ReadResponse(br, req)
+	slog.Debug("Sending request", "method", req.Method, "path", command.Path)
+
+	// Only requests that are safe to repeat are retried
+	policy := rc.retry
+	if !isIdempotent(req.Method) {
+		policy.Retries = 0
+	}
+
+	var resp *http.Response
+	err = policy.Do(ctx, func() error {
+		resp, err = httpClient.Do(req)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error with response: %s\n", err)
+		slog.Error("Error with response", "err", err)
 		return 1
 	}
 	defer resp.Body.Close()
 
-	// Print status and body
-	fmt.Println("Status:", resp.Status)
-	body := new(strings.Builder)
-	_, err = bufio.NewReader(resp.Body).WriteTo(body)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "read body: %v\n", err)
+	// With the JSON formats, listings are written as the rows of the local
+	// commands, unless saved with -output. The text format keeps printing
+	// the response as Podman sent it, which scripts parse.
+	ok := resp.StatusCode >= http.StatusOK && resp.StatusCode < 300
+	if table := apiTables[rc.name]; table != nil && ok && rc.output == "" && rc.opts.format != formatText {
+		headers, rows, err := table(resp.Body)
+		if err == nil {
+			err = writeTable(out, rc.opts.format, headers, rows)
+		}
+		if err != nil {
+			slog.Error("read body", "err", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Print status and body; the JSON formats only print the body so that
+	// it can be parsed
+	if rc.opts.format == formatText {
+		fmt.Fprintln(out, "Status:", resp.Status)
+	}
+	if err := rc.writeBody(resp, out); err != nil {
+		slog.Error("read body", "err", err)
 		return 1
 	}
-	fmt.Println(body.String())
 
 	// Use HTTP status code to determine exit code: non-2xx => failure
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+	if !ok {
 		return 1
 	}
 	return 0
 }
+
+// apiTables decode the responses of the API commands listing resources,
+// keyed by command name, into the headers and rows written by writeTable.
+var apiTables = map[string]func(body io.Reader) ([]string, []tuiRow, error){
+	"list_containers": func(body io.Reader) ([]string, []tuiRow, error) {
+		var containers []models.ContainerSummary
+		if err := json.NewDecoder(body).Decode(&containers); err != nil {
+			return nil, nil, err
+		}
+		rows, _ := containerRows(containers)
+		return containerHeaders, rows, nil
+	},
+	"list_images": func(body io.Reader) ([]string, []tuiRow, error) {
+		var images []models.ImageSummary
+		if err := json.NewDecoder(body).Decode(&images); err != nil {
+			return nil, nil, err
+		}
+		rows := make([]tuiRow, 0, len(images))
+		for _, img := range images {
+			tags := "<none>"
+			if len(img.RepoTags) > 0 {
+				tags = strings.Join(img.RepoTags, ",")
+			}
+			rows = append(rows, tuiRow{id: img.ID, cols: []string{shortID(img.ID), tags, formatSize(img.Size)}})
+		}
+		return []string{"IMAGE ID", "TAGS", "SIZE"}, rows, nil
+	},
+}
+
+// dialSSH establishes the SSH connection to the remote host, retrying
+// transient failures according to the configured retry policy.
+func (rc *RemoteCLI) dialSSH(ctx context.Context) (*ssh.Client, error) {
+	if rc.replay != nil {
+		return nil, fmt.Errorf("%s needs an SSH connection, which -replay does not provide", rc.name)
+	}
+	_, span := rc.telemetry.Start(ctx, "ssh.dial")
+	span.SetAttr("server.address", rc.addr)
+
+	var sshClient *ssh.Client
+	attempts := 0
+	err := rc.retry.Do(ctx, func() error {
+		attempts++
+		var err error
+		if rc.tracer != nil {
+			sshClient, err = rc.tracer.DialSSH(rc.proxy, rc.addr, rc.sshClientConfig)
+		} else {
+			sshClient, err = client.NewSSHClientVia(rc.proxy, rc.addr, rc.sshClientConfig)
+		}
+		if err != nil && client.IsTransient(err) {
+			slog.Warn("Connecting failed", "addr", rc.addr, "err", err)
+		}
+		return err
+	})
+	span.SetAttr("podman_cli.attempts", attempts)
+	span.End(err)
+	return sshClient, err
+}
+
+// isIdempotent reports whether requests with the given method can safely be
+// sent more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
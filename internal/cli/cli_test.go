@@ -1,17 +1,33 @@
 package cli
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/alexjch/podman-cli/internal/commands"
 )
 
+// setHomeDir makes dir the home directory reported by the OS for the
+// duration of the test: $HOME on Unix and %USERPROFILE% on Windows.
+func setHomeDir(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
+
 func setupTestSSHConfig(t *testing.T, tmpDir string) string {
 	sshDir := filepath.Join(tmpDir, ".ssh")
 	if err := os.MkdirAll(sshDir, 0700); err != nil {
@@ -58,9 +74,7 @@ func TestNewRemoteCLI_ValidArgs(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "list_containers"}
 	cli, err := NewRemoteCLI(args)
@@ -93,9 +107,7 @@ func TestNewRemoteCLI_MissingHost(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"list_containers"}
 	_, err := NewRemoteCLI(args)
@@ -112,9 +124,7 @@ func TestNewRemoteCLI_MissingCommand(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost"}
 	_, err := NewRemoteCLI(args)
@@ -131,9 +141,7 @@ func TestNewRemoteCLI_InvalidCommand(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "invalid_command"}
 	_, err := NewRemoteCLI(args)
@@ -150,9 +158,7 @@ func TestNewRemoteCLI_CustomTimeout(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "-timeout", "60s", "list_containers"}
 	cli, err := NewRemoteCLI(args)
@@ -169,9 +175,7 @@ func TestNewRemoteCLI_NoHostValidation(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "-no-host-validation", "list_containers"}
 	cli, err := NewRemoteCLI(args)
@@ -193,9 +197,7 @@ func TestNewRemoteCLI_InvalidFlagFormat(t *testing.T) {
 	tmpDir := t.TempDir()
 	setupTestSSHConfig(t, tmpDir)
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "-timeout", "invalid", "list_containers"}
 	_, err := NewRemoteCLI(args)
@@ -207,9 +209,7 @@ func TestNewRemoteCLI_InvalidFlagFormat(t *testing.T) {
 func TestNewRemoteCLI_NoConfigFile(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	args := []string{"-host", "testhost", "list_containers"}
 	_, err := NewRemoteCLI(args)
@@ -227,3 +227,341 @@ func TestRemoteCLI_Struct(t *testing.T) {
 		t.Errorf("RemoteCLI.addr = %q, want %q", cli.addr, "test.example.com:22")
 	}
 }
+
+func TestNewRemoteCLI_RetryFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	args := []string{"-host", "testhost", "-retries", "4", "-retry-delay", "250ms", "list_containers"}
+	cli, err := NewRemoteCLI(args)
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	if cli.retry.Retries != 4 {
+		t.Errorf("NewRemoteCLI() retry.Retries = %d, want 4", cli.retry.Retries)
+	}
+	if cli.retry.Delay != 250*time.Millisecond {
+		t.Errorf("NewRemoteCLI() retry.Delay = %v, want %v", cli.retry.Delay, 250*time.Millisecond)
+	}
+}
+
+func TestNewRemoteCLI_NegativeRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	args := []string{"-host", "testhost", "-retries", "-1", "list_containers"}
+	if _, err := NewRemoteCLI(args); err == nil {
+		t.Error("NewRemoteCLI() expected error for negative retries, got nil")
+	}
+}
+
+func TestNewRemoteCLI_ConfigFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	configDir := filepath.Join(tmpDir, ".config", "podman-cli")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	configData := `host = "testhost"
+timeout = "5s"
+
+[profiles.root]
+socket = "/run/podman/podman.sock"
+format = "json"
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	setHomeDir(t, tmpDir)
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantTimeout time.Duration
+		wantSocket  string
+		wantFormat  string
+	}{
+		{"defaults from file", []string{"list_containers"}, 5 * time.Second, "/run/user/1000/podman/podman.sock", "text"},
+		{"flag overrides file", []string{"list_containers", "-timeout", "1m"}, time.Minute, "/run/user/1000/podman/podman.sock", "text"},
+		{"profile", []string{"-profile", "root", "list_containers"}, 5 * time.Second, "/run/podman/podman.sock", "json"},
+		{"flag overrides profile", []string{"-profile", "root", "-format", "text", "list_containers"}, 5 * time.Second, "/run/podman/podman.sock", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := NewRemoteCLI(tt.args)
+			if err != nil {
+				t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+			}
+			if cli.host != "testhost" {
+				t.Errorf("NewRemoteCLI() host = %q, want %q", cli.host, "testhost")
+			}
+			if cli.opts.timeout != tt.wantTimeout {
+				t.Errorf("NewRemoteCLI() timeout = %v, want %v", cli.opts.timeout, tt.wantTimeout)
+			}
+			if cli.socketPath != tt.wantSocket {
+				t.Errorf("NewRemoteCLI() socketPath = %q, want %q", cli.socketPath, tt.wantSocket)
+			}
+			if cli.opts.format != tt.wantFormat {
+				t.Errorf("NewRemoteCLI() format = %q, want %q", cli.opts.format, tt.wantFormat)
+			}
+		})
+	}
+
+	if _, err := NewRemoteCLI([]string{"-profile", "staging", "list_containers"}); err == nil {
+		t.Error("NewRemoteCLI() expected error for unknown profile, got nil")
+	}
+}
+
+func TestNewRemoteCLI_InvalidFormat(t *testing.T) {
+	if _, err := NewRemoteCLI([]string{"-format", "yaml", "commands"}); err == nil {
+		t.Error("NewRemoteCLI() expected error for invalid -format, got nil")
+	}
+}
+
+func TestNewRemoteCLI_Env(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	configDir := filepath.Join(tmpDir, ".config", "podman-cli")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	configData := "host = \"otherhost\"\ntimeout = \"5s\"\nsocket = \"/config.sock\"\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	t.Setenv("PODMAN_CLI_HOST", "testhost")
+	t.Setenv("PODMAN_CLI_TIMEOUT", "20s")
+	t.Setenv("PODMAN_CLI_RETRY_DELAY", "3s")
+	t.Setenv("PODMAN_CLI_NO_HOST_VALIDATION", "true")
+
+	cli, err := NewRemoteCLI([]string{"-timeout", "1m", "list_containers"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	if cli.host != "testhost" {
+		t.Errorf("NewRemoteCLI() host = %q, want env value %q", cli.host, "testhost")
+	}
+	if cli.opts.timeout != time.Minute {
+		t.Errorf("NewRemoteCLI() timeout = %v, want flag value %v", cli.opts.timeout, time.Minute)
+	}
+	if cli.opts.retryDelay != 3*time.Second {
+		t.Errorf("NewRemoteCLI() retryDelay = %v, want env value %v", cli.opts.retryDelay, 3*time.Second)
+	}
+	if !cli.opts.insecure {
+		t.Error("NewRemoteCLI() insecure = false, want env value true")
+	}
+	if cli.socketPath != "/config.sock" {
+		t.Errorf("NewRemoteCLI() socketPath = %q, want config value %q", cli.socketPath, "/config.sock")
+	}
+
+	t.Setenv("PODMAN_CLI_RETRIES", "many")
+	if _, err := NewRemoteCLI([]string{"list_containers"}); err == nil || !strings.Contains(err.Error(), "PODMAN_CLI_RETRIES") {
+		t.Errorf("NewRemoteCLI() error = %v, want invalid PODMAN_CLI_RETRIES", err)
+	}
+}
+
+func TestNewRemoteCLI_CredentialSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	args := []string{"-host", "testhost", "-identity-cmd", "echo secret", "-credential-helper", "keychain", "list_containers"}
+	if _, err := NewRemoteCLI(args); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("NewRemoteCLI() error = %v, want mutually exclusive sources", err)
+	}
+
+	t.Setenv("VAULT_ADDR", "")
+	args = []string{"-host", "testhost", "-vault-role", "edge", "list_containers"}
+	if _, err := NewRemoteCLI(args); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Errorf("NewRemoteCLI() error = %v, want missing Vault address", err)
+	}
+
+	args = []string{"-host", "testhost", "-credential-helper", "vault", "list_containers"}
+	if _, err := NewRemoteCLI(args); err == nil || !strings.Contains(err.Error(), "unknown credential helper") {
+		t.Errorf("NewRemoteCLI() error = %v, want unknown credential helper", err)
+	}
+}
+
+func TestWriteBody(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	newResponse := func() *http.Response {
+		return &http.Response{Body: io.NopCloser(strings.NewReader(body)), ContentLength: int64(len(body))}
+	}
+
+	var out bytes.Buffer
+	rc := &RemoteCLI{}
+	if err := rc.writeBody(newResponse(), &out); err != nil {
+		t.Fatalf("writeBody() unexpected error = %v", err)
+	}
+	if out.String() != body+"\n" {
+		t.Errorf("writeBody() wrote %d bytes to stdout, want %d", out.Len(), len(body)+1)
+	}
+
+	out.Reset()
+	rc.output = filepath.Join(t.TempDir(), "body.json")
+	if err := rc.writeBody(newResponse(), &out); err != nil {
+		t.Fatalf("writeBody() unexpected error = %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("writeBody() wrote %q to stdout with -output", out.String())
+	}
+	if got, err := os.ReadFile(rc.output); err != nil || string(got) != body {
+		t.Errorf("writeBody() -output file has %d bytes (err %v), want %d", len(got), err, len(body))
+	}
+}
+
+func TestExecute_ListImages(t *testing.T) {
+	status := http.StatusOK
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"message":"boom"}`))
+			return
+		}
+		w.Write([]byte(`[{"Id":"sha256:0123456789abcdef0123","RepoTags":["nginx:1.27","nginx:latest"],"Size":1048576},{"Id":"fedcba9876543210fedc","Size":512}]`))
+	}))
+	command := *commands.IsCommand("list_images")
+
+	var out bytes.Buffer
+	rc := &RemoteCLI{name: "list_images", opts: globalOptions{format: formatJSON}}
+	if code := rc.execute(context.Background(), httpClient, command, nil, &out); code != 0 {
+		t.Errorf("execute() = %d, want 0", code)
+	}
+	var got []map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("execute() output %q is not a JSON array: %v", out.String(), err)
+	}
+	want := []map[string]string{
+		{"imageId": "0123456789ab", "tags": "nginx:1.27,nginx:latest", "size": formatSize(1048576)},
+		{"imageId": "fedcba987654", "tags": "<none>", "size": formatSize(512)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("execute() = %v, want %v", got, want)
+	}
+
+	// The text format prints the response as Podman returned it
+	out.Reset()
+	rc.opts.format = formatText
+	if code := rc.execute(context.Background(), httpClient, command, nil, &out); code != 0 {
+		t.Errorf("execute() = %d, want 0", code)
+	}
+	if !strings.HasPrefix(out.String(), "Status: 200 OK\n[{\"Id\":\"sha256:0123456789abcdef0123\"") {
+		t.Errorf("execute() output = %q, want the raw response", out.String())
+	}
+
+	// Errors are printed as Podman returned them
+	out.Reset()
+	status = http.StatusInternalServerError
+	rc.opts.format = formatText
+	if code := rc.execute(context.Background(), httpClient, command, nil, &out); code != 1 {
+		t.Errorf("execute() = %d, want 1 for an error status", code)
+	}
+	if want := "Status: 500 Internal Server Error\n{\"message\":\"boom\"}\n"; out.String() != want {
+		t.Errorf("execute() output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestNewRemoteCLI_OutputFlag(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	cli, err := NewRemoteCLI([]string{"-host", "testhost", "list_containers", "-output", "containers.json"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if cli.output != "containers.json" {
+		t.Errorf("NewRemoteCLI() output = %q, want %q", cli.output, "containers.json")
+	}
+}
+
+func TestNewRemoteCLI_FilterFlag(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	cli, err := NewRemoteCLI([]string{"-host", "testhost", "list_containers", "-filter", "label=owner=fleet", "-filter", "label=tier=edge"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if got, want := cli.query.Get("filters"), `{"label":["owner=fleet","tier=edge"]}`; got != want {
+		t.Errorf("NewRemoteCLI() filters = %s, want %s", got, want)
+	}
+
+	if _, err := NewRemoteCLI([]string{"-host", "testhost", "list_containers", "-filter", "label"}); err == nil {
+		t.Error("NewRemoteCLI() with -filter label error = nil, want an error")
+	}
+}
+
+func TestNewRemoteCLI_RequestTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", []string{"-host", "testhost", "list_containers"}, defaultRequestTimeout, false},
+		{"flag", []string{"-host", "testhost", "-request-timeout", "5s", "ps"}, 5 * time.Second, false},
+		{"disabled", []string{"-host", "testhost", "list_containers", "-request-timeout", "0"}, 0, false},
+		{"streaming command", []string{"-host", "testhost", "-request-timeout", "5s", "events"}, 0, false},
+		{"negative", []string{"-host", "testhost", "-request-timeout", "-1s", "ps"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := NewRemoteCLI(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRemoteCLI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cli.requestTimeout != tt.want {
+				t.Errorf("NewRemoteCLI() requestTimeout = %v, want %v", cli.requestTimeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRemoteCLI_HostArgument(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	for _, args := range [][]string{
+		{"ps", "testhost"},
+		{"ps", "-a", "testhost"},
+		{"list_containers", "testhost"},
+	} {
+		cli, err := NewRemoteCLI(args)
+		if err != nil {
+			t.Errorf("NewRemoteCLI(%q) unexpected error = %v", args, err)
+			continue
+		}
+		if cli.host != "testhost" || len(cli.args) != 0 {
+			t.Errorf("NewRemoteCLI(%q) host = %q, args = %q, want the host from the argument", args, cli.host, cli.args)
+		}
+	}
+
+	if _, err := NewRemoteCLI([]string{"-host", "testhost", "ps", "other"}); err == nil {
+		t.Error("NewRemoteCLI() expected error for a host given twice, got nil")
+	}
+
+	// Arguments of commands that take them are not hosts
+	_, err := NewRemoteCLI([]string{"logs", "testhost"})
+	if err == nil || !strings.Contains(err.Error(), "PODMAN_CLI_HOST") {
+		t.Errorf("NewRemoteCLI() error = %v, want one naming the ways to give the host", err)
+	}
+}
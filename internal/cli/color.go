@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Values of -color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI colors of the colored output. They all have the same length, so
+// that columns of a table stay aligned whichever color their cells get.
+const (
+	ansiGreen   = "\x1b[32m"
+	ansiRed     = "\x1b[31m"
+	ansiYellow  = "\x1b[33m"
+	ansiDefault = "\x1b[39m"
+)
+
+// colorMode is the -color setting, resolved by setColorMode.
+var colorMode = colorAuto
+
+// setColorMode sets colorMode from the -color value. With auto, the
+// NO_COLOR convention (https://no-color.org) disables colors.
+func setColorMode(mode string) error {
+	switch mode {
+	case colorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			mode = colorNever
+		}
+	case colorAlways, colorNever:
+	default:
+		return fmt.Errorf("invalid -color %q (use %s, %s or %s)", mode, colorAuto, colorAlways, colorNever)
+	}
+	colorMode = mode
+	return nil
+}
+
+// colorEnabled reports whether output written to f is colored: always
+// with -color always, and with auto when f is a terminal.
+func colorEnabled(f *os.File) bool {
+	switch colorMode {
+	case colorAlways:
+		return true
+	case colorAuto:
+		return term.IsTerminal(int(f.Fd()))
+	}
+	return false
+}
+
+// statusColor returns the color of a status such as running, exited or
+// unhealthy: green for those that are fine, red for failures and yellow
+// for states in between.
+func statusColor(status string) string {
+	word, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(status)), " ")
+	switch word {
+	case "up", "running", "healthy", "ok", "pulled", "present", "started":
+		return ansiGreen
+	case "exited", "dead", "unhealthy", "fail", "failed", "missing", "unreachable", "error":
+		return ansiRed
+	case "paused", "created", "starting", "stopping", "restarting", "removing", "outdated", "degraded":
+		return ansiYellow
+	}
+	return ansiDefault
+}
+
+// statusColumns are the table columns colored by statusColor.
+var statusColumns = map[string]bool{"STATUS": true, "STATE": true, "HEALTH": true}
+
+// colorTable returns copies of headers and rows with the cells of the
+// status columns colored. Headers of those columns are wrapped in color
+// codes of the same length as the cells, keeping the table aligned.
+func colorTable(headers []string, rows []tuiRow) ([]string, []tuiRow) {
+	var columns []int
+	for i, h := range headers {
+		if statusColumns[h] {
+			columns = append(columns, i)
+		}
+	}
+	if len(columns) == 0 {
+		return headers, rows
+	}
+
+	headers = append([]string(nil), headers...)
+	for _, i := range columns {
+		headers[i] = ansiDefault + headers[i] + ansiReset
+	}
+	colored := make([]tuiRow, len(rows))
+	for r, row := range rows {
+		colored[r] = tuiRow{id: row.id, cols: append([]string(nil), row.cols...)}
+		for _, i := range columns {
+			if i < len(row.cols) {
+				colored[r].cols[i] = statusColor(row.cols[i]) + row.cols[i] + ansiReset
+			}
+		}
+	}
+	return headers, colored
+}
+
+// colorLogWriter colors the diagnostics written by the text logger by
+// level: errors red and warnings yellow. The logger writes each record
+// with a single call to Write.
+type colorLogWriter struct {
+	w io.Writer
+}
+
+func (cw colorLogWriter) Write(p []byte) (int, error) {
+	var color string
+	switch {
+	case bytes.HasPrefix(p, []byte("level=ERROR")):
+		color = ansiRed
+	case bytes.HasPrefix(p, []byte("level=WARN")):
+		color = ansiYellow
+	default:
+		return cw.w.Write(p)
+	}
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	if _, err := cw.w.Write([]byte(color + string(line) + ansiReset + string(p[len(line):]))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
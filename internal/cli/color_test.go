@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetColorMode(t *testing.T) {
+	defer func() { colorMode = colorAuto }()
+
+	t.Setenv("NO_COLOR", "1")
+	if err := setColorMode(colorAuto); err != nil || colorMode != colorNever {
+		t.Errorf("setColorMode(auto) with NO_COLOR = %q, %v, want never", colorMode, err)
+	}
+	if err := setColorMode(colorAlways); err != nil || !colorEnabled(os.Stdout) {
+		t.Errorf("setColorMode(always) with NO_COLOR: colorEnabled() = false, err = %v", err)
+	}
+	if err := setColorMode("sometimes"); err == nil {
+		t.Error("setColorMode(sometimes) error = nil, want an error")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	if err := setColorMode(colorAuto); err != nil || colorMode != colorAuto {
+		t.Errorf("setColorMode(auto) = %q, %v", colorMode, err)
+	}
+	// Test output is not a terminal
+	if colorEnabled(os.Stdout) {
+		t.Error("colorEnabled() = true with auto for a non-terminal")
+	}
+}
+
+func TestStatusColor(t *testing.T) {
+	tests := map[string]string{
+		"Up 2 hours":               ansiGreen,
+		"running":                  ansiGreen,
+		"Exited (1) 3 minutes ago": ansiRed,
+		"unhealthy":                ansiRed,
+		"paused":                   ansiYellow,
+		"Created":                  ansiYellow,
+		"":                         ansiDefault,
+	}
+	for status, want := range tests {
+		if got := statusColor(status); got != want {
+			t.Errorf("statusColor(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestColorTable(t *testing.T) {
+	headers := []string{"NAMES", "STATUS", "IMAGE"}
+	rows := []tuiRow{
+		{id: "1", cols: []string{"web", "Up 2 hours", "nginx"}},
+		{id: "2", cols: []string{"db", "Exited (0)", "postgres"}},
+	}
+	coloredHeaders, colored := colorTable(headers, rows)
+	if rows[0].cols[1] != "Up 2 hours" || headers[1] != "STATUS" {
+		t.Error("colorTable() modified its arguments")
+	}
+	if colored[1].cols[1] != ansiRed+"Exited (0)"+ansiReset || colored[0].cols[0] != "web" {
+		t.Errorf("colorTable() rows = %q", colored)
+	}
+
+	// The column after the colored one starts at the same place in every
+	// line once the color codes are removed
+	lines := formatTable(coloredHeaders, colored)
+	strip := strings.NewReplacer(ansiGreen, "", ansiRed, "", ansiDefault, "", ansiReset, "")
+	want := strings.Index(strip.Replace(lines[0]), "IMAGE")
+	for _, line := range lines[1:] {
+		plain := strip.Replace(line)
+		if got := strings.LastIndex(plain, "  ") + 2; got != want {
+			t.Errorf("line %q: last column at %d, want %d", plain, got, want)
+		}
+	}
+}
+
+func TestColorLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := colorLogWriter{&out}
+	for _, line := range []string{"level=ERROR msg=failed\n", "level=WARN msg=careful\n", "level=INFO msg=fine\n"} {
+		if n, err := w.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+	want := ansiRed + "level=ERROR msg=failed" + ansiReset + "\n" + ansiYellow + "level=WARN msg=careful" + ansiReset + "\nlevel=INFO msg=fine\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/commands"
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func init() {
+	// Registered here rather than in localCommands, as completion looks
+	// up the other commands in it.
+	localCommands["completion"] = localCommand{
+		setup:    newCompletionCommand,
+		summary:  "Print a shell completion script",
+		usage:    "bash|zsh|fish",
+		examples: []string{"source <(podman-cli completion bash)"},
+		noHost:   true,
+		noDryRun: true,
+	}
+	localCommands[completeCommandName] = localCommand{setup: newCompleteCommand, noHost: true, noDryRun: true}
+}
+
+// completeCommandName is the hidden command called by the completion
+// scripts to compute the candidates for the word being completed.
+const completeCommandName = "__complete"
+
+// completionTimeout bounds the SSH connection made to complete remote
+// container, image and volume names, so that a slow host does not hang the shell.
+const completionTimeout = 5 * time.Second
+
+// Kinds of remote objects completed as command arguments.
+const (
+	completeContainers = "containers"
+	completeImages     = "images"
+	completeVolumes    = "volumes"
+)
+
+// completionArgs maps commands to the kind of remote object their
+// positional arguments name.
+var completionArgs = map[string]string{
+	"attach":            completeContainers,
+	"checkpoint":        completeContainers,
+	"commit":            completeContainers,
+	"copy_image":        completeImages,
+	"exec":              completeContainers,
+	"generate_kube":     completeContainers,
+	"generate_systemd":  completeContainers,
+	"healthcheck_run":   completeContainers,
+	"image_tree":        completeImages,
+	"init_container":    completeContainers,
+	"inspect":           completeContainers,
+	"logs":              completeContainers,
+	"mount_container":   completeContainers,
+	"pause":             completeContainers,
+	"port":              completeContainers,
+	"prefetch":          completeImages,
+	"push_image":        completeImages,
+	"rename":            completeContainers,
+	"restart":           completeContainers,
+	"restore":           completeContainers,
+	"rm":                completeContainers,
+	"rm_container":      completeContainers,
+	"save_image":        completeImages,
+	"start":             completeContainers,
+	"stop":              completeContainers,
+	"unmount_container": completeContainers,
+	"unpause":           completeContainers,
+	"update":            completeContainers,
+	"volume_export":     completeVolumes,
+	"volume_import":     completeVolumes,
+	"wait":              completeContainers,
+}
+
+// completionScripts holds the completion script for each supported shell.
+// Each script passes the words typed so far to the hidden __complete
+// command and offers the candidates it prints, one per line.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for podman-cli
+_podman_cli() {
+    local IFS=$'\n'
+    COMPREPLY=($(podman-cli __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _podman_cli podman-cli
+`,
+	"zsh": `#compdef podman-cli
+# zsh completion for podman-cli
+_podman_cli() {
+    local -a candidates
+    candidates=("${(@f)$(podman-cli __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _podman_cli podman-cli
+`,
+	"fish": `# fish completion for podman-cli
+function __podman_cli_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    podman-cli __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c podman-cli -f -a '(__podman_cli_complete)'
+`,
+}
+
+// newCompletionCommand returns the "completion" command, which prints the
+// completion script for the given shell.
+func newCompletionCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("completion: usage: completion bash|zsh|fish")
+			return 1
+		}
+		script, ok := completionScripts[rc.args[0]]
+		if !ok {
+			slog.Error("completion: unsupported shell (use bash, zsh or fish)", "shell", rc.args[0])
+			return 1
+		}
+		fmt.Print(script)
+		return 0
+	}
+}
+
+// newCompleteCommand returns the hidden "__complete" command. Its
+// arguments are the words of the command line after the program name, the
+// last being the word under completion, possibly empty. Matching
+// candidates are printed one per line. Remote container, image and volume
+// names are only offered when the words include -host.
+func newCompleteCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		lookup := func(host, kind string) []string {
+			names, err := rc.remoteNames(host, kind)
+			if err != nil {
+				slog.Debug("completion lookup failed", "host", host, "err", err)
+			}
+			return names
+		}
+		printCompletions(os.Stdout, completions(rc.args, lookup))
+		return 0
+	}
+}
+
+// printCompletions writes candidates to out, one per line.
+func printCompletions(out io.Writer, candidates []string) {
+	for _, c := range candidates {
+		fmt.Fprintln(out, c)
+	}
+}
+
+// completions returns the sorted candidates for the last of words, the
+// command line typed so far without the program name. lookup returns the
+// names of remote objects of a kind on a host.
+func completions(words []string, lookup func(host, kind string) []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+
+	var name, host string
+	fs := commandFlags("")
+	for i := 0; i < len(prev); i++ {
+		w := prev[i]
+		if !strings.HasPrefix(w, "-") || w == "-" {
+			if name == "" {
+				name = w
+				fs = commandFlags(name)
+			}
+			continue
+		}
+
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+		f := fs.Lookup(flagName)
+		if f == nil || hasValue || isBoolFlag(f) {
+			if flagName == "host" && hasValue {
+				host = value
+			}
+			continue
+		}
+
+		// The flag takes the next word as its value
+		if i+1 == len(prev) {
+			return nil
+		}
+		i++
+		if flagName == "host" {
+			host = prev[i]
+		}
+	}
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(cur, "-"):
+		fs.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+		prefix := strings.TrimLeft(cur, "-")
+		cur = "-" + prefix
+	case name == "":
+		candidates = allCommandNames()
+	case completionArgs[name] != "" && host != "":
+		candidates = lookup(host, completionArgs[name])
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// commandFlags returns a flag set with the global flags and those of the
+// named command. An empty or unknown name only yields the global flags.
+func commandFlags(name string) *flag.FlagSet {
+	fs := ownCommandFlags(name)
+	var opts globalOptions
+	opts.register(fs)
+	return fs
+}
+
+// ownCommandFlags returns a flag set with the flags of the named command
+// only, which is empty for an unknown name.
+func ownCommandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if target, ok := builtinAliases[name]; ok && !isCommand(name) {
+		name = target
+	}
+	if local, ok := localCommands[name]; ok {
+		local.setup(fs)
+	} else if command := commands.IsCommand(name); command != nil {
+		var output string
+		var filters stringsFlag
+		apiCommandFlags(fs, *command, &output, &filters)
+	}
+	return fs
+}
+
+// isBoolFlag reports whether f is a boolean flag, which takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// allCommandNames returns the sorted names of the local and API commands,
+// of the built-in aliases and of the plugins on PATH, leaving out hidden
+// ones.
+func allCommandNames() []string {
+	var names []string
+	for name := range localCommands {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	for name := range commands.Commands() {
+		names = append(names, name)
+	}
+	for name := range builtinAliases {
+		if !isCommand(name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range pluginNames() {
+		if _, ok := builtinAliases[name]; !ok && !isCommand(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remoteNames returns the names of the containers or images, depending on
+// kind, on host.
+func (rc *RemoteCLI) remoteNames(host, kind string) ([]string, error) {
+	rc.opts.timeout = completionTimeout
+	remote, err := rc.forHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	sshClient, httpClient, err := remote.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sshClient.Close()
+
+	var names []string
+	switch kind {
+	case completeContainers:
+		var containers []models.ContainerSummary
+		if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", url.Values{"all": {"true"}}, &containers); err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			for _, n := range c.Names {
+				names = append(names, strings.TrimPrefix(n, "/"))
+			}
+		}
+	case completeImages:
+		var images []models.ImageSummary
+		if err := getJSON(ctx, httpClient, "/v3.0.0/images/json", nil, &images); err != nil {
+			return nil, err
+		}
+		for _, img := range images {
+			for _, tag := range img.RepoTags {
+				if tag != "<none>:<none>" {
+					names = append(names, tag)
+				}
+			}
+		}
+	case completeVolumes:
+		var volumes []models.VolumeConfigResponse
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/volumes/json", nil, &volumes); err != nil {
+			return nil, err
+		}
+		for _, v := range volumes {
+			names = append(names, v.Name)
+		}
+	}
+	return names, nil
+}
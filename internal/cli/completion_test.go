@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	lookup := func(host, kind string) []string {
+		if host != "myhost" {
+			t.Errorf("lookup host = %q, want myhost", host)
+		}
+		if kind == completeImages {
+			return []string{"docker.io/library/alpine:latest"}
+		}
+		if kind == completeVolumes {
+			return []string{"pgdata"}
+		}
+		return []string{"web", "db", "worker"}
+	}
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"command names", []string{"sta"}, []string{"start", "status"}},
+		{"command after global flag", []string{"-host", "myhost", "unp"}, []string{"unpause"}},
+		{"global flags", []string{"-ret"}, []string{"-retries", "-retry-delay"}},
+		{"command flags", []string{"rm", "--vol"}, []string{"-volumes"}},
+		{"containers", []string{"-host", "myhost", "stop", "w"}, []string{"web", "worker"}},
+		{"host after command", []string{"stop", "-host=myhost", "d"}, []string{"db"}},
+		{"images", []string{"save_image", "-host", "myhost", ""}, []string{"docker.io/library/alpine:latest"}},
+		{"volumes", []string{"volume_export", "-host", "myhost", "pg"}, []string{"pgdata"}},
+		{"no host", []string{"stop", ""}, nil},
+		{"flag value", []string{"-host", ""}, nil},
+		{"no arguments", []string{"ps", "-a", ""}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := completions(tt.words, lookup)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("completions(%q) = %q, want %q", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllCommandNames_Hidden(t *testing.T) {
+	for _, name := range allCommandNames() {
+		if name == completeCommandName {
+			t.Errorf("allCommandNames() includes hidden %s", name)
+		}
+	}
+}
+
+func TestCompletionCommand_UnknownShell(t *testing.T) {
+	rc := &RemoteCLI{args: []string{"powershell"}}
+	run := newTestCommand(t, newCompletionCommand)
+
+	if code := run(rc); code != 1 {
+		t.Errorf("completion powershell exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,1010 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// Labels identifying the containers, networks and volumes of a Compose
+// project, as set by Docker Compose.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// composeFiles are the files looked for in the current directory when -f
+// is not given, in order.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// composeProject is a Compose file translated into the Podman resources
+// making up the project.
+type composeProject struct {
+	Name     string
+	Services []composeService // in the order they are started
+	Networks map[string]composeResource
+	Volumes  map[string]composeResource
+}
+
+// composeResource is a network or volume of a project. Resources are
+// named after the project unless they are external, in which case they
+// must already exist.
+type composeResource struct {
+	Name     string
+	External bool
+}
+
+// composeService is a service of a project, run as a single container.
+type composeService struct {
+	Name      string
+	Spec      containerSpec
+	DependsOn []string
+}
+
+// newComposeCommand returns the "compose" command, which deploys the
+// services of a local Compose file to the remote host. Each service runs
+// as one container, attached to the project's networks, in which the
+// other services are reachable by their names; networks and named volumes
+// are created, and missing images pulled, as needed.
+func newComposeCommand(fs *flag.FlagSet) runFunc {
+	var file, project, authFile string
+	var volumes, tlsVerify bool
+	fs.StringVar(&file, "f", "", "Compose file (default compose.yaml, compose.yml, docker-compose.yaml or docker-compose.yml)")
+	fs.StringVar(&project, "p", "", "Project name (default the file's name key, or the name of its directory)")
+	fs.BoolVar(&volumes, "volumes", false, "With down, also remove the project's named volumes")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file, for pulling images with up")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when pulling images with up")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("compose: usage: compose [flags] up|down|ps [flags]")
+			return 1
+		}
+		action := rc.args[0]
+
+		// Flags may also follow the action, as in "compose up -f app.yml"
+		if err := fs.Parse(rc.args[1:]); err != nil {
+			slog.Error("compose", "err", err)
+			return 1
+		}
+		if fs.NArg() > 0 {
+			slog.Error("compose: unexpected arguments", "args", strings.Join(fs.Args(), " "))
+			return 1
+		}
+		if action != "up" && action != "down" && action != "ps" {
+			slog.Error("compose: unknown action (use up, down or ps)", "action", action)
+			return 1
+		}
+
+		p, err := loadComposeFile(file, project)
+		if err != nil {
+			slog.Error("compose", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		switch action {
+		case "up":
+			pull := func(image string) error {
+				authHeader, err := registryAuth(authFile, image)
+				if err != nil {
+					return err
+				}
+				return pullImage(ctx, httpClient, image, tlsVerify, authHeader, io.Discard, nil)
+			}
+			err = composeUp(ctx, httpClient, p, pull, os.Stdout)
+		case "down":
+			err = composeDown(ctx, httpClient, p, volumes, os.Stdout)
+		case "ps":
+			err = composePs(ctx, httpClient, p, rc.opts.format, os.Stdout)
+		}
+		if err != nil {
+			slog.Error("compose "+action, "project", p.Name, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// loadComposeFile loads the Compose file at path, or the default one in
+// the current directory if path is empty. Variables in the file are
+// substituted from the local environment and the .env file next to it.
+func loadComposeFile(path, project string) (*composeProject, error) {
+	if path == "" {
+		for _, name := range composeFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("no compose file found (use -f)")
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+
+	dotEnv := map[string]string{}
+	if _, err := os.Stat(filepath.Join(dir, ".env")); err == nil {
+		if err := readEnvFile(filepath.Join(dir, ".env"), dotEnv); err != nil {
+			return nil, err
+		}
+	}
+	lookup := func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := dotEnv[key]
+		return v, ok
+	}
+
+	p, err := parseCompose(data, dir, project, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// parseCompose translates a Compose file into a project. Relative paths
+// in the file are taken from dir, whose name is the default project name,
+// and variables are looked up with lookup.
+func parseCompose(data []byte, dir, project string, lookup func(string) (string, bool)) (*composeProject, error) {
+	tree, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	if tree, err = interpolateTree(tree, lookup); err != nil {
+		return nil, err
+	}
+	top, err := composeMap(tree, "top level")
+	if err != nil {
+		return nil, err
+	}
+	for key := range top {
+		switch key {
+		case "version", "name", "services", "networks", "volumes":
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				slog.Warn("compose: ignoring unsupported key", "key", key)
+			}
+		}
+	}
+	return newComposeProject(top, dir, project)
+}
+
+// newComposeProject translates the top level of a Compose file, with its
+// variables substituted, into a project, as parseCompose does.
+func newComposeProject(top map[string]any, dir, project string) (*composeProject, error) {
+	if project == "" {
+		project, _ = top["name"].(string)
+	}
+	if project == "" {
+		project = filepath.Base(dir)
+	}
+	p := &composeProject{Name: projectName(project)}
+	if p.Name == "" {
+		return nil, fmt.Errorf("invalid project name %q (use -p)", project)
+	}
+
+	var err error
+	if p.Networks, err = p.resources(top["networks"], "networks"); err != nil {
+		return nil, err
+	}
+	if p.Volumes, err = p.resources(top["volumes"], "volumes"); err != nil {
+		return nil, err
+	}
+
+	services, err := composeMap(top["services"], "services")
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services defined")
+	}
+	byName := map[string]composeService{}
+	for name, def := range services {
+		svc, err := p.service(name, def, dir)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		byName[name] = svc
+	}
+	if p.Services, err = startOrder(byName); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// projectName normalizes a project name as Compose does: lower case, with
+// only letters, digits, dashes and underscores.
+func projectName(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' {
+			b.WriteRune(c)
+		}
+	}
+	return strings.TrimLeft(b.String(), "-_")
+}
+
+// resources decodes the top-level networks or volumes of the file.
+func (p *composeProject) resources(v any, what string) (map[string]composeResource, error) {
+	defs, err := composeMap(v, what)
+	if err != nil {
+		return nil, err
+	}
+	resources := map[string]composeResource{}
+	for key, def := range defs {
+		opts, err := composeMap(def, what+"."+key)
+		if err != nil {
+			return nil, err
+		}
+		r := composeResource{Name: p.Name + "_" + key}
+		if name, _ := opts["name"].(string); name != "" {
+			r.Name = name
+		}
+		if external, _ := opts["external"].(string); external == "true" {
+			r.External = true
+			if opts["name"] == nil {
+				r.Name = key
+			}
+		}
+		resources[key] = r
+	}
+	return resources, nil
+}
+
+// service translates the definition of the named service into a
+// container spec.
+func (p *composeProject) service(name string, def any, dir string) (composeService, error) {
+	opts, err := composeMap(def, "definition")
+	if err != nil {
+		return composeService{}, err
+	}
+	svc := composeService{Name: name}
+	s := &svc.Spec
+
+	for key, v := range opts {
+		switch key {
+		case "image":
+			s.Image, _ = v.(string)
+		case "container_name":
+			s.Name, _ = v.(string)
+		case "command", "entrypoint":
+			var args []string
+			if str, ok := v.(string); ok {
+				args, err = splitCommand(str)
+			} else {
+				args, err = composeStrings(v, key)
+			}
+			if key == "command" {
+				s.Command = args
+			} else {
+				s.Entrypoint = args
+			}
+		case "working_dir":
+			s.WorkDir, _ = v.(string)
+		case "user":
+			s.User, _ = v.(string)
+		case "restart":
+			policy, _ := v.(string)
+			s.RestartPolicy, s.RestartTries, err = parseRestartPolicy(policy)
+		case "labels":
+			var entries []string
+			if entries, err = composeKeyValues(v, key); err == nil {
+				s.Labels, err = parseKeyValues("labels", entries, true)
+			}
+		case "ports":
+			err = composePorts(s, v)
+		case "volumes":
+			err = p.composeVolumes(s, v)
+		case "extra_hosts":
+			var entries []string
+			if entries, err = composeKeyValues(v, key); err == nil {
+				for _, e := range entries {
+					var entry string
+					if entry, err = parseAddHost(strings.Replace(e, "=", ":", 1)); err != nil {
+						break
+					}
+					s.HostAdd = append(s.HostAdd, entry)
+				}
+			}
+		case "dns":
+			s.DNSServers, err = composeStrings(v, key)
+		case "depends_on":
+			if m, ok := v.(map[string]any); ok {
+				for dep := range m {
+					svc.DependsOn = append(svc.DependsOn, dep)
+				}
+				sort.Strings(svc.DependsOn)
+			} else {
+				svc.DependsOn, err = composeStrings(v, key)
+			}
+		case "environment", "env_file", "networks", "network_mode":
+			// Handled below, as they depend on each other
+		case "build":
+			err = fmt.Errorf("build is not supported: build and push the image, then refer to it with image")
+		default:
+			slog.Warn("compose: ignoring unsupported key", "service", name, "key", key)
+		}
+		if err != nil {
+			return svc, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if s.Image == "" {
+		return svc, fmt.Errorf("image is required")
+	}
+	if s.Name == "" {
+		s.Name = p.Name + "-" + name + "-1"
+	}
+	if s.Labels == nil {
+		s.Labels = map[string]string{}
+	}
+	s.Labels[composeProjectLabel] = p.Name
+	s.Labels[composeServiceLabel] = name
+
+	// Variables of environment override those of env_file
+	env := map[string]string{}
+	files, err := composeStrings(opts["env_file"], "env_file")
+	if err != nil {
+		return svc, err
+	}
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(dir, f)
+		}
+		if err := readEnvFile(f, env); err != nil {
+			return svc, err
+		}
+	}
+	entries, err := composeKeyValues(opts["environment"], "environment")
+	if err != nil {
+		return svc, err
+	}
+	for _, e := range entries {
+		if err := setEnv(env, e); err != nil {
+			return svc, fmt.Errorf("environment: %w", err)
+		}
+	}
+	if len(env) > 0 {
+		s.Env = env
+	}
+
+	if err := p.composeNetworks(s, name, opts["networks"], opts["network_mode"]); err != nil {
+		return svc, err
+	}
+	return svc, nil
+}
+
+// composePorts adds the published ports of a service to s, given in the
+// short syntax of -publish or as mappings with target, published,
+// host_ip and protocol keys.
+func composePorts(s *containerSpec, v any) error {
+	list, ok := v.([]any)
+	if !ok {
+		return fmt.Errorf("want a list")
+	}
+	for _, item := range list {
+		port, ok := item.(string)
+		if m, isMap := item.(map[string]any); isMap {
+			target, _ := m["target"].(string)
+			published, _ := m["published"].(string)
+			hostIP, _ := m["host_ip"].(string)
+			protocol, _ := m["protocol"].(string)
+			port = target
+			if published != "" {
+				port = published + ":" + port
+			}
+			if hostIP != "" {
+				port = hostIP + ":" + port
+			}
+			if protocol != "" {
+				port += "/" + protocol
+			}
+			ok = target != ""
+		}
+		if !ok {
+			return fmt.Errorf("want a port mapping such as 8080:80")
+		}
+		mapping, err := parsePublish(port)
+		if err != nil {
+			return err
+		}
+		s.PortMappings = append(s.PortMappings, mapping)
+	}
+	return nil
+}
+
+// composeVolumes adds the mounts of a service to s, given as
+// [source:]target[:options] or as mappings with type, source, target and
+// read_only keys. Sources that are absolute paths on the remote host are
+// bind mounted, others name volumes of the project; relative paths are
+// rejected as the files they refer to are local.
+func (p *composeProject) composeVolumes(s *containerSpec, v any) error {
+	list, ok := v.([]any)
+	if !ok {
+		return fmt.Errorf("want a list")
+	}
+	for _, item := range list {
+		var source, target string
+		var options []string
+		switch item := item.(type) {
+		case string:
+			parts := strings.Split(item, ":")
+			switch len(parts) {
+			case 1:
+				target = parts[0]
+			case 2, 3:
+				source, target = parts[0], parts[1]
+				if len(parts) == 3 {
+					options = strings.Split(parts[2], ",")
+				}
+			default:
+				return fmt.Errorf("invalid volume %q: want [source:]target[:options]", item)
+			}
+		case map[string]any:
+			source, _ = item["source"].(string)
+			target, _ = item["target"].(string)
+			if readOnly, _ := item["read_only"].(string); readOnly == "true" {
+				options = []string{"ro"}
+			}
+			if typ, _ := item["type"].(string); typ != "" && typ != "bind" && typ != "volume" {
+				return fmt.Errorf("volume type %q is not supported", typ)
+			}
+		default:
+			return fmt.Errorf("want a list of volumes")
+		}
+
+		switch {
+		case target == "" || !strings.HasPrefix(target, "/"):
+			return fmt.Errorf("invalid volume target %q: want an absolute path", target)
+		case source == "":
+			s.Volumes = append(s.Volumes, namedVolume{Dest: target, Options: options})
+		case strings.HasPrefix(source, "/"):
+			s.Mounts = append(s.Mounts, specMount{Destination: target, Type: "bind", Source: source, Options: append([]string{"rbind"}, options...)})
+		case strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~"):
+			return fmt.Errorf("relative bind mount %q is not supported: mounts refer to paths on the remote host", source)
+		default:
+			vol, ok := p.Volumes[source]
+			if !ok {
+				return fmt.Errorf("volume %q is not defined in the top-level volumes", source)
+			}
+			s.Volumes = append(s.Volumes, namedVolume{Name: vol.Name, Dest: target, Options: options})
+		}
+	}
+	return nil
+}
+
+// composeNetworks attaches the container of the named service to its
+// networks, with the service name as alias, or to the default network of
+// the project, which is then added to it. A network_mode such as host
+// replaces the networks.
+func (p *composeProject) composeNetworks(s *containerSpec, service string, networks, mode any) error {
+	if mode, _ := mode.(string); mode != "" {
+		if networks != nil {
+			return fmt.Errorf("network_mode and networks cannot be combined")
+		}
+		if strings.HasPrefix(mode, "service:") {
+			return fmt.Errorf("network_mode %q is not supported", mode)
+		}
+		ns, names, err := parseNetwork(mode)
+		if err != nil || len(names) > 0 {
+			return fmt.Errorf("invalid network_mode %q", mode)
+		}
+		s.NetNS = ns
+		return nil
+	}
+
+	aliases := map[string][]string{}
+	switch networks := networks.(type) {
+	case nil:
+		aliases["default"] = nil
+	case []any:
+		names, err := composeStrings(networks, "networks")
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			aliases[name] = nil
+		}
+	case map[string]any:
+		for name, opts := range networks {
+			m, err := composeMap(opts, "networks."+name)
+			if err != nil {
+				return err
+			}
+			if aliases[name], err = composeStrings(m["aliases"], "aliases"); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("networks: want a list or a mapping")
+	}
+
+	s.NetNS = &specNamespace{NSMode: "bridge"}
+	s.Networks = map[string]networkOptions{}
+	for name, extra := range aliases {
+		net, ok := p.Networks[name]
+		if !ok {
+			if name != "default" {
+				return fmt.Errorf("network %q is not defined in the top-level networks", name)
+			}
+			net = composeResource{Name: p.Name + "_default"}
+			p.Networks[name] = net
+		}
+		s.Networks[net.Name] = networkOptions{Aliases: append([]string{service}, extra...)}
+	}
+	return nil
+}
+
+// startOrder returns the services sorted so that each comes after those
+// it depends on, and by name otherwise.
+func startOrder(services map[string]composeService) ([]composeService, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var order []composeService
+	state := map[string]int{} // 1 while visiting, 2 once ordered
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		svc := services[name]
+		for _, dep := range svc.DependsOn {
+			if _, ok := services[dep]; !ok {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, svc)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// composeUp creates the networks and volumes of the project that do not
+// exist yet, then creates and starts its containers in dependency order,
+// starting those that already exist. The images of the containers to
+// create are pulled with pull unless already on the host.
+func composeUp(ctx context.Context, httpClient *http.Client, p *composeProject, pull func(image string) error, out io.Writer) error {
+	if _, err := projectResources(ctx, httpClient, p, true, out); err != nil {
+		return err
+	}
+
+	for _, svc := range p.Services {
+		name := svc.Spec.Name
+		exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/exists")
+		if err != nil {
+			return err
+		}
+		if !exists {
+			image := svc.Spec.Image
+			found, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(image)+"/exists")
+			if err != nil {
+				return err
+			}
+			if !found {
+				if err := pull(image); err != nil {
+					return fmt.Errorf("service %s: pull %s: %w", svc.Name, image, err)
+				}
+				fmt.Fprintf(out, "Pulled image %s\n", image)
+			}
+			if _, err := createContainer(ctx, httpClient, svc.Spec); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+			fmt.Fprintf(out, "Created container %s\n", name)
+		}
+		if err := containerAction(ctx, httpClient, name, "start"); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		fmt.Fprintf(out, "Started container %s\n", name)
+	}
+	return nil
+}
+
+// projectResources returns the networks and volumes of the project that
+// do not exist yet, as "network <name>" or "volume <name>", creating them
+// if create is set. External ones must exist.
+func projectResources(ctx context.Context, httpClient *http.Client, p *composeProject, create bool, out io.Writer) ([]string, error) {
+	labels := map[string]string{composeProjectLabel: p.Name}
+	var missing []string
+	for _, kind := range []struct {
+		name      string
+		resources map[string]composeResource
+		body      func(name string) any
+	}{
+		{"networks", p.Networks, func(name string) any { return map[string]any{"name": name, "labels": labels} }},
+		{"volumes", p.Volumes, func(name string) any { return map[string]any{"Name": name, "Label": labels} }},
+	} {
+		for _, key := range sortedKeys(kind.resources) {
+			r := kind.resources[key]
+			exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/"+kind.name+"/"+url.PathEscape(r.Name)+"/exists")
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				continue
+			}
+			if r.External {
+				return nil, fmt.Errorf("external %s %s does not exist", strings.TrimSuffix(kind.name, "s"), r.Name)
+			}
+			missing = append(missing, strings.TrimSuffix(kind.name, "s")+" "+r.Name)
+			if !create {
+				continue
+			}
+			if err := postJSON(ctx, httpClient, "/v3.0.0/libpod/"+kind.name+"/create", kind.body(r.Name)); err != nil {
+				return nil, fmt.Errorf("create %s: %w", r.Name, err)
+			}
+			fmt.Fprintf(out, "Created %s %s\n", strings.TrimSuffix(kind.name, "s"), r.Name)
+		}
+	}
+	return missing, nil
+}
+
+// composeDown removes the containers of the project, including those of
+// services no longer in the file, and its networks, along with its
+// volumes if volumes is set. External networks and volumes are kept.
+func composeDown(ctx context.Context, httpClient *http.Client, p *composeProject, volumes bool, out io.Writer) error {
+	filters, err := encodeFilters([]string{"label=" + composeProjectLabel + "=" + p.Name})
+	if err != nil {
+		return err
+	}
+	names, err := filteredContainers(ctx, httpClient, filters)
+	if err != nil {
+		return err
+	}
+
+	// Dependents are removed before the services they depend on
+	var order []string
+	for i := len(p.Services) - 1; i >= 0; i-- {
+		if name := p.Services[i].Spec.Name; slices.Contains(names, name) {
+			order = append(order, name)
+		}
+	}
+	order = appendUnique(order, names...)
+	for _, name := range order {
+		if err := removeContainer(ctx, httpClient, name, removeOptions{force: true, volumes: true}); err != nil && !isStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+		fmt.Fprintf(out, "Removed container %s\n", name)
+	}
+
+	kinds := []struct {
+		name      string
+		resources map[string]composeResource
+	}{{"networks", p.Networks}}
+	if volumes {
+		kinds = append(kinds, struct {
+			name      string
+			resources map[string]composeResource
+		}{"volumes", p.Volumes})
+	}
+	for _, kind := range kinds {
+		for _, key := range sortedKeys(kind.resources) {
+			r := kind.resources[key]
+			if r.External {
+				continue
+			}
+			resp, err := apiRequest(ctx, httpClient, http.MethodDelete, "/v3.0.0/libpod/"+kind.name+"/"+url.PathEscape(r.Name), nil, nil)
+			if isStatus(err, http.StatusNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("remove %s: %w", r.Name, err)
+			}
+			resp.Body.Close()
+			fmt.Fprintf(out, "Removed %s %s\n", strings.TrimSuffix(kind.name, "s"), r.Name)
+		}
+	}
+	return nil
+}
+
+// composePs lists the containers of the project with their service.
+func composePs(ctx context.Context, httpClient *http.Client, p *composeProject, format string, out io.Writer) error {
+	filters, err := encodeFilters([]string{"label=" + composeProjectLabel + "=" + p.Name})
+	if err != nil {
+		return err
+	}
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", url.Values{"all": {"true"}, "filters": {filters}}, &containers); err != nil {
+		return err
+	}
+
+	rows := make([]tuiRow, 0, len(containers))
+	for _, c := range containers {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		rows = append(rows, tuiRow{id: name, cols: []string{name, c.Labels[composeServiceLabel], c.Image, c.Status}})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+	return writeTable(out, format, []string{"NAME", "SERVICE", "IMAGE", "STATUS"}, rows)
+}
+
+// resourceExists queries an exists endpoint, which answers 204 if the
+// resource exists and 404 if not.
+func resourceExists(ctx context.Context, httpClient *http.Client, path string) (bool, error) {
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, path, nil, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, resp.Body.Close()
+}
+
+// postJSON sends v as the JSON body of a POST request to path.
+func postJSON(ctx context.Context, httpClient *http.Client, path string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, path, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// composeMap returns v as a mapping; an empty value is an empty mapping.
+func composeMap(v any, what string) (map[string]any, error) {
+	switch v := v.(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s: want a mapping", what)
+}
+
+// composeStrings returns v, a string or a list of strings, as a list.
+func composeStrings(v any, what string) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: want a list of strings", what)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%s: want a string or a list of strings", what)
+}
+
+// composeKeyValues returns v, a list of key=value entries or a mapping,
+// as a list of entries. Keys mapped to an empty value are returned bare.
+func composeKeyValues(v any, what string) ([]string, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return composeStrings(v, what)
+	}
+	var entries []string
+	for _, key := range sortedKeys(m) {
+		switch value := m[key].(type) {
+		case nil:
+			entries = append(entries, key)
+		case string:
+			entries = append(entries, key+"="+value)
+		default:
+			return nil, fmt.Errorf("%s.%s: want a string", what, key)
+		}
+	}
+	return entries, nil
+}
+
+// splitCommand splits a command given as a string into arguments at
+// spaces, as a shell would, keeping quoted strings together.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// interpolateTree substitutes variables in the string values of tree.
+func interpolateTree(tree any, lookup func(string) (string, bool)) (any, error) {
+	switch v := tree.(type) {
+	case string:
+		return interpolate(v, lookup)
+	case []any:
+		for i, item := range v {
+			var err error
+			if v[i], err = interpolateTree(item, lookup); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]any:
+		for key, item := range v {
+			var err error
+			if v[key], err = interpolateTree(item, lookup); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tree, nil
+}
+
+// interpolate substitutes the variables in s as Compose does: $VAR and
+// ${VAR} are replaced with the value of VAR, or nothing if unset;
+// ${VAR:-default} and ${VAR-default} give a default for an unset (or, with
+// the colon, empty) variable, and ${VAR:?message} and ${VAR?message} fail
+// instead. $$ is a literal $.
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := expandVariable(s[i+2:i+end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		case next == '_' || 'a' <= next && next <= 'z' || 'A' <= next && next <= 'Z':
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || 'a' <= s[end] && s[end] <= 'z' || 'A' <= s[end] && s[end] <= 'Z' || '0' <= s[end] && s[end] <= '9') {
+				end++
+			}
+			value, _ := expandVariable(s[i+1:end], lookup)
+			b.WriteString(value)
+			i = end - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// expandVariable returns the value of a ${...} expression.
+func expandVariable(expr string, lookup func(string) (string, bool)) (string, error) {
+	name, op, arg := expr, "", ""
+	if i := strings.IndexAny(expr, ":-?"); i >= 0 {
+		name, op = expr[:i], expr[i:i+1]
+		if op == ":" && i+1 < len(expr) {
+			op = expr[i : i+2]
+		}
+		arg = expr[i+len(op):]
+	}
+	if !validEnvKey(name) {
+		return "", fmt.Errorf("invalid variable ${%s}", expr)
+	}
+
+	value, set := lookup(name)
+	switch op {
+	case "":
+		if !set {
+			slog.Warn("compose: variable is not set, using an empty string", "variable", name)
+		}
+		return value, nil
+	case "-":
+		if !set {
+			return arg, nil
+		}
+	case ":-":
+		if value == "" {
+			return arg, nil
+		}
+	case "?":
+		if !set {
+			return "", fmt.Errorf("variable %s is required: %s", name, arg)
+		}
+	case ":?":
+		if value == "" {
+			return "", fmt.Errorf("variable %s is required: %s", name, arg)
+		}
+	default:
+		return "", fmt.Errorf("invalid variable ${%s}", expr)
+	}
+	return value, nil
+}
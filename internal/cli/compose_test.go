@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testComposeFile = `
+name: Shop
+services:
+  web:
+    image: nginx:${WEB_TAG:-latest}
+    ports:
+      - "8080:80"
+      - target: 443
+        published: "8443"
+    depends_on: [api]
+    networks: [front]
+  api:
+    image: myapp
+    command: serve --port "8000"
+    environment:
+      DB_HOST: db
+      MODE:
+    env_file: api.env
+    volumes:
+      - data:/var/lib/app
+      - /etc/ssl:/etc/ssl:ro
+      - /tmp/cache
+    networks:
+      front:
+      back:
+        aliases: [backend]
+    depends_on:
+      db:
+        condition: service_started
+    restart: unless-stopped
+    labels:
+      tier: api
+  db:
+    image: postgres
+    container_name: shop-db
+    environment:
+      - POSTGRES_PASSWORD=$$ecret
+networks:
+  front:
+  back:
+    name: shared
+    external: true
+volumes:
+  data:
+x-common:
+  anything: goes
+`
+
+func TestParseCompose(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "api.env"), []byte("DB_HOST=localhost\nDB_PORT=5432\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(key string) (string, bool) { return "", false }
+
+	p, err := parseCompose([]byte(testComposeFile), dir, "", lookup)
+	if err != nil {
+		t.Fatalf("parseCompose() unexpected error = %v", err)
+	}
+	if p.Name != "shop" {
+		t.Errorf("Name = %q, want shop", p.Name)
+	}
+	var order []string
+	for _, svc := range p.Services {
+		order = append(order, svc.Name)
+	}
+	if strings.Join(order, ",") != "db,api,web" {
+		t.Errorf("start order = %v, want db,api,web", order)
+	}
+	wantNetworks := map[string]composeResource{
+		"front":   {Name: "shop_front"},
+		"back":    {Name: "shared", External: true},
+		"default": {Name: "shop_default"},
+	}
+	if !reflect.DeepEqual(p.Networks, wantNetworks) {
+		t.Errorf("Networks = %v, want %v", p.Networks, wantNetworks)
+	}
+
+	db, api, web := p.Services[0].Spec, p.Services[1].Spec, p.Services[2].Spec
+	if db.Name != "shop-db" || db.Env["POSTGRES_PASSWORD"] != "$ecret" {
+		t.Errorf("db = %+v", db)
+	}
+	if _, ok := db.Networks["shop_default"]; !ok {
+		t.Errorf("db networks = %v, want shop_default", db.Networks)
+	}
+
+	if api.Name != "shop-api-1" || !reflect.DeepEqual(api.Command, []string{"serve", "--port", "8000"}) {
+		t.Errorf("api name = %q, command = %q", api.Name, api.Command)
+	}
+	wantEnv := map[string]string{"DB_HOST": "db", "DB_PORT": "5432"}
+	if !reflect.DeepEqual(api.Env, wantEnv) {
+		t.Errorf("api env = %v, want %v", api.Env, wantEnv)
+	}
+	wantLabels := map[string]string{"tier": "api", composeProjectLabel: "shop", composeServiceLabel: "api"}
+	if !reflect.DeepEqual(api.Labels, wantLabels) {
+		t.Errorf("api labels = %v, want %v", api.Labels, wantLabels)
+	}
+	wantVolumes := []namedVolume{{Name: "shop_data", Dest: "/var/lib/app"}, {Dest: "/tmp/cache"}}
+	if !reflect.DeepEqual(api.Volumes, wantVolumes) {
+		t.Errorf("api volumes = %+v, want %+v", api.Volumes, wantVolumes)
+	}
+	wantMounts := []specMount{{Destination: "/etc/ssl", Type: "bind", Source: "/etc/ssl", Options: []string{"rbind", "ro"}}}
+	if !reflect.DeepEqual(api.Mounts, wantMounts) {
+		t.Errorf("api mounts = %+v, want %+v", api.Mounts, wantMounts)
+	}
+	wantAPINetworks := map[string]networkOptions{
+		"shop_front": {Aliases: []string{"api"}},
+		"shared":     {Aliases: []string{"api", "backend"}},
+	}
+	if !reflect.DeepEqual(api.Networks, wantAPINetworks) || api.NetNS.NSMode != "bridge" {
+		t.Errorf("api networks = %+v, want %+v", api.Networks, wantAPINetworks)
+	}
+	if api.RestartPolicy != "unless-stopped" {
+		t.Errorf("api restart policy = %q", api.RestartPolicy)
+	}
+
+	if web.Image != "nginx:latest" || len(web.PortMappings) != 2 || web.PortMappings[1].HostPort != 8443 || web.PortMappings[1].ContainerPort != 443 {
+		t.Errorf("web = %+v", web)
+	}
+
+	// Variables and -p override the defaults
+	lookup = func(key string) (string, bool) { return "1.25", key == "WEB_TAG" }
+	if p, err = parseCompose([]byte(testComposeFile), dir, "Other", lookup); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "other" || p.Services[2].Spec.Image != "nginx:1.25" {
+		t.Errorf("name = %q, web image = %q", p.Name, p.Services[2].Spec.Image)
+	}
+}
+
+func TestParseCompose_Errors(t *testing.T) {
+	tests := []struct {
+		name, file, want string
+	}{
+		{"no services", "services: {}\n", "no services"},
+		{"no image", "services:\n  web:\n    command: x\n", "image is required"},
+		{"build", "services:\n  web:\n    build: .\n", "build is not supported"},
+		{"cycle", "services:\n  a:\n    image: x\n    depends_on: [b]\n  b:\n    image: x\n    depends_on: [a]\n", "dependency cycle: a -> b -> a"},
+		{"unknown dependency", "services:\n  a:\n    image: x\n    depends_on: [b]\n", "undefined service b"},
+		{"relative bind", "services:\n  a:\n    image: x\n    volumes: [./data:/data]\n", "remote host"},
+		{"undeclared volume", "services:\n  a:\n    image: x\n    volumes: [data:/data]\n", `volume "data" is not defined`},
+		{"undeclared network", "services:\n  a:\n    image: x\n    networks: [back]\n", `network "back" is not defined`},
+		{"required variable", "services:\n  a:\n    image: ${IMAGE:?set IMAGE}\n", "variable IMAGE is required: set IMAGE"},
+		{"port", "services:\n  a:\n    image: x\n    ports: [http]\n", "ports"},
+	}
+	lookup := func(string) (string, bool) { return "", false }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCompose([]byte(tt.file), "/srv/app", "", lookup)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseCompose() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "EMPTY": ""}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	tests := []struct{ in, want string }{
+		{"$HOST:80", "example.com:80"},
+		{"${HOST}x", "example.comx"},
+		{"${EMPTY:-def} ${EMPTY-def} ${UNSET-def}", "def  def"},
+		{"$$HOST $1 ${UNSET}", "$HOST $1 "},
+		{"trailing $", "trailing $"},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.in, lookup)
+		if err != nil || got != tt.want {
+			t.Errorf("interpolate(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"${EMPTY:?is empty}", "${HOST", "${1X}"} {
+		if _, err := interpolate(in, lookup); err == nil {
+			t.Errorf("interpolate(%q) error = nil, want an error", in)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	got, err := splitCommand(`sh -c 'echo "hi there"' a\ b  "x y"`)
+	want := []string{"sh", "-c", `echo "hi there"`, "a b", "x y"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("splitCommand() = %q, %v, want %q", got, err, want)
+	}
+	if _, err := splitCommand(`echo "oops`); err == nil {
+		t.Error("splitCommand() error = nil, want an unterminated quote error")
+	}
+}
+
+func TestComposeUpDown(t *testing.T) {
+	file := "services:\n  web:\n    image: nginx\n    depends_on: [db]\n  db:\n    image: postgres\n    volumes: [data:/var/lib/postgresql]\nvolumes:\n  data:\n"
+	p, err := parseCompose([]byte(file), "/srv/shop", "", func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var requests []string
+	existing := map[string]bool{"/v3.0.0/libpod/containers/shop-db-1/exists": true}
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/exists"):
+			if !existing[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"no such object"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/v3.0.0/libpod/containers/create":
+			var spec containerSpec
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &spec); err != nil || spec.Name != "shop-web-1" {
+				t.Errorf("create body = %s", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		case r.URL.Path == "/v3.0.0/containers/json":
+			if got := r.URL.Query().Get("filters"); got != `{"label":["com.docker.compose.project=shop"]}` {
+				t.Errorf("filters = %s", got)
+			}
+			w.Write([]byte(`[{"Names":["/shop-web-1"],"Image":"nginx","Status":"Up","Labels":{"com.docker.compose.service":"web"}},{"Names":["/shop-old-1"]},{"Names":["/shop-db-1"]}]`))
+		case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/networks/"):
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such network"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	var pulled []string
+	pull := func(image string) error {
+		pulled = append(pulled, image)
+		return nil
+	}
+	var out bytes.Buffer
+	if err := composeUp(context.Background(), httpClient, p, pull, &out); err != nil {
+		t.Fatalf("composeUp() unexpected error = %v", err)
+	}
+	want := []string{
+		"GET /v3.0.0/libpod/networks/shop_default/exists",
+		"POST /v3.0.0/libpod/networks/create",
+		"GET /v3.0.0/libpod/volumes/shop_data/exists",
+		"POST /v3.0.0/libpod/volumes/create",
+		"GET /v3.0.0/libpod/containers/shop-db-1/exists",
+		"POST /v3.0.0/libpod/containers/shop-db-1/start",
+		"GET /v3.0.0/libpod/containers/shop-web-1/exists",
+		"GET /v3.0.0/libpod/images/nginx/exists",
+		"POST /v3.0.0/libpod/containers/create",
+		"POST /v3.0.0/libpod/containers/shop-web-1/start",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("up requests =\n%s\nwant\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+	if want := []string{"nginx"}; !reflect.DeepEqual(pulled, want) {
+		t.Errorf("pulled images = %q, want %q", pulled, want)
+	}
+	if !strings.Contains(out.String(), "Pulled image nginx\nCreated container shop-web-1\nStarted container shop-web-1\n") {
+		t.Errorf("up output = %q", out.String())
+	}
+
+	requests = nil
+	out.Reset()
+	if err := composeDown(context.Background(), httpClient, p, true, &out); err != nil {
+		t.Fatalf("composeDown() unexpected error = %v", err)
+	}
+	want = []string{
+		"GET /v3.0.0/containers/json",
+		"DELETE /v3.0.0/libpod/containers/shop-web-1",
+		"DELETE /v3.0.0/libpod/containers/shop-db-1",
+		"DELETE /v3.0.0/libpod/containers/shop-old-1",
+		"DELETE /v3.0.0/libpod/networks/shop_default",
+		"DELETE /v3.0.0/libpod/volumes/shop_data",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("down requests =\n%s\nwant\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+
+	out.Reset()
+	if err := composePs(context.Background(), httpClient, p, formatText, &out); err != nil {
+		t.Fatalf("composePs() unexpected error = %v", err)
+	}
+	if !strings.Contains(out.String(), "shop-web-1") || !strings.Contains(out.String(), "SERVICE") {
+		t.Errorf("ps output = %q", out.String())
+	}
+}
@@ -0,0 +1,592 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// newCommitCommand returns the "commit" command, which creates an image
+// from a remote container's changes.
+func newCommitCommand(fs *flag.FlagSet) runFunc {
+	var author, message string
+	var pause bool
+	fs.StringVar(&author, "author", "", "Author of the new image")
+	fs.StringVar(&message, "m", "", "Commit message for the new image")
+	fs.BoolVar(&pause, "pause", false, "Pause the container while committing")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) < 1 || len(rc.args) > 2 {
+			slog.Error("commit: usage: commit [flags] <container> [<repo>[:<tag>]]")
+			return 1
+		}
+		name := rc.args[0]
+
+		query := url.Values{
+			"container": {name},
+			"pause":     {strconv.FormatBool(pause)},
+		}
+		if len(rc.args) == 2 {
+			repo, tag := splitImageTag(rc.args[1])
+			query.Set("repo", repo)
+			if tag != "" {
+				query.Set("tag", tag)
+			}
+		}
+		if author != "" {
+			query.Set("author", author)
+		}
+		if message != "" {
+			query.Set("comment", message)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		id, err := commitContainer(ctx, httpClient, query)
+		if err != nil {
+			slog.Error("commit", "target", name, "err", err)
+			return 1
+		}
+
+		fmt.Println(id)
+		return 0
+	}
+}
+
+// commitContainer creates an image from the container named in query and
+// returns the new image's ID.
+func commitContainer(ctx context.Context, httpClient *http.Client, query url.Values) (string, error) {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/commit", query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	return result.ID, nil
+}
+
+// splitImageTag splits an image reference into its repository and tag. A
+// colon belonging to a registry port is not taken as the tag separator.
+func splitImageTag(ref string) (repo, tag string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// newRenameCommand returns the "rename" command, which renames a remote
+// container.
+func newRenameCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 2 {
+			slog.Error("rename: usage: rename <container> <new-name>")
+			return 1
+		}
+		name, newName := rc.args[0], rc.args[1]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		query := url.Values{"name": {newName}}
+		resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/rename", query, nil)
+		if err != nil {
+			slog.Error("rename", "target", name, "err", err)
+			return 1
+		}
+		resp.Body.Close()
+		return 0
+	}
+}
+
+// linuxResources is the subset of the OCI runtime resource limits accepted
+// by the container update endpoint and in container specs.
+type linuxResources struct {
+	CPU    *cpuResources    `json:"cpu,omitempty"`
+	Memory *memoryResources `json:"memory,omitempty"`
+	Pids   *pidsResources   `json:"pids,omitempty"`
+}
+
+type cpuResources struct {
+	Shares uint64 `json:"shares,omitempty"`
+	Quota  int64  `json:"quota,omitempty"`
+	Period uint64 `json:"period,omitempty"`
+}
+
+type memoryResources struct {
+	Limit int64 `json:"limit"`
+}
+
+type pidsResources struct {
+	Limit int64 `json:"limit"`
+}
+
+// cpuPeriod is the CFS period, in microseconds, used to express -cpus as a
+// quota.
+const cpuPeriod = 100000
+
+// newUpdateCommand returns the "update" command, which changes the resource
+// limits of a running remote container.
+func newUpdateCommand(fs *flag.FlagSet) runFunc {
+	var cpus float64
+	var memory string
+	var pidsLimit int64
+	fs.Float64Var(&cpus, "cpus", 0, "Number of CPUs the container may use")
+	fs.StringVar(&memory, "memory", "", "Memory limit (e.g. 512m, 2g)")
+	fs.Int64Var(&pidsLimit, "pids-limit", 0, "Maximum number of processes (-1 for unlimited)")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("update: exactly one container name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		var res linuxResources
+		if cpus < 0 {
+			slog.Error("update: -cpus must not be negative")
+			return 1
+		}
+		if cpus > 0 {
+			res.CPU = &cpuResources{Quota: int64(cpus * cpuPeriod), Period: cpuPeriod}
+		}
+		if memory != "" {
+			limit, err := parseMemory(memory)
+			if err != nil {
+				slog.Error("update: invalid -memory", "err", err)
+				return 1
+			}
+			res.Memory = &memoryResources{Limit: limit}
+		}
+		if pidsLimit != 0 {
+			res.Pids = &pidsResources{Limit: pidsLimit}
+		}
+		if res.CPU == nil && res.Memory == nil && res.Pids == nil {
+			slog.Error("update: at least one of -cpus, -memory or -pids-limit is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if err := updateContainer(ctx, httpClient, name, res); err != nil {
+			slog.Error("update", "target", name, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// updateContainer applies res to the named container.
+func updateContainer(ctx context.Context, httpClient *http.Client, name string, res linuxResources) error {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/update", nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// parseMemory parses a memory size such as "512m" or "2g" into bytes. A
+// plain number is taken as bytes.
+func parseMemory(s string) (int64, error) {
+	multipliers := map[byte]int64{'b': 1, 'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+
+	num, multiplier := s, int64(1)
+	if m, ok := multipliers[strings.ToLower(s)[len(s)-1]]; ok {
+		num, multiplier = s[:len(s)-1], m
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", s)
+	}
+	return n * multiplier, nil
+}
+
+// newPauseCommand returns a command that pauses, or unpauses, the given
+// containers or, with -pod, pods. Targets are handled concurrently.
+func newPauseCommand(action string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		var pod bool
+		fs.BoolVar(&pod, "pod", false, "Targets are pods rather than containers")
+		maxParallel := maxParallelFlag(fs)
+
+		return func(rc *RemoteCLI) int {
+			if len(rc.args) == 0 {
+				slog.Error(action + ": at least one target is required")
+				return 1
+			}
+
+			kind := "containers"
+			if pod {
+				kind = "pods"
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			sshClient, httpClient, err := rc.connect(ctx)
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+				return 1
+			}
+			defer sshClient.Close()
+
+			return forEachTarget(ctx, action, rc.args, *maxParallel, os.Stdout, func(ctx context.Context, name string) error {
+				resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/"+kind+"/"+url.PathEscape(name)+"/"+action, nil, nil)
+				if err != nil {
+					return err
+				}
+				return resp.Body.Close()
+			})
+		}
+	}
+}
+
+// newMountCommand returns the "mount_container" command, which mounts a
+// remote container's root filesystem and prints the mount point on the
+// remote host.
+func newMountCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("mount_container: exactly one container name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		path, err := mountContainer(ctx, httpClient, name)
+		if err != nil {
+			slog.Error("mount_container", "target", name, "err", err)
+			return 1
+		}
+
+		fmt.Println(path)
+		return 0
+	}
+}
+
+// mountContainer mounts the named container and returns the path of its
+// root filesystem on the remote host.
+func mountContainer(ctx context.Context, httpClient *http.Client, name string) (string, error) {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/mount", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var path string
+	if err := json.NewDecoder(resp.Body).Decode(&path); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	return path, nil
+}
+
+// newUnmountCommand returns the "unmount_container" command, which
+// unmounts the root filesystems of the given containers.
+func newUnmountCommand(fs *flag.FlagSet) runFunc {
+	return newBatchCommand(fs, "unmount_container", postContainerAction("unmount"))
+}
+
+// newInitCommand returns the "init_container" command, which initializes
+// the given containers without starting them. Containers that are already
+// initialized are not treated as failures.
+func newInitCommand(fs *flag.FlagSet) runFunc {
+	return newBatchCommand(fs, "init_container", postContainerAction("init"))
+}
+
+// newLifecycleCommand returns a command that starts the given containers
+// concurrently. Containers already running are not treated as failures.
+func newLifecycleCommand(action string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return newBatchCommand(fs, action, postContainerAction(action))
+	}
+}
+
+// stopRequestMargin is added to the -time of stop and restart to bound
+// their requests, leaving time to kill the container once it elapsed.
+const stopRequestMargin = 30 * time.Second
+
+// newStopCommand returns the "stop" or "restart" command, which stops, and
+// restarts, the given containers concurrently, killing those that have not
+// stopped -time seconds after their stop signal.
+func newStopCommand(action string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		timeout := -1
+		for _, name := range []string{"time", "t"} {
+			fs.IntVar(&timeout, name, timeout, "Seconds to wait after the stop signal before killing the container with SIGKILL (default the container's stop timeout, 10 unless set)")
+		}
+		batch := newBatchCommand(fs, action, func(ctx context.Context, httpClient *http.Client, name string) error {
+			return stopContainer(ctx, httpClient, name, action, timeout)
+		})
+
+		return func(rc *RemoteCLI) int {
+			if timeout < -1 {
+				slog.Error(action+": -time must not be negative", "time", timeout)
+				return 1
+			}
+			if limit := time.Duration(timeout)*time.Second + stopRequestMargin; rc.requestTimeout > 0 && rc.requestTimeout < limit {
+				rc.requestTimeout = limit
+			}
+			return batch(rc)
+		}
+	}
+}
+
+// stopContainer sends the stop or restart action to the named container,
+// with the timeout in seconds unless negative. With -verbose, the container
+// is inspected first so that how it stopped can be reported: by its stop
+// signal, or by SIGKILL once the timeout elapsed.
+func stopContainer(ctx context.Context, httpClient *http.Client, name, action string, timeout int) error {
+	var query url.Values
+	if timeout >= 0 {
+		query = url.Values{"t": {strconv.Itoa(timeout)}}
+	}
+	verbose := slog.Default().Enabled(ctx, slog.LevelDebug)
+	var ctr models.InspectContainerData
+	if verbose {
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/"+action, query, nil)
+	if isStatus(err, http.StatusNotModified) {
+		slog.Debug(action, "target", name, "outcome", "already stopped")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if verbose {
+		slog.Debug(action, "target", name, "outcome", stopOutcome(ctr, timeout, time.Since(start)), "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// stopOutcome describes how the container ctr, inspected before being
+// stopped with timeout, or its own stop timeout if negative, stopped in
+// elapsed: Podman kills containers still running once the timeout elapsed.
+func stopOutcome(ctr models.InspectContainerData, timeout int, elapsed time.Duration) string {
+	if !ctr.State.Running {
+		return "not running"
+	}
+	limit := time.Duration(ctr.Config.StopTimeout) * time.Second
+	if timeout >= 0 {
+		limit = time.Duration(timeout) * time.Second
+	}
+	if elapsed >= limit {
+		return fmt.Sprintf("killed with SIGKILL after %s", limit)
+	}
+	stopSignal := ctr.Config.StopSignal
+	if stopSignal == "" {
+		stopSignal = "SIGTERM"
+	}
+	return "stopped by " + stopSignal
+}
+
+// newRmCommand returns the "rm" command, also available as
+// "rm_container", which removes the given containers concurrently. With
+// -all, every stopped container is removed instead, or every container
+// when combined with -force.
+func newRmCommand(fs *flag.FlagSet) runFunc {
+	var opts removeOptions
+	var all bool
+	fs.BoolVar(&opts.force, "force", false, "Stop running containers before removing them")
+	fs.BoolVar(&opts.volumes, "volumes", false, "Remove anonymous volumes associated with the containers")
+	fs.BoolVar(&all, "all", false, "Remove all stopped containers (all containers with -force)")
+	maxParallel := maxParallelFlag(fs)
+
+	return func(rc *RemoteCLI) int {
+		if all == (len(rc.args) > 0) {
+			slog.Error("rm: usage: rm [flags] <container>... | rm [flags] -all")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		targets := rc.args
+		if all {
+			targets, err = removableContainers(ctx, httpClient, opts.force)
+			if err != nil {
+				slog.Error("rm", "err", err)
+				return 1
+			}
+		}
+
+		if len(targets) > 0 && !rc.confirmDestructive("Remove containers "+strings.Join(targets, ", ")) {
+			slog.Error("rm: aborted; nothing was removed")
+			return 1
+		}
+
+		return forEachTarget(ctx, "rm", targets, *maxParallel, os.Stdout, func(ctx context.Context, name string) error {
+			return removeContainer(ctx, httpClient, name, opts)
+		})
+	}
+}
+
+// newPodRmCommand returns the "pod_rm" command, which removes pods and
+// their containers. Targets are handled concurrently.
+func newPodRmCommand(fs *flag.FlagSet) runFunc {
+	var force bool
+	fs.BoolVar(&force, "force", false, "Stop running containers of the pods before removing them")
+	maxParallel := maxParallelFlag(fs)
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("pod_rm: at least one pod name or ID is required")
+			return 1
+		}
+		if !rc.confirmDestructive("Remove pods " + strings.Join(rc.args, ", ") + " and their containers") {
+			slog.Error("pod_rm: aborted; nothing was removed")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		query := url.Values{"force": {strconv.FormatBool(force)}}
+		return forEachTarget(ctx, "pod_rm", rc.args, *maxParallel, os.Stdout, func(ctx context.Context, name string) error {
+			resp, err := apiRequest(ctx, httpClient, http.MethodDelete, "/v3.0.0/libpod/pods/"+url.PathEscape(name), query, nil)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		})
+	}
+}
+
+// removeOptions holds the query parameters of a container removal.
+type removeOptions struct {
+	force   bool
+	volumes bool
+}
+
+// removeContainer removes the named container.
+func removeContainer(ctx context.Context, httpClient *http.Client, name string, opts removeOptions) error {
+	query := url.Values{
+		"force": {strconv.FormatBool(opts.force)},
+		"v":     {strconv.FormatBool(opts.volumes)},
+	}
+	resp, err := apiRequest(ctx, httpClient, http.MethodDelete, "/v3.0.0/libpod/containers/"+url.PathEscape(name), query, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// removableContainers returns the names of the containers removed by
+// "rm -all": those that are not running, or all of them when force is set.
+func removableContainers(ctx context.Context, httpClient *http.Client, force bool) ([]string, error) {
+	query := url.Values{"all": {"true"}}
+	if !force {
+		query.Set("filters", `{"status":["created","exited","dead"]}`)
+	}
+
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		}
+	}
+	return names, nil
+}
+
+// postContainerAction returns a batch function that performs action on a
+// container with containerAction.
+func postContainerAction(action string) func(ctx context.Context, httpClient *http.Client, name string) error {
+	return func(ctx context.Context, httpClient *http.Client, name string) error {
+		return containerAction(ctx, httpClient, name, action)
+	}
+}
+
+// containerAction sends a POST request for action to the named container.
+// A 304 Not Modified response, returned when the container is already in
+// the requested state, counts as success.
+func containerAction(ctx context.Context, httpClient *http.Client, name, action string) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/"+action, nil, nil)
+	if isStatus(err, http.StatusNotModified) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
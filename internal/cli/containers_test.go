@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestCommitContainer(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotQuery url.Values
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		gotQuery = r.URL.Query()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"sha256:abc123"}`))
+	}))
+
+	query := url.Values{"container": {"web"}, "repo": {"myapp"}, "tag": {"v2"}, "pause": {"true"}}
+	id, err := commitContainer(context.Background(), httpClient, query)
+	if err != nil {
+		t.Fatalf("commitContainer() unexpected error = %v", err)
+	}
+
+	if id != "sha256:abc123" {
+		t.Errorf("commitContainer() = %q, want %q", id, "sha256:abc123")
+	}
+	if gotMethod != http.MethodPost || gotPath != "/v3.0.0/libpod/commit" {
+		t.Errorf("commitContainer() request = %s %s, want POST /v3.0.0/libpod/commit", gotMethod, gotPath)
+	}
+	for key, want := range map[string]string{"container": "web", "repo": "myapp", "tag": "v2", "pause": "true"} {
+		if got := gotQuery.Get(key); got != want {
+			t.Errorf("commitContainer() %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSplitImageTag(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantRepo string
+		wantTag  string
+	}{
+		{"myapp", "myapp", ""},
+		{"myapp:v2", "myapp", "v2"},
+		{"registry:5000/myapp", "registry:5000/myapp", ""},
+		{"registry:5000/myapp:latest", "registry:5000/myapp", "latest"},
+	}
+
+	for _, tt := range tests {
+		repo, tag := splitImageTag(tt.ref)
+		if repo != tt.wantRepo || tag != tt.wantTag {
+			t.Errorf("splitImageTag(%q) = %q, %q, want %q, %q", tt.ref, repo, tag, tt.wantRepo, tt.wantTag)
+		}
+	}
+}
+
+func TestCommitCommand_MissingContainer(t *testing.T) {
+	rc := &RemoteCLI{}
+	run := newTestCommand(t, newCommitCommand)
+
+	if code := run(rc); code != 1 {
+		t.Errorf("commit without container exit code = %d, want 1", code)
+	}
+}
+
+func TestUpdateContainer(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotBody map[string]map[string]int64
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	res := linuxResources{
+		CPU:    &cpuResources{Quota: 150000, Period: cpuPeriod},
+		Memory: &memoryResources{Limit: 512 << 20},
+	}
+	if err := updateContainer(context.Background(), httpClient, "web", res); err != nil {
+		t.Fatalf("updateContainer() unexpected error = %v", err)
+	}
+
+	if gotMethod != http.MethodPost || gotPath != "/v3.0.0/libpod/containers/web/update" {
+		t.Errorf("updateContainer() request = %s %s, want POST /v3.0.0/libpod/containers/web/update", gotMethod, gotPath)
+	}
+	if gotBody["cpu"]["quota"] != 150000 || gotBody["cpu"]["period"] != cpuPeriod {
+		t.Errorf("updateContainer() cpu = %v, want quota 150000 period %d", gotBody["cpu"], cpuPeriod)
+	}
+	if gotBody["memory"]["limit"] != 512<<20 {
+		t.Errorf("updateContainer() memory = %v, want limit %d", gotBody["memory"], 512<<20)
+	}
+	if _, ok := gotBody["pids"]; ok {
+		t.Errorf("updateContainer() sent pids = %v, want omitted", gotBody["pids"])
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"100b", 100, false},
+		{"4k", 4 << 10, false},
+		{"512m", 512 << 20, false},
+		{"2G", 2 << 30, false},
+		{"m", 0, true},
+		{"-1m", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMemory(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMemory(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMemory(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateCommand_NoLimits(t *testing.T) {
+	rc := &RemoteCLI{args: []string{"web"}}
+	run := newTestCommand(t, newUpdateCommand)
+
+	if code := run(rc); code != 1 {
+		t.Errorf("update without limits exit code = %d, want 1", code)
+	}
+}
+
+func TestRenameCommand_Args(t *testing.T) {
+	rc := &RemoteCLI{args: []string{"web"}}
+	run := newTestCommand(t, newRenameCommand)
+
+	if code := run(rc); code != 1 {
+		t.Errorf("rename without new name exit code = %d, want 1", code)
+	}
+}
+
+func TestMountContainer(t *testing.T) {
+	var gotMethod, gotPath string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		w.Write([]byte(`"/home/user/.local/share/containers/storage/overlay/abc/merged"`))
+	}))
+
+	path, err := mountContainer(context.Background(), httpClient, "web")
+	if err != nil {
+		t.Fatalf("mountContainer() unexpected error = %v", err)
+	}
+
+	if path != "/home/user/.local/share/containers/storage/overlay/abc/merged" {
+		t.Errorf("mountContainer() = %q, want merged overlay path", path)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/v3.0.0/libpod/containers/web/mount" {
+		t.Errorf("mountContainer() request = %s %s, want POST /v3.0.0/libpod/containers/web/mount", gotMethod, gotPath)
+	}
+}
+
+func TestContainerAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"no content", http.StatusNoContent, false},
+		{"already initialized", http.StatusNotModified, false},
+		{"not found", http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				w.WriteHeader(tt.status)
+			}))
+
+			err := containerAction(context.Background(), httpClient, "web", "init")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("containerAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotPath != "/v3.0.0/libpod/containers/web/init" {
+				t.Errorf("containerAction() path = %q, want /v3.0.0/libpod/containers/web/init", gotPath)
+			}
+		})
+	}
+}
+
+func TestRemoveContainer(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotQuery url.Values
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		gotQuery = r.URL.Query()
+		w.Write([]byte(`[{"Id":"abc","Err":null}]`))
+	}))
+
+	if err := removeContainer(context.Background(), httpClient, "web", removeOptions{force: true, volumes: true}); err != nil {
+		t.Fatalf("removeContainer() unexpected error = %v", err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/v3.0.0/libpod/containers/web" {
+		t.Errorf("removeContainer() request = %s %s, want DELETE /v3.0.0/libpod/containers/web", gotMethod, gotPath)
+	}
+	if gotQuery.Get("force") != "true" || gotQuery.Get("v") != "true" {
+		t.Errorf("removeContainer() query = %v, want force=true and v=true", gotQuery)
+	}
+}
+
+func TestRemovableContainers(t *testing.T) {
+	tests := []struct {
+		force       bool
+		wantFilters string
+	}{
+		{false, `{"status":["created","exited","dead"]}`},
+		{true, ""},
+	}
+
+	for _, tt := range tests {
+		var gotQuery url.Values
+		httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotQuery = r.URL.Query()
+			w.Write([]byte(`[{"Names":["/web"]},{"Names":["db"]}]`))
+		}))
+
+		names, err := removableContainers(context.Background(), httpClient, tt.force)
+		if err != nil {
+			t.Fatalf("removableContainers(%v) unexpected error = %v", tt.force, err)
+		}
+		if strings.Join(names, ",") != "web,db" {
+			t.Errorf("removableContainers(%v) = %v, want [web db]", tt.force, names)
+		}
+		if gotQuery.Get("all") != "true" || gotQuery.Get("filters") != tt.wantFilters {
+			t.Errorf("removableContainers(%v) query = %v, want all=true filters=%q", tt.force, gotQuery, tt.wantFilters)
+		}
+	}
+}
+
+func TestRmCommand_Args(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"-all", "web"},
+	}
+
+	for _, args := range tests {
+		flagArgs := args
+		var targets []string
+		if len(args) > 1 {
+			flagArgs, targets = args[:1], args[1:]
+		}
+		rc := &RemoteCLI{args: targets}
+		run := newTestCommand(t, newRmCommand, flagArgs...)
+
+		if code := run(rc); code != 1 {
+			t.Errorf("rm %v exit code = %d, want 1", args, code)
+		}
+	}
+}
+
+func TestPodRmCommand_NoPods(t *testing.T) {
+	run := newTestCommand(t, newPodRmCommand, "-force")
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("pod_rm exit code = %d, want 1", code)
+	}
+}
+
+func TestStopContainer(t *testing.T) {
+	var got []string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.RequestURI())
+		if strings.Contains(r.URL.Path, "/stopped/") {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tt := range []struct {
+		name, action string
+		timeout      int
+	}{
+		{"web", "stop", 30},
+		{"web", "restart", -1},
+		{"stopped", "stop", 0},
+	} {
+		if err := stopContainer(context.Background(), httpClient, tt.name, tt.action, tt.timeout); err != nil {
+			t.Errorf("stopContainer(%s, %s) unexpected error = %v", tt.name, tt.action, err)
+		}
+	}
+	want := []string{
+		"POST /v3.0.0/libpod/containers/web/stop?t=30",
+		"POST /v3.0.0/libpod/containers/web/restart",
+		"POST /v3.0.0/libpod/containers/stopped/stop?t=0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestStopOutcome(t *testing.T) {
+	running := models.InspectContainerData{
+		State:  models.InspectContainerState{Running: true},
+		Config: models.InspectContainerConfig{StopTimeout: 10},
+	}
+	custom := running
+	custom.Config.StopSignal = "SIGQUIT"
+
+	tests := []struct {
+		name    string
+		ctr     models.InspectContainerData
+		timeout int
+		elapsed time.Duration
+		want    string
+	}{
+		{"signal", running, -1, 2 * time.Second, "stopped by SIGTERM"},
+		{"stop signal", custom, 5, time.Second, "stopped by SIGQUIT"},
+		{"container timeout", running, -1, 10 * time.Second, "killed with SIGKILL after 10s"},
+		{"time", running, 0, 50 * time.Millisecond, "killed with SIGKILL after 0s"},
+		{"not running", models.InspectContainerData{}, -1, 0, "not running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stopOutcome(tt.ctr, tt.timeout, tt.elapsed); got != tt.want {
+				t.Errorf("stopOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopCommand_TimeFlag(t *testing.T) {
+	run := newTestCommand(t, newStopCommand("stop"), "-time", "-5", "web")
+	if code := run(&RemoteCLI{args: []string{"web"}}); code != 1 {
+		t.Errorf("stop -time -5 exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,665 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// containerSpec is the subset of Podman's SpecGenerator sent to the libpod
+// container create endpoint.
+type containerSpec struct {
+	Name           string            `json:"name,omitempty"`
+	Image          string            `json:"image"`
+	Command        []string          `json:"command,omitempty"`
+	Entrypoint     []string          `json:"entrypoint,omitempty"`
+	WorkDir        string            `json:"work_dir,omitempty"`
+	User           string            `json:"user,omitempty"`
+	RestartPolicy  string            `json:"restart_policy,omitempty"`
+	RestartTries   *uint             `json:"restart_tries,omitempty"`
+	ResourceLimits *linuxResources   `json:"resource_limits,omitempty"`
+	Rlimits        []posixRlimit     `json:"r_limits,omitempty"`
+	CapAdd         []string          `json:"cap_add,omitempty"`
+	CapDrop        []string          `json:"cap_drop,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Secrets        []specSecret      `json:"secrets,omitempty"`
+	SecretEnv      map[string]string `json:"secret_env,omitempty"`
+
+	PortMappings      []portMapping             `json:"portmappings,omitempty"`
+	PublishImagePorts bool                      `json:"publish_image_ports,omitempty"`
+	NetNS             *specNamespace            `json:"netns,omitempty"`
+	Networks          map[string]networkOptions `json:"Networks,omitempty"`
+	DNSServers        []string                  `json:"dns_server,omitempty"`
+	HostAdd           []string                  `json:"hostadd,omitempty"`
+
+	Mounts  []specMount   `json:"mounts,omitempty"`
+	Volumes []namedVolume `json:"volumes,omitempty"`
+}
+
+// specMount is a bind mount of a path on the host into the container.
+type specMount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type"`
+	Source      string   `json:"source"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// namedVolume mounts a Podman volume into the container; a volume without
+// a name is created for the container. Podman does not name the fields in
+// JSON.
+type namedVolume struct {
+	Name    string
+	Dest    string
+	Options []string `json:",omitempty"`
+}
+
+// specSecret is a secret mounted as a file in the container. Podman does
+// not name the fields in JSON.
+type specSecret struct {
+	Source string
+	Target string `json:",omitempty"`
+	UID    uint32
+	GID    uint32
+	Mode   uint32 `json:",omitempty"`
+}
+
+// posixRlimit is a process resource limit, as set with -ulimit.
+type posixRlimit struct {
+	Type string `json:"type"`
+	Hard uint64 `json:"hard"`
+	Soft uint64 `json:"soft"`
+}
+
+// stringsFlag is a flag.Value collecting the values of a flag given more
+// than once.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// specFlags holds the flags of the create and run commands, from which
+// the container spec is built.
+type specFlags struct {
+	name      string
+	restart   string
+	memory    string
+	cpus      float64
+	cpuShares uint64
+	pidsLimit int64
+	ulimits   stringsFlag
+	capAdd    stringsFlag
+	capDrop   stringsFlag
+	labels    stringsFlag
+	annots    stringsFlag
+	envFiles  stringsFlag
+	env       stringsFlag
+	secrets   stringsFlag
+
+	networkFlags
+	publishAll bool
+}
+
+// networkFlags holds the flags setting the published ports, network and
+// name resolution, shared by containers and pods.
+type networkFlags struct {
+	publish  stringsFlag
+	network  string
+	ip       string
+	dns      stringsFlag
+	addHosts stringsFlag
+}
+
+// register defines the flags on fs.
+func (f *specFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "name", "", "Name of the container")
+	fs.StringVar(&f.restart, "restart", "", "Restart policy: no, always, on-failure[:max-retries] or unless-stopped")
+	fs.StringVar(&f.memory, "memory", "", "Memory limit (e.g. 512m, 2g)")
+	fs.Float64Var(&f.cpus, "cpus", 0, "Number of CPUs the container may use")
+	fs.Uint64Var(&f.cpuShares, "cpu-shares", 0, "Relative CPU weight")
+	fs.Int64Var(&f.pidsLimit, "pids-limit", 0, "Maximum number of processes (-1 for unlimited)")
+	fs.Var(&f.ulimits, "ulimit", "Resource limit as name=soft[:hard], such as nofile=1024:2048 (repeatable)")
+	fs.Var(&f.capAdd, "cap-add", "Add a Linux capability, such as NET_ADMIN (repeatable)")
+	fs.Var(&f.capDrop, "cap-drop", "Drop a Linux capability, or ALL (repeatable)")
+	fs.Var(&f.labels, "label", "Set a label as key=value, or key for an empty value (repeatable)")
+	fs.Var(&f.annots, "annotation", "Set an OCI annotation as key=value (repeatable)")
+	fs.Var(&f.envFiles, "env-file", "Read environment variables from a local file of KEY=value lines (repeatable)")
+	fs.Var(&f.env, "env", "Set an environment variable as KEY=value, or KEY to pass the local value (repeatable)")
+	fs.Var(&f.secrets, "secret", "Expose a Podman secret as name[,type=mount|env][,target=...][,uid=N][,gid=N][,mode=0400] (repeatable)")
+	f.networkFlags.register(fs, "container")
+	for _, name := range []string{"publish-all", "P"} {
+		fs.BoolVar(&f.publishAll, name, false, "Publish all ports exposed by the image on random host ports")
+	}
+}
+
+// register defines the flags on fs, for a container or a pod as named by
+// kind.
+func (f *networkFlags) register(fs *flag.FlagSet, kind string) {
+	for _, name := range []string{"publish", "p"} {
+		fs.Var(&f.publish, name, "Publish a port as [[ip:][hostPort]:]containerPort[/protocol] (repeatable)")
+	}
+	fs.StringVar(&f.network, "network", "", "Network mode (bridge, host, none, container:<name>, ns:<path>) or networks to attach to, comma separated")
+	fs.StringVar(&f.ip, "ip", "", "Static IP address of the "+kind+" on its network")
+	fs.Var(&f.dns, "dns", "Set a DNS server (repeatable)")
+	fs.Var(&f.addHosts, "add-host", "Add a host:ip entry to /etc/hosts (repeatable)")
+}
+
+// spec builds the container spec for image, running command if given.
+func (f *specFlags) spec(image string, command []string) (containerSpec, error) {
+	s := containerSpec{Name: f.name, Image: image, Command: command}
+
+	if f.restart != "" {
+		policy, tries, err := parseRestartPolicy(f.restart)
+		if err != nil {
+			return s, err
+		}
+		s.RestartPolicy, s.RestartTries = policy, tries
+	}
+
+	var res linuxResources
+	if f.memory != "" {
+		limit, err := parseMemory(f.memory)
+		if err != nil {
+			return s, fmt.Errorf("invalid -memory: %w", err)
+		}
+		res.Memory = &memoryResources{Limit: limit}
+	}
+	if f.cpus < 0 {
+		return s, fmt.Errorf("-cpus must not be negative")
+	}
+	if f.cpus > 0 || f.cpuShares > 0 {
+		res.CPU = &cpuResources{Shares: f.cpuShares}
+		if f.cpus > 0 {
+			res.CPU.Quota, res.CPU.Period = int64(f.cpus*cpuPeriod), cpuPeriod
+		}
+	}
+	if f.pidsLimit != 0 {
+		res.Pids = &pidsResources{Limit: f.pidsLimit}
+	}
+	if res != (linuxResources{}) {
+		s.ResourceLimits = &res
+	}
+
+	for _, u := range f.ulimits {
+		limit, err := parseUlimit(u)
+		if err != nil {
+			return s, err
+		}
+		s.Rlimits = append(s.Rlimits, limit)
+	}
+	for _, c := range f.capAdd {
+		s.CapAdd = append(s.CapAdd, capabilityName(c))
+	}
+	for _, c := range f.capDrop {
+		s.CapDrop = append(s.CapDrop, capabilityName(c))
+	}
+
+	var err error
+	if s.Labels, err = parseKeyValues("-label", f.labels, true); err != nil {
+		return s, err
+	}
+	if s.Annotations, err = parseKeyValues("-annotation", f.annots, false); err != nil {
+		return s, err
+	}
+
+	// Variables given with -env override those of the files
+	env := map[string]string{}
+	for _, path := range f.envFiles {
+		if err := readEnvFile(path, env); err != nil {
+			return s, err
+		}
+	}
+	for _, e := range f.env {
+		if err := setEnv(env, e); err != nil {
+			return s, fmt.Errorf("invalid -env: %w", err)
+		}
+	}
+	if len(env) > 0 {
+		s.Env = env
+	}
+
+	for _, opt := range f.secrets {
+		if err := addSecret(&s, opt); err != nil {
+			return s, err
+		}
+	}
+
+	if err := f.networking(&s); err != nil {
+		return s, err
+	}
+	s.PublishImagePorts = f.publishAll
+	return s, nil
+}
+
+// networking sets the published ports, network and name resolution
+// settings of s.
+func (f *networkFlags) networking(s *containerSpec) error {
+	for _, p := range f.publish {
+		m, err := parsePublish(p)
+		if err != nil {
+			return err
+		}
+		s.PortMappings = append(s.PortMappings, m)
+	}
+
+	var networks []string
+	if f.network != "" {
+		var err error
+		if s.NetNS, networks, err = parseNetwork(f.network); err != nil {
+			return err
+		}
+	}
+	if f.ip != "" {
+		// Podman assigns static addresses per network, so the network
+		// must be named
+		if net.ParseIP(f.ip) == nil {
+			return fmt.Errorf("invalid -ip %q: not an IP address", f.ip)
+		}
+		if len(networks) != 1 {
+			return fmt.Errorf("-ip requires -network with a single network name")
+		}
+	}
+	for _, name := range networks {
+		if s.Networks == nil {
+			s.Networks = map[string]networkOptions{}
+		}
+		var opts networkOptions
+		if f.ip != "" {
+			opts.StaticIPs = []string{f.ip}
+		}
+		s.Networks[name] = opts
+	}
+
+	for _, d := range f.dns {
+		if net.ParseIP(d) == nil {
+			return fmt.Errorf("invalid -dns %q: not an IP address", d)
+		}
+		s.DNSServers = append(s.DNSServers, d)
+	}
+	for _, h := range f.addHosts {
+		entry, err := parseAddHost(h)
+		if err != nil {
+			return err
+		}
+		s.HostAdd = append(s.HostAdd, entry)
+	}
+	return nil
+}
+
+// parseKeyValues parses the key=value entries of the named flag into a
+// map, nil if there are none; a later entry for a key overrides an earlier
+// one. If bareKeys is set, an entry without "=" sets an empty value.
+func parseKeyValues(name string, entries []string, bareKeys bool) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		key, value, ok := strings.Cut(e, "=")
+		if key == "" || (!ok && !bareKeys) {
+			return nil, fmt.Errorf("invalid %s %q: want key=value", name, e)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// readEnvFile adds the variables of the local env file at path to env.
+// Each line holds KEY=value, or KEY to pass the local value of KEY if it
+// is set; blank lines and lines starting with # are ignored. The value is
+// taken literally, without removing quotes.
+func readEnvFile(path string, env map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("-env-file: %w", err)
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if err := setEnv(env, strings.TrimLeft(line, " \t")); err != nil {
+			return fmt.Errorf("-env-file %s:%d: %w", path, n+1, err)
+		}
+	}
+	return nil
+}
+
+// setEnv adds the variable of a KEY=value entry to env, or for a bare KEY
+// its local value, if set.
+func setEnv(env map[string]string, entry string) error {
+	key, value, hasValue := strings.Cut(entry, "=")
+	if !validEnvKey(key) {
+		return fmt.Errorf("%q is not a valid variable name", key)
+	}
+	if !hasValue {
+		var ok bool
+		if value, ok = os.LookupEnv(key); !ok {
+			return nil
+		}
+	}
+	env[key] = value
+	return nil
+}
+
+// validEnvKey reports whether key is a valid environment variable name: a
+// letter or underscore followed by letters, digits, underscores, dots or
+// dashes.
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && (c == '.' || c == '-' || '0' <= c && c <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// addSecret adds the secret described by a -secret value to s, as a file
+// under /run/secrets or, with type=env, as an environment variable.
+func addSecret(s *containerSpec, opt string) error {
+	fields := strings.Split(opt, ",")
+	secret := specSecret{Source: fields[0]}
+	if secret.Source == "" {
+		return fmt.Errorf("invalid -secret %q: the secret name is required", opt)
+	}
+
+	kind := "mount"
+	var ownership bool
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("invalid -secret %q: want key=value options after the name", opt)
+		}
+		var err error
+		switch key {
+		case "type":
+			if value != "mount" && value != "env" {
+				return fmt.Errorf("invalid -secret %q: type must be mount or env", opt)
+			}
+			kind = value
+		case "target":
+			secret.Target = value
+		case "uid", "gid":
+			var n uint64
+			if n, err = strconv.ParseUint(value, 10, 32); err == nil {
+				if key == "uid" {
+					secret.UID = uint32(n)
+				} else {
+					secret.GID = uint32(n)
+				}
+			}
+			ownership = true
+		case "mode":
+			var n uint64
+			n, err = strconv.ParseUint(value, 8, 32)
+			secret.Mode = uint32(n)
+			ownership = true
+		default:
+			return fmt.Errorf("invalid -secret %q: unknown option %q", opt, key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid -secret %q: bad %s %q", opt, key, value)
+		}
+	}
+
+	if kind == "mount" {
+		s.Secrets = append(s.Secrets, secret)
+		return nil
+	}
+	if ownership {
+		return fmt.Errorf("invalid -secret %q: uid, gid and mode only apply to type=mount", opt)
+	}
+	target := secret.Target
+	if target == "" {
+		target = secret.Source
+	}
+	if !validEnvKey(target) {
+		return fmt.Errorf("invalid -secret %q: %q is not a valid variable name", opt, target)
+	}
+	if s.SecretEnv == nil {
+		s.SecretEnv = map[string]string{}
+	}
+	s.SecretEnv[target] = secret.Source
+	return nil
+}
+
+// parseRestartPolicy parses a -restart value, returning the policy and,
+// for on-failure with a count, the maximum number of retries.
+func parseRestartPolicy(s string) (string, *uint, error) {
+	policy, count, hasCount := strings.Cut(s, ":")
+	switch policy {
+	case "no", "always", "unless-stopped":
+		if hasCount {
+			return "", nil, fmt.Errorf("invalid -restart %q: only on-failure takes a retry count", s)
+		}
+		return policy, nil, nil
+	case "on-failure":
+		if !hasCount {
+			return policy, nil, nil
+		}
+		n, err := strconv.ParseUint(count, 10, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid -restart %q: retry count must be a non-negative number", s)
+		}
+		tries := uint(n)
+		return policy, &tries, nil
+	}
+	return "", nil, fmt.Errorf("invalid -restart %q (use no, always, on-failure[:N] or unless-stopped)", s)
+}
+
+// rlimitNames are the resource names accepted by -ulimit.
+var rlimitNames = map[string]bool{
+	"as": true, "core": true, "cpu": true, "data": true, "fsize": true,
+	"locks": true, "memlock": true, "msgqueue": true, "nice": true,
+	"nofile": true, "nproc": true, "rss": true, "rtprio": true,
+	"rttime": true, "sigpending": true, "stack": true,
+}
+
+// parseUlimit parses a -ulimit value of the form name=soft[:hard], where
+// the hard limit defaults to the soft one and -1 means unlimited.
+func parseUlimit(s string) (posixRlimit, error) {
+	name, limits, ok := strings.Cut(s, "=")
+	name = strings.ToLower(name)
+	if !ok || !rlimitNames[name] {
+		return posixRlimit{}, fmt.Errorf("invalid -ulimit %q: want name=soft[:hard] with a name such as nofile or nproc", s)
+	}
+
+	soft, hard, hasHard := strings.Cut(limits, ":")
+	if !hasHard {
+		hard = soft
+	}
+	limit := posixRlimit{Type: "RLIMIT_" + strings.ToUpper(name)}
+	var err error
+	if limit.Soft, err = parseRlimitValue(soft); err != nil {
+		return posixRlimit{}, fmt.Errorf("invalid -ulimit %q: %w", s, err)
+	}
+	if limit.Hard, err = parseRlimitValue(hard); err != nil {
+		return posixRlimit{}, fmt.Errorf("invalid -ulimit %q: %w", s, err)
+	}
+	if limit.Soft > limit.Hard {
+		return posixRlimit{}, fmt.Errorf("invalid -ulimit %q: soft limit exceeds hard limit", s)
+	}
+	return limit, nil
+}
+
+// parseRlimitValue parses a resource limit, where -1 means unlimited.
+func parseRlimitValue(s string) (uint64, error) {
+	if s == "-1" {
+		return math.MaxUint64, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a limit", s)
+	}
+	return n, nil
+}
+
+// capabilityName returns the capability c in the CAP_ form Podman expects,
+// accepting names such as net_admin. ALL is kept as is.
+func capabilityName(c string) string {
+	c = strings.ToUpper(c)
+	if c == "ALL" || strings.HasPrefix(c, "CAP_") {
+		return c
+	}
+	return "CAP_" + c
+}
+
+// newCreateCommand returns the "create" command, which creates a container
+// on the remote host and prints its ID.
+func newCreateCommand(fs *flag.FlagSet) runFunc {
+	return newContainerCommand("create", fs, false)
+}
+
+// newRunCommand returns the "run" command, which creates a container on
+// the remote host and starts it in the background, printing its ID.
+func newRunCommand(fs *flag.FlagSet) runFunc {
+	return newContainerCommand("run", fs, true)
+}
+
+// newContainerCommand returns the create or run command, starting the
+// created container if start is set.
+func newContainerCommand(cmd string, fs *flag.FlagSet, start bool) runFunc {
+	var flags specFlags
+	flags.register(fs)
+	var rm bool
+	if start {
+		fs.BoolVar(&rm, "rm", false, "Stream the output of the container until it exits, exit with its exit code and remove it, even if interrupted")
+	}
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) < 1 {
+			slog.Error(cmd + ": usage: " + cmd + " [flags] <image> [<command> [<arg>...]]")
+			return 1
+		}
+		if rm && flags.restart != "" && flags.restart != "no" {
+			slog.Error(cmd + ": -rm and -restart cannot be used together")
+			return 1
+		}
+		spec, err := flags.spec(rc.args[0], rc.args[1:])
+		if err != nil {
+			slog.Error(cmd, "err", err)
+			return 1
+		}
+		if rm {
+			// The container may run for as long as it likes
+			rc.requestTimeout = 0
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		id, err := createContainer(ctx, httpClient, spec)
+		if err != nil {
+			slog.Error(cmd, "image", spec.Image, "err", err)
+			return 1
+		}
+		if rm {
+			return runAndRemove(ctx, httpClient, id, os.Stdout, os.Stderr)
+		}
+		if start {
+			if err := containerAction(ctx, httpClient, id, "start"); err != nil {
+				slog.Error(cmd, "target", id, "err", err)
+				return 1
+			}
+		}
+
+		fmt.Println(id)
+		return 0
+	}
+}
+
+// runAndRemove starts the container id, streams its output to stdout and
+// stderr until it exits and returns its exit code, as wait does, removing it with its anonymous volumes
+// afterwards, even when ctx is canceled by an interrupt, in which case the
+// running container is stopped as it is removed.
+func runAndRemove(ctx context.Context, httpClient *http.Client, id string, stdout, stderr io.Writer) int {
+	defer func() {
+		if err := removeContainer(context.WithoutCancel(ctx), httpClient, id, removeOptions{force: true, volumes: true}); err != nil {
+			slog.Error("Failed to remove the container", "id", shortID(id), "err", err)
+		}
+	}()
+	interrupted := func() int {
+		slog.Warn("run: interrupted, removing the container", "id", shortID(id))
+		return 130
+	}
+
+	if err := containerAction(ctx, httpClient, id, "start"); err != nil {
+		slog.Error("run", "target", id, "err", err)
+		return 1
+	}
+	query := url.Values{"follow": {"true"}, "stdout": {"true"}, "stderr": {"true"}}
+	if err := containerLogs(ctx, httpClient, id, query, stdout, stderr); err != nil {
+		if ctx.Err() != nil {
+			return interrupted()
+		}
+		slog.Error("run", "target", id, "err", err)
+		return 1
+	}
+	exitCode, err := waitContainer(ctx, httpClient, id, "stopped")
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted()
+		}
+		slog.Error("run", "target", id, "err", err)
+		return 1
+	}
+	return waitExitCode(exitCode, "stopped")
+}
+
+// createContainer creates a container from spec and returns its ID,
+// logging the warnings Podman reports.
+func createContainer(ctx context.Context, httpClient *http.Client, spec containerSpec) (string, error) {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/create", nil, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID       string   `json:"Id"`
+		Warnings []string `json:"Warnings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	for _, w := range result.Warnings {
+		slog.Warn("create", "warning", w)
+	}
+	return result.ID, nil
+}
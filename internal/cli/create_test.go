@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// parseSpec parses args with the create flags and builds the spec.
+func parseSpec(t *testing.T, args ...string) (containerSpec, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var flags specFlags
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return containerSpec{}, err
+	}
+	return flags.spec(fs.Arg(0), fs.Args()[1:])
+}
+
+func TestSpecFlags(t *testing.T) {
+	spec, err := parseSpec(t,
+		"-name", "web", "-restart", "on-failure:3", "-memory", "512m", "-cpus", "1.5", "-cpu-shares", "512",
+		"-pids-limit", "200", "-ulimit", "nofile=1024:2048", "-ulimit", "core=-1",
+		"-cap-drop", "all", "-cap-add", "net_bind_service", "-cap-add", "CAP_CHOWN",
+		"nginx", "nginx", "-g", "daemon off;")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"web","image":"nginx","command":["nginx","-g","daemon off;"],"restart_policy":"on-failure","restart_tries":3,` +
+		`"resource_limits":{"cpu":{"shares":512,"quota":150000,"period":100000},"memory":{"limit":536870912},"pids":{"limit":200}},` +
+		`"r_limits":[{"type":"RLIMIT_NOFILE","hard":2048,"soft":1024},{"type":"RLIMIT_CORE","hard":18446744073709551615,"soft":18446744073709551615}],` +
+		`"cap_add":["CAP_NET_BIND_SERVICE","CAP_CHOWN"],"cap_drop":["ALL"]}`
+	if string(got) != want {
+		t.Errorf("spec() = %s\nwant %s", got, want)
+	}
+}
+
+func TestSpecFlags_Minimal(t *testing.T) {
+	spec, err := parseSpec(t, "alpine")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+	got, _ := json.Marshal(spec)
+	if want := `{"image":"alpine"}`; string(got) != want {
+		t.Errorf("spec() = %s, want %s", got, want)
+	}
+}
+
+func TestSpecFlags_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"restart policy", []string{"-restart", "sometimes"}, "invalid -restart"},
+		{"restart count", []string{"-restart", "always:3"}, "only on-failure"},
+		{"memory", []string{"-memory", "lots"}, "invalid -memory"},
+		{"cpus", []string{"-cpus", "-1"}, "must not be negative"},
+		{"ulimit name", []string{"-ulimit", "files=10"}, "invalid -ulimit"},
+		{"ulimit value", []string{"-ulimit", "nofile=many"}, "not a limit"},
+		{"ulimit order", []string{"-ulimit", "nofile=2048:1024"}, "soft limit exceeds hard limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSpec(t, append(tt.args, "alpine")...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("spec() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecFlags_Labels(t *testing.T) {
+	spec, err := parseSpec(t,
+		"-label", "owner=fleet", "-label", "tier=edge", "-label", "canary", "-label", "tier=core",
+		"-annotation", "io.podman.annotations.autoremove=FALSE",
+		"alpine")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+	got, _ := json.Marshal(spec)
+	want := `{"image":"alpine","labels":{"canary":"","owner":"fleet","tier":"core"},"annotations":{"io.podman.annotations.autoremove":"FALSE"}}`
+	if string(got) != want {
+		t.Errorf("spec() = %s\nwant %s", got, want)
+	}
+
+	for _, args := range [][]string{{"-label", "=x"}, {"-annotation", "note"}} {
+		if _, err := parseSpec(t, append(args, "alpine")...); err == nil || !strings.Contains(err.Error(), "want key=value") {
+			t.Errorf("spec(%q) error = %v, want key=value error", args, err)
+		}
+	}
+}
+
+func TestSpecFlags_Env(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "app.env")
+	data := "# database\nDB_HOST=db.internal\n\nDB_PASS=p=ss word\r\n  LOG_LEVEL=info\nFROM_LOCAL\nUNSET_LOCAL\n"
+	if err := os.WriteFile(envFile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FROM_LOCAL", "local")
+	t.Setenv("FROM_FLAG", "flag")
+	os.Unsetenv("UNSET_LOCAL")
+
+	spec, err := parseSpec(t, "-env-file", envFile, "-env", "LOG_LEVEL=debug", "-env", "FROM_FLAG", "-env", "EMPTY=", "alpine")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+	want := map[string]string{
+		"DB_HOST":    "db.internal",
+		"DB_PASS":    "p=ss word",
+		"LOG_LEVEL":  "debug",
+		"FROM_LOCAL": "local",
+		"FROM_FLAG":  "flag",
+		"EMPTY":      "",
+	}
+	if len(spec.Env) != len(want) {
+		t.Errorf("spec() env = %v, want %v", spec.Env, want)
+	}
+	for k, v := range want {
+		if got, ok := spec.Env[k]; !ok || got != v {
+			t.Errorf("spec() env[%s] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestSpecFlags_EnvInvalid(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "bad.env")
+	if err := os.WriteFile(envFile, []byte("OK=1\n1BAD=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"file key", []string{"-env-file", envFile}, "bad.env:2: \"1BAD\" is not a valid variable name"},
+		{"missing file", []string{"-env-file", envFile + ".missing"}, "-env-file"},
+		{"flag key", []string{"-env", "MY VAR=1"}, "invalid -env"},
+		{"empty key", []string{"-env", "=1"}, "invalid -env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSpec(t, append(tt.args, "alpine")...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("spec() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecFlags_Secrets(t *testing.T) {
+	spec, err := parseSpec(t,
+		"-secret", "tls-key",
+		"-secret", "db-cert,target=/etc/db/cert.pem,uid=1000,gid=1000,mode=0440",
+		"-secret", "api-token,type=env,target=API_TOKEN",
+		"-secret", "REGISTRY_PASS,type=env",
+		"alpine")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+
+	got, _ := json.Marshal(struct {
+		Secrets   []specSecret      `json:"secrets"`
+		SecretEnv map[string]string `json:"secret_env"`
+	}{spec.Secrets, spec.SecretEnv})
+	want := `{"secrets":[{"Source":"tls-key","UID":0,"GID":0},{"Source":"db-cert","Target":"/etc/db/cert.pem","UID":1000,"GID":1000,"Mode":288}],` +
+		`"secret_env":{"API_TOKEN":"api-token","REGISTRY_PASS":"REGISTRY_PASS"}}`
+	if string(got) != want {
+		t.Errorf("spec() secrets = %s\nwant %s", got, want)
+	}
+}
+
+func TestSpecFlags_SecretInvalid(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{",target=/x", "secret name is required"},
+		{"key,type=file", "type must be mount or env"},
+		{"key,mode=999", "bad mode"},
+		{"key,uid=-1", "bad uid"},
+		{"key,owner=root", "unknown option"},
+		{"key,target", "key=value"},
+		{"key,type=env,mode=0400", "only apply to type=mount"},
+		{"1password,type=env", "not a valid variable name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			_, err := parseSpec(t, "-secret", tt.secret, "alpine")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("spec() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUlimit(t *testing.T) {
+	got, err := parseUlimit("nproc=-1:-1")
+	if err != nil {
+		t.Fatalf("parseUlimit() unexpected error = %v", err)
+	}
+	if got.Type != "RLIMIT_NPROC" || got.Soft != math.MaxUint64 || got.Hard != math.MaxUint64 {
+		t.Errorf("parseUlimit() = %+v, want unlimited nproc", got)
+	}
+}
+
+func TestCreateContainer(t *testing.T) {
+	var gotSpec map[string]any
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3.0.0/libpod/containers/create" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&gotSpec)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"abc123","Warnings":[]}`))
+	}))
+
+	id, err := createContainer(context.Background(), httpClient, containerSpec{Image: "alpine", RestartPolicy: "always"})
+	if err != nil {
+		t.Fatalf("createContainer() unexpected error = %v", err)
+	}
+	if id != "abc123" {
+		t.Errorf("createContainer() = %q, want %q", id, "abc123")
+	}
+	if gotSpec["image"] != "alpine" || gotSpec["restart_policy"] != "always" {
+		t.Errorf("createContainer() sent %v", gotSpec)
+	}
+}
+
+func TestCreateContainer_Error(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no such image"}`))
+	}))
+
+	if _, err := createContainer(context.Background(), httpClient, containerSpec{Image: "missing"}); err == nil || !strings.Contains(err.Error(), "no such image") {
+		t.Errorf("createContainer() error = %v, want no such image", err)
+	}
+}
+
+func TestRunAndRemove(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+	logsStarted := make(chan struct{})
+	handler := func(block bool) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls = append(calls, r.Method+" "+r.URL.Path+"?"+r.URL.Query().Encode())
+			mu.Unlock()
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/start"):
+				w.WriteHeader(http.StatusNoContent)
+			case strings.HasSuffix(r.URL.Path, "/logs"):
+				w.Write(frame(1, "hello\n"))
+				w.Write(frame(2, "oops\n"))
+				if block {
+					w.(http.Flusher).Flush()
+					close(logsStarted)
+					<-r.Context().Done()
+				}
+			case strings.HasSuffix(r.URL.Path, "/wait"):
+				w.Write([]byte("3\n"))
+			case r.Method == http.MethodDelete:
+				w.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+
+	var stdout, stderr strings.Builder
+	if code := runAndRemove(context.Background(), newTestHTTPClient(t, handler(false)), "abc", &stdout, &stderr); code != 3 {
+		t.Errorf("runAndRemove() = %d, want the exit code 3", code)
+	}
+	if stdout.String() != "hello\n" || stderr.String() != "oops\n" {
+		t.Errorf("output = %q, %q, want hello and oops", stdout.String(), stderr.String())
+	}
+	want := []string{
+		"POST /v3.0.0/libpod/containers/abc/start?",
+		"GET /v3.0.0/libpod/containers/abc/logs?follow=true&stderr=true&stdout=true",
+		"POST /v3.0.0/libpod/containers/abc/wait?condition=stopped",
+		"DELETE /v3.0.0/libpod/containers/abc?force=true&v=true",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", calls, want)
+	}
+
+	// Interrupted while streaming, the container is still removed
+	calls = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-logsStarted
+		cancel()
+	}()
+	if code := runAndRemove(ctx, newTestHTTPClient(t, handler(true)), "abc", io.Discard, io.Discard); code != 130 {
+		t.Errorf("interrupted runAndRemove() = %d, want 130", code)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 3 || calls[2] != want[3] {
+		t.Errorf("interrupted requests = %q, want the container removed after its logs", calls)
+	}
+}
+
+func TestRunAndRemove_UnknownExitCode(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			w.Write([]byte("-1\n"))
+		}
+	}))
+	if code := runAndRemove(context.Background(), httpClient, "abc", io.Discard, io.Discard); code != waitUnknownExitCode {
+		t.Errorf("runAndRemove() = %d, want %d for an unknown exit code", code, waitUnknownExitCode)
+	}
+}
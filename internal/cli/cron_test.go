@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	// Wednesday
+	from := time.Date(2026, time.March, 11, 10, 17, 42, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2026-03-11 10:18"},
+		{"*/15 * * * *", "2026-03-11 10:30"},
+		{"0 3 * * *", "2026-03-12 03:00"},
+		{"@hourly", "2026-03-11 11:00"},
+		{"@weekly", "2026-03-15 00:00"},
+		{"30 2 1 * *", "2026-04-01 02:30"},
+		{"0 9 * * mon-fri", "2026-03-12 09:00"},
+		{"0 0 * * 7", "2026-03-15 00:00"},
+		{"0 12 * jun *", "2026-06-01 12:00"},
+		{"5,10 8-9 * * *", "2026-03-12 08:05"},
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+		// Either day field matches when neither is *
+		{"0 0 13 * fri", "2026-03-13 00:00"},
+		{"0 0 20 * wed", "2026-03-18 00:00"},
+		{"0 22/1 * * *", "2026-03-11 22:00"},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) unexpected error = %v", tt.expr, err)
+			continue
+		}
+		if got := s.next(from).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("parseCron(%q).next() = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronSchedule_NextNever(t *testing.T) {
+	s, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parseCron() unexpected error = %v", err)
+	}
+	if got := s.next(time.Now()); !got.IsZero() {
+		t.Errorf("next() = %v, want the zero time for February 30", got)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * *", "want 5 fields"},
+		{"@often", "want 5 fields"},
+		{"60 * * * *", `invalid value "60" in minute field`},
+		{"* * 0 * *", "day of month field"},
+		{"* * * foo *", "month field"},
+		{"*/0 * * * *", `invalid step "0"`},
+		{"10-5 * * * *", `invalid range "10-5"`},
+		{"* * * * 8", "day of week field"},
+	}
+	for _, tt := range tests {
+		if _, err := parseCron(tt.expr); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCron(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
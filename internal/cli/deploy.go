@@ -0,0 +1,667 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/models"
+	"golang.org/x/term"
+)
+
+// deploySpecLabel holds the spec a container was created from by deploy,
+// as JSON, so that later deployments can tell what changed: the inspect
+// data of a container mixes in the defaults of its image and of Podman.
+const deploySpecLabel = "io.podman-cli.deploy.spec"
+
+// Actions planned by deploy for a container.
+const (
+	deployCreate    = "create"
+	deployReplace   = "replace"
+	deployStart     = "start"
+	deployRemove    = "remove"
+	deployUnchanged = "unchanged"
+)
+
+// Outcomes of applying a deployment to a host.
+const (
+	deployApplied     = "applied"
+	deployFailed      = "failed"
+	deployUnreachable = "unreachable"
+)
+
+// deployFile is a deployment file: containers, networks and volumes in the
+// syntax of a Compose file, deployed on several hosts, and images the
+// hosts must have, whose values may refer to variables set for all hosts
+// by vars and per host by hosts.
+type deployFile struct {
+	name       string
+	dir        string
+	data       []byte
+	vars       map[string]string
+	hosts      map[string]map[string]string
+	containers bool // whether the file declares containers
+}
+
+// deployChange is a change planned for a container on a host.
+type deployChange struct {
+	action  string
+	name    string
+	service *composeService // nil for removals
+	reason  string
+	diff    []string
+}
+
+// deployPlan is what deploying the project on a host involves.
+type deployPlan struct {
+	resources []string
+	changes   []deployChange
+}
+
+// newDeployCommand returns the "deploy" command, which reconciles several
+// hosts with a deployment file: "plan" shows the changes each host needs,
+// and "apply" makes them, pulling the images and replacing the containers
+// whose spec or image changed.
+func newDeployCommand(fs *flag.FlagSet) runFunc {
+	var file, hosts, authFile string
+	var maxParallel int
+	var tlsVerify bool
+	fs.StringVar(&file, "f", "deployment.yaml", "Deployment file")
+	fs.StringVar(&hosts, "hosts", "", "Comma separated hosts, or @<group> for a group of the configuration file (default the hosts of the file)")
+	fs.IntVar(&maxParallel, "max-parallel", 4, "Number of hosts deployed to at the same time")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when contacting the registry")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("deploy: usage: deploy [flags] plan|apply [flags]")
+			return 1
+		}
+		action := rc.args[0]
+
+		// Flags may also follow the action, as in "deploy plan -hosts @fleet"
+		if err := fs.Parse(rc.args[1:]); err != nil {
+			slog.Error("deploy", "err", err)
+			return 1
+		}
+		if fs.NArg() > 0 {
+			slog.Error("deploy: unexpected arguments", "args", strings.Join(fs.Args(), " "))
+			return 1
+		}
+		if action != "plan" && action != "apply" {
+			slog.Error("deploy: unknown action (use plan or apply)", "action", action)
+			return 1
+		}
+		if maxParallel < 1 {
+			slog.Error("deploy: -max-parallel must be at least 1")
+			return 1
+		}
+
+		d, err := loadDeployFile(file)
+		if err != nil {
+			slog.Error("deploy", "err", err)
+			return 1
+		}
+		targets := sortedKeys(d.hosts)
+		if hosts != "" {
+			if targets, err = resolveHosts(hosts, rc.opts.configFile); err != nil {
+				slog.Error("deploy", "err", err)
+				return 1
+			}
+		}
+		if len(targets) == 0 {
+			slog.Error("deploy: no hosts (use -hosts, or list them under hosts in the file)")
+			return 1
+		}
+		// Every host must have a valid project before any is changed
+		projects := make([]*composeProject, len(targets))
+		for i, host := range targets {
+			if projects[i], err = d.project(host); err != nil {
+				slog.Error("deploy", "host", host, "err", fmt.Errorf("%s: %w", file, err))
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		plans := make([]*deployPlan, len(targets))
+		forEachHost(targets, maxParallel, func(i int, host string) {
+			httpClient, closeFn, err := connectHost(ctx, rc, host)
+			if err != nil {
+				slog.Error("deploy: failed to connect", "host", host, "err", err)
+				return
+			}
+			defer closeFn()
+			if plans[i], err = planDeploy(ctx, httpClient, projects[i]); err != nil {
+				slog.Error("deploy plan", "host", host, "err", err)
+			}
+		})
+
+		// The outcome table of apply is the output with -format json, so
+		// the plan and the actions taken go to stderr instead
+		report := os.Stdout
+		if action == "apply" && rc.opts.format != formatText {
+			report = os.Stderr
+		}
+		code := 0
+		for i, host := range targets {
+			if plans[i] == nil {
+				fmt.Fprintf(report, "%s: could not be planned\n", host)
+				code = 1
+				continue
+			}
+			fmt.Fprintf(report, "%s:\n", host)
+			plans[i].write(report)
+		}
+		if action == "plan" || code != 0 {
+			return code
+		}
+
+		if !rc.opts.yes && term.IsTerminal(int(os.Stdin.Fd())) && !confirm(fmt.Sprintf("Apply the deployment %s to %d hosts?", d.name, len(targets))) {
+			return 1
+		}
+
+		authHeaders := map[string]string{}
+		for _, p := range projects {
+			for _, svc := range p.Services {
+				if _, ok := authHeaders[svc.Spec.Image]; ok {
+					continue
+				}
+				if authHeaders[svc.Spec.Image], err = registryAuth(authFile, svc.Spec.Image); err != nil {
+					slog.Error("deploy", "err", err)
+					return 1
+				}
+			}
+		}
+
+		width := 0
+		for _, host := range targets {
+			width = max(width, len(host))
+		}
+		var mu sync.Mutex
+		results := make([]string, len(targets))
+		forEachHost(targets, maxParallel, func(i int, host string) {
+			prefix := fmt.Sprintf("%-*s | ", width, host)
+			if colorEnabled(report) {
+				prefix = logColors[i%len(logColors)] + prefix + ansiReset
+			}
+			pw := &prefixWriter{w: report, mu: &mu, prefix: prefix}
+			defer pw.flush()
+
+			httpClient, closeFn, err := connectHost(ctx, rc, host)
+			if err != nil {
+				slog.Error("deploy: failed to connect", "host", host, "err", err)
+				results[i] = deployUnreachable
+				return
+			}
+			defer closeFn()
+			pull := func(image string) error {
+				return pullImage(ctx, httpClient, image, tlsVerify, authHeaders[image], io.Discard, nil)
+			}
+			if err := applyDeploy(ctx, httpClient, projects[i], pull, pw); err != nil {
+				slog.Error("deploy apply", "host", host, "err", err)
+				results[i] = deployFailed
+				return
+			}
+			results[i] = deployApplied
+		})
+
+		rows := make([]tuiRow, len(targets))
+		for i, host := range targets {
+			rows[i] = tuiRow{id: host, cols: []string{host, results[i]}}
+			if results[i] != deployApplied {
+				code = 1
+			}
+		}
+		if err := writeTable(os.Stdout, rc.opts.format, []string{"HOST", "RESULT"}, rows); err != nil {
+			slog.Error("deploy", "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// forEachHost calls fn for each host, with at most maxParallel calls at
+// the same time, and returns once all have returned.
+func forEachHost(hosts []string, maxParallel int, fn func(i int, host string)) {
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(i, host)
+		}()
+	}
+	wg.Wait()
+}
+
+// connectHost connects to host with the settings of rc, returning the API
+// client and the function closing the connection.
+func connectHost(ctx context.Context, rc *RemoteCLI, host string) (*http.Client, func() error, error) {
+	hostRC, err := rc.forHost(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	sshClient, httpClient, err := hostRC.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return httpClient, sshClient.Close, nil
+}
+
+// loadDeployFile reads the deployment file at path. Its containers are
+// only translated per host, by project, once variables are substituted.
+func loadDeployFile(path string) (*deployFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseDeployFile(data, filepath.Dir(abs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// parseDeployFile parses the top level of a deployment file, whose
+// relative paths are taken from dir.
+func parseDeployFile(data []byte, dir string) (*deployFile, error) {
+	tree, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	top, err := composeMap(tree, "top level")
+	if err != nil {
+		return nil, err
+	}
+	for key := range top {
+		switch key {
+		case "name", "vars", "hosts", "containers", "networks", "volumes", "images":
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				slog.Warn("deploy: ignoring unsupported key", "key", key)
+			}
+		}
+	}
+
+	d := &deployFile{dir: dir, data: data, hosts: map[string]map[string]string{}}
+	name, _ := top["name"].(string)
+	if d.name = projectName(name); d.name == "" {
+		return nil, fmt.Errorf("name is required, with letters, digits, dashes and underscores")
+	}
+	if d.vars, err = deployVars(top["vars"], "vars"); err != nil {
+		return nil, err
+	}
+	hosts, err := composeMap(top["hosts"], "hosts")
+	if err != nil {
+		return nil, err
+	}
+	for host, vars := range hosts {
+		if d.hosts[host], err = deployVars(vars, "hosts."+host); err != nil {
+			return nil, err
+		}
+	}
+	d.containers = top["containers"] != nil
+	if !d.containers && top["images"] == nil {
+		return nil, fmt.Errorf("no containers or images defined")
+	}
+	return d, nil
+}
+
+// deployVars returns v, a mapping of variable names to strings.
+func deployVars(v any, what string) (map[string]string, error) {
+	m, err := composeMap(v, what)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(m))
+	for key, value := range m {
+		if !validEnvKey(key) {
+			return nil, fmt.Errorf("%s: invalid variable name %q", what, key)
+		}
+		switch value := value.(type) {
+		case nil:
+			vars[key] = ""
+		case string:
+			vars[key] = value
+		default:
+			return nil, fmt.Errorf("%s.%s: want a string", what, key)
+		}
+	}
+	return vars, nil
+}
+
+// project returns the project deployed on host: the containers, networks
+// and volumes of the file with their variables substituted from those of
+// host, then those of vars. The variable host is the name of the host
+// unless set otherwise.
+func (d *deployFile) project(host string) (*composeProject, error) {
+	top, lookup, err := d.hostValues(host)
+	if err != nil {
+		return nil, err
+	}
+	compose := map[string]any{"services": top["containers"], "networks": top["networks"], "volumes": top["volumes"]}
+	for key, v := range compose {
+		if compose[key], err = interpolateTree(v, lookup); err != nil {
+			return nil, err
+		}
+	}
+	return newComposeProject(compose, d.dir, d.name)
+}
+
+// images returns the images listed under images for host, with their
+// variables substituted as project does.
+func (d *deployFile) images(host string) ([]desiredImage, error) {
+	top, lookup, err := d.hostValues(host)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := interpolateTree(top["images"], lookup)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := composeStrings(tree, "images")
+	if err != nil {
+		return nil, err
+	}
+	images := make([]desiredImage, len(refs))
+	for i, ref := range refs {
+		if images[i], err = parseDesiredImage(ref); err != nil {
+			return nil, fmt.Errorf("images: %w", err)
+		}
+	}
+	return images, nil
+}
+
+// hostValues returns the top level of the file, parsed again as
+// substitution replaces values in place, and the lookup of the variables
+// of host.
+func (d *deployFile) hostValues(host string) (map[string]any, func(string) (string, bool), error) {
+	tree, err := parseYAML(d.data)
+	if err != nil {
+		return nil, nil, err
+	}
+	top, err := composeMap(tree, "top level")
+	if err != nil {
+		return nil, nil, err
+	}
+	vars := map[string]string{"host": host}
+	maps.Copy(vars, d.vars)
+	maps.Copy(vars, d.hosts[host])
+	return top, func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}, nil
+}
+
+// planDeploy returns the changes deploying the project needs on the host:
+// missing networks and volumes, containers to create, to replace because
+// their spec changed or they were not created by deploy, to start, and
+// containers of the deployment no longer in it, to remove.
+func planDeploy(ctx context.Context, httpClient *http.Client, p *composeProject) (*deployPlan, error) {
+	plan := &deployPlan{}
+	var err error
+	if plan.resources, err = projectResources(ctx, httpClient, p, false, io.Discard); err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for i := range p.Services {
+		svc := &p.Services[i]
+		name := svc.Spec.Name
+		wanted[name] = true
+		change := deployChange{action: deployCreate, name: name, service: svc}
+
+		var ctr models.InspectContainerData
+		err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr)
+		switch {
+		case isStatus(err, http.StatusNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			change.action, change.reason, change.diff, err = compareDeployed(ctr, svc.Spec)
+			if err != nil {
+				return nil, fmt.Errorf("container %s: %w", name, err)
+			}
+		}
+		plan.changes = append(plan.changes, change)
+	}
+
+	filters, err := encodeFilters([]string{"label=" + composeProjectLabel + "=" + p.Name, "label=" + deploySpecLabel})
+	if err != nil {
+		return nil, err
+	}
+	names, err := filteredContainers(ctx, httpClient, filters)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !wanted[name] {
+			plan.changes = append(plan.changes, deployChange{action: deployRemove, name: name, reason: "no longer in the deployment"})
+		}
+	}
+	return plan, nil
+}
+
+// compareDeployed returns the action bringing the inspected container ctr
+// to spec, why, and the differences between the spec it was deployed
+// from and spec.
+func compareDeployed(ctr models.InspectContainerData, spec containerSpec) (action, reason string, diff []string, err error) {
+	deployed, ok := ctr.Config.Labels[deploySpecLabel]
+	if !ok {
+		return deployReplace, "not created by deploy", nil, nil
+	}
+	wanted, err := deploySpecJSON(spec)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if diff = diffSpecs(deployed, wanted); len(diff) > 0 {
+		return deployReplace, "spec changed", diff, nil
+	}
+	if !ctr.State.Running {
+		return deployStart, ctr.State.Status, nil, nil
+	}
+	return deployUnchanged, "", nil, nil
+}
+
+// deploySpecJSON returns spec as stored in deploySpecLabel.
+func deploySpecJSON(spec containerSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	return string(data), err
+}
+
+// diffSpecs returns the differences between the JSON specs old and new,
+// one field per line, as "path: old -> new", "+ path: new" for a field
+// only in new and "- path: old" for one only in old, sorted by path.
+func diffSpecs(old, new string) []string {
+	before, after := map[string]string{}, map[string]string{}
+	for _, f := range []struct {
+		data   string
+		fields map[string]string
+	}{{old, before}, {new, after}} {
+		var v any
+		if err := json.Unmarshal([]byte(f.data), &v); err != nil {
+			f.fields[""] = f.data
+			continue
+		}
+		flattenJSON("", v, f.fields)
+	}
+
+	paths := sortedKeys(before)
+	paths = appendUnique(paths, sortedKeys(after)...)
+	sort.Strings(paths)
+	var diff []string
+	for _, path := range paths {
+		b, inBefore := before[path]
+		a, inAfter := after[path]
+		switch {
+		case !inBefore:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", path, a))
+		case !inAfter:
+			diff = append(diff, fmt.Sprintf("- %s: %s", path, b))
+		case a != b:
+			diff = append(diff, fmt.Sprintf("  %s: %s -> %s", path, b, a))
+		}
+	}
+	return diff
+}
+
+// flattenJSON adds the scalar values of the decoded JSON v to fields,
+// keyed by their path from prefix, such as env.MODE or portmappings[0].
+func flattenJSON(prefix string, v any, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, item := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenJSON(path, item, fields)
+		}
+	case []any:
+		for i, item := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), item, fields)
+		}
+	default:
+		data, _ := json.Marshal(v)
+		fields[prefix] = string(data)
+	}
+}
+
+// write writes the plan, one change per line, to out.
+func (plan *deployPlan) write(out io.Writer) {
+	changed := false
+	for _, r := range plan.resources {
+		fmt.Fprintf(out, "  + %s\n", r)
+		changed = true
+	}
+	for _, c := range plan.changes {
+		symbol := map[string]string{deployCreate: "+", deployReplace: "~", deployStart: ">", deployRemove: "-"}[c.action]
+		if symbol == "" {
+			continue
+		}
+		changed = true
+		line := fmt.Sprintf("  %s container %s (%s", symbol, c.name, c.action)
+		if c.reason != "" {
+			line += ": " + c.reason
+		}
+		fmt.Fprintln(out, line+")")
+		for _, d := range c.diff {
+			fmt.Fprintf(out, "      %s\n", d)
+		}
+	}
+	if !changed {
+		fmt.Fprintln(out, "  no changes")
+	}
+}
+
+// applyDeploy deploys the project on the host, writing the actions taken
+// to out: it creates the missing networks and volumes, pulls the image of
+// each container with pull, and creates, replaces or starts containers in
+// dependency order, replacing those whose image was updated by the pull,
+// then removes the containers no longer in the deployment.
+func applyDeploy(ctx context.Context, httpClient *http.Client, p *composeProject, pull func(image string) error, out io.Writer) error {
+	plan, err := planDeploy(ctx, httpClient, p)
+	if err != nil {
+		return err
+	}
+	if _, err := projectResources(ctx, httpClient, p, true, out); err != nil {
+		return err
+	}
+
+	for _, c := range plan.changes {
+		if c.action == deployRemove {
+			continue
+		}
+		spec := c.service.Spec
+		if err := pull(spec.Image); err != nil {
+			return fmt.Errorf("pull %s: %w", spec.Image, err)
+		}
+		if c.action == deployStart || c.action == deployUnchanged {
+			updated, err := imageUpdated(ctx, httpClient, c.name, spec.Image)
+			if err != nil {
+				return err
+			}
+			if updated {
+				c.action, c.reason = deployReplace, "image updated"
+			}
+		}
+
+		switch c.action {
+		case deployUnchanged:
+			continue
+		case deployReplace:
+			if err := removeContainer(ctx, httpClient, c.name, removeOptions{force: true}); err != nil && !isStatus(err, http.StatusNotFound) {
+				return fmt.Errorf("remove %s: %w", c.name, err)
+			}
+			fmt.Fprintf(out, "Removed container %s (%s)\n", c.name, c.reason)
+			fallthrough
+		case deployCreate:
+			data, err := deploySpecJSON(spec)
+			if err != nil {
+				return err
+			}
+			spec.Labels = maps.Clone(spec.Labels)
+			if spec.Labels == nil {
+				spec.Labels = map[string]string{}
+			}
+			spec.Labels[deploySpecLabel] = data
+			if _, err := createContainer(ctx, httpClient, spec); err != nil {
+				return fmt.Errorf("container %s: %w", c.name, err)
+			}
+			fmt.Fprintf(out, "Created container %s from %s\n", c.name, spec.Image)
+		}
+		if err := containerAction(ctx, httpClient, c.name, "start"); err != nil {
+			return fmt.Errorf("container %s: %w", c.name, err)
+		}
+		fmt.Fprintf(out, "Started container %s\n", c.name)
+	}
+
+	for _, c := range plan.changes {
+		if c.action != deployRemove {
+			continue
+		}
+		if err := removeContainer(ctx, httpClient, c.name, removeOptions{force: true}); err != nil && !isStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("remove %s: %w", c.name, err)
+		}
+		fmt.Fprintf(out, "Removed container %s (%s)\n", c.name, c.reason)
+	}
+	return nil
+}
+
+// imageUpdated reports whether image, as now on the host, is another image
+// than the named container runs.
+func imageUpdated(ctx context.Context, httpClient *http.Client, name, image string) (bool, error) {
+	var ctr models.InspectContainerData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr); err != nil {
+		return false, err
+	}
+	var img models.ImageData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(image)+"/json", nil, &img); err != nil {
+		return false, err
+	}
+	return img.ID != ctr.Image, nil
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+const testDeployment = `name: shop
+vars:
+  tag: "1.27"
+  region: eu
+hosts:
+  edge1:
+    region: us
+  edge2:
+containers:
+  web:
+    image: nginx:${tag}
+    ports: ["8080:80"]
+    environment:
+      REGION: ${region}
+      NODE: ${host}
+    depends_on: [db]
+  db:
+    image: postgres:16
+`
+
+func TestDeployFileProject(t *testing.T) {
+	d, err := parseDeployFile([]byte(testDeployment), "/srv/deploy")
+	if err != nil {
+		t.Fatalf("parseDeployFile() unexpected error = %v", err)
+	}
+	if d.name != "shop" || !reflect.DeepEqual(sortedKeys(d.hosts), []string{"edge1", "edge2"}) {
+		t.Errorf("parseDeployFile() = %s %v, want shop with edge1 and edge2", d.name, sortedKeys(d.hosts))
+	}
+
+	for _, tt := range []struct {
+		host    string
+		wantEnv map[string]string
+	}{
+		{"edge1", map[string]string{"REGION": "us", "NODE": "edge1"}},
+		{"edge3", map[string]string{"REGION": "eu", "NODE": "edge3"}},
+	} {
+		p, err := d.project(tt.host)
+		if err != nil {
+			t.Fatalf("project(%s) unexpected error = %v", tt.host, err)
+		}
+		if len(p.Services) != 2 || p.Services[0].Name != "db" || p.Services[1].Name != "web" {
+			t.Fatalf("project(%s) services = %+v, want db then web", tt.host, p.Services)
+		}
+		web := p.Services[1].Spec
+		if web.Name != "shop-web-1" || web.Image != "nginx:1.27" || !reflect.DeepEqual(web.Env, tt.wantEnv) {
+			t.Errorf("project(%s) web = %s %s %v, want shop-web-1 nginx:1.27 %v", tt.host, web.Name, web.Image, web.Env, tt.wantEnv)
+		}
+	}
+
+	for _, file := range []string{
+		"containers:\n  web:\n    image: nginx\n",
+		"name: shop\n",
+		"name: shop\nvars:\n  tag: [1]\ncontainers:\n  web:\n    image: nginx\n",
+		"name: shop\nvars:\n  1tag: x\ncontainers:\n  web:\n    image: nginx\n",
+	} {
+		if _, err := parseDeployFile([]byte(file), "/srv/deploy"); err == nil {
+			t.Errorf("parseDeployFile(%q) expected error, got nil", file)
+		}
+	}
+}
+
+func TestDiffSpecs(t *testing.T) {
+	old := `{"image":"nginx:1.26","env":{"REGION":"us","DEBUG":"1"},"portmappings":[{"container_port":80,"host_port":8080}]}`
+	new := `{"image":"nginx:1.27","env":{"REGION":"us","NODE":"edge1"},"portmappings":[{"container_port":80,"host_port":8080}]}`
+	// Paths are sorted, whatever the change
+	want := []string{
+		"- env.DEBUG: \"1\"",
+		"+ env.NODE: \"edge1\"",
+		"  image: \"nginx:1.26\" -> \"nginx:1.27\"",
+	}
+	if got := diffSpecs(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSpecs() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := diffSpecs(new, new); len(got) != 0 {
+		t.Errorf("diffSpecs() of equal specs = %q, want none", got)
+	}
+}
+
+func TestPlanAndApplyDeploy(t *testing.T) {
+	d, err := parseDeployFile([]byte(testDeployment), "/srv/deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.project("edge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbSpec, _ := deploySpecJSON(p.Services[0].Spec)
+	oldWeb := p.Services[1].Spec
+	oldWeb.Image = "nginx:1.26"
+	webSpec, _ := deploySpecJSON(oldWeb)
+
+	// db is deployed but stopped, and its image was updated since; web was
+	// deployed with an older tag; old is no longer in the deployment
+	containers := map[string]models.InspectContainerData{
+		"shop-db-1":  {Image: "sha-old", State: models.InspectContainerState{Status: "exited"}, Config: models.InspectContainerConfig{Labels: map[string]string{deploySpecLabel: dbSpec}}},
+		"shop-web-1": {Image: "sha-web", State: models.InspectContainerState{Status: "running", Running: true}, Config: models.InspectContainerConfig{Labels: map[string]string{deploySpecLabel: webSpec}}},
+	}
+	var mu sync.Mutex
+	var requests []string
+	var created []containerSpec
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := r.URL.Path
+		if r.Method != http.MethodGet {
+			requests = append(requests, r.Method+" "+path)
+		}
+		switch {
+		case path == "/v3.0.0/libpod/networks/shop_default/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case path == "/v3.0.0/containers/json":
+			if got := r.URL.Query().Get("filters"); got != `{"label":["com.docker.compose.project=shop","`+deploySpecLabel+`"]}` {
+				t.Errorf("filters = %s", got)
+			}
+			w.Write([]byte(`[{"Names":["/shop-web-1"]},{"Names":["/shop-old-1"]},{"Names":["/shop-db-1"]}]`))
+		case path == "/v3.0.0/libpod/images/postgres:16/json":
+			w.Write([]byte(`{"Id":"sha-new"}`))
+		case strings.HasPrefix(path, "/v3.0.0/libpod/containers/") && strings.HasSuffix(path, "/json"):
+			name := strings.TrimSuffix(strings.TrimPrefix(path, "/v3.0.0/libpod/containers/"), "/json")
+			ctr, ok := containers[name]
+			if !ok {
+				http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(ctr)
+		case path == "/v3.0.0/libpod/containers/create":
+			var spec containerSpec
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &spec)
+			created = append(created, spec)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	plan, err := planDeploy(context.Background(), httpClient, p)
+	if err != nil {
+		t.Fatalf("planDeploy() unexpected error = %v", err)
+	}
+	var out bytes.Buffer
+	plan.write(&out)
+	wantPlan := `  > container shop-db-1 (start: exited)
+  ~ container shop-web-1 (replace: spec changed)
+        image: "nginx:1.26" -> "nginx:1.27"
+  - container shop-old-1 (remove: no longer in the deployment)
+`
+	if out.String() != wantPlan {
+		t.Errorf("plan =\n%s\nwant\n%s", out.String(), wantPlan)
+	}
+	if len(requests) != 0 {
+		t.Errorf("planDeploy() changed the host: %q", requests)
+	}
+
+	var pulled []string
+	pull := func(image string) error {
+		pulled = append(pulled, image)
+		return nil
+	}
+	out.Reset()
+	if err := applyDeploy(context.Background(), httpClient, p, pull, &out); err != nil {
+		t.Fatalf("applyDeploy() unexpected error = %v", err)
+	}
+	if want := []string{"postgres:16", "nginx:1.27"}; !reflect.DeepEqual(pulled, want) {
+		t.Errorf("applyDeploy() pulled %q, want %q", pulled, want)
+	}
+	wantRequests := []string{
+		"DELETE /v3.0.0/libpod/containers/shop-db-1",
+		"POST /v3.0.0/libpod/containers/create",
+		"POST /v3.0.0/libpod/containers/shop-db-1/start",
+		"DELETE /v3.0.0/libpod/containers/shop-web-1",
+		"POST /v3.0.0/libpod/containers/create",
+		"POST /v3.0.0/libpod/containers/shop-web-1/start",
+		"DELETE /v3.0.0/libpod/containers/shop-old-1",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("applyDeploy() requests =\n%s\nwant\n%s", strings.Join(requests, "\n"), strings.Join(wantRequests, "\n"))
+	}
+	if len(created) != 2 || created[1].Image != "nginx:1.27" || created[1].Labels[deploySpecLabel] == "" {
+		t.Fatalf("applyDeploy() created %+v, want web with its spec label", created)
+	}
+	// The label holds the spec without itself, so the next plan matches
+	if diff := diffSpecs(created[1].Labels[deploySpecLabel], mustDeploySpec(t, p.Services[1].Spec)); len(diff) != 0 {
+		t.Errorf("spec label differs from the deployment: %q", diff)
+	}
+	if p.Services[1].Spec.Labels[deploySpecLabel] != "" {
+		t.Error("applyDeploy() added the spec label to the project")
+	}
+	for _, line := range []string{"Removed container shop-db-1 (image updated)", "Created container shop-web-1 from nginx:1.27", "Removed container shop-old-1 (no longer in the deployment)"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("applyDeploy() output =\n%s\nwant %q", out.String(), line)
+		}
+	}
+}
+
+func mustDeploySpec(t *testing.T, spec containerSpec) string {
+	t.Helper()
+	data, err := deploySpecJSON(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// minPodmanVersion is the oldest Podman release serving the libpod API
+// version used by podman-cli.
+const minPodmanVersion = "3.0.0"
+
+// maxClockSkew is the largest difference between the local and remote
+// clocks doctor accepts. Larger skews break short-lived certificates and
+// make event timestamps misleading.
+const maxClockSkew = 10 * time.Second
+
+// doctorCheck is the outcome of one of the checks run by doctor.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"` // remediation when the check failed
+}
+
+// newDoctorCommand returns the "doctor" command, which checks that the
+// remote host is set up for podman-cli, from SSH reachability to the
+// Podman API, and suggests how to fix what is not.
+func newDoctorCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		checks := rc.doctor(ctx)
+		if err := writeChecks(os.Stdout, rc.opts.format, checks); err != nil {
+			slog.Error("doctor", "err", err)
+			return 1
+		}
+		for _, c := range checks {
+			if !c.OK {
+				return 1
+			}
+		}
+		return 0
+	}
+}
+
+// doctor runs the checks against the remote host in order, stopping at the
+// first failure that prevents the following checks from running.
+func (rc *RemoteCLI) doctor(ctx context.Context) []doctorCheck {
+	var checks []doctorCheck
+
+	conn, err := rc.proxy.Dial(rc.addr, rc.opts.timeout)
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "SSH reachable",
+			Detail: err.Error(),
+			Fix:    fmt.Sprintf("Check that the host is up and that sshd listens on %s; HostName and Port are taken from the SSH config.", rc.addr),
+		})
+	}
+	conn.Close()
+	checks = append(checks, doctorCheck{Name: "SSH reachable", OK: true, Detail: rc.addr})
+
+	sshClient, err := client.NewSSHClientVia(rc.proxy, rc.addr, rc.sshClientConfig)
+	if err != nil {
+		return append(checks, sshAuthCheck(rc.addr, err))
+	}
+	defer sshClient.Close()
+	checks = append(checks, doctorCheck{Name: "SSH authentication", OK: true, Detail: "logged in as " + sshClient.User()})
+
+	socket, err := sshClient.Dial("unix", rc.socketPath)
+	check := socketCheck(rc.socketPath, err)
+	if err == nil {
+		socket.Close()
+		var mode strings.Builder
+		if client.Exec(sshClient, "stat -c '%A %U:%G' "+client.ShellQuote(rc.socketPath), nil, &mode, nil) == nil {
+			check.Detail += " (" + strings.TrimSpace(mode.String()) + ")"
+		}
+	}
+	checks = append(checks, check)
+	if !check.OK {
+		return checks
+	}
+
+	httpClient := client.NewHTTPClient(func() (net.Conn, error) {
+		return sshClient.Dial("unix", rc.socketPath)
+	})
+	httpClient.Timeout = rc.requestTimeout
+	return append(checks, checkPodman(ctx, httpClient)...)
+}
+
+// sshAuthCheck describes the failure err to establish an SSH session with
+// addr.
+func sshAuthCheck(addr string, err error) doctorCheck {
+	check := doctorCheck{Name: "SSH authentication", Detail: err.Error()}
+
+	var keyErr *knownhosts.KeyError
+	switch {
+	case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+		check.Fix = "The host key differs from the one in known_hosts. If the host was reinstalled, remove the old key with \"ssh-keygen -R <host>\" and connect again."
+	case errors.As(err, &keyErr):
+		check.Fix = fmt.Sprintf("The host key is not in known_hosts. Verify it and add it with \"ssh-keyscan -p <port> <host> >> ~/.ssh/known_hosts\" for %s.", addr)
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		check.Fix = "No key was accepted. Check IdentityFile and User in the SSH config, that the key is loaded in ssh-agent if encrypted, and that its public half is in ~/.ssh/authorized_keys on the host."
+	default:
+		check.Fix = "Check that \"ssh <host>\" works with the same SSH config."
+	}
+	return check
+}
+
+// socketCheck describes the outcome err of connecting to the Podman socket
+// at path.
+func socketCheck(path string, err error) doctorCheck {
+	check := doctorCheck{Name: "Podman socket", OK: err == nil, Detail: path}
+	switch {
+	case err == nil:
+	case client.IsSocketMissing(err):
+		check.Detail = path + " not found"
+		check.Fix = "Enable the API socket with \"systemctl --user enable --now podman.socket\" on the host (or \"systemctl enable --now podman.socket\" as root for the rootful socket), or rerun with -ensure-service. Use -socket if the socket is elsewhere."
+	case strings.Contains(strings.ToLower(err.Error()), "permission denied"):
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("The remote user cannot open %s. Use the rootless socket under /run/user/<uid> of the user you log in as, or log in as the socket's owner.", path)
+	default:
+		check.Detail = err.Error()
+		check.Fix = "Check that sshd allows Unix socket forwarding (AllowStreamLocalForwarding yes)."
+	}
+	return check
+}
+
+// podmanVersion is the part of the libpod version response checked by
+// doctor.
+type podmanVersion struct {
+	Version string `json:"Version"`
+	Os      string `json:"Os"`
+	Arch    string `json:"Arch"`
+}
+
+// checkPodman checks the Podman version and API compatibility of the
+// service behind httpClient, and the skew between the local clock and the
+// host's, taken from the Date header of the response.
+func checkPodman(ctx context.Context, httpClient *http.Client) []doctorCheck {
+	start := time.Now()
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/version", nil, nil)
+	if err != nil {
+		return []doctorCheck{{
+			Name:   "Podman version",
+			Detail: err.Error(),
+			Fix:    "The socket does not answer as a Podman API. Check \"podman info\" and the service's logs with \"journalctl --user -u podman.service\" on the host.",
+		}}
+	}
+	defer resp.Body.Close()
+	end := time.Now()
+
+	var version podmanVersion
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return []doctorCheck{{Name: "Podman version", Detail: "invalid response: " + err.Error()}}
+	}
+	check := doctorCheck{Name: "Podman version", OK: version.Version != "", Detail: fmt.Sprintf("%s %s/%s", version.Version, version.Os, version.Arch)}
+	if !check.OK {
+		check.Fix = "The service did not report its version. Check \"podman version\" on the host."
+	}
+	checks := []doctorCheck{check}
+
+	api := resp.Header.Get("Libpod-Api-Version")
+	if api == "" {
+		api = version.Version
+	}
+	compatible := compareVersions(api, minPodmanVersion) >= 0
+	check = doctorCheck{Name: "API compatibility", OK: compatible, Detail: "libpod API " + api}
+	if !compatible {
+		check.Fix = fmt.Sprintf("podman-cli needs the libpod API of Podman %s or later. Upgrade Podman on the host.", minPodmanVersion)
+	}
+	checks = append(checks, check)
+
+	check = doctorCheck{Name: "Clock skew"}
+	if remote, err := http.ParseTime(resp.Header.Get("Date")); err != nil {
+		check.Detail = "the host did not report its time"
+		check.OK = true
+	} else {
+		// The Date header has a resolution of one second and was set while
+		// the request was in flight
+		skew := remote.Sub(start.Add(end.Sub(start) / 2)).Round(time.Second)
+		check.OK = skew.Abs() <= maxClockSkew
+		check.Detail = "remote clock is " + skewString(skew)
+		if !check.OK {
+			check.Fix = "Synchronize the clocks with NTP, for example with \"timedatectl set-ntp true\" on the host."
+		}
+	}
+	return append(checks, check)
+}
+
+// skewString describes skew, the remote time minus the local time.
+func skewString(skew time.Duration) string {
+	switch {
+	case skew > 0:
+		return skew.String() + " ahead"
+	case skew < 0:
+		return (-skew).String() + " behind"
+	}
+	return "in sync"
+}
+
+// compareVersions compares the dotted numeric versions a and b, returning
+// -1, 0 or 1. Suffixes such as "-dev" are ignored.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x = leadingInt(as[i])
+		}
+		if i < len(bs) {
+			y = leadingInt(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// leadingInt returns the number s starts with, or 0.
+func leadingInt(s string) int {
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(s)
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// writeChecks writes checks to out in the given -format: one line per check
+// with the remediation of failed ones for text, or a JSON array.
+func writeChecks(out io.Writer, format string, checks []doctorCheck) error {
+	if format != formatText {
+		enc := json.NewEncoder(out)
+		if format == formatJSON {
+			enc.SetIndent("", "  ")
+			return enc.Encode(checks)
+		}
+		for _, c := range checks {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, c := range checks {
+		status := "ok"
+		if !c.OK {
+			status = "FAIL"
+		}
+		if _, err := fmt.Fprintf(out, "%-4s  %s: %s\n", status, c.Name, c.Detail); err != nil {
+			return err
+		}
+		if c.Fix != "" {
+			if _, err := fmt.Fprintf(out, "      %s\n", c.Fix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestCheckPodman(t *testing.T) {
+	tests := []struct {
+		name    string
+		api     string
+		offset  time.Duration
+		wantOK  []bool
+		wantFix string
+	}{
+		{"healthy", "4.9.3", 0, []bool{true, true, true}, ""},
+		{"old API", "2.2.1", 0, []bool{true, false, true}, "Upgrade Podman"},
+		{"clock behind", "5.0.0", -time.Minute, []bool{true, true, false}, "NTP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v3.0.0/libpod/version" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Date", time.Now().Add(tt.offset).UTC().Format(http.TimeFormat))
+				w.Header().Set("Libpod-Api-Version", tt.api)
+				w.Write([]byte(`{"Version":"` + tt.api + `","Os":"linux","Arch":"arm64"}`))
+			}))
+
+			checks := checkPodman(context.Background(), httpClient)
+			if len(checks) != len(tt.wantOK) {
+				t.Fatalf("checkPodman() = %+v, want %d checks", checks, len(tt.wantOK))
+			}
+			var fixes []string
+			for i, c := range checks {
+				if c.OK != tt.wantOK[i] {
+					t.Errorf("checkPodman() %s ok = %v, want %v (%s)", c.Name, c.OK, tt.wantOK[i], c.Detail)
+				}
+				fixes = append(fixes, c.Fix)
+			}
+			if got := strings.Join(fixes, ""); !strings.Contains(got, tt.wantFix) || (tt.wantFix == "" && got != "") {
+				t.Errorf("checkPodman() fixes = %q, want one containing %q", got, tt.wantFix)
+			}
+			if checks[0].Detail != tt.api+" linux/arm64" {
+				t.Errorf("checkPodman() version detail = %q", checks[0].Detail)
+			}
+		})
+	}
+}
+
+func TestCheckPodman_NotPodman(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.NotFoundHandler())
+
+	checks := checkPodman(context.Background(), httpClient)
+	if len(checks) != 1 || checks[0].OK || checks[0].Fix == "" {
+		t.Errorf("checkPodman() = %+v, want one failed check with a fix", checks)
+	}
+}
+
+func TestSocketCheck(t *testing.T) {
+	const path = "/run/user/1000/podman/podman.sock"
+	tests := []struct {
+		name    string
+		err     error
+		wantOK  bool
+		wantFix string
+	}{
+		{"reachable", nil, true, ""},
+		{"missing", &ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "open failed: No such file or directory"}, false, "enable --now podman.socket"},
+		{"permissions", &ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "open failed: Permission denied"}, false, "cannot open"},
+		{"forwarding disabled", &ssh.OpenChannelError{Reason: ssh.Prohibited, Message: "administratively prohibited"}, false, "AllowStreamLocalForwarding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := socketCheck(path, tt.err)
+			if got.OK != tt.wantOK || !strings.Contains(got.Fix, tt.wantFix) {
+				t.Errorf("socketCheck() = %+v, want ok %v and fix containing %q", got, tt.wantOK, tt.wantFix)
+			}
+		})
+	}
+}
+
+func TestSSHAuthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantFix string
+	}{
+		{"changed host key", &knownhosts.KeyError{Want: []knownhosts.KnownKey{{Filename: "known_hosts"}}}, "ssh-keygen -R"},
+		{"unknown host key", &knownhosts.KeyError{}, "ssh-keyscan"},
+		{"no key accepted", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey]"), "IdentityFile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sshAuthCheck("edge1:22", tt.err)
+			if got.OK || !strings.Contains(got.Fix, tt.wantFix) {
+				t.Errorf("sshAuthCheck() = %+v, want fix containing %q", got, tt.wantFix)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"4.9.3", "3.0.0", 1},
+		{"3.0.0", "3.0.0", 0},
+		{"3.0", "3.0.0", 0},
+		{"2.2.1", "3.0.0", -1},
+		{"5.1.0-dev", "5.1.0", 0},
+		{"10.0.0", "9.9.9", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWriteChecks(t *testing.T) {
+	checks := []doctorCheck{
+		{Name: "SSH reachable", OK: true, Detail: "edge1:22"},
+		{Name: "Podman socket", Detail: "/run/podman/podman.sock not found", Fix: "Enable the socket."},
+	}
+
+	var text strings.Builder
+	if err := writeChecks(&text, formatText, checks); err != nil {
+		t.Fatalf("writeChecks(text) unexpected error = %v", err)
+	}
+	want := "ok    SSH reachable: edge1:22\nFAIL  Podman socket: /run/podman/podman.sock not found\n      Enable the socket.\n"
+	if text.String() != want {
+		t.Errorf("writeChecks(text) = %q, want %q", text.String(), want)
+	}
+
+	var stream strings.Builder
+	if err := writeChecks(&stream, formatJSONStream, checks); err != nil {
+		t.Fatalf("writeChecks(json-stream) unexpected error = %v", err)
+	}
+	if want := `{"name":"SSH reachable","ok":true,"detail":"edge1:22"}`; !strings.HasPrefix(stream.String(), want+"\n") {
+		t.Errorf("writeChecks(json-stream) = %q, want it to start with %s", stream.String(), want)
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// driftEntry is a difference between the desired state of a resource and
+// the remote host.
+type driftEntry struct {
+	resource string // container, image, network or volume
+	name     string
+	drift    string
+}
+
+// newDiffStateCommand returns the "diff_state" command, which compares the
+// containers and images declared in a file, in the format of deploy, with
+// those of the remote host and lists the drift, without changing anything.
+func newDiffStateCommand(fs *flag.FlagSet) runFunc {
+	var file string
+	fs.StringVar(&file, "f", "deployment.yaml", "Desired state file, in the format of deploy")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) > 0 {
+			slog.Error("diff_state: usage: diff_state [-f <file>]")
+			return 1
+		}
+		d, err := loadDeployFile(file)
+		if err != nil {
+			slog.Error("diff_state", "err", err)
+			return 1
+		}
+		// Variables are those of the host as named on the command line
+		var p *composeProject
+		if d.containers {
+			if p, err = d.project(rc.host); err != nil {
+				slog.Error("diff_state", "err", fmt.Errorf("%s: %w", file, err))
+				return 1
+			}
+		}
+		images, err := d.images(rc.host)
+		if err != nil {
+			slog.Error("diff_state", "err", fmt.Errorf("%s: %w", file, err))
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		drift, err := stateDrift(ctx, httpClient, p, images)
+		if err != nil {
+			slog.Error("diff_state", "err", err)
+			return 1
+		}
+		rows := make([]tuiRow, len(drift))
+		for i, e := range drift {
+			rows[i] = tuiRow{id: e.resource + "/" + e.name, cols: []string{e.resource, e.name, e.drift}}
+		}
+		if err := writeTable(os.Stdout, rc.opts.format, []string{"RESOURCE", "NAME", "DRIFT"}, rows); err != nil {
+			slog.Error("diff_state", "err", err)
+			return 1
+		}
+		if len(drift) > 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+// stateDrift returns the drift of the host from the project p, if not nil,
+// and images: missing networks, volumes, containers and images, containers
+// of the project that are not declared, and for declared containers, a
+// different image, environment variables not set as declared, or not
+// running.
+func stateDrift(ctx context.Context, httpClient *http.Client, p *composeProject, images []desiredImage) ([]driftEntry, error) {
+	var drift []driftEntry
+	if p != nil {
+		missing, err := projectResources(ctx, httpClient, p, false, io.Discard)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range missing {
+			kind, name, _ := strings.Cut(r, " ")
+			drift = append(drift, driftEntry{kind, name, "missing"})
+		}
+
+		declared := map[string]bool{}
+		for _, svc := range p.Services {
+			name := svc.Spec.Name
+			declared[name] = true
+			var ctr models.InspectContainerData
+			err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr)
+			if isStatus(err, http.StatusNotFound) {
+				drift = append(drift, driftEntry{"container", name, "missing"})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			diffs, err := containerDrift(ctx, httpClient, ctr, svc.Spec)
+			if err != nil {
+				return nil, fmt.Errorf("container %s: %w", name, err)
+			}
+			for _, d := range diffs {
+				drift = append(drift, driftEntry{"container", name, d})
+			}
+		}
+
+		filters, err := encodeFilters([]string{"label=" + composeProjectLabel + "=" + p.Name})
+		if err != nil {
+			return nil, err
+		}
+		names, err := filteredContainers(ctx, httpClient, filters)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !declared[name] {
+				drift = append(drift, driftEntry{"container", name, "extra: not declared"})
+			}
+		}
+	}
+
+	for _, img := range images {
+		check, err := checkImage(ctx, httpClient, img)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %w", img.Ref, err)
+		}
+		switch check.Status {
+		case imageMissing:
+			drift = append(drift, driftEntry{"image", img.Ref, "missing"})
+		case imageOutdated:
+			drift = append(drift, driftEntry{"image", img.Ref, fmt.Sprintf("digest %s, want %s", check.Digest, img.Digest)})
+		}
+	}
+	return drift, nil
+}
+
+// containerDrift returns how the inspected container ctr differs from
+// spec. Environment values are not shown, as they may be secrets. Only
+// the variables of spec are compared, as the container also has those of
+// its image, unless ctr was created by deploy: the variables it was
+// deployed with and no longer declared are then reported as well.
+func containerDrift(ctx context.Context, httpClient *http.Client, ctr models.InspectContainerData, spec containerSpec) ([]string, error) {
+	var drift []string
+	if d, err := imageDrift(ctx, httpClient, ctr, spec.Image); err != nil {
+		return nil, err
+	} else if d != "" {
+		drift = append(drift, d)
+	}
+
+	env := map[string]string{}
+	for _, kv := range ctr.Config.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		env[key] = value
+	}
+	for _, key := range sortedKeys(spec.Env) {
+		value, ok := env[key]
+		switch {
+		case !ok:
+			drift = append(drift, "env "+key+": not set")
+		case value != spec.Env[key]:
+			drift = append(drift, "env "+key+": different value")
+		}
+	}
+	if label, ok := ctr.Config.Labels[deploySpecLabel]; ok {
+		var deployed containerSpec
+		if err := json.Unmarshal([]byte(label), &deployed); err == nil {
+			for _, key := range sortedKeys(deployed.Env) {
+				if _, declared := spec.Env[key]; !declared {
+					if _, set := env[key]; set {
+						drift = append(drift, "env "+key+": set, not declared")
+					}
+				}
+			}
+		}
+	}
+
+	if !ctr.State.Running {
+		drift = append(drift, "not running ("+ctr.State.Status+")")
+	}
+	return drift, nil
+}
+
+// imageDrift describes how the image the container ctr runs differs from
+// ref, or returns "" if it does not: for a reference pinned to a digest,
+// the image must have that digest, and otherwise be the image ref names
+// on the host.
+func imageDrift(ctx context.Context, httpClient *http.Client, ctr models.InspectContainerData, ref string) (string, error) {
+	var running models.ImageData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(ctr.Image)+"/json", nil, &running); err != nil && !isStatus(err, http.StatusNotFound) {
+		return "", err
+	}
+
+	want, err := parseDesiredImage(ref)
+	if err != nil {
+		want = desiredImage{Ref: ref}
+	}
+	if want.Digest != "" {
+		if running.Digest == want.Digest || slices.ContainsFunc(running.RepoDigests, func(d string) bool {
+			return strings.HasSuffix(d, "@"+want.Digest)
+		}) {
+			return "", nil
+		}
+		return fmt.Sprintf("image digest %s, want %s", running.Digest, want.Digest), nil
+	}
+
+	var img models.ImageData
+	err = getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(want.Ref)+"/json", nil, &img)
+	if isStatus(err, http.StatusNotFound) {
+		return fmt.Sprintf("image %s, %s is not on the host", ctr.ImageName, want.Ref), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if img.ID == ctr.Image {
+		return "", nil
+	}
+	return fmt.Sprintf("image %s (%s), want %s (%s)", ctr.ImageName, shortID(running.Digest), want.Ref, shortID(img.Digest)), nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestStateDrift(t *testing.T) {
+	pin := "sha256:" + strings.Repeat("a", 64)
+	file := `name: shop
+vars:
+  tag: "1.27"
+containers:
+  web:
+    image: nginx:${tag}
+    environment:
+      REGION: us
+      TOKEN: secret
+  db:
+    image: postgres:16@` + pin + `
+  cache:
+    image: redis:7
+images:
+  - busybox:1.36
+  - alpine:3.20@` + pin + `
+  - nginx:${tag}
+`
+	d, err := parseDeployFile([]byte(file), "/srv/deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.project("edge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := d.images("edge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployed, _ := json.Marshal(containerSpec{Env: map[string]string{"REGION": "us", "DEBUG": "1"}})
+	containers := map[string]models.InspectContainerData{
+		"shop-web-1": {
+			Image: "sha-old", ImageName: "docker.io/library/nginx:1.26",
+			State: models.InspectContainerState{Status: "exited"},
+			Config: models.InspectContainerConfig{
+				Env:    []string{"PATH=/usr/bin", "REGION=eu", "DEBUG=1"},
+				Labels: map[string]string{deploySpecLabel: string(deployed)},
+			},
+		},
+		"shop-db-1": {Image: "sha-db", ImageName: "postgres:16", State: models.InspectContainerState{Status: "running", Running: true}},
+	}
+	// busybox:1.36 is missing, and alpine:3.20 has another digest than pinned
+	imagesOnHost := map[string]models.ImageData{
+		"sha-old":     {ID: "sha-old", Digest: "sha256:" + strings.Repeat("1", 64)},
+		"nginx:1.27":  {ID: "sha-new", Digest: "sha256:" + strings.Repeat("2", 64)},
+		"sha-db":      {ID: "sha-db", Digest: "sha256:" + strings.Repeat("3", 64), RepoDigests: []string{"docker.io/library/postgres@" + pin}},
+		"alpine:3.20": {ID: "sha-alpine", Digest: "sha256:" + strings.Repeat("4", 64)},
+	}
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("request %s %s, want no changes", r.Method, r.URL.Path)
+		}
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/exists"):
+			w.WriteHeader(http.StatusNoContent)
+		case path == "/v3.0.0/containers/json":
+			w.Write([]byte(`[{"Names":["/shop-web-1"]},{"Names":["/shop-db-1"]},{"Names":["/shop-old-1"]}]`))
+		case strings.HasPrefix(path, "/v3.0.0/libpod/containers/"):
+			ctr, ok := containers[strings.TrimSuffix(strings.TrimPrefix(path, "/v3.0.0/libpod/containers/"), "/json")]
+			if !ok {
+				http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(ctr)
+		case strings.HasPrefix(path, "/v3.0.0/libpod/images/"):
+			img, ok := imagesOnHost[strings.TrimSuffix(strings.TrimPrefix(path, "/v3.0.0/libpod/images/"), "/json")]
+			if !ok {
+				http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(img)
+		default:
+			t.Errorf("unexpected request %s", path)
+		}
+	}))
+
+	drift, err := stateDrift(context.Background(), httpClient, p, images)
+	if err != nil {
+		t.Fatalf("stateDrift() unexpected error = %v", err)
+	}
+	var got []string
+	for _, e := range drift {
+		got = append(got, e.resource+" "+e.name+": "+e.drift)
+	}
+	want := []string{
+		"container shop-cache-1: missing",
+		"container shop-web-1: image docker.io/library/nginx:1.26 (111111111111), want nginx:1.27 (222222222222)",
+		"container shop-web-1: env REGION: different value",
+		"container shop-web-1: env TOKEN: not set",
+		"container shop-web-1: env DEBUG: set, not declared",
+		"container shop-web-1: not running (exited)",
+		"container shop-old-1: extra: not declared",
+		"image busybox:1.36: missing",
+		"image alpine:3.20: digest sha256:" + strings.Repeat("4", 64) + ", want " + pin,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stateDrift() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// errDryRun is returned in place of a response for requests that -dry-run
+// printed instead of sending.
+var errDryRun = errors.New("dry run: request not sent")
+
+// maxDryRunBody is the number of body bytes printed for a dry-run request.
+const maxDryRunBody = 4096
+
+// dryRunTransport is an http.RoundTripper that prints requests instead of
+// sending them.
+type dryRunTransport struct {
+	out        io.Writer
+	socketPath string
+
+	mu   sync.Mutex
+	sent bool
+}
+
+// RoundTrip prints req and fails with errDryRun. The request line and body
+// are followed by an equivalent curl command to run on the remote host.
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		defer req.Body.Close()
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(req.Body, maxDryRunBody+1)); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = true
+
+	fmt.Fprintf(t.out, "%s %s\n", req.Method, req.URL.RequestURI())
+	for _, name := range []string{"Content-Type", "X-Registry-Auth"} {
+		if v := req.Header.Get(name); v != "" {
+			if name == "X-Registry-Auth" {
+				v = "<redacted>"
+			}
+			fmt.Fprintf(t.out, "%s: %s\n", name, v)
+		}
+	}
+
+	curl := fmt.Sprintf("curl --unix-socket %s -X %s %s", t.socketPath, req.Method, shellQuote("http://d"+req.URL.RequestURI()))
+	switch {
+	case len(body) == 0:
+	case len(body) > maxDryRunBody || !utf8.Valid(body):
+		fmt.Fprintf(t.out, "\n<binary or large body not shown>\n")
+		curl += " --data-binary @BODY"
+	default:
+		fmt.Fprintf(t.out, "\n%s\n", strings.TrimRight(string(body), "\n"))
+		curl += " --data-binary " + shellQuote(string(body))
+	}
+	fmt.Fprintf(t.out, "# %s\n", curl)
+	return nil, errDryRun
+}
+
+// dryRunSent reports whether a request was printed in -dry-run mode.
+func (rc *RemoteCLI) dryRunSent() bool {
+	if rc.dryRun == nil {
+		return false
+	}
+	rc.dryRun.mu.Lock()
+	defer rc.dryRun.mu.Unlock()
+	return rc.dryRun.sent
+}
+
+// shellQuote quotes s for use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dryRunConn stands in for the SSH connection in -dry-run mode, so that
+// commands can run unchanged without contacting the remote host.
+type dryRunConn struct {
+	ssh.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+// newDryRunClient returns an SSH client that is not connected to anything.
+func newDryRunClient() *ssh.Client {
+	chans := make(chan ssh.NewChannel)
+	reqs := make(chan *ssh.Request)
+	close(chans)
+	close(reqs)
+	return ssh.NewClient(&dryRunConn{done: make(chan struct{})}, chans, reqs)
+}
+
+func (c *dryRunConn) SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error) {
+	return false, nil, errDryRun
+}
+
+func (c *dryRunConn) OpenChannel(name string, data []byte) (ssh.Channel, <-chan *ssh.Request, error) {
+	return nil, nil, errDryRun
+}
+
+func (c *dryRunConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *dryRunConn) Wait() error {
+	<-c.done
+	return nil
+}
+
+// dryRunHandler is a slog.Handler that drops records caused by errDryRun,
+// as the request was printed instead of failing.
+type dryRunHandler struct {
+	slog.Handler
+}
+
+func (h dryRunHandler) Handle(ctx context.Context, r slog.Record) error {
+	dropped := false
+	r.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && errors.Is(err, errDryRun) {
+			dropped = true
+			return false
+		}
+		return true
+	})
+	if dropped {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h dryRunHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return dryRunHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h dryRunHandler) WithGroup(name string) slog.Handler {
+	return dryRunHandler{h.Handler.WithGroup(name)}
+}
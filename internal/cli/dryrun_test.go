@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunTransport(t *testing.T) {
+	var out strings.Builder
+	transport := &dryRunTransport{out: &out, socketPath: "/run/podman/podman.sock"}
+	httpClient := &http.Client{Transport: transport}
+
+	req, err := newAPIRequest(t.Context(), http.MethodPost, "/v3.0.0/libpod/containers/web/update", url.Values{"restartPolicy": {"always"}}, strings.NewReader(`{"memory":{"limit":1024}}`))
+	if err != nil {
+		t.Fatalf("newAPIRequest() unexpected error = %v", err)
+	}
+	req.Header.Set("X-Registry-Auth", "c2VjcmV0")
+
+	if _, err := httpClient.Do(req); !errors.Is(err, errDryRun) {
+		t.Fatalf("Do() error = %v, want errDryRun", err)
+	}
+
+	want := `POST /v3.0.0/libpod/containers/web/update?restartPolicy=always
+X-Registry-Auth: <redacted>
+
+{"memory":{"limit":1024}}
+# curl --unix-socket /run/podman/podman.sock -X POST 'http://d/v3.0.0/libpod/containers/web/update?restartPolicy=always' --data-binary '{"memory":{"limit":1024}}'
+`
+	if out.String() != want {
+		t.Errorf("dry run output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	// test.example.com is never dialed in dry-run mode
+	cli, err := NewRemoteCLI([]string{"-host", "testhost", "-dry-run", "list_containers"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	var out strings.Builder
+	cli.dryRun.out = &out
+	if code := cli.Run(); code != 0 {
+		t.Errorf("Run() exit code = %d, want 0", code)
+	}
+	if !strings.HasPrefix(out.String(), "GET /v3.0.0/containers/json\n") {
+		t.Errorf("Run() dry run output = %q, want the list request", out.String())
+	}
+}
+
+func TestRun_DryRunWithoutIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	config := filepath.Join(tmpDir, "ssh_config")
+	data := "Host edge\n  HostName 192.0.2.10\n  User deploy\n  IdentityFile " + filepath.Join(tmpDir, "missing_key") + "\n"
+	if err := os.WriteFile(config, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRemoteCLI([]string{"-ssh-config", config, "-host", "edge", "list_containers"}); err == nil {
+		t.Fatal("NewRemoteCLI() without an identity error = nil, want an error")
+	}
+
+	cli, err := NewRemoteCLI([]string{"-ssh-config", config, "-host", "edge", "-dry-run", "list_containers"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() -dry-run without an identity unexpected error = %v", err)
+	}
+	if cli.addr != "192.0.2.10:22" || cli.sshClientConfig.User != "deploy" {
+		t.Errorf("NewRemoteCLI() resolved %s@%s, want deploy@192.0.2.10:22", cli.sshClientConfig.User, cli.addr)
+	}
+	var out strings.Builder
+	cli.dryRun.out = &out
+	if code := cli.Run(); code != 0 {
+		t.Errorf("Run() exit code = %d, want 0", code)
+	}
+	if !strings.HasPrefix(out.String(), "GET /v3.0.0/containers/json\n") {
+		t.Errorf("Run() dry run output = %q, want the list request", out.String())
+	}
+}
+
+func TestNewRemoteCLI_DryRunUnsupported(t *testing.T) {
+	if _, err := NewRemoteCLI([]string{"-dry-run", "shell"}); err == nil {
+		t.Error("NewRemoteCLI() expected error for shell with -dry-run, got nil")
+	}
+}
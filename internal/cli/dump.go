@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Files written next to the responses of a run by -output-dir.
+const (
+	dumpIndexFile = "index.jsonl"
+	dumpSumsFile  = "SHA256SUMS"
+)
+
+// dumpEntry is a line of the index of a -output-dir run, describing an
+// API response and the file holding its body.
+type dumpEntry struct {
+	File   string      `json:"file,omitempty"`
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Time   time.Time   `json:"time"`
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256,omitempty"`
+}
+
+// responseDump saves the API responses of a command for -output-dir, each
+// in a file of the directory <dir>/<host>/<command>/<start time>. The
+// directory also holds an index of the responses, and their checksums in
+// the format of sha256sum, so that they can be verified later.
+type responseDump struct {
+	dir     string
+	command string
+	started time.Time
+	seq     atomic.Int64
+
+	mu      sync.Mutex // serializes writes to the index and checksums
+	errOnce sync.Once
+}
+
+// newResponseDump returns a dump of the responses of command into dir,
+// which is created if missing.
+func newResponseDump(dir, command string) (*responseDump, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("-output-dir: %w", err)
+	}
+	return &responseDump{dir: dir, command: command, started: time.Now().UTC()}, nil
+}
+
+// transport returns rt saving the responses from host.
+func (d *responseDump) transport(rt http.RoundTripper, host string) http.RoundTripper {
+	return &dumpTransport{rt: rt, dump: d, dir: filepath.Join(d.dir, dumpName(host), d.command, d.started.Format("20060102T150405.000Z"))}
+}
+
+// fail reports the first error met saving responses, which does not fail
+// the command.
+func (d *responseDump) fail(err error) {
+	d.errOnce.Do(func() {
+		slog.Error("Failed to save responses to -output-dir", "err", err)
+	})
+}
+
+// add appends e to the index and checksums of dir.
+func (d *responseDump) add(dir string, e dumpEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := appendFile(filepath.Join(dir, dumpIndexFile), append(line, '\n')); err != nil {
+		d.fail(err)
+		return
+	}
+	if e.File != "" {
+		if err := appendFile(filepath.Join(dir, dumpSumsFile), []byte(e.SHA256+"  "+e.File+"\n")); err != nil {
+			d.fail(err)
+		}
+	}
+}
+
+// appendFile appends data to the file at path, creating it if missing.
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// dumpTransport is an http.RoundTripper saving the responses of one host
+// into dir as the command reads them, so that streamed responses, such as
+// image archives or logs, are saved whole without being held in memory.
+type dumpTransport struct {
+	rt   http.RoundTripper
+	dump *responseDump
+	dir  string
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	n := t.dump.seq.Add(1)
+	e := dumpEntry{Method: req.Method, URI: req.URL.RequestURI(), Status: resp.StatusCode, Header: resp.Header.Clone(), Time: time.Now().UTC()}
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		t.dump.fail(err)
+		return resp, nil
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The connection is handed over to an interactive session, such
+		// as exec, whose stream is not saved
+		t.dump.add(t.dir, e)
+		return resp, nil
+	}
+
+	e.File = fmt.Sprintf("%03d-%s-%s%s", n, req.Method, dumpName(strings.TrimPrefix(req.URL.Path, "/v3.0.0/")), dumpExtension(resp.Header.Get("Content-Type")))
+	f, err := os.OpenFile(filepath.Join(t.dir, e.File), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		t.dump.fail(err)
+		return resp, nil
+	}
+	resp.Body = &dumpBody{ReadCloser: resp.Body, t: t, f: f, hash: sha256.New(), entry: e}
+	return resp, nil
+}
+
+// dumpBody is a response body copying what is read from it to a file,
+// and adding the file to the index once closed.
+type dumpBody struct {
+	io.ReadCloser
+	t     *dumpTransport
+	f     *os.File
+	hash  hash.Hash
+	entry dumpEntry
+	err   error
+	once  sync.Once
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.err == nil {
+		b.hash.Write(p[:n])
+		b.entry.Size += int64(n)
+		_, b.err = b.f.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	b.once.Do(func() {
+		if err := b.f.Close(); b.err == nil {
+			b.err = err
+		}
+		if b.err != nil {
+			b.t.dump.fail(b.err)
+			return
+		}
+		b.entry.SHA256 = hex.EncodeToString(b.hash.Sum(nil))
+		b.t.dump.add(b.t.dir, b.entry)
+	})
+	return b.ReadCloser.Close()
+}
+
+// dumpName returns s with the characters that are not safe in file names
+// replaced by dashes.
+func dumpName(s string) string {
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, s), "-.")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+// dumpExtension returns the file name extension of content of the given
+// media type.
+func dumpExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json":
+		return ".json"
+	case mediaType == "application/x-tar":
+		return ".tar"
+	case strings.HasPrefix(mediaType, "text/"):
+		return ".txt"
+	}
+	return ".bin"
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseDump(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/images/alpine/get":
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write([]byte{0x00, 0xff, 0xfe})
+		case "/v3.0.0/libpod/containers/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"Id":"abc"}]`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such container"}`))
+		}
+	}))
+
+	dir := t.TempDir()
+	dump, err := newResponseDump(dir, "report")
+	if err != nil {
+		t.Fatalf("newResponseDump() unexpected error = %v", err)
+	}
+	dumping := &http.Client{Transport: dump.transport(httpClient.Transport, "edge1:2222")}
+	for _, uri := range []string{"/v3.0.0/libpod/containers/json?all=true", "/v3.0.0/libpod/images/alpine/get", "/v3.0.0/libpod/containers/db/json"} {
+		resp, err := dumping.Get("http://localhost" + uri)
+		if err != nil {
+			t.Fatalf("GET %s: %v", uri, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	runs, _ := filepath.Glob(filepath.Join(dir, "edge1-2222", "report", "*"))
+	if len(runs) != 1 {
+		t.Fatalf("run directories = %v, want one under edge1-2222/report", runs)
+	}
+	run := runs[0]
+	want := map[string]string{
+		"001-GET-libpod-containers-json.json":    `[{"Id":"abc"}]`,
+		"002-GET-libpod-images-alpine-get.tar":   "\x00\xff\xfe",
+		"003-GET-libpod-containers-db-json.json": `{"message":"no such container"}`,
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(run, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(run, dumpIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []dumpEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e dumpEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("index line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 || entries[0].URI != "/v3.0.0/libpod/containers/json?all=true" || entries[2].Status != http.StatusNotFound {
+		t.Errorf("index = %+v, want the 3 responses in order", entries)
+	}
+
+	// The checksums can be verified with sha256sum -c
+	sums, _ := os.ReadFile(filepath.Join(run, dumpSumsFile))
+	lines := strings.Split(strings.TrimSpace(string(sums)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("%s = %q, want 3 lines", dumpSumsFile, sums)
+	}
+	for _, line := range lines {
+		sum, name, ok := strings.Cut(line, "  ")
+		data, _ := os.ReadFile(filepath.Join(run, name))
+		if got := sha256.Sum256(data); !ok || hex.EncodeToString(got[:]) != sum {
+			t.Errorf("checksum line %q does not match %s", line, name)
+		}
+	}
+}
+
+func TestDumpName(t *testing.T) {
+	tests := map[string]string{
+		"edge1":                      "edge1",
+		"user@host:22":               "user-host-22",
+		"libpod/containers/web/json": "libpod-containers-web-json",
+		"../etc/passwd":              "etc-passwd",
+		"":                           "-",
+		strings.Repeat("a", 100):     strings.Repeat("a", 80),
+	}
+	for in, want := range tests {
+		if got := dumpName(in); got != want {
+			t.Errorf("dumpName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
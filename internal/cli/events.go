@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxReconnects is the number of consecutive failed attempts to re-establish
+// a stream before giving up.
+const maxReconnects = 5
+
+// reconnectDelay is the delay before re-establishing a stream after its
+// first failure, multiplied by the number of consecutive failures.
+var reconnectDelay = time.Second
+
+// newEventsCommand returns the "events" command, which streams Podman events
+// from the remote host. If the SSH connection drops, the stream is resumed
+// from the last received event.
+func newEventsCommand(fs *flag.FlagSet) runFunc {
+	var since string
+	fs.StringVar(&since, "since", "", "Show events created since this timestamp")
+
+	return func(rc *RemoteCLI) int {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		redialer := client.NewRedialer(func() (*ssh.Client, error) {
+			return rc.dialSSH(ctx)
+		}, rc.keepAlive)
+		defer redialer.Close()
+
+		httpClient := rc.apiClient(func() (net.Conn, error) {
+			return redialer.Dial("unix", rc.socketPath)
+		})
+
+		events := rc.eventStream(os.Stdout, "events")
+		var lastNano int64
+		err := resumeStream(ctx, "events", func() (int, error) {
+			if lastNano > 0 {
+				since = strconv.FormatInt(lastNano/int64(time.Second), 10)
+			}
+			return streamEvents(ctx, httpClient, since, &lastNano, events)
+		})
+		if err != nil {
+			if events != nil {
+				events.emit(streamEvent{Type: eventError, Message: err.Error()})
+			}
+			slog.Error("events", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// resumeStream calls stream, which returns the number of items it wrote,
+// until it ends cleanly or ctx is done. When stream fails with a transient
+// error, such as a dropped connection, it is called again after a delay
+// growing with each consecutive failure, and is expected to resume where
+// it stopped. Other errors are returned at once, as is the last error once
+// maxReconnects consecutive attempts wrote nothing.
+func resumeStream(ctx context.Context, name string, stream func() (int, error)) error {
+	failures := 0
+	for {
+		n, err := stream()
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if !client.IsTransient(err) {
+			return err
+		}
+
+		if n > 0 {
+			failures = 0
+		}
+		failures++
+		if failures > maxReconnects {
+			return err
+		}
+		slog.Warn(name+": stream interrupted, reconnecting", "err", err)
+
+		timer := time.NewTimer(time.Duration(failures) * reconnectDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// streamEvents requests the event stream and copies each event to stdout,
+// or wraps it in an event on events when that is not nil, skipping events
+// at or before *lastNano so that resumed streams do not repeat output. It
+// returns the number of events written.
+func streamEvents(ctx context.Context, httpClient *http.Client, since string, lastNano *int64, events *eventStream) (int, error) {
+
+	query := url.Values{"stream": {"true"}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: "/v3.0.0/libpod/events", RawQuery: query.Encode()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	written := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		var event struct {
+			TimeNano int64 `json:"timeNano"`
+		}
+		if err := json.Unmarshal(line, &event); err == nil && event.TimeNano != 0 {
+			if event.TimeNano <= *lastNano {
+				continue
+			}
+			*lastNano = event.TimeNano
+		}
+
+		if events != nil {
+			ev := streamEvent{Type: eventPodman}
+			if event.TimeNano != 0 {
+				ev.Time = time.Unix(0, event.TimeNano)
+			}
+			if json.Valid(line) {
+				ev.Data = json.RawMessage(line)
+			} else {
+				ev.Message = string(line)
+			}
+			events.emit(ev)
+		} else {
+			fmt.Println(string(line))
+		}
+		written++
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		return written, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return written, nil
+	}
+	return written, err
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+)
+
+func TestStreamEvents_SkipsSeenEvents(t *testing.T) {
+	var gotSince string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSince = r.URL.Query().Get("since")
+		for _, nano := range []int64{100, 200, 300} {
+			fmt.Fprintf(w, "{\"Type\":\"container\",\"timeNano\":%d}\n", nano)
+		}
+	}))
+	defer server.Close()
+
+	httpClient := client.NewHTTPClient(func() (net.Conn, error) {
+		return net.Dial("tcp", server.Listener.Addr().String())
+	})
+
+	lastNano := int64(200)
+	n, err := streamEvents(context.Background(), httpClient, "1700000000", &lastNano, nil)
+	if err != nil {
+		t.Fatalf("streamEvents() unexpected error = %v", err)
+	}
+
+	if gotSince != "1700000000" {
+		t.Errorf("streamEvents() since = %q, want %q", gotSince, "1700000000")
+	}
+	if n != 1 {
+		t.Errorf("streamEvents() wrote %d events, want 1", n)
+	}
+	if lastNano != 300 {
+		t.Errorf("streamEvents() lastNano = %d, want 300", lastNano)
+	}
+}
+
+func TestStreamEvents_EventStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Type":"container","Action":"start","timeNano":1700000000000000000}`)
+	}))
+	defer server.Close()
+
+	httpClient := client.NewHTTPClient(func() (net.Conn, error) {
+		return net.Dial("tcp", server.Listener.Addr().String())
+	})
+
+	var out bytes.Buffer
+	var lastNano int64
+	if _, err := streamEvents(context.Background(), httpClient, "", &lastNano, newEventStream(&out, "edge1", "events")); err != nil {
+		t.Fatalf("streamEvents() unexpected error = %v", err)
+	}
+
+	var ev struct {
+		Time string `json:"time"`
+		Type string `json:"type"`
+		Data struct {
+			Action string `json:"Action"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &ev); err != nil {
+		t.Fatalf("streamEvents() wrote invalid JSON %q: %v", out.String(), err)
+	}
+	if ev.Type != eventPodman || ev.Data.Action != "start" || ev.Time != "2023-11-14T22:13:20Z" {
+		t.Errorf("streamEvents() event = %+v, want the Podman event at its own time", ev)
+	}
+}
+
+func TestStreamEvents_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	httpClient := client.NewHTTPClient(func() (net.Conn, error) {
+		return net.Dial("tcp", server.Listener.Addr().String())
+	})
+
+	var lastNano int64
+	if _, err := streamEvents(context.Background(), httpClient, "", &lastNano, nil); err == nil {
+		t.Error("streamEvents() expected error for HTTP 500, got nil")
+	}
+}
+
+func TestResumeStream(t *testing.T) {
+	reconnectDelay = time.Millisecond
+	t.Cleanup(func() { reconnectDelay = time.Second })
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"clean end", []error{nil}, 1, false},
+		{"resumed after a dropped connection", []error{io.ErrUnexpectedEOF, syscall.ECONNRESET, nil}, 3, false},
+		{"API error", []error{errors.New("unexpected status: 404 Not Found")}, 1, true},
+		{"connection lost for good", []error{io.EOF, io.EOF, io.EOF, io.EOF, io.EOF, io.EOF}, maxReconnects + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := resumeStream(context.Background(), "test", func() (int, error) {
+				calls++
+				return 0, tt.errs[calls-1]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resumeStream() error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("resumeStream() called the stream %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResumeStream_CanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- resumeStream(ctx, "test", func() (int, error) { return 0, io.ErrUnexpectedEOF })
+	}()
+
+	// The first backoff lasts reconnectDelay, a second
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("resumeStream() error = %v, want nil once canceled", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("resumeStream() did not return when canceled during the backoff")
+	}
+}
+
+func TestEventsCommand_Recorded(t *testing.T) {
+	sshConfig := startMockHostHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Type":"container","Action":"start","timeNano":100}`)
+	}))
+	recording := filepath.Join(t.TempDir(), "session.jsonl")
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "-record", recording, "events"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if code := cli.Run(); code != 0 {
+		t.Fatalf("Run() = %d, want 0", code)
+	}
+
+	// The stream goes through the same HTTP client as other commands
+	data, err := os.ReadFile(recording)
+	if err != nil {
+		t.Fatalf("ReadFile() unexpected error = %v", err)
+	}
+	if !strings.Contains(string(data), `"uri":"/v3.0.0/libpod/events?stream=true"`) {
+		t.Errorf("recording = %s, want the events request", data)
+	}
+}
@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// execConfig is the body of a libpod exec create request.
+type execConfig struct {
+	Cmd          []string `json:"Cmd"`
+	Env          []string `json:"Env,omitempty"`
+	User         string   `json:"User,omitempty"`
+	WorkingDir   string   `json:"WorkingDir,omitempty"`
+	Tty          bool     `json:"Tty"`
+	AttachStdin  bool     `json:"AttachStdin"`
+	AttachStdout bool     `json:"AttachStdout"`
+	AttachStderr bool     `json:"AttachStderr"`
+}
+
+// newExecCommand returns the "exec" command, which runs a command in a
+// running container and exits with its exit code. With -t the command
+// gets a TTY: the local terminal is put into raw mode for the session and
+// its size is kept in sync with the remote one, so that full-screen
+// programs such as vim and htop work.
+//
+// With -detach the command is started in the background and the ID of
+// its exec session printed, to be followed with exec_inspect. Its output
+// is discarded unless -output names a file in the container to append it
+// to.
+func newExecCommand(fs *flag.FlagSet) runFunc {
+	var interactive, tty, detach bool
+	var user, workdir, output string
+	var env stringsFlag
+	fs.BoolVar(&interactive, "interactive", false, "Send stdin to the command")
+	fs.BoolVar(&interactive, "i", false, "Shorthand for -interactive")
+	fs.BoolVar(&tty, "tty", false, "Run the command with a TTY, putting the local terminal into raw mode")
+	fs.BoolVar(&tty, "t", false, "Shorthand for -tty")
+	fs.Var(&env, "e", "Set an environment variable as KEY=VALUE (repeatable)")
+	fs.StringVar(&user, "u", "", "Run the command as this user[:group]")
+	fs.StringVar(&workdir, "w", "", "Working directory of the command")
+	fs.BoolVar(&detach, "detach", false, "Run the command in the background and print its exec session ID")
+	fs.BoolVar(&detach, "d", false, "Shorthand for -detach")
+	fs.StringVar(&output, "output", "", "With -detach, append the output of the command to this file in the container")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) < 2 {
+			slog.Error("exec: usage: exec [flags] <container> <command> [<arg>...]")
+			return 1
+		}
+		if detach && (interactive || tty) {
+			slog.Error("exec: -detach cannot be combined with -interactive or -tty")
+			return 1
+		}
+		if output != "" && !detach {
+			slog.Error("exec: -output requires -detach")
+			return 1
+		}
+		name := rc.args[0]
+		config := execConfig{
+			Cmd:          rc.args[1:],
+			Env:          env,
+			User:         user,
+			WorkingDir:   workdir,
+			Tty:          tty,
+			AttachStdin:  interactive,
+			AttachStdout: true,
+			AttachStderr: true,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if detach {
+			id, err := startDetachedExec(ctx, httpClient, name, detachedExecConfig(config, output))
+			if err != nil {
+				slog.Error("exec", "target", name, "err", err)
+				return 1
+			}
+			fmt.Println(id)
+			return 0
+		}
+
+		terminal, err := openTerminal(tty)
+		if err != nil {
+			slog.Error("exec", "err", err)
+			return 1
+		}
+		defer terminal.restore()
+
+		var stdin io.Reader
+		if interactive {
+			stdin = os.Stdin
+		}
+		code, err := runExec(ctx, httpClient, name, config, terminal, stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			terminal.restore()
+			slog.Error("exec", "target", name, "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// runExec runs the command described by config in the named container,
+// streaming stdin to it and its output to stdout and stderr, and returns
+// its exit code. The size of terminal, if not nil, is forwarded to the
+// TTY of the command.
+func runExec(ctx context.Context, httpClient *http.Client, name string, config execConfig, terminal *localTerminal, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	id, err := createExec(ctx, httpClient, name, config)
+	if err != nil {
+		return 0, err
+	}
+
+	start := map[string]any{"Detach": false, "Tty": config.Tty}
+	output, w, err := hijack(ctx, httpClient, "/v3.0.0/libpod/exec/"+url.PathEscape(id)+"/start", nil, start)
+	if err != nil {
+		return 0, err
+	}
+	stopResize := terminal.forwardResize(ctx, func(ctx context.Context, width, height int) error {
+		return resizeTTY(ctx, httpClient, "/v3.0.0/libpod/exec/"+url.PathEscape(id)+"/resize", width, height)
+	})
+	err = streamSession(ctx, output, w, stdin, config.Tty, stdout, stderr)
+	stopResize()
+	if err != nil {
+		return 0, err
+	}
+	return execExitCode(ctx, httpClient, id)
+}
+
+// detachedExecConfig returns config for a command run in the background,
+// with no stream attached. Its output is appended to the file at output in
+// the container, if not empty, by running it through /bin/sh.
+func detachedExecConfig(config execConfig, output string) execConfig {
+	config.AttachStdin, config.AttachStdout, config.AttachStderr = false, false, false
+	if output != "" {
+		// The arguments are passed to the shell as is, not parsed by it
+		redirect := `exec "$@" >>` + shellQuote(output) + ` 2>&1`
+		config.Cmd = append([]string{"/bin/sh", "-c", redirect, "sh"}, config.Cmd...)
+	}
+	return config
+}
+
+// startDetachedExec starts the command described by config in the named
+// container without waiting for it, and returns the ID of its exec
+// session.
+func startDetachedExec(ctx context.Context, httpClient *http.Client, name string, config execConfig) (string, error) {
+	id, err := createExec(ctx, httpClient, name, config)
+	if err != nil {
+		return "", err
+	}
+	if err := postJSON(ctx, httpClient, "/v3.0.0/libpod/exec/"+url.PathEscape(id)+"/start", map[string]any{"Detach": true}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// createExec creates an exec session in the named container and returns
+// its ID.
+func createExec(ctx context.Context, httpClient *http.Client, name string, config execConfig) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := postJSONResult(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/exec", config, &created); err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("podman returned no exec session ID")
+	}
+	return created.ID, nil
+}
+
+// execSession holds the fields of an exec session inspection.
+type execSession struct {
+	ID            string `json:"ID"`
+	ContainerID   string `json:"ContainerID"`
+	Running       bool   `json:"Running"`
+	ExitCode      int    `json:"ExitCode"`
+	Pid           int    `json:"Pid"`
+	ProcessConfig struct {
+		Entrypoint string   `json:"entrypoint"`
+		Arguments  []string `json:"arguments"`
+	} `json:"ProcessConfig"`
+}
+
+// inspectExec returns the state of the exec session id.
+func inspectExec(ctx context.Context, httpClient *http.Client, id string) (execSession, error) {
+	var session execSession
+	err := getJSON(ctx, httpClient, "/v3.0.0/libpod/exec/"+url.PathEscape(id)+"/json", nil, &session)
+	return session, err
+}
+
+// execExitCode returns the exit code of the exec session id once it has
+// ended, waiting briefly for Podman to record it after the output
+// stream closed.
+func execExitCode(ctx context.Context, httpClient *http.Client, id string) (int, error) {
+	for attempt := 0; ; attempt++ {
+		session, err := inspectExec(ctx, httpClient, id)
+		if err != nil {
+			return 0, err
+		}
+		if !session.Running || attempt == 20 {
+			return session.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// newExecInspectCommand returns the "exec_inspect" command, which shows
+// whether the exec sessions given by ID, such as those started with exec
+// -detach, are still running, and the exit code of those that ended.
+// Podman forgets sessions some time after they end.
+func newExecInspectCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("exec_inspect: at least one exec session ID is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		code := 0
+		var rows []tuiRow
+		for _, id := range rc.args {
+			session, err := inspectExec(ctx, httpClient, id)
+			if err != nil {
+				slog.Error("exec_inspect", "target", id, "err", err)
+				code = 1
+				continue
+			}
+			rows = append(rows, execSessionRow(session))
+		}
+		if err := writeTable(os.Stdout, rc.opts.format, execSessionHeaders, rows); err != nil {
+			slog.Error("exec_inspect", "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// execSessionHeaders are the columns of the exec_inspect table.
+var execSessionHeaders = []string{"ID", "CONTAINER", "STATUS", "EXIT CODE", "PID", "COMMAND"}
+
+// execSessionRow returns the exec_inspect row of session. The exit code
+// is only shown once the session has ended.
+func execSessionRow(session execSession) tuiRow {
+	status, exitCode := "running", ""
+	if !session.Running {
+		status, exitCode = "exited", strconv.Itoa(session.ExitCode)
+	}
+	command := strings.Join(append([]string{session.ProcessConfig.Entrypoint}, session.ProcessConfig.Arguments...), " ")
+	return tuiRow{id: session.ID, cols: []string{
+		shortID(session.ID),
+		shortID(session.ContainerID),
+		status,
+		exitCode,
+		strconv.Itoa(session.Pid),
+		command,
+	}}
+}
+
+// postJSONResult sends v as JSON to path and decodes the response into
+// result.
+func postJSONResult(ctx context.Context, httpClient *http.Client, path string, v, result any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, path, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(result)
+}
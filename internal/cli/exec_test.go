@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// serveSession takes over the connection of an exec start or attach
+// request, upgrading it as Podman does, and hands it to session.
+func serveSession(t *testing.T, w http.ResponseWriter, r *http.Request, session func(conn net.Conn, in *bufio.Reader)) {
+	t.Helper()
+	if r.Header.Get("Upgrade") != "tcp" || r.Header.Get("Connection") != "Upgrade" {
+		t.Errorf("%s headers = %v, want an upgrade to tcp", r.URL.Path, r.Header)
+	}
+	io.Copy(io.Discard, r.Body)
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("Hijack() unexpected error = %v", err)
+		return
+	}
+	defer conn.Close()
+	io.WriteString(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.multiplexed-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	session(conn, buf.Reader)
+}
+
+func TestRunExec(t *testing.T) {
+	var created execConfig
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/db/exec":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		case "/v3.0.0/libpod/exec/abc/start":
+			serveSession(t, w, r, func(conn net.Conn, in *bufio.Reader) {
+				// Reply once stdin ends, as psql reading a script does
+				data, err := io.ReadAll(in)
+				if err != nil {
+					t.Errorf("reading stdin: %v", err)
+				}
+				conn.Write(frame(1, "got "+string(data)))
+				conn.Write(frame(2, "warning\n"))
+			})
+		case "/v3.0.0/libpod/exec/abc/json":
+			w.Write([]byte(`{"Running":false,"ExitCode":3}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	config := execConfig{Cmd: []string{"psql"}, Env: []string{"PGUSER=app"}, AttachStdin: true, AttachStdout: true, AttachStderr: true}
+	var stdout, stderr bytes.Buffer
+	code, err := runExec(context.Background(), httpClient, "db", config, nil, strings.NewReader("select 1;\nselect 2;\n"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("runExec() unexpected error = %v", err)
+	}
+	if code != 3 {
+		t.Errorf("runExec() = %d, want the exit code 3", code)
+	}
+	if stdout.String() != "got select 1;\nselect 2;\n" || stderr.String() != "warning\n" {
+		t.Errorf("runExec() stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+	if created.Cmd[0] != "psql" || created.Env[0] != "PGUSER=app" || !created.AttachStdin || created.Tty {
+		t.Errorf("exec created with %+v", created)
+	}
+}
+
+func TestRunExec_TTY(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/web/exec":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		case "/v3.0.0/libpod/exec/abc/start":
+			var start struct{ Tty bool }
+			json.NewDecoder(r.Body).Decode(&start)
+			if !start.Tty {
+				t.Error("exec started without Tty")
+			}
+			serveSession(t, w, r, func(conn net.Conn, in *bufio.Reader) {
+				// TTY output is not multiplexed
+				io.WriteString(conn, "\x1b[2J$ ")
+			})
+		case "/v3.0.0/libpod/exec/abc/json":
+			w.Write([]byte(`{"Running":false,"ExitCode":0}`))
+		}
+	}))
+
+	var stdout bytes.Buffer
+	code, err := runExec(context.Background(), httpClient, "web", execConfig{Cmd: []string{"sh"}, Tty: true}, nil, nil, &stdout, io.Discard)
+	if err != nil || code != 0 {
+		t.Fatalf("runExec() = %d, %v, want 0", code, err)
+	}
+	if stdout.String() != "\x1b[2J$ " {
+		t.Errorf("runExec() stdout = %q, want the raw TTY output", stdout.String())
+	}
+}
+
+func TestRunExec_NotFound(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no container with name or ID \"db\" found"}`))
+	}))
+
+	if _, err := runExec(context.Background(), httpClient, "db", execConfig{Cmd: []string{"true"}}, nil, nil, io.Discard, io.Discard); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("runExec() error = %v, want a 404 error", err)
+	}
+}
+
+func TestLocalTerminal_Nil(t *testing.T) {
+	// Without a terminal, the session is not resized
+	var terminal *localTerminal
+	stop := terminal.forwardResize(context.Background(), func(context.Context, int, int) error {
+		t.Error("resize called without a terminal")
+		return nil
+	})
+	stop()
+	terminal.restore()
+
+	if terminal, err := openTerminal(false); terminal != nil || err != nil {
+		t.Errorf("openTerminal(false) = %v, %v, want nil", terminal, err)
+	}
+}
+
+func TestStartDetachedExec(t *testing.T) {
+	var created execConfig
+	var start map[string]any
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/db/exec":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc"}`))
+		case "/v3.0.0/libpod/exec/abc/start":
+			json.NewDecoder(r.Body).Decode(&start)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+
+	config := execConfig{Cmd: []string{"vacuumdb", "--all"}, AttachStdout: true, AttachStderr: true}
+	id, err := startDetachedExec(context.Background(), httpClient, "db", detachedExecConfig(config, "/var/log/it's.log"))
+	if err != nil {
+		t.Fatalf("startDetachedExec() unexpected error = %v", err)
+	}
+	if id != "abc" {
+		t.Errorf("startDetachedExec() = %q, want abc", id)
+	}
+	if start["Detach"] != true {
+		t.Errorf("exec started with %v, want Detach", start)
+	}
+	if created.AttachStdout || created.AttachStderr {
+		t.Error("detached exec created with streams attached")
+	}
+	want := []string{"/bin/sh", "-c", `exec "$@" >>'/var/log/it'\''s.log' 2>&1`, "sh", "vacuumdb", "--all"}
+	if strings.Join(created.Cmd, "|") != strings.Join(want, "|") {
+		t.Errorf("exec created with Cmd %q, want %q", created.Cmd, want)
+	}
+}
+
+func TestExecSessionRow(t *testing.T) {
+	var session execSession
+	if err := json.Unmarshal([]byte(`{"ID":"abc","ContainerID":"0123456789abcdef","Running":false,"ExitCode":2,"Pid":42,
+		"ProcessConfig":{"entrypoint":"vacuumdb","arguments":["--all"]}}`), &session); err != nil {
+		t.Fatalf("Unmarshal() unexpected error = %v", err)
+	}
+	want := []string{"abc", "0123456789ab", "exited", "2", "42", "vacuumdb --all"}
+	if got := execSessionRow(session).cols; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("execSessionRow() = %q, want %q", got, want)
+	}
+
+	session.Running = true
+	if got := execSessionRow(session).cols; got[2] != "running" || got[3] != "" {
+		t.Errorf("execSessionRow() of a running session = %q, want no exit code", got)
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// newPushFileCommand returns the "push_file" command, which copies a local
+// file, such as an env file, Quadlet unit or Compose file, to the remote
+// host over the SSH connection, without the Podman API.
+func newPushFileCommand(fs *flag.FlagSet) runFunc {
+	var mode string
+	fs.StringVar(&mode, "mode", "", "Octal permissions of the remote file (default those of the local file, 0600 from stdin)")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 2 {
+			slog.Error("push_file: usage: push_file [-mode <perm>] <local-file>|- <remote-path>")
+			return 1
+		}
+		local, remote := rc.args[0], rc.args[1]
+
+		in := io.Reader(os.Stdin)
+		perm := os.FileMode(0600)
+		name := "stdin"
+		var size int64
+		if local != "-" {
+			f, err := os.Open(local)
+			if err != nil {
+				slog.Error("push_file", "err", err)
+				return 1
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil {
+				slog.Error("push_file", "err", err)
+				return 1
+			}
+			if info.IsDir() {
+				slog.Error("push_file: not a regular file", "file", local)
+				return 1
+			}
+			in, perm, name, size = f, info.Mode().Perm(), filepath.Base(local), info.Size()
+		}
+		if mode != "" {
+			m, err := strconv.ParseUint(mode, 8, 32)
+			if err != nil || m > 0777 {
+				slog.Error("push_file: invalid -mode (use octal permissions such as 0644)", "mode", mode)
+				return 1
+			}
+			perm = os.FileMode(m)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sftpClient, closeSFTP, err := rc.dialSFTP(ctx)
+		if err != nil {
+			slog.Error("push_file", "host", rc.host, "err", err)
+			return 1
+		}
+		defer closeSFTP()
+
+		start := time.Now()
+		dest, err := pushFile(sftpClient, newProgressReader(in, "Copying "+name, size), remote, name, perm)
+		if dest == "" {
+			dest = remote
+		}
+		rc.auditSSH(auditSFTPWrite, dest, start, err)
+		if err != nil {
+			slog.Error("push_file", "target", remote, "err", err)
+			return 1
+		}
+		slog.Info("Copied", "source", local, "host", rc.host, "target", dest)
+		return 0
+	}
+}
+
+// newPullFileCommand returns the "pull_file" command, which copies a file
+// of the remote host to a local file, or to stdout, over the SSH
+// connection.
+func newPullFileCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 && len(rc.args) != 2 {
+			slog.Error("pull_file: usage: pull_file <remote-path> [<local-file>|-]")
+			return 1
+		}
+		remote := rc.args[0]
+		local := path.Base(remote)
+		if len(rc.args) == 2 {
+			local = rc.args[1]
+		}
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			local = filepath.Join(local, path.Base(remote))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sftpClient, closeSFTP, err := rc.dialSFTP(ctx)
+		if err != nil {
+			slog.Error("pull_file", "host", rc.host, "err", err)
+			return 1
+		}
+		defer closeSFTP()
+
+		src, err := sftpClient.Open(remotePath(remote))
+		if err != nil {
+			slog.Error("pull_file", "target", remote, "err", err)
+			return 1
+		}
+		defer src.Close()
+		var size int64
+		if info, err := src.Stat(); err == nil {
+			size = info.Size()
+		}
+
+		if local == "-" {
+			pw := newProgressWriter(os.Stdout, "Copying "+path.Base(remote), size)
+			_, err := io.Copy(pw, src)
+			pw.Close()
+			if err != nil {
+				slog.Error("pull_file", "target", remote, "err", err)
+				return 1
+			}
+			return 0
+		}
+
+		// The file is written next to its destination and renamed once
+		// complete, so that a failed copy leaves no partial file
+		tmp, err := os.CreateTemp(filepath.Dir(local), "."+filepath.Base(local)+".*")
+		if err != nil {
+			slog.Error("pull_file", "err", err)
+			return 1
+		}
+		defer os.Remove(tmp.Name())
+		pw := newProgressWriter(tmp, "Copying "+path.Base(remote), size)
+		_, err = io.Copy(pw, src)
+		pw.Close()
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), local)
+		}
+		if err != nil {
+			slog.Error("pull_file", "target", remote, "err", err)
+			return 1
+		}
+		slog.Info("Copied", "host", rc.host, "source", remote, "target", local)
+		return 0
+	}
+}
+
+// dialSFTP connects to the remote host and starts an SFTP session on the
+// connection. The returned function closes both.
+func (rc *RemoteCLI) dialSFTP(ctx context.Context) (*sftp.Client, func(), error) {
+	sshClient, err := rc.dialSSH(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting: %w", err)
+	}
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, fmt.Errorf("starting SFTP session: %w", err)
+	}
+	return sftpClient, func() {
+		sftpClient.Close()
+		sshClient.Close()
+	}, nil
+}
+
+// remotePath returns p as a path for the SFTP server. A leading "~/" is
+// dropped, as relative paths are resolved from the remote user's home
+// directory.
+func remotePath(p string) string {
+	if p == "~" {
+		return "."
+	}
+	return strings.TrimPrefix(p, "~/")
+}
+
+// pushFile copies in to remote, or to name in it if remote is a directory
+// or ends with a slash, with permissions perm, and returns the path of the
+// file. Parent directories are created, and the file is written under a
+// temporary name and renamed once complete, so that a failed copy leaves
+// the destination untouched.
+func pushFile(c *sftp.Client, in io.Reader, remote, name string, perm os.FileMode) (string, error) {
+	dest := remotePath(remote)
+	if strings.HasSuffix(remote, "/") {
+		dest = path.Join(dest, name)
+	} else if info, err := c.Stat(dest); err == nil && info.IsDir() {
+		dest = path.Join(dest, name)
+	}
+	if err := c.MkdirAll(path.Dir(dest)); err != nil {
+		return "", err
+	}
+
+	tmp := fmt.Sprintf("%s.podman-cli.%d", dest, time.Now().UnixNano())
+	f, err := c.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", err
+	}
+	// The permissions are set while the file is still empty, so that the
+	// contents of a secret file are never readable by others
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.ReadFrom(in)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = rename(c, tmp, dest)
+	}
+	if err != nil {
+		c.Remove(tmp)
+		return "", err
+	}
+	return dest, nil
+}
+
+// rename renames oldname to newname, replacing newname if it exists. The
+// atomic POSIX rename extension is used where the server supports it, as
+// plain SFTP renames fail over existing files.
+func rename(c *sftp.Client, oldname, newname string) error {
+	if _, ok := c.HasExtension("posix-rename@openssh.com"); ok {
+		return c.PosixRename(oldname, newname)
+	}
+	if err := c.Remove(newname); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return c.Rename(oldname, newname)
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestRemotePath(t *testing.T) {
+	tests := map[string]string{
+		"~/.config/containers/systemd/web.container": ".config/containers/systemd/web.container",
+		"~":            ".",
+		"/etc/app.env": "/etc/app.env",
+		"app.env":      "app.env",
+	}
+	for in, want := range tests {
+		if got := remotePath(in); got != want {
+			t.Errorf("remotePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// newTestSFTP returns a client of an SFTP server serving the files of a
+// temporary directory, which it also returns.
+func newTestSFTP(t *testing.T) (*sftp.Client, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server resolves POSIX paths")
+	}
+	dir := t.TempDir()
+	serverConn, clientConn := net.Pipe()
+	server, err := sftp.NewServer(serverConn, sftp.WithServerWorkingDirectory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	c, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		server.Close()
+	})
+	return c, dir
+}
+
+func TestPushFile(t *testing.T) {
+	c, dir := newTestSFTP(t)
+	if err := os.Mkdir(filepath.Join(dir, "units"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "old.env"), []byte("MODE=stable\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		want   string
+	}{
+		{name: "file", remote: "app.env", want: "app.env"},
+		{name: "home", remote: "~/home.env", want: "home.env"},
+		{name: "existing file", remote: "old.env", want: "old.env"},
+		{name: "missing parents", remote: "config/deep/app.env", want: "config/deep/app.env"},
+		{name: "directory", remote: "units", want: "units/web container"},
+		{name: "trailing slash", remote: "new/", want: "new/web container"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pushFile(c, strings.NewReader("MODE=edge\n"), tt.remote, "web container", 0640)
+			if err != nil {
+				t.Fatalf("pushFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("pushFile() = %q, want %q", got, tt.want)
+			}
+			info, err := os.Stat(filepath.Join(dir, tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("mode = %o, want 640", info.Mode().Perm())
+			}
+			data, _ := os.ReadFile(filepath.Join(dir, tt.want))
+			if string(data) != "MODE=edge\n" {
+				t.Errorf("content = %q, want the input", data)
+			}
+		})
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*", "*.podman-cli.*"))
+	if top, _ := filepath.Glob(filepath.Join(dir, "*.podman-cli.*")); len(top) > 0 {
+		leftovers = append(leftovers, top...)
+	}
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left: %v", leftovers)
+	}
+}
+
+func TestPushFile_Failure(t *testing.T) {
+	c, dir := newTestSFTP(t)
+	// A file in the way of the parent directory
+	if err := os.WriteFile(filepath.Join(dir, "config"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := pushFile(c, strings.NewReader("x"), "config/app.env", "app.env", 0600); err == nil {
+		t.Errorf("pushFile() = %q, want an error", got)
+	}
+}
+
+func TestFileCommands_Validation(t *testing.T) {
+	if code := newTestCommand(t, newPushFileCommand)(&RemoteCLI{args: []string{"app.env"}}); code != 1 {
+		t.Errorf("push_file with one argument = %d, want 1", code)
+	}
+	if code := newTestCommand(t, newPushFileCommand, "-mode", "0999")(&RemoteCLI{args: []string{"-", "app.env"}}); code != 1 {
+		t.Errorf("push_file -mode 0999 = %d, want 1", code)
+	}
+	if code := newTestCommand(t, newPushFileCommand)(&RemoteCLI{args: []string{filepath.Join(t.TempDir(), "missing"), "app.env"}}); code != 1 {
+		t.Errorf("push_file of a missing file = %d, want 1", code)
+	}
+	if code := newTestCommand(t, newPullFileCommand)(&RemoteCLI{}); code != 1 {
+		t.Errorf("pull_file without arguments = %d, want 1", code)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// newForwardCommand returns the "forward" command, which exposes the remote
+// Podman socket on a local Unix socket or TCP address so that other tools
+// (docker CLI, podman --url, IDE plugins) can use it through the SSH tunnel.
+func newForwardCommand(fs *flag.FlagSet) runFunc {
+	var listen string
+	fs.StringVar(&listen, "listen", "", "Local Unix socket path or TCP address to listen on")
+
+	return func(rc *RemoteCLI) int {
+		if listen == "" {
+			slog.Error("forward: -listen is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Connections are dialed lazily and re-established if the SSH
+		// link drops while forwarding.
+		redialer := client.NewRedialer(func() (*ssh.Client, error) {
+			return rc.dialSSH(ctx)
+		}, rc.keepAlive)
+		defer redialer.Close()
+
+		if _, err := redialer.Client(); err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+
+		// The API gives root-equivalent access to the remote host, without
+		// authentication. A Unix socket is only accessible to the user, but
+		// every local user can connect to a loopback TCP address, so other
+		// addresses are refused and loopback ones warned about
+		listener, err := listenLocal(listen, false)
+		if err != nil {
+			slog.Error("forward", "err", err)
+			return 1
+		}
+		defer listener.Close()
+		if listener.Addr().Network() == "tcp" {
+			slog.Warn("forward: any local user can use the remote Podman API on a TCP address; prefer a Unix socket", "listen", listener.Addr().String())
+		}
+
+		slog.Info("Forwarding", "listen", listener.Addr().Network()+"://"+listener.Addr().String(), "host", rc.addr, "socket", rc.socketPath)
+
+		err = client.Forward(ctx, listener, func() (net.Conn, error) {
+			return redialer.Dial("unix", rc.socketPath)
+		})
+		if err != nil {
+			slog.Error("forward", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// listenAddress splits a -listen value into a network and address. Values
+// with a "unix://" or "tcp://" scheme use that network; otherwise anything
+// that looks like a file path is treated as a Unix socket, a bare port as
+// that port of 127.0.0.1 and everything else as a TCP address.
+func listenAddress(listen string) (network, address string) {
+	switch {
+	case isPort(listen):
+		return "tcp", net.JoinHostPort("127.0.0.1", listen)
+	case strings.HasPrefix(listen, "unix://"):
+		return "unix", strings.TrimPrefix(listen, "unix://")
+	case strings.HasPrefix(listen, "tcp://"):
+		return "tcp", strings.TrimPrefix(listen, "tcp://")
+	case strings.ContainsRune(listen, '/'):
+		return "unix", listen
+	default:
+		return "tcp", listen
+	}
+}
+
+// isPort reports whether s is a port number, such as 2375.
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 0 && port <= 65535 && strconv.Itoa(port) == s
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+)
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		listen      string
+		wantNetwork string
+		wantAddress string
+	}{
+		{"/tmp/podman-remote.sock", "unix", "/tmp/podman-remote.sock"},
+		{"unix:///tmp/podman.sock", "unix", "/tmp/podman.sock"},
+		{"127.0.0.1:8080", "tcp", "127.0.0.1:8080"},
+		{":8080", "tcp", ":8080"},
+		{"2375", "tcp", "127.0.0.1:2375"},
+		{"tcp://localhost:2375", "tcp", "localhost:2375"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.listen, func(t *testing.T) {
+			network, address := listenAddress(tt.listen)
+			if network != tt.wantNetwork || address != tt.wantAddress {
+				t.Errorf("listenAddress(%q) = (%q, %q), want (%q, %q)",
+					tt.listen, network, address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestNewRemoteCLI_ForwardFlagsAfterCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	args := []string{"forward", "-host", "testhost", "-listen", "/tmp/podman-remote.sock"}
+	cli, err := NewRemoteCLI(args)
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	if cli.run == nil {
+		t.Error("NewRemoteCLI() run is nil for local command")
+	}
+
+	if cli.addr != "test.example.com:22" {
+		t.Errorf("NewRemoteCLI() addr = %q, want %q", cli.addr, "test.example.com:22")
+	}
+}
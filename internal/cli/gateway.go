@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// gatewayVersionPrefix matches the API version starting request paths,
+// such as /v4.0.0, which -allow prefixes leave out.
+var gatewayVersionPrefix = regexp.MustCompile(`^/v[0-9][0-9.]*(/|$)`)
+
+// gatewayAlwaysAllowed are the paths clients probe before anything else,
+// answered whatever the -allow prefixes.
+var gatewayAlwaysAllowed = []string{"/_ping", "/version", "/libpod/_ping", "/libpod/version"}
+
+// newGatewayCommand returns the "gateway" command, which serves the remote
+// Podman API over HTTP on a local address through the SSH tunnel, so that
+// tools speaking the Docker or Podman API over TCP, such as Portainer or
+// IDE extensions, can manage the remote host.
+func newGatewayCommand(fs *flag.FlagSet) runFunc {
+	var listen, token string
+	var allow stringsFlag
+	fs.StringVar(&listen, "listen", "", "Local TCP address, or Unix socket, to serve the API on")
+	fs.StringVar(&token, "token", os.Getenv(envName("gateway-token")), "Require this bearer token in the Authorization header (default $"+envName("gateway-token")+")")
+	fs.Var(&allow, "allow", "Only forward requests whose path, without its API version, starts with this prefix, such as /libpod/containers (repeatable)")
+
+	return func(rc *RemoteCLI) int {
+		if listen == "" {
+			slog.Error("gateway: -listen is required")
+			return 1
+		}
+		// Any local user, and web pages through DNS rebinding, can reach
+		// a TCP address, even a loopback one
+		if network, _ := listenAddress(listen); network == "tcp" && token == "" {
+			slog.Error("gateway: -token is required to serve on TCP")
+			return 1
+		}
+		for _, prefix := range allow {
+			if !strings.HasPrefix(prefix, "/") {
+				slog.Error("gateway: -allow prefixes must start with /", "allow", prefix)
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		redialer := client.NewRedialer(func() (*ssh.Client, error) {
+			return rc.dialSSH(ctx)
+		}, rc.keepAlive)
+		defer redialer.Close()
+
+		if _, err := redialer.Client(); err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+
+		listener, err := listenLocal(listen, token != "")
+		if err != nil {
+			slog.Error("gateway", "err", err)
+			return 1
+		}
+
+		var rt http.RoundTripper = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return redialer.Dial("unix", rc.socketPath)
+			},
+			DisableCompression: true,
+		}
+		if rc.audit != nil {
+			rt = rc.audit.transport(rt, rc.host)
+		}
+		server := &http.Server{
+			Handler:           gatewayHandler(newGatewayProxy(rt), token, allow),
+			ReadHeaderTimeout: 30 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+
+		slog.Info("Serving the Podman API", "listen", listener.Addr().Network()+"://"+listener.Addr().String(), "host", rc.addr, "socket", rc.socketPath)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("gateway", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// newGatewayProxy returns a reverse proxy sending requests to the Podman
+// API with rt, flushing responses as they are written so that streams such
+// as events and logs reach clients at once. Upgraded connections, used by
+// attach and exec, are proxied as well.
+func newGatewayProxy(rt http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme, r.Out.URL.Host = "http", "d"
+			r.Out.Host = "d"
+			// The token is meant for the gateway, not the remote host
+			r.Out.Header.Del("Authorization")
+		},
+		Transport:     rt,
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Error("gateway", "method", r.Method, "path", r.URL.Path, "err", err)
+			http.Error(w, "podman-cli gateway: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+}
+
+// gatewayHandler returns the handler passing the requests with the bearer
+// token, unless empty, whose path starts with one of the allow prefixes,
+// if any, to next. Requests from web pages, which carry an Origin header
+// unlike those of API clients, are refused.
+func gatewayHandler(next http.Handler, token string, allow []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			slog.Warn("gateway: request from a web page denied", "method", r.Method, "path", r.URL.Path, "origin", r.Header.Get("Origin"))
+			http.Error(w, "podman-cli gateway: requests from web pages are not allowed", http.StatusForbidden)
+			return
+		}
+		if token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="podman-cli"`)
+				http.Error(w, "podman-cli gateway: missing or invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		// Dot segments would let a path escape its allowed prefix
+		if path.Clean(r.URL.Path) != r.URL.Path && path.Clean(r.URL.Path)+"/" != r.URL.Path {
+			http.Error(w, "podman-cli gateway: invalid path", http.StatusBadRequest)
+			return
+		}
+		if len(allow) > 0 && !gatewayAllowed(r.URL.Path, allow) {
+			slog.Warn("gateway: request denied", "method", r.Method, "path", r.URL.Path)
+			http.Error(w, "podman-cli gateway: path not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gatewayAllowed reports whether the request path p, without its API
+// version, is one of gatewayAlwaysAllowed or starts with one of the allow
+// prefixes, at a segment boundary.
+func gatewayAllowed(p string, allow []string) bool {
+	if loc := gatewayVersionPrefix.FindStringIndex(p); loc != nil {
+		p = "/" + p[loc[1]:]
+	}
+	for _, prefixes := range [][]string{gatewayAlwaysAllowed, allow} {
+		for _, prefix := range prefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
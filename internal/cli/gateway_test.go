@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGatewayHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Authorization header forwarded to the remote host")
+		}
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+	}))
+	defer backend.Close()
+	rt := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return net.Dial("tcp", backend.Listener.Addr().String())
+	}}
+	gateway := httptest.NewServer(gatewayHandler(newGatewayProxy(rt), "secret", []string{"/libpod/containers", "/images/"}))
+	defer gateway.Close()
+
+	tests := []struct {
+		method, path, token string
+		origin              string
+		wantStatus          int
+		wantBody            string
+	}{
+		{"GET", "/v4.0.0/libpod/containers/json?all=true", "secret", "", http.StatusOK, "GET /v4.0.0/libpod/containers/json?all=true"},
+		{"POST", "/libpod/containers/web/stop", "secret", "", http.StatusOK, "POST /libpod/containers/web/stop"},
+		{"GET", "/v1.41/images/json", "secret", "", http.StatusOK, "GET /v1.41/images/json"},
+		{"GET", "/_ping", "secret", "", http.StatusOK, "GET /_ping"},
+		{"GET", "/v4.0.0/libpod/containers/json", "", "", http.StatusUnauthorized, ""},
+		{"GET", "/v4.0.0/libpod/containers/json", "wrong", "", http.StatusUnauthorized, ""},
+		{"GET", "/v4.0.0/libpod/containersx/json", "secret", "", http.StatusForbidden, ""},
+		{"DELETE", "/v4.0.0/libpod/volumes/data", "secret", "", http.StatusForbidden, ""},
+		{"GET", "/v4.0.0/libpod/containers/../secrets/json", "secret", "", http.StatusBadRequest, ""},
+		{"POST", "/v4.0.0/libpod/containers/create", "secret", "http://evil.example", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, gateway.URL, nil)
+			// Set the path as is, without the cleaning of NewRequest
+			req.URL.Opaque = tt.path
+			if before, _, ok := strings.Cut(tt.path, "?"); ok {
+				req.URL.Opaque, req.URL.RawQuery = before, strings.TrimPrefix(tt.path, before+"?")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request unexpected error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
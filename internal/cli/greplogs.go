@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// newGrepLogsCommand returns the "grep_logs" command, a simple distributed
+// log search for small fleets: it fetches the recent logs of the matching
+// containers of several hosts, keeps the lines matching a regular
+// expression, and prints them prefixed with the host and container. Like
+// grep, it exits with 0 if any line matched, 1 if none did and 2 if the
+// logs of a host or container could not be read.
+func newGrepLogsCommand(fs *flag.FlagSet) runFunc {
+	var hosts, since, timezone string
+	var maxParallel int
+	var ignoreCase, timestamps bool
+	var filters stringsFlag
+	fs.StringVar(&hosts, "hosts", "", "Comma separated hosts, or @<group> for a group of the configuration file")
+	fs.StringVar(&since, "since", "1h", "Only search output since this time: RFC 3339 timestamp, Unix time or duration before now")
+	fs.Var(&filters, "filter", "Only search the containers matching key=value, such as label=app=web (repeatable)")
+	fs.BoolVar(&ignoreCase, "i", false, "Match the pattern without regard to case")
+	fs.BoolVar(&timestamps, "timestamps", false, "Prefix each line with the time it was written")
+	fs.BoolVar(&timestamps, "t", false, "Shorthand for -timestamps")
+	fs.StringVar(&timezone, "timezone", "local", "Time zone of -timestamps: local, UTC or an IANA name such as Europe/Berlin")
+	fs.IntVar(&maxParallel, "max-parallel", 4, "Number of hosts searched at the same time")
+
+	return func(rc *RemoteCLI) int {
+		if hosts == "" || len(rc.args) != 1 {
+			slog.Error("grep_logs: usage: grep_logs -hosts <host>,...|@<group> [flags] <pattern>")
+			return 2
+		}
+		if maxParallel < 1 {
+			slog.Error("grep_logs: -max-parallel must be at least 1")
+			return 2
+		}
+		pattern := rc.args[0]
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			slog.Error("grep_logs: invalid pattern", "err", err)
+			return 2
+		}
+		query, err := logsQuery(since, "", "all", time.Now())
+		if err != nil {
+			slog.Error("grep_logs", "err", err)
+			return 2
+		}
+		// Timestamps are always requested, to be left out of the match
+		query.Set("timestamps", "true")
+		var loc *time.Location
+		if timestamps {
+			if loc, err = parseTimezone(timezone); err != nil {
+				slog.Error("grep_logs", "err", err)
+				return 2
+			}
+		}
+		encoded, err := encodeFilters(filters)
+		if err != nil {
+			slog.Error("grep_logs", "err", err)
+			return 2
+		}
+		targets, err := resolveHosts(hosts, rc.opts.configFile)
+		if err != nil {
+			slog.Error("grep_logs", "err", err)
+			return 2
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		color := colorEnabled(os.Stdout)
+		var mu sync.Mutex
+		var matches atomic.Int64
+		var failed atomic.Bool
+		forEachHost(targets, maxParallel, func(i int, host string) {
+			httpClient, closeFn, err := connectHost(ctx, rc, host)
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", host, "err", err)
+				failed.Store(true)
+				return
+			}
+			defer closeFn()
+
+			names, err := filteredContainers(ctx, httpClient, encoded)
+			if err != nil {
+				slog.Error("grep_logs", "host", host, "err", err)
+				failed.Store(true)
+				return
+			}
+			for _, name := range names {
+				prefix := host + "/" + name + " | "
+				if color {
+					prefix = logColors[i%len(logColors)] + prefix + ansiReset
+				}
+				out := &prefixWriter{w: os.Stdout, mu: &mu, prefix: prefix}
+				n, err := grepContainerLogs(ctx, httpClient, name, query, re, loc, out)
+				out.flush()
+				matches.Add(int64(n))
+				if err != nil && ctx.Err() == nil {
+					slog.Error("grep_logs", "host", host, "target", name, "err", err)
+					failed.Store(true)
+				}
+			}
+		})
+
+		switch {
+		case failed.Load() || ctx.Err() != nil:
+			return 2
+		case matches.Load() == 0:
+			return 1
+		}
+		return 0
+	}
+}
+
+// grepContainerLogs writes the lines of output of the named container,
+// from stdout and stderr, that match re to out, with their timestamps
+// rendered in loc, or without them if loc is nil. The logs are read with
+// query, which must request timestamps. It returns the number of lines
+// written.
+func grepContainerLogs(ctx context.Context, httpClient *http.Client, name string, query url.Values, re *regexp.Regexp, loc *time.Location, out io.Writer) (int, error) {
+	var w io.Writer = out
+	if loc != nil {
+		stamp := &timestampWriter{w: out, loc: loc}
+		defer stamp.flush()
+		w = stamp
+	}
+	stdout := &grepWriter{w: w, re: re, keepStamp: loc != nil}
+	stderr := &grepWriter{w: w, re: re, keepStamp: loc != nil}
+	err := containerLogs(ctx, httpClient, name, query, stdout, stderr)
+	stdout.flush()
+	stderr.flush()
+	return stdout.matches + stderr.matches, err
+}
+
+// grepWriter writes the lines of output matching re to w, matching them
+// without the timestamp Podman puts at their start, which is dropped
+// unless keepStamp is set. Partial lines are held until complete or
+// flushed.
+type grepWriter struct {
+	w         io.Writer
+	re        *regexp.Regexp
+	keepStamp bool
+	line      []byte
+	matches   int
+}
+
+func (gw *grepWriter) Write(p []byte) (int, error) {
+	gw.line = append(gw.line, p...)
+	for {
+		i := bytes.IndexByte(gw.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := gw.writeLine(gw.line[:i+1]); err != nil {
+			return 0, err
+		}
+		gw.line = append(gw.line[:0], gw.line[i+1:]...)
+	}
+}
+
+// flush writes the last line of output if it did not end with a newline.
+func (gw *grepWriter) flush() error {
+	if len(gw.line) == 0 {
+		return nil
+	}
+	err := gw.writeLine(append(gw.line, '\n'))
+	gw.line = gw.line[:0]
+	return err
+}
+
+func (gw *grepWriter) writeLine(line []byte) error {
+	text := line
+	if stamp, rest, ok := bytes.Cut(line, []byte(" ")); ok {
+		if _, err := time.Parse(time.RFC3339Nano, string(stamp)); err == nil {
+			text = rest
+		}
+	}
+	if !gw.re.Match(bytes.TrimRight(text, "\r\n")) {
+		return nil
+	}
+	gw.matches++
+	if !gw.keepStamp {
+		line = text
+	}
+	_, err := gw.w.Write(line)
+	return err
+}
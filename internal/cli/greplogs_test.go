@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestGrepWriter(t *testing.T) {
+	var out bytes.Buffer
+	gw := &grepWriter{w: &out, re: regexp.MustCompile(`error|2024`)}
+	gw.Write([]byte("2024-05-01T08:00:00Z GET /\n2024-05-01T08:00:01Z connect er"))
+	gw.Write([]byte("ror\nplain error line\n2024-05-01T08:00:02Z last error"))
+	gw.flush()
+
+	// The timestamp is not matched, and dropped unless kept
+	want := "connect error\nplain error line\nlast error\n"
+	if out.String() != want {
+		t.Errorf("grepWriter output = %q, want %q", out.String(), want)
+	}
+	if gw.matches != 3 {
+		t.Errorf("grepWriter matches = %d, want 3", gw.matches)
+	}
+}
+
+func TestGrepContainerLogs(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/containers/web/logs" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		w.Write(frame(1, "2024-05-01T08:00:00Z GET / 200\n"))
+		w.Write(frame(2, "2024-05-01T08:00:01Z upstream Timeout\n"))
+		w.Write(frame(1, "2024-05-01T08:00:02Z GET /api 504\n"))
+	}))
+
+	query, _ := logsQuery("1h", "", "all", time.Now())
+	query.Set("timestamps", "true")
+	re := regexp.MustCompile(`(?i)timeout|50[0-9]`)
+	var out bytes.Buffer
+	n, err := grepContainerLogs(context.Background(), httpClient, "web", query, re, time.UTC, &out)
+	if err != nil {
+		t.Fatalf("grepContainerLogs() unexpected error = %v", err)
+	}
+	want := "2024-05-01T08:00:01Z upstream Timeout\n2024-05-01T08:00:02Z GET /api 504\n"
+	if n != 2 || out.String() != want {
+		t.Errorf("grepContainerLogs() = %d, %q, want 2, %q", n, out.String(), want)
+	}
+}
+
+func TestGrepLogsCommand_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+	}{
+		{"no hosts", nil, []string{"error"}},
+		{"no pattern", []string{"-hosts", "edge1"}, nil},
+		{"invalid pattern", []string{"-hosts", "edge1"}, []string{"("}},
+		{"invalid since", []string{"-hosts", "edge1", "-since", "yesterday"}, []string{"error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newTestCommand(t, newGrepLogsCommand, tt.flags...)
+			if code := run(&RemoteCLI{args: tt.args}); code != 2 {
+				t.Errorf("grep_logs = %d, want 2", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// newHealthcheckRunCommand returns the "healthcheck_run" command, which runs
+// a container's healthcheck and exits non-zero unless it reports healthy,
+// for use from external monitoring scripts.
+func newHealthcheckRunCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("healthcheck_run: exactly one container name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		health, err := runHealthcheck(ctx, httpClient, name)
+		if err != nil {
+			slog.Error("healthcheck_run", "target", name, "err", err)
+			return 1
+		}
+
+		fmt.Println(health.Status)
+		if health.Status != models.HealthHealthy {
+			if n := len(health.Log); n > 0 {
+				fmt.Fprint(os.Stderr, health.Log[n-1].Output)
+			}
+			return 1
+		}
+		return 0
+	}
+}
+
+// runHealthcheck runs the healthcheck of the named container.
+func runHealthcheck(ctx context.Context, httpClient *http.Client, name string) (models.HealthCheckResults, error) {
+	var health models.HealthCheckResults
+	err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/healthcheck", nil, &health)
+	return health, err
+}
+
+// newInspectCommand returns the "inspect" command, which prints the inspect
+// data of one or more containers as a JSON array. It exits non-zero when a
+// container's healthcheck reports unhealthy.
+func newInspectCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("inspect: at least one container name or ID is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		unhealthy, err := inspectContainers(ctx, httpClient, rc.args, os.Stdout)
+		if err != nil {
+			slog.Error("inspect", "err", err)
+			return 1
+		}
+		if unhealthy {
+			return 1
+		}
+		return 0
+	}
+}
+
+// inspectContainers writes the inspect data of the named containers to out
+// as an indented JSON array, reporting whether any of them is unhealthy.
+func inspectContainers(ctx context.Context, httpClient *http.Client, names []string, out io.Writer) (bool, error) {
+	unhealthy := false
+	all := make([]json.RawMessage, 0, len(names))
+	for _, name := range names {
+		var raw json.RawMessage
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &raw); err != nil {
+			return false, fmt.Errorf("%s: %w", name, err)
+		}
+
+		var data models.InspectContainerData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return false, fmt.Errorf("%s: %w", name, err)
+		}
+		if h := data.State.Health; h != nil && h.Status == models.HealthUnhealthy {
+			unhealthy = true
+		}
+		all = append(all, raw)
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return unhealthy, err
+}
+
+// newPsCommand returns the "ps" command, which lists containers on the
+// remote host along with their health status. It exits non-zero when any
+// listed container is unhealthy.
+func newPsCommand(fs *flag.FlagSet) runFunc {
+	var all bool
+	var filters stringsFlag
+	fs.BoolVar(&all, "a", false, "Show all containers, not only running ones")
+	fs.Var(&filters, "filter", filterUsage)
+
+	return func(rc *RemoteCLI) int {
+		query := url.Values{"all": {fmt.Sprint(all)}}
+		if len(filters) > 0 {
+			encoded, err := encodeFilters(filters)
+			if err != nil {
+				slog.Error("ps", "err", err)
+				return 1
+			}
+			query.Set("filters", encoded)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		rows, unhealthy, err := listContainers(ctx, httpClient, query)
+		if err != nil {
+			slog.Error("ps", "err", err)
+			return 1
+		}
+
+		if err := writeTable(os.Stdout, rc.opts.format, containerHeaders, rows); err != nil {
+			slog.Error("ps", "err", err)
+			return 1
+		}
+		if unhealthy {
+			return 1
+		}
+		return 0
+	}
+}
+
+// listContainers returns a table row per container listed with the given
+// query, reporting whether any of them is unhealthy.
+func listContainers(ctx context.Context, httpClient *http.Client, query url.Values) ([]tuiRow, bool, error) {
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", query, &containers); err != nil {
+		return nil, false, err
+	}
+	rows, unhealthy := containerRows(containers)
+	return rows, unhealthy, nil
+}
+
+// containerHeaders are the headers of the rows of containerRows.
+var containerHeaders = []string{"CONTAINER ID", "NAMES", "IMAGE", "STATUS", "HEALTH"}
+
+// containerRows returns a table row per container, reporting whether any
+// of them is unhealthy.
+func containerRows(containers []models.ContainerSummary) ([]tuiRow, bool) {
+	unhealthy := false
+	rows := make([]tuiRow, 0, len(containers))
+	for _, c := range containers {
+		names := make([]string, len(c.Names))
+		for i, n := range c.Names {
+			names[i] = strings.TrimPrefix(n, "/")
+		}
+
+		health := healthFromStatus(c.Status)
+		if health == models.HealthUnhealthy {
+			unhealthy = true
+		}
+		if health == "" {
+			health = "-"
+		}
+		rows = append(rows, tuiRow{id: c.ID, cols: []string{shortID(c.ID), strings.Join(names, ","), c.Image, c.Status, health}})
+	}
+	return rows, unhealthy
+}
+
+// healthFromStatus extracts the health state from a compat container status
+// such as "Up 5 minutes (healthy)" or "Up 2 seconds (health: starting)". It
+// returns "" for containers without a healthcheck.
+func healthFromStatus(status string) string {
+	// unhealthy is checked first as it also ends in "healthy)"
+	for _, h := range []string{models.HealthUnhealthy, models.HealthHealthy, models.HealthStarting} {
+		if strings.HasSuffix(status, h+")") {
+			return h
+		}
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestRunHealthcheck(t *testing.T) {
+	var gotMethod, gotPath string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"Status":"unhealthy","FailingStreak":3,"Log":[{"ExitCode":1,"Output":"connection refused\n"}]}`))
+	}))
+
+	health, err := runHealthcheck(context.Background(), httpClient, "web")
+	if err != nil {
+		t.Fatalf("runHealthcheck() unexpected error = %v", err)
+	}
+
+	if gotMethod != http.MethodGet || gotPath != "/v3.0.0/libpod/containers/web/healthcheck" {
+		t.Errorf("runHealthcheck() request = %s %s, want GET /v3.0.0/libpod/containers/web/healthcheck", gotMethod, gotPath)
+	}
+	if health.Status != models.HealthUnhealthy || health.FailingStreak != 3 {
+		t.Errorf("runHealthcheck() = %s (streak %d), want unhealthy (streak 3)", health.Status, health.FailingStreak)
+	}
+	if len(health.Log) != 1 || health.Log[0].Output != "connection refused\n" {
+		t.Errorf("runHealthcheck() log = %+v, want one entry", health.Log)
+	}
+}
+
+func TestInspectContainers(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/web/json":
+			w.Write([]byte(`{"Name":"web","State":{"Health":{"Status":"healthy"}}}`))
+		case "/v3.0.0/libpod/containers/db/json":
+			w.Write([]byte(`{"Name":"db","State":{"Health":{"Status":"unhealthy"}}}`))
+		case "/v3.0.0/libpod/containers/plain/json":
+			w.Write([]byte(`{"Name":"plain","State":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	tests := []struct {
+		names         []string
+		wantUnhealthy bool
+	}{
+		{[]string{"web"}, false},
+		{[]string{"plain"}, false},
+		{[]string{"web", "db"}, true},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		unhealthy, err := inspectContainers(context.Background(), httpClient, tt.names, &out)
+		if err != nil {
+			t.Fatalf("inspectContainers(%v) unexpected error = %v", tt.names, err)
+		}
+		if unhealthy != tt.wantUnhealthy {
+			t.Errorf("inspectContainers(%v) unhealthy = %v, want %v", tt.names, unhealthy, tt.wantUnhealthy)
+		}
+		if !strings.HasPrefix(out.String(), "[") || !strings.Contains(out.String(), `"Name": "`+tt.names[0]+`"`) {
+			t.Errorf("inspectContainers(%v) output = %q, want JSON array", tt.names, out.String())
+		}
+	}
+}
+
+func TestInspectContainers_NotFound(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+	}))
+
+	var out strings.Builder
+	if _, err := inspectContainers(context.Background(), httpClient, []string{"missing"}, &out); err == nil {
+		t.Error("inspectContainers() expected error for missing container, got nil")
+	}
+}
+
+func TestListContainers(t *testing.T) {
+	var gotAll string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAll = r.URL.Query().Get("all")
+		w.Write([]byte(`[
+			{"Id":"0123456789abcdef","Names":["/web"],"Image":"nginx","Status":"Up 5 minutes (healthy)"},
+			{"Id":"fedcba9876543210","Names":["/db"],"Image":"postgres","Status":"Up 1 minute (unhealthy)"},
+			{"Id":"aaaaaaaaaaaaaaaa","Names":["/tmp"],"Image":"alpine","Status":"Exited (0) 2 hours ago"}
+		]`))
+	}))
+
+	rows, unhealthy, err := listContainers(context.Background(), httpClient, url.Values{"all": {"true"}})
+	if err != nil {
+		t.Fatalf("listContainers() unexpected error = %v", err)
+	}
+
+	if gotAll != "true" {
+		t.Errorf("listContainers() all = %q, want %q", gotAll, "true")
+	}
+	if !unhealthy {
+		t.Error("listContainers() unhealthy = false, want true")
+	}
+
+	want := [][]string{
+		{"0123456789ab", "web", "nginx", "Up 5 minutes (healthy)", "healthy"},
+		{"fedcba987654", "db", "postgres", "Up 1 minute (unhealthy)", "unhealthy"},
+		{"aaaaaaaaaaaa", "tmp", "alpine", "Exited (0) 2 hours ago", "-"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("listContainers() returned %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if strings.Join(row.cols, "|") != strings.Join(want[i], "|") {
+			t.Errorf("listContainers() row %d = %q, want %q", i, row.cols, want[i])
+		}
+	}
+}
+
+func TestHealthFromStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"Up 5 minutes (healthy)", models.HealthHealthy},
+		{"Up 5 minutes (unhealthy)", models.HealthUnhealthy},
+		{"Up 2 seconds (health: starting)", models.HealthStarting},
+		{"Up 2 seconds (starting)", models.HealthStarting},
+		{"Up 5 minutes", ""},
+		{"Exited (0) 2 hours ago", ""},
+	}
+
+	for _, tt := range tests {
+		if got := healthFromStatus(tt.status); got != tt.want {
+			t.Errorf("healthFromStatus(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
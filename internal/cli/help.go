@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexjch/podman-cli/internal/commands"
+)
+
+func init() {
+	// Registered here rather than in localCommands, as help looks up the
+	// other commands in it.
+	localCommands["help"] = localCommand{
+		setup:    newHelpCommand,
+		summary:  "Show general help, or the usage of a command",
+		usage:    "[<command>]",
+		noHost:   true,
+		noDryRun: true,
+	}
+	localCommands["commands"] = localCommand{
+		setup:    newCommandsCommand,
+		summary:  "List the available commands",
+		noHost:   true,
+		noDryRun: true,
+	}
+}
+
+// newHelpCommand returns the "help" command, which prints general usage
+// or, given a command name, the usage of that command.
+func newHelpCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		switch len(rc.args) {
+		case 0:
+			printUsage(os.Stdout)
+			return 0
+		case 1:
+			if err := printCommandHelp(os.Stdout, rc.args[0]); err != nil {
+				slog.Error("help", "err", err)
+				return 1
+			}
+			return 0
+		}
+		slog.Error("help: usage: help [<command>]")
+		return 1
+	}
+}
+
+// newCommandsCommand returns the "commands" command, which lists every
+// command with its one-line description.
+func newCommandsCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		printCommandList(os.Stdout, "")
+		return 0
+	}
+}
+
+// printUsage writes the general usage: the synopsis, the commands and the
+// global flags.
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: podman-cli [global flags] <command> [flags] [arguments]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Run Podman commands on a remote host through its API socket over SSH.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	printCommandList(out, "  ")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Global flags (accepted before or after the command):")
+	printGlobalFlags(out)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, `Run "podman-cli help <command>" for the usage of a command.`)
+}
+
+// printCommandList writes one line per command with its summary, each
+// preceded by indent.
+func printCommandList(out io.Writer, indent string) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	for _, name := range allCommandNames() {
+		fmt.Fprintf(w, "%s%s\t%s\n", indent, name, commandSummary(name))
+	}
+	w.Flush()
+}
+
+// commandSummary returns the one-line description of the named command.
+func commandSummary(name string) string {
+	if target, ok := builtinAliases[name]; ok && !isCommand(name) {
+		return "Alias for " + target
+	}
+	if local, ok := localCommands[name]; ok {
+		return local.summary
+	}
+	if command := commands.IsCommand(name); command != nil {
+		return command.Description
+	}
+	if _, err := lookPlugin(name); err == nil {
+		return "Plugin (" + pluginPrefix + name + ")"
+	}
+	return ""
+}
+
+// printGlobalFlags writes the defaults of the global flags.
+func printGlobalFlags(out io.Writer) {
+	fs := flag.NewFlagSet("global", flag.ContinueOnError)
+	opts := defaultGlobalOptions()
+	opts.register(fs)
+	fs.SetOutput(out)
+	fs.PrintDefaults()
+}
+
+// printCommandHelp writes the usage of the named command: its synopsis,
+// description, own flags and examples.
+func printCommandHelp(out io.Writer, name string) error {
+	if target, ok := builtinAliases[name]; ok && !isCommand(name) {
+		fmt.Fprintf(out, "%s is an alias for %s.\n\n", name, target)
+		name = target
+	}
+	local, isLocal := localCommands[name]
+	command := commands.IsCommand(name)
+	if !isLocal && command == nil {
+		path, err := lookPlugin(name)
+		if err != nil {
+			return fmt.Errorf("unknown command %q (run \"podman-cli commands\" for a list)", name)
+		}
+		fmt.Fprintf(out, "Usage: podman-cli [global flags] %s [arguments]\n\n", name)
+		fmt.Fprintf(out, "Runs the plugin %s with the arguments, which include its own flags.\n", path)
+		fmt.Fprintf(out, "Run \"podman-cli %s -h\" for the plugin's usage, if it provides one.\n", name)
+		return nil
+	}
+
+	usage := ""
+	if isLocal && local.usage != "" {
+		usage = " " + local.usage
+	}
+	if !isLocal || local.hostArg {
+		usage += " [<host>]"
+	}
+	fmt.Fprintf(out, "Usage: podman-cli [global flags] %s%s\n\n", name, usage)
+	fmt.Fprintln(out, commandSummary(name))
+	if aliases := aliasesOf(name); len(aliases) > 0 {
+		fmt.Fprintf(out, "\nAliases: %s\n", strings.Join(aliases, ", "))
+	}
+	if command != nil {
+		fmt.Fprintf(out, "\nSends %s %s and prints the response.\n", command.Method, command.Path)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if isLocal {
+		local.setup(fs)
+	} else {
+		var output string
+		var filters stringsFlag
+		apiCommandFlags(fs, *command, &output, &filters)
+	}
+	if hasFlags(fs) {
+		fmt.Fprintln(out, "\nFlags:")
+		fs.SetOutput(out)
+		fs.PrintDefaults()
+	}
+
+	if isLocal {
+
+		if len(local.examples) > 0 {
+			fmt.Fprintln(out, "\nExamples:")
+			for _, example := range local.examples {
+				fmt.Fprintln(out, "  "+example)
+			}
+		}
+	}
+
+	fmt.Fprintln(out, "\nGlobal flags are listed by \"podman-cli help\".")
+	return nil
+}
+
+// hasFlags reports whether any flag is defined on fs.
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// usageFunc returns a flag.FlagSet Usage function printing the help for
+// the named command, or the general usage if name is empty.
+func usageFunc(fs *flag.FlagSet, name string) func() {
+	return func() {
+		if name == "" || printCommandHelp(fs.Output(), name) != nil {
+			printUsage(fs.Output())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCommandSummaries(t *testing.T) {
+	for _, name := range allCommandNames() {
+		if commandSummary(name) == "" {
+			t.Errorf("command %s has no summary", name)
+		}
+	}
+}
+
+func TestPrintCommandHelp(t *testing.T) {
+	var out strings.Builder
+	if err := printCommandHelp(&out, "rm"); err != nil {
+		t.Fatalf("printCommandHelp() unexpected error = %v", err)
+	}
+
+	for _, want := range []string{
+		"Usage: podman-cli [global flags] rm [flags] <container>... | -all\n",
+		"\nRemove containers\n",
+		"  -volumes\n",
+		"Examples:\n  podman-cli rm -host myserver -force web db\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printCommandHelp() missing %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "-retries") {
+		t.Errorf("printCommandHelp() lists global flags, got:\n%s", out.String())
+	}
+}
+
+func TestPrintCommandHelp_Unknown(t *testing.T) {
+	var out strings.Builder
+	if err := printCommandHelp(&out, "nonexistent"); err == nil {
+		t.Error("printCommandHelp() expected error for unknown command, got nil")
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	var out strings.Builder
+	printUsage(&out)
+
+	for _, want := range []string{"list_containers", "stop ", "-retries", "-dry-run"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printUsage() missing %q", want)
+		}
+	}
+	if strings.Contains(out.String(), completeCommandName) {
+		t.Errorf("printUsage() lists hidden %s", completeCommandName)
+	}
+}
+
+func TestNewRemoteCLI_Help(t *testing.T) {
+	if _, err := NewRemoteCLI([]string{"stop", "-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("NewRemoteCLI(stop -h) error = %v, want flag.ErrHelp", err)
+	}
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/config"
+)
+
+// defaultHookTimeout bounds the notification of a hook without a timeout
+// of its own.
+const defaultHookTimeout = 10 * time.Second
+
+// hookSummary is the JSON summary of a completed command sent to the
+// hooks of the configuration file. The arguments of the command are left
+// out, as they may hold secrets.
+type hookSummary struct {
+	// Text describes the outcome in a line, which Slack incoming webhooks
+	// post as is
+	Text     string    `json:"text"`
+	Command  string    `json:"command"`
+	Host     string    `json:"host,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	ExitCode int       `json:"exitCode"`
+	Success  bool      `json:"success"`
+	Version  string    `json:"version"`
+}
+
+// newHookSummary returns the summary of the named command, run on host
+// from start and exiting with code.
+func newHookSummary(name, host string, start time.Time, code int) hookSummary {
+	duration := time.Since(start).Round(time.Millisecond)
+	s := hookSummary{
+		Command:  name,
+		Host:     host,
+		Start:    start,
+		Duration: duration.String(),
+		ExitCode: code,
+		Success:  code == 0,
+		Version:  buildVersion().Version,
+	}
+	target := ""
+	if host != "" {
+		target = " on " + host
+	}
+	if s.Success {
+		s.Text = fmt.Sprintf("podman-cli %s%s succeeded in %s", name, target, duration)
+	} else {
+		s.Text = fmt.Sprintf("podman-cli %s%s failed with exit code %d after %s", name, target, code, duration)
+	}
+	return s
+}
+
+// notifyHooks sends the summary of the command, started at start and
+// exiting with code, to the hooks of the configuration file that match it.
+// Hooks are not notified of dry runs, replays and shell completion, and
+// their failures are logged without affecting the exit code.
+func (rc *RemoteCLI) notifyHooks(start time.Time, code int) {
+	if rc.name == completeCommandName || rc.dryRun != nil || rc.replay != nil {
+		return
+	}
+	f, err := config.Load(rc.opts.configFile)
+	if err != nil {
+		slog.Warn("Failed to load the hooks", "err", err)
+		return
+	}
+	var hooks []config.Hook
+	for _, h := range f.Hooks {
+		if h.Matches(rc.name, code == 0) {
+			hooks = append(hooks, h)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	summary, err := json.Marshal(newHookSummary(rc.name, rc.host, start, code))
+	if err != nil {
+		slog.Warn("Failed to encode the command summary", "err", err)
+		return
+	}
+	for _, h := range hooks {
+		if err := notifyHook(h, summary); err != nil {
+			slog.Warn("Hook failed", "hook", hookName(h), "err", err)
+		}
+	}
+}
+
+// notifyHook sends summary to the hook h: as the body of a POST request to
+// its URL, or on the standard input of its command.
+func notifyHook(h config.Hook, summary []byte) error {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if h.URL == "" {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", "-c", h.Command)
+		}
+		// Standard output is kept for the command's own output
+		cmd.Stdin = bytes.NewReader(summary)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		return cmd.Run()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(summary))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		// Without the URL, as hookName explains
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// hookName identifies h in diagnostics: by the host of its URL, whose path
+// and query often hold a secret token, or by its command.
+func hookName(h config.Hook) string {
+	if h.URL == "" {
+		return h.Command
+	}
+	if u, err := url.Parse(h.URL); err == nil {
+		return u.Host
+	}
+	return "webhook"
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHookSummary(t *testing.T) {
+	start := time.Now().Add(-1500 * time.Millisecond)
+	s := newHookSummary("run", "edge1", start, 0)
+	if !s.Success || s.ExitCode != 0 || !strings.HasPrefix(s.Text, "podman-cli run on edge1 succeeded in 1.5") {
+		t.Errorf("newHookSummary() = %+v, want a success on edge1", s)
+	}
+	s = newHookSummary("prefetch", "", start, 2)
+	if s.Success || !strings.HasPrefix(s.Text, "podman-cli prefetch failed with exit code 2 after ") {
+		t.Errorf("newHookSummary() = %+v, want a failure without host", s)
+	}
+}
+
+func TestNotifyHooks(t *testing.T) {
+	var got []hookSummary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected hook request %s %s %v", r.Method, r.URL, r.Header)
+		}
+		var s hookSummary
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			t.Errorf("hook request body invalid: %v", err)
+		}
+		got = append(got, s)
+	}))
+	defer server.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	config := `
+[[hooks]]
+url = "` + server.URL + `/hook"
+headers = { Authorization = "Bearer token" }
+on = "failure"
+
+[[hooks]]
+url = "` + server.URL + `/deploys"
+headers = { Authorization = "Bearer token" }
+commands = ["run"]
+`
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	rc := &RemoteCLI{name: "run", host: "edge1", opts: globalOptions{configFile: configFile}}
+
+	rc.notifyHooks(time.Now(), 0)
+	if len(got) != 1 || got[0].Command != "run" || got[0].Host != "edge1" || !got[0].Success {
+		t.Fatalf("hooks got %+v, want the success of run on the deploys hook", got)
+	}
+
+	got = nil
+	rc.name = "rm"
+	rc.notifyHooks(time.Now(), 1)
+	if len(got) != 1 || got[0].Command != "rm" || got[0].ExitCode != 1 {
+		t.Fatalf("hooks got %+v, want the failure of rm on the failure hook", got)
+	}
+
+	// Completion runs on every key press
+	got = nil
+	rc.name = completeCommandName
+	rc.notifyHooks(time.Now(), 1)
+	if len(got) != 0 {
+		t.Errorf("hooks got %+v, want none for completion", got)
+	}
+}
+
+func TestNotifyHook_Command(t *testing.T) {
+	if _, err := exec.LookPath("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh to run the hook")
+	}
+	out := filepath.Join(t.TempDir(), "summary.json")
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	config := "[[hooks]]\ncommand = \"cat > '" + out + "'\"\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	rc := &RemoteCLI{name: "ps", host: "edge1", opts: globalOptions{configFile: configFile}}
+
+	rc.notifyHooks(time.Now(), 0)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook command did not run: %v", err)
+	}
+	var s hookSummary
+	if err := json.Unmarshal(data, &s); err != nil || s.Command != "ps" || s.Host != "edge1" {
+		t.Errorf("hook command read %s (%v), want the summary of ps", data, err)
+	}
+}
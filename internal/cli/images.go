@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/term"
+)
+
+// imageArchiveFormats are the archive formats accepted by save_image.
+var imageArchiveFormats = map[string]bool{
+	"docker-archive": true,
+	"oci-archive":    true,
+}
+
+// newSaveImageCommand returns the "save_image" command, which streams an
+// image from the remote host as a tarball to a local file or stdout.
+func newSaveImageCommand(fs *flag.FlagSet) runFunc {
+	var output, format string
+	fs.StringVar(&output, "o", "", "Write the archive to this file instead of stdout")
+	fs.StringVar(&format, "format", "docker-archive", "Archive format: docker-archive or oci-archive")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("save_image: exactly one image name or ID is required")
+			return 1
+		}
+		if !imageArchiveFormats[format] {
+			slog.Error("save_image: invalid format", "format", format)
+			return 1
+		}
+		name := rc.args[0]
+
+		var out io.Writer = os.Stdout
+		if output == "" {
+			if term.IsTerminal(int(os.Stdout.Fd())) {
+				slog.Error("save_image: refusing to write an archive to a terminal (use -o)")
+				return 1
+			}
+		} else {
+			f, err := os.Create(output)
+			if err != nil {
+				slog.Error("save_image", "err", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+
+		query := url.Values{"format": {format}}
+		path := "/v3.0.0/libpod/images/" + url.PathEscape(name) + "/get"
+		pw := newProgressWriter(out, "Saving "+name, 0)
+		err := rc.transfer(http.MethodGet, path, query, nil, pw)
+		pw.Close()
+		if err != nil {
+			slog.Error("save_image", "target", name, "err", err)
+			if output != "" {
+				os.Remove(output)
+			}
+			return 1
+		}
+		return 0
+	}
+}
+
+// newLoadImageCommand returns the "load_image" command, which streams a
+// local image tarball, or stdin, to the remote host.
+func newLoadImageCommand(fs *flag.FlagSet) runFunc {
+	var input string
+	var compress bool
+	fs.StringVar(&input, "i", "", "Read the archive from this file instead of stdin")
+	fs.BoolVar(&compress, "compress", true, "Gzip the archive on the fly unless it is already compressed")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 0 {
+			slog.Error("load_image: unexpected arguments (use -i to specify the archive)", "args", rc.args)
+			return 1
+		}
+
+		var body io.Reader = os.Stdin
+		var size int64
+		if input != "" {
+			f, err := os.Open(input)
+			if err != nil {
+				slog.Error("load_image", "err", err)
+				return 1
+			}
+			defer f.Close()
+
+			if info, err := f.Stat(); err == nil {
+				size = info.Size()
+			}
+			body = f
+		}
+
+		body = newProgressReader(body, "Loading", size)
+		if compress {
+			body = client.Gzip(body)
+		}
+		if err := rc.transfer(http.MethodPost, "/v3.0.0/libpod/images/load", nil, body, os.Stdout); err != nil {
+			slog.Error("load_image", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// newCopyImageCommand returns the "copy_image" command, which streams an
+// image from one remote host directly into another without writing a
+// temporary file locally.
+func newCopyImageCommand(fs *flag.FlagSet) runFunc {
+	var from, to string
+	var compress bool
+	fs.StringVar(&from, "from", "", "Host to copy the image from")
+	fs.StringVar(&to, "to", "", "Host to copy the image to")
+	fs.BoolVar(&compress, "compress", false, "Gzip the archive sent to the destination host")
+
+	return func(rc *RemoteCLI) int {
+		if from == "" || to == "" {
+			slog.Error("copy_image: -from and -to are required")
+			return 1
+		}
+		if len(rc.args) != 1 {
+			slog.Error("copy_image: exactly one image name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		src, err := rc.forHost(from)
+		if err != nil {
+			slog.Error("copy_image", "err", err)
+			return 1
+		}
+		dst, err := rc.forHost(to)
+		if err != nil {
+			slog.Error("copy_image", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		srcSSH, srcHTTP, err := src.connect(ctx)
+		if err != nil {
+			slog.Error("copy_image: failed to connect", "host", from, "err", err)
+			return 1
+		}
+		defer srcSSH.Close()
+
+		dstSSH, dstHTTP, err := dst.connect(ctx)
+		if err != nil {
+			slog.Error("copy_image: failed to connect", "host", to, "err", err)
+			return 1
+		}
+		defer dstSSH.Close()
+
+		if err := copyImage(ctx, srcHTTP, dstHTTP, name, compress, os.Stdout); err != nil {
+			slog.Error("copy_image", "target", name, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// newRmImageCommand returns the "rm_image" command, which removes the
+// given images concurrently. Images used by containers are only removed
+// with -force, which removes those containers too.
+func newRmImageCommand(fs *flag.FlagSet) runFunc {
+	var force bool
+	fs.BoolVar(&force, "force", false, "Remove the images even if containers use them, removing those containers")
+	maxParallel := maxParallelFlag(fs)
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("rm_image: at least one image name or ID is required")
+			return 1
+		}
+		if !rc.confirmDestructive("Remove images " + strings.Join(rc.args, ", ")) {
+			slog.Error("rm_image: aborted; nothing was removed")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		query := url.Values{"force": {strconv.FormatBool(force)}}
+		return forEachTarget(ctx, "rm_image", rc.args, *maxParallel, os.Stdout, func(ctx context.Context, name string) error {
+			resp, err := apiRequest(ctx, httpClient, http.MethodDelete, "/v3.0.0/libpod/images/"+url.PathEscape(name), query, nil)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		})
+	}
+}
+
+// newImageTreeCommand returns the "image_tree" command, which prints the
+// layer hierarchy of a remote image with the size of each layer and the
+// images sharing it, as rendered by Podman.
+func newImageTreeCommand(fs *flag.FlagSet) runFunc {
+	var whatRequires bool
+	fs.BoolVar(&whatRequires, "whatrequires", false, "Show the images built on the image instead of its layers")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("image_tree: exactly one image name or ID is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		tree, err := imageTree(ctx, httpClient, name, whatRequires)
+		if err != nil {
+			slog.Error("image_tree", "target", name, "err", err)
+			return 1
+		}
+		if rc.opts.format != formatText {
+			enc := json.NewEncoder(os.Stdout)
+			if rc.opts.format == formatJSON {
+				enc.SetIndent("", "  ")
+			}
+			err = enc.Encode(map[string]string{"image": name, "tree": tree})
+		} else {
+			_, err = io.WriteString(os.Stdout, tree)
+		}
+		if err != nil {
+			slog.Error("image_tree", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// imageTree returns the layer tree of the named image as rendered by
+// Podman, or the tree of images built on it if whatRequires is set.
+func imageTree(ctx context.Context, httpClient *http.Client, name string, whatRequires bool) (string, error) {
+	var report struct {
+		Tree string `json:"Tree"`
+	}
+	query := url.Values{"whatrequires": {strconv.FormatBool(whatRequires)}}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/tree", query, &report); err != nil {
+		return "", err
+	}
+	if report.Tree != "" && !strings.HasSuffix(report.Tree, "\n") {
+		report.Tree += "\n"
+	}
+	return report.Tree, nil
+}
+
+// copyImage pipes the archive of the named image from the source API into
+// the load endpoint of the destination API, writing the load report to out.
+// If compress is set the archive is gzipped before it is sent.
+func copyImage(ctx context.Context, src, dst *http.Client, name string, compress bool, out io.Writer) error {
+	query := url.Values{"format": {"docker-archive"}}
+	resp, err := apiRequest(ctx, src, http.MethodGet, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/get", query, nil)
+	if err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := newProgressReader(resp.Body, "Copying "+name, 0)
+	if compress {
+		body = client.Gzip(body)
+	}
+
+	loadResp, err := apiRequest(ctx, dst, http.MethodPost, "/v3.0.0/libpod/images/load", nil, body)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	defer loadResp.Body.Close()
+
+	_, err = io.Copy(out, loadResp.Body)
+	return err
+}
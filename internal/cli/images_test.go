@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSaveImageCommand_ArgumentValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+	}{
+		{"no image", []string{"-o", "out.tar"}, nil},
+		{"invalid format", []string{"-o", "out.tar", "-format", "zip"}, []string{"alpine"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newTestCommand(t, newSaveImageCommand, tt.flags...)
+			if code := run(&RemoteCLI{args: tt.args}); code != 1 {
+				t.Errorf("save_image exit code = %d, want 1", code)
+			}
+		})
+	}
+}
+
+func TestLoadImageCommand_ArgumentValidation(t *testing.T) {
+	run := newTestCommand(t, newLoadImageCommand)
+	if code := run(&RemoteCLI{args: []string{"image.tar"}}); code != 1 {
+		t.Errorf("load_image with positional archive exit code = %d, want 1", code)
+	}
+
+	run = newTestCommand(t, newLoadImageCommand, "-i", t.TempDir()+"/missing.tar")
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("load_image with missing archive exit code = %d, want 1", code)
+	}
+}
+
+func TestCopyImage(t *testing.T) {
+	archive := strings.Repeat("layer-data", 100)
+
+	src := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/images/alpine:latest/get" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(archive))
+	}))
+
+	for _, compress := range []bool{false, true} {
+		var received string
+		dst := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body io.Reader = r.Body
+			if compress {
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("load body is not gzipped: %v", err)
+					return
+				}
+				body = gz
+			}
+			data, _ := io.ReadAll(body)
+			received = string(data)
+			w.Write([]byte(`{"Names":["alpine:latest"]}`))
+		}))
+
+		var out bytes.Buffer
+		if err := copyImage(context.Background(), src, dst, "alpine:latest", compress, &out); err != nil {
+			t.Fatalf("copyImage(compress=%v) unexpected error = %v", compress, err)
+		}
+
+		if received != archive {
+			t.Errorf("copyImage(compress=%v) destination received %d bytes, want %d", compress, len(received), len(archive))
+		}
+		if !strings.Contains(out.String(), "alpine:latest") {
+			t.Errorf("copyImage(compress=%v) output = %q, want load report", compress, out.String())
+		}
+	}
+}
+
+func TestCopyImage_SourceError(t *testing.T) {
+	src := newTestHTTPClient(t, http.NotFoundHandler())
+	dst := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("destination should not be contacted when the source fails")
+	}))
+
+	if err := copyImage(context.Background(), src, dst, "missing", false, io.Discard); err == nil {
+		t.Error("copyImage() expected error for missing image, got nil")
+	}
+}
+
+func TestNewRemoteCLI_CopyImageWithoutHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	args := []string{"copy_image", "-from", "testhost", "-to", "testhost", "alpine"}
+	cli, err := NewRemoteCLI(args)
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	dst, err := cli.forHost("testhost")
+	if err != nil {
+		t.Fatalf("forHost() unexpected error = %v", err)
+	}
+	if dst.addr != "test.example.com:22" {
+		t.Errorf("forHost() addr = %q, want %q", dst.addr, "test.example.com:22")
+	}
+}
+
+func TestImageTree(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/images/myapp:v2/tree" || r.URL.Query().Get("whatrequires") != "false" {
+			t.Errorf("request = %s, want the myapp:v2 tree", r.URL)
+		}
+		w.Write([]byte(`{"Tree":"Image ID: 1a2b3c\nTags:     [localhost/myapp:v2]\nSize:     84.5MB\nImage Layers\n├── ID: 9f8e Size: 7.3MB Top Layer of: [alpine:latest]\n└── ID: 7d6c Size: 77.2MB Top Layer of: [localhost/myapp:v2]"}`))
+	}))
+
+	tree, err := imageTree(context.Background(), httpClient, "myapp:v2", false)
+	if err != nil {
+		t.Fatalf("imageTree() unexpected error = %v", err)
+	}
+	if !strings.HasPrefix(tree, "Image ID: 1a2b3c\n") || !strings.HasSuffix(tree, "[localhost/myapp:v2]\n") {
+		t.Errorf("imageTree() = %q", tree)
+	}
+}
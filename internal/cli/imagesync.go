@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+)
+
+// Statuses of the images checked by image_sync_check.
+const (
+	imagePresent  = "present"
+	imageMissing  = "missing"
+	imageOutdated = "outdated"
+	imagePulled   = "pulled"
+)
+
+// desiredImage is an entry of an image_sync_check list: an image
+// reference, optionally pinned to the digest the remote host must have.
+type desiredImage struct {
+	Ref    string
+	Digest string
+}
+
+// imageCheck is the state of a desired image on the remote host.
+type imageCheck struct {
+	Image  desiredImage
+	Status string
+	Digest string // the remote digest, if the image is present
+}
+
+// newImageSyncCheckCommand returns the "image_sync_check" command, which
+// compares a local list of images with those on the remote host, to stage
+// the images of a deployment before it rolls out. With -pull the missing
+// and outdated images are pulled.
+func newImageSyncCheckCommand(fs *flag.FlagSet) runFunc {
+	var file, authFile string
+	var pull, tlsVerify bool
+	fs.StringVar(&file, "file", "", "File listing the images, one reference per line, optionally pinned with @sha256:<digest> (- for stdin)")
+	fs.BoolVar(&pull, "pull", false, "Pull the missing and outdated images")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file, with -pull")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when pulling")
+
+	return func(rc *RemoteCLI) int {
+		if file == "" || len(rc.args) > 0 {
+			slog.Error("image_sync_check: -file is required and no arguments are accepted")
+			return 1
+		}
+		images, err := readImageList(file)
+		if err != nil {
+			slog.Error("image_sync_check", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		checks := make([]imageCheck, len(images))
+		for i, img := range images {
+			if checks[i], err = checkImage(ctx, httpClient, img); err != nil {
+				slog.Error("image_sync_check", "target", img.Ref, "err", err)
+				return 1
+			}
+			if !pull || checks[i].Status == imagePresent {
+				continue
+			}
+
+			authHeader, err := registryAuth(authFile, img.Ref)
+			if err == nil {
+				err = pullImage(ctx, httpClient, img.pullRef(), tlsVerify, authHeader, io.Discard, nil)
+			}
+			if err != nil {
+				slog.Error("image_sync_check: pull failed", "target", img.Ref, "err", err)
+				continue
+			}
+			check, err := checkImage(ctx, httpClient, img)
+			if err != nil {
+				slog.Error("image_sync_check", "target", img.Ref, "err", err)
+				return 1
+			}
+			if check.Status == imagePresent {
+				check.Status = imagePulled
+			}
+			checks[i] = check
+		}
+
+		rows := make([]tuiRow, len(checks))
+		code := 0
+		for i, c := range checks {
+			rows[i] = tuiRow{id: c.Image.Ref, cols: []string{c.Image.Ref, c.Status, c.Digest}}
+			if c.Status == imageMissing || c.Status == imageOutdated {
+				code = 1
+			}
+		}
+		if err := writeTable(os.Stdout, rc.opts.format, []string{"IMAGE", "STATUS", "DIGEST"}, rows); err != nil {
+			slog.Error("image_sync_check", "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// readImageList reads the images listed in the file at path, or stdin
+// for -. Blank lines and lines starting with # are ignored.
+func readImageList(path string) ([]desiredImage, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var images []desiredImage
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		img, err := parseDesiredImage(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		images = append(images, img)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%s: no images listed", path)
+	}
+	return images, nil
+}
+
+// parseDesiredImage parses a reference such as nginx:1.25 or
+// nginx:1.25@sha256:<digest>.
+func parseDesiredImage(s string) (desiredImage, error) {
+	if strings.ContainsAny(s, " \t") {
+		return desiredImage{}, fmt.Errorf("invalid image %q: want one reference per line", s)
+	}
+	ref, digest, pinned := strings.Cut(s, "@")
+	if !pinned {
+		return desiredImage{Ref: s}, nil
+	}
+	algo, hex, _ := strings.Cut(digest, ":")
+	if ref == "" || algo != "sha256" || len(hex) != 64 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return desiredImage{}, fmt.Errorf("invalid image %q: want <image>@sha256:<64 hex digits>", s)
+	}
+	return desiredImage{Ref: ref, Digest: digest}, nil
+}
+
+// pullRef returns the reference to pull the image by: its tag, so that
+// the tag points at the pulled image, or its digest if it has no tag.
+func (img desiredImage) pullRef() string {
+	if img.Digest != "" && !hasTag(img.Ref) {
+		return img.Ref + "@" + img.Digest
+	}
+	return img.Ref
+}
+
+// hasTag reports whether ref names a tag, as in host:5000/app:v1.
+func hasTag(ref string) bool {
+	i := strings.LastIndex(ref, ":")
+	return i > strings.LastIndex(ref, "/")
+}
+
+// checkImage reports whether the remote host has img, and for a pinned
+// image whether its digest matches.
+func checkImage(ctx context.Context, httpClient *http.Client, img desiredImage) (imageCheck, error) {
+	check := imageCheck{Image: img}
+	var inspect struct {
+		Digest      string   `json:"Digest"`
+		RepoDigests []string `json:"RepoDigests"`
+	}
+	name := img.Ref
+	if img.Digest != "" && !hasTag(img.Ref) {
+		name = img.pullRef()
+	}
+	err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/json", nil, &inspect)
+	if isStatus(err, http.StatusNotFound) {
+		check.Status = imageMissing
+		return check, nil
+	}
+	if err != nil {
+		return check, err
+	}
+
+	check.Digest = inspect.Digest
+	check.Status = imagePresent
+	if img.Digest != "" && inspect.Digest != img.Digest && !slices.ContainsFunc(inspect.RepoDigests, func(d string) bool {
+		return strings.HasSuffix(d, "@"+img.Digest)
+	}) {
+		check.Status = imageOutdated
+	}
+	return check, nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestReadImageList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.txt")
+	list := "# edge release 42\nnginx:1.25\n\nregistry.local:5000/app:v2@" + testDigest + "\n  alpine@" + testDigest + "  \n"
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	images, err := readImageList(path)
+	if err != nil {
+		t.Fatalf("readImageList() unexpected error = %v", err)
+	}
+	want := []desiredImage{
+		{Ref: "nginx:1.25"},
+		{Ref: "registry.local:5000/app:v2", Digest: testDigest},
+		{Ref: "alpine", Digest: testDigest},
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("readImageList() = %+v, want %+v", images, want)
+	}
+	if images[1].pullRef() != "registry.local:5000/app:v2" || images[2].pullRef() != "alpine@"+testDigest {
+		t.Errorf("pullRef() = %q, %q", images[1].pullRef(), images[2].pullRef())
+	}
+
+	for _, line := range []string{"nginx@sha256:abc", "nginx@md5:" + testDigest[7:], "nginx latest"} {
+		if _, err := parseDesiredImage(line); err == nil {
+			t.Errorf("parseDesiredImage(%q) error = nil, want an error", line)
+		}
+	}
+	if err := os.WriteFile(path, []byte("# nothing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readImageList(path); err == nil || !strings.Contains(err.Error(), "no images") {
+		t.Errorf("readImageList() error = %v, want no images listed", err)
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/images/app:v2/json":
+			w.Write([]byte(`{"Digest":"sha256:aaaa","RepoDigests":["registry.local/app@` + testDigest + `"]}`))
+		case "/v3.0.0/libpod/images/app:v1/json":
+			w.Write([]byte(`{"Digest":"sha256:bbbb","RepoDigests":["registry.local/app@sha256:bbbb"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"image not known"}`))
+		}
+	}))
+
+	tests := []struct {
+		img  desiredImage
+		want string
+	}{
+		{desiredImage{Ref: "app:v1"}, imagePresent},
+		{desiredImage{Ref: "app:v2", Digest: testDigest}, imagePresent},
+		{desiredImage{Ref: "app:v1", Digest: testDigest}, imageOutdated},
+		{desiredImage{Ref: "app:v3"}, imageMissing},
+	}
+	for _, tt := range tests {
+		check, err := checkImage(context.Background(), httpClient, tt.img)
+		if err != nil {
+			t.Fatalf("checkImage(%+v) unexpected error = %v", tt.img, err)
+		}
+		if check.Status != tt.want {
+			t.Errorf("checkImage(%+v) = %s, want %s", tt.img, check.Status, tt.want)
+		}
+	}
+}
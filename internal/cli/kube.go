@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// playKubeReport is the subset of the play kube response reported to the
+// user.
+type playKubeReport struct {
+	Pods []struct {
+		ID         string   `json:"ID"`
+		Containers []string `json:"Containers"`
+		Logs       []string `json:"Logs"`
+	} `json:"Pods"`
+	Volumes []struct {
+		Name string `json:"Name"`
+	} `json:"Volumes"`
+	StopReport []struct {
+		Id string `json:"Id"`
+	} `json:"StopReport"`
+	RmReport []struct {
+		Id string `json:"Id"`
+	} `json:"RmReport"`
+}
+
+// newPlayKubeCommand returns the "play_kube" command, which creates the pods
+// and containers described by a local Kubernetes YAML file on the remote
+// host, or tears them down again with -down.
+func newPlayKubeCommand(fs *flag.FlagSet) runFunc {
+	var start, replace, down bool
+	var network string
+	fs.BoolVar(&start, "start", true, "Start the pods after creating them")
+	fs.StringVar(&network, "network", "", "Connect the pods to this network")
+	fs.BoolVar(&replace, "replace", false, "Replace existing pods and containers with the same names")
+	fs.BoolVar(&down, "down", false, "Stop and remove the pods described by the YAML file")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("play_kube: exactly one YAML file (or - for stdin) is required")
+			return 1
+		}
+
+		var yaml io.Reader = os.Stdin
+		if rc.args[0] != "-" {
+			f, err := os.Open(rc.args[0])
+			if err != nil {
+				slog.Error("play_kube", "err", err)
+				return 1
+			}
+			defer f.Close()
+			yaml = f
+		}
+
+		method := http.MethodPost
+		query := url.Values{}
+		if down {
+			method = http.MethodDelete
+		} else {
+			query.Set("start", strconv.FormatBool(start))
+			query.Set("replace", strconv.FormatBool(replace))
+			if network != "" {
+				query.Set("network", network)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		report, err := playKube(ctx, httpClient, method, query, yaml)
+		if err != nil {
+			slog.Error("play_kube", "err", err)
+			return 1
+		}
+		printPlayKubeReport(os.Stdout, report)
+		return 0
+	}
+}
+
+// playKube sends the YAML document to the play kube endpoint and decodes
+// the report.
+func playKube(ctx context.Context, httpClient *http.Client, method string, query url.Values, yaml io.Reader) (*playKubeReport, error) {
+	req, err := newAPIRequest(ctx, method, "/v3.0.0/libpod/play/kube", query, yaml)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	report := &playKubeReport{}
+	if err := json.NewDecoder(resp.Body).Decode(report); err != nil {
+		return nil, fmt.Errorf("decode report: %w", err)
+	}
+	return report, nil
+}
+
+// printPlayKubeReport writes the created or removed resources to out in the
+// same layout as podman play kube.
+func printPlayKubeReport(out io.Writer, report *playKubeReport) {
+	for _, pod := range report.Pods {
+		for _, line := range pod.Logs {
+			fmt.Fprint(out, line)
+		}
+		fmt.Fprintln(out, "Pod:")
+		fmt.Fprintln(out, pod.ID)
+		if len(pod.Containers) > 0 {
+			fmt.Fprintln(out, "Containers:")
+			for _, ctr := range pod.Containers {
+				fmt.Fprintln(out, ctr)
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	if len(report.Volumes) > 0 {
+		fmt.Fprintln(out, "Volumes:")
+		for _, vol := range report.Volumes {
+			fmt.Fprintln(out, vol.Name)
+		}
+	}
+
+	if len(report.StopReport) > 0 {
+		fmt.Fprintln(out, "Pods stopped:")
+		for _, r := range report.StopReport {
+			fmt.Fprintln(out, r.Id)
+		}
+	}
+	if len(report.RmReport) > 0 {
+		fmt.Fprintln(out, "Pods removed:")
+		for _, r := range report.RmReport {
+			fmt.Fprintln(out, r.Id)
+		}
+	}
+}
+
+// newGenerateKubeCommand returns the "generate_kube" command, which captures
+// pods or containers running on the remote host as Kubernetes YAML.
+func newGenerateKubeCommand(fs *flag.FlagSet) runFunc {
+	var file string
+	var service bool
+	fs.StringVar(&file, "f", "", "Write the YAML to this file instead of stdout")
+	fs.BoolVar(&service, "service", false, "Also generate a Kubernetes Service object")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("generate_kube: at least one pod or container name is required")
+			return 1
+		}
+
+		query := url.Values{"names": rc.args, "service": {strconv.FormatBool(service)}}
+
+		var out io.Writer = os.Stdout
+		if file != "" {
+			f, err := os.Create(file)
+			if err != nil {
+				slog.Error("generate_kube", "err", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+
+		if err := rc.transfer(http.MethodGet, "/v3.0.0/libpod/generate/kube", query, nil, out); err != nil {
+			slog.Error("generate_kube", "err", err)
+			if file != "" {
+				os.Remove(file)
+			}
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testPodYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: nginx
+    image: nginx
+`
+
+func TestPlayKube(t *testing.T) {
+	var gotBody, gotType, gotReplace string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		gotType = r.Header.Get("Content-Type")
+		gotReplace = r.URL.Query().Get("replace")
+		w.Write([]byte(`{"Pods":[{"ID":"pod123","Containers":["ctr1","ctr2"]}],"Volumes":[{"Name":"data"}]}`))
+	}))
+
+	query := url.Values{"replace": {"true"}}
+	report, err := playKube(context.Background(), httpClient, http.MethodPost, query, strings.NewReader(testPodYAML))
+	if err != nil {
+		t.Fatalf("playKube() unexpected error = %v", err)
+	}
+
+	if gotBody != testPodYAML || gotType != "application/yaml" || gotReplace != "true" {
+		t.Errorf("playKube() sent body=%q type=%q replace=%q", gotBody, gotType, gotReplace)
+	}
+
+	var out bytes.Buffer
+	printPlayKubeReport(&out, report)
+	want := "Pod:\npod123\nContainers:\nctr1\nctr2\n\nVolumes:\ndata\n"
+	if out.String() != want {
+		t.Errorf("printPlayKubeReport() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestPlayKube_Down(t *testing.T) {
+	var gotMethod string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		w.Write([]byte(`{"StopReport":[{"Id":"pod123"}],"RmReport":[{"Id":"pod123"}]}`))
+	}))
+
+	report, err := playKube(context.Background(), httpClient, http.MethodDelete, nil, strings.NewReader(testPodYAML))
+	if err != nil {
+		t.Fatalf("playKube() unexpected error = %v", err)
+	}
+	if gotMethod != http.MethodDelete {
+		t.Errorf("playKube() method = %s, want DELETE", gotMethod)
+	}
+
+	var out bytes.Buffer
+	printPlayKubeReport(&out, report)
+	if out.String() != "Pods stopped:\npod123\nPods removed:\npod123\n" {
+		t.Errorf("printPlayKubeReport() = %q", out.String())
+	}
+}
+
+func TestPlayKubeCommand_MissingFile(t *testing.T) {
+	run := newTestCommand(t, newPlayKubeCommand)
+	if code := run(&RemoteCLI{args: []string{t.TempDir() + "/missing.yaml"}}); code != 1 {
+		t.Errorf("play_kube with missing file exit code = %d, want 1", code)
+	}
+}
+
+func TestGenerateKubeCommand_RequiresNames(t *testing.T) {
+	run := newTestCommand(t, newGenerateKubeCommand, "-f", t.TempDir()+"/pod.yaml")
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("generate_kube without names exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,626 @@
+package cli
+
+import "flag"
+
+// runFunc executes a locally implemented command and returns its exit code.
+// Positional arguments following the command's flags are available in
+// rc.args.
+type runFunc func(rc *RemoteCLI) int
+
+// localCommand describes a command implemented by the CLI itself rather
+// than as a single Podman API request.
+type localCommand struct {
+	// setup registers the command's flags on fs and returns the function
+	// that runs it once the flags have been parsed.
+	setup func(fs *flag.FlagSet) runFunc
+
+	// noHost is set for commands that do not require -host, such as those
+	// selecting their remote hosts through their own flags.
+	noHost bool
+
+	// noDryRun is set for commands that cannot honor -dry-run, as they
+	// stream from or hand out the connection rather than sending
+	// individual requests.
+	noDryRun bool
+
+	// streaming is set for commands whose requests last as long as a
+	// stream or transfer, such as following events or uploading images,
+	// so that -request-timeout does not apply to them.
+	streaming bool
+
+	// hostArg is set for commands that take no arguments, so that a single
+	// argument names the host instead of -host, as in "ps myhost".
+	hostArg bool
+
+	// summary is a one-line description of the command, usage the
+	// synopsis of its arguments and examples complete invocations, all
+	// shown by the help command.
+	summary  string
+	usage    string
+	examples []string
+}
+
+// localCommands maps command names to their local implementation.
+var localCommands = map[string]localCommand{
+	"attach": {
+		setup:   newAttachCommand,
+		summary: "Attach the terminal to a running container",
+		usage:   "[-no-stdin] [-sig-proxy=false] <container>",
+		examples: []string{
+			"podman-cli attach -host myserver web",
+		},
+		noDryRun:  true,
+		streaming: true,
+	},
+	"build": {
+		setup:   newBuildCommand,
+		summary: "Build an image from a local context directory, or on a farm of hosts into a multi-platform manifest list",
+		usage:   "[-t <name>]... [-f <file>] [-build-arg <key>=<value>]... [-no-cache] [-farm <host>,...|@<group> -manifest <name>] <context-dir>",
+		examples: []string{
+			"podman-cli build -host myserver -t myapp:dev .",
+			"podman-cli build -farm @builders -manifest registry.example.com/myapp:latest .",
+		},
+		noHost:    true,
+		streaming: true,
+	},
+	"checkpoint": {
+		setup:   newCheckpointCommand,
+		summary: "Checkpoint a container, optionally exporting the archive locally",
+		usage:   "[flags] <container>",
+		examples: []string{
+			"podman-cli checkpoint -host myserver -export web.tar.gz web",
+		},
+		streaming: true,
+	},
+	"commit": {
+		setup:   newCommitCommand,
+		summary: "Create an image from a container's changes",
+		usage:   "[flags] <container> [<repo>[:<tag>]]",
+		examples: []string{
+			`podman-cli commit -host myserver -m "add config" web myapp:v2`,
+		},
+	},
+	"compose": {
+		setup:   newComposeCommand,
+		summary: "Deploy the services of a Compose file, or tear them down",
+		usage:   "[-f <file>] [-p <name>] [-volumes] [-authfile <path>] [-tls-verify=false] up|down|ps",
+		examples: []string{
+			"podman-cli compose -host myserver up",
+			"podman-cli compose -host myserver -f deploy/shop.yaml down -volumes",
+		},
+		streaming: true,
+	},
+	"copy_image": {
+		setup:   newCopyImageCommand,
+		summary: "Stream an image between two remote hosts",
+		usage:   "-from <host> -to <host> [flags] <image>",
+		examples: []string{
+			"podman-cli copy_image -from build1 -to edge1 -compress myapp:latest",
+		},
+		noHost:    true,
+		streaming: true,
+	},
+	"create_pod": {
+		setup:   newCreatePodCommand,
+		summary: "Create a pod with its infra container, shared namespaces and networking",
+		usage:   "[flags]",
+		examples: []string{
+			"podman-cli create_pod -host myserver -name shop -p 8080:80 -network backend",
+			"podman-cli create_pod -host myserver -name batch -share ipc,net -infra-image registry.example.com/pause:3.9",
+		},
+		hostArg: true,
+	},
+	"create": {
+		setup:   newCreateCommand,
+		summary: "Create a container from an image",
+		usage:   "[flags] <image> [<command> [<arg>...]]",
+		examples: []string{
+			"podman-cli create -host myserver -name web -restart always -memory 512m nginx",
+		},
+	},
+	"deploy": {
+		setup:   newDeployCommand,
+		summary: "Plan or apply a templated deployment file on several hosts",
+		usage:   "[-f <file>] [-hosts <hosts>] plan|apply",
+		examples: []string{
+			"podman-cli -config fleet.toml deploy -f deployment.yaml -hosts @fleet plan",
+			"podman-cli -config fleet.toml deploy -f deployment.yaml -hosts @fleet -yes apply",
+		},
+		noHost:    true,
+		streaming: true,
+	},
+	"diff_state": {
+		setup:   newDiffStateCommand,
+		summary: "List the drift of the remote host from a desired state file",
+		usage:   "[-f <file>]",
+		examples: []string{
+			"podman-cli diff_state -host edge1 -f deployment.yaml",
+		},
+	},
+	"doctor": {
+		setup:   newDoctorCommand,
+		summary: "Check that a host is ready for podman-cli and suggest fixes",
+		examples: []string{
+			"podman-cli doctor -host edge1",
+		},
+		noDryRun: true,
+		hostArg:  true,
+	},
+	"events": {
+		setup:     newEventsCommand,
+		summary:   "Stream Podman events, resuming after a dropped connection",
+		usage:     "[flags]",
+		noDryRun:  true,
+		streaming: true,
+		hostArg:   true,
+	},
+	"exec": {
+		setup:   newExecCommand,
+		summary: "Run a command in a running container",
+		usage:   "[-i] [-t] [-d [-output <file>]] [-e <key>=<value>]... [-u <user>] [-w <dir>] <container> <command> [<arg>...]",
+		examples: []string{
+			"podman-cli exec -host myserver web cat /etc/os-release",
+			"podman-cli exec -host myserver -i -t web vim /etc/nginx/nginx.conf",
+			"cat data.sql | podman-cli exec -host myserver -i db psql",
+			"podman-cli exec -host myserver -d -output /var/log/vacuum.log db vacuumdb --all",
+		},
+		noDryRun:  true,
+		streaming: true,
+	},
+	"exec_inspect": {
+		setup:   newExecInspectCommand,
+		summary: "Show the status and exit code of exec sessions",
+		usage:   "<exec-id>...",
+		examples: []string{
+			"podman-cli exec_inspect -host myserver 6f2a1c9d0b3e",
+		},
+	},
+	"forward": {
+		setup:   newForwardCommand,
+		summary: "Proxy a local socket or TCP address to the remote Podman socket",
+		usage:   "-listen <path|addr>",
+		examples: []string{
+			"podman-cli forward -host myserver -listen /tmp/podman-remote.sock",
+		},
+		noDryRun: true,
+		hostArg:  true,
+	},
+	"gateway": {
+		setup:   newGatewayCommand,
+		summary: "Serve the remote Podman API over HTTP on a local address",
+		usage:   "-listen unix://<path>|<addr> [-allow <prefix>]... [-token <token>]",
+		examples: []string{
+			"podman-cli gateway -host myserver -listen unix:///run/user/1000/podman-gateway.sock",
+			"podman-cli gateway -host myserver -listen 127.0.0.1:8080 -token \"$(openssl rand -hex 16)\" -allow /libpod/containers -allow /containers",
+		},
+		noDryRun:  true,
+		streaming: true,
+		hostArg:   true,
+	},
+	"generate_kube": {
+		setup:   newGenerateKubeCommand,
+		summary: "Capture pods or containers as Kubernetes YAML",
+		usage:   "[flags] <pod|container>...",
+	},
+	"generate_systemd": {
+		setup:   newGenerateSystemdCommand,
+		summary: "Generate systemd units or Quadlet files for containers",
+		usage:   "[flags] <container>...",
+		examples: []string{
+			"podman-cli generate_systemd -host myserver -quadlet -install web",
+		},
+	},
+	"grep_logs": {
+		setup:   newGrepLogsCommand,
+		summary: "Search the recent logs of containers on several hosts for a regular expression",
+		usage:   "-hosts <host>,...|@<group> [-since <time>] [-filter <key>=<value>]... [-i] [-timestamps] [-max-parallel <n>] <pattern>",
+		examples: []string{
+			"podman-cli grep_logs -hosts @fleet -since 1h 'timeout|refused'",
+			"podman-cli grep_logs -hosts edge1,edge2 -filter label=app=web -i -t 'status=5[0-9][0-9]'",
+		},
+		noHost:    true,
+		streaming: true,
+	},
+	"healthcheck_run": {
+		setup:   newHealthcheckRunCommand,
+		summary: "Run a container's healthcheck and print its status",
+		usage:   "<container>",
+	},
+	"image_sync_check": {
+		setup:   newImageSyncCheckCommand,
+		summary: "Check which images of a list are missing or outdated, optionally pulling them",
+		usage:   "-file <file|-> [-pull] [-authfile <file>] [-tls-verify=false]",
+		examples: []string{
+			"podman-cli image_sync_check -host edge1 -file images.txt",
+			"podman-cli image_sync_check -host edge1 -file images.txt -pull",
+		},
+		streaming: true,
+	},
+	"image_tree": {
+		setup:   newImageTreeCommand,
+		summary: "Show an image's layers with their sizes and the images sharing them",
+		usage:   "[-whatrequires] <image>",
+		examples: []string{
+			"podman-cli image_tree -host myserver myapp:latest",
+		},
+	},
+	"init_container": {
+		setup:   newInitCommand,
+		summary: "Initialize containers without starting them",
+		usage:   "<container>...",
+	},
+	"inspect": {
+		setup:   newInspectCommand,
+		summary: "Print container inspect data as JSON",
+		usage:   "<container>...",
+	},
+	"load_image": {
+		setup:   newLoadImageCommand,
+		summary: "Load a local image archive on the remote host",
+		usage:   "[-i <file>]",
+		examples: []string{
+			"podman-cli load_image -host myserver -i myapp.tar",
+		},
+		streaming: true,
+	},
+	"login": {
+		setup:   newLoginCommand,
+		summary: "Store registry credentials",
+		usage:   "[flags] <registry>",
+		examples: []string{
+			`echo "$TOKEN" | podman-cli login -u ci -password-stdin quay.io`,
+		},
+		noHost: true,
+	},
+	"logout": {
+		setup:   newLogoutCommand,
+		summary: "Remove stored registry credentials",
+		usage:   "[-a] [<registry>]",
+		noHost:  true,
+	},
+	"logs": {
+		setup:   newLogsCommand,
+		summary: "Print the output of containers, interleaving several",
+		usage:   "[-f] [-since <time>] [-until <time>] [-tail <n>] [-t [-timezone <zone>]] [-filter <key>=<value>]... [<container>...]",
+		examples: []string{
+			"podman-cli logs -host myserver -since 1h -tail 100 web",
+			"podman-cli logs -host myserver -f -filter label=app=web",
+			"podman-cli logs -host myserver -t -timezone UTC -since 2024-05-01T08:00:00Z -until 2024-05-01T09:00:00Z web",
+		},
+		streaming: true,
+	},
+	"mock_server": {
+		setup:   newMockServerCommand,
+		summary: "Serve an in-memory Podman API over SSH locally, to try the CLI or test scripts",
+		usage:   "[-listen <addr>] [-unix <path>] [-fixtures <file>]",
+		examples: []string{
+			"podman-cli mock_server",
+			"podman-cli mock_server -listen 127.0.0.1:2222 -fixtures fixtures.json",
+		},
+		noHost:    true,
+		noDryRun:  true,
+		streaming: true,
+	},
+	"mount_container": {
+		setup:   newMountCommand,
+		summary: "Mount a container's root filesystem and print its path",
+		usage:   "<container>",
+	},
+	"pause": {
+		setup:   newPauseCommand("pause"),
+		summary: "Pause containers or pods",
+		usage:   "[-pod] <target>...",
+	},
+	"play_kube": {
+		setup:   newPlayKubeCommand,
+		summary: "Create or tear down pods from a Kubernetes YAML file",
+		usage:   "[flags] <file.yaml|->",
+		examples: []string{
+			"podman-cli play_kube -host myserver -replace pod.yaml",
+		},
+		streaming: true,
+	},
+	"pod_rm": {
+		setup:   newPodRmCommand,
+		summary: "Remove pods and their containers",
+		usage:   "[-force] <pod>...",
+		examples: []string{
+			"podman-cli pod_rm -host myserver -force shop",
+		},
+	},
+	"port": {
+		setup:   newPortCommand,
+		summary: "Print published port mappings",
+		usage:   "[<container>]",
+	},
+	"prefetch": {
+		setup:   newPrefetchCommand,
+		summary: "Pull images on several hosts in parallel and report the outcome per host",
+		usage:   "-hosts <host>,...|@<group> [-max-parallel <n>] [-skip-existing] [-verify <policy.json>] <image>...",
+		examples: []string{
+			"podman-cli prefetch -hosts @fleet -skip-existing myapp:v2 redis:7",
+			"podman-cli prefetch -hosts edge1,edge2 -max-parallel 2 myapp:v2",
+			"podman-cli prefetch -hosts @fleet -verify policy.json quay.io/team/app:v1",
+		},
+		noHost:    true,
+		noDryRun:  true,
+		streaming: true,
+	},
+	"prune": {
+		setup:   newPruneCommand,
+		summary: "Remove stopped containers, unused pods and networks, and dangling images",
+		usage:   "[-all] [-volumes]",
+		examples: []string{
+			"podman-cli prune -host myserver -all -volumes",
+			"podman-cli prune -host myserver -yes",
+		},
+	},
+	"ps": {
+		setup:   newPsCommand,
+		summary: "List containers with their health status",
+		usage:   "[-a] [-filter <key>=<value>]...",
+		examples: []string{
+			"podman-cli ps -host myserver -a",
+			"podman-cli ps -host myserver -filter label=owner=fleet",
+		},
+		hostArg: true,
+	},
+	"pull_file": {
+		setup:   newPullFileCommand,
+		summary: "Copy a file of the remote host to a local file over SSH",
+		usage:   "<remote-path> [<local-file>|-]",
+		examples: []string{
+			"podman-cli pull_file -host myserver ~/.config/containers/systemd/web.container",
+		},
+		noDryRun: true,
+	},
+	"pull_image": {
+		setup:   newPullImageCommand,
+		summary: "Pull an image on the remote host",
+		usage:   "[flags] <image>",
+		examples: []string{
+			"podman-cli pull_image -host myserver docker.io/library/alpine:latest",
+			"podman-cli pull_image -host myserver -verify /etc/containers/policy.json quay.io/team/app:v1",
+		},
+		streaming: true,
+	},
+	"push_file": {
+		setup:   newPushFileCommand,
+		summary: "Copy a local file to the remote host over SSH",
+		usage:   "[-mode <perm>] <local-file>|- <remote-path>",
+		examples: []string{
+			"podman-cli push_file -host myserver web.container ~/.config/containers/systemd/",
+			"podman-cli push_file -host myserver -mode 0600 app.env /srv/app/app.env",
+		},
+		noDryRun: true,
+	},
+	"push_image": {
+		setup:     newPushImageCommand,
+		summary:   "Push an image from the remote host",
+		usage:     "[flags] <image>",
+		streaming: true,
+	},
+	"rename": {
+		setup:   newRenameCommand,
+		summary: "Rename a container",
+		usage:   "<container> <new-name>",
+	},
+	"report": {
+		setup:   newReportCommand,
+		summary: "Collect host info, containers, images, disk usage and stats into one document",
+		usage:   "[-o <file>]",
+		examples: []string{
+			"podman-cli report -host edge1 -o edge1-report.md",
+			"podman-cli report -host edge1 -format json -o edge1-report.json",
+		},
+		hostArg: true,
+	},
+	"restart": {
+		setup:   newStopCommand("restart"),
+		summary: "Restart containers",
+		usage:   "[-time <seconds>] <container>...",
+	},
+	"restore": {
+		setup:     newRestoreCommand,
+		summary:   "Restore a checkpointed or imported container",
+		usage:     "[flags] [<container>]",
+		streaming: true,
+	},
+	"rm": {
+		setup:   newRmCommand,
+		summary: "Remove containers",
+		usage:   "[flags] <container>... | -all",
+		examples: []string{
+			"podman-cli rm -host myserver -force web db",
+			"podman-cli rm -host myserver -all",
+		},
+	},
+	"rm_container": {
+		setup:   newRmCommand,
+		summary: "Remove containers (same as rm)",
+		usage:   "[flags] <container>... | -all",
+	},
+	"rm_image": {
+		setup:   newRmImageCommand,
+		summary: "Remove images",
+		usage:   "[-force] <image>...",
+		examples: []string{
+			"podman-cli rmi -host myserver myapp:old",
+		},
+	},
+	"run": {
+		setup:   newRunCommand,
+		summary: "Create and start a container in the background",
+		usage:   "[flags] <image> [<command> [<arg>...]]",
+		examples: []string{
+			"podman-cli run -host myserver -name web -cpus 1.5 -pids-limit 200 -cap-drop ALL -cap-add NET_BIND_SERVICE nginx",
+			"podman-cli run -host myserver -name api -network backend -ip 10.89.0.10 -p 127.0.0.1:8080:80 -add-host db:10.89.0.5 myapp",
+			"podman-cli run -host myserver -rm -env-file migrate.env myapp ./migrate up",
+		},
+	},
+	"save_image": {
+		setup:   newSaveImageCommand,
+		summary: "Stream an image archive from the remote host",
+		usage:   "[flags] <image>",
+		examples: []string{
+			"podman-cli save_image -host myserver -o myapp.tar myapp:latest",
+		},
+		streaming: true,
+	},
+	"scan": {
+		setup:   newScanCommand,
+		summary: "Scan an image for vulnerabilities with a local scanner such as trivy or grype",
+		usage:   "[-scanner trivy|grype | -scanner-cmd <command>] [-by-digest] <image> [-- <scanner argument>...]",
+		examples: []string{
+			"podman-cli scan -host myserver nginx:1.27 -- --severity HIGH,CRITICAL --exit-code 1",
+			"podman-cli scan -host myserver -scanner grype -by-digest registry.example.com/app:2.3",
+		},
+		streaming: true,
+	},
+	"schedule": {
+		setup:   newScheduleCommand,
+		summary: "Run commands against hosts on cron schedules, as a fleet maintenance agent",
+		usage:   "-jobs <jobs.yaml> [-log-dir <dir>] [-listen unix://<path>|<addr>] [-check]",
+		examples: []string{
+			"podman-cli schedule -jobs jobs.yaml -log-dir /var/log/podman-cli -listen 127.0.0.1:9090",
+			"podman-cli schedule -jobs jobs.yaml -check",
+		},
+		noHost:    true,
+		noDryRun:  true,
+		streaming: true,
+	},
+	"service": {
+		setup:   newServiceCommand,
+		summary: "Start, stop or show the systemd units running containers",
+		usage:   "[-scope user|system] start|stop|status <unit>...",
+		examples: []string{
+			"podman-cli service -host myserver status web",
+			"podman-cli service -host myserver -scope system stop container-db.service",
+		},
+		noDryRun: true,
+	},
+	"snapshot": {
+		setup:   newSnapshotCommand,
+		summary: "Save the container definitions of the host locally, or recreate them from a snapshot",
+		usage:   "[-f <file>] save|restore [<container>...]",
+		examples: []string{
+			"podman-cli snapshot -host myserver save",
+			"podman-cli snapshot -host myserver restore -f snapshot-myserver-20260101-120000.json web",
+		},
+		streaming: true,
+	},
+	"ssh_exec": {
+		setup:   newSSHExecCommand,
+		summary: "Run a shell command on the remote host over SSH, outside of the Podman API",
+		usage:   "[-i] [--] <command> [<arg>...]",
+		examples: []string{
+			"podman-cli ssh_exec -host myserver -- df -h /var/lib/containers",
+			"podman-cli ssh_exec -host myserver -- systemctl --user restart podman.socket",
+		},
+		noDryRun: true,
+	},
+	"start": {
+		setup:   newLifecycleCommand("start"),
+		summary: "Start containers",
+		usage:   "<container>...",
+	},
+	"status": {
+		setup:   newStatusCommand,
+		summary: "Print a health matrix of several hosts, checked in parallel",
+		usage:   "-hosts <host>,...|@<group> [-disk-threshold <percent>]",
+		examples: []string{
+			"podman-cli -config fleet.toml status -hosts @fleet",
+		},
+		noHost: true,
+	},
+	"stop": {
+		setup:   newStopCommand("stop"),
+		summary: "Stop containers",
+		usage:   "[-time <seconds>] <container>...",
+		examples: []string{
+			"podman-cli stop -host myserver web db",
+			"podman-cli stop -host myserver -time 60 db",
+		},
+	},
+	"system_reset": {
+		setup:   newSystemResetCommand,
+		summary: "Remove all containers, pods, images, volumes, networks and secrets",
+		usage:   "[-confirm <host>]",
+		examples: []string{
+			"podman-cli system_reset -host test-device-3",
+			"podman-cli system_reset -host test-device-3 -confirm test-device-3",
+		},
+	},
+	"tui": {
+		setup:    newTUICommand,
+		summary:  "Terminal dashboard of containers, pods and images",
+		usage:    "[-interval <duration>]",
+		noDryRun: true,
+		hostArg:  true,
+	},
+	"unmount_container": {
+		setup:   newUnmountCommand,
+		summary: "Unmount containers' root filesystems",
+		usage:   "<container>...",
+	},
+	"unpause": {
+		setup:   newPauseCommand("unpause"),
+		summary: "Unpause containers or pods",
+		usage:   "[-pod] <target>...",
+	},
+	"update": {
+		setup:   newUpdateCommand,
+		summary: "Change a running container's resource limits",
+		usage:   "[flags] <container>",
+		examples: []string{
+			"podman-cli update -host myserver -memory 512m -cpus 1.5 web",
+		},
+	},
+	"version": {
+		setup:   newVersionCommand,
+		summary: "Show the version of podman-cli and, with -host, of Podman on the host",
+		examples: []string{
+			"podman-cli version",
+			"podman-cli version -host myserver",
+		},
+		noHost:   true,
+		noDryRun: true,
+		hostArg:  true,
+	},
+	"volume_export": {
+		setup:   newVolumeExportCommand,
+		summary: "Stream the content of a volume from the remote host as a tarball",
+		usage:   "[flags] <volume>",
+		examples: []string{
+			"podman-cli volume_export -host myserver -o pgdata.tar pgdata",
+		},
+		streaming: true,
+	},
+	"volume_import": {
+		setup:   newVolumeImportCommand,
+		summary: "Extract a local tarball into a volume on the remote host",
+		usage:   "[flags] <volume> [<file>]",
+		examples: []string{
+			"podman-cli volume_import -host myserver pgdata pgdata.tar",
+		},
+		streaming: true,
+	},
+	"wait": {
+		setup:     newWaitCommand,
+		summary:   "Wait for a container condition and exit with its exit code",
+		usage:     "[-condition running|stopped|exited] <container>",
+		streaming: true,
+	},
+	"watchdog": {
+		setup:   newWatchdogCommand,
+		summary: "Monitor a container and recreate it on a standby host when it keeps failing",
+		usage:   "-from <host> -to <host> -container <name> [flags]",
+		examples: []string{
+			"podman-cli watchdog -from edge1 -to edge2 -container web",
+			"podman-cli watchdog -from edge1 -to edge2 -container web -interval 30s -failures 5 -volumes",
+		},
+		noHost:    true,
+		noDryRun:  true,
+		streaming: true,
+	},
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// logOutput is where diagnostics are written. It is always stderr, so that
+// stdout only carries command output, except while the shell command has
+// the terminal in raw mode.
+var logOutput io.Writer = os.Stderr
+
+// logWriter writes to the current logOutput.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	return logOutput.Write(p)
+}
+
+// logLevel returns the level selected by the -log-level, -quiet and
+// -verbose flags. -quiet only lets errors through and -verbose, as well as
+// -debug, enables debug messages; any of them overrides -log-level.
+func (o *globalOptions) logLevel() (slog.Level, error) {
+	if o.quiet && (o.verbose || o.debug) {
+		return 0, fmt.Errorf("-quiet cannot be combined with -verbose or -debug")
+	}
+	switch {
+	case o.quiet:
+		return slog.LevelError, nil
+	case o.verbose, o.debug:
+		return slog.LevelDebug, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.level)); err != nil {
+		return 0, fmt.Errorf("invalid -log-level %q (use debug, info, warn or error)", o.level)
+	}
+	return level, nil
+}
+
+// newLogger returns a logger writing diagnostics at or above level to w as
+// key=value pairs. Timestamps are left out as messages are read as they
+// happen.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, handlerOptions(level)))
+}
+
+// newJSONLogger is like newLogger, but writes each diagnostic as a JSON
+// object, for the JSON output formats.
+func newJSONLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, handlerOptions(level)))
+}
+
+func handlerOptions(level slog.Level) *slog.HandlerOptions {
+	return &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+}
+
+// errorLog holds back the error records logged while a command runs with
+// a JSON output format, so that the last one can be reported as the
+// command's failure (see RemoteCLI.reportFailure) instead of as a log line.
+type errorLog struct {
+	mu      sync.Mutex
+	records []heldRecord
+}
+
+// heldRecord is an error record and the handler it would have been
+// written with.
+type heldRecord struct {
+	handler slog.Handler
+	record  slog.Record
+}
+
+// errorHandler is a slog.Handler passing error records to log instead of
+// handling them.
+type errorHandler struct {
+	slog.Handler
+	log *errorLog
+}
+
+func (h errorHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError {
+		return h.Handler.Handle(ctx, r)
+	}
+	h.log.mu.Lock()
+	defer h.log.mu.Unlock()
+	h.log.records = append(h.log.records, heldRecord{h.Handler, r.Clone()})
+	return nil
+}
+
+func (h errorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return errorHandler{h.Handler.WithAttrs(attrs), h.log}
+}
+
+func (h errorHandler) WithGroup(name string) slog.Handler {
+	return errorHandler{h.Handler.WithGroup(name), h.log}
+}
+
+// take returns the held records and forgets them.
+func (l *errorLog) take() []heldRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := l.records
+	l.records = nil
+	return records
+}
+
+// failureReport is written to stderr when a command fails with a JSON
+// output format.
+type failureReport struct {
+	Error    map[string]any `json:"error"`
+	ExitCode int            `json:"exitCode"`
+	Host     string         `json:"host,omitempty"`
+	Command  string         `json:"command"`
+}
+
+// reportFailure writes the errors held back while the command ran. If it
+// failed, the last one is written as a failureReport with its exit code;
+// the others are logged as they would have been.
+func (rc *RemoteCLI) reportFailure(code int) {
+	if rc.errors == nil {
+		return
+	}
+	records := rc.errors.take()
+	var last *slog.Record
+	if code != 0 && len(records) > 0 {
+		last = &records[len(records)-1].record
+		records = records[:len(records)-1]
+	}
+	for _, held := range records {
+		held.handler.Handle(context.Background(), held.record)
+	}
+	if code == 0 {
+		return
+	}
+
+	report := failureReport{
+		Error:    map[string]any{"message": "command failed"},
+		ExitCode: code,
+		Host:     rc.host,
+		Command:  rc.name,
+	}
+	if last != nil {
+		report.Error = recordFields(*last)
+	}
+	json.NewEncoder(logOutput).Encode(report)
+}
+
+// ErrReported wraps the errors of NewRemoteCLI that were already written to
+// stderr as a failure report, which callers should not print again.
+var ErrReported = errors.New("failure already reported")
+
+// reportSetupFailure reports err, which kept the command from starting, as
+// reportFailure reports the failure of a command, along with the errors
+// held in errLog if not nil. It returns err wrapped with ErrReported.
+func reportSetupFailure(name, host string, errLog *errorLog, err error) error {
+	if errLog == nil {
+		errLog = &errorLog{}
+	}
+	logger := slog.New(errorHandler{newJSONLogger(logWriter{}, slog.LevelError).Handler(), errLog})
+	logger.Error("failed to initialize CLI", "err", err)
+	rc := &RemoteCLI{name: name, host: host, errors: errLog}
+	rc.reportFailure(1)
+	return fmt.Errorf("%w: %w", ErrReported, err)
+}
+
+// recordFields returns the message and attributes of r as JSON fields.
+// Errors are reported by their message.
+func recordFields(r slog.Record) map[string]any {
+	fields := map[string]any{"message": r.Message}
+	r.Attrs(func(a slog.Attr) bool {
+		fields[a.Key] = attrValue(a.Value)
+		return true
+	})
+	return fields
+}
+
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := map[string]any{}
+		for _, a := range v.Group() {
+			group[a.Key] = attrValue(a.Value)
+		}
+		return group
+	}
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	if _, err := json.Marshal(v.Any()); err != nil {
+		return v.String()
+	}
+	return v.Any()
+}
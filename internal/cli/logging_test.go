@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    globalOptions
+		want    slog.Level
+		wantErr bool
+	}{
+		{"default", globalOptions{level: "info"}, slog.LevelInfo, false},
+		{"debug", globalOptions{level: "debug"}, slog.LevelDebug, false},
+		{"upper case", globalOptions{level: "WARN"}, slog.LevelWarn, false},
+		{"quiet", globalOptions{level: "debug", quiet: true}, slog.LevelError, false},
+		{"verbose", globalOptions{level: "error", verbose: true}, slog.LevelDebug, false},
+		{"quiet and verbose", globalOptions{level: "info", quiet: true, verbose: true}, 0, true},
+		{"invalid", globalOptions{level: "loud"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.logLevel()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("logLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("logLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var out strings.Builder
+	logger := newLogger(&out, slog.LevelWarn)
+
+	logger.Info("hidden")
+	logger.Error("rm", "target", "web", "err", "no such container")
+
+	want := "level=ERROR msg=rm target=web err=\"no such container\"\n"
+	if out.String() != want {
+		t.Errorf("newLogger() output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestReportFailure(t *testing.T) {
+	var out strings.Builder
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = &out
+
+	rc := &RemoteCLI{name: "pull_image", host: "edge1", errors: &errorLog{}}
+	logger := slog.New(errorHandler{newJSONLogger(logWriter{}, slog.LevelInfo).Handler(), rc.errors})
+	logger.Warn("retrying")
+	logger.Error("registry", "err", errors.New("timeout"))
+	logger.Error("pull_image", "target", "app", "err", errors.New("unauthorized"))
+
+	if !strings.Contains(out.String(), `"msg":"retrying"`) || strings.Contains(out.String(), "unauthorized") {
+		t.Fatalf("errors were not held back: %q", out.String())
+	}
+	out.Reset()
+	rc.reportFailure(1)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"err":"timeout"`) {
+		t.Fatalf("reportFailure() output = %q, want the earlier error then the report", out.String())
+	}
+	want := `{"error":{"err":"unauthorized","message":"pull_image","target":"app"},"exitCode":1,"host":"edge1","command":"pull_image"}`
+	if lines[1] != want {
+		t.Errorf("reportFailure() = %s, want %s", lines[1], want)
+	}
+
+	// Without held errors the exit code is still reported
+	out.Reset()
+	rc.reportFailure(2)
+	if want := `{"error":{"message":"command failed"},"exitCode":2,"host":"edge1","command":"pull_image"}` + "\n"; out.String() != want {
+		t.Errorf("reportFailure() = %q, want %q", out.String(), want)
+	}
+
+	// Successful commands only log what was held
+	out.Reset()
+	logger.Error("cleanup", "err", errors.New("busy"))
+	rc.reportFailure(0)
+	if strings.Contains(out.String(), "exitCode") || !strings.Contains(out.String(), `"msg":"cleanup"`) {
+		t.Errorf("reportFailure(0) = %q, want the held error logged", out.String())
+	}
+}
+
+func TestNewRemoteCLI_ReportsFailure(t *testing.T) {
+	var out strings.Builder
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = &out
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	setHomeDir(t, t.TempDir())
+
+	_, err := NewRemoteCLI([]string{"-format", "json", "-host", "edge1", "bogus"})
+	if !errors.Is(err, ErrReported) {
+		t.Fatalf("NewRemoteCLI() error = %v, want ErrReported", err)
+	}
+	want := `{"error":{"err":"invalid command: bogus (run \"commands\" for a list)","message":"failed to initialize CLI"},"exitCode":1,"host":"edge1","command":"bogus"}` + "\n"
+	if out.String() != want {
+		t.Errorf("NewRemoteCLI() reported %q, want %q", out.String(), want)
+	}
+
+	// Text output leaves printing the error to the caller
+	out.Reset()
+	_, err = NewRemoteCLI([]string{"-host", "edge1", "bogus"})
+	if err == nil || errors.Is(err, ErrReported) || out.Len() > 0 {
+		t.Errorf("NewRemoteCLI() error = %v with output %q, want an unreported error", err, out.String())
+	}
+}
@@ -0,0 +1,449 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// newLogsCommand returns the "logs" command, which prints the output of
+// remote containers. The time window and tail options are passed on to
+// Podman; timestamps are requested from Podman in UTC and rendered in the
+// -timezone zone.
+//
+// The containers are given by name or selected with -filter. The logs of
+// several containers are streamed concurrently over the one SSH
+// connection and interleaved line by line, each prefixed with the
+// container's name. Followed logs are resumed, from the last line
+// written, when the connection drops.
+func newLogsCommand(fs *flag.FlagSet) runFunc {
+	var since, until, tail, timezone string
+	var follow, timestamps bool
+	var filters stringsFlag
+	fs.BoolVar(&follow, "follow", false, "Keep printing output as the containers write it")
+	fs.BoolVar(&follow, "f", false, "Shorthand for -follow")
+	fs.StringVar(&since, "since", "", "Only show output since this time: RFC 3339 timestamp, Unix time or duration before now such as 10m")
+	fs.StringVar(&until, "until", "", "Only show output until this time, in the same forms as -since")
+	fs.StringVar(&tail, "tail", "all", "Number of lines to show from the end of the output, or all")
+	fs.BoolVar(&timestamps, "timestamps", false, "Prefix each line with the time it was written")
+	fs.BoolVar(&timestamps, "t", false, "Shorthand for -timestamps")
+	fs.StringVar(&timezone, "timezone", "local", "Time zone of -timestamps: local, UTC or an IANA name such as Europe/Berlin")
+	fs.Var(&filters, "filter", "Also show the logs of the containers matching key=value, such as label=app=web (repeatable)")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 && len(filters) == 0 {
+			slog.Error("logs: a container name or ID, or -filter, is required")
+			return 1
+		}
+
+		query, err := logsQuery(since, until, tail, time.Now())
+		if err != nil {
+			slog.Error("logs", "err", err)
+			return 1
+		}
+		query.Set("follow", strconv.FormatBool(follow))
+		query.Set("timestamps", strconv.FormatBool(timestamps))
+		var loc *time.Location
+		if timestamps {
+			if loc, err = parseTimezone(timezone); err != nil {
+				slog.Error("logs", "err", err)
+				return 1
+			}
+		}
+		var encoded string
+		if len(filters) > 0 {
+			if encoded, err = encodeFilters(filters); err != nil {
+				slog.Error("logs", "err", err)
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var httpClient *http.Client
+		if follow && rc.dryRun == nil && rc.replay == nil {
+			// Followed logs are resumed over a new connection when it drops
+			redialer := client.NewRedialer(func() (*ssh.Client, error) {
+				return rc.dialSSH(ctx)
+			}, rc.keepAlive)
+			defer redialer.Close()
+			httpClient = rc.apiClient(func() (net.Conn, error) {
+				return redialer.Dial("unix", rc.socketPath)
+			})
+		} else {
+			sshClient, c, err := rc.connect(ctx)
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+				return 1
+			}
+			defer sshClient.Close()
+			httpClient = c
+		}
+
+		targets := slices.Clone(rc.args)
+		if encoded != "" {
+			names, err := filteredContainers(ctx, httpClient, encoded)
+			if err != nil {
+				slog.Error("logs", "err", err)
+				return 1
+			}
+			targets = appendUnique(targets, names...)
+			if len(targets) == 0 {
+				slog.Error("logs: no containers match -filter", "filter", strings.Join(filters, " "))
+				return 1
+			}
+		}
+
+		color := colorEnabled(os.Stdout)
+		return streamLogs(ctx, httpClient, targets, query, loc, color, os.Stdout, os.Stderr)
+	}
+}
+
+// streamLogs copies the logs of the targets to stdout and stderr
+// concurrently, with timestamps rendered in loc unless it is nil. The
+// lines of several targets are prefixed with the target's name, in a
+// color per target if color is set. It returns 1 if the logs of any
+// target could not be read.
+func streamLogs(ctx context.Context, httpClient *http.Client, targets []string, query url.Values, loc *time.Location, color bool, stdout, stderr io.Writer) int {
+	width := 0
+	for _, target := range targets {
+		width = max(width, len(target))
+	}
+
+	var mu sync.Mutex
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			out, errOut := stdout, stderr
+			if len(targets) > 1 {
+				prefix := fmt.Sprintf("%-*s | ", width, target)
+				if color {
+					prefix = logColors[i%len(logColors)] + prefix + ansiReset
+				}
+				outPrefix := &prefixWriter{w: stdout, mu: &mu, prefix: prefix}
+				errPrefix := &prefixWriter{w: stderr, mu: &mu, prefix: prefix}
+				defer outPrefix.flush()
+				defer errPrefix.flush()
+				out, errOut = outPrefix, errPrefix
+			}
+			if loc != nil {
+				outStamp := &timestampWriter{w: out, loc: loc}
+				errStamp := &timestampWriter{w: errOut, loc: loc}
+				defer outStamp.flush()
+				defer errStamp.flush()
+				out, errOut = outStamp, errStamp
+			}
+			errs[i] = followLogs(ctx, httpClient, target, query, out, errOut)
+		}()
+	}
+	wg.Wait()
+
+	code := 0
+	for i, target := range targets {
+		if errs[i] != nil && ctx.Err() == nil {
+			slog.Error("logs", "target", target, "err", errs[i])
+			code = 1
+		}
+	}
+	return code
+}
+
+// logColors are the ANSI colors of the container name prefixes, assigned
+// to the containers in turn.
+var logColors = []string{"\x1b[36m", "\x1b[33m", "\x1b[32m", "\x1b[35m", "\x1b[34m", "\x1b[31m"}
+
+// filteredContainers returns the names of the containers, running or not,
+// matching filters, as encoded by encodeFilters.
+func filteredContainers(ctx context.Context, httpClient *http.Client, filters string) ([]string, error) {
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", url.Values{"all": {"true"}, "filters": {filters}}, &containers); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		}
+	}
+	return names, nil
+}
+
+// appendUnique appends the values to list that it does not contain yet.
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// logsQuery returns the query parameters of the logs endpoint for the
+// -since, -until and -tail values, with relative times taken from now.
+func logsQuery(since, until, tail string, now time.Time) (url.Values, error) {
+	query := url.Values{"stdout": {"true"}, "stderr": {"true"}}
+	for _, p := range []struct{ name, value string }{{"since", since}, {"until", until}} {
+		if p.value == "" {
+			continue
+		}
+		t, err := parseLogTime(p.value, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s %q: %w", p.name, p.value, err)
+		}
+		query.Set(p.name, strconv.FormatInt(t.Unix(), 10))
+	}
+	if tail != "all" {
+		if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid -tail %q: want a number of lines or all", tail)
+		}
+	}
+	query.Set("tail", tail)
+	return query, nil
+}
+
+// parseLogTime parses a -since or -until value: an RFC 3339 timestamp, a
+// date, Unix time in seconds, or a duration before now.
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("want an RFC 3339 timestamp, Unix time or duration such as 10m")
+}
+
+// parseTimezone returns the location named by -timezone.
+func parseTimezone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// containerLogs copies the logs of the named container to stdout and
+// stderr.
+func containerLogs(ctx context.Context, httpClient *http.Client, name string, query url.Values, stdout, stderr io.Writer) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return demuxStream(stdout, stderr, resp.Body)
+}
+
+// followLogs copies the logs of the named container to stdout and stderr
+// as containerLogs does. Logs that are followed are resumed after a
+// transient error, such as a dropped connection, from the time of the last
+// line written.
+func followLogs(ctx context.Context, httpClient *http.Client, name string, query url.Values, stdout, stderr io.Writer) error {
+	if query.Get("follow") != "true" {
+		return containerLogs(ctx, httpClient, name, query, stdout, stderr)
+	}
+
+	// Timestamps tell where to resume, and are removed unless asked for
+	cursor := &logCursor{keep: query.Get("timestamps") == "true"}
+	out, errOut := cursor.writer(stdout), cursor.writer(stderr)
+	query = maps.Clone(query)
+	query.Set("timestamps", "true")
+	return resumeStream(ctx, "logs "+name, func() (int, error) {
+		if !cursor.last.IsZero() {
+			// Lines of the last second written are skipped by the cursor
+			query.Set("since", strconv.FormatInt(cursor.last.Unix(), 10))
+			query.Set("tail", "all")
+		}
+		lines := cursor.lines
+		err := containerLogs(ctx, httpClient, name, query, out, errOut)
+		if err == nil {
+			out.flush()
+			errOut.flush()
+		} else {
+			// A partial line is written in full once resumed
+			out.line, errOut.line = out.line[:0], errOut.line[:0]
+		}
+		return cursor.lines - lines, err
+	})
+}
+
+// logCursor tracks the time of the last log line written, so that resumed
+// logs skip the lines already written. The lines must start with the
+// timestamp added by Podman, which is removed unless keep is set. The
+// writers of a cursor must not be written to concurrently.
+type logCursor struct {
+	keep  bool
+	last  time.Time
+	lines int
+}
+
+// writer returns a writer passing the new lines of the logs on to w.
+func (c *logCursor) writer(w io.Writer) *cursorWriter {
+	return &cursorWriter{w: w, cursor: c}
+}
+
+// cursorWriter writes the lines of the logs newer than the last line of
+// its cursor to w, holding partial lines until complete or flushed.
+type cursorWriter struct {
+	w      io.Writer
+	cursor *logCursor
+	line   []byte
+}
+
+func (cw *cursorWriter) Write(p []byte) (int, error) {
+	cw.line = append(cw.line, p...)
+	for {
+		i := bytes.IndexByte(cw.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := cw.writeLine(cw.line[:i+1]); err != nil {
+			return 0, err
+		}
+		cw.line = append(cw.line[:0], cw.line[i+1:]...)
+	}
+}
+
+// flush writes the last line of output if it did not end with a newline.
+func (cw *cursorWriter) flush() error {
+	if len(cw.line) == 0 {
+		return nil
+	}
+	err := cw.writeLine(cw.line)
+	cw.line = cw.line[:0]
+	return err
+}
+
+func (cw *cursorWriter) writeLine(line []byte) error {
+	stamp, rest, ok := bytes.Cut(line, []byte(" "))
+	if t, err := time.Parse(time.RFC3339Nano, string(stamp)); ok && err == nil {
+		if !t.After(cw.cursor.last) {
+			return nil
+		}
+		cw.cursor.last = t
+		if !cw.cursor.keep {
+			line = rest
+		}
+	}
+	cw.cursor.lines++
+	_, err := cw.w.Write(line)
+	return err
+}
+
+// timestampWriter rewrites the RFC 3339 timestamp Podman puts at the start
+// of each log line in loc, passing other lines through unchanged. Partial
+// lines are held until complete or flushed.
+type timestampWriter struct {
+	w    io.Writer
+	loc  *time.Location
+	line []byte
+}
+
+func (tw *timestampWriter) Write(p []byte) (int, error) {
+	tw.line = append(tw.line, p...)
+	for {
+		i := bytes.IndexByte(tw.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := tw.writeLine(tw.line[:i+1]); err != nil {
+			return 0, err
+		}
+		tw.line = append(tw.line[:0], tw.line[i+1:]...)
+	}
+}
+
+// flush writes the last line of output if it did not end with a newline.
+func (tw *timestampWriter) flush() error {
+	if len(tw.line) == 0 {
+		return nil
+	}
+	err := tw.writeLine(tw.line)
+	tw.line = tw.line[:0]
+	return err
+}
+
+func (tw *timestampWriter) writeLine(line []byte) error {
+	stamp, rest, ok := bytes.Cut(line, []byte(" "))
+	if t, err := time.Parse(time.RFC3339Nano, string(stamp)); ok && err == nil {
+		line = append([]byte(t.In(tw.loc).Format(time.RFC3339Nano)+" "), rest...)
+	}
+	_, err := tw.w.Write(line)
+	return err
+}
+
+// prefixWriter writes each line of output to w after prefix, holding
+// partial lines until complete or flushed. Writers sharing mu write whole
+// lines to w without interleaving them.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	line   []byte
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.line = append(pw.line, p...)
+	for {
+		i := bytes.IndexByte(pw.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := pw.writeLine(pw.line[:i+1]); err != nil {
+			return 0, err
+		}
+		pw.line = append(pw.line[:0], pw.line[i+1:]...)
+	}
+}
+
+// flush writes the last line of output, ending it with a newline so that
+// the next line of another writer starts on its own line.
+func (pw *prefixWriter) flush() error {
+	if len(pw.line) == 0 {
+		return nil
+	}
+	err := pw.writeLine(append(pw.line, '\n'))
+	pw.line = pw.line[:0]
+	return err
+}
+
+func (pw *prefixWriter) writeLine(line []byte) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err := pw.w.Write(append([]byte(pw.prefix), line...))
+	return err
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogsQuery(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, since, until, tail string
+		want                     string
+	}{
+		{"defaults", "", "", "all", "stderr=true&stdout=true&tail=all"},
+		{"duration", "10m", "", "100", "since=1714564200&stderr=true&stdout=true&tail=100"},
+		{"timestamps", "2024-05-01T08:00:00Z", "2024-05-01T09:00:00+02:00", "all", "since=1714550400&stderr=true&stdout=true&tail=all&until=1714546800"},
+		{"unix", "1714550400", "0s", "0", "since=1714550400&stderr=true&stdout=true&tail=0&until=1714564800"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := logsQuery(tt.since, tt.until, tt.tail, now)
+			if err != nil {
+				t.Fatalf("logsQuery() unexpected error = %v", err)
+			}
+			if got := query.Encode(); got != tt.want {
+				t.Errorf("logsQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, args := range [][3]string{{"yesterday", "", "all"}, {"", "5 minutes", "all"}, {"", "", "-1"}, {"", "", "ten"}} {
+		if _, err := logsQuery(args[0], args[1], args[2], now); err == nil {
+			t.Errorf("logsQuery(%q) error = nil, want an error", args)
+		}
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	for _, name := range []string{"local", "UTC", "utc"} {
+		if _, err := parseTimezone(name); err != nil {
+			t.Errorf("parseTimezone(%q) unexpected error = %v", name, err)
+		}
+	}
+	if _, err := parseTimezone("Mars/Olympus"); err == nil || !strings.Contains(err.Error(), "-timezone") {
+		t.Errorf("parseTimezone() error = %v, want an invalid -timezone error", err)
+	}
+}
+
+func TestTimestampWriter(t *testing.T) {
+	var out bytes.Buffer
+	tw := &timestampWriter{w: &out, loc: time.FixedZone("UTC+2", 2*60*60)}
+
+	// Lines split across writes, and a last line without a newline
+	for _, chunk := range []string{"2024-05-01T08:00:00.5Z star", "ted\nno timestamp\n2024-05-01T08:00:01Z ", "done"} {
+		if _, err := tw.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() unexpected error = %v", err)
+		}
+	}
+	if err := tw.flush(); err != nil {
+		t.Fatalf("flush() unexpected error = %v", err)
+	}
+	want := "2024-05-01T10:00:00.5+02:00 started\nno timestamp\n2024-05-01T10:00:01+02:00 done"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestContainerLogs(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/containers/web/logs" || r.URL.Query().Get("tail") != "2" {
+			t.Errorf("request = %s, want the web logs with tail=2", r.URL)
+		}
+		w.Write(frame(1, "out\n"))
+		w.Write(frame(2, "err\n"))
+	}))
+
+	query, err := logsQuery("", "", "2", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := containerLogs(context.Background(), httpClient, "web", query, &stdout, &stderr); err != nil {
+		t.Fatalf("containerLogs() unexpected error = %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("containerLogs() stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestStreamLogs_Multiple(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/web/logs":
+			w.Write(frame(1, "2024-05-01T08:00:00Z GET /\n"))
+			w.Write(frame(2, "2024-05-01T08:00:01Z warn\n"))
+		case "/v3.0.0/libpod/containers/db/logs":
+			// A TTY container, whose output is not multiplexed, ending
+			// without a newline
+			w.Write([]byte("2024-05-01T08:00:02Z ready\n2024-05-01T08:00:03Z partial"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such container"}`))
+		}
+	}))
+
+	var stdout, stderr bytes.Buffer
+	code := streamLogs(context.Background(), httpClient, []string{"web", "db"}, nil, time.UTC, false, &stdout, &stderr)
+	if code != 0 {
+		t.Errorf("streamLogs() = %d, want 0", code)
+	}
+	for _, want := range []string{"web | 2024-05-01T08:00:00Z GET /\n", "db  | 2024-05-01T08:00:02Z ready\n", "db  | 2024-05-01T08:00:03Z partial\n"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout = %q, missing %q", stdout.String(), want)
+		}
+	}
+	if want := "web | 2024-05-01T08:00:01Z warn\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+
+	stdout.Reset()
+	if code := streamLogs(context.Background(), httpClient, []string{"web", "missing"}, nil, nil, true, &stdout, &stderr); code != 1 {
+		t.Errorf("streamLogs() = %d, want 1 for a missing container", code)
+	}
+	if want := logColors[0] + "web     | " + ansiReset + "2024-05-01T08:00:00Z GET /\n"; stdout.String() != want {
+		t.Errorf("colored stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestFollowLogs_Resumes(t *testing.T) {
+	reconnectDelay = time.Millisecond
+	t.Cleanup(func() { reconnectDelay = time.Second })
+
+	var queries []url.Values
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Write(frame(1, "2024-05-01T08:00:00.1Z first\n"))
+		w.Write(frame(2, "2024-05-01T08:00:00.2Z second\n"))
+		if len(queries) == 1 {
+			// The connection drops in the middle of a line
+			w.Write(frame(1, "2024-05-01T08:00:00.3Z thi"))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Write(frame(1, "2024-05-01T08:00:00.3Z third\n"))
+		w.Write(frame(1, "2024-05-01T08:00:01Z fourth\n"))
+	}))
+
+	query, err := logsQuery("", "", "10", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	query.Set("follow", "true")
+	var stdout, stderr bytes.Buffer
+	if err := followLogs(context.Background(), httpClient, "web", query, &stdout, &stderr); err != nil {
+		t.Fatalf("followLogs() unexpected error = %v", err)
+	}
+	if want := "first\nthird\nfourth\n"; stdout.String() != want {
+		t.Errorf("followLogs() stdout = %q, want %q", stdout.String(), want)
+	}
+	if want := "second\n"; stderr.String() != want {
+		t.Errorf("followLogs() stderr = %q, want %q", stderr.String(), want)
+	}
+
+	if len(queries) != 2 {
+		t.Fatalf("followLogs() made %d requests, want 2", len(queries))
+	}
+	if queries[0].Get("timestamps") != "true" || queries[0].Get("since") != "" || queries[0].Get("tail") != "10" {
+		t.Errorf("first query = %v, want timestamps with the given tail", queries[0])
+	}
+	if since := queries[1].Get("since"); since != "1714550400" || queries[1].Get("tail") != "all" {
+		t.Errorf("resumed query = %v, want since the second line and the whole tail", queries[1])
+	}
+}
+
+func TestFilteredContainers(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("filters"); got != `{"label":["app=web"]}` || r.URL.Query().Get("all") != "true" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"Names":["/web-1"]},{"Names":["/web-2"]}]`))
+	}))
+	names, err := filteredContainers(context.Background(), httpClient, `{"label":["app=web"]}`)
+	if err != nil {
+		t.Fatalf("filteredContainers() unexpected error = %v", err)
+	}
+	if got := appendUnique([]string{"web-2", "db"}, names...); strings.Join(got, ",") != "web-2,db,web-1" {
+		t.Errorf("appendUnique() = %q, want web-2,db,web-1", got)
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// mockHost is the host name under which the mock server is found in the SSH
+// configuration it writes.
+const mockHost = "mock"
+
+// newMockServerCommand returns the "mock_server" command, which serves an
+// in-memory Podman API over SSH on a local address, so that the CLI can be
+// tried without a remote host. The SSH configuration, keys and known_hosts
+// file to reach it are written to a temporary directory, removed on exit,
+// and the command line to use them is printed.
+func newMockServerCommand(fs *flag.FlagSet) runFunc {
+	var listen, unixSocket, fixtures string
+	fs.StringVar(&listen, "listen", "127.0.0.1:0", "TCP address to accept SSH connections on")
+	fs.StringVar(&unixSocket, "unix", "", "Also serve the API on this local Unix socket")
+	fs.StringVar(&fixtures, "fixtures", "", "JSON file of the containers and images to start with (default a few demo ones)")
+
+	return func(rc *RemoteCLI) int {
+		f := testserver.DemoFixtures()
+		if fixtures != "" {
+			var err error
+			if f, err = testserver.LoadFixtures(fixtures); err != nil {
+				slog.Error("mock_server", "err", err)
+				return 1
+			}
+		}
+		server := testserver.New(f)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		dir, err := os.MkdirTemp("", "podman-cli-mock-")
+		if err != nil {
+			slog.Error("mock_server", "err", err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			slog.Error("mock_server", "err", err)
+			return 1
+		}
+		defer listener.Close()
+		config, err := writeMockSSHConfig(dir, listener.Addr().String())
+		if err != nil {
+			slog.Error("mock_server", "err", err)
+			return 1
+		}
+		go (&testserver.SSHServer{Handler: server, Config: config}).Serve(listener)
+
+		if unixSocket != "" {
+			unixListener, err := net.Listen("unix", unixSocket)
+			if err != nil {
+				slog.Error("mock_server", "err", err)
+				return 1
+			}
+			defer unixListener.Close()
+			go server.Serve(unixListener)
+			fmt.Printf("Serving the API on %s\n", unixSocket)
+		}
+
+		fmt.Printf("Serving the API over SSH on %s; until interrupted, run commands with:\n", listener.Addr())
+		fmt.Printf("  podman-cli -ssh-config %s -host %s ps\n", filepath.Join(dir, "ssh_config"), mockHost)
+		<-ctx.Done()
+		return 0
+	}
+}
+
+// writeMockSSHConfig generates a host key and a client key for an SSH
+// server at addr, and writes to dir an SSH configuration naming the server
+// mockHost, along with the client key and a known_hosts file trusting the
+// host key. It returns the server configuration, which only accepts the
+// client key.
+func writeMockSSHConfig(dir, addr string) (*ssh.ServerConfig, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		return nil, err
+	}
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	clientKey, err := ssh.NewSignerFromKey(clientPriv)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		return nil, err
+	}
+
+	identityFile := filepath.Join(dir, "id_ed25519")
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	sshConfig := fmt.Sprintf("Host %s\n  HostName %s\n  Port %s\n  User podman\n  IdentityFile %s\n  UserKnownHostsFile %s\n",
+		mockHost, host, port, identityFile, knownHostsFile)
+	files := map[string][]byte{
+		identityFile:                     pem.EncodeToMemory(block),
+		knownHostsFile:                   []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey()) + "\n"),
+		filepath.Join(dir, "ssh_config"): []byte(sshConfig),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	authorized := string(clientKey.PublicKey().Marshal())
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != authorized {
+				return nil, fmt.Errorf("unknown public key for %s", conn.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+	return config, nil
+}
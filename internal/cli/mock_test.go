@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/testserver"
+)
+
+// startMockHost serves the demo fixtures of the mock server over SSH as
+// mockHost, with HOME pointing to a temporary directory holding the SSH
+// configuration, whose path is returned.
+func startMockHost(t *testing.T) (string, *testserver.Server) {
+	t.Helper()
+	server := testserver.New(testserver.DemoFixtures())
+	return startMockHostHandler(t, server), server
+}
+
+// startMockHostHandler is startMockHost serving handler as the Podman API.
+func startMockHostHandler(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	config, err := writeMockSSHConfig(tmpDir, listener.Addr().String())
+	if err != nil {
+		t.Fatalf("writeMockSSHConfig() unexpected error = %v", err)
+	}
+	go (&testserver.SSHServer{Handler: handler, Config: config, SocketPath: client.DefaultSocketPath}).Serve(listener)
+	return filepath.Join(tmpDir, "ssh_config")
+}
+
+// TestMockServer_EndToEnd runs commands against the mock server through the
+// whole SSH path: configuration, host key verification, public key
+// authentication and the forwarded Podman socket.
+func TestMockServer_EndToEnd(t *testing.T) {
+	sshConfig, server := startMockHost(t)
+
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "list_containers", "-filter", "label=app=db"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	ctx := context.Background()
+	sshClient, httpClient, err := cli.connect(ctx)
+	if err != nil {
+		t.Fatalf("connect() unexpected error = %v", err)
+	}
+	defer sshClient.Close()
+
+	var out bytes.Buffer
+	if code := cli.execute(ctx, httpClient, cli.command, cli.query, &out); code != 0 {
+		t.Errorf("execute() = %d, want 0", code)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "Status: 200 OK\n") || !strings.Contains(got, `"/db"`) || strings.Contains(got, `"/web"`) {
+		t.Errorf("execute() output = %q, want the running db container", got)
+	}
+
+	if err := containerAction(ctx, httpClient, "web", "stop"); err != nil {
+		t.Fatalf("containerAction() unexpected error = %v", err)
+	}
+	if c, _ := server.Container("web"); c.State != testserver.StateExited {
+		t.Errorf("web state = %q after stop, want exited", c.State)
+	}
+	rows, _, err := listContainers(ctx, httpClient, nil)
+	if err != nil {
+		t.Fatalf("listContainers() unexpected error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].cols[1] != "db" {
+		t.Errorf("listContainers() = %v, want only db running", rows)
+	}
+}
+
+func TestConnect_SharesPooledConnection(t *testing.T) {
+	sshConfig, _ := startMockHost(t)
+
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "ps"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	cli.pool = client.NewPool(0)
+	defer cli.pool.Close()
+
+	ctx := context.Background()
+	first, _, err := cli.connect(ctx)
+	if err != nil {
+		t.Fatalf("connect() unexpected error = %v", err)
+	}
+	first.Close()
+	second, httpClient, err := cli.connect(ctx)
+	if err != nil {
+		t.Fatalf("connect() unexpected error = %v", err)
+	}
+	defer second.Close()
+
+	if first.Client != second.Client {
+		t.Error("connect() dialed again instead of reusing the pooled connection")
+	}
+	if _, _, err := listContainers(ctx, httpClient, nil); err != nil {
+		t.Errorf("listContainers() over the reused connection: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// portMapping publishes a container port, or a range of ports, on the
+// host, as set with -publish.
+type portMapping struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	ContainerPort uint16 `json:"container_port"`
+	HostPort      uint16 `json:"host_port,omitempty"`
+	Range         uint16 `json:"range,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// specNamespace selects the namespace a container joins, such as its
+// network namespace.
+type specNamespace struct {
+	NSMode string `json:"nsmode"`
+	Value  string `json:"value,omitempty"`
+}
+
+// networkOptions are the per-network settings of a container attached to
+// a named network.
+type networkOptions struct {
+	StaticIPs []string `json:"static_ips,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// networkModes are the -network values selecting a network namespace mode
+// rather than naming networks.
+var networkModes = map[string]bool{
+	"bridge": true, "host": true, "none": true, "private": true,
+	"slirp4netns": true, "pasta": true,
+}
+
+// parsePublish parses a -publish value of the form
+// [[ip:][hostPort]:]containerPort[/protocol], where either port may be a
+// range such as 8000-8010 and an IPv6 address is written in brackets. An
+// omitted host port lets Podman pick a free one.
+func parsePublish(s string) (portMapping, error) {
+	spec, protocol, hasProtocol := strings.Cut(s, "/")
+	var m portMapping
+	if hasProtocol {
+		switch protocol {
+		case "tcp", "udp", "sctp":
+			m.Protocol = protocol
+		default:
+			return m, fmt.Errorf("invalid -publish %q: protocol must be tcp, udp or sctp", s)
+		}
+	}
+
+	if strings.HasPrefix(spec, "[") {
+		ip, rest, ok := strings.Cut(spec[1:], "]:")
+		if !ok {
+			return m, fmt.Errorf("invalid -publish %q: want [ip]:hostPort:containerPort", s)
+		}
+		m.HostIP, spec = ip, rest
+		if !strings.Contains(spec, ":") {
+			return m, fmt.Errorf("invalid -publish %q: want [ip]:hostPort:containerPort", s)
+		}
+	}
+
+	var hostPorts, containerPorts string
+	switch parts := strings.Split(spec, ":"); len(parts) {
+	case 1:
+		containerPorts = parts[0]
+	case 2:
+		hostPorts, containerPorts = parts[0], parts[1]
+	case 3:
+		if m.HostIP != "" {
+			return m, fmt.Errorf("invalid -publish %q: too many fields", s)
+		}
+		m.HostIP, hostPorts, containerPorts = parts[0], parts[1], parts[2]
+	default:
+		return m, fmt.Errorf("invalid -publish %q: too many fields; write IPv6 addresses in brackets", s)
+	}
+	if m.HostIP != "" && net.ParseIP(m.HostIP) == nil {
+		return m, fmt.Errorf("invalid -publish %q: %q is not an IP address", s, m.HostIP)
+	}
+
+	start, count, err := parsePortRange(containerPorts)
+	if err != nil || start == 0 {
+		return m, fmt.Errorf("invalid -publish %q: bad container port %q", s, containerPorts)
+	}
+	m.ContainerPort = start
+	if hostPorts != "" {
+		hostStart, hostCount, err := parsePortRange(hostPorts)
+		if err != nil {
+			return m, fmt.Errorf("invalid -publish %q: bad host port %q", s, hostPorts)
+		}
+		if hostCount != count {
+			return m, fmt.Errorf("invalid -publish %q: host and container port ranges differ in length", s)
+		}
+		m.HostPort = hostStart
+	}
+	if count > 1 {
+		m.Range = count
+	}
+	return m, nil
+}
+
+// parsePortRange parses a port or a range of ports such as 8000-8010,
+// returning the first port and the number of ports.
+func parsePortRange(s string) (uint16, uint16, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return uint16(start), 1, nil
+	}
+	end, err := strconv.ParseUint(last, 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %q is reversed", s)
+	}
+	return uint16(start), uint16(end - start + 1), nil
+}
+
+// parseNetwork parses a -network value: a mode such as host or none,
+// container:<name> to share the network of another container, ns:<path>
+// to join a network namespace, or a comma separated list of networks to
+// attach the container to.
+func parseNetwork(s string) (*specNamespace, []string, error) {
+	if networkModes[s] {
+		return &specNamespace{NSMode: s}, nil, nil
+	}
+	if mode, value, ok := strings.Cut(s, ":"); ok && (mode == "container" || mode == "ns") {
+		if value == "" {
+			return nil, nil, fmt.Errorf("invalid -network %q: %s: requires a value", s, mode)
+		}
+		if mode == "ns" {
+			mode = "path"
+		}
+		return &specNamespace{NSMode: mode, Value: value}, nil, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name == "" || networkModes[name] {
+			return nil, nil, fmt.Errorf("invalid -network %q: want a mode or a list of network names", s)
+		}
+		names = append(names, name)
+	}
+	return &specNamespace{NSMode: "bridge"}, names, nil
+}
+
+// parseAddHost parses an -add-host value of the form host:ip, where ip may
+// be host-gateway for the address of the host.
+func parseAddHost(s string) (string, error) {
+	host, ip, ok := strings.Cut(s, ":")
+	if !ok || host == "" {
+		return "", fmt.Errorf("invalid -add-host %q: want host:ip", s)
+	}
+	if ip != "host-gateway" && net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid -add-host %q: %q is not an IP address", s, ip)
+	}
+	return host + ":" + ip, nil
+}
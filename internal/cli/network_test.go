@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParsePublish(t *testing.T) {
+	tests := []struct {
+		value string
+		want  portMapping
+	}{
+		{"80", portMapping{ContainerPort: 80}},
+		{"8080:80", portMapping{HostPort: 8080, ContainerPort: 80}},
+		{"127.0.0.1:8080:80/udp", portMapping{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "udp"}},
+		{"127.0.0.1::80", portMapping{HostIP: "127.0.0.1", ContainerPort: 80}},
+		{"[::1]:8443:443", portMapping{HostIP: "::1", HostPort: 8443, ContainerPort: 443}},
+		{"8000-8002:9000-9002", portMapping{HostPort: 8000, ContainerPort: 9000, Range: 3}},
+		{"5000-5001/sctp", portMapping{ContainerPort: 5000, Range: 2, Protocol: "sctp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parsePublish(tt.value)
+			if err != nil {
+				t.Fatalf("parsePublish() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePublish() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePublish_Invalid(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"80/icmp", "protocol must be"},
+		{"0", "bad container port"},
+		{"http", "bad container port"},
+		{"70000:80", "bad host port"},
+		{"8000-8001:80", "differ in length"},
+		{"9000-8000:80", "bad host port"},
+		{"::1:8080:80", "write IPv6 addresses in brackets"},
+		{"[::1]80", "want [ip]:hostPort:containerPort"},
+		{"localhost:8080:80", "not an IP address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := parsePublish(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parsePublish() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantNS    specNamespace
+		wantNames []string
+	}{
+		{"host", specNamespace{NSMode: "host"}, nil},
+		{"none", specNamespace{NSMode: "none"}, nil},
+		{"container:db", specNamespace{NSMode: "container", Value: "db"}, nil},
+		{"ns:/run/netns/blue", specNamespace{NSMode: "path", Value: "/run/netns/blue"}, nil},
+		{"frontend,backend", specNamespace{NSMode: "bridge"}, []string{"frontend", "backend"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ns, names, err := parseNetwork(tt.value)
+			if err != nil {
+				t.Fatalf("parseNetwork() unexpected error = %v", err)
+			}
+			if *ns != tt.wantNS || strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("parseNetwork() = %+v, %v, want %+v, %v", *ns, names, tt.wantNS, tt.wantNames)
+			}
+		})
+	}
+
+	for _, value := range []string{"container:", "backend,,frontend", "backend,host"} {
+		if _, _, err := parseNetwork(value); err == nil {
+			t.Errorf("parseNetwork(%q) error = nil, want an error", value)
+		}
+	}
+}
+
+func TestSpecFlags_Networking(t *testing.T) {
+	spec, err := parseSpec(t,
+		"-p", "8080:80", "-publish", "127.0.0.1:8443:443/tcp", "-P",
+		"-network", "backend", "-ip", "10.89.0.10",
+		"-dns", "10.89.0.1", "-dns", "2001:db8::53",
+		"-add-host", "db:10.89.0.5", "-add-host", "gateway:host-gateway",
+		"alpine")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+
+	got, _ := json.Marshal(spec)
+	want := `{"image":"alpine",` +
+		`"portmappings":[{"container_port":80,"host_port":8080},{"host_ip":"127.0.0.1","container_port":443,"host_port":8443,"protocol":"tcp"}],` +
+		`"publish_image_ports":true,"netns":{"nsmode":"bridge"},"Networks":{"backend":{"static_ips":["10.89.0.10"]}},` +
+		`"dns_server":["10.89.0.1","2001:db8::53"],"hostadd":["db:10.89.0.5","gateway:host-gateway"]}`
+	if string(got) != want {
+		t.Errorf("spec() = %s\nwant %s", got, want)
+	}
+}
+
+func TestSpecFlags_NetworkingInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"ip without network", []string{"-ip", "10.89.0.10"}, "-ip requires -network"},
+		{"ip with two networks", []string{"-network", "a,b", "-ip", "10.89.0.10"}, "-ip requires -network"},
+		{"ip with host network", []string{"-network", "host", "-ip", "10.89.0.10"}, "-ip requires -network"},
+		{"bad ip", []string{"-network", "a", "-ip", "10.89.0"}, "invalid -ip"},
+		{"bad dns", []string{"-dns", "dns.example.com"}, "invalid -dns"},
+		{"add-host without ip", []string{"-add-host", "db"}, "want host:ip"},
+		{"add-host bad ip", []string{"-add-host", "db:nowhere"}, "not an IP address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSpec(t, append(tt.args, "alpine")...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("spec() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeTable writes rows under headers to out in the given -format: an
+// aligned table for text, or for JSON an array with one object per row,
+// keyed by the headers in camel case ("CONTAINER ID" becomes
+// "containerId"). With json-stream the objects are written one per line.
+// Status columns of tables written to a terminal are colored (see
+// colorEnabled).
+func writeTable(out io.Writer, format string, headers []string, rows []tuiRow) error {
+	if format == formatText {
+		if f, ok := out.(*os.File); ok && colorEnabled(f) {
+			headers, rows = colorTable(headers, rows)
+		}
+		for _, line := range formatTable(headers, rows) {
+			if _, err := fmt.Fprintln(out, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys := make([]string, len(headers))
+	for i, h := range headers {
+		keys[i] = jsonKey(h)
+	}
+
+	objects := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		obj := make(map[string]string, len(keys))
+		for i, col := range row.cols {
+			obj[keys[i]] = col
+		}
+		objects = append(objects, obj)
+	}
+
+	enc := json.NewEncoder(out)
+	if format == formatJSONStream {
+		for _, obj := range objects {
+			if err := enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
+
+// jsonKey converts a table header such as "CONTAINER ID" to a camel case
+// JSON key such as "containerId".
+func jsonKey(header string) string {
+	words := strings.Fields(strings.ToLower(header))
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
+
+// Types of the events written with -format json-stream.
+const (
+	eventStatus   = "status"   // a progress message
+	eventProgress = "progress" // bytes transferred for a layer
+	eventResult   = "result"   // the outcome, such as a pulled image ID
+	eventError    = "error"    // a failure ending the command
+	eventPodman   = "event"    // a Podman event, in data
+)
+
+// streamEvent is a line of -format json-stream output.
+type streamEvent struct {
+	Time    time.Time       `json:"time"`
+	Host    string          `json:"host,omitempty"`
+	Command string          `json:"command"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Current int64           `json:"current,omitempty"`
+	Total   int64           `json:"total,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// eventStream writes the output of streaming commands as newline
+// delimited JSON, one streamEvent per line, for automation to parse. It is
+// safe for concurrent use.
+type eventStream struct {
+	host    string
+	command string
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newEventStream(out io.Writer, host, command string) *eventStream {
+	return &eventStream{host: host, command: command, enc: json.NewEncoder(out)}
+}
+
+// eventStream returns the stream for command's output when -format is
+// json-stream, and nil otherwise.
+func (rc *RemoteCLI) eventStream(out io.Writer, command string) *eventStream {
+	if rc.opts.format != formatJSONStream {
+		return nil
+	}
+	return newEventStream(out, rc.host, command)
+}
+
+// emit writes ev, stamped with the current time unless it has one, the
+// host and the command.
+func (s *eventStream) emit(ev streamEvent) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Time = ev.Time.UTC()
+	ev.Host = s.host
+	ev.Command = s.command
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
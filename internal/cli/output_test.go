@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTable(t *testing.T) {
+	headers := []string{"CONTAINER ID", "NAMES"}
+	rows := []tuiRow{{cols: []string{"abc123", "web"}}}
+
+	var text strings.Builder
+	if err := writeTable(&text, formatText, headers, rows); err != nil {
+		t.Fatalf("writeTable(text) unexpected error = %v", err)
+	}
+	if want := "CONTAINER ID  NAMES\nabc123        web\n"; text.String() != want {
+		t.Errorf("writeTable(text) = %q, want %q", text.String(), want)
+	}
+
+	var js strings.Builder
+	if err := writeTable(&js, formatJSON, headers, rows); err != nil {
+		t.Fatalf("writeTable(json) unexpected error = %v", err)
+	}
+	if want := "[\n  {\n    \"containerId\": \"abc123\",\n    \"names\": \"web\"\n  }\n]\n"; js.String() != want {
+		t.Errorf("writeTable(json) = %q, want %q", js.String(), want)
+	}
+
+	var stream strings.Builder
+	if err := writeTable(&stream, formatJSONStream, headers, append(rows, tuiRow{cols: []string{"def456", "db"}})); err != nil {
+		t.Fatalf("writeTable(json-stream) unexpected error = %v", err)
+	}
+	if want := "{\"containerId\":\"abc123\",\"names\":\"web\"}\n{\"containerId\":\"def456\",\"names\":\"db\"}\n"; stream.String() != want {
+		t.Errorf("writeTable(json-stream) = %q, want %q", stream.String(), want)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	var out bytes.Buffer
+	events := newEventStream(&out, "edge1", "pull_image")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events.emit(streamEvent{Time: at, Type: eventProgress, ID: "abc", Current: 5, Total: 10})
+	events.emit(streamEvent{Type: eventError, Message: "denied"})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("emit() wrote %d lines, want 2: %q", len(lines), out.String())
+	}
+	if want := `{"time":"2024-05-01T12:00:00Z","host":"edge1","command":"pull_image","type":"progress","id":"abc","current":5,"total":10}`; lines[0] != want {
+		t.Errorf("emit() = %s, want %s", lines[0], want)
+	}
+
+	var ev streamEvent
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatalf("emit() wrote invalid JSON: %v", err)
+	}
+	if ev.Time.IsZero() || ev.Type != eventError || ev.Message != "denied" {
+		t.Errorf("emit() = %+v, want a timestamped error event", ev)
+	}
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// pluginPrefix is the prefix of the executables on PATH that provide
+// commands not built into the CLI, as with git: "podman-cli foo" runs
+// podman-cli-foo.
+const pluginPrefix = "podman-cli-"
+
+// pluginCommand describes a plugin command. Its flags and arguments are
+// all passed to the plugin, so global flags must precede the command name.
+var pluginCommand = localCommand{
+	noHost:    true,
+	noDryRun:  true,
+	streaming: true,
+}
+
+// lookPlugin returns the path of the plugin executable providing the named
+// command.
+func lookPlugin(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, filepath.Separator) {
+		return "", exec.ErrNotFound
+	}
+	return exec.LookPath(pluginPrefix + name)
+}
+
+// pluginNames returns the sorted names of the commands provided by the
+// executables found on PATH, the first of the same name taking precedence.
+func pluginNames() []string {
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), pluginPrefix)
+			if !ok || name == "" || seen[name] {
+				continue
+			}
+			if info, err := e.Info(); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+				seen[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newPluginCommand returns the command running the plugin executable at
+// path with the command's arguments, which exits with the plugin's code.
+//
+// With a remote host, the remote Podman socket is forwarded to a private
+// local Unix socket for as long as the plugin runs, and the connection
+// details are passed in environment variables:
+//
+//   - PODMAN_CLI_HOST, PODMAN_CLI_SOCKET and PODMAN_CLI_FORMAT: the host,
+//     remote socket path and output format, which also apply to podman-cli
+//     commands run by the plugin
+//   - PODMAN_CLI_SSH_ADDR and PODMAN_CLI_SSH_USER: the SSH address and user
+//   - PODMAN_CLI_FORWARDED_SOCKET: the path of the local socket, also set
+//     as CONTAINER_HOST and DOCKER_HOST URLs for Podman and Docker clients
+func newPluginCommand(path string) runFunc {
+	return func(rc *RemoteCLI) int {
+		cmd := exec.Command(path, rc.args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Env = os.Environ()
+
+		if rc.host != "" {
+			socket, cleanup, err := rc.forwardSocket(context.Background())
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+				return 1
+			}
+			defer cleanup()
+			cmd.Env = append(cmd.Env, rc.pluginEnv(socket)...)
+		}
+
+		if err := cmd.Start(); err != nil {
+			slog.Error(rc.name, "plugin", path, "err", err)
+			return 1
+		}
+
+		// Interrupts from the terminal reach the plugin directly, and
+		// the socket is kept forwarded until it exits; a termination
+		// request is passed on.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			for s := range signals {
+				if s == syscall.SIGTERM {
+					cmd.Process.Signal(s)
+				}
+			}
+		}()
+
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		if err != nil {
+			slog.Error(rc.name, "plugin", path, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// forwardSocket forwards the remote Podman socket to a Unix socket in a
+// new private directory, and returns its path along with a function that
+// stops forwarding and removes the directory.
+func (rc *RemoteCLI) forwardSocket(ctx context.Context) (string, func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	redialer := client.NewRedialer(func() (*ssh.Client, error) {
+		return rc.dialSSH(ctx)
+	}, rc.keepAlive)
+	fail := func(err error) (string, func(), error) {
+		cancel()
+		redialer.Close()
+		return "", nil, err
+	}
+	if _, err := redialer.Client(); err != nil {
+		return fail(err)
+	}
+
+	dir, err := os.MkdirTemp("", "podman-cli-")
+	if err != nil {
+		return fail(err)
+	}
+	socket := filepath.Join(dir, "podman.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return fail(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := client.Forward(ctx, listener, func() (net.Conn, error) {
+			return redialer.Dial("unix", rc.socketPath)
+		})
+		if err != nil {
+			slog.Error("forward", "err", err)
+		}
+	}()
+
+	cleanup := func() {
+		cancel()
+		<-done
+		redialer.Close()
+		os.RemoveAll(dir)
+	}
+	return socket, cleanup, nil
+}
+
+// pluginEnv returns the environment variables describing the connection
+// to a plugin, with socket the path of the forwarded socket.
+func (rc *RemoteCLI) pluginEnv(socket string) []string {
+	env := []string{
+		"PODMAN_CLI_HOST=" + rc.host,
+		"PODMAN_CLI_SOCKET=" + rc.socketPath,
+		"PODMAN_CLI_FORMAT=" + rc.opts.format,
+		"PODMAN_CLI_SSH_ADDR=" + rc.addr,
+		"PODMAN_CLI_FORWARDED_SOCKET=" + socket,
+		"CONTAINER_HOST=unix://" + socket,
+		"DOCKER_HOST=unix://" + socket,
+	}
+	if rc.sshClientConfig != nil {
+		env = append(env, "PODMAN_CLI_SSH_USER="+rc.sshClientConfig.User)
+	}
+	return env
+}
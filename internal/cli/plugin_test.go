@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writePlugin creates an executable shell script named podman-cli-<name> in
+// dir.
+func writePlugin(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	path := filepath.Join(dir, pluginPrefix+name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	return path
+}
+
+func TestNewRemoteCLI_Plugin(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	out := filepath.Join(tmpDir, "out")
+	writePlugin(t, tmpDir, "hello", `echo "$@" "forwarded=${PODMAN_CLI_FORWARDED_SOCKET:-none}" > "`+out+`"; exit 3`)
+	t.Setenv("PATH", tmpDir)
+
+	cli, err := NewRemoteCLI([]string{"hello", "-name", "web", "--", "x"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if want := []string{"-name", "web", "--", "x"}; !reflect.DeepEqual(cli.args, want) {
+		t.Errorf("NewRemoteCLI() args = %q, want %q", cli.args, want)
+	}
+
+	if code := cli.Run(); code != 3 {
+		t.Errorf("Run() = %d, want the plugin's exit code 3", code)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("plugin did not run: %v", err)
+	}
+	if want := "-name web -- x forwarded=none\n"; string(got) != want {
+		t.Errorf("plugin output = %q, want %q", got, want)
+	}
+}
+
+func TestNewRemoteCLI_PluginNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := NewRemoteCLI([]string{"hello"}); err == nil || !strings.Contains(err.Error(), "invalid command") {
+		t.Errorf("NewRemoteCLI() error = %v, want invalid command", err)
+	}
+}
+
+func TestPluginNames(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePlugin(t, first, "audit", "")
+	writePlugin(t, second, "audit", "")
+	writePlugin(t, second, "rollout", "")
+	if err := os.WriteFile(filepath.Join(second, pluginPrefix+"notes"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(second, pluginPrefix+"dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	if got, want := pluginNames(), []string{"audit", "rollout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginNames() = %q, want %q", got, want)
+	}
+	if path, err := lookPlugin("audit"); err != nil || path != filepath.Join(first, pluginPrefix+"audit") {
+		t.Errorf("lookPlugin() = %q, %v, want the first on PATH", path, err)
+	}
+	if _, err := lookPlugin("../audit"); err == nil {
+		t.Error("lookPlugin() accepted a path")
+	}
+}
+
+func TestPluginEnv(t *testing.T) {
+	rc := &RemoteCLI{
+		host:            "edge1",
+		addr:            "edge1.example.com:22",
+		socketPath:      "/run/podman/podman.sock",
+		sshClientConfig: &ssh.ClientConfig{User: "core"},
+		opts:            globalOptions{format: formatJSON},
+	}
+	got := rc.pluginEnv("/tmp/podman-cli-1/podman.sock")
+	for _, want := range []string{
+		"PODMAN_CLI_HOST=edge1",
+		"PODMAN_CLI_SOCKET=/run/podman/podman.sock",
+		"PODMAN_CLI_FORMAT=json",
+		"PODMAN_CLI_SSH_ADDR=edge1.example.com:22",
+		"PODMAN_CLI_SSH_USER=core",
+		"PODMAN_CLI_FORWARDED_SOCKET=/tmp/podman-cli-1/podman.sock",
+		"CONTAINER_HOST=unix:///tmp/podman-cli-1/podman.sock",
+		"DOCKER_HOST=unix:///tmp/podman-cli-1/podman.sock",
+	} {
+		found := false
+		for _, e := range got {
+			found = found || e == want
+		}
+		if !found {
+			t.Errorf("pluginEnv() = %q, missing %q", got, want)
+		}
+	}
+}
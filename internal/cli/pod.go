@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// podSpec is the subset of Podman's PodSpecGenerator sent to the libpod
+// pod create endpoint. The networking settings apply to the infra
+// container, whose network namespace the pod's containers join.
+type podSpec struct {
+	Name             string            `json:"name,omitempty"`
+	Hostname         string            `json:"hostname,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	NoInfra          bool              `json:"no_infra,omitempty"`
+	InfraImage       string            `json:"infra_image,omitempty"`
+	SharedNamespaces []string          `json:"shared_namespaces,omitempty"`
+
+	PortMappings []portMapping             `json:"portmappings,omitempty"`
+	NetNS        *specNamespace            `json:"netns,omitempty"`
+	Networks     map[string]networkOptions `json:"Networks,omitempty"`
+	DNSServers   []string                  `json:"dns_server,omitempty"`
+	HostAdd      []string                  `json:"hostadd,omitempty"`
+}
+
+// podNamespaces are the namespaces the containers of a pod can share, as
+// given to -share.
+var podNamespaces = []string{"cgroup", "ipc", "net", "pid", "uts"}
+
+// podFlags holds the flags of the create_pod command.
+type podFlags struct {
+	name       string
+	hostname   string
+	labels     stringsFlag
+	share      string
+	infra      bool
+	infraImage string
+	networkFlags
+}
+
+func (f *podFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "name", "", "Name of the pod")
+	fs.StringVar(&f.hostname, "hostname", "", "Hostname of the pod")
+	fs.Var(&f.labels, "label", "Set a key=value label on the pod (repeatable)")
+	fs.StringVar(&f.share, "share", "", "Namespaces shared by the pod's containers, comma separated ("+strings.Join(podNamespaces, ", ")+" or none; default chosen by Podman)")
+	fs.BoolVar(&f.infra, "infra", true, "Create an infra container holding the pod's namespaces")
+	fs.StringVar(&f.infraImage, "infra-image", "", "Image of the infra container (default chosen by Podman)")
+	f.networkFlags.register(fs, "pod")
+}
+
+// spec returns the pod spec described by the flags.
+func (f *podFlags) spec() (podSpec, error) {
+	s := podSpec{
+		Name:       f.name,
+		Hostname:   f.hostname,
+		NoInfra:    !f.infra,
+		InfraImage: f.infraImage,
+	}
+
+	var err error
+	if s.Labels, err = parseKeyValues("label", f.labels, true); err != nil {
+		return s, err
+	}
+	if s.SharedNamespaces, err = parseShare(f.share); err != nil {
+		return s, err
+	}
+
+	// The networking settings are those of a container, the infra one
+	var c containerSpec
+	if err := f.networking(&c); err != nil {
+		return s, err
+	}
+	s.PortMappings, s.NetNS, s.Networks = c.PortMappings, c.NetNS, c.Networks
+	s.DNSServers, s.HostAdd = c.DNSServers, c.HostAdd
+
+	if s.NoInfra {
+		if s.InfraImage != "" || len(f.publish) > 0 || f.network != "" || len(f.dns) > 0 || len(f.addHosts) > 0 {
+			return s, fmt.Errorf("-infra-image, -publish, -network, -dns and -add-host require the infra container")
+		}
+		for _, ns := range s.SharedNamespaces {
+			if ns != "cgroup" && ns != "none" {
+				return s, fmt.Errorf("sharing the %s namespace requires the infra container", ns)
+			}
+		}
+	}
+	return s, nil
+}
+
+// parseShare parses the comma separated namespaces of -share. "none",
+// which shares no namespace, cannot be combined with others.
+func parseShare(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var namespaces []string
+	for _, ns := range strings.Split(value, ",") {
+		ns = strings.TrimSpace(ns)
+		switch {
+		case ns == "none":
+			if value != "none" {
+				return nil, fmt.Errorf("invalid -share %q: none cannot be combined with other namespaces", value)
+			}
+		case !slices.Contains(podNamespaces, ns):
+			return nil, fmt.Errorf("invalid -share %q: unknown namespace %q, want one of %s or none", value, ns, strings.Join(podNamespaces, ", "))
+		}
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+// newCreatePodCommand returns the "create_pod" command, which creates a
+// pod on the remote host from its flags and prints its ID.
+func newCreatePodCommand(fs *flag.FlagSet) runFunc {
+	var flags podFlags
+	flags.register(fs)
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 0 {
+			slog.Error("create_pod: usage: create_pod [flags]")
+			return 1
+		}
+		spec, err := flags.spec()
+		if err != nil {
+			slog.Error("create_pod", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		var result struct {
+			ID string `json:"Id"`
+		}
+		if err := postJSONResult(ctx, httpClient, "/v3.0.0/libpod/pods/create", spec, &result); err != nil {
+			slog.Error("create_pod", "target", spec.Name, "err", err)
+			return 1
+		}
+		fmt.Println(result.ID)
+		return 0
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// parsePodSpec parses args with the create_pod flags and builds the spec.
+func parsePodSpec(t *testing.T, args ...string) (podSpec, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("create_pod", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var flags podFlags
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return podSpec{}, err
+	}
+	return flags.spec()
+}
+
+func TestPodFlags(t *testing.T) {
+	spec, err := parsePodSpec(t,
+		"-name", "shop", "-hostname", "shop.local", "-label", "tier=web",
+		"-share", "ipc,net,uts", "-infra-image", "registry.example.com/pause:3.9",
+		"-p", "8080:80", "-network", "backend", "-ip", "10.89.0.10",
+		"-dns", "10.89.0.1", "-add-host", "db:10.89.0.5")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+
+	got, _ := json.Marshal(spec)
+	want := `{"name":"shop","hostname":"shop.local","labels":{"tier":"web"},` +
+		`"infra_image":"registry.example.com/pause:3.9","shared_namespaces":["ipc","net","uts"],` +
+		`"portmappings":[{"container_port":80,"host_port":8080}],"netns":{"nsmode":"bridge"},` +
+		`"Networks":{"backend":{"static_ips":["10.89.0.10"]}},"dns_server":["10.89.0.1"],"hostadd":["db:10.89.0.5"]}`
+	if string(got) != want {
+		t.Errorf("spec() = %s\nwant %s", got, want)
+	}
+}
+
+func TestPodFlags_NoInfra(t *testing.T) {
+	spec, err := parsePodSpec(t, "-name", "batch", "-infra=false", "-share", "cgroup")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+	if got, _ := json.Marshal(spec); string(got) != `{"name":"batch","no_infra":true,"shared_namespaces":["cgroup"]}` {
+		t.Errorf("spec() = %s, want a pod without infra container", got)
+	}
+}
+
+func TestPodFlags_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unknown namespace", []string{"-share", "ipc,mnt"}, `unknown namespace "mnt"`},
+		{"none with others", []string{"-share", "none,net"}, "none cannot be combined"},
+		{"publish without infra", []string{"-infra=false", "-p", "8080:80"}, "require the infra container"},
+		{"infra image without infra", []string{"-infra=false", "-infra-image", "pause"}, "require the infra container"},
+		{"share net without infra", []string{"-infra=false", "-share", "net"}, "net namespace requires the infra container"},
+		{"ip without network", []string{"-ip", "10.89.0.10"}, "-ip requires -network"},
+		{"bad label", []string{"-label", "=web"}, "label"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePodSpec(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("spec() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePodRequest(t *testing.T) {
+	spec, err := parsePodSpec(t, "-name", "shop", "-p", "8080:80")
+	if err != nil {
+		t.Fatalf("spec() unexpected error = %v", err)
+	}
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3.0.0/libpod/pods/create" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		var got podSpec
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil || got.Name != "shop" || len(got.PortMappings) != 1 {
+			t.Errorf("request body = %+v (%v), want the pod spec", got, err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"4f2a"}`))
+	}))
+
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := postJSONResult(t.Context(), httpClient, "/v3.0.0/libpod/pods/create", spec, &result); err != nil {
+		t.Fatalf("postJSONResult() unexpected error = %v", err)
+	}
+	if result.ID != "4f2a" {
+		t.Errorf("pod ID = %q, want 4f2a", result.ID)
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// newPortCommand returns the "port" command, which prints the published
+// port mappings of a container, or of all running containers when no
+// container is given.
+func newPortCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) > 1 {
+			slog.Error("port: at most one container name or ID is accepted")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if len(rc.args) == 1 {
+			ports, err := containerPorts(ctx, httpClient, rc.args[0])
+			if err != nil {
+				slog.Error("port", "target", rc.args[0], "err", err)
+				return 1
+			}
+			printPorts(os.Stdout, "", ports)
+			return 0
+		}
+
+		var containers []models.ContainerSummary
+		if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", nil, &containers); err != nil {
+			slog.Error("port", "err", err)
+			return 1
+		}
+
+		for _, c := range containers {
+			if len(c.Names) == 0 {
+				continue
+			}
+			name := strings.TrimPrefix(c.Names[0], "/")
+			ports, err := containerPorts(ctx, httpClient, name)
+			if err != nil {
+				slog.Error("port", "target", name, "err", err)
+				return 1
+			}
+			printPorts(os.Stdout, name+"\t", ports)
+		}
+		return 0
+	}
+}
+
+// containerPorts returns the port bindings of the named container.
+func containerPorts(ctx context.Context, httpClient *http.Client, name string) (map[string][]models.InspectHostPort, error) {
+	var inspect models.InspectContainerData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &inspect); err != nil {
+		return nil, err
+	}
+	return inspect.NetworkSettings.Ports, nil
+}
+
+// printPorts writes one "80/tcp -> 0.0.0.0:8080" line per published port
+// binding, sorted by container port, each preceded by prefix. Ports that
+// are exposed but not published are skipped.
+func printPorts(out io.Writer, prefix string, ports map[string][]models.InspectHostPort) {
+	keys := make([]string, 0, len(ports))
+	for port := range ports {
+		keys = append(keys, port)
+	}
+	sort.Strings(keys)
+
+	for _, port := range keys {
+		for _, b := range ports[port] {
+			hostIP := b.HostIP
+			if hostIP == "" {
+				hostIP = "0.0.0.0"
+			}
+			fmt.Fprintf(out, "%s%s -> %s\n", prefix, port, net.JoinHostPort(hostIP, b.HostPort))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestContainerPorts(t *testing.T) {
+	var gotPath string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"NetworkSettings":{"Ports":{
+			"80/tcp":[{"HostIp":"","HostPort":"8080"}],
+			"443/tcp":[{"HostIp":"127.0.0.1","HostPort":"8443"},{"HostIp":"::1","HostPort":"8443"}],
+			"9000/udp":null
+		}}}`))
+	}))
+
+	ports, err := containerPorts(context.Background(), httpClient, "web")
+	if err != nil {
+		t.Fatalf("containerPorts() unexpected error = %v", err)
+	}
+	if gotPath != "/v3.0.0/libpod/containers/web/json" {
+		t.Errorf("containerPorts() path = %q, want %q", gotPath, "/v3.0.0/libpod/containers/web/json")
+	}
+
+	var out strings.Builder
+	printPorts(&out, "", ports)
+
+	want := "443/tcp -> 127.0.0.1:8443\n" +
+		"443/tcp -> [::1]:8443\n" +
+		"80/tcp -> 0.0.0.0:8080\n"
+	if out.String() != want {
+		t.Errorf("printPorts() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestPrintPorts_Prefix(t *testing.T) {
+	ports := map[string][]models.InspectHostPort{"5432/tcp": {{HostPort: "5432"}}}
+
+	var out strings.Builder
+	printPorts(&out, "db\t", ports)
+
+	if want := "db\t5432/tcp -> 0.0.0.0:5432\n"; out.String() != want {
+		t.Errorf("printPorts() = %q, want %q", out.String(), want)
+	}
+}
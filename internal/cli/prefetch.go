@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/config"
+	"github.com/alexjch/podman-cli/internal/signature"
+)
+
+// Outcomes of pulling an image on a host, as reported by prefetch.
+const (
+	prefetchPulled      = "pulled"
+	prefetchPresent     = "present"
+	prefetchFailed      = "failed"
+	prefetchUnreachable = "unreachable"
+)
+
+// newPrefetchCommand returns the "prefetch" command, which pulls a set of
+// images on several hosts in parallel, to stage a rollout before it
+// starts. Progress is written to stderr with lines prefixed by host, and a
+// matrix of the outcome per host and image to stdout once all hosts have
+// finished.
+func newPrefetchCommand(fs *flag.FlagSet) runFunc {
+	var hosts, authFile, policyFile string
+	var maxParallel int
+	var skipExisting, tlsVerify bool
+	fs.StringVar(&hosts, "hosts", "", "Comma separated hosts, or @<group> for a group of the configuration file")
+	fs.IntVar(&maxParallel, "max-parallel", 4, "Number of hosts pulling at the same time")
+	fs.BoolVar(&skipExisting, "skip-existing", false, "Do not pull images a host already has")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when contacting the registry")
+	fs.StringVar(&policyFile, "verify", "", "Signature policy (containers-policy.json) the images must meet before any host pulls them")
+
+	return func(rc *RemoteCLI) int {
+		if hosts == "" || len(rc.args) == 0 {
+			slog.Error("prefetch: -hosts and at least one image are required")
+			return 1
+		}
+		if maxParallel < 1 {
+			slog.Error("prefetch: -max-parallel must be at least 1")
+			return 1
+		}
+		targets, err := resolveHosts(hosts, rc.opts.configFile)
+		if err != nil {
+			slog.Error("prefetch", "err", err)
+			return 1
+		}
+		images := rc.args
+		authHeaders := make([]string, len(images))
+		for i, image := range images {
+			if authHeaders[i], err = registryAuth(authFile, image); err != nil {
+				slog.Error("prefetch", "err", err)
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Images are verified once, before any host pulls them, and none
+		// is pulled if one is refused
+		var verified []signature.Reference
+		if policyFile != "" {
+			verified = make([]signature.Reference, len(images))
+			for i, image := range images {
+				if verified[i], err = verifyImage(ctx, policyFile, authFile, image, tlsVerify); err != nil {
+					slog.Error("prefetch: refusing to pull the images", "target", image, "err", err)
+					return 1
+				}
+			}
+		}
+
+		width := 0
+		for _, host := range targets {
+			width = max(width, len(host))
+		}
+		var mu sync.Mutex
+		results := make([][]string, len(targets))
+		sem := make(chan struct{}, maxParallel)
+		var wg sync.WaitGroup
+		for i, host := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				var progress io.Writer = io.Discard
+				if showProgress {
+					prefix := fmt.Sprintf("%-*s | ", width, host)
+					if colorEnabled(os.Stderr) {
+						prefix = logColors[i%len(logColors)] + prefix + ansiReset
+					}
+					pw := &prefixWriter{w: os.Stderr, mu: &mu, prefix: prefix}
+					defer pw.flush()
+					progress = pw
+				}
+				results[i] = prefetchHost(ctx, rc, host, images, verified, authHeaders, tlsVerify, skipExisting, progress)
+			}()
+		}
+		wg.Wait()
+
+		code := 0
+		rows := make([]tuiRow, len(targets))
+		for i, host := range targets {
+			rows[i] = tuiRow{id: host, cols: append([]string{host}, results[i]...)}
+			for _, result := range results[i] {
+				if result == prefetchFailed || result == prefetchUnreachable {
+					code = 1
+				}
+			}
+		}
+		if err := writeTable(os.Stdout, rc.opts.format, append([]string{"HOST"}, images...), rows); err != nil {
+			slog.Error("prefetch", "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// prefetchHost pulls images on host, writing progress to out, and returns
+// the outcome for each image. When verified is not nil, it holds the
+// images as verified by verifyImage, which are pulled by digest.
+func prefetchHost(ctx context.Context, rc *RemoteCLI, host string, images []string, verified []signature.Reference, authHeaders []string, tlsVerify, skipExisting bool, out io.Writer) []string {
+	results := make([]string, len(images))
+	fail := func(from int, result string) []string {
+		for i := from; i < len(results); i++ {
+			results[i] = result
+		}
+		return results
+	}
+
+	hostRC, err := rc.forHost(host)
+	if err != nil {
+		slog.Error("prefetch", "err", err)
+		return fail(0, prefetchUnreachable)
+	}
+	sshClient, httpClient, err := hostRC.connect(ctx)
+	if err != nil {
+		slog.Error("prefetch: failed to connect", "host", host, "err", err)
+		return fail(0, prefetchUnreachable)
+	}
+	defer sshClient.Close()
+
+	for i, image := range images {
+		if ctx.Err() != nil {
+			return fail(i, prefetchFailed)
+		}
+		reference := image
+		if verified != nil {
+			reference = verified[i].Repository() + "@" + verified[i].Digest
+		}
+		if skipExisting {
+			exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(reference)+"/exists")
+			if err == nil && exists && verified != nil && verified[i].Tag != "" {
+				// The tag may still name another image on the host
+				err = tagImage(ctx, httpClient, reference, verified[i].Repository(), verified[i].Tag)
+			}
+			if err == nil && exists {
+				fmt.Fprintf(out, "%s already present\n", image)
+				results[i] = prefetchPresent
+				continue
+			}
+		}
+		fmt.Fprintf(out, "Pulling %s\n", image)
+		if verified != nil {
+			err = pullVerifiedImage(ctx, httpClient, verified[i], tlsVerify, authHeaders[i], out, nil)
+		} else {
+			err = pullImage(ctx, httpClient, image, tlsVerify, authHeaders[i], out, nil)
+		}
+		if err != nil {
+			slog.Error("prefetch", "host", host, "target", image, "err", err)
+			results[i] = prefetchFailed
+			continue
+		}
+		results[i] = prefetchPulled
+	}
+	return results
+}
+
+// resolveHosts returns the hosts of a comma separated list, in which
+// @<group> stands for the hosts of a group of the configuration file at
+// configFile. Hosts listed more than once are returned once.
+func resolveHosts(list, configFile string) ([]string, error) {
+	var f *config.File
+	var hosts []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		group, isGroup := strings.CutPrefix(entry, "@")
+		switch {
+		case entry == "":
+			return nil, fmt.Errorf("invalid -hosts %q: empty host", list)
+		case isGroup:
+			if f == nil {
+				var err error
+				if f, err = config.Load(configFile); err != nil {
+					return nil, err
+				}
+			}
+			members, err := f.Group(group)
+			if err != nil {
+				return nil, err
+			}
+			hosts = appendUnique(hosts, members...)
+		default:
+			hosts = appendUnique(hosts, entry)
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[groups]\nfleet = [\"edge1\", \"edge2\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := resolveHosts("build1, @fleet,edge2", path)
+	if err != nil {
+		t.Fatalf("resolveHosts() unexpected error = %v", err)
+	}
+	if got := strings.Join(hosts, ","); got != "build1,edge1,edge2" {
+		t.Errorf("resolveHosts() = %s, want build1,edge1,edge2", got)
+	}
+
+	for _, list := range []string{"@staging", "edge1,,edge2"} {
+		if _, err := resolveHosts(list, path); err == nil {
+			t.Errorf("resolveHosts(%q) error = nil, want an error", list)
+		}
+	}
+}
+
+func TestPrefetchCommand_ArgumentValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		args  []string
+	}{
+		{"no hosts", nil, []string{"alpine"}},
+		{"no images", []string{"-hosts", "edge1"}, nil},
+		{"max parallel", []string{"-hosts", "edge1", "-max-parallel", "0"}, []string{"alpine"}},
+		{"unknown group", []string{"-hosts", "@fleet"}, []string{"alpine"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newTestCommand(t, newPrefetchCommand, tt.flags...)
+			rc := &RemoteCLI{args: tt.args}
+			rc.opts.configFile = filepath.Join(t.TempDir(), "missing.toml")
+			if code := run(rc); code != 1 {
+				t.Errorf("prefetch exit code = %d, want 1", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progressInterval is the minimum time between progress updates.
+const progressInterval = 100 * time.Millisecond
+
+// showProgress is cleared by -quiet to disable progress reporting.
+var showProgress = true
+
+// progressTerminal reports whether progress should be rendered on f: it
+// must be a terminal and -quiet not given.
+func progressTerminal(f *os.File) bool {
+	return showProgress && term.IsTerminal(int(f.Fd()))
+}
+
+// progress reports the number of bytes transferred so far, the transfer
+// rate and, when the total is known, the estimated time remaining on a
+// single, continuously rewritten terminal line.
+type progress struct {
+	out   io.Writer
+	label string
+	total int64 // expected size in bytes, or 0 if unknown
+
+	done  int64
+	start time.Time
+	last  time.Time
+}
+
+// add records n transferred bytes, reporting progress if enough time has
+// passed since the last update or if final is set.
+func (p *progress) add(n int, final bool) {
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	p.done += int64(n)
+	if !final && now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+
+	var rate int64
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = int64(float64(p.done) / elapsed)
+	}
+
+	line := fmt.Sprintf("%s: %s", p.label, formatSize(p.done))
+	if p.total > 0 {
+		percent := float64(p.done) * 100 / float64(p.total)
+		line = fmt.Sprintf("%s: %s / %s (%.0f%%)", p.label, formatSize(p.done), formatSize(p.total), percent)
+	}
+	line += fmt.Sprintf(" %s/s", formatSize(rate))
+	if p.total > 0 && !final {
+		line += ", ETA " + eta(p.total-p.done, rate)
+	}
+
+	// Clear the rest of the line, left over from a longer update
+	fmt.Fprintf(p.out, "\r%s\x1b[K", line)
+	if final {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// eta returns the time needed to transfer remaining bytes at rate bytes
+// per second, or "--" if the rate is not known yet.
+func eta(remaining, rate int64) string {
+	if rate <= 0 {
+		return "--"
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return (time.Duration(remaining) * time.Second / time.Duration(rate)).Round(time.Second).String()
+}
+
+// progressReader wraps an io.Reader, reporting the bytes read.
+type progressReader struct {
+	r io.Reader
+	progress
+}
+
+// newProgressReader returns a reader reporting progress to stderr when it is
+// a terminal and -quiet is not given. Otherwise r is returned unchanged.
+func newProgressReader(r io.Reader, label string, total int64) io.Reader {
+	if !progressTerminal(os.Stderr) {
+		return r
+	}
+	return &progressReader{r: r, progress: progress{out: os.Stderr, label: label, total: total}}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.add(n, err == io.EOF)
+	return n, err
+}
+
+// progressWriter wraps an io.Writer, reporting the bytes written. Call
+// Close once the transfer is complete to print the final update.
+type progressWriter struct {
+	w io.Writer
+	progress
+}
+
+// newProgressWriter returns a writer reporting progress to stderr when it
+// is a terminal and -quiet is not given. Otherwise w is returned unchanged.
+func newProgressWriter(w io.Writer, label string, total int64) io.WriteCloser {
+	if !progressTerminal(os.Stderr) {
+		return nopWriteCloser{w}
+	}
+	return &progressWriter{w: w, progress: progress{out: os.Stderr, label: label, total: total}}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.add(n, false)
+	return n, err
+}
+
+func (p *progressWriter) Close() error {
+	p.add(0, true)
+	return nil
+}
+
+// nopWriteCloser adds a no-op Close method to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// jsonMessage is a progress message in the format streamed by the
+// Docker-compatible push and pull endpoints.
+type jsonMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// progressBarWidth is the number of characters in a layer progress bar.
+const progressBarWidth = 30
+
+// layerProgress renders a stream of jsonMessages, showing one line per
+// layer. On a terminal each layer's line is updated in place with a
+// progress bar; otherwise only status changes are printed.
+type layerProgress struct {
+	out      io.Writer
+	terminal bool
+	events   *eventStream // if set, messages are emitted as events instead
+
+	layers []string          // layer IDs in order of appearance
+	status map[string]string // last status line printed for each layer
+}
+
+func newLayerProgress(out io.Writer, terminal bool) *layerProgress {
+	return &layerProgress{out: out, terminal: terminal, status: map[string]string{}}
+}
+
+// update renders msg. Messages without an ID are printed as plain lines.
+func (lp *layerProgress) update(msg jsonMessage) {
+	if lp.events != nil {
+		ev := streamEvent{Type: eventStatus, ID: msg.ID, Message: msg.Status}
+		if msg.ProgressDetail.Total > 0 {
+			ev.Type = eventProgress
+			ev.Current, ev.Total = msg.ProgressDetail.Current, msg.ProgressDetail.Total
+		}
+		lp.events.emit(ev)
+		return
+	}
+
+	if msg.ID == "" {
+		if msg.Status != "" {
+			fmt.Fprintln(lp.out, msg.Status)
+		}
+		return
+	}
+
+	line := msg.ID + ": " + msg.Status
+	if msg.ProgressDetail.Total > 0 && lp.terminal {
+		line += " " + progressBar(msg.ProgressDetail.Current, msg.ProgressDetail.Total)
+	}
+
+	previous, seen := lp.status[msg.ID]
+	if !seen {
+		lp.layers = append(lp.layers, msg.ID)
+	}
+	lp.status[msg.ID] = line
+
+	if !lp.terminal {
+		// Without cursor control, only report status transitions
+		if previous != line {
+			fmt.Fprintln(lp.out, line)
+		}
+		return
+	}
+
+	if !seen {
+		fmt.Fprintln(lp.out, line)
+		return
+	}
+
+	// Move up to the layer's line, rewrite it and return to the bottom
+	up := len(lp.layers) - slices.Index(lp.layers, msg.ID)
+	fmt.Fprintf(lp.out, "\x1b[%dA\r\x1b[2K%s\x1b[%dB\r", up, line, up)
+}
+
+// progressBar renders a fixed-width bar with the transferred and total
+// sizes.
+func progressBar(current, total int64) string {
+	filled := int(current * progressBarWidth / total)
+	filled = min(max(filled, 0), progressBarWidth)
+
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("[%s] %s/%s", bar, formatSize(current), formatSize(total))
+}
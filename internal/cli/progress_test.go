@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReader_ReportsTotal(t *testing.T) {
+	var out bytes.Buffer
+	r := &progressReader{
+		r:        strings.NewReader(strings.Repeat("x", 2048)),
+		progress: progress{out: &out, label: "Loading", total: 2048},
+	}
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("Copy() unexpected error = %v", err)
+	}
+
+	final := regexp.MustCompile(`\rLoading: 2\.0KiB / 2\.0KiB \(100%\) [0-9.]+[KMG]?i?B/s\x1b\[K\n$`)
+	if !final.MatchString(out.String()) {
+		t.Errorf("progress output = %q, want final 100%% line with rate", out.String())
+	}
+}
+
+func TestProgressWriter_UnknownTotal(t *testing.T) {
+	var out, dst bytes.Buffer
+	w := &progressWriter{w: &dst, progress: progress{out: &out, label: "Saving"}}
+
+	w.Write(make([]byte, 512))
+	w.Close()
+
+	if dst.Len() != 512 {
+		t.Errorf("progressWriter wrote %d bytes, want 512", dst.Len())
+	}
+	final := regexp.MustCompile(`\rSaving: 512B [0-9.]+[KMG]?i?B/s\x1b\[K\n$`)
+	if !final.MatchString(out.String()) {
+		t.Errorf("progress output = %q, want final byte count with rate", out.String())
+	}
+}
+
+func TestProgress_RateAndETA(t *testing.T) {
+	var out bytes.Buffer
+	p := progress{out: &out, label: "Saving", total: 4 << 20}
+
+	// Half the transfer took a second, leaving about a second
+	p.start = time.Now().Add(-time.Second)
+	p.add(2<<20, false)
+
+	want := regexp.MustCompile(`^\rSaving: 2\.0MiB / 4\.0MiB \(50%\) 2\.0MiB/s, ETA 1s\x1b\[K$`)
+	if !want.MatchString(out.String()) {
+		t.Errorf("progress output = %q, want rate and ETA", out.String())
+	}
+}
+
+func TestETA(t *testing.T) {
+	tests := []struct {
+		remaining, rate int64
+		want            string
+	}{
+		{1 << 20, 0, "--"},
+		{90 << 20, 1 << 20, "1m30s"},
+		{-5, 100, "0s"},
+	}
+	for _, tt := range tests {
+		if got := eta(tt.remaining, tt.rate); got != tt.want {
+			t.Errorf("eta(%d, %d) = %q, want %q", tt.remaining, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestProgressTerminal_Quiet(t *testing.T) {
+	defer func() { showProgress = true }()
+	showProgress = false
+	if progressTerminal(os.Stderr) {
+		t.Error("progressTerminal() = true with -quiet")
+	}
+	if r := strings.NewReader("x"); newProgressReader(r, "Loading", 1) != io.Reader(r) {
+		t.Error("newProgressReader() wrapped the reader with -quiet")
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		current, total int64
+		want           string
+	}{
+		{0, 1024, "[>                             ] 0B/1.0KiB"},
+		{512, 1024, "[===============>              ] 512B/1.0KiB"},
+		{1024, 1024, "[==============================] 1.0KiB/1.0KiB"},
+	}
+
+	for _, tt := range tests {
+		if got := progressBar(tt.current, tt.total); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", tt.current, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestLayerProgress_NonTerminal(t *testing.T) {
+	var out bytes.Buffer
+	lp := newLayerProgress(&out, false)
+
+	msg := func(id, status string, current int64) jsonMessage {
+		m := jsonMessage{ID: id, Status: status}
+		m.ProgressDetail.Current = current
+		m.ProgressDetail.Total = 100
+		return m
+	}
+
+	lp.update(jsonMessage{Status: "The push refers to repository [quay.io/team/app]"})
+	lp.update(msg("aaa", "Pushing", 10))
+	lp.update(msg("aaa", "Pushing", 50))
+	lp.update(msg("bbb", "Pushing", 10))
+	lp.update(jsonMessage{ID: "aaa", Status: "Pushed"})
+
+	want := "The push refers to repository [quay.io/team/app]\naaa: Pushing\nbbb: Pushing\naaa: Pushed\n"
+	if out.String() != want {
+		t.Errorf("layerProgress output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestLayerProgress_TerminalRewritesLine(t *testing.T) {
+	var out bytes.Buffer
+	lp := newLayerProgress(&out, true)
+
+	lp.update(jsonMessage{ID: "aaa", Status: "Preparing"})
+	lp.update(jsonMessage{ID: "bbb", Status: "Preparing"})
+	out.Reset()
+
+	lp.update(jsonMessage{ID: "aaa", Status: "Pushed"})
+
+	if !strings.HasPrefix(out.String(), "\x1b[2A\r\x1b[2Kaaa: Pushed") {
+		t.Errorf("layerProgress output = %q, want cursor moved up two lines", out.String())
+	}
+}
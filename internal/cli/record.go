@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// recordedExchange is an API request and its response, as written by
+// -record and served by -replay, one JSON object per line. Request bodies
+// are not recorded, as they may hold credentials; response bodies that
+// are not valid UTF-8 are stored base64 encoded, and those of archives or
+// larger than recordMaxBody are left out, BodyOmitted being set.
+type recordedExchange struct {
+	Method      string      `json:"method"`
+	URI         string      `json:"uri"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body,omitempty"`
+	BodyBase64  string      `json:"bodyBase64,omitempty"`
+	BodyOmitted bool        `json:"bodyOmitted,omitempty"`
+}
+
+// recordMaxBody is the size of the largest response body recorded.
+const recordMaxBody = 1 << 20
+
+// recordOmittedTypes are the content types of the response bodies left
+// out of recordings, such as the tarballs of save_image, volume_export
+// and checkpoint -export, which are streamed to files.
+var recordOmittedTypes = map[string]bool{
+	"application/x-tar":        true,
+	"application/tar":          true,
+	"application/gzip":         true,
+	"application/x-gzip":       true,
+	"application/x-xz":         true,
+	"application/zstd":         true,
+	"application/octet-stream": true,
+}
+
+// recording is the file of -record, to which the transports returned by
+// transport append the exchanges of their HTTP clients.
+type recording struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+	err error
+}
+
+// newRecording returns a recording to the file at path, which is created,
+// readable by the user only as responses may hold secrets such as
+// environment variables, or truncated.
+func newRecording(path string) (*recording, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("-record: %w", err)
+	}
+	return &recording{out: f}, nil
+}
+
+// transport returns a transport sending requests with rt and recording
+// each exchange once its response body is closed.
+func (r *recording) transport(rt http.RoundTripper) http.RoundTripper {
+	return &recordTransport{rt: rt, rec: r}
+}
+
+// write appends ex to the recording. The first error is kept for close.
+func (r *recording) write(ex recordedExchange) {
+	line, err := json.Marshal(ex)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		_, err = r.out.Write(append(line, '\n'))
+	}
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// close closes the recording, returning the first error met writing it.
+func (r *recording) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.out.Close(); r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("-record: %w", r.err)
+	}
+	return nil
+}
+
+// recordTransport is an http.RoundTripper that sends requests with rt and
+// appends each exchange to rec once its response body is closed.
+type recordTransport struct {
+	rt  http.RoundTripper
+	rec *recording
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The body is captured as the command reads it, so that streamed
+	// responses are recorded without being held back
+	ex := recordedExchange{Method: req.Method, URI: req.URL.RequestURI(), Status: resp.StatusCode, Header: resp.Header.Clone()}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The connection is handed over to an interactive session, such
+		// as exec, whose stream is not recorded
+		t.rec.write(ex)
+		return resp, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ex.BodyOmitted = recordOmittedTypes[mediaType]
+	resp.Body = &recordingBody{ReadCloser: resp.Body, rec: t.rec, ex: ex}
+	return resp, nil
+}
+
+// recordingBody is a response body that records its exchange, with the
+// data read unless too large or omitted, when closed.
+type recordingBody struct {
+	io.ReadCloser
+	rec  *recording
+	ex   recordedExchange
+	data bytes.Buffer
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.ex.BodyOmitted {
+		if b.data.Len()+n > recordMaxBody {
+			b.ex.BodyOmitted = true
+			b.data = bytes.Buffer{}
+		} else {
+			b.data.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() {
+		switch data := b.data.Bytes(); {
+		case b.ex.BodyOmitted:
+		case utf8.Valid(data):
+			b.ex.Body = string(data)
+		default:
+			b.ex.BodyBase64 = base64.StdEncoding.EncodeToString(data)
+		}
+		b.rec.write(b.ex)
+	})
+	return b.ReadCloser.Close()
+}
+
+// replayTransport is an http.RoundTripper that answers requests with the
+// responses of a recording instead of sending them. Each request gets the
+// first unused exchange with the same method and URI, so repeated
+// requests are answered in the recorded order.
+type replayTransport struct {
+	mu        sync.Mutex
+	exchanges []recordedExchange
+	used      []bool
+}
+
+// newReplayTransport returns a transport replaying the recording at path.
+func newReplayTransport(path string) (*replayTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("-replay: %w", err)
+	}
+	defer f.Close()
+
+	t := &replayTransport{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ex recordedExchange
+		if err := json.Unmarshal(line, &ex); err != nil {
+			return nil, fmt.Errorf("-replay %s:%d: %w", path, n, err)
+		}
+		if ex.Method == "" || ex.URI == "" || ex.Status == 0 {
+			return nil, fmt.Errorf("-replay %s:%d: method, uri and status are required", path, n)
+		}
+		t.exchanges = append(t.exchanges, ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("-replay: %w", err)
+	}
+	t.used = make([]bool, len(t.exchanges))
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	uri := req.URL.RequestURI()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, ex := range t.exchanges {
+		if t.used[i] || ex.Method != req.Method || ex.URI != uri {
+			continue
+		}
+		t.used[i] = true
+		if ex.BodyOmitted {
+			return nil, fmt.Errorf("replay %s %s: the response body was not recorded", req.Method, uri)
+		}
+
+		body := []byte(ex.Body)
+		if ex.BodyBase64 != "" {
+			var err error
+			if body, err = base64.StdEncoding.DecodeString(ex.BodyBase64); err != nil {
+				return nil, fmt.Errorf("replay %s %s: %w", req.Method, uri, err)
+			}
+		}
+		header := ex.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+			StatusCode:    ex.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("replay: no recorded response for %s %s", req.Method, uri)
+}
+
+// unused returns the requests of the recording that were not replayed.
+func (t *replayTransport) unused() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var requests []string
+	for i, ex := range t.exchanges {
+		if !t.used[i] {
+			requests = append(requests, ex.Method+" "+ex.URI)
+		}
+	}
+	return requests
+}
+
+// finishRecording closes the -record file and reports requests of the
+// -replay recording that the command did not make, which usually means
+// the recording is stale.
+func (rc *RemoteCLI) finishRecording() {
+	if rc.recorder != nil {
+		if err := rc.recorder.close(); err != nil {
+			slog.Error("Failed to write recording", "err", err)
+		}
+	}
+	if rc.replay != nil {
+		if unused := rc.replay.unused(); len(unused) > 0 {
+			slog.Warn("Recorded requests were not replayed", "requests", strings.Join(unused, ", "))
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	calls := 0
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/images/alpine/get":
+			w.Header().Set("Content-Type", "application/x-tar")
+			w.Write([]byte("alpine.tar"))
+		case "/v3.0.0/libpod/containers/web/logs":
+			w.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+			w.Write([]byte{0x00, 0xff, 0xfe})
+		case "/v3.0.0/libpod/containers/web/top":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(bytes.Repeat([]byte(" "), recordMaxBody+1))
+		case "/v3.0.0/libpod/containers/web/json":
+			w.Header().Set("Content-Type", "application/json")
+			if calls > 1 {
+				w.Write([]byte(`{"State":{"Status":"running"}}`))
+				return
+			}
+			w.Write([]byte(`{"State":{"Status":"created"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such container"}`))
+		}
+	}))
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := newRecording(path)
+	if err != nil {
+		t.Fatalf("newRecording() unexpected error = %v", err)
+	}
+	recording := &http.Client{Transport: recorder.transport(httpClient.Transport)}
+
+	requests := []string{
+		"/v3.0.0/libpod/containers/web/json",
+		"/v3.0.0/libpod/containers/web/json",
+		"/v3.0.0/libpod/containers/web/logs",
+		"/v3.0.0/libpod/containers/db/json",
+	}
+	// Archives and large bodies are not recorded, so cannot be replayed
+	omitted := []string{
+		"/v3.0.0/libpod/images/alpine/get?compress=false",
+		"/v3.0.0/libpod/containers/web/top",
+	}
+	get := func(c *http.Client, uri string) (int, string, error) {
+		resp, err := c.Get("http://localhost" + uri)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), err
+	}
+	var want []string
+	for _, uri := range requests {
+		code, body, err := get(recording, uri)
+		if err != nil {
+			t.Fatalf("recording GET %s: %v", uri, err)
+		}
+		want = append(want, http.StatusText(code)+" "+body)
+	}
+	for _, uri := range omitted {
+		if _, _, err := get(recording, uri); err != nil {
+			t.Fatalf("recording GET %s: %v", uri, err)
+		}
+	}
+	if err := recorder.close(); err != nil {
+		t.Fatalf("close() unexpected error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"bodyBase64":"AP/+"`) || !strings.Contains(string(data), `"body":"{\"State\":{\"Status\":\"created\"}}"`) {
+		t.Errorf("recording = %s, want text and base64 bodies", data)
+	}
+	if strings.Contains(string(data), "alpine.tar") || strings.Count(string(data), `"bodyOmitted":true`) != len(omitted) {
+		t.Errorf("recording = %.500s, want the archive and large bodies omitted", data)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("recording mode = %v, want 0600", info.Mode())
+	}
+
+	replay, err := newReplayTransport(path)
+	if err != nil {
+		t.Fatalf("newReplayTransport() unexpected error = %v", err)
+	}
+	replaying := &http.Client{Transport: replay}
+	if unused := replay.unused(); len(unused) != len(requests)+len(omitted) {
+		t.Errorf("unused() = %q before replaying, want all requests", unused)
+	}
+	for i, uri := range requests {
+		code, body, err := get(replaying, uri)
+		if err != nil {
+			t.Fatalf("replaying GET %s: %v", uri, err)
+		}
+		if got := http.StatusText(code) + " " + body; got != want[i] {
+			t.Errorf("replayed GET %s = %q, want %q", uri, got, want[i])
+		}
+	}
+	for _, uri := range omitted {
+		if _, _, err := get(replaying, uri); err == nil || !strings.Contains(err.Error(), "not recorded") {
+			t.Errorf("replaying GET %s error = %v, want a body not recorded error", uri, err)
+		}
+	}
+	if unused := replay.unused(); len(unused) != 0 {
+		t.Errorf("unused() = %q, want none", unused)
+	}
+
+	// Every exchange has been used up
+	if _, _, err := get(replaying, requests[0]); err == nil || !strings.Contains(err.Error(), "no recorded response for GET "+requests[0]) {
+		t.Errorf("replaying an extra request error = %v", err)
+	}
+}
+
+func TestNewReplayTransport_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data, want string
+	}{
+		{"json", "{\"method\":\"GET\",\"uri\":\"/_ping\",\"status\":200}\nnot json\n", "bad.jsonl:2:"},
+		{"fields", `{"method":"GET","status":200}`, "method, uri and status are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.jsonl")
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := newReplayTransport(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("newReplayTransport() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRemoteCLI_Replay(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	path := filepath.Join(tmpDir, "session.jsonl")
+	data := `{"method":"GET","uri":"/v3.0.0/containers/json?filters=%7B%22label%22%3A%5B%22owner%3Dfleet%22%5D%7D","status":200,"body":"[]"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// No host is needed, nor SSH configuration for it
+	cli, err := NewRemoteCLI([]string{"-replay", path, "list_containers", "-filter", "label=owner=fleet"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	sshClient, httpClient, err := cli.connect(context.Background())
+	if err != nil {
+		t.Fatalf("connect() unexpected error = %v", err)
+	}
+	defer sshClient.Close()
+
+	var out bytes.Buffer
+	if code := cli.execute(context.Background(), httpClient, cli.command, cli.query, &out); code != 0 {
+		t.Errorf("execute() = %d, want 0", code)
+	}
+	if want := "Status: 200 OK\n[]\n"; out.String() != want {
+		t.Errorf("execute() output = %q, want %q", out.String(), want)
+	}
+
+	for _, args := range [][]string{
+		{"-replay", path, "-record", filepath.Join(tmpDir, "out.jsonl"), "ps"},
+		{"-replay", path, "-dry-run", "ps"},
+		{"-replay", filepath.Join(tmpDir, "missing.jsonl"), "ps"},
+	} {
+		if _, err := NewRemoteCLI(args); err == nil {
+			t.Errorf("NewRemoteCLI(%q) error = nil, want an error", args)
+		}
+	}
+}
+
+func TestRecording_PerClientTransport(t *testing.T) {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := newRecording(path)
+	if err != nil {
+		t.Fatalf("newRecording() unexpected error = %v", err)
+	}
+	// As for copy_image, each host has its own client, both recorded
+	source := &http.Client{Transport: recorder.transport(newTestHTTPClient(t, handler("source")).Transport)}
+	destination := &http.Client{Transport: recorder.transport(newTestHTTPClient(t, handler("destination")).Transport)}
+
+	for _, tt := range []struct {
+		c    *http.Client
+		want string
+	}{{source, "source"}, {destination, "destination"}, {source, "source"}} {
+		resp, err := tt.c.Get("http://localhost/_ping")
+		if err != nil {
+			t.Fatalf("GET unexpected error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.want {
+			t.Errorf("GET answered by %q, want %q", body, tt.want)
+		}
+	}
+	if err := recorder.close(); err != nil {
+		t.Fatalf("close() unexpected error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if got := strings.Count(string(data), "\n"); got != 3 {
+		t.Errorf("recording has %d exchanges, want 3", got)
+	}
+}
@@ -0,0 +1,465 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/signature"
+	"golang.org/x/term"
+)
+
+// newLoginCommand returns the "login" command, which stores registry
+// credentials in the local auth file. When -host is given, the credentials
+// are first verified by the remote host against the registry.
+func newLoginCommand(fs *flag.FlagSet) runFunc {
+	var username, password, authFile string
+	var passwordStdin bool
+	fs.StringVar(&username, "u", "", "Registry username")
+	fs.StringVar(&password, "p", "", "Registry password (prefer -password-stdin)")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "Read the password from stdin")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("login: exactly one registry is required")
+			return 1
+		}
+		registry := rc.args[0]
+
+		username, password, err := readCredentials(username, password, passwordStdin)
+		if err != nil {
+			slog.Error("login", "err", err)
+			return 1
+		}
+
+		if rc.host != "" {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			sshClient, httpClient, err := rc.connect(ctx)
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+				return 1
+			}
+			defer sshClient.Close()
+
+			if err := checkLogin(ctx, httpClient, registry, username, password); err != nil {
+				slog.Error("login", "target", registry, "err", err)
+				return 1
+			}
+		}
+
+		f, err := auth.Load(authFile)
+		if err != nil {
+			slog.Error("login", "err", err)
+			return 1
+		}
+		f.Set(registry, username, password)
+		if err := f.Save(authFile); err != nil {
+			slog.Error("login", "err", err)
+			return 1
+		}
+
+		fmt.Println("Login Succeeded!")
+		return 0
+	}
+}
+
+// newLogoutCommand returns the "logout" command, which removes registry
+// credentials from the local auth file.
+func newLogoutCommand(fs *flag.FlagSet) runFunc {
+	var authFile string
+	var all bool
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.BoolVar(&all, "a", false, "Remove the credentials for all registries")
+
+	return func(rc *RemoteCLI) int {
+		if (all && len(rc.args) != 0) || (!all && len(rc.args) != 1) {
+			slog.Error("logout: specify exactly one registry, or -a")
+			return 1
+		}
+
+		f, err := auth.Load(authFile)
+		if err != nil {
+			slog.Error("logout", "err", err)
+			return 1
+		}
+
+		if all {
+			f.Auths = map[string]auth.Entry{}
+		} else if !f.Remove(rc.args[0]) {
+			slog.Error("logout: not logged in", "registry", rc.args[0])
+			return 1
+		}
+
+		if err := f.Save(authFile); err != nil {
+			slog.Error("logout", "err", err)
+			return 1
+		}
+
+		if all {
+			fmt.Println("Removed login credentials for all registries")
+		} else {
+			fmt.Println("Removed login credentials for", rc.args[0])
+		}
+		return 0
+	}
+}
+
+// newPullImageCommand returns the "pull_image" command, which pulls an image
+// on the remote host using credentials from the local auth file.
+func newPullImageCommand(fs *flag.FlagSet) runFunc {
+	var authFile, policyFile string
+	var tlsVerify bool
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when contacting the registry")
+	fs.StringVar(&policyFile, "verify", "", "Signature policy (containers-policy.json) the image must meet before it is pulled")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("pull_image: exactly one image reference is required")
+			return 1
+		}
+		image := rc.args[0]
+
+		authHeader, err := registryAuth(authFile, image)
+		if err != nil {
+			slog.Error("pull_image", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var verified signature.Reference
+		if policyFile != "" {
+			if verified, err = verifyImage(ctx, policyFile, authFile, image, tlsVerify); err != nil {
+				slog.Error("pull_image: refusing to pull the image", "target", image, "err", err)
+				return 1
+			}
+			slog.Info("Verified image signatures", "target", image, "digest", verified.Digest)
+		}
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		events := rc.eventStream(os.Stdout, "pull_image")
+		if policyFile != "" {
+			err = pullVerifiedImage(ctx, httpClient, verified, tlsVerify, authHeader, os.Stdout, events)
+		} else {
+			err = pullImage(ctx, httpClient, image, tlsVerify, authHeader, os.Stdout, events)
+		}
+		if err != nil {
+			if events != nil {
+				events.emit(streamEvent{Type: eventError, Message: err.Error()})
+			}
+			slog.Error("pull_image", "target", image, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// pullImage pulls image on the remote host, writing the progress messages
+// reported by Podman to out, or to events when it is not nil.
+func pullImage(ctx context.Context, httpClient *http.Client, image string, tlsVerify bool, authHeader string, out io.Writer, events *eventStream) error {
+	query := url.Values{"reference": {image}, "tlsVerify": {strconv.FormatBool(tlsVerify)}}
+	req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/libpod/images/pull", query, nil)
+	if err != nil {
+		return err
+	}
+	if authHeader != "" {
+		req.Header.Set(auth.Header, authHeader)
+	}
+
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of JSON objects carrying either progress
+	// text, an error, or the final image IDs
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var report struct {
+			Stream string   `json:"stream"`
+			Error  string   `json:"error"`
+			Images []string `json:"images"`
+		}
+		if err := dec.Decode(&report); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if report.Error != "" {
+			return errors.New(report.Error)
+		}
+		if events != nil {
+			if msg := strings.TrimSpace(report.Stream); msg != "" {
+				events.emit(streamEvent{Type: eventStatus, Message: msg})
+			}
+			for _, id := range report.Images {
+				events.emit(streamEvent{Type: eventResult, ID: id})
+			}
+			continue
+		}
+		io.WriteString(out, report.Stream)
+		for _, id := range report.Images {
+			fmt.Fprintln(out, id)
+		}
+	}
+}
+
+// verifyImage checks image against the signature policy in policyFile,
+// reading its manifest and signatures from its registry with the
+// credentials stored in authFile, and returns its reference pinned to the
+// verified digest.
+func verifyImage(ctx context.Context, policyFile, authFile, image string, tlsVerify bool) (signature.Reference, error) {
+	policy, err := signature.LoadPolicy(policyFile)
+	if err != nil {
+		return signature.Reference{}, err
+	}
+	f, err := auth.Load(authFile)
+	if err != nil {
+		return signature.Reference{}, err
+	}
+	reg := &signature.Registry{}
+	reg.Username, reg.Password, _ = f.Get(auth.RegistryFromImage(image))
+	if !tlsVerify {
+		reg.Client = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+	return policy.Verify(ctx, reg, image)
+}
+
+// pullVerifiedImage pulls the image verified by verifyImage on the remote
+// host by the digest that was verified, so that the host gets that image
+// even if the tag moved meanwhile, then tags it with the verified tag.
+func pullVerifiedImage(ctx context.Context, httpClient *http.Client, verified signature.Reference, tlsVerify bool, authHeader string, out io.Writer, events *eventStream) error {
+	reference := verified.Repository() + "@" + verified.Digest
+	if err := pullImage(ctx, httpClient, reference, tlsVerify, authHeader, out, events); err != nil {
+		return err
+	}
+	if verified.Tag == "" {
+		return nil
+	}
+	return tagImage(ctx, httpClient, reference, verified.Repository(), verified.Tag)
+}
+
+// tagImage tags the image named name on the remote host as repo:tag.
+func tagImage(ctx context.Context, httpClient *http.Client, name, repo, tag string) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/tag", url.Values{"repo": {repo}, "tag": {tag}}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// registryAuth returns the X-Registry-Auth header value for the registry of
+// image from the auth file, or "" if no credentials are stored.
+func registryAuth(authFile, image string) (string, error) {
+	f, err := auth.Load(authFile)
+	if err != nil {
+		return "", err
+	}
+	return f.HeaderValue(auth.RegistryFromImage(image))
+}
+
+// registryConfig returns the X-Registry-Config header value carrying the
+// credentials of every registry in the auth file, or "" if none are
+// stored.
+func registryConfig(authFile string) (string, error) {
+	f, err := auth.Load(authFile)
+	if err != nil {
+		return "", err
+	}
+	return f.ConfigHeaderValue()
+}
+
+// checkLogin asks the remote host to verify the credentials against
+// registry.
+func checkLogin(ctx context.Context, httpClient *http.Client, registry, username, password string) error {
+	body, err := json.Marshal(map[string]string{
+		"username":      username,
+		"password":      password,
+		"serveraddress": registry,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/auth", nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readCredentials completes the username and password given on the command
+// line, reading the password from stdin or prompting on the terminal for
+// whatever is missing.
+func readCredentials(username, password string, passwordStdin bool) (string, string, error) {
+	if passwordStdin {
+		if password != "" {
+			return "", "", errors.New("-p and -password-stdin are mutually exclusive")
+		}
+		if username == "" {
+			return "", "", errors.New("-u is required with -password-stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", "", err
+		}
+		return username, strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if username != "" && password != "" {
+		return username, password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", "", errors.New("credentials must be given with -u and -p or -password-stdin when stdin is not a terminal")
+	}
+
+	if username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		username = strings.TrimSpace(line)
+	}
+
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", "", err
+		}
+		password = string(data)
+	}
+
+	if username == "" || password == "" {
+		return "", "", errors.New("username and password are required")
+	}
+	return username, password, nil
+}
+
+// newPushImageCommand returns the "push_image" command, which pushes an
+// image from the remote host to a registry, rendering per-layer progress.
+func newPushImageCommand(fs *flag.FlagSet) runFunc {
+	var authFile, destination string
+	var tlsVerify bool
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+	fs.StringVar(&destination, "destination", "", "Destination reference to push to (defaults to the image name)")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when contacting the registry")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("push_image: exactly one image name or ID is required")
+			return 1
+		}
+		image := rc.args[0]
+		if destination == "" {
+			destination = image
+		}
+
+		authHeader, err := registryAuth(authFile, destination)
+		if err != nil {
+			slog.Error("push_image", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		lp := newLayerProgress(os.Stdout, progressTerminal(os.Stdout))
+		lp.events = rc.eventStream(os.Stdout, "push_image")
+		if err := pushImage(ctx, httpClient, image, destination, tlsVerify, authHeader, lp); err != nil {
+			if lp.events != nil {
+				lp.events.emit(streamEvent{Type: eventError, Message: err.Error()})
+			}
+			slog.Error("push_image", "target", image, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// pushImage pushes image to destination from the remote host, rendering the
+// streamed progress messages with lp.
+func pushImage(ctx context.Context, httpClient *http.Client, image, destination string, tlsVerify bool, authHeader string, lp *layerProgress) error {
+	query := url.Values{"destination": {destination}, "tlsVerify": {strconv.FormatBool(tlsVerify)}}
+	req, err := newAPIRequest(ctx, http.MethodPost, "/v3.0.0/images/"+url.PathEscape(image)+"/push", query, nil)
+	if err != nil {
+		return err
+	}
+	if authHeader != "" {
+		req.Header.Set(auth.Header, authHeader)
+	}
+
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg jsonMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if msg.ErrorDetail.Message != "" {
+			return errors.New(msg.ErrorDetail.Message)
+		}
+		lp.update(msg)
+	}
+}
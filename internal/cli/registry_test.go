@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/signature"
+)
+
+func TestPullImage_SendsAuthHeader(t *testing.T) {
+	var gotAuth, gotReference string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get(auth.Header)
+		gotReference = r.URL.Query().Get("reference")
+		w.Write([]byte(`{"stream":"Copying blob 123\n"}` + "\n" + `{"images":["abc123"],"id":"abc123"}`))
+	}))
+
+	var out bytes.Buffer
+	err := pullImage(context.Background(), httpClient, "quay.io/team/app:v1", true, "encoded-auth", &out, nil)
+	if err != nil {
+		t.Fatalf("pullImage() unexpected error = %v", err)
+	}
+
+	if gotAuth != "encoded-auth" {
+		t.Errorf("pullImage() %s = %q, want %q", auth.Header, gotAuth, "encoded-auth")
+	}
+	if gotReference != "quay.io/team/app:v1" {
+		t.Errorf("pullImage() reference = %q, want %q", gotReference, "quay.io/team/app:v1")
+	}
+	if out.String() != "Copying blob 123\nabc123\n" {
+		t.Errorf("pullImage() output = %q", out.String())
+	}
+}
+
+func TestPullImage_EventStream(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"stream":"Copying blob 123\n"}` + "\n" + `{"images":["abc123"],"id":"abc123"}`))
+	}))
+
+	var out bytes.Buffer
+	err := pullImage(context.Background(), httpClient, "app", true, "", &bytes.Buffer{}, newEventStream(&out, "edge1", "pull_image"))
+	if err != nil {
+		t.Fatalf("pullImage() unexpected error = %v", err)
+	}
+
+	var got []streamEvent
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var ev streamEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatalf("pullImage() wrote invalid JSON: %v", err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 2 {
+		t.Fatalf("pullImage() wrote %d events, want 2", len(got))
+	}
+	if got[0].Type != eventStatus || got[0].Message != "Copying blob 123" {
+		t.Errorf("pullImage() first event = %+v, want status", got[0])
+	}
+	if got[1].Type != eventResult || got[1].ID != "abc123" || got[1].Host != "edge1" {
+		t.Errorf("pullImage() second event = %+v, want result", got[1])
+	}
+}
+
+func TestPullImage_StreamError(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":"unauthorized: authentication required"}`))
+	}))
+
+	err := pullImage(context.Background(), httpClient, "private/app", true, "", &bytes.Buffer{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("pullImage() error = %v, want unauthorized", err)
+	}
+}
+
+func TestCheckLogin(t *testing.T) {
+	var got map[string]string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/auth" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"Status":"Login Succeeded"}`))
+	}))
+
+	if err := checkLogin(context.Background(), httpClient, "quay.io", "bob", "pw"); err != nil {
+		t.Fatalf("checkLogin() unexpected error = %v", err)
+	}
+	if got["username"] != "bob" || got["serveraddress"] != "quay.io" {
+		t.Errorf("checkLogin() sent %v", got)
+	}
+}
+
+func TestLoginLogout_AuthFile(t *testing.T) {
+	authFile := filepath.Join(t.TempDir(), "auth.json")
+
+	login := newTestCommand(t, newLoginCommand, "-authfile", authFile, "-u", "bob", "-p", "pw")
+	if code := login(&RemoteCLI{args: []string{"quay.io"}}); code != 0 {
+		t.Fatalf("login exit code = %d, want 0", code)
+	}
+
+	header, err := registryAuth(authFile, "quay.io/team/app")
+	if err != nil || header == "" {
+		t.Fatalf("registryAuth() = (%q, %v), want stored credentials", header, err)
+	}
+
+	logout := newTestCommand(t, newLogoutCommand, "-authfile", authFile)
+	if code := logout(&RemoteCLI{args: []string{"quay.io"}}); code != 0 {
+		t.Fatalf("logout exit code = %d, want 0", code)
+	}
+
+	header, err = registryAuth(authFile, "quay.io/team/app")
+	if err != nil || header != "" {
+		t.Errorf("registryAuth() after logout = (%q, %v), want no credentials", header, err)
+	}
+
+	if code := logout(&RemoteCLI{args: []string{"quay.io"}}); code != 1 {
+		t.Errorf("logout of unknown registry exit code = %d, want 1", code)
+	}
+}
+
+func TestPushImage(t *testing.T) {
+	var gotPath, gotDestination, gotTLS string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotDestination = r.URL.Query().Get("destination")
+		gotTLS = r.URL.Query().Get("tlsVerify")
+		w.Write([]byte(`{"status":"Pushing","id":"aaa","progressDetail":{"current":5,"total":10}}
+{"status":"Pushed","id":"aaa"}
+{"status":"latest: digest: sha256:123 size: 528"}
+`))
+	}))
+
+	var out bytes.Buffer
+	err := pushImage(context.Background(), httpClient, "app", "quay.io/team/app:v1", false, "", newLayerProgress(&out, false))
+	if err != nil {
+		t.Fatalf("pushImage() unexpected error = %v", err)
+	}
+
+	if gotPath != "/v3.0.0/images/app/push" || gotDestination != "quay.io/team/app:v1" || gotTLS != "false" {
+		t.Errorf("pushImage() request = %s destination=%s tlsVerify=%s", gotPath, gotDestination, gotTLS)
+	}
+	if !strings.Contains(out.String(), "aaa: Pushed") || !strings.Contains(out.String(), "digest: sha256:123") {
+		t.Errorf("pushImage() output = %q", out.String())
+	}
+}
+
+func TestPushImage_ErrorDetail(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errorDetail":{"message":"denied: requested access to the resource is denied"}}`))
+	}))
+
+	err := pushImage(context.Background(), httpClient, "app", "app", true, "", newLayerProgress(&bytes.Buffer{}, false))
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("pushImage() error = %v, want denied", err)
+	}
+}
+
+func TestPullVerifiedImage_PullsByDigestAndTags(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	var requests []string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.RawQuery)
+		if strings.HasSuffix(r.URL.Path, "/tag") {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Write([]byte(`{"images":["abc123"],"id":"abc123"}`))
+	}))
+
+	verified := signature.Reference{Registry: "quay.io", Path: "team/app", Tag: "v1", Digest: digest}
+	if err := pullVerifiedImage(context.Background(), httpClient, verified, true, "", &bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("pullVerifiedImage() unexpected error = %v", err)
+	}
+
+	want := []string{
+		"POST /v3.0.0/libpod/images/pull reference=quay.io%2Fteam%2Fapp%40" + strings.Replace(digest, ":", "%3A", 1) + "&tlsVerify=true",
+		"POST /v3.0.0/libpod/images/quay.io/team/app@" + digest + "/tag repo=quay.io%2Fteam%2Fapp&tag=v1",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("pullVerifiedImage() requests =\n%s\nwant\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPullImageCommand_VerifyRefused(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(policy, []byte(`{"default": [{"type": "reject"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image is refused before connecting to the host
+	run := newTestCommand(t, newPullImageCommand, "-verify", policy, "-authfile", filepath.Join(dir, "auth.json"))
+	if code := run(&RemoteCLI{args: []string{"quay.io/team/app:v1"}}); code != 1 {
+		t.Errorf("pull_image exit code = %d, want 1", code)
+	}
+}
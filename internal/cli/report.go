@@ -0,0 +1,418 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// hostReport is the document written by the report command.
+type hostReport struct {
+	Host       string            `json:"host"`
+	Generated  time.Time         `json:"generated"`
+	System     reportSystem      `json:"system"`
+	Containers []reportContainer `json:"containers"`
+	Images     []reportImage     `json:"images"`
+	Disk       []reportDisk      `json:"disk"`
+	Stats      []reportStats     `json:"stats"`
+	Errors     []string          `json:"errors,omitempty"` // sections that could not be collected
+}
+
+// reportSystem describes the remote host and its Podman installation.
+type reportSystem struct {
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`
+	Distribution  string `json:"distribution"`
+	Kernel        string `json:"kernel"`
+	Arch          string `json:"arch"`
+	CPUs          int    `json:"cpus"`
+	MemTotal      int64  `json:"memTotal"`
+	MemFree       int64  `json:"memFree"`
+	Uptime        string `json:"uptime"`
+	PodmanVersion string `json:"podmanVersion"`
+	GraphDriver   string `json:"graphDriver"`
+	GraphRoot     string `json:"graphRoot"`
+}
+
+type reportContainer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Image  string `json:"image"`
+	State  string `json:"state"`
+	Status string `json:"status"`
+}
+
+type reportImage struct {
+	ID      string    `json:"id"`
+	Tags    []string  `json:"tags"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// reportDisk summarizes the disk space used by one kind of object, as
+// podman system df does.
+type reportDisk struct {
+	Type        string `json:"type"`
+	Total       int    `json:"total"`
+	Active      int    `json:"active"`
+	Size        int64  `json:"size"`
+	Reclaimable int64  `json:"reclaimable"`
+}
+
+// reportStats is the resource usage of a running container at the time of
+// the report.
+type reportStats struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	CPU         float64 `json:"cpuPercent"`
+	MemUsage    int64   `json:"memUsage"`
+	MemLimit    int64   `json:"memLimit"`
+	NetInput    int64   `json:"netInput"`
+	NetOutput   int64   `json:"netOutput"`
+	BlockInput  int64   `json:"blockInput"`
+	BlockOutput int64   `json:"blockOutput"`
+	PIDs        int64   `json:"pids"`
+}
+
+// newReportCommand returns the "report" command, which collects the state
+// of the remote host into a single document to attach to support tickets:
+// Markdown with the text format, JSON otherwise.
+func newReportCommand(fs *flag.FlagSet) runFunc {
+	var output string
+	fs.StringVar(&output, "o", "", "Write the report to this file instead of stdout")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) > 0 {
+			slog.Error("report: no arguments are accepted")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		report := collectReport(ctx, httpClient, rc.host, time.Now())
+
+		var out io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				slog.Error("report", "err", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeReport(out, rc.opts.format, report); err != nil {
+			slog.Error("report", "err", err)
+			return 1
+		}
+		if len(report.Errors) > 0 {
+			slog.Error("report: some sections could not be collected", "errors", strings.Join(report.Errors, "; "))
+			return 1
+		}
+		return 0
+	}
+}
+
+// collectReport gathers the report of host. Sections that cannot be
+// collected are left empty and their errors recorded in the report.
+func collectReport(ctx context.Context, httpClient *http.Client, host string, now time.Time) *hostReport {
+	report := &hostReport{Host: host, Generated: now.UTC()}
+	for _, section := range []struct {
+		name    string
+		collect func(context.Context, *http.Client, *hostReport) error
+	}{
+		{"system", reportSystemInfo},
+		{"containers", reportContainers},
+		{"images", reportImages},
+		{"disk", reportDiskUsage},
+		{"stats", reportContainerStats},
+	} {
+		if err := section.collect(ctx, httpClient, report); err != nil {
+			report.Errors = append(report.Errors, section.name+": "+err.Error())
+		}
+	}
+	return report
+}
+
+func reportSystemInfo(ctx context.Context, httpClient *http.Client, report *hostReport) error {
+	var info struct {
+		Host struct {
+			Hostname     string `json:"hostname"`
+			OS           string `json:"os"`
+			Kernel       string `json:"kernel"`
+			Arch         string `json:"arch"`
+			CPUs         int    `json:"cpus"`
+			MemTotal     int64  `json:"memTotal"`
+			MemFree      int64  `json:"memFree"`
+			Uptime       string `json:"uptime"`
+			Distribution struct {
+				Distribution string `json:"distribution"`
+				Version      string `json:"version"`
+			} `json:"distribution"`
+		} `json:"host"`
+		Store struct {
+			GraphDriverName string `json:"graphDriverName"`
+			GraphRoot       string `json:"graphRoot"`
+		} `json:"store"`
+		Version struct {
+			Version string `json:"Version"`
+		} `json:"version"`
+	}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/info", nil, &info); err != nil {
+		return err
+	}
+	h := info.Host
+	report.System = reportSystem{
+		Hostname:      h.Hostname,
+		OS:            h.OS,
+		Distribution:  strings.TrimSpace(h.Distribution.Distribution + " " + h.Distribution.Version),
+		Kernel:        h.Kernel,
+		Arch:          h.Arch,
+		CPUs:          h.CPUs,
+		MemTotal:      h.MemTotal,
+		MemFree:       h.MemFree,
+		Uptime:        h.Uptime,
+		PodmanVersion: info.Version.Version,
+		GraphDriver:   info.Store.GraphDriverName,
+		GraphRoot:     info.Store.GraphRoot,
+	}
+	return nil
+}
+
+func reportContainers(ctx context.Context, httpClient *http.Client, report *hostReport) error {
+	var containers []models.ListContainer
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/json", url.Values{"all": {"true"}}, &containers); err != nil {
+		return err
+	}
+	report.Containers = make([]reportContainer, 0, len(containers))
+	for _, c := range containers {
+		report.Containers = append(report.Containers, reportContainer{
+			ID:     c.ID,
+			Name:   strings.Join(c.Names, ","),
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+		})
+	}
+	sort.Slice(report.Containers, func(i, j int) bool { return report.Containers[i].Name < report.Containers[j].Name })
+	return nil
+}
+
+func reportImages(ctx context.Context, httpClient *http.Client, report *hostReport) error {
+	var images []models.ImageSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/json", nil, &images); err != nil {
+		return err
+	}
+	report.Images = make([]reportImage, 0, len(images))
+	for _, img := range images {
+		report.Images = append(report.Images, reportImage{
+			ID:      img.ID,
+			Tags:    img.RepoTags,
+			Size:    img.Size,
+			Created: time.Unix(img.Created, 0).UTC(),
+		})
+	}
+	// Largest first, as the images are usually what fills the disk
+	sort.SliceStable(report.Images, func(i, j int) bool { return report.Images[i].Size > report.Images[j].Size })
+	return nil
+}
+
+func reportDiskUsage(ctx context.Context, httpClient *http.Client, report *hostReport) error {
+	var df struct {
+		Images []struct {
+			Size       int64 `json:"Size"`
+			UniqueSize int64 `json:"UniqueSize"`
+			Containers int   `json:"Containers"`
+		} `json:"Images"`
+		Containers []struct {
+			RWSize int64  `json:"RWSize"`
+			Status string `json:"Status"`
+		} `json:"Containers"`
+		Volumes []struct {
+			Links           int   `json:"Links"`
+			Size            int64 `json:"Size"`
+			ReclaimableSize int64 `json:"ReclaimableSize"`
+		} `json:"Volumes"`
+	}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/system/df", nil, &df); err != nil {
+		return err
+	}
+
+	images := reportDisk{Type: "Images", Total: len(df.Images)}
+	for _, img := range df.Images {
+		images.Size += img.UniqueSize
+		if img.Containers > 0 {
+			images.Active++
+		} else {
+			images.Reclaimable += img.UniqueSize
+		}
+	}
+	containers := reportDisk{Type: "Containers", Total: len(df.Containers)}
+	for _, c := range df.Containers {
+		containers.Size += c.RWSize
+		if c.Status == "running" {
+			containers.Active++
+		} else {
+			containers.Reclaimable += c.RWSize
+		}
+	}
+	volumes := reportDisk{Type: "Local Volumes", Total: len(df.Volumes)}
+	for _, v := range df.Volumes {
+		volumes.Size += v.Size
+		volumes.Reclaimable += v.ReclaimableSize
+		if v.Links > 0 {
+			volumes.Active++
+		}
+	}
+	report.Disk = []reportDisk{images, containers, volumes}
+	return nil
+}
+
+func reportContainerStats(ctx context.Context, httpClient *http.Client, report *hostReport) error {
+	var stats models.ContainerStatsReport
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/stats", url.Values{"stream": {"false"}}, &stats); err != nil {
+		return err
+	}
+	report.Stats = make([]reportStats, 0, len(stats.Stats))
+	for _, s := range stats.Stats {
+		report.Stats = append(report.Stats, reportStats{
+			ID:          s.ContainerID,
+			Name:        s.Name,
+			CPU:         s.CPU,
+			MemUsage:    s.MemUsage,
+			MemLimit:    s.MemLimit,
+			NetInput:    s.NetInput,
+			NetOutput:   s.NetOutput,
+			BlockInput:  s.BlockInput,
+			BlockOutput: s.BlockOutput,
+			PIDs:        s.PIDs,
+		})
+	}
+	sort.Slice(report.Stats, func(i, j int) bool { return report.Stats[i].Name < report.Stats[j].Name })
+	return nil
+}
+
+// writeReport writes report to out in the given -format: Markdown for
+// text, or a JSON document, indented for json.
+func writeReport(out io.Writer, format string, report *hostReport) error {
+	if format != formatText {
+		enc := json.NewEncoder(out)
+		if format == formatJSON {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(report)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Podman report: %s\n\nGenerated %s.\n", report.Host, report.Generated.Format(time.RFC3339))
+
+	s := report.System
+	b.WriteString("\n## System\n\n")
+	writeMarkdownTable(&b, []string{"Property", "Value"}, [][]string{
+		{"Hostname", s.Hostname},
+		{"OS", strings.TrimSpace(s.OS + " " + s.Distribution)},
+		{"Kernel", s.Kernel},
+		{"Architecture", s.Arch},
+		{"CPUs", fmt.Sprint(s.CPUs)},
+		{"Memory", fmt.Sprintf("%s total, %s free", formatSize(s.MemTotal), formatSize(s.MemFree))},
+		{"Uptime", s.Uptime},
+		{"Podman", s.PodmanVersion},
+		{"Storage", strings.TrimSpace(s.GraphDriver + " " + s.GraphRoot)},
+	})
+
+	b.WriteString("\n## Disk usage\n\n")
+	var rows [][]string
+	for _, d := range report.Disk {
+		rows = append(rows, []string{d.Type, fmt.Sprint(d.Total), fmt.Sprint(d.Active), formatSize(d.Size), formatSize(d.Reclaimable)})
+	}
+	writeMarkdownTable(&b, []string{"Type", "Total", "Active", "Size", "Reclaimable"}, rows)
+
+	fmt.Fprintf(&b, "\n## Containers (%d)\n\n", len(report.Containers))
+	rows = nil
+	for _, c := range report.Containers {
+		rows = append(rows, []string{c.Name, shortID(c.ID), c.Image, c.State, c.Status})
+	}
+	writeMarkdownTable(&b, []string{"Name", "ID", "Image", "State", "Status"}, rows)
+
+	b.WriteString("\n## Resource usage\n\n")
+	rows = nil
+	for _, st := range report.Stats {
+		mem := formatSize(st.MemUsage)
+		if st.MemLimit > 0 {
+			mem += " / " + formatSize(st.MemLimit)
+		}
+		rows = append(rows, []string{
+			st.Name,
+			fmt.Sprintf("%.1f%%", st.CPU),
+			mem,
+			formatSize(st.NetInput) + " / " + formatSize(st.NetOutput),
+			formatSize(st.BlockInput) + " / " + formatSize(st.BlockOutput),
+			fmt.Sprint(st.PIDs),
+		})
+	}
+	writeMarkdownTable(&b, []string{"Name", "CPU", "Memory", "Net I/O", "Block I/O", "PIDs"}, rows)
+
+	fmt.Fprintf(&b, "\n## Images (%d)\n\n", len(report.Images))
+	rows = nil
+	for _, img := range report.Images {
+		tags := strings.Join(img.Tags, ", ")
+		if tags == "" {
+			tags = "<none>"
+		}
+		rows = append(rows, []string{tags, shortID(img.ID), formatSize(img.Size), img.Created.Format(time.DateOnly)})
+	}
+	writeMarkdownTable(&b, []string{"Tags", "ID", "Size", "Created"}, rows)
+
+	if len(report.Errors) > 0 {
+		b.WriteString("\n## Errors\n\n")
+		for _, e := range report.Errors {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// writeMarkdownTable writes a Markdown table, or a note that there is
+// nothing to show if rows is empty.
+func writeMarkdownTable(b *strings.Builder, headers []string, rows [][]string) {
+	if len(rows) == 0 {
+		b.WriteString("None.\n")
+		return
+	}
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	writeRow := func(cols []string) {
+		b.WriteString("|")
+		for _, col := range cols {
+			b.WriteString(" " + escape.Replace(col) + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(headers)
+	b.WriteString(strings.Repeat("| --- ", len(headers)) + "|\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
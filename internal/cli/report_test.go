@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectReport(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/info":
+			w.Write([]byte(`{"host":{"hostname":"edge1","os":"linux","kernel":"6.1.0","arch":"arm64","cpus":4,"memTotal":4294967296,"memFree":1073741824,"uptime":"72h 3m","distribution":{"distribution":"debian","version":"12"}},"store":{"graphDriverName":"overlay","graphRoot":"/var/lib/containers/storage"},"version":{"Version":"4.9.3"}}`))
+		case "/v3.0.0/libpod/containers/json":
+			w.Write([]byte(`[{"Id":"aaaabbbbccccdddd","Names":["web"],"Image":"nginx","State":"running","Status":"Up 2 hours"}]`))
+		case "/v3.0.0/libpod/images/json":
+			w.Write([]byte(`[{"Id":"sha256:1111","RepoTags":["alpine:latest"],"Size":8388608,"Created":1714550400},{"Id":"sha256:2222","RepoTags":["nginx:latest"],"Size":196083712,"Created":1714550400}]`))
+		case "/v3.0.0/libpod/system/df":
+			w.Write([]byte(`{"Images":[{"Size":196083712,"UniqueSize":190000000,"Containers":1},{"Size":8388608,"UniqueSize":8388608,"Containers":0}],"Containers":[{"RWSize":1024,"Status":"running"}],"Volumes":[{"Links":0,"Size":2048,"ReclaimableSize":2048}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"stats unavailable"}`))
+		}
+	}))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	report := collectReport(context.Background(), httpClient, "edge1", now)
+	if report.System.Hostname != "edge1" || report.System.Distribution != "debian 12" || report.System.PodmanVersion != "4.9.3" {
+		t.Errorf("System = %+v", report.System)
+	}
+	if len(report.Containers) != 1 || report.Containers[0].Name != "web" {
+		t.Errorf("Containers = %+v", report.Containers)
+	}
+	if len(report.Images) != 2 || report.Images[0].Tags[0] != "nginx:latest" {
+		t.Errorf("Images = %+v, want the largest first", report.Images)
+	}
+	wantDisk := []reportDisk{
+		{Type: "Images", Total: 2, Active: 1, Size: 198388608, Reclaimable: 8388608},
+		{Type: "Containers", Total: 1, Active: 1, Size: 1024},
+		{Type: "Local Volumes", Total: 1, Size: 2048, Reclaimable: 2048},
+	}
+	for i, d := range wantDisk {
+		if i >= len(report.Disk) || report.Disk[i] != d {
+			t.Errorf("Disk = %+v, want %+v", report.Disk, wantDisk)
+			break
+		}
+	}
+	if len(report.Errors) != 1 || !strings.HasPrefix(report.Errors[0], "stats: ") {
+		t.Errorf("Errors = %q, want the stats error", report.Errors)
+	}
+
+	var md bytes.Buffer
+	if err := writeReport(&md, formatText, report); err != nil {
+		t.Fatalf("writeReport(text) unexpected error = %v", err)
+	}
+	for _, want := range []string{
+		"# Podman report: edge1\n",
+		"| Memory | 4.0GiB total, 1.0GiB free |\n",
+		"| Images | 2 | 1 | 189.2MiB | 8.0MiB |\n",
+		"| web | aaaabbbbcccc | nginx | running | Up 2 hours |\n",
+		"## Resource usage\n\nNone.\n",
+		"- stats: ",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown report missing %q:\n%s", want, md.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := writeReport(&js, formatJSON, report); err != nil {
+		t.Fatalf("writeReport(json) unexpected error = %v", err)
+	}
+	var decoded hostReport
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.System.Kernel != "6.1.0" || !decoded.Generated.Equal(now) {
+		t.Errorf("JSON report = %s, err = %v", js.String(), err)
+	}
+}
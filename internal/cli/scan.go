@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// Names of the scanners accepted by newImageScanner.
+const (
+	scannerTrivy = "trivy"
+	scannerGrype = "grype"
+)
+
+// scanTarget is what a scanner scans: a local docker-archive of the image,
+// or else a reference to it by digest, which the scanner fetches from the
+// registry itself.
+type scanTarget struct {
+	archive string
+	ref     string
+}
+
+// imageScanner is a locally installed vulnerability scanner.
+type imageScanner interface {
+	// command returns the command scanning target, with the scanner's
+	// own arguments args.
+	command(target scanTarget, args []string) *exec.Cmd
+}
+
+// newImageScanner returns the scanner named name, or the first of trivy
+// and grype found in PATH if name is empty.
+func newImageScanner(name string) (imageScanner, error) {
+	if name == "" {
+		for _, program := range []string{scannerTrivy, scannerGrype} {
+			if _, err := exec.LookPath(program); err == nil {
+				name = program
+				break
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("no scanner found in PATH (install %s or %s, or use -scanner-cmd)", scannerTrivy, scannerGrype)
+		}
+	}
+	switch name {
+	case scannerTrivy:
+		return trivyScanner{}, nil
+	case scannerGrype:
+		return grypeScanner{}, nil
+	}
+	return nil, fmt.Errorf("unknown scanner %q (use %s or %s, or -scanner-cmd)", name, scannerTrivy, scannerGrype)
+}
+
+// trivyScanner scans with Aqua Security's Trivy.
+type trivyScanner struct{}
+
+func (trivyScanner) command(target scanTarget, args []string) *exec.Cmd {
+	args = append([]string{"image"}, args...)
+	if target.archive != "" {
+		return exec.Command(scannerTrivy, append(args, "--input", target.archive)...)
+	}
+	return exec.Command(scannerTrivy, append(args, target.ref)...)
+}
+
+// grypeScanner scans with Anchore's Grype.
+type grypeScanner struct{}
+
+func (grypeScanner) command(target scanTarget, args []string) *exec.Cmd {
+	if target.archive != "" {
+		return exec.Command(scannerGrype, append(args, "docker-archive:"+target.archive)...)
+	}
+	return exec.Command(scannerGrype, append(args, "registry:"+target.ref)...)
+}
+
+// commandScanner runs a shell command scanning the image, for scanners
+// other than the built-in ones. The archive or reference is given in
+// PODMAN_CLI_SCAN_ARCHIVE or PODMAN_CLI_SCAN_REF, and the scanner's
+// arguments as the command's positional parameters.
+type commandScanner struct {
+	cmd string
+}
+
+func (s commandScanner) command(target scanTarget, args []string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", append([]string{"/C", s.cmd}, args...)...)
+	} else {
+		cmd = exec.Command("/bin/sh", append([]string{"-c", s.cmd, "sh"}, args...)...)
+	}
+	cmd.Env = append(os.Environ(), "PODMAN_CLI_SCAN_ARCHIVE="+target.archive, "PODMAN_CLI_SCAN_REF="+target.ref)
+	return cmd
+}
+
+// newScanCommand returns the "scan" command, which scans an image of the
+// remote host for vulnerabilities with a locally installed scanner. The
+// image is exported from the host to a temporary archive, or, with
+// -by-digest, scanned by the scanner from its registry by the digest the
+// host pulled, so that it need not be pushed anywhere first. Arguments
+// after the image are passed to the scanner, and the command exits with
+// the scanner's exit code.
+func newScanCommand(fs *flag.FlagSet) runFunc {
+	var scannerName, scannerCmd string
+	var byDigest bool
+	fs.StringVar(&scannerName, "scanner", "", "Scanner to run: trivy or grype (default the first found in PATH)")
+	fs.StringVar(&scannerCmd, "scanner-cmd", "", "Shell command running another scanner, given $PODMAN_CLI_SCAN_ARCHIVE or $PODMAN_CLI_SCAN_REF")
+	fs.BoolVar(&byDigest, "by-digest", false, "Have the scanner fetch the image from its registry by digest instead of exporting it")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("scan: usage: scan [flags] <image> [-- <scanner argument>...]")
+			return 1
+		}
+		name, args := rc.args[0], rc.args[1:]
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if scannerName != "" && scannerCmd != "" {
+			slog.Error("scan: -scanner cannot be combined with -scanner-cmd")
+			return 1
+		}
+		var scanner imageScanner = commandScanner{scannerCmd}
+		if scannerCmd == "" {
+			var err error
+			if scanner, err = newImageScanner(scannerName); err != nil {
+				slog.Error("scan", "err", err)
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		var img models.ImageData
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/json", nil, &img); err != nil {
+			slog.Error("scan", "target", name, "err", err)
+			return 1
+		}
+		var target scanTarget
+		if byDigest {
+			if len(img.RepoDigests) == 0 {
+				slog.Error("scan: the image has no repository digest, as it was not pulled from a registry (scan it without -by-digest)", "target", name)
+				return 1
+			}
+			target.ref = img.RepoDigests[0]
+		} else {
+			if target.archive, err = exportImage(ctx, httpClient, name, img.Size); err != nil {
+				slog.Error("scan", "target", name, "err", err)
+				return 1
+			}
+			defer os.Remove(target.archive)
+		}
+
+		cmd := scanner.command(target, args)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		slog.Debug("Running scanner", "command", cmd.String())
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return 0
+		case errors.As(err, &exitErr) && exitErr.ExitCode() > 0:
+			// Scanners exit non-zero on findings when asked to
+			return exitErr.ExitCode()
+		}
+		slog.Error("scan", "err", err)
+		return 1
+	}
+}
+
+// exportImage exports the named image of the remote host, of about size
+// bytes, to a temporary docker-archive, returning its path.
+func exportImage(ctx context.Context, httpClient *http.Client, name string, size int64) (string, error) {
+	f, err := os.CreateTemp("", "podman-cli-scan-*.tar")
+	if err != nil {
+		return "", err
+	}
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/get", url.Values{"format": {"docker-archive"}}, nil)
+	if err == nil {
+		pw := newProgressWriter(f, "Exporting "+name, size)
+		_, err = io.Copy(pw, resp.Body)
+		pw.Close()
+		resp.Body.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
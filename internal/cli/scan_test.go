@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestImageScannerCommands(t *testing.T) {
+	archive := scanTarget{archive: "/tmp/image.tar"}
+	ref := scanTarget{ref: "docker.io/library/nginx@sha256:abc"}
+	tests := []struct {
+		name    string
+		scanner imageScanner
+		target  scanTarget
+		want    []string
+	}{
+		{"trivy archive", trivyScanner{}, archive, []string{"trivy", "image", "--severity", "HIGH", "--input", "/tmp/image.tar"}},
+		{"trivy ref", trivyScanner{}, ref, []string{"trivy", "image", "--severity", "HIGH", "docker.io/library/nginx@sha256:abc"}},
+		{"grype archive", grypeScanner{}, archive, []string{"grype", "--severity", "HIGH", "docker-archive:/tmp/image.tar"}},
+		{"grype ref", grypeScanner{}, ref, []string{"grype", "--severity", "HIGH", "registry:docker.io/library/nginx@sha256:abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.scanner.command(tt.target, []string{"--severity", "HIGH"})
+			if !reflect.DeepEqual(cmd.Args, tt.want) {
+				t.Errorf("command() = %q, want %q", cmd.Args, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	cmd := commandScanner{`echo "$PODMAN_CLI_SCAN_ARCHIVE $1"`}.command(scanTarget{archive: "/tmp/image.tar"}, []string{"--quiet"})
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("command() failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "/tmp/image.tar --quiet" {
+		t.Errorf("command() printed %q, want the archive and argument", got)
+	}
+}
+
+func TestNewImageScanner(t *testing.T) {
+	if _, err := newImageScanner("clair"); err == nil {
+		t.Error("newImageScanner(clair) expected error, got nil")
+	}
+	if s, err := newImageScanner(scannerGrype); err != nil || s != (grypeScanner{}) {
+		t.Errorf("newImageScanner(grype) = %v, %v, want grype", s, err)
+	}
+	t.Setenv("PATH", t.TempDir())
+	if _, err := newImageScanner(""); err == nil {
+		t.Error("newImageScanner() without scanners in PATH expected error, got nil")
+	}
+}
+
+func TestExportImage(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/images/nginx:1.27/get" || r.URL.Query().Get("format") != "docker-archive" {
+			http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("archive"))
+	}))
+
+	path, err := exportImage(context.Background(), httpClient, "nginx:1.27", 7)
+	if err != nil {
+		t.Fatalf("exportImage() unexpected error = %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); string(data) != "archive" {
+		t.Errorf("exportImage() wrote %q, want the archive", data)
+	}
+
+	if path, err := exportImage(context.Background(), httpClient, "missing", 0); err == nil {
+		os.Remove(path)
+		t.Error("exportImage(missing) expected error, got nil")
+	}
+}
+
+func TestScanCommand_Validation(t *testing.T) {
+	if code := newTestCommand(t, newScanCommand)(&RemoteCLI{}); code != 1 {
+		t.Errorf("scan without an image = %d, want 1", code)
+	}
+	run := newTestCommand(t, newScanCommand, "-scanner", "trivy", "-scanner-cmd", "true")
+	if code := run(&RemoteCLI{args: []string{"nginx"}}); code != 1 {
+		t.Errorf("scan -scanner -scanner-cmd = %d, want 1", code)
+	}
+}
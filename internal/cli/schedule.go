@@ -0,0 +1,494 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// scheduleJob is a job of the schedule configuration file: a podman-cli
+// command run on each of its hosts, or once without a host, whenever its
+// cron expression matches.
+type scheduleJob struct {
+	name        string
+	spec        string
+	cron        *cronSchedule
+	command     []string
+	hosts       []string
+	timeout     time.Duration // limit of each run on a host, 0 for none
+	maxParallel int
+}
+
+// jobStatus is the state of a job, as served by the status endpoint.
+type jobStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Command  []string   `json:"command"`
+	Hosts    []string   `json:"hosts,omitempty"`
+	Running  bool       `json:"running"`
+	Next     *time.Time `json:"next,omitempty"`
+	Runs     int        `json:"runs"`
+	Failures int        `json:"failures"`
+	Last     *jobRun    `json:"last,omitempty"`
+}
+
+// jobRun is the outcome of the last run of a job.
+type jobRun struct {
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Results []hostResult `json:"results"`
+}
+
+// hostResult is the outcome of a run of a job on a host.
+type hostResult struct {
+	Host     string `json:"host,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+func (r hostResult) failed() bool { return r.ExitCode != 0 || r.Error != "" }
+
+// newScheduleCommand returns the "schedule" command, which runs as a
+// long-lived agent executing the jobs of a configuration file on their
+// cron schedules. Each run starts podman-cli again with the global flags
+// given before "schedule", so any command can be scheduled.
+func newScheduleCommand(fs *flag.FlagSet) runFunc {
+	var jobsFile, logDir, listen string
+	var check bool
+	fs.StringVar(&jobsFile, "jobs", "", "Path of the YAML file defining the jobs")
+	fs.StringVar(&logDir, "log-dir", "", "Append the output of each job to <job>.log in this directory instead of stderr")
+	fs.StringVar(&listen, "listen", "", "Serve the status of the jobs as JSON on this loopback address, such as 127.0.0.1:9090, or unix://<path>")
+	fs.BoolVar(&check, "check", false, "Validate the jobs and print their next run instead of running them")
+
+	return func(rc *RemoteCLI) int {
+		if jobsFile == "" || len(rc.args) != 0 {
+			slog.Error("schedule: usage: schedule -jobs <jobs.yaml> [-log-dir <dir>] [-listen <addr>]")
+			return 1
+		}
+		jobs, err := loadScheduleJobs(jobsFile, rc.opts.configFile)
+		if err != nil {
+			slog.Error("schedule", "err", err)
+			return 1
+		}
+		if check {
+			if err := writeScheduleCheck(os.Stdout, rc.opts.format, jobs, time.Now()); err != nil {
+				slog.Error("schedule", "err", err)
+				return 1
+			}
+			return 0
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			slog.Error("schedule", "err", err)
+			return 1
+		}
+		if logDir != "" {
+			if err := os.MkdirAll(logDir, 0700); err != nil {
+				slog.Error("schedule", "err", err)
+				return 1
+			}
+		}
+		s := newScheduler(jobs, commandRunner(exe, rc.globalArgs), logDir, os.Stderr)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if listen != "" {
+			ln, err := listenLocal(listen, false)
+			if err != nil {
+				slog.Error("schedule", "err", err)
+				return 1
+			}
+			srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+			go srv.Serve(ln)
+			defer srv.Close()
+			slog.Info("Serving job status", "listen", ln.Addr().Network()+"://"+ln.Addr().String())
+		}
+
+		slog.Info("Scheduler started", "jobs", len(jobs))
+		s.runUntil(ctx)
+		slog.Info("Scheduler stopped")
+		return 0
+	}
+}
+
+// loadScheduleJobs loads the jobs of the file at path, resolving @<group>
+// hosts with the podman-cli configuration file at configFile.
+func loadScheduleJobs(path, configFile string) ([]*scheduleJob, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := parseScheduleJobs(data, func(list string) ([]string, error) {
+		return resolveHosts(list, configFile)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return jobs, nil
+}
+
+// parseScheduleJobs parses the jobs of a schedule configuration file,
+// sorted by name, with resolve returning the hosts of a comma separated
+// list. The file maps job names to their settings under "jobs":
+//
+//	jobs:
+//	  prune:
+//	    schedule: "0 3 * * *"
+//	    hosts: [edge1, "@fleet"]
+//	    command: prune -all -yes
+//	    timeout: 30m
+//	    max_parallel: 4
+func parseScheduleJobs(data []byte, resolve func(list string) ([]string, error)) ([]*scheduleJob, error) {
+	tree, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := composeMap(tree, "top level")
+	if err != nil {
+		return nil, err
+	}
+	for key := range root {
+		if key != "jobs" {
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	defs, err := composeMap(root["jobs"], "jobs")
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, errors.New("no jobs defined")
+	}
+
+	var jobs []*scheduleJob
+	for _, name := range sortedKeys(defs) {
+		job, err := parseScheduleJob(name, defs[name], resolve)
+		if err != nil {
+			return nil, fmt.Errorf("jobs.%s: %w", name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// parseScheduleJob parses the settings of the named job.
+func parseScheduleJob(name string, def any, resolve func(list string) ([]string, error)) (*scheduleJob, error) {
+	m, err := composeMap(def, "job")
+	if err != nil {
+		return nil, err
+	}
+	// The name is that of the job's log file
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, errors.New("invalid job name")
+	}
+	job := &scheduleJob{name: name, maxParallel: 4}
+	for _, key := range sortedKeys(m) {
+		value := m[key]
+		switch key {
+		case "schedule":
+			s, ok := value.(string)
+			if !ok {
+				return nil, errors.New("schedule: want a cron expression")
+			}
+			if job.cron, err = parseCron(s); err != nil {
+				return nil, err
+			}
+			job.spec = s
+		case "command":
+			if s, ok := value.(string); ok {
+				job.command, err = splitCommand(s)
+			} else {
+				job.command, err = composeStrings(value, key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case "hosts":
+			list, err := composeStrings(value, key)
+			if err != nil {
+				return nil, err
+			}
+			if len(list) > 0 {
+				if job.hosts, err = resolve(strings.Join(list, ",")); err != nil {
+					return nil, err
+				}
+			}
+		case "timeout":
+			s, _ := value.(string)
+			if job.timeout, err = time.ParseDuration(s); err != nil || job.timeout < 0 {
+				return nil, fmt.Errorf("timeout: invalid duration %q", s)
+			}
+		case "max_parallel":
+			s, _ := value.(string)
+			if job.maxParallel, err = strconv.Atoi(s); err != nil || job.maxParallel < 1 {
+				return nil, fmt.Errorf("max_parallel: want a positive number, got %q", s)
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	if job.cron == nil {
+		return nil, errors.New("schedule is required")
+	}
+	if len(job.command) == 0 {
+		return nil, errors.New("command is required")
+	}
+	if job.command[0] == "schedule" {
+		return nil, errors.New("command: schedule cannot be scheduled")
+	}
+	return job, nil
+}
+
+// writeScheduleCheck writes the jobs with their next run after now.
+func writeScheduleCheck(w io.Writer, format string, jobs []*scheduleJob, now time.Time) error {
+	rows := make([]tuiRow, len(jobs))
+	for i, job := range jobs {
+		next := "never"
+		if t := job.cron.next(now); !t.IsZero() {
+			next = t.Format(time.RFC3339)
+		}
+		hosts := strings.Join(job.hosts, ",")
+		if hosts == "" {
+			hosts = "-"
+		}
+		rows[i] = tuiRow{id: job.name, cols: []string{job.name, job.spec, next, hosts, strings.Join(job.command, " ")}}
+	}
+	return writeTable(w, format, []string{"JOB", "SCHEDULE", "NEXT", "HOSTS", "COMMAND"}, rows)
+}
+
+// commandRunner returns the function running a job on a host, or without
+// a host if it is empty, by starting the podman-cli executable at exe with
+// globalArgs, and writing its output to out.
+func commandRunner(exe string, globalArgs []string) func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error) {
+	return func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error) {
+		args := append([]string{}, globalArgs...)
+		if host != "" {
+			args = append(args, "-host", host)
+		}
+		args = append(args, job.command...)
+
+		cmd := exec.CommandContext(ctx, exe, args...)
+		cmd.Stdout, cmd.Stderr = out, out
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		if err != nil {
+			return -1, err
+		}
+		return 0, nil
+	}
+}
+
+// scheduler runs jobs on their schedules and keeps their status.
+type scheduler struct {
+	jobs   []*scheduleJob
+	run    func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error)
+	logDir string    // directory of the job logs, if set
+	stderr io.Writer // receives the job output without logDir
+
+	mu     sync.Mutex // guards status and writes to stderr
+	status map[string]*jobStatus
+}
+
+// newScheduler returns a scheduler running jobs with run, and writing
+// their output to logDir, or to stderr if it is empty.
+func newScheduler(jobs []*scheduleJob, run func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error), logDir string, stderr io.Writer) *scheduler {
+	s := &scheduler{jobs: jobs, run: run, logDir: logDir, stderr: stderr, status: map[string]*jobStatus{}}
+	for _, job := range jobs {
+		s.status[job.name] = &jobStatus{Name: job.name, Schedule: job.spec, Command: job.command, Hosts: job.hosts}
+	}
+	return s
+}
+
+// runUntil runs each job on its schedule until ctx is done, then waits for
+// the runs in progress, which are interrupted, to end. A run still in
+// progress when the job is next due delays it to the following match.
+func (s *scheduler) runUntil(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				next := job.cron.next(time.Now())
+				if next.IsZero() {
+					slog.Warn("schedule: the job never runs", "job", job.name, "schedule", job.spec)
+					return
+				}
+				s.update(job, func(st *jobStatus) { st.Next = &next })
+
+				timer := time.NewTimer(time.Until(next))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+				s.runJob(ctx, job)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runJob runs job on its hosts, at most job.maxParallel at a time, and
+// records the outcome.
+func (s *scheduler) runJob(ctx context.Context, job *scheduleJob) {
+	run := &jobRun{Start: time.Now()}
+	s.update(job, func(st *jobStatus) { st.Running = true })
+
+	hosts := job.hosts
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	run.Results = make([]hostResult, len(hosts))
+	sem := make(chan struct{}, job.maxParallel)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			run.Results[i] = s.runOnHost(ctx, job, host)
+		}()
+	}
+	wg.Wait()
+	run.End = time.Now()
+
+	failed := false
+	for _, r := range run.Results {
+		failed = failed || r.failed()
+	}
+	s.update(job, func(st *jobStatus) {
+		st.Running = false
+		st.Runs++
+		if failed {
+			st.Failures++
+		}
+		st.Last = run
+	})
+}
+
+// runOnHost runs job on host and logs its output.
+func (s *scheduler) runOnHost(ctx context.Context, job *scheduleJob, host string) hostResult {
+	if job.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var out bytes.Buffer
+	code, err := s.run(ctx, job, host, &out)
+	result := hostResult{Host: host, ExitCode: code, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if err := s.writeLog(job, start, result, out.Bytes()); err != nil {
+		slog.Error("schedule: failed to write the job log", "job", job.name, "err", err)
+	}
+	if result.failed() {
+		slog.Error("schedule: job failed", "job", job.name, "host", host, "code", code, "err", err)
+	} else {
+		slog.Info("Job finished", "job", job.name, "host", host, "duration", result.Duration)
+	}
+	return result
+}
+
+// writeLog writes the output of a run of job, between lines giving the
+// host, start time and outcome, to the job's log file or stderr. The run
+// is written at once so that runs on several hosts do not interleave.
+func (s *scheduler) writeLog(job *scheduleJob, start time.Time, result hostResult, output []byte) error {
+	var b bytes.Buffer
+	target := ""
+	if result.Host != "" {
+		target = " on " + result.Host
+	}
+	fmt.Fprintf(&b, "=== %s %s%s: %s\n", start.Format(time.RFC3339), job.name, target, strings.Join(job.command, " "))
+	b.Write(output)
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	outcome := fmt.Sprintf("exit code %d", result.ExitCode)
+	if result.Error != "" {
+		outcome = result.Error
+	}
+	fmt.Fprintf(&b, "=== %s after %s\n", outcome, result.Duration)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.logDir == "" {
+		_, err := s.stderr.Write(b.Bytes())
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.logDir, job.name+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// update changes the status of job with fn.
+func (s *scheduler) update(job *scheduleJob, fn func(st *jobStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.status[job.name])
+}
+
+// ServeHTTP serves the status of the jobs, sorted by name, as a JSON array
+// on / and /status.
+func (s *scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/status" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	statuses := make([]jobStatus, len(s.jobs))
+	for i, job := range s.jobs {
+		statuses[i] = *s.status[job.name]
+	}
+	data, err := json.Marshal(statuses)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resolveTestHosts resolves @fleet to two hosts and other lists as is.
+func resolveTestHosts(list string) ([]string, error) {
+	var hosts []string
+	for _, h := range strings.Split(list, ",") {
+		if h == "@fleet" {
+			hosts = append(hosts, "edge1", "edge2")
+		} else {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+func TestParseScheduleJobs(t *testing.T) {
+	data := []byte(`
+jobs:
+  prune:
+    schedule: "0 3 * * *"
+    hosts: ["@fleet", build1]
+    command: prune -all -yes
+    timeout: 30m
+    max_parallel: 2
+  prefetch:
+    schedule: "@hourly"
+    command: [prefetch, -hosts, "@fleet", "myapp:v2"]
+`)
+	jobs, err := parseScheduleJobs(data, resolveTestHosts)
+	if err != nil {
+		t.Fatalf("parseScheduleJobs() unexpected error = %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("parseScheduleJobs() = %d jobs, want 2", len(jobs))
+	}
+
+	prefetch, prune := jobs[0], jobs[1]
+	if prefetch.name != "prefetch" || prefetch.hosts != nil || strings.Join(prefetch.command, " ") != "prefetch -hosts @fleet myapp:v2" || prefetch.maxParallel != 4 {
+		t.Errorf("prefetch job = %+v", prefetch)
+	}
+	if prune.spec != "0 3 * * *" || !reflect.DeepEqual(prune.hosts, []string{"edge1", "edge2", "build1"}) ||
+		!reflect.DeepEqual(prune.command, []string{"prune", "-all", "-yes"}) || prune.timeout != 30*time.Minute || prune.maxParallel != 2 {
+		t.Errorf("prune job = %+v", prune)
+	}
+}
+
+func TestParseScheduleJobs_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no jobs", "jobs:\n", "no jobs defined"},
+		{"unknown top-level key", "tasks:\n  a:\n    schedule: '@daily'\n", `unknown key "tasks"`},
+		{"unknown job key", "jobs:\n  a:\n    schedule: '@daily'\n    command: ps\n    every: 1h\n", `jobs.a: unknown key "every"`},
+		{"no schedule", "jobs:\n  a:\n    command: ps\n", "jobs.a: schedule is required"},
+		{"bad schedule", "jobs:\n  a:\n    schedule: '61 * * * *'\n    command: ps\n", "minute field"},
+		{"no command", "jobs:\n  a:\n    schedule: '@daily'\n", "jobs.a: command is required"},
+		{"recursive", "jobs:\n  a:\n    schedule: '@daily'\n    command: schedule -jobs x.yaml\n", "cannot be scheduled"},
+		{"bad timeout", "jobs:\n  a:\n    schedule: '@daily'\n    command: ps\n    timeout: soon\n", "invalid duration"},
+		{"bad max_parallel", "jobs:\n  a:\n    schedule: '@daily'\n    command: ps\n    max_parallel: 0\n", "want a positive number"},
+		{"path in name", "jobs:\n  ../a:\n    schedule: '@daily'\n    command: ps\n", "invalid job name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseScheduleJobs([]byte(tt.data), resolveTestHosts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseScheduleJobs() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// newTestJob returns a job running command on hosts every minute.
+func newTestJob(t *testing.T, name string, hosts []string, command ...string) *scheduleJob {
+	t.Helper()
+	cron, err := parseCron("* * * * *")
+	if err != nil {
+		t.Fatalf("parseCron() unexpected error = %v", err)
+	}
+	return &scheduleJob{name: name, spec: "* * * * *", cron: cron, command: command, hosts: hosts, maxParallel: 4}
+}
+
+func TestScheduler_RunJob(t *testing.T) {
+	job := newTestJob(t, "prune", []string{"edge1", "edge2"}, "prune", "-yes")
+	run := func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error) {
+		fmt.Fprintf(out, "pruned %s", host)
+		if host == "edge2" {
+			return 0, errors.New("connection refused")
+		}
+		return 0, nil
+	}
+	var stderr bytes.Buffer
+	s := newScheduler([]*scheduleJob{job}, run, "", &stderr)
+
+	s.runJob(context.Background(), job)
+
+	for _, want := range []string{" prune on edge1: prune -yes\npruned edge1\n=== exit code 0 after ", "pruned edge2\n=== connection refused after "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("job output missing %q:\n%s", want, stderr.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var statuses []jobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("status endpoint returned invalid JSON: %v\n%s", err, rec.Body.String())
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("status endpoint returned %d jobs, want 1", len(statuses))
+	}
+	st := statuses[0]
+	if st.Name != "prune" || st.Running || st.Runs != 1 || st.Failures != 1 || st.Last == nil || len(st.Last.Results) != 2 {
+		t.Fatalf("status = %+v, want one failed run", st)
+	}
+	if r := st.Last.Results[1]; r.Host != "edge2" || r.Error != "connection refused" {
+		t.Errorf("status result = %+v, want the error on edge2", r)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /status = %d, want 405", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /jobs = %d, want 404", rec.Code)
+	}
+}
+
+func TestScheduler_LogDir(t *testing.T) {
+	logDir := t.TempDir()
+	job := newTestJob(t, "prefetch", nil, "prefetch", "-hosts", "@fleet", "myapp:v2")
+	job.timeout = time.Millisecond
+	run := func(ctx context.Context, job *scheduleJob, host string, out io.Writer) (int, error) {
+		if host != "" {
+			t.Errorf("job without hosts run with host %q", host)
+		}
+		io.WriteString(out, "pulling\n")
+		<-ctx.Done()
+		return -1, ctx.Err()
+	}
+	s := newScheduler([]*scheduleJob{job}, run, logDir, io.Discard)
+
+	s.runJob(context.Background(), job)
+	s.runJob(context.Background(), job)
+
+	data, err := os.ReadFile(filepath.Join(logDir, "prefetch.log"))
+	if err != nil {
+		t.Fatalf("Failed to read the job log: %v", err)
+	}
+	if got := strings.Count(string(data), "pulling\n=== context deadline exceeded after "); got != 2 {
+		t.Errorf("job log has %d timed out runs, want 2:\n%s", got, data)
+	}
+	if st := s.status["prefetch"]; st.Runs != 2 || st.Failures != 2 {
+		t.Errorf("status = %+v, want two failed runs", st)
+	}
+}
+
+func TestWriteScheduleCheck(t *testing.T) {
+	jobs, err := parseScheduleJobs([]byte("jobs:\n  prune:\n    schedule: '0 3 * * *'\n    hosts: edge1\n    command: prune -yes\n"), resolveTestHosts)
+	if err != nil {
+		t.Fatalf("parseScheduleJobs() unexpected error = %v", err)
+	}
+	var out bytes.Buffer
+	now := time.Date(2026, time.March, 11, 10, 0, 0, 0, time.UTC)
+	if err := writeScheduleCheck(&out, formatText, jobs, now); err != nil {
+		t.Fatalf("writeScheduleCheck() unexpected error = %v", err)
+	}
+	for _, want := range []string{"prune", "0 3 * * *", "2026-03-12T03:00:00Z", "edge1", "prune -yes"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("writeScheduleCheck() output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCommandRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to stand in for podman-cli")
+	}
+	// The global arguments make sh print and fail with the rest
+	run := commandRunner(sh, []string{"-c", `echo "$@"; exit 3`, "sh"})
+	job := newTestJob(t, "prune", nil, "prune", "-yes")
+
+	var out bytes.Buffer
+	code, err := run(context.Background(), job, "edge1", &out)
+	if err != nil || code != 3 {
+		t.Errorf("run() = %d, %v, want exit code 3", code, err)
+	}
+	if out.String() != "-host edge1 prune -yes\n" {
+		t.Errorf("run() output = %q, want the host and command", out.String())
+	}
+}
+
+func TestNewRemoteCLI_GlobalArgs(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	configFile := filepath.Join(tmpDir, "config.toml")
+	cli, err := NewRemoteCLI([]string{"-config", configFile, "-retries", "2", "schedule", "-jobs", "jobs.yaml", "-timeout", "5s", "-host", "edge1"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	// The command's own -jobs and -host are not passed on
+	want := []string{"-config=" + configFile, "-retries=2", "-timeout=5s"}
+	if !reflect.DeepEqual(cli.globalArgs, want) {
+		t.Errorf("NewRemoteCLI() globalArgs = %q, want %q", cli.globalArgs, want)
+	}
+}
@@ -0,0 +1,653 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/config"
+)
+
+func init() {
+	// Registered here rather than in localCommands, as command.run looks
+	// up the flags of the other commands in it.
+	localCommands["serve"] = localCommand{
+		setup:   newServeCommand,
+		summary: "Serve a local JSON-RPC API through which GUIs and editors drive remote hosts",
+		usage:   "-listen unix://<path>|<addr> [-token <token>]",
+		examples: []string{
+			"podman-cli serve -listen unix:///tmp/pcli.sock",
+			"podman-cli serve -listen 127.0.0.1:7070 -token \"$(openssl rand -hex 16)\"",
+		},
+		noHost:    true,
+		noDryRun:  true,
+		streaming: true,
+	}
+}
+
+// JSON-RPC 2.0 error codes, and the one of canceled requests used by the
+// Language Server Protocol, which editor extensions already handle.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+	rpcUnauthorized   = -32001
+	rpcCanceled       = -32800
+)
+
+// rpcMaxMessage bounds the size of a request line.
+const rpcMaxMessage = 1 << 20
+
+// serveExcluded are the commands command.run refuses: those serving or
+// waiting for a terminal until interrupted.
+var serveExcluded = map[string]bool{
+	"mock_server": true, "schedule": true, "serve": true, "shell": true, "tui": true,
+}
+
+// serveGlobalFlags are the global flags command.run accepts, which only
+// shape the output of a command or its timing. The others read or write
+// local files, or run local commands, which is not for clients to decide.
+var serveGlobalFlags = map[string]bool{
+	"format": true, "color": true, "quiet": true, "verbose": true, "log-level": true,
+	"timeout": true, "request-timeout": true, "retries": true, "retry-delay": true, "keepalive": true,
+	"yes": true, "y": true,
+}
+
+// rpcRequest is a JSON-RPC request, or a notification if it has no ID.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse answers a request with either a result or an error.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is a message sent to the client without a request,
+// such as a line of logs.
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// invalidParams returns the error of a request with invalid parameters.
+func invalidParams(format string, args ...any) error {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// rpcHost is a host listed by hosts.list.
+type rpcHost struct {
+	Name      string   `json:"name"`
+	Groups    []string `json:"groups,omitempty"`
+	Connected bool     `json:"connected"`
+}
+
+// rpcServer answers the requests of the local clients of "serve".
+type rpcServer struct {
+	// hosts lists the known hosts
+	hosts func() ([]rpcHost, error)
+	// api returns an HTTP client for the Podman API of host, over the
+	// connection held for it, and the function to call once done with it
+	api func(ctx context.Context, host string) (*http.Client, func(), error)
+	// run runs podman-cli with args, returning its exit code
+	run func(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error)
+	// token, unless empty, must be given by the authenticate request
+	// before any other
+	token string
+}
+
+// newServeCommand returns the "serve" command, which runs a local JSON-RPC
+// service through which GUIs and editor extensions drive remote hosts,
+// with one process holding the SSH connections.
+func newServeCommand(fs *flag.FlagSet) runFunc {
+	var listen, token string
+	fs.StringVar(&listen, "listen", "", "Serve on this Unix socket, such as unix:///tmp/pcli.sock, or loopback TCP address")
+	fs.StringVar(&token, "token", os.Getenv(envName("serve-token")), "Require clients to authenticate with this token, as needed on TCP (default $"+envName("serve-token")+")")
+
+	return func(rc *RemoteCLI) int {
+		if listen == "" || len(rc.args) != 0 {
+			slog.Error("serve: usage: serve -listen unix://<path>|<addr>")
+			return 1
+		}
+		// Any local user, or web page through the browser, may connect to
+		// a TCP port, while a Unix socket is only accessible to the user
+		if network, _ := listenAddress(listen); network == "tcp" && token == "" {
+			slog.Error("serve: -token is required to serve on TCP")
+			return 1
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			slog.Error("serve", "err", err)
+			return 1
+		}
+		conns := newHostPool(rc)
+		defer conns.close()
+
+		listener, err := listenLocal(listen, false)
+		if err != nil {
+			slog.Error("serve", "err", err)
+			return 1
+		}
+		defer listener.Close()
+
+		paths := client.Paths{Config: rc.opts.sshConfig, KnownHosts: rc.opts.knownHosts}
+		s := &rpcServer{
+			hosts: func() ([]rpcHost, error) { return knownHosts(paths, rc.opts.configFile, conns.connected) },
+			api:   conns.get,
+			run:   processRunner(exe, rc.globalArgs),
+			token: token,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+
+		slog.Info("Serving", "listen", listener.Addr().Network()+"://"+listener.Addr().String())
+		var wg sync.WaitGroup
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				s.serveConn(ctx, conn)
+			}()
+		}
+		wg.Wait()
+		return 0
+	}
+}
+
+// listenLocal listens on the -listen address of "serve", "gateway",
+// "forward" or "schedule": a Unix socket, only accessible to the user, or a
+// loopback TCP address unless anyAddress is set, as these services act on
+// remote hosts, or report on them, for anyone connecting. A stale socket
+// file, left by a process that did not exit cleanly, is replaced.
+func listenLocal(listen string, anyAddress bool) (net.Listener, error) {
+	network, address := listenAddress(listen)
+	if network == "tcp" && anyAddress {
+		return net.Listen(network, address)
+	}
+	if network == "tcp" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("-listen %s: only loopback addresses may be served", address)
+		}
+		return net.Listen(network, address)
+	}
+
+	if _, err := os.Stat(address); err == nil {
+		if conn, err := net.Dial(network, address); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("-listen %s: another process is serving on it", address)
+		}
+		os.Remove(address)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(address, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// serveIdleTimeout is how long "serve" keeps a connection to a host no
+// request uses.
+const serveIdleTimeout = 5 * time.Minute
+
+// hostPool leases the connections of "serve" to its requests from a pool,
+// connecting to a host on first use.
+type hostPool struct {
+	rc   *RemoteCLI
+	pool *client.Pool
+
+	mu   sync.Mutex
+	keys map[string]string // pool key of each host connected to
+}
+
+func newHostPool(rc *RemoteCLI) *hostPool {
+	return &hostPool{rc: rc, pool: client.NewPool(serveIdleTimeout), keys: map[string]string{}}
+}
+
+// get returns an HTTP client for the Podman API of host, over a connection
+// leased from the pool, and the function releasing it.
+func (h *hostPool) get(ctx context.Context, host string) (*http.Client, func(), error) {
+	hostRC, err := h.rc.forHost(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostRC.pool = h.pool
+	// Requests end with their context, as logs may be followed
+	hostRC.requestTimeout = 0
+	lease, httpClient, err := hostRC.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.mu.Lock()
+	h.keys[host] = client.PoolKey(hostRC.sshClientConfig.User, hostRC.addr, hostRC.proxy)
+	h.mu.Unlock()
+	return httpClient, func() { lease.Close() }, nil
+}
+
+// connected reports whether a connection to host is held in the pool.
+func (h *hostPool) connected(host string) bool {
+	h.mu.Lock()
+	key, ok := h.keys[host]
+	h.mu.Unlock()
+	return ok && h.pool.Cached(key)
+}
+
+func (h *hostPool) close() {
+	h.pool.Close()
+}
+
+// knownHosts returns the hosts of the SSH configuration, with the groups
+// of the podman-cli configuration file they belong to, and the hosts of
+// those groups and of its settings, sorted by name.
+func knownHosts(paths client.Paths, configFile string, connected func(host string) bool) ([]rpcHost, error) {
+	names, err := client.ConfiguredHosts(paths)
+	if err != nil {
+		return nil, err
+	}
+	f, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string][]string{}
+	for _, group := range sortedKeys(f.Groups) {
+		for _, host := range f.Groups[group] {
+			groups[host] = append(groups[host], group)
+			names = append(names, host)
+		}
+	}
+	names = append(names, f.Host)
+	for _, p := range f.Profiles {
+		names = append(names, p.Host)
+	}
+
+	sort.Strings(names)
+	hosts := []rpcHost{}
+	for i, name := range names {
+		if name == "" || (i > 0 && name == names[i-1]) {
+			continue
+		}
+		hosts = append(hosts, rpcHost{Name: name, Groups: groups[name], Connected: connected(name)})
+	}
+	return hosts, nil
+}
+
+// processRunner returns the function starting the podman-cli executable
+// at exe with globalArgs followed by args.
+func processRunner(exe string, globalArgs []string) func(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+	return func(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+		cmd := exec.CommandContext(ctx, exe, append(append([]string{}, globalArgs...), args...)...)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		if err != nil {
+			return -1, err
+		}
+		return 0, nil
+	}
+}
+
+// serveConn answers the requests read from conn, one JSON object per
+// line, until it is closed or ctx is done. Requests are handled
+// concurrently, so that a log stream does not hold up other requests,
+// and those in progress are canceled when the connection closes.
+//
+// The connection is closed on the first line that is not JSON, so that
+// other protocols, such as HTTP requests a web page makes to a loopback
+// port, cannot get a request through, and, if s has a token, on any
+// request before a successful authenticate one.
+func (s *rpcServer) serveConn(ctx context.Context, conn io.ReadWriter) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// Unblock the read below on shutdown
+		<-ctx.Done()
+		if c, ok := conn.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+
+	var writeMu sync.Mutex
+	send := func(v any) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			slog.Error("serve", "err", err)
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.Write(append(data, '\n'))
+	}
+
+	var callsMu sync.Mutex
+	calls := map[string]context.CancelFunc{}
+	cancelCall := func(id json.RawMessage) bool {
+		callsMu.Lock()
+		defer callsMu.Unlock()
+		stop, ok := calls[string(id)]
+		if ok {
+			stop()
+		}
+		return ok
+	}
+
+	authenticated := s.token == ""
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), rpcMaxMessage)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			send(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			return
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			send(rpcResponse{JSONRPC: "2.0", ID: nullID(req.ID), Error: &rpcError{Code: rpcInvalidRequest, Message: `want a "jsonrpc": "2.0" request with a method`}})
+			continue
+		}
+
+		if !authenticated {
+			if err := s.authenticate(req); err != nil {
+				send(rpcResponse{JSONRPC: "2.0", ID: nullID(req.ID), Error: err})
+				return
+			}
+			authenticated = true
+			send(rpcResponse{JSONRPC: "2.0", ID: nullID(req.ID), Result: json.RawMessage("true")})
+			continue
+		}
+
+		if req.Method == "cancel" {
+			var params struct {
+				ID json.RawMessage `json:"id"`
+			}
+			json.Unmarshal(req.Params, &params)
+			found := cancelCall(params.ID)
+			if req.ID != nil {
+				send(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(fmt.Sprint(found))})
+			}
+			continue
+		}
+
+		callCtx, stop := context.WithCancel(ctx)
+		if req.ID != nil {
+			callsMu.Lock()
+			calls[string(req.ID)] = stop
+			callsMu.Unlock()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stop()
+			result, err := s.call(callCtx, req, send)
+			if req.ID == nil {
+				return
+			}
+			callsMu.Lock()
+			delete(calls, string(req.ID))
+			callsMu.Unlock()
+			send(newRPCResponse(req.ID, result, err, callCtx.Err() != nil && ctx.Err() == nil))
+		}()
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		slog.Warn("serve: closing connection", "err", err)
+	}
+}
+
+// authenticate returns an error unless req is an authenticate request
+// giving the token of s.
+func (s *rpcServer) authenticate(req rpcRequest) *rpcError {
+	var params struct {
+		Token string `json:"token"`
+	}
+	if req.Method != "authenticate" || json.Unmarshal(req.Params, &params) != nil ||
+		subtle.ConstantTimeCompare([]byte(params.Token), []byte(s.token)) != 1 {
+		return &rpcError{Code: rpcUnauthorized, Message: `want an "authenticate" request with a valid token first`}
+	}
+	return nil
+}
+
+// nullID returns id, or the JSON null a response carries when the ID of
+// the request is unknown.
+func nullID(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// newRPCResponse returns the response to the request id with result, or
+// with err if it failed; canceled reports whether the client canceled it.
+func newRPCResponse(id json.RawMessage, result any, err error, canceled bool) rpcResponse {
+	resp := rpcResponse{JSONRPC: "2.0", ID: id}
+	var rpcErr *rpcError
+	switch {
+	case canceled:
+		resp.Error = &rpcError{Code: rpcCanceled, Message: "request canceled"}
+	case errors.As(err, &rpcErr):
+		resp.Error = rpcErr
+	case err != nil:
+		resp.Error = &rpcError{Code: rpcServerError, Message: err.Error()}
+	default:
+		data, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &rpcError{Code: rpcServerError, Message: err.Error()}
+		} else {
+			resp.Result = data
+		}
+	}
+	return resp
+}
+
+// call runs the method of req, sending notifications with send.
+func (s *rpcServer) call(ctx context.Context, req rpcRequest, send func(v any)) (any, error) {
+	decode := func(v any) error {
+		if len(req.Params) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			return invalidParams("%s: %v", req.Method, err)
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "hosts.list":
+		return s.hosts()
+
+	case "command.run":
+		var params struct {
+			Host string   `json:"host"`
+			Args []string `json:"args"`
+		}
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if len(params.Args) == 0 {
+			return nil, invalidParams("command.run: args must name a command")
+		}
+		if err := checkServeArgs(params.Args); err != nil {
+			return nil, err
+		}
+		args := params.Args
+		if params.Host != "" {
+			args = append([]string{"-host", params.Host}, args...)
+		}
+		var stdout, stderr bytes.Buffer
+		code, err := s.run(ctx, args, &stdout, &stderr)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"exitCode": code, "stdout": stdout.String(), "stderr": stderr.String()}, nil
+
+	case "logs.stream":
+		var params struct {
+			Host      string `json:"host"`
+			Container string `json:"container"`
+			Follow    bool   `json:"follow"`
+			Tail      string `json:"tail"`
+			Since     string `json:"since"`
+		}
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if params.Host == "" || params.Container == "" {
+			return nil, invalidParams("logs.stream: host and container are required")
+		}
+		if params.Tail == "" {
+			params.Tail = "all"
+		}
+		query, err := logsQuery(params.Since, "", params.Tail, time.Now())
+		if err != nil {
+			return nil, invalidParams("logs.stream: %v", err)
+		}
+		if params.Follow {
+			query.Set("follow", "true")
+		}
+		httpClient, release, err := s.api(ctx, params.Host)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		lines := 0
+		writer := func(stream string) *rpcLineWriter {
+			return &rpcLineWriter{send: func(line string) {
+				lines++
+				send(rpcNotification{JSONRPC: "2.0", Method: "logs.output", Params: map[string]any{
+					"id": req.ID, "stream": stream, "line": line,
+				}})
+			}}
+		}
+		// Lines are sent from this goroutine only, so counting is safe
+		stdout, stderr := writer("stdout"), writer("stderr")
+		err = containerLogs(ctx, httpClient, params.Container, query, stdout, stderr)
+		stdout.flush()
+		stderr.flush()
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		return map[string]any{"lines": lines}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method}
+}
+
+// checkServeArgs returns an error unless args, given to command.run,
+// start with a command serve runs and only set the global flags of
+// serveGlobalFlags, flags of the command itself being accepted.
+func checkServeArgs(args []string) error {
+	name := args[0]
+	if strings.HasPrefix(name, "-") {
+		return invalidParams("command.run: args must start with a command, got %s", name)
+	}
+	if serveExcluded[name] {
+		return invalidParams("command.run: %s cannot be run through serve", name)
+	}
+	own := ownCommandFlags(name)
+	global := commandFlags("")
+	for _, arg := range args[1:] {
+		if arg == "--" {
+			break
+		}
+		flagName, ok := strings.CutPrefix(arg, "-")
+		if !ok {
+			continue
+		}
+		flagName, _, _ = strings.Cut(strings.TrimPrefix(flagName, "-"), "=")
+		if global.Lookup(flagName) != nil && own.Lookup(flagName) == nil && !serveGlobalFlags[flagName] {
+			return invalidParams("command.run: global flag -%s cannot be set through serve", flagName)
+		}
+	}
+	return nil
+}
+
+// rpcLineWriter passes each line written to it, without its newline, to
+// send, holding partial lines until complete or flushed.
+type rpcLineWriter struct {
+	send func(line string)
+	buf  []byte
+}
+
+func (w *rpcLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.send(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *rpcLineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.send(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+)
+
+// rpcMessage is a response or notification read by a test client.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// startRPCServer serves s on one end of a pipe, returning a function
+// sending a line on the other end and the channel of the messages read
+// from it.
+func startRPCServer(t *testing.T, s *rpcServer) (func(line string), <-chan rpcMessage) {
+	t.Helper()
+	serverConn, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serveConn(context.Background(), serverConn)
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+
+	messages := make(chan rpcMessage, 100)
+	go func() {
+		defer close(messages)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var msg rpcMessage
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				t.Errorf("invalid message %q: %v", scanner.Text(), err)
+				return
+			}
+			messages <- msg
+		}
+	}()
+	send := func(line string) {
+		if _, err := io.WriteString(conn, line+"\n"); err != nil {
+			t.Fatalf("Write() unexpected error = %v", err)
+		}
+	}
+	return send, messages
+}
+
+// nextMessage returns the next message read, failing the test if none
+// comes in time.
+func nextMessage(t *testing.T, messages <-chan rpcMessage) rpcMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return rpcMessage{}
+}
+
+func TestRPCServer(t *testing.T) {
+	s := &rpcServer{
+		hosts: func() ([]rpcHost, error) {
+			return []rpcHost{{Name: "edge1", Groups: []string{"fleet"}, Connected: true}}, nil
+		},
+		api: func(ctx context.Context, host string) (*http.Client, func(), error) {
+			if host != "edge1" {
+				return nil, nil, fmt.Errorf("unknown host %s", host)
+			}
+			return newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v3.0.0/libpod/containers/web/logs" || r.URL.Query().Get("tail") != "10" {
+					t.Errorf("request = %s, want the web logs with tail=10", r.URL)
+				}
+				w.Write(frame(1, "started\nlistening"))
+				w.Write(frame(2, " on :80\nwarning\n"))
+			})), func() {}, nil
+		},
+		run: func(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+			fmt.Fprint(stdout, strings.Join(args, " "))
+			fmt.Fprint(stderr, "warning")
+			return 3, nil
+		},
+	}
+	send, messages := startRPCServer(t, s)
+
+	errorTests := []struct {
+		request  string
+		wantCode int
+	}{
+		{`{"id": 1, "method": "hosts.list"}`, rpcInvalidRequest},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "containers.rm"}`, rpcMethodNotFound},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "command.run", "params": {"host": "edge1"}}`, rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "command.run", "params": {"args": ["serve"]}}`, rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "command.run", "params": {"args": ["-identity-cmd", "touch /tmp/x", "ps"]}}`, rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "command.run", "params": {"args": ["ps", "--record=/tmp/x"]}}`, rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "logs.stream", "params": {"host": 1}}`, rpcInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "logs.stream", "params": {"host": "db", "container": "web"}}`, rpcServerError},
+	}
+	for _, tt := range errorTests {
+		send(tt.request)
+		if msg := nextMessage(t, messages); msg.Error == nil || msg.Error.Code != tt.wantCode {
+			t.Errorf("%s: error = %+v, want code %d", tt.request, msg.Error, tt.wantCode)
+		}
+	}
+
+	send(`{"jsonrpc": "2.0", "id": "hosts", "method": "hosts.list"}`)
+	msg := nextMessage(t, messages)
+	if string(msg.ID) != `"hosts"` || string(msg.Result) != `[{"name":"edge1","groups":["fleet"],"connected":true}]` {
+		t.Errorf("hosts.list = %s %s, want edge1", msg.ID, msg.Result)
+	}
+
+	send(`{"jsonrpc": "2.0", "id": 2, "method": "command.run", "params": {"host": "edge1", "args": ["ps", "-a", "-format", "json"]}}`)
+	msg = nextMessage(t, messages)
+	if string(msg.Result) != `{"exitCode":3,"stderr":"warning","stdout":"-host edge1 ps -a -format json"}` {
+		t.Errorf("command.run = %s", msg.Result)
+	}
+
+	send(`{"jsonrpc": "2.0", "id": 3, "method": "logs.stream", "params": {"host": "edge1", "container": "web", "tail": "10"}}`)
+	var lines []string
+	for {
+		msg := nextMessage(t, messages)
+		if msg.Method != "logs.output" {
+			if string(msg.ID) != "3" || string(msg.Result) != `{"lines":4}` {
+				t.Errorf("logs.stream = %s %s %+v, want 4 lines", msg.ID, msg.Result, msg.Error)
+			}
+			break
+		}
+		var params struct {
+			ID     json.RawMessage `json:"id"`
+			Stream string          `json:"stream"`
+			Line   string          `json:"line"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		if string(params.ID) != "3" {
+			t.Errorf("logs.output id = %s, want 3", params.ID)
+		}
+		lines = append(lines, params.Stream+": "+params.Line)
+	}
+	// Each stream is split into lines on its own, incomplete lines being
+	// sent once the stream ends
+	want := []string{"stdout: started", "stderr:  on :80", "stderr: warning", "stdout: listening"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("logs.output lines = %q, want %q", lines, want)
+	}
+}
+
+func TestRPCServer_Malformed(t *testing.T) {
+	ran := false
+	s := &rpcServer{run: func(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
+		ran = true
+		return 0, nil
+	}}
+	send, messages := startRPCServer(t, s)
+
+	// An HTTP request made by a web page, whose body is a request
+	send("POST / HTTP/1.1")
+	if msg := nextMessage(t, messages); msg.Error == nil || msg.Error.Code != rpcParseError {
+		t.Errorf("error = %+v, want code %d", msg.Error, rpcParseError)
+	}
+	if _, ok := <-messages; ok {
+		t.Fatal("connection still open after a malformed line")
+	}
+	if ran {
+		t.Error("command run after a malformed line")
+	}
+}
+
+func TestRPCServer_Token(t *testing.T) {
+	s := &rpcServer{
+		hosts: func() ([]rpcHost, error) { return []rpcHost{{Name: "edge1"}}, nil },
+		token: "secret",
+	}
+
+	send, messages := startRPCServer(t, s)
+	send(`{"jsonrpc": "2.0", "id": 1, "method": "hosts.list"}`)
+	if msg := nextMessage(t, messages); msg.Error == nil || msg.Error.Code != rpcUnauthorized {
+		t.Errorf("hosts.list before authenticate: error = %+v, want code %d", msg.Error, rpcUnauthorized)
+	}
+	if _, ok := <-messages; ok {
+		t.Error("connection still open after an unauthenticated request")
+	}
+
+	send, messages = startRPCServer(t, s)
+	send(`{"jsonrpc": "2.0", "id": 1, "method": "authenticate", "params": {"token": "guess"}}`)
+	if msg := nextMessage(t, messages); msg.Error == nil || msg.Error.Code != rpcUnauthorized {
+		t.Errorf("authenticate with a wrong token: error = %+v, want code %d", msg.Error, rpcUnauthorized)
+	}
+
+	send, messages = startRPCServer(t, s)
+	send(`{"jsonrpc": "2.0", "id": 1, "method": "authenticate", "params": {"token": "secret"}}`)
+	if msg := nextMessage(t, messages); string(msg.Result) != "true" {
+		t.Errorf("authenticate = %s %+v, want true", msg.Result, msg.Error)
+	}
+	send(`{"jsonrpc": "2.0", "id": 2, "method": "hosts.list"}`)
+	if msg := nextMessage(t, messages); string(msg.Result) != `[{"name":"edge1","connected":false}]` {
+		t.Errorf("hosts.list = %s %+v, want edge1", msg.Result, msg.Error)
+	}
+}
+
+func TestServe_TCPNeedsToken(t *testing.T) {
+	rc, err := NewRemoteCLI([]string{"serve", "-listen", "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	if code := rc.run(rc); code != 1 {
+		t.Errorf("serve without -token on TCP = %d, want 1", code)
+	}
+}
+
+func TestRPCServer_Cancel(t *testing.T) {
+	started := make(chan struct{})
+	s := &rpcServer{api: func(ctx context.Context, host string) (*http.Client, func(), error) {
+		return newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(frame(1, "started\n"))
+			w.(http.Flusher).Flush()
+			close(started)
+			<-r.Context().Done()
+		})), func() {}, nil
+	}}
+	send, messages := startRPCServer(t, s)
+
+	send(`{"jsonrpc": "2.0", "id": 1, "method": "logs.stream", "params": {"host": "edge1", "container": "web", "follow": true}}`)
+	if msg := nextMessage(t, messages); msg.Method != "logs.output" {
+		t.Fatalf("message = %+v, want logs.output", msg)
+	}
+	<-started
+	send(`{"jsonrpc": "2.0", "id": 2, "method": "cancel", "params": {"id": 1}}`)
+
+	got := map[string]rpcMessage{}
+	for len(got) < 2 {
+		msg := nextMessage(t, messages)
+		got[string(msg.ID)] = msg
+	}
+	if string(got["2"].Result) != "true" {
+		t.Errorf("cancel = %s, want true", got["2"].Result)
+	}
+	if err := got["1"].Error; err == nil || err.Code != rpcCanceled {
+		t.Errorf("logs.stream error = %+v, want code %d", err, rpcCanceled)
+	}
+}
+
+func TestHostPool(t *testing.T) {
+	sshConfig, _ := startMockHost(t)
+	rc, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "serve", "-listen", "unix:///unused.sock"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	conns := newHostPool(rc)
+	defer conns.close()
+
+	if conns.connected(mockHost) {
+		t.Errorf("connected() = true before any request")
+	}
+	for i := 0; i < 2; i++ {
+		httpClient, release, err := conns.get(context.Background(), mockHost)
+		if err != nil {
+			t.Fatalf("get() unexpected error = %v", err)
+		}
+		if _, _, err := listContainers(context.Background(), httpClient, nil); err != nil {
+			t.Errorf("listContainers() unexpected error = %v", err)
+		}
+		release()
+	}
+	if !conns.connected(mockHost) || conns.pool.Len() != 1 {
+		t.Errorf("connected() = %v with %d pooled connections, want one connection shared by the requests", conns.connected(mockHost), conns.pool.Len())
+	}
+}
+
+func TestKnownHosts(t *testing.T) {
+	dir := t.TempDir()
+	sshConfig := filepath.Join(dir, "ssh_config")
+	configFile := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(sshConfig, []byte("Host edge1 *.example.com\n  User core\nHost db\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := "host = \"db\"\n\n[profiles.prod]\nhost = \"prod\"\n\n[groups]\nfleet = [\"edge1\", \"edge2\"]\nedge = [\"edge1\"]\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := knownHosts(client.Paths{Config: sshConfig}, configFile, func(host string) bool { return host == "db" })
+	if err != nil {
+		t.Fatalf("knownHosts() unexpected error = %v", err)
+	}
+	want := []rpcHost{
+		{Name: "db", Connected: true},
+		{Name: "edge1", Groups: []string{"edge", "fleet"}},
+		{Name: "edge2", Groups: []string{"fleet"}},
+		{Name: "prod"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("knownHosts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestListenLocal(t *testing.T) {
+	for _, listen := range []string{"tcp://192.0.2.1:7070", ":7070"} {
+		if _, err := listenLocal(listen, false); err == nil || !strings.Contains(err.Error(), "loopback") {
+			t.Errorf("listenLocal(%q) error = %v, want a loopback error", listen, err)
+		}
+	}
+	listener, err := listenLocal("0", false)
+	if err != nil {
+		t.Fatalf("listenLocal() of a bare port unexpected error = %v", err)
+	}
+	if addr := listener.Addr().(*net.TCPAddr); !addr.IP.IsLoopback() {
+		t.Errorf("listenLocal() of a bare port listens on %v, want a loopback address", addr)
+	}
+	listener.Close()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("serves on a Unix socket")
+	}
+	socket := filepath.Join(t.TempDir(), "pcli.sock")
+	// A socket file left by a process that did not exit cleanly
+	if err := os.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	listener, err = listenLocal("unix://"+socket, false)
+	if err != nil {
+		t.Fatalf("listenLocal() unexpected error = %v", err)
+	}
+	defer listener.Close()
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v, want 0600", info.Mode(), err)
+	}
+	if _, err := listenLocal("unix://"+socket, false); err == nil || !strings.Contains(err.Error(), "another process") {
+		t.Errorf("second listenLocal() error = %v, want an in use error", err)
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// systemdUnitLabel is the label Podman sets on containers run by a systemd
+// unit, generated by Quadlet or "podman generate systemd --new", naming
+// the unit.
+const systemdUnitLabel = "PODMAN_SYSTEMD_UNIT"
+
+// newServiceCommand returns the "service" command, which starts, stops or
+// shows the systemd units running containers on the remote host, through
+// systemctl over SSH. Containers managed by a unit are better controlled
+// through it, as systemd restarts or recreates them otherwise.
+func newServiceCommand(fs *flag.FlagSet) runFunc {
+	var scope string
+	fs.StringVar(&scope, "scope", "", "systemd manager of the units: user or system (default user for sockets in /run/user, system otherwise)")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) < 2 {
+			slog.Error("service: usage: service [-scope user|system] start|stop|status <unit>...")
+			return 1
+		}
+		action, units := rc.args[0], rc.args[1:]
+		if action != "start" && action != "stop" && action != "status" {
+			slog.Error("service: unknown action (use start, stop or status)", "action", action)
+			return 1
+		}
+		user := strings.HasPrefix(rc.socketPath, "/run/user/")
+		switch scope {
+		case "":
+		case "user", "system":
+			user = scope == "user"
+		default:
+			slog.Error("service: invalid -scope (use user or system)", "scope", scope)
+			return 1
+		}
+		for i, unit := range units {
+			units[i] = unitName(unit)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		cmd := systemctlCommand(user, action, units)
+		start := time.Now()
+		err = client.Exec(sshClient.Client, cmd, nil, os.Stdout, os.Stderr)
+		if action != "status" {
+			rc.auditSSH(auditExec, cmd, start, err)
+		}
+		var exitErr *ssh.ExitError
+		code := 0
+		switch {
+		case errors.As(err, &exitErr):
+			// systemctl explains its failures on stderr; "status" exits
+			// with 3 for inactive units
+			code = exitErr.ExitStatus()
+		case err != nil:
+			slog.Error("service", "err", err)
+			return 1
+		}
+		if action != "status" {
+			return code
+		}
+
+		for _, unit := range units {
+			containers, err := unitContainers(ctx, httpClient, unit)
+			if err != nil {
+				slog.Error("service status", "target", unit, "err", err)
+				return 1
+			}
+			fmt.Fprintf(os.Stdout, "\nContainers of %s:\n", unit)
+			rows := make([]tuiRow, len(containers))
+			for i, c := range containers {
+				rows[i] = tuiRow{id: c.ID, cols: []string{shortID(c.ID), strings.Join(c.Names, ","), c.Image, c.State, c.Status}}
+			}
+			if err := writeTable(os.Stdout, rc.opts.format, []string{"CONTAINER ID", "NAMES", "IMAGE", "STATE", "STATUS"}, rows); err != nil {
+				slog.Error("service status", "err", err)
+				return 1
+			}
+		}
+		return code
+	}
+}
+
+// unitName returns the name of a systemd unit, adding the .service suffix
+// systemctl assumes if it has none, so that it can be matched against the
+// label of the containers the unit runs.
+func unitName(unit string) string {
+	if i := strings.LastIndexByte(unit, '.'); i > 0 && slices.Contains([]string{"service", "socket", "target", "timer"}, unit[i+1:]) {
+		return unit
+	}
+	return unit + ".service"
+}
+
+// systemctlCommand returns the shell command applying action to units with
+// the user's systemd manager, or the system's if user is false. Output is
+// not paged, as it is not read on a terminal.
+func systemctlCommand(user bool, action string, units []string) string {
+	args := []string{"systemctl"}
+	if user {
+		args = append(args, "--user")
+	}
+	args = append(args, "--no-pager", action)
+	for _, unit := range units {
+		args = append(args, client.ShellQuote(unit))
+	}
+	return strings.Join(args, " ")
+}
+
+// unitContainers returns the containers run by a systemd unit.
+func unitContainers(ctx context.Context, httpClient *http.Client, unit string) ([]models.ListContainer, error) {
+	filters, err := encodeFilters([]string{"label=" + systemdUnitLabel + "=" + unit})
+	if err != nil {
+		return nil, err
+	}
+	var containers []models.ListContainer
+	query := url.Values{"all": {"true"}, "filters": {filters}}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
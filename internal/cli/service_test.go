@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestUnitName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"web", "web.service"},
+		{"container-db.service", "container-db.service"},
+		{"podman-auto-update.timer", "podman-auto-update.timer"},
+		{"app.v2", "app.v2.service"},
+	}
+	for _, tt := range tests {
+		if got := unitName(tt.in); got != tt.want {
+			t.Errorf("unitName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSystemctlCommand(t *testing.T) {
+	if got, want := systemctlCommand(true, "start", []string{"web.service", "db.service"}), "systemctl --user --no-pager start web.service db.service"; got != want {
+		t.Errorf("systemctlCommand(user) = %q, want %q", got, want)
+	}
+	if got, want := systemctlCommand(false, "status", []string{"my app.service"}), "systemctl --no-pager status 'my app.service'"; got != want {
+		t.Errorf("systemctlCommand(system) = %q, want %q", got, want)
+	}
+}
+
+func TestUnitContainers(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/containers/json" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		if got, want := r.URL.Query().Get("filters"), `{"label":["PODMAN_SYSTEMD_UNIT=web.service"]}`; got != want {
+			t.Errorf("filters = %s, want %s", got, want)
+		}
+		if r.URL.Query().Get("all") != "true" {
+			t.Errorf("query = %s, want all containers", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"Id":"abc","Names":["systemd-web"],"State":"running"}]`))
+	}))
+
+	containers, err := unitContainers(context.Background(), httpClient, "web.service")
+	if err != nil {
+		t.Fatalf("unitContainers() unexpected error = %v", err)
+	}
+	if len(containers) != 1 || containers[0].Names[0] != "systemd-web" {
+		t.Errorf("unitContainers() = %+v, want systemd-web", containers)
+	}
+}
+
+func TestServiceCommand_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no unit", []string{"start"}},
+		{"unknown action", []string{"reload", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &RemoteCLI{args: tt.args}
+			run := newTestCommand(t, newServiceCommand)
+			if code := run(rc); code != 1 {
+				t.Errorf("service %v = %d, want 1", tt.args, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/alexjch/podman-cli/internal/commands"
+	"github.com/alexjch/podman-cli/internal/config"
+	"golang.org/x/term"
+)
+
+func init() {
+	// Registered here rather than in localCommands, as the shell looks up
+	// the other commands in it.
+	localCommands["shell"] = localCommand{
+		setup:     newShellCommand,
+		summary:   "Run commands interactively over a single connection",
+		noDryRun:  true,
+		streaming: true,
+		hostArg:   true,
+	}
+}
+
+// shellBuiltins are the commands handled by the shell itself.
+var shellBuiltins = []string{"exit", "help", "quit"}
+
+// shellGlobalFlags are the global flags a shell line may set, before or
+// after its command name. The others configure the connection, or the
+// process as a whole, so are only taken from the command line of the shell.
+var shellGlobalFlags = map[string]bool{
+	"format": true, "request-timeout": true, "ensure-service": true, "yes": true, "y": true,
+}
+
+// newShellCommand returns the "shell" command, which opens a single SSH
+// connection and then reads commands interactively, avoiding a handshake
+// per command. Each line is a command with its flags and arguments, as on
+// the command line. When stdin is a terminal, line editing, history and tab
+// completion of command names are available.
+//
+// Ctrl-C stops the command running, not the shell. The shell exits with the
+// status of the last command.
+func newShellCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The commands of the shell lease the connection from the pool, so
+		// holding it open here lets them all share it
+		sshClient, _, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		// Interrupts stop the command running, not the shell
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		defer signal.Stop(interrupts)
+
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			scanner := bufio.NewScanner(os.Stdin)
+			return rc.runShell(func() (string, error) {
+				if !scanner.Scan() {
+					if err := scanner.Err(); err != nil {
+						return "", err
+					}
+					return "", io.EOF
+				}
+				return scanner.Text(), nil
+			}, interrupts, os.Stdout)
+		}
+
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, rc.host+"> ")
+		t.AutoCompleteCallback = completeCommand
+		// The terminal is raw only while a line is edited, so that the
+		// commands write to it as usual
+		readLine := func() (string, error) {
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return "", err
+			}
+			defer term.Restore(fd, state)
+
+			// The terminal translates newlines while in raw mode
+			logOutput = t
+			defer func() { logOutput = os.Stderr }()
+			return t.ReadLine()
+		}
+		return rc.runShell(readLine, interrupts, os.Stdout)
+	}
+}
+
+// runShell runs the commands of the lines returned by readLine until it
+// returns io.EOF or an exit command, and returns the exit status of the
+// last command. A failing command does not end the shell, which reports
+// its status. An interrupt received on interrupts cancels the command
+// running.
+func (rc *RemoteCLI) runShell(readLine func() (string, error), interrupts <-chan os.Signal, out io.Writer) int {
+	var aliases map[string]string
+	if cfg, err := config.Load(rc.opts.configFile); err == nil {
+		aliases = cfg.Aliases
+	}
+
+	status := 0
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return status
+		}
+		if err != nil {
+			slog.Error("shell", "err", err)
+			return 1
+		}
+
+		fields, err := splitCommand(line)
+		if err != nil {
+			slog.Error("shell", "err", err)
+			status = 1
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "exit", "quit":
+			return status
+		case "help":
+			fmt.Fprintln(out, "Available commands:")
+			for _, name := range commandNames() {
+				fmt.Fprintln(out, "  "+name)
+			}
+			status = 0
+			continue
+		}
+
+		if fields, err = expandAlias(fields, aliases); err != nil {
+			slog.Error("shell", "err", err)
+			status = 1
+			continue
+		}
+		cmd, err := rc.shellCommand(fields)
+		if err == flag.ErrHelp {
+			status = 0
+			continue
+		}
+		if err != nil {
+			slog.Error("shell", "err", err)
+			status = 1
+			continue
+		}
+
+		status = runShellLine(cmd, interrupts)
+		if status != 0 {
+			slog.Warn("Command failed", "command", cmd.name, "status", status)
+		}
+	}
+}
+
+// runShellLine runs cmd, canceling it on an interrupt, and returns its
+// exit code.
+func runShellLine(cmd *RemoteCLI, interrupts <-chan os.Signal) int {
+	// An interrupt received while no command was running is not for this one
+	select {
+	case <-interrupts:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return cmd.runCommand(ctx)
+}
+
+// shellCommand returns a copy of rc running the command of a shell line
+// split into fields, with the command's flags and arguments, and the
+// global flags of shellGlobalFlags, parsed from it.
+func (rc *RemoteCLI) shellCommand(fields []string) (*RemoteCLI, error) {
+	cmd := *rc
+	global := flag.NewFlagSet("shell", flag.ContinueOnError)
+	global.Usage = usageFunc(global, "")
+	cmd.opts.register(global)
+	if err := global.Parse(fields); err != nil {
+		return nil, err
+	}
+	if global.NArg() == 0 {
+		return nil, errors.New("a command must follow the global flags")
+	}
+	fields = global.Args()
+
+	name := fields[0]
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = usageFunc(fs, name)
+
+	cmd.name, cmd.run, cmd.command, cmd.output, cmd.query = name, nil, commands.Command{}, "", nil
+	var filters stringsFlag
+	local, isLocal := localCommands[name]
+	if name == "shell" {
+		return nil, errors.New("already in a shell")
+	} else if isLocal {
+		cmd.run = local.setup(fs)
+	} else if c := commands.IsCommand(name); c != nil {
+		cmd.command = *c
+		apiCommandFlags(fs, cmd.command, &cmd.output, &filters)
+	} else {
+		return nil, fmt.Errorf("invalid command: %s (run \"help\" for a list)", name)
+	}
+	own := map[string]bool{}
+	fs.VisitAll(func(f *flag.Flag) { own[f.Name] = true })
+	cmd.opts.register(fs)
+	if err := fs.Parse(fields[1:]); err != nil {
+		return nil, err
+	}
+
+	var fixed []string
+	checkGlobal := func(f *flag.Flag) {
+		if !shellGlobalFlags[f.Name] {
+			fixed = append(fixed, "-"+f.Name)
+		}
+	}
+	global.Visit(checkGlobal)
+	fs.Visit(func(f *flag.Flag) {
+		if !own[f.Name] {
+			checkGlobal(f)
+		}
+	})
+	if len(fixed) > 0 {
+		return nil, fmt.Errorf("%s cannot be changed in a shell", strings.Join(fixed, ", "))
+	}
+	if f := cmd.opts.format; f != rc.opts.format && f != formatText && f != formatJSON && f != formatJSONStream {
+		return nil, fmt.Errorf("invalid -format %q (use %s, %s or %s)", f, formatText, formatJSON, formatJSONStream)
+	}
+
+	cmd.args = fs.Args()
+	if !isLocal && len(cmd.args) > 0 {
+		return nil, fmt.Errorf("%s takes no arguments", name)
+	}
+	if len(filters) > 0 {
+		encoded, err := encodeFilters(filters)
+		if err != nil {
+			return nil, err
+		}
+		cmd.query = url.Values{"filters": {encoded}}
+	}
+	cmd.requestTimeout = cmd.opts.requestTimeout
+	if isLocal && local.streaming {
+		cmd.requestTimeout = 0
+	}
+	return &cmd, nil
+}
+
+// commandNames returns the sorted names of all commands usable in the shell.
+func commandNames() []string {
+	names := append([]string{}, shellBuiltins...)
+	for name := range commands.Commands() {
+		names = append(names, name)
+	}
+	for name := range localCommands {
+		if name != "shell" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeCommand implements tab completion of command names for
+// term.Terminal. A unique match is completed in full; multiple matches are
+// completed up to their longest common prefix.
+func completeCommand(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || strings.ContainsRune(line[:pos], ' ') {
+		return "", 0, false
+	}
+
+	prefix := line[:pos]
+	var matches []string
+	for _, name := range commandNames() {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if len(matches) == 1 {
+		completion += " "
+	}
+
+	return completion + line[pos:], len(completion), true
+}
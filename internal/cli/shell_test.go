@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/testserver"
+)
+
+func TestCompleteCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		pos      int
+		key      rune
+		wantLine string
+		wantPos  int
+		wantOk   bool
+	}{
+		{"unique match", "list_c", 6, '\t', "list_containers ", 16, true},
+		{"builtin", "qu", 2, '\t', "quit ", 5, true},
+		{"no match", "zzz", 3, '\t', "", 0, false},
+		{"not tab", "list_c", 6, 'x', "", 0, false},
+		{"argument position", "list_containers fo", 18, '\t', "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, pos, ok := completeCommand(tt.line, tt.pos, tt.key)
+			if ok != tt.wantOk || line != tt.wantLine || pos != tt.wantPos {
+				t.Errorf("completeCommand(%q, %d) = (%q, %d, %v), want (%q, %d, %v)",
+					tt.line, tt.pos, line, pos, ok, tt.wantLine, tt.wantPos, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCommandNames_IncludesBuiltinsAndCommands(t *testing.T) {
+	names := commandNames()
+
+	want := map[string]bool{"exit": false, "help": false, "list_containers": false, "stop": false}
+	for _, name := range names {
+		if _, ok := want[name]; ok {
+			want[name] = true
+		}
+	}
+
+	for name, found := range want {
+		if !found {
+			t.Errorf("commandNames() missing %q", name)
+		}
+	}
+}
+
+// lines returns a readLine function of the shell returning each of lines
+// in turn, then io.EOF.
+func lines(lines ...string) func() (string, error) {
+	return func() (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+}
+
+func TestRunShell_LocalCommandWithArguments(t *testing.T) {
+	sshConfig, server := startMockHost(t)
+
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "shell"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	cli.pool = client.NewPool(0)
+	defer cli.pool.Close()
+
+	var out bytes.Buffer
+	code := cli.runShell(lines("stop -time 0 web", "unknown", "stop -bogus db", "help"), nil, &out)
+	if code != 0 {
+		t.Errorf("runShell() = %d, want 0", code)
+	}
+	if c, _ := server.Container("web"); c.State != testserver.StateExited {
+		t.Errorf("web state = %q after the shell, want exited", c.State)
+	}
+	if c, _ := server.Container("db"); c.State != testserver.StateRunning {
+		t.Errorf("db state = %q after an invalid flag, want running", c.State)
+	}
+	if !strings.Contains(out.String(), "  stop\n") {
+		t.Errorf("help output = %q, want the local commands listed", out.String())
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	rc := &RemoteCLI{name: "shell"}
+
+	cmd, err := rc.shellCommand([]string{"list_containers", "-filter", "status=running"})
+	if err != nil {
+		t.Fatalf("shellCommand() unexpected error = %v", err)
+	}
+	if cmd.name != "list_containers" || !cmd.command.Filters || cmd.query.Get("filters") == "" || cmd.run != nil {
+		t.Errorf("shellCommand() = %+v, want list_containers with a filter", cmd)
+	}
+
+	cmd, err = rc.shellCommand([]string{"-format", "json", "ps", "-request-timeout", "5s"})
+	if err != nil {
+		t.Fatalf("shellCommand() with global flags unexpected error = %v", err)
+	}
+	if cmd.name != "ps" || cmd.opts.format != formatJSON || cmd.requestTimeout != 5*time.Second || rc.opts.format != "" {
+		t.Errorf("shellCommand() = %s with -format %q and a %v timeout, want ps with json and 5s, leaving the shell's unchanged", cmd.name, cmd.opts.format, cmd.requestTimeout)
+	}
+
+	for _, fields := range [][]string{
+		{"shell"}, {"list_containers", "extra"}, {"nope"}, {"-format", "json"},
+		{"-record", "out.jsonl", "ps"}, {"ps", "-identity-cmd", "cat key"}, {"-format", "yaml", "ps"},
+	} {
+		if _, err := rc.shellCommand(fields); err == nil {
+			t.Errorf("shellCommand(%q) error = nil, want an error", fields)
+		}
+	}
+}
+
+func TestRunShell_ExitStatus(t *testing.T) {
+	sshConfig, _ := startMockHost(t)
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "shell"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	cli.pool = client.NewPool(0)
+	defer cli.pool.Close()
+
+	tests := []struct {
+		lines []string
+		want  int
+	}{
+		{[]string{"stop -time 0 missing"}, 1},
+		{[]string{"stop -time 0 missing", "ps"}, 0},
+		{[]string{"unknown"}, 1},
+		{[]string{"stop -time 0 missing", "exit"}, 1},
+	}
+	for _, tt := range tests {
+		if got := cli.runShell(lines(tt.lines...), nil, io.Discard); got != tt.want {
+			t.Errorf("runShell(%q) = %d, want %d", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestRunShellLine_Interrupt(t *testing.T) {
+	requested := make(chan struct{})
+	sshConfig := startMockHostHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		<-r.Context().Done()
+	}))
+	cli, err := NewRemoteCLI([]string{"-ssh-config", sshConfig, "-host", mockHost, "shell"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	cli.pool = client.NewPool(0)
+	defer cli.pool.Close()
+	cmd, err := cli.shellCommand([]string{"list_containers"})
+	if err != nil {
+		t.Fatalf("shellCommand() unexpected error = %v", err)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	// Left from before the command, so not for it
+	interrupts <- os.Interrupt
+	done := make(chan int)
+	go func() { done <- runShellLine(cmd, interrupts) }()
+
+	<-requested
+	interrupts <- os.Interrupt
+	select {
+	case code := <-done:
+		if code == 0 {
+			t.Errorf("runShellLine() = 0 for an interrupted command, want a failure")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runShellLine() not interrupted")
+	}
+}
@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// snapshotBundle is the local file written by "snapshot save": the inspect
+// data of the containers of a host at a point in time.
+type snapshotBundle struct {
+	Host       string              `json:"host"`
+	Created    time.Time           `json:"created"`
+	Containers []snapshotContainer `json:"containers"`
+}
+
+// snapshotContainer is a container of a snapshot. Inspect is the data
+// returned by Podman, kept whole; ImageDigests are the repository digests
+// of its image, by which the image can be pulled again.
+type snapshotContainer struct {
+	Name         string          `json:"name"`
+	Running      bool            `json:"running"`
+	ImageDigests []string        `json:"image_digests,omitempty"`
+	Inspect      json.RawMessage `json:"inspect"`
+}
+
+// newSnapshotCommand returns the "snapshot" command: "save" writes the
+// definitions of all containers of the remote host to a local bundle, and
+// "restore" recreates the containers of a bundle, with the same spec and
+// image, replacing those of the same name, to roll back a bad change.
+func newSnapshotCommand(fs *flag.FlagSet) runFunc {
+	var file, authFile string
+	var tlsVerify bool
+	fs.StringVar(&file, "f", "", "Bundle file (default snapshot-<host>-<time>.json with save)")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file, with restore")
+	fs.BoolVar(&tlsVerify, "tls-verify", true, "Require HTTPS and verify certificates when pulling, with restore")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("snapshot: usage: snapshot [flags] save|restore [flags] [<container>...]")
+			return 1
+		}
+		action := rc.args[0]
+
+		// Flags may also follow the action, as in "snapshot restore -f x.json"
+		if err := fs.Parse(rc.args[1:]); err != nil {
+			slog.Error("snapshot", "err", err)
+			return 1
+		}
+		names := fs.Args()
+		switch {
+		case action != "save" && action != "restore":
+			slog.Error("snapshot: unknown action (use save or restore)", "action", action)
+			return 1
+		case action == "save" && len(names) > 0:
+			slog.Error("snapshot save: unexpected arguments", "args", strings.Join(names, " "))
+			return 1
+		case action == "restore" && file == "":
+			slog.Error("snapshot restore: -f is required")
+			return 1
+		}
+
+		var bundle snapshotBundle
+		if action == "restore" {
+			data, err := os.ReadFile(file)
+			if err == nil {
+				err = json.Unmarshal(data, &bundle)
+			}
+			if err != nil {
+				slog.Error("snapshot restore", "err", err)
+				return 1
+			}
+			if bundle.Containers, err = selectSnapshot(bundle.Containers, names); err != nil {
+				slog.Error("snapshot restore", "err", err)
+				return 1
+			}
+			if bundle.Host != rc.host {
+				slog.Warn("snapshot restore: the snapshot was taken on another host", "snapshot", bundle.Host, "host", rc.host)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if action == "save" {
+			bundle, err := saveSnapshot(ctx, httpClient, rc.host)
+			if err != nil {
+				slog.Error("snapshot save", "err", err)
+				return 1
+			}
+			if file == "" {
+				file = fmt.Sprintf("snapshot-%s-%s.json", rc.host, bundle.Created.Format("20060102-150405"))
+			}
+			data, err := json.MarshalIndent(bundle, "", "  ")
+			if err == nil {
+				err = os.WriteFile(file, append(data, '\n'), 0600)
+			}
+			if err != nil {
+				slog.Error("snapshot save", "err", err)
+				return 1
+			}
+			fmt.Fprintf(os.Stdout, "Saved %d containers to %s\n", len(bundle.Containers), file)
+			return 0
+		}
+
+		var replaced []string
+		for _, c := range bundle.Containers {
+			exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(c.Name)+"/exists")
+			if err != nil {
+				slog.Error("snapshot restore", "target", c.Name, "err", err)
+				return 1
+			}
+			if exists {
+				replaced = append(replaced, c.Name)
+			}
+		}
+		if len(replaced) > 0 && !rc.confirmDestructive("Replace containers "+strings.Join(replaced, ", ")) {
+			return 1
+		}
+
+		pull := func(image string) error {
+			authHeader, err := registryAuth(authFile, image)
+			if err != nil {
+				return err
+			}
+			return pullImage(ctx, httpClient, image, tlsVerify, authHeader, io.Discard, nil)
+		}
+		code := 0
+		for _, c := range bundle.Containers {
+			if err := restoreContainer(ctx, httpClient, c, pull, os.Stdout); err != nil {
+				slog.Error("snapshot restore", "target", c.Name, "err", err)
+				code = 1
+			}
+		}
+		return code
+	}
+}
+
+// selectSnapshot returns the containers of a snapshot with the given
+// names, or all of them if none are given.
+func selectSnapshot(containers []snapshotContainer, names []string) ([]snapshotContainer, error) {
+	if len(names) == 0 {
+		return containers, nil
+	}
+	var selected []snapshotContainer
+	for _, name := range names {
+		i := slices.IndexFunc(containers, func(c snapshotContainer) bool { return c.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("container %s is not in the snapshot", name)
+		}
+		selected = append(selected, containers[i])
+	}
+	return selected, nil
+}
+
+// saveSnapshot returns the snapshot of the containers of the host, sorted
+// by name.
+func saveSnapshot(ctx context.Context, httpClient *http.Client, host string) (*snapshotBundle, error) {
+	var list []models.ListContainer
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/json", url.Values{"all": {"true"}}, &list); err != nil {
+		return nil, err
+	}
+	bundle := &snapshotBundle{Host: host, Created: time.Now().UTC()}
+	for _, c := range list {
+		var raw json.RawMessage
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(c.ID)+"/json", nil, &raw); err != nil {
+			if isStatus(err, http.StatusNotFound) {
+				// Removed since it was listed
+				continue
+			}
+			return nil, err
+		}
+		var ctr models.InspectContainerData
+		if err := json.Unmarshal(raw, &ctr); err != nil {
+			return nil, err
+		}
+		var img models.ImageData
+		if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(ctr.Image)+"/json", nil, &img); err != nil && !isStatus(err, http.StatusNotFound) {
+			return nil, err
+		}
+		bundle.Containers = append(bundle.Containers, snapshotContainer{
+			Name:         strings.TrimPrefix(ctr.Name, "/"),
+			Running:      ctr.State.Running,
+			ImageDigests: img.RepoDigests,
+			Inspect:      raw,
+		})
+	}
+	slices.SortFunc(bundle.Containers, func(a, b snapshotContainer) int { return strings.Compare(a.Name, b.Name) })
+	return bundle, nil
+}
+
+// restoreContainer recreates the container c of a snapshot from its spec,
+// with its volume and bind mounts, replacing any container of the same
+// name, and starts it if it was running. The image is the one the
+// container ran: by its name if that still names it on the host, by its
+// ID if the name now names another image, or else pulled again with pull,
+// by digest if known. Actions are written to out.
+func restoreContainer(ctx context.Context, httpClient *http.Client, c snapshotContainer, pull func(image string) error, out io.Writer) error {
+	var ctr models.InspectContainerData
+	if err := json.Unmarshal(c.Inspect, &ctr); err != nil {
+		return err
+	}
+	spec, err := specFromInspect(ctr, true)
+	if err != nil {
+		return err
+	}
+	if spec.Image, err = snapshotImage(ctx, httpClient, ctr, c.ImageDigests, pull, out); err != nil {
+		return err
+	}
+
+	if err := removeContainer(ctx, httpClient, c.Name, removeOptions{force: true}); err == nil {
+		fmt.Fprintf(out, "Removed container %s\n", c.Name)
+	} else if !isStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("remove: %w", err)
+	}
+	id, err := createContainer(ctx, httpClient, spec)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created container %s from %s\n", c.Name, spec.Image)
+	if !c.Running {
+		return nil
+	}
+	if err := containerAction(ctx, httpClient, id, "start"); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Started container %s\n", c.Name)
+	return nil
+}
+
+// snapshotImage returns the reference to create the container ctr of a
+// snapshot from, pulling its image if the host no longer has it.
+func snapshotImage(ctx context.Context, httpClient *http.Client, ctr models.InspectContainerData, digests []string, pull func(image string) error, out io.Writer) (string, error) {
+	name := ctr.ImageName
+	if name == "" {
+		name = ctr.Config.Image
+	}
+	var img models.ImageData
+	err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(name)+"/json", nil, &img)
+	switch {
+	case err == nil && img.ID == ctr.Image:
+		return name, nil
+	case err != nil && !isStatus(err, http.StatusNotFound):
+		return "", err
+	}
+
+	exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(ctr.Image)+"/exists")
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		fmt.Fprintf(out, "Using image %s for %s, as %s now names another image\n", shortID(ctr.Image), strings.TrimPrefix(ctr.Name, "/"), name)
+		return ctr.Image, nil
+	}
+
+	ref := name
+	if len(digests) > 0 {
+		ref = digests[0]
+	}
+	fmt.Fprintf(out, "Pulling %s\n", ref)
+	if err := pull(ref); err != nil {
+		return "", fmt.Errorf("pull %s: %w", ref, err)
+	}
+	return ref, nil
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestSaveSnapshot(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/json":
+			if r.URL.Query().Get("all") != "true" {
+				t.Errorf("list query = %s, want all containers", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"Id":"b2"},{"Id":"a1"},{"Id":"gone"}]`))
+		case "/v3.0.0/libpod/containers/a1/json":
+			w.Write([]byte(`{"Id":"a1","Name":"web","Image":"sha-web","ImageName":"nginx:1.27","State":{"Running":true},"Extra":{"kept":true}}`))
+		case "/v3.0.0/libpod/containers/b2/json":
+			w.Write([]byte(`{"Id":"b2","Name":"db","Image":"sha-db","ImageName":"postgres:16","State":{"Running":false}}`))
+		case "/v3.0.0/libpod/images/sha-web/json":
+			w.Write([]byte(`{"Id":"sha-web","RepoDigests":["docker.io/library/nginx@sha256:abc"]}`))
+		default:
+			http.Error(w, `{"message":"no such object"}`, http.StatusNotFound)
+		}
+	}))
+
+	bundle, err := saveSnapshot(context.Background(), httpClient, "edge1")
+	if err != nil {
+		t.Fatalf("saveSnapshot() unexpected error = %v", err)
+	}
+	if bundle.Host != "edge1" || len(bundle.Containers) != 2 {
+		t.Fatalf("saveSnapshot() = %+v, want the 2 containers of edge1", bundle)
+	}
+	db, web := bundle.Containers[0], bundle.Containers[1]
+	if db.Name != "db" || db.Running || db.ImageDigests != nil {
+		t.Errorf("saveSnapshot() db = %+v, want stopped without digests", db)
+	}
+	if web.Name != "web" || !web.Running || !reflect.DeepEqual(web.ImageDigests, []string{"docker.io/library/nginx@sha256:abc"}) {
+		t.Errorf("saveSnapshot() web = %+v, want running with its digest", web)
+	}
+	// The inspect data is kept whole, including fields podman-cli ignores
+	if !strings.Contains(string(web.Inspect), `"Extra":{"kept":true}`) {
+		t.Errorf("saveSnapshot() web inspect = %s, want it whole", web.Inspect)
+	}
+}
+
+func TestRestoreContainer(t *testing.T) {
+	inspect, _ := json.Marshal(models.InspectContainerData{
+		Name: "web", Image: "sha-old", ImageName: "nginx:latest",
+		Config:     models.InspectContainerConfig{Env: []string{"MODE=edge"}},
+		HostConfig: models.InspectContainerHostConfig{NetworkMode: "bridge"},
+		Mounts:     []models.InspectMount{{Type: "volume", Name: "html", Destination: "/usr/share/nginx/html", RW: true}},
+	})
+	tests := []struct {
+		name      string
+		images    map[string]string // image name or ID to ID
+		wantImage string
+		wantPull  string
+	}{
+		{name: "same image", images: map[string]string{"nginx:latest": "sha-old", "sha-old": "sha-old"}, wantImage: "nginx:latest"},
+		{name: "tag moved", images: map[string]string{"nginx:latest": "sha-new", "sha-old": "sha-old"}, wantImage: "sha-old"},
+		{name: "image removed", images: map[string]string{"nginx:latest": "sha-new"}, wantImage: "docker.io/library/nginx@sha256:abc", wantPull: "docker.io/library/nginx@sha256:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []string
+			var created containerSpec
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				name, _ := strings.CutPrefix(r.URL.Path, "/v3.0.0/libpod/images/")
+				name = strings.TrimSuffix(strings.TrimSuffix(name, "/json"), "/exists")
+				switch {
+				case strings.HasPrefix(r.URL.Path, "/v3.0.0/libpod/images/"):
+					id, ok := tt.images[name]
+					if !ok {
+						http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+						return
+					}
+					if strings.HasSuffix(r.URL.Path, "/exists") {
+						w.WriteHeader(http.StatusNoContent)
+						return
+					}
+					json.NewEncoder(w).Encode(models.ImageData{ID: id})
+				case r.URL.Path == "/v3.0.0/libpod/containers/create":
+					body, _ := io.ReadAll(r.Body)
+					json.Unmarshal(body, &created)
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"Id":"new"}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			}))
+
+			var pulled string
+			pull := func(image string) error {
+				pulled = image
+				return nil
+			}
+			c := snapshotContainer{Name: "web", Running: true, ImageDigests: []string{"docker.io/library/nginx@sha256:abc"}, Inspect: inspect}
+			var out bytes.Buffer
+			if err := restoreContainer(context.Background(), httpClient, c, pull, &out); err != nil {
+				t.Fatalf("restoreContainer() unexpected error = %v", err)
+			}
+			if created.Name != "web" || created.Image != tt.wantImage || created.Env["MODE"] != "edge" || len(created.Volumes) != 1 {
+				t.Errorf("restoreContainer() created %+v, want web from %s with its env and volume", created, tt.wantImage)
+			}
+			if pulled != tt.wantPull {
+				t.Errorf("restoreContainer() pulled %q, want %q", pulled, tt.wantPull)
+			}
+			tail := requests[len(requests)-3:]
+			want := []string{"DELETE /v3.0.0/libpod/containers/web", "POST /v3.0.0/libpod/containers/create", "POST /v3.0.0/libpod/containers/new/start"}
+			if !reflect.DeepEqual(tail, want) {
+				t.Errorf("restoreContainer() requests = %q, want them to end with %q", requests, want)
+			}
+		})
+	}
+}
+
+func TestSelectSnapshot(t *testing.T) {
+	containers := []snapshotContainer{{Name: "db"}, {Name: "web"}}
+	if got, err := selectSnapshot(containers, nil); err != nil || len(got) != 2 {
+		t.Errorf("selectSnapshot() = %+v, %v, want all", got, err)
+	}
+	if got, err := selectSnapshot(containers, []string{"web"}); err != nil || len(got) != 1 || got[0].Name != "web" {
+		t.Errorf("selectSnapshot(web) = %+v, %v, want web", got, err)
+	}
+	if _, err := selectSnapshot(containers, []string{"cache"}); err == nil {
+		t.Error("selectSnapshot(cache) expected error, got nil")
+	}
+}
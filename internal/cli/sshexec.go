@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHExecCommand returns the "ssh_exec" command, which runs a shell
+// command on the remote host in an exec session of the SSH connection,
+// outside of the Podman API, for auxiliary tasks such as checking disk
+// space or restarting podman.socket. Like ssh, it joins its arguments with
+// spaces into the command line, which the remote shell interprets, and
+// exits with the command's exit status.
+func newSSHExecCommand(fs *flag.FlagSet) runFunc {
+	var interactive bool
+	fs.BoolVar(&interactive, "i", false, "Pass stdin to the command")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("ssh_exec: usage: ssh_exec [-i] [--] <command> [<arg>...]")
+			return 1
+		}
+		cmd := strings.Join(rc.args, " ")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, err := rc.dialSSH(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if rc.requestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rc.requestTimeout)
+			defer cancel()
+		}
+		var stdin io.Reader
+		if interactive {
+			stdin = os.Stdin
+		}
+		start := time.Now()
+		err = client.ExecContext(ctx, sshClient, cmd, stdin, os.Stdout, os.Stderr)
+		rc.auditSSH(auditExec, cmd, start, err)
+		var exitErr *ssh.ExitError
+		switch {
+		case err == nil:
+			return 0
+		case errors.As(err, &exitErr):
+			return exitErr.ExitStatus()
+		case errors.Is(err, context.DeadlineExceeded):
+			slog.Error("ssh_exec: the command did not finish in time (see -request-timeout)", "timeout", rc.requestTimeout)
+		default:
+			slog.Error("ssh_exec", "err", err)
+		}
+		return 1
+	}
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSSHExecCommand_Args(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+	setHomeDir(t, tmpDir)
+
+	cli, err := NewRemoteCLI([]string{"ssh_exec", "-host", "testhost", "-i", "--", "df", "-h", "/"})
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+	// Flags after "--" belong to the remote command
+	if want := []string{"df", "-h", "/"}; !reflect.DeepEqual(cli.args, want) {
+		t.Errorf("args = %q, want %q", cli.args, want)
+	}
+}
+
+func TestSSHExecCommand_RequiresCommand(t *testing.T) {
+	run := newTestCommand(t, newSSHExecCommand)
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("ssh_exec without a command = %d, want 1", code)
+	}
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// States of a host in the status matrix, colored by statusColor.
+const (
+	hostOK          = "ok"
+	hostDegraded    = "degraded"
+	hostUnreachable = "unreachable"
+)
+
+// hostStatus is a row of the status matrix.
+type hostStatus struct {
+	state     string
+	podman    string
+	running   int
+	exited    int
+	unhealthy int
+	disk      float64 // percentage of the graph root filesystem used, -1 if unknown
+	notes     []string
+}
+
+// newStatusCommand returns the "status" command, which checks several
+// hosts in parallel and prints a compact matrix of their health: whether
+// they are reachable, their Podman version, their running, exited and
+// unhealthy containers and the disk usage of their container storage.
+func newStatusCommand(fs *flag.FlagSet) runFunc {
+	var hosts string
+	var maxParallel int
+	var diskThreshold float64
+	fs.StringVar(&hosts, "hosts", "", "Comma separated hosts, or @<group> for a group of the configuration file")
+	fs.IntVar(&maxParallel, "max-parallel", 8, "Number of hosts checked at the same time")
+	fs.Float64Var(&diskThreshold, "disk-threshold", 90, "Disk usage percentage from which a host is degraded")
+
+	return func(rc *RemoteCLI) int {
+		if hosts == "" || len(rc.args) > 0 {
+			slog.Error("status: usage: status -hosts <host>,...|@<group>")
+			return 1
+		}
+		if maxParallel < 1 {
+			slog.Error("status: -max-parallel must be at least 1")
+			return 1
+		}
+		targets, err := resolveHosts(hosts, rc.opts.configFile)
+		if err != nil {
+			slog.Error("status", "err", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		statuses := make([]hostStatus, len(targets))
+		forEachHost(targets, maxParallel, func(i int, host string) {
+			httpClient, closeFn, err := connectHost(ctx, rc, host)
+			if err != nil {
+				slog.Debug("status: failed to connect", "host", host, "err", err)
+				statuses[i] = hostStatus{state: hostUnreachable, disk: -1, notes: []string{err.Error()}}
+				return
+			}
+			defer closeFn()
+			statuses[i] = collectHostStatus(ctx, httpClient, diskThreshold)
+		})
+
+		code := 0
+		rows := make([]tuiRow, len(targets))
+		for i, host := range targets {
+			s := statuses[i]
+			if s.state != hostOK {
+				code = 1
+			}
+			disk := "-"
+			if s.disk >= 0 {
+				disk = fmt.Sprintf("%.0f%%", s.disk)
+			}
+			counts := []string{strconv.Itoa(s.running), strconv.Itoa(s.exited), strconv.Itoa(s.unhealthy)}
+			if s.state == hostUnreachable {
+				counts = []string{"-", "-", "-"}
+			}
+			podman := s.podman
+			if podman == "" {
+				podman = "-"
+			}
+			cols := append([]string{host, s.state, podman}, counts...)
+			rows[i] = tuiRow{id: host, cols: append(cols, disk, strings.Join(s.notes, "; "))}
+		}
+		headers := []string{"HOST", "STATUS", "PODMAN", "RUNNING", "EXITED", "UNHEALTHY", "DISK", "NOTES"}
+		if err := writeTable(os.Stdout, rc.opts.format, headers, rows); err != nil {
+			slog.Error("status", "err", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// collectHostStatus returns the status of a reachable host. The host is
+// degraded if any container is unhealthy, its disk usage reaches
+// diskThreshold percent, or part of its status could not be collected.
+func collectHostStatus(ctx context.Context, httpClient *http.Client, diskThreshold float64) hostStatus {
+	s := hostStatus{state: hostOK, disk: -1}
+	degrade := func(note string) {
+		s.state = hostDegraded
+		s.notes = append(s.notes, note)
+	}
+
+	var info struct {
+		Store struct {
+			GraphRootAllocated uint64 `json:"graphRootAllocated"`
+			GraphRootUsed      uint64 `json:"graphRootUsed"`
+		} `json:"store"`
+		Version struct {
+			Version string `json:"Version"`
+		} `json:"version"`
+	}
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/info", nil, &info); err != nil {
+		degrade("info: " + err.Error())
+	} else {
+		s.podman = info.Version.Version
+		// Podman before 4.0 does not report the size of the filesystem
+		if info.Store.GraphRootAllocated > 0 {
+			s.disk = 100 * float64(info.Store.GraphRootUsed) / float64(info.Store.GraphRootAllocated)
+			if s.disk >= diskThreshold {
+				degrade(fmt.Sprintf("disk %.0f%% used", s.disk))
+			}
+		}
+	}
+
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, httpClient, "/v3.0.0/containers/json", url.Values{"all": {"true"}}, &containers); err != nil {
+		degrade("containers: " + err.Error())
+		return s
+	}
+	for _, c := range containers {
+		switch c.State {
+		case "running":
+			s.running++
+		case "exited":
+			s.exited++
+		}
+		if healthFromStatus(c.Status) == models.HealthUnhealthy {
+			s.unhealthy++
+		}
+	}
+	if s.unhealthy > 0 {
+		degrade(fmt.Sprintf("%d unhealthy", s.unhealthy))
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCollectHostStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		info       string
+		containers string
+		want       hostStatus
+	}{
+		{
+			name:       "ok",
+			info:       `{"store":{"graphRootAllocated":1000,"graphRootUsed":420},"version":{"Version":"5.2.1"}}`,
+			containers: `[{"State":"running","Status":"Up 2 hours (healthy)"},{"State":"running","Status":"Up 2 hours"},{"State":"exited","Status":"Exited (0) 1 hour ago"},{"State":"created"}]`,
+			want:       hostStatus{state: hostOK, podman: "5.2.1", running: 2, exited: 1, disk: 42},
+		},
+		{
+			name:       "unhealthy and full",
+			info:       `{"store":{"graphRootAllocated":1000,"graphRootUsed":950},"version":{"Version":"4.9.4"}}`,
+			containers: `[{"State":"running","Status":"Up 5 minutes (unhealthy)"}]`,
+			want:       hostStatus{state: hostDegraded, podman: "4.9.4", running: 1, unhealthy: 1, disk: 95, notes: []string{"disk 95% used", "1 unhealthy"}},
+		},
+		{
+			name:       "disk size unknown",
+			info:       `{"store":{},"version":{"Version":"3.4.4"}}`,
+			containers: `[]`,
+			want:       hostStatus{state: hostOK, podman: "3.4.4", disk: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v3.0.0/libpod/info":
+					w.Write([]byte(tt.info))
+				case "/v3.0.0/containers/json":
+					if r.URL.Query().Get("all") != "true" {
+						t.Errorf("containers query = %s, want all", r.URL.RawQuery)
+					}
+					w.Write([]byte(tt.containers))
+				default:
+					t.Errorf("unexpected request %s", r.URL.Path)
+				}
+			}))
+			if got := collectHostStatus(context.Background(), httpClient, 90); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectHostStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectHostStatus_APIError(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"storage is locked"}`, http.StatusInternalServerError)
+	}))
+	got := collectHostStatus(context.Background(), httpClient, 90)
+	if got.state != hostDegraded || len(got.notes) != 2 {
+		t.Errorf("collectHostStatus() = %+v, want degraded with the info and containers errors", got)
+	}
+}
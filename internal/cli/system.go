@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// newSystemResetCommand returns the "system_reset" command, which removes
+// all containers, pods, images, volumes, networks and secrets on the
+// remote host, as "podman system reset" does. As there is no undoing it,
+// the host name must be typed at the prompt or given with -confirm.
+func newSystemResetCommand(fs *flag.FlagSet) runFunc {
+	var confirmHost string
+	fs.StringVar(&confirmHost, "confirm", "", "Confirm the reset without prompting by repeating the -host value")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) > 0 {
+			slog.Error("system_reset: unexpected arguments", "args", rc.args)
+			return 1
+		}
+
+		if confirmHost == "" {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				slog.Error("system_reset: confirm the reset with -confirm " + rc.host)
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "This removes all containers, pods, images, volumes, networks and secrets on %s.\n", rc.host)
+			confirmHost = promptHost(os.Stdin, os.Stderr)
+		}
+		if !resetConfirmed(rc.host, confirmHost) {
+			slog.Error("system_reset: confirmation does not match the host; nothing was removed", "host", rc.host, "confirm", confirmHost)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if err := systemReset(ctx, httpClient); err != nil {
+			slog.Error("system_reset", "host", rc.host, "err", err)
+			return 1
+		}
+		fmt.Printf("Reset Podman on %s\n", rc.host)
+		return 0
+	}
+}
+
+// newPruneCommand returns the "prune" command, which removes the unused
+// data on the remote host, as "podman system prune" does: stopped
+// containers, pods without running containers, unused networks and
+// dangling images, optionally along with all unused images and volumes.
+func newPruneCommand(fs *flag.FlagSet) runFunc {
+	var all, volumes bool
+	fs.BoolVar(&all, "all", false, "Remove all images not used by a container, not just dangling ones")
+	fs.BoolVar(&volumes, "volumes", false, "Also remove volumes not used by a container")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) > 0 {
+			slog.Error("prune: unexpected arguments", "args", rc.args)
+			return 1
+		}
+		parts := []string{"stopped containers", "unused pods", "unused networks", "dangling images"}
+		if all {
+			parts[3] = "unused images"
+		}
+		if volumes {
+			parts = append(parts, "unused volumes")
+		}
+		what := strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+		if !rc.confirmDestructive("Remove " + what) {
+			slog.Error("prune: aborted; nothing was removed")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		report, err := systemPrune(ctx, httpClient, all, volumes)
+		if err != nil {
+			slog.Error("prune", "host", rc.host, "err", err)
+			return 1
+		}
+		code := 0
+		for _, e := range report.errors() {
+			slog.Error("prune", "err", e)
+			code = 1
+		}
+		fmt.Printf("Removed %d containers, %d pods, %d networks, %d images and %d volumes on %s, reclaiming %s\n",
+			len(report.Containers), len(report.Pods), len(report.Networks), len(report.Images), len(report.Volumes), rc.host, formatSize(report.ReclaimedSpace))
+		return code
+	}
+}
+
+// pruneItem is an object removed, or failed to be removed, by a prune.
+type pruneItem struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+	Size int64  `json:"Size"`
+	Err  string `json:"Err"`
+}
+
+// pruneReport is the response of the system prune endpoint.
+type pruneReport struct {
+	Pods           []pruneItem `json:"PodPruneReport"`
+	Containers     []pruneItem `json:"ContainerPruneReports"`
+	Images         []pruneItem `json:"ImagePruneReports"`
+	Networks       []pruneItem `json:"NetworkPruneReports"`
+	Volumes        []pruneItem `json:"VolumePruneReports"`
+	ReclaimedSpace int64       `json:"ReclaimedSpace"`
+}
+
+// errors returns the failures reported for individual objects.
+func (r *pruneReport) errors() []string {
+	var errs []string
+	for _, items := range [][]pruneItem{r.Pods, r.Containers, r.Images, r.Networks, r.Volumes} {
+		for _, item := range items {
+			if item.Err != "" {
+				errs = append(errs, item.ID+item.Name+": "+item.Err)
+			}
+		}
+	}
+	return errs
+}
+
+// systemPrune removes the unused data on the remote host.
+func systemPrune(ctx context.Context, httpClient *http.Client, all, volumes bool) (*pruneReport, error) {
+	query := url.Values{"all": {strconv.FormatBool(all)}, "volumes": {strconv.FormatBool(volumes)}}
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/system/prune", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var report pruneReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &report, nil
+}
+
+// promptHost asks for the host name on out and returns the line read from
+// in.
+func promptHost(in io.Reader, out io.Writer) string {
+	fmt.Fprint(out, "Type the host name to confirm: ")
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// resetConfirmed reports whether confirmation repeats host exactly, so
+// that a reset cannot be confirmed for one host and sent to another.
+func resetConfirmed(host, confirmation string) bool {
+	return host != "" && confirmation == host
+}
+
+// systemReset removes all Podman containers, pods, images, volumes,
+// networks and secrets.
+func systemReset(ctx context.Context, httpClient *http.Client) error {
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/system/reset", nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResetConfirmed(t *testing.T) {
+	tests := []struct {
+		host, confirmation string
+		want               bool
+	}{
+		{"test-device-3", "test-device-3", true},
+		{"test-device-3", "test-device-4", false},
+		{"test-device-3", "TEST-DEVICE-3", false},
+		{"test-device-3", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := resetConfirmed(tt.host, tt.confirmation); got != tt.want {
+			t.Errorf("resetConfirmed(%q, %q) = %v, want %v", tt.host, tt.confirmation, got, tt.want)
+		}
+	}
+}
+
+func TestPromptHost(t *testing.T) {
+	var out strings.Builder
+	if got := promptHost(strings.NewReader("  test-device-3 \n"), &out); got != "test-device-3" {
+		t.Errorf("promptHost() = %q, want %q", got, "test-device-3")
+	}
+	if !strings.Contains(out.String(), "Type the host name") {
+		t.Errorf("promptHost() prompt = %q", out.String())
+	}
+}
+
+func TestSystemReset(t *testing.T) {
+	var gotMethod, gotPath string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if err := systemReset(context.Background(), httpClient); err != nil {
+		t.Fatalf("systemReset() unexpected error = %v", err)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/v3.0.0/libpod/system/reset" {
+		t.Errorf("systemReset() sent %s %s", gotMethod, gotPath)
+	}
+}
+
+func TestSystemResetCommand_Unconfirmed(t *testing.T) {
+	run := newTestCommand(t, newSystemResetCommand, "-confirm", "other-host")
+
+	// A mismatch fails before connecting, so no connection is set up
+	if code := run(&RemoteCLI{host: "test-device-3"}); code != 1 {
+		t.Errorf("system_reset exit code = %d, want 1", code)
+	}
+}
+
+func TestSystemPrune(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.0.0/libpod/system/prune" || r.URL.Query().Get("all") != "true" || r.URL.Query().Get("volumes") != "false" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`{"ContainerPruneReports":[{"Id":"abc","Size":1024}],"ImagePruneReports":[{"Id":"def","Size":2048},{"Id":"123","Err":"image is in use"}],"NetworkPruneReports":[{"Name":"old"}],"ReclaimedSpace":3072}`))
+	}))
+
+	report, err := systemPrune(context.Background(), httpClient, true, false)
+	if err != nil {
+		t.Fatalf("systemPrune() unexpected error = %v", err)
+	}
+	if len(report.Containers) != 1 || len(report.Images) != 2 || len(report.Networks) != 1 || report.ReclaimedSpace != 3072 {
+		t.Errorf("systemPrune() = %+v", report)
+	}
+	if errs := report.errors(); len(errs) != 1 || errs[0] != "123: image is in use" {
+		t.Errorf("errors() = %q", errs)
+	}
+}
+
+func TestConfirmDestructive(t *testing.T) {
+	// Test input is not a terminal, as in scripts, so nothing is asked
+	rc := &RemoteCLI{host: "edge1"}
+	if !rc.confirmDestructive("Remove containers web") {
+		t.Error("confirmDestructive() = false without a terminal, want true")
+	}
+	rc.opts.yes = true
+	if !rc.confirmDestructive("Remove containers web") {
+		t.Error("confirmDestructive() = false with -yes, want true")
+	}
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// Remote directories, relative to the remote user's home directory, from
+// which rootless systemd units and Quadlet files are loaded.
+const (
+	remoteUnitDir    = ".config/systemd/user"
+	remoteQuadletDir = ".config/containers/systemd"
+)
+
+// quadletSkipEnv lists environment variables set by Podman or the runtime
+// that are not carried over into Quadlet files.
+var quadletSkipEnv = []string{"container", "HOME", "HOSTNAME", "TERM"}
+
+// newGenerateSystemdCommand returns the "generate_systemd" command, which
+// generates systemd units, or Quadlet .container files with -quadlet, for
+// containers on the remote host. Units are printed, written to a local
+// directory with -files, or installed on the remote host with -install.
+func newGenerateSystemdCommand(fs *flag.FlagSet) runFunc {
+	var restartPolicy, files string
+	var newCtr, quadlet, install bool
+	fs.BoolVar(&newCtr, "new", false, "Create a new container when the service starts instead of starting the existing one")
+	fs.StringVar(&restartPolicy, "restart-policy", "on-failure", "systemd restart policy for the units")
+	fs.BoolVar(&quadlet, "quadlet", false, "Generate Quadlet .container files instead of systemd units")
+	fs.StringVar(&files, "files", "", "Write the units to this local directory instead of stdout")
+	fs.BoolVar(&install, "install", false, "Copy the units to the remote host's systemd directory and reload systemd")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) == 0 {
+			slog.Error("generate_systemd: at least one container name is required")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		// units maps file names to their contents
+		units := map[string]string{}
+		for _, name := range rc.args {
+			if quadlet {
+				unit, err := generateQuadlet(ctx, httpClient, name)
+				if err != nil {
+					slog.Error("generate_systemd", "target", name, "err", err)
+					return 1
+				}
+				units[name+".container"] = unit
+				continue
+			}
+
+			query := url.Values{
+				"new":           {strconv.FormatBool(newCtr)},
+				"restartPolicy": {restartPolicy},
+			}
+			var generated map[string]string
+			if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/generate/"+url.PathEscape(name)+"/systemd", query, &generated); err != nil {
+				slog.Error("generate_systemd", "target", name, "err", err)
+				return 1
+			}
+			for unit, content := range generated {
+				units[unit+".service"] = content
+			}
+		}
+
+		names := make([]string, 0, len(units))
+		for name := range units {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		switch {
+		case install:
+			dir := remoteUnitDir
+			if quadlet {
+				dir = remoteQuadletDir
+			}
+			if err := rc.installUnits(sshClient.Client, dir, names, units); err != nil {
+				slog.Error("generate_systemd", "err", err)
+				return 1
+			}
+		case files != "":
+			for _, name := range names {
+				path := filepath.Join(files, name)
+				if err := os.WriteFile(path, []byte(units[name]), 0644); err != nil {
+					slog.Error("generate_systemd", "err", err)
+					return 1
+				}
+				fmt.Println(path)
+			}
+		default:
+			for _, name := range names {
+				fmt.Printf("# %s\n%s\n", name, units[name])
+			}
+		}
+		return 0
+	}
+}
+
+// generateQuadlet builds a Quadlet .container file reproducing the named
+// container from its inspect data. Settings inherited from the image are
+// omitted.
+func generateQuadlet(ctx context.Context, httpClient *http.Client, name string) (string, error) {
+	var ctr models.InspectContainerData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/json", nil, &ctr); err != nil {
+		return "", err
+	}
+
+	var img models.ImageData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(ctr.ImageName)+"/json", nil, &img); err != nil {
+		return "", fmt.Errorf("inspect image %s: %w", ctr.ImageName, err)
+	}
+
+	return quadletUnit(ctr, img), nil
+}
+
+// quadletUnit renders the Quadlet .container file for ctr.
+func quadletUnit(ctr models.InspectContainerData, img models.ImageData) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[Unit]\nDescription=Podman container %s\n\n", ctr.Name)
+	fmt.Fprintf(&b, "[Container]\nContainerName=%s\nImage=%s\n", ctr.Name, ctr.ImageName)
+
+	for _, env := range ctr.Config.Env {
+		key, _, _ := strings.Cut(env, "=")
+		if slices.Contains(img.Config.Env, env) || slices.Contains(quadletSkipEnv, key) {
+			continue
+		}
+		fmt.Fprintf(&b, "Environment=%s\n", quoteQuadlet(env))
+	}
+
+	ports := make([]string, 0, len(ctr.HostConfig.PortBindings))
+	for port := range ctr.HostConfig.PortBindings {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		for _, binding := range ctr.HostConfig.PortBindings[port] {
+			publish := binding.HostPort + ":" + port
+			if binding.HostIP != "" {
+				publish = net.JoinHostPort(binding.HostIP, publish)
+			}
+			fmt.Fprintf(&b, "PublishPort=%s\n", publish)
+		}
+	}
+
+	for _, m := range ctr.Mounts {
+		source := m.Source
+		if m.Type == "volume" {
+			source = m.Name
+		}
+		volume := source + ":" + m.Destination
+		if !m.RW {
+			volume += ":ro"
+		}
+		fmt.Fprintf(&b, "Volume=%s\n", volume)
+	}
+
+	labels := make([]string, 0, len(ctr.Config.Labels))
+	for key := range ctr.Config.Labels {
+		labels = append(labels, key)
+	}
+	sort.Strings(labels)
+	for _, key := range labels {
+		fmt.Fprintf(&b, "Label=%s\n", quoteQuadlet(key+"="+ctr.Config.Labels[key]))
+	}
+
+	if len(ctr.Config.Cmd) > 0 && !slices.Equal(ctr.Config.Cmd, img.Config.Cmd) {
+		args := make([]string, len(ctr.Config.Cmd))
+		for i, arg := range ctr.Config.Cmd {
+			args[i] = quoteQuadlet(arg)
+		}
+		fmt.Fprintf(&b, "Exec=%s\n", strings.Join(args, " "))
+	}
+
+	b.WriteString("\n[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// quoteQuadlet quotes s for use as a systemd unit file value if it contains
+// whitespace or quotes.
+func quoteQuadlet(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// installUnits copies the units to dir, relative to the remote user's home
+// directory, over SSH and reloads the user's systemd manager. The commands
+// are audited.
+func (rc *RemoteCLI) installUnits(sshClient *ssh.Client, dir string, names []string, units map[string]string) error {
+	run := func(cmd string, stdin io.Reader, stdout io.Writer) error {
+		start := time.Now()
+		err := client.Exec(sshClient, cmd, stdin, stdout, os.Stderr)
+		rc.auditSSH(auditExec, cmd, start, err)
+		return err
+	}
+
+	if err := run("mkdir -p "+client.ShellQuote(dir), nil, nil); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+
+	for _, name := range names {
+		path := dir + "/" + name
+		if err := run("cat > "+client.ShellQuote(path), strings.NewReader(units[name]), nil); err != nil {
+			return fmt.Errorf("copy %s: %w", path, err)
+		}
+		fmt.Println("Installed", "~/"+path)
+	}
+
+	if err := run("systemctl --user daemon-reload", nil, os.Stdout); err != nil {
+		return fmt.Errorf("daemon-reload: %w", err)
+	}
+	return nil
+}
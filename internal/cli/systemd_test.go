@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestGenerateQuadlet(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/v3.0.0/libpod/containers/web/json":
+			w.Write([]byte(`{
+				"Name": "web",
+				"ImageName": "docker.io/library/nginx:latest",
+				"Config": {
+					"Env": ["PATH=/usr/bin", "HOSTNAME=abc", "MODE=prod", "GREETING=hello world"],
+					"Cmd": ["nginx", "-g", "daemon off;"],
+					"Labels": {"app": "web"}
+				},
+				"HostConfig": {"PortBindings": {"80/tcp": [{"HostIp": "", "HostPort": "8080"}]}},
+				"Mounts": [
+					{"Type": "volume", "Name": "html", "Source": "/var/lib/volumes/html", "Destination": "/usr/share/nginx/html", "RW": false},
+					{"Type": "bind", "Source": "/etc/web", "Destination": "/etc/nginx/conf.d", "RW": true}
+				]
+			}`))
+		case "/v3.0.0/libpod/images/docker.io%2Flibrary%2Fnginx:latest/json":
+			w.Write([]byte(`{"Config": {"Env": ["PATH=/usr/bin"], "Cmd": ["nginx", "-g", "daemon off;"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	got, err := generateQuadlet(context.Background(), httpClient, "web")
+	if err != nil {
+		t.Fatalf("generateQuadlet() unexpected error = %v", err)
+	}
+
+	want := `[Unit]
+Description=Podman container web
+
+[Container]
+ContainerName=web
+Image=docker.io/library/nginx:latest
+Environment=MODE=prod
+Environment="GREETING=hello world"
+PublishPort=8080:80/tcp
+Volume=html:/usr/share/nginx/html:ro
+Volume=/etc/web:/etc/nginx/conf.d
+Label=app=web
+
+[Install]
+WantedBy=default.target
+`
+	if got != want {
+		t.Errorf("generateQuadlet() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestQuadletUnit_CustomCommand(t *testing.T) {
+	var ctr models.InspectContainerData
+	ctr.Name = "job"
+	ctr.ImageName = "alpine"
+	ctr.Config.Cmd = []string{"sh", "-c", "echo hi"}
+
+	var img models.ImageData
+	img.Config.Cmd = []string{"/bin/sh"}
+
+	got := quadletUnit(ctr, img)
+	if want := "Exec=sh -c \"echo hi\"\n"; !strings.Contains(got, want) {
+		t.Errorf("quadletUnit() = %q, want line %q", got, want)
+	}
+}
+
+func TestGenerateSystemdCommand_RequiresNames(t *testing.T) {
+	run := newTestCommand(t, newGenerateSystemdCommand, "-quadlet")
+	if code := run(&RemoteCLI{}); code != 1 {
+		t.Errorf("generate_systemd without names exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// localTerminal is the terminal of an interactive session with a TTY,
+// switched to raw mode so that keys such as Ctrl-C and the escape
+// sequences of full-screen programs reach the remote program unchanged.
+type localTerminal struct {
+	fd    int
+	state *term.State
+}
+
+// openTerminal puts the local terminal into raw mode for a session with a
+// TTY. It returns nil, leaving the terminal alone, if tty is not set or
+// stdin is not a terminal. The terminal must be restored with restore,
+// which deferred calls also do when the command panics.
+func openTerminal(tty bool) (*localTerminal, error) {
+	fd := int(os.Stdin.Fd())
+	if !tty || !term.IsTerminal(fd) {
+		return nil, nil
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return &localTerminal{fd: fd, state: state}, nil
+}
+
+// restore returns the terminal to the mode it had before openTerminal.
+func (t *localTerminal) restore() {
+	if t != nil {
+		term.Restore(t.fd, t.state)
+	}
+}
+
+// size returns the width and height of the terminal.
+func (t *localTerminal) size() (int, int, error) {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return term.GetSize(int(os.Stdout.Fd()))
+	}
+	return term.GetSize(t.fd)
+}
+
+// forwardResize sends the size of the terminal with resize, then again
+// each time the terminal is resized, until the returned function is
+// called or ctx is done. It does nothing for a nil terminal.
+func (t *localTerminal) forwardResize(ctx context.Context, resize func(ctx context.Context, width, height int) error) func() {
+	if t == nil {
+		return func() {}
+	}
+	send := func() {
+		width, height, err := t.size()
+		if err != nil {
+			return
+		}
+		if err := resize(ctx, width, height); err != nil {
+			slog.Debug("Resizing the remote terminal failed", "err", err)
+		}
+	}
+	send()
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-resized:
+				send()
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(resized)
+		close(done)
+	}
+}
+
+// resizeTTY sets the size of the TTY of the exec session or container at
+// path, such as /v3.0.0/libpod/exec/{id}/resize.
+func resizeTTY(ctx context.Context, httpClient *http.Client, path string, width, height int) error {
+	query := url.Values{"h": {strconv.Itoa(height)}, "w": {strconv.Itoa(width)}}
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, path, query, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// hijack sends a POST request with the JSON encoding of body, if not nil,
+// to an endpoint that takes over the connection for the session it
+// starts, such as exec start and attach. It returns the output of the
+// session and, if Podman upgraded the connection, the input of the
+// session, which is nil otherwise.
+func hijack(ctx context.Context, httpClient *http.Client, path string, query url.Values, body any) (io.ReadCloser, *sessionInput, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	// The connection is kept to half-close it at the end of stdin, which
+	// the response body does not allow
+	var conn net.Conn
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { conn = info.Conn },
+	})
+	req, err := newAPIRequest(ctx, http.MethodPost, path, query, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	resp, err := doAPIRequest(httpClient, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rw, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		return rw, &sessionInput{Writer: rw, conn: conn}, nil
+	}
+	return resp.Body, nil, nil
+}
+
+// sessionInput writes to the stdin of an exec or attach session.
+type sessionInput struct {
+	io.Writer
+	conn net.Conn
+}
+
+// closeWrite half-closes the connection of the session, which Podman
+// passes on to the remote process as the end of its stdin, while its
+// output can still be read.
+func (in *sessionInput) closeWrite() error {
+	if cw, ok := in.conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.ErrUnsupported
+}
+
+// streamSession copies stdin, if not nil, to the session through in and
+// the output of the session to stdout and stderr until the session ends:
+// as is with a TTY, and demultiplexed otherwise. The end of stdin, such as
+// that of a file piped to the CLI, is passed on to the session. The
+// session is closed when ctx is done.
+func streamSession(ctx context.Context, output io.ReadCloser, in *sessionInput, stdin io.Reader, tty bool, stdout, stderr io.Writer) error {
+	defer output.Close()
+	stop := context.AfterFunc(ctx, func() { output.Close() })
+	defer stop()
+
+	if stdin != nil {
+		if in == nil {
+			return errors.New("podman did not upgrade the connection, so stdin cannot be sent")
+		}
+		go func() {
+			if _, err := io.Copy(in, stdin); err != nil {
+				slog.Debug("Sending stdin failed", "err", err)
+				return
+			}
+			if err := in.closeWrite(); err != nil {
+				slog.Debug("Closing stdin failed", "err", err)
+			}
+		}()
+	}
+
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, output)
+	} else {
+		err = demuxStream(stdout, stderr, output)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
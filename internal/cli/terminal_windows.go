@@ -0,0 +1,8 @@
+package cli
+
+import "os"
+
+// notifyResize does nothing, as Windows does not signal resizes of the
+// console: the remote terminal keeps the size it had when the session
+// started.
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,530 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+	"golang.org/x/term"
+)
+
+// Terminal control sequences used by the dashboard.
+const (
+	ansiAltScreen    = "\x1b[?1049h"
+	ansiMainScreen   = "\x1b[?1049l"
+	ansiHideCursor   = "\x1b[?25l"
+	ansiShowCursor   = "\x1b[?25h"
+	ansiClear        = "\x1b[H\x1b[2J"
+	ansiReverse      = "\x1b[7m"
+	ansiBold         = "\x1b[1m"
+	ansiReset        = "\x1b[0m"
+	tuiFooterHelp    = "tab/←/→ pane  ↑/↓ select  s start  x stop  l logs  i inspect  r refresh  q quit"
+	tuiDetailHelp    = "↑/↓ scroll  esc/q back"
+	tuiLogTail       = "200"
+	tuiDefaultHeight = 24
+)
+
+// Dashboard panes, in tab order.
+const (
+	paneContainers = iota
+	panePods
+	paneImages
+	paneCount
+)
+
+var paneTitles = [paneCount]string{"Containers", "Pods", "Images"}
+
+var paneHeaders = [paneCount][]string{
+	{"NAME", "IMAGE", "STATE", "CPU %", "MEM", "STATUS"},
+	{"NAME", "ID", "STATUS", "CONTAINERS"},
+	{"REPOSITORY:TAG", "ID", "SIZE"},
+}
+
+// tuiRow is a single line in a dashboard pane.
+type tuiRow struct {
+	id   string
+	cols []string
+}
+
+// dashboard holds the state of the terminal UI.
+type dashboard struct {
+	rc         *RemoteCLI
+	httpClient *http.Client
+	out        io.Writer
+
+	pane     int
+	selected [paneCount]int
+	rows     [paneCount][]tuiRow
+
+	// When detail is non-nil it is shown in place of the panes
+	detail       []string
+	detailTitle  string
+	detailOffset int
+
+	status string
+	width  int
+	height int
+}
+
+// newTUICommand returns the "tui" command, a top-like dashboard of the
+// remote host's containers, pods and images with live stats and key
+// bindings for common operations.
+func newTUICommand(fs *flag.FlagSet) runFunc {
+	var interval time.Duration
+	fs.DurationVar(&interval, "interval", 2*time.Second, "Refresh interval")
+
+	return func(rc *RemoteCLI) int {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			slog.Error("tui: stdin is not a terminal")
+			return 1
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			slog.Error("tui", "err", err)
+			return 1
+		}
+		defer term.Restore(fd, state)
+
+		fmt.Print(ansiAltScreen + ansiHideCursor)
+		defer fmt.Print(ansiShowCursor + ansiMainScreen)
+
+		d := &dashboard{rc: rc, httpClient: httpClient, out: os.Stdout}
+		d.refresh(ctx)
+
+		keys := make(chan string)
+		go func() {
+			defer close(keys)
+			buf := make([]byte, 16)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					return
+				}
+				keys <- string(buf[:n])
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			d.width, d.height, err = term.GetSize(fd)
+			if err != nil || d.height <= 0 {
+				d.width, d.height = 0, tuiDefaultHeight
+			}
+			d.render()
+
+			select {
+			case key, ok := <-keys:
+				if !ok || !d.handleKey(ctx, parseKey(key)) {
+					return 0
+				}
+			case <-ticker.C:
+				if d.detail == nil {
+					d.refresh(ctx)
+				}
+			}
+		}
+	}
+}
+
+// parseKey maps raw terminal input to a key name. Escape sequences for the
+// arrow keys are translated to "up", "down", "left" and "right".
+func parseKey(input string) string {
+	switch input {
+	case "\x1b[A", "\x1bOA", "k":
+		return "up"
+	case "\x1b[B", "\x1bOB", "j":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\t":
+		return "tab"
+	case "\x1b":
+		return "esc"
+	case "\r", "\n":
+		return "enter"
+	case "\x03":
+		return "ctrl-c"
+	}
+	return input
+}
+
+// handleKey applies a key press to the dashboard. It returns false when the
+// dashboard should exit.
+func (d *dashboard) handleKey(ctx context.Context, key string) bool {
+	if key == "ctrl-c" {
+		return false
+	}
+
+	if d.detail != nil {
+		switch key {
+		case "up":
+			if d.detailOffset > 0 {
+				d.detailOffset--
+			}
+		case "down":
+			if d.detailOffset < len(d.detail)-1 {
+				d.detailOffset++
+			}
+		case "esc", "q", "enter":
+			d.detail = nil
+			d.detailOffset = 0
+		}
+		return true
+	}
+
+	switch key {
+	case "q":
+		return false
+	case "tab", "right":
+		d.pane = (d.pane + 1) % paneCount
+	case "left":
+		d.pane = (d.pane + paneCount - 1) % paneCount
+	case "up":
+		if d.selected[d.pane] > 0 {
+			d.selected[d.pane]--
+		}
+	case "down":
+		if d.selected[d.pane] < len(d.rows[d.pane])-1 {
+			d.selected[d.pane]++
+		}
+	case "r":
+		d.refresh(ctx)
+	case "s":
+		d.action(ctx, "start")
+	case "x":
+		d.action(ctx, "stop")
+	case "l":
+		d.showLogs(ctx)
+	case "i", "enter":
+		d.showInspect(ctx)
+	}
+	return true
+}
+
+// current returns the selected row of the active pane, or nil if the pane
+// is empty.
+func (d *dashboard) current() *tuiRow {
+	rows := d.rows[d.pane]
+	i := d.selected[d.pane]
+	if i < 0 || i >= len(rows) {
+		return nil
+	}
+	return &rows[i]
+}
+
+// refresh reloads the data for every pane.
+func (d *dashboard) refresh(ctx context.Context) {
+	var errs []string
+
+	if rows, err := d.loadContainers(ctx); err != nil {
+		errs = append(errs, "containers: "+err.Error())
+	} else {
+		d.rows[paneContainers] = rows
+	}
+
+	if rows, err := d.loadPods(ctx); err != nil {
+		errs = append(errs, "pods: "+err.Error())
+	} else {
+		d.rows[panePods] = rows
+	}
+
+	if rows, err := d.loadImages(ctx); err != nil {
+		errs = append(errs, "images: "+err.Error())
+	} else {
+		d.rows[paneImages] = rows
+	}
+
+	for p := range d.rows {
+		if d.selected[p] >= len(d.rows[p]) {
+			d.selected[p] = max(len(d.rows[p])-1, 0)
+		}
+	}
+
+	if len(errs) > 0 {
+		d.status = strings.Join(errs, "; ")
+	} else {
+		d.status = "Updated " + time.Now().Format("15:04:05")
+	}
+}
+
+func (d *dashboard) loadContainers(ctx context.Context) ([]tuiRow, error) {
+	var containers []models.ContainerSummary
+	if err := getJSON(ctx, d.httpClient, "/v3.0.0/containers/json", url.Values{"all": {"true"}}, &containers); err != nil {
+		return nil, err
+	}
+
+	// Stats are best effort: they are only available for running containers
+	var stats models.ContainerStatsReport
+	getJSON(ctx, d.httpClient, "/v3.0.0/libpod/containers/stats", url.Values{"stream": {"false"}}, &stats)
+
+	rows := make([]tuiRow, 0, len(containers))
+	for _, c := range containers {
+		cpu, mem := "-", "-"
+		for _, s := range stats.Stats {
+			if s.ContainerID == c.ID {
+				cpu = fmt.Sprintf("%.1f", s.CPU)
+				mem = formatSize(s.MemUsage)
+			}
+		}
+		names := make([]string, len(c.Names))
+		for i, n := range c.Names {
+			names[i] = strings.TrimPrefix(n, "/")
+		}
+		name := strings.Join(names, ",")
+		rows = append(rows, tuiRow{id: c.ID, cols: []string{name, c.Image, c.State, cpu, mem, c.Status}})
+	}
+	return rows, nil
+}
+
+func (d *dashboard) loadPods(ctx context.Context) ([]tuiRow, error) {
+	var pods []models.ListPodsReport
+	if err := getJSON(ctx, d.httpClient, "/v3.0.0/libpod/pods/json", nil, &pods); err != nil {
+		return nil, err
+	}
+
+	rows := make([]tuiRow, 0, len(pods))
+	for _, p := range pods {
+		rows = append(rows, tuiRow{id: p.ID, cols: []string{p.Name, shortID(p.ID), p.Status, fmt.Sprint(len(p.Containers))}})
+	}
+	return rows, nil
+}
+
+func (d *dashboard) loadImages(ctx context.Context) ([]tuiRow, error) {
+	var images []models.ImageSummary
+	if err := getJSON(ctx, d.httpClient, "/v3.0.0/images/json", nil, &images); err != nil {
+		return nil, err
+	}
+
+	rows := make([]tuiRow, 0, len(images))
+	for _, img := range images {
+		tag := "<none>"
+		if len(img.RepoTags) > 0 {
+			tag = img.RepoTags[0]
+		}
+		rows = append(rows, tuiRow{id: img.ID, cols: []string{tag, shortID(img.ID), formatSize(img.Size)}})
+	}
+	return rows, nil
+}
+
+// action starts or stops the selected container or pod.
+func (d *dashboard) action(ctx context.Context, verb string) {
+	row := d.current()
+	if row == nil {
+		return
+	}
+
+	var path string
+	switch d.pane {
+	case paneContainers:
+		path = "/v3.0.0/containers/" + row.id + "/" + verb
+	case panePods:
+		path = "/v3.0.0/libpod/pods/" + row.id + "/" + verb
+	default:
+		d.status = verb + " is not supported for images"
+		return
+	}
+
+	resp, err := apiRequest(ctx, d.httpClient, http.MethodPost, path, nil, nil)
+	if err != nil {
+		d.status = verb + " " + row.cols[0] + ": " + err.Error()
+		return
+	}
+	resp.Body.Close()
+
+	d.refresh(ctx)
+	d.status = verb + " " + row.cols[0] + ": ok"
+}
+
+// showLogs displays the recent logs of the selected container.
+func (d *dashboard) showLogs(ctx context.Context) {
+	row := d.current()
+	if row == nil || d.pane != paneContainers {
+		return
+	}
+
+	query := url.Values{"stdout": {"true"}, "stderr": {"true"}, "tail": {tuiLogTail}}
+	resp, err := apiRequest(ctx, d.httpClient, http.MethodGet, "/v3.0.0/containers/"+row.id+"/logs", query, nil)
+	if err != nil {
+		d.status = "logs " + row.cols[0] + ": " + err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if err := demuxStream(&buf, &buf, resp.Body); err != nil {
+		d.status = "logs " + row.cols[0] + ": " + err.Error()
+	}
+
+	d.showDetail("Logs: "+row.cols[0], buf.String())
+}
+
+// showInspect displays the inspect output of the selected item.
+func (d *dashboard) showInspect(ctx context.Context) {
+	row := d.current()
+	if row == nil {
+		return
+	}
+
+	paths := [paneCount]string{
+		paneContainers: "/v3.0.0/containers/" + row.id + "/json",
+		panePods:       "/v3.0.0/libpod/pods/" + row.id + "/json",
+		paneImages:     "/v3.0.0/images/" + row.id + "/json",
+	}
+
+	var data json.RawMessage
+	if err := getJSON(ctx, d.httpClient, paths[d.pane], nil, &data); err != nil {
+		d.status = "inspect " + row.cols[0] + ": " + err.Error()
+		return
+	}
+
+	var pretty bytes.Buffer
+	json.Indent(&pretty, data, "", "  ")
+	d.showDetail("Inspect: "+row.cols[0], pretty.String())
+}
+
+func (d *dashboard) showDetail(title, text string) {
+	d.detailTitle = title
+	d.detail = strings.Split(strings.TrimRight(text, "\n"), "\n")
+	d.detailOffset = 0
+}
+
+// render draws the dashboard to the terminal.
+func (d *dashboard) render() {
+	var b strings.Builder
+	b.WriteString(ansiClear)
+
+	lines := d.view()
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+	}
+	io.WriteString(d.out, b.String())
+}
+
+// view returns the lines making up the current screen.
+func (d *dashboard) view() []string {
+	header := ansiBold + "podman-cli " + d.rc.host + ansiReset + "  "
+	if d.detail != nil {
+		header += d.detailTitle
+	} else {
+		for i, title := range paneTitles {
+			if i == d.pane {
+				header += ansiReverse + " " + title + " " + ansiReset
+			} else {
+				header += " " + title + " "
+			}
+		}
+	}
+
+	lines := []string{header, ""}
+	body := max(d.height-4, 1)
+
+	if d.detail != nil {
+		end := min(d.detailOffset+body, len(d.detail))
+		for _, line := range d.detail[d.detailOffset:end] {
+			lines = append(lines, d.truncate(line))
+		}
+		for len(lines) < body+2 {
+			lines = append(lines, "")
+		}
+		return append(lines, "", tuiDetailHelp)
+	}
+
+	table := formatTable(paneHeaders[d.pane], d.rows[d.pane])
+	lines = append(lines, ansiBold+d.truncate(table[0])+ansiReset)
+
+	// Scroll so that the selected row stays visible
+	rows := table[1:]
+	sel := d.selected[d.pane]
+	start := 0
+	if sel >= body-1 {
+		start = sel - (body - 2)
+	}
+	for i := start; i < len(rows) && i < start+body-1; i++ {
+		line := d.truncate(rows[i])
+		if i == sel {
+			line = ansiReverse + line + ansiReset
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < body+2 {
+		lines = append(lines, "")
+	}
+
+	return append(lines, d.truncate(d.status), tuiFooterHelp)
+}
+
+// truncate shortens line to the terminal width.
+func (d *dashboard) truncate(line string) string {
+	if d.width > 0 && len(line) > d.width {
+		return line[:d.width]
+	}
+	return line
+}
+
+// formatTable aligns headers and rows into columns, returning one string
+// per line with the header first.
+func formatTable(headers []string, rows []tuiRow) []string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row.cols, "\t"))
+	}
+	w.Flush()
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// shortID returns the 12-character short form of a container, pod or image
+// ID, dropping any "sha256:" prefix.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// formatSize renders a byte count using binary units.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
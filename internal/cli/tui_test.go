@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := map[string]string{
+		"\x1b[A": "up",
+		"j":      "down",
+		"\x1b[C": "right",
+		"\t":     "tab",
+		"\x1b":   "esc",
+		"\r":     "enter",
+		"\x03":   "ctrl-c",
+		"s":      "s",
+	}
+
+	for input, want := range tests {
+		if got := parseKey(input); got != want {
+			t.Errorf("parseKey(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{512, "512B"},
+		{2048, "2.0KiB"},
+		{5 * 1024 * 1024, "5.0MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestShortID(t *testing.T) {
+	if got := shortID("sha256:0123456789abcdef0123"); got != "0123456789ab" {
+		t.Errorf("shortID() = %q, want %q", got, "0123456789ab")
+	}
+	if got := shortID("abc"); got != "abc" {
+		t.Errorf("shortID() = %q, want %q", got, "abc")
+	}
+}
+
+func TestDashboard_RefreshAndNavigate(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/containers/json":
+			w.Write([]byte(`[{"Id":"c1","Names":["/web"],"Image":"nginx","State":"running","Status":"Up"},
+				{"Id":"c2","Names":["/db"],"Image":"postgres","State":"exited","Status":"Exited (0)"}]`))
+		case "/v3.0.0/libpod/containers/stats":
+			w.Write([]byte(`{"Stats":[{"ContainerID":"c1","CPU":12.5,"MemUsage":1048576}]}`))
+		case "/v3.0.0/libpod/pods/json":
+			w.Write([]byte(`[]`))
+		case "/v3.0.0/images/json":
+			w.Write([]byte(`[{"Id":"sha256:aaaaaaaaaaaaaaaa","RepoTags":["nginx:latest"],"Size":2048}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	d := &dashboard{rc: &RemoteCLI{host: "testhost"}, httpClient: httpClient, height: 24}
+	d.refresh(context.Background())
+
+	if len(d.rows[paneContainers]) != 2 {
+		t.Fatalf("refresh() containers = %d, want 2", len(d.rows[paneContainers]))
+	}
+	if got := d.rows[paneContainers][0].cols; got[0] != "web" || got[3] != "12.5" || got[4] != "1.0MiB" {
+		t.Errorf("refresh() first container = %v, want web with stats", got)
+	}
+
+	d.handleKey(context.Background(), "down")
+	if d.current().id != "c2" {
+		t.Errorf("current() = %q after down, want c2", d.current().id)
+	}
+
+	d.handleKey(context.Background(), "tab")
+	d.handleKey(context.Background(), "tab")
+	if d.pane != paneImages {
+		t.Errorf("pane = %d after two tabs, want %d", d.pane, paneImages)
+	}
+
+	view := strings.Join(d.view(), "\n")
+	if !strings.Contains(view, "nginx:latest") {
+		t.Errorf("view() missing image row:\n%s", view)
+	}
+
+	if d.handleKey(context.Background(), "q") {
+		t.Error("handleKey(q) = true, want false")
+	}
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"syscall"
+)
+
+// version and commit identify the build of podman-cli. Release builds set
+// them with -ldflags "-X github.com/alexjch/podman-cli/internal/cli.version=v1.2.0
+// -X github.com/alexjch/podman-cli/internal/cli.commit=<sha>"; otherwise
+// they are taken from the build information go embeds.
+var (
+	version string
+	commit  string
+)
+
+// clientVersion describes the build of podman-cli.
+type clientVersion struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// serverVersion describes the Podman service of the remote host.
+type serverVersion struct {
+	Host       string `json:"host"`
+	Version    string `json:"version"`
+	APIVersion string `json:"apiVersion"`
+	GoVersion  string `json:"goVersion,omitempty"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+}
+
+// versionReport is the output of the version command.
+type versionReport struct {
+	Client clientVersion  `json:"client"`
+	Server *serverVersion `json:"server,omitempty"`
+}
+
+// newVersionCommand returns the "version" command, which prints the
+// version of podman-cli and, when -host is given, that of Podman on the
+// host, warning when its API is older than podman-cli supports.
+func newVersionCommand(fs *flag.FlagSet) runFunc {
+	return func(rc *RemoteCLI) int {
+		report := versionReport{Client: buildVersion()}
+
+		if rc.host != "" {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			sshClient, httpClient, err := rc.connect(ctx)
+			if err != nil {
+				slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+				return 1
+			}
+			defer sshClient.Close()
+
+			server, err := fetchServerVersion(ctx, httpClient)
+			if err != nil {
+				slog.Error("version", "host", rc.host, "err", err)
+				return 1
+			}
+			server.Host = rc.host
+			report.Server = &server
+			if compareVersions(server.APIVersion, minPodmanVersion) < 0 {
+				slog.Warn("The libpod API of the host is older than podman-cli supports; upgrade Podman on the host",
+					"host", rc.host, "apiVersion", server.APIVersion, "minimum", minPodmanVersion)
+			}
+		}
+
+		if err := writeVersion(os.Stdout, rc.opts.format, report); err != nil {
+			slog.Error("version", "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// buildVersion returns the version of podman-cli set at link time, falling
+// back to the module version and VCS revision recorded by go build.
+func buildVersion() clientVersion {
+	v := clientVersion{
+		Version:   version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		if v.Version == "" {
+			v.Version = "unknown"
+		}
+		return v
+	}
+	if v.Version == "" {
+		v.Version = info.Main.Version
+		if v.Version == "" || v.Version == "(devel)" {
+			v.Version = "devel"
+		}
+	}
+	if v.Commit == "" {
+		var modified bool
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				v.Commit = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if v.Commit != "" && modified {
+			v.Commit += "-dirty"
+		}
+	}
+	return v
+}
+
+// fetchServerVersion returns the version of the Podman service behind
+// httpClient. The API version is taken from the Libpod-Api-Version header,
+// which Podman releases without it in the body also send.
+func fetchServerVersion(ctx context.Context, httpClient *http.Client) (serverVersion, error) {
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/version", nil, nil)
+	if err != nil {
+		return serverVersion{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Version    string `json:"Version"`
+		APIVersion string `json:"ApiVersion"`
+		GoVersion  string `json:"GoVersion"`
+		Os         string `json:"Os"`
+		Arch       string `json:"Arch"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return serverVersion{}, fmt.Errorf("invalid version response: %w", err)
+	}
+	server := serverVersion{
+		Version:    body.Version,
+		APIVersion: resp.Header.Get("Libpod-Api-Version"),
+		GoVersion:  body.GoVersion,
+		OS:         body.Os,
+		Arch:       body.Arch,
+	}
+	if server.APIVersion == "" {
+		server.APIVersion = body.APIVersion
+	}
+	if server.APIVersion == "" {
+		server.APIVersion = body.Version
+	}
+	return server, nil
+}
+
+// writeVersion writes report to out in the given format.
+func writeVersion(out io.Writer, format string, report versionReport) error {
+	if format != formatText {
+		enc := json.NewEncoder(out)
+		if format == formatJSON {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(report)
+	}
+
+	c := report.Client
+	fmt.Fprintln(out, "Client:")
+	fmt.Fprintf(out, "  Version:     %s\n", c.Version)
+	if c.Commit != "" {
+		fmt.Fprintf(out, "  Commit:      %s\n", c.Commit)
+	}
+	fmt.Fprintf(out, "  Go version:  %s\n", c.GoVersion)
+	fmt.Fprintf(out, "  OS/Arch:     %s/%s\n", c.OS, c.Arch)
+	if s := report.Server; s != nil {
+		fmt.Fprintf(out, "\nServer (%s):\n", s.Host)
+		fmt.Fprintf(out, "  Version:     %s\n", s.Version)
+		fmt.Fprintf(out, "  API version: %s\n", s.APIVersion)
+		if s.GoVersion != "" {
+			fmt.Fprintf(out, "  Go version:  %s\n", s.GoVersion)
+		}
+		fmt.Fprintf(out, "  OS/Arch:     %s/%s\n", s.OS, s.Arch)
+	}
+	return nil
+}
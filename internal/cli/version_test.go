@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBuildVersion_LinkerFlags(t *testing.T) {
+	oldVersion, oldCommit := version, commit
+	t.Cleanup(func() { version, commit = oldVersion, oldCommit })
+	version, commit = "v1.2.0", "abc123"
+
+	got := buildVersion()
+	if got.Version != "v1.2.0" || got.Commit != "abc123" {
+		t.Errorf("buildVersion() = %+v, want the linker-set version and commit", got)
+	}
+	if got.GoVersion == "" || got.OS == "" || got.Arch == "" {
+		t.Errorf("buildVersion() = %+v, want the Go version and platform", got)
+	}
+}
+
+func TestBuildVersion_BuildInfo(t *testing.T) {
+	// Test binaries carry no module version
+	if got := buildVersion(); got.Version == "" {
+		t.Errorf("buildVersion() = %+v, want a fallback version", got)
+	}
+}
+
+func TestFetchServerVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		body    string
+		wantAPI string
+	}{
+		{"header", "4.9.3", `{"Version":"4.9.3","ApiVersion":"1.41","Os":"linux","Arch":"arm64"}`, "4.9.3"},
+		{"body only", "", `{"Version":"4.0.0","Os":"linux","Arch":"amd64"}`, "4.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v3.0.0/libpod/version" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				}
+				if tt.header != "" {
+					w.Header().Set("Libpod-Api-Version", tt.header)
+				}
+				w.Write([]byte(tt.body))
+			}))
+
+			server, err := fetchServerVersion(context.Background(), httpClient)
+			if err != nil {
+				t.Fatalf("fetchServerVersion() unexpected error = %v", err)
+			}
+			if server.APIVersion != tt.wantAPI || server.OS != "linux" {
+				t.Errorf("fetchServerVersion() = %+v, want API version %s", server, tt.wantAPI)
+			}
+		})
+	}
+}
+
+func TestWriteVersion(t *testing.T) {
+	report := versionReport{
+		Client: clientVersion{Version: "v1.2.0", Commit: "abc123", GoVersion: "go1.24.0", OS: "linux", Arch: "amd64"},
+		Server: &serverVersion{Host: "edge1", Version: "4.9.3", APIVersion: "4.9.3", OS: "linux", Arch: "arm64"},
+	}
+
+	var out bytes.Buffer
+	if err := writeVersion(&out, formatText, report); err != nil {
+		t.Fatalf("writeVersion() unexpected error = %v", err)
+	}
+	for _, want := range []string{"Version:     v1.2.0", "Commit:      abc123", "Server (edge1):", "API version: 4.9.3", "OS/Arch:     linux/arm64"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("writeVersion() text output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	report.Server = nil
+	if err := writeVersion(&out, formatJSON, report); err != nil {
+		t.Fatalf("writeVersion() unexpected error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("writeVersion() JSON output invalid: %v", err)
+	}
+	if _, ok := got["server"]; ok {
+		t.Errorf("writeVersion() JSON output = %s, want no server without -host", out.String())
+	}
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// volumeHelperImage is the default image of the helper container that
+// runs tar on hosts whose Podman has no volume export and import
+// endpoints, or with -tar. It must provide tar and sleep.
+const volumeHelperImage = "docker.io/library/busybox:latest"
+
+// volumeHelperMount is where the helper container mounts the volume.
+const volumeHelperMount = "/volume"
+
+// volumeArchiveVersion is the first Podman release serving the volume
+// export and import endpoints.
+const volumeArchiveVersion = "5.0.0"
+
+// newVolumeExportCommand returns the "volume_export" command, which streams
+// the content of a volume on the remote host as a tarball to a local file
+// or stdout.
+func newVolumeExportCommand(fs *flag.FlagSet) runFunc {
+	var output, helperImage string
+	var useTar bool
+	fs.StringVar(&output, "o", "", "Write the archive to this file instead of stdout")
+	fs.BoolVar(&useTar, "tar", false, "Run tar in a helper container even if the host's Podman can export volumes itself")
+	fs.StringVar(&helperImage, "helper-image", volumeHelperImage, "Image of the helper container running tar")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("volume_export: exactly one volume name is required")
+			return 1
+		}
+		name := rc.args[0]
+
+		var out io.Writer = os.Stdout
+		if output == "" {
+			if term.IsTerminal(int(os.Stdout.Fd())) {
+				slog.Error("volume_export: refusing to write an archive to a terminal (use -o)")
+				return 1
+			}
+		} else {
+			f, err := os.Create(output)
+			if err != nil {
+				slog.Error("volume_export", "err", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		pw := newProgressWriter(out, "Exporting "+name, 0)
+		err = exportVolume(ctx, httpClient, name, helperImage, useTar, pw)
+		pw.Close()
+		if err != nil {
+			slog.Error("volume_export", "target", name, "err", err)
+			if output != "" {
+				os.Remove(output)
+			}
+			return 1
+		}
+		return 0
+	}
+}
+
+// newVolumeImportCommand returns the "volume_import" command, which
+// extracts a local tarball, or stdin, into an existing volume on the
+// remote host.
+func newVolumeImportCommand(fs *flag.FlagSet) runFunc {
+	var helperImage string
+	var useTar bool
+	fs.BoolVar(&useTar, "tar", false, "Run tar in a helper container even if the host's Podman can import volumes itself")
+	fs.StringVar(&helperImage, "helper-image", volumeHelperImage, "Image of the helper container running tar")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) < 1 || len(rc.args) > 2 {
+			slog.Error("volume_import: usage: volume_import [flags] <volume> [<file>]")
+			return 1
+		}
+		name := rc.args[0]
+
+		var body io.Reader = os.Stdin
+		var size int64
+		if len(rc.args) == 2 {
+			f, err := os.Open(rc.args[1])
+			if err != nil {
+				slog.Error("volume_import", "err", err)
+				return 1
+			}
+			defer f.Close()
+
+			if info, err := f.Stat(); err == nil {
+				size = info.Size()
+			}
+			body = f
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		if err := importVolume(ctx, httpClient, name, helperImage, useTar, newProgressReader(body, "Importing "+name, size)); err != nil {
+			slog.Error("volume_import", "target", name, "err", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// exportVolume writes the content of the named volume to out as a tarball,
+// with the libpod export endpoint or, on older hosts or if useTar is set,
+// with tar run in a helper container from helperImage.
+func exportVolume(ctx context.Context, httpClient *http.Client, name, helperImage string, useTar bool, out io.Writer) error {
+	native, err := checkVolume(ctx, httpClient, name)
+	if err != nil {
+		return err
+	}
+	if useTar || !native {
+		return runVolumeTar(ctx, httpClient, name, helperImage, []string{"tar", "-C", volumeHelperMount, "-cf", "-", "."}, nil, out)
+	}
+
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/volumes/"+url.PathEscape(name)+"/export", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// importVolume extracts the tarball read from body into the named volume,
+// choosing between the libpod import endpoint and tar as exportVolume
+// does.
+func importVolume(ctx context.Context, httpClient *http.Client, name, helperImage string, useTar bool, body io.Reader) error {
+	native, err := checkVolume(ctx, httpClient, name)
+	if err != nil {
+		return err
+	}
+	if useTar || !native {
+		return runVolumeTar(ctx, httpClient, name, helperImage, []string{"tar", "-C", volumeHelperMount, "-xf", "-"}, body, nil)
+	}
+
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/volumes/"+url.PathEscape(name)+"/import", nil, body)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// checkVolume returns an error if the named volume does not exist, and
+// otherwise whether the host's Podman serves the volume export and import
+// endpoints.
+func checkVolume(ctx context.Context, httpClient *http.Client, name string) (bool, error) {
+	resp, err := apiRequest(ctx, httpClient, http.MethodGet, "/v3.0.0/libpod/volumes/"+url.PathEscape(name)+"/exists", nil, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return false, fmt.Errorf("no such volume %q", name)
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	api := resp.Header.Get("Libpod-Api-Version")
+	native := api != "" && compareVersions(api, volumeArchiveVersion) >= 0
+	if !native {
+		slog.Debug("No volume export and import endpoints, using tar", "volume", name, "api", api)
+	}
+	return native, nil
+}
+
+// runVolumeTar runs cmd in a helper container from image with the named
+// volume mounted at volumeHelperMount, streaming stdin to it and its
+// output to stdout. The image is pulled if missing and the container is
+// removed afterwards.
+func runVolumeTar(ctx context.Context, httpClient *http.Client, volume, image string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(image)+"/exists")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := pullImage(ctx, httpClient, image, true, "", io.Discard, nil); err != nil {
+			return fmt.Errorf("pull helper image %s: %w", image, err)
+		}
+	}
+
+	id, err := createContainer(ctx, httpClient, containerSpec{
+		Image:   image,
+		Command: []string{"sleep", "infinity"},
+		Labels:  map[string]string{"podman-cli.helper": "volume"},
+		Volumes: []namedVolume{{Name: volume, Dest: volumeHelperMount}},
+	})
+	if err != nil {
+		return fmt.Errorf("create helper container: %w", err)
+	}
+	defer func() {
+		if err := removeContainer(context.WithoutCancel(ctx), httpClient, id, removeOptions{force: true}); err != nil {
+			slog.Warn("Failed to remove the helper container", "id", shortID(id), "err", err)
+		}
+	}()
+	if err := containerAction(ctx, httpClient, id, "start"); err != nil {
+		return fmt.Errorf("start helper container: %w", err)
+	}
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	var stderr bytes.Buffer
+	config := execConfig{Cmd: cmd, AttachStdin: stdin != nil, AttachStdout: true, AttachStderr: true}
+	code, err := runExec(ctx, httpClient, id, config, nil, stdin, stdout, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("%s: exit code %d", cmd[0], code)
+	}
+	return nil
+}
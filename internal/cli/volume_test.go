@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportVolume(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Libpod-Api-Version", "5.2.0")
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/volumes/pgdata/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/volumes/pgdata/export":
+			w.Write([]byte("tarball"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	var out bytes.Buffer
+	if err := exportVolume(context.Background(), httpClient, "pgdata", volumeHelperImage, false, &out); err != nil {
+		t.Fatalf("exportVolume() unexpected error = %v", err)
+	}
+	if out.String() != "tarball" {
+		t.Errorf("exportVolume() wrote %q, want the exported archive", out.String())
+	}
+}
+
+func TestImportVolume(t *testing.T) {
+	var got string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Libpod-Api-Version", "5.0.0")
+		switch {
+		case r.URL.Path == "/v3.0.0/libpod/volumes/pgdata/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && r.URL.Path == "/v3.0.0/libpod/volumes/pgdata/import":
+			data, _ := io.ReadAll(r.Body)
+			got = string(data)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	if err := importVolume(context.Background(), httpClient, "pgdata", volumeHelperImage, false, strings.NewReader("tarball")); err != nil {
+		t.Fatalf("importVolume() unexpected error = %v", err)
+	}
+	if got != "tarball" {
+		t.Errorf("import request body = %q, want the archive", got)
+	}
+}
+
+func TestExportVolume_NotFound(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no such volume"}`))
+	}))
+
+	err := exportVolume(context.Background(), httpClient, "missing", volumeHelperImage, false, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), `no such volume "missing"`) {
+		t.Errorf("exportVolume() error = %v, want no such volume", err)
+	}
+}
+
+func TestExportVolume_Tar(t *testing.T) {
+	var spec containerSpec
+	var exec execConfig
+	var removed bool
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Podman 4 has no volume export endpoint
+		w.Header().Set("Libpod-Api-Version", "4.9.3")
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/volumes/pgdata/exists", "/v3.0.0/libpod/images/" + volumeHelperImage + "/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/containers/create":
+			json.NewDecoder(r.Body).Decode(&spec)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"helper1"}`))
+		case "/v3.0.0/libpod/containers/helper1/start":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/containers/helper1/exec":
+			json.NewDecoder(r.Body).Decode(&exec)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"exec1"}`))
+		case "/v3.0.0/libpod/exec/exec1/start":
+			serveSession(t, w, r, func(conn net.Conn, in *bufio.Reader) {
+				conn.Write(frame(1, "tarball"))
+			})
+		case "/v3.0.0/libpod/exec/exec1/json":
+			w.Write([]byte(`{"Running":false,"ExitCode":0}`))
+		case "/v3.0.0/libpod/containers/helper1":
+			removed = r.Method == http.MethodDelete && r.URL.Query().Get("force") == "true"
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	var out bytes.Buffer
+	if err := exportVolume(context.Background(), httpClient, "pgdata", volumeHelperImage, false, &out); err != nil {
+		t.Fatalf("exportVolume() unexpected error = %v", err)
+	}
+	if out.String() != "tarball" {
+		t.Errorf("exportVolume() wrote %q, want the output of tar", out.String())
+	}
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Name != "pgdata" || spec.Volumes[0].Dest != volumeHelperMount {
+		t.Errorf("helper container created with %+v, want pgdata mounted", spec)
+	}
+	if strings.Join(exec.Cmd, " ") != "tar -C /volume -cf - ." || exec.AttachStdin {
+		t.Errorf("exec created with %+v, want tar writing to stdout", exec)
+	}
+	if !removed {
+		t.Error("helper container was not removed")
+	}
+}
+
+func TestImportVolume_TarFailure(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/volumes/pgdata/exists", "/v3.0.0/libpod/images/busybox/exists":
+			w.Header().Set("Libpod-Api-Version", "5.2.0")
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/containers/create":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"helper1"}`))
+		case "/v3.0.0/libpod/containers/helper1/start", "/v3.0.0/libpod/containers/helper1":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/containers/helper1/exec":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"exec1"}`))
+		case "/v3.0.0/libpod/exec/exec1/start":
+			serveSession(t, w, r, func(conn net.Conn, in *bufio.Reader) {
+				io.ReadAll(in)
+				conn.Write(frame(2, "tar: invalid tar magic\n"))
+			})
+		case "/v3.0.0/libpod/exec/exec1/json":
+			w.Write([]byte(`{"Running":false,"ExitCode":1}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	// -tar runs tar even on hosts serving the import endpoint
+	err := importVolume(context.Background(), httpClient, "pgdata", "busybox", true, strings.NewReader("garbage"))
+	if err == nil || !strings.Contains(err.Error(), "tar: invalid tar magic") {
+		t.Errorf("importVolume() error = %v, want the error of tar", err)
+	}
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// waitUnknownExitCode is the exit code of wait when Podman does not know
+// the exit code of the container, as podman does for its own failures, so
+// that CI pipelines do not mistake it for success.
+const waitUnknownExitCode = 125
+
+// waitConditions are the container states accepted by -condition.
+var waitConditions = map[string]bool{
+	"running": true,
+	"stopped": true,
+	"exited":  true,
+}
+
+// newWaitCommand returns the "wait" command, which blocks until a container
+// reaches the given condition and exits with the container's exit code so
+// that CI pipelines can block on remote container completion.
+func newWaitCommand(fs *flag.FlagSet) runFunc {
+	var condition string
+	fs.StringVar(&condition, "condition", "stopped", "Condition to wait for: running, stopped or exited")
+
+	return func(rc *RemoteCLI) int {
+		if len(rc.args) != 1 {
+			slog.Error("wait: exactly one container name or ID is required")
+			return 1
+		}
+		if !waitConditions[condition] {
+			slog.Error("wait: invalid condition (use running, stopped or exited)", "condition", condition)
+			return 1
+		}
+		name := rc.args[0]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		sshClient, httpClient, err := rc.connect(ctx)
+		if err != nil {
+			slog.Error("Failed while connecting to client", "host", rc.host, "err", err)
+			return 1
+		}
+		defer sshClient.Close()
+
+		exitCode, err := waitContainer(ctx, httpClient, name, condition)
+		if err != nil {
+			slog.Error("wait", "target", name, "err", err)
+			return 1
+		}
+
+		fmt.Println(exitCode)
+		return waitExitCode(exitCode, condition)
+	}
+}
+
+// waitExitCode returns the exit code of wait for the exit code Podman
+// reported once the container reached condition.
+func waitExitCode(exitCode int, condition string) int {
+	switch {
+	case condition == "running":
+		// The exit code is only meaningful once the container has stopped
+		return 0
+	case exitCode < 0:
+		return waitUnknownExitCode
+	}
+	return exitCode
+}
+
+// waitContainer blocks until the named container reaches condition and
+// returns the exit code reported by Podman.
+func waitContainer(ctx context.Context, httpClient *http.Client, name, condition string) (int, error) {
+	query := url.Values{"condition": {condition}}
+	resp, err := apiRequest(ctx, httpClient, http.MethodPost, "/v3.0.0/libpod/containers/"+url.PathEscape(name)+"/wait", query, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var exitCode int
+	if err := json.NewDecoder(resp.Body).Decode(&exitCode); err != nil {
+		return 0, fmt.Errorf("decode exit code: %w", err)
+	}
+	return exitCode, nil
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestWaitContainer(t *testing.T) {
+	var gotPath, gotCondition, gotMethod string
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotMethod = r.Method
+		gotCondition = r.URL.Query().Get("condition")
+		w.Write([]byte("137\n"))
+	}))
+
+	exitCode, err := waitContainer(context.Background(), httpClient, "web", "exited")
+	if err != nil {
+		t.Fatalf("waitContainer() unexpected error = %v", err)
+	}
+
+	if exitCode != 137 {
+		t.Errorf("waitContainer() exit code = %d, want 137", exitCode)
+	}
+	if gotMethod != http.MethodPost || gotPath != "/v3.0.0/libpod/containers/web/wait" {
+		t.Errorf("waitContainer() request = %s %s, want POST /v3.0.0/libpod/containers/web/wait", gotMethod, gotPath)
+	}
+	if gotCondition != "exited" {
+		t.Errorf("waitContainer() condition = %q, want %q", gotCondition, "exited")
+	}
+}
+
+func TestWaitExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode  int
+		condition string
+		want      int
+	}{
+		{0, "stopped", 0},
+		{137, "exited", 137},
+		{-1, "stopped", waitUnknownExitCode},
+		{-1, "exited", waitUnknownExitCode},
+		{-1, "running", 0},
+		{3, "running", 0},
+	}
+	for _, tt := range tests {
+		if got := waitExitCode(tt.exitCode, tt.condition); got != tt.want {
+			t.Errorf("waitExitCode(%d, %q) = %d, want %d", tt.exitCode, tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestWaitContainer_NotFound(t *testing.T) {
+	httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+	}))
+
+	if _, err := waitContainer(context.Background(), httpClient, "missing", "stopped"); err == nil {
+		t.Error("waitContainer() expected error for missing container, got nil")
+	}
+}
+
+func TestNewRemoteCLI_WaitArgs(t *testing.T) {
+	tmpDir := t.TempDir()
+	setupTestSSHConfig(t, tmpDir)
+
+	setHomeDir(t, tmpDir)
+
+	args := []string{"-host", "testhost", "wait", "-condition", "exited", "web"}
+	cli, err := NewRemoteCLI(args)
+	if err != nil {
+		t.Fatalf("NewRemoteCLI() unexpected error = %v", err)
+	}
+
+	if len(cli.args) != 1 || cli.args[0] != "web" {
+		t.Errorf("NewRemoteCLI() args = %v, want [web]", cli.args)
+	}
+}
+
+func TestWaitCommand_InvalidCondition(t *testing.T) {
+	rc := &RemoteCLI{args: []string{"web"}}
+	run := newTestCommand(t, newWaitCommand, "-condition", "paused")
+
+	if code := run(rc); code != 1 {
+		t.Errorf("wait with invalid condition exit code = %d, want 1", code)
+	}
+}
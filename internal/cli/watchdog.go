@@ -0,0 +1,350 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+	"github.com/alexjch/podman-cli/internal/client"
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+// newWatchdogCommand returns the "watchdog" command, which monitors a
+// container on a primary host and, once it has failed a number of
+// consecutive checks, recreates it from the last spec seen on a standby
+// host, as a failover primitive for pairs of edge hosts.
+func newWatchdogCommand(fs *flag.FlagSet) runFunc {
+	var from, to, name, authFile string
+	var interval time.Duration
+	var failures int
+	var volumes bool
+	fs.StringVar(&from, "from", "", "Primary host running the container")
+	fs.StringVar(&to, "to", "", "Standby host to recreate the container on")
+	fs.StringVar(&name, "container", "", "Name of the container to monitor")
+	fs.DurationVar(&interval, "interval", 10*time.Second, "Time between checks")
+	fs.IntVar(&failures, "failures", 3, "Consecutive failed checks that trigger the failover")
+	fs.BoolVar(&volumes, "volumes", false, "Recreate the volume and bind mounts of the container; they are not copied")
+	fs.StringVar(&authFile, "authfile", auth.DefaultFilePath(), "Path of the registry auth file")
+
+	return func(rc *RemoteCLI) int {
+		if from == "" || to == "" || name == "" || len(rc.args) != 0 {
+			slog.Error("watchdog: usage: watchdog -from <host> -to <host> -container <name>")
+			return 1
+		}
+		if from == to {
+			slog.Error("watchdog: -from and -to must be different hosts")
+			return 1
+		}
+		if interval <= 0 || failures < 1 {
+			slog.Error("watchdog: -interval must be positive and -failures at least 1")
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		primary, err := newWatchdogHost(rc, from)
+		if err != nil {
+			slog.Error("watchdog", "err", err)
+			return 1
+		}
+		defer primary.reset()
+		standby, err := newWatchdogHost(rc, to)
+		if err != nil {
+			slog.Error("watchdog", "err", err)
+			return 1
+		}
+		defer standby.reset()
+
+		w := &watchdog{name: name, primary: primary, standby: standby, volumes: volumes, out: os.Stdout}
+		// The spec must be known before the primary fails, as it may not
+		// be reachable then
+		if _, err := w.check(ctx, interval); err != nil {
+			slog.Error("watchdog: the container must be reachable when starting", "host", from, "target", name, "err", err)
+			return 1
+		}
+		if authHeader, err := registryAuth(authFile, w.spec.Image); err != nil {
+			slog.Warn("watchdog", "err", err)
+		} else if err := w.prepareStandby(ctx, authHeader); err != nil {
+			slog.Warn("watchdog: could not pull the image on the standby host, failover will need it", "host", to, "image", w.spec.Image, "err", err)
+		}
+		w.report("watching %s on %s, failing over to %s after %d failed checks", name, from, to, failures)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failed := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return 0
+			case <-ticker.C:
+			}
+			healthy, err := w.check(ctx, interval)
+			if ctx.Err() != nil {
+				return 0
+			}
+			if healthy {
+				if failed > 0 {
+					w.report("%s recovered on %s", name, from)
+				}
+				failed = 0
+				continue
+			}
+			failed++
+			slog.Warn("Check failed", "host", from, "target", name, "failures", failed, "err", err)
+			if failed < failures {
+				continue
+			}
+
+			w.report("%s failed %d consecutive checks on %s: failing over to %s", name, failed, from, to)
+			if err := w.failover(ctx); err != nil {
+				slog.Error("watchdog: failover failed", "host", to, "target", name, "err", err)
+				return 1
+			}
+			return 0
+		}
+	}
+}
+
+// watchdogHost is a host of the watchdog, connected to when needed and
+// reconnected to after a failure.
+type watchdogHost struct {
+	name       string
+	rc         *RemoteCLI
+	httpClient *http.Client
+	lease      *client.Lease
+}
+
+func newWatchdogHost(rc *RemoteCLI, host string) (*watchdogHost, error) {
+	hostRC, err := rc.forHost(host)
+	if err != nil {
+		return nil, err
+	}
+	return &watchdogHost{name: host, rc: hostRC}, nil
+}
+
+// client returns the API client of the host, connecting to it if needed.
+func (h *watchdogHost) client(ctx context.Context) (*http.Client, error) {
+	if h.httpClient != nil {
+		return h.httpClient, nil
+	}
+	sshClient, httpClient, err := h.rc.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.httpClient, h.lease = httpClient, sshClient
+	return httpClient, nil
+}
+
+// reset closes the connection, dropping it from the connection pool as it
+// may be stuck, so that the next request reconnects.
+func (h *watchdogHost) reset() {
+	if h.lease != nil {
+		h.lease.Discard()
+	}
+	h.httpClient, h.lease = nil, nil
+}
+
+// watchdog holds the state of the watchdog command.
+type watchdog struct {
+	name             string
+	primary, standby *watchdogHost
+	volumes          bool
+	out              io.Writer
+
+	// spec is the spec of the container, as last seen on the primary
+	spec containerSpec
+}
+
+// report writes an action of the watchdog to its output.
+func (w *watchdog) report(format string, args ...any) {
+	fmt.Fprintf(w.out, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// check inspects the container on the primary host within timeout,
+// updating the spec, and reports whether it is running and not unhealthy.
+func (w *watchdog) check(ctx context.Context, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpClient, err := w.primary.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	var ctr models.InspectContainerData
+	if err := getJSON(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(w.name)+"/json", nil, &ctr); err != nil {
+		// The connection may be the cause, and is cheap to open again
+		if !isStatus(err, http.StatusNotFound) {
+			w.primary.reset()
+		}
+		return false, err
+	}
+	spec, err := specFromInspect(ctr, w.volumes)
+	if err != nil {
+		return false, err
+	}
+	w.spec = spec
+
+	if !ctr.State.Running {
+		return false, fmt.Errorf("container is %s", ctr.State.Status)
+	}
+	if h := ctr.State.Health; h != nil && h.Status == models.HealthUnhealthy {
+		return false, errors.New("container is unhealthy")
+	}
+	return true, nil
+}
+
+// prepareStandby pulls the image of the container on the standby host,
+// unless already there, so that failing over does not wait for it.
+func (w *watchdog) prepareStandby(ctx context.Context, authHeader string) error {
+	httpClient, err := w.standby.client(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/images/"+url.PathEscape(w.spec.Image)+"/exists")
+	if err != nil || exists {
+		return err
+	}
+	return pullImage(ctx, httpClient, w.spec.Image, true, authHeader, io.Discard, nil)
+}
+
+// failover stops the container on the primary host, if it can still be
+// reached, so that both hosts do not serve at once, then replaces any
+// container of the same name on the standby host with one created from
+// the spec and starts it, reporting each action.
+func (w *watchdog) failover(ctx context.Context) error {
+	stopCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	if httpClient, err := w.primary.client(stopCtx); err == nil {
+		if err := containerAction(stopCtx, httpClient, w.name, "stop"); err == nil {
+			w.report("stopped %s on %s", w.name, w.primary.name)
+		}
+	}
+	cancel()
+
+	httpClient, err := w.standby.client(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := resourceExists(ctx, httpClient, "/v3.0.0/libpod/containers/"+url.PathEscape(w.name)+"/exists")
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := removeContainer(ctx, httpClient, w.name, removeOptions{force: true}); err != nil {
+			return fmt.Errorf("remove the previous container: %w", err)
+		}
+		w.report("removed the previous %s on %s", w.name, w.standby.name)
+	}
+	id, err := createContainer(ctx, httpClient, w.spec)
+	if err != nil {
+		return err
+	}
+	w.report("created %s on %s from %s", w.name, w.standby.name, w.spec.Image)
+	if err := containerAction(ctx, httpClient, id, "start"); err != nil {
+		return err
+	}
+	w.report("started %s on %s", w.name, w.standby.name)
+	return nil
+}
+
+// specFromInspect returns the spec recreating the inspected container ctr:
+// its image, command, environment, labels, restart policy, published
+// ports and networks, and with volumes, its volume and bind mounts. The
+// contents of volumes are not part of the spec.
+func specFromInspect(ctr models.InspectContainerData, volumes bool) (containerSpec, error) {
+	if ctr.Pod != "" {
+		return containerSpec{}, errors.New("containers of pods cannot be recreated")
+	}
+	image := ctr.ImageName
+	if image == "" {
+		image = ctr.Config.Image
+	}
+	s := containerSpec{
+		Name:       strings.TrimPrefix(ctr.Name, "/"),
+		Image:      image,
+		Command:    ctr.Config.Cmd,
+		Entrypoint: ctr.Config.Entrypoint,
+		WorkDir:    ctr.Config.WorkingDir,
+		User:       ctr.Config.User,
+		Labels:     ctr.Config.Labels,
+	}
+	for _, kv := range ctr.Config.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		if s.Env == nil {
+			s.Env = map[string]string{}
+		}
+		s.Env[key] = value
+	}
+
+	if policy := ctr.HostConfig.RestartPolicy; policy.Name != "" && policy.Name != "no" {
+		s.RestartPolicy = policy.Name
+		if policy.Name == "on-failure" && policy.MaximumRetryCount > 0 {
+			tries := policy.MaximumRetryCount
+			s.RestartTries = &tries
+		}
+	}
+
+	for _, port := range sortedKeys(ctr.HostConfig.PortBindings) {
+		number, protocol, _ := strings.Cut(port, "/")
+		containerPort, err := strconv.ParseUint(number, 10, 16)
+		if err != nil {
+			return s, fmt.Errorf("invalid port %q", port)
+		}
+		for _, binding := range ctr.HostConfig.PortBindings[port] {
+			m := portMapping{HostIP: binding.HostIP, ContainerPort: uint16(containerPort), Protocol: protocol}
+			if binding.HostPort != "" {
+				hostPort, err := strconv.ParseUint(binding.HostPort, 10, 16)
+				if err != nil {
+					return s, fmt.Errorf("invalid host port %q", binding.HostPort)
+				}
+				m.HostPort = uint16(hostPort)
+			}
+			s.PortMappings = append(s.PortMappings, m)
+		}
+	}
+
+	switch mode := ctr.HostConfig.NetworkMode; {
+	case mode == "bridge" || mode == "default" || mode == "":
+		for _, network := range sortedKeys(ctr.NetworkSettings.Networks) {
+			if network == "podman" {
+				continue
+			}
+			if s.Networks == nil {
+				s.Networks = map[string]networkOptions{}
+			}
+			s.Networks[network] = networkOptions{}
+		}
+	case networkModes[mode]:
+		s.NetNS = &specNamespace{NSMode: mode}
+	default:
+		return s, fmt.Errorf("network mode %s cannot be recreated on another host", mode)
+	}
+
+	if volumes {
+		for _, m := range ctr.Mounts {
+			options := append([]string(nil), m.Options...)
+			if !m.RW {
+				options = append(options, "ro")
+			}
+			switch m.Type {
+			case "volume":
+				s.Volumes = append(s.Volumes, namedVolume{Name: m.Name, Dest: m.Destination, Options: options})
+			case "bind":
+				s.Mounts = append(s.Mounts, specMount{Destination: m.Destination, Type: "bind", Source: m.Source, Options: options})
+			}
+		}
+	}
+	return s, nil
+}
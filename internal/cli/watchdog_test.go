@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/models"
+)
+
+func TestSpecFromInspect(t *testing.T) {
+	ctr := models.InspectContainerData{
+		Name:      "web",
+		ImageName: "docker.io/library/nginx:1.27",
+		Config: models.InspectContainerConfig{
+			Env:    []string{"MODE=edge", "EMPTY="},
+			Cmd:    []string{"nginx", "-g", "daemon off;"},
+			Labels: map[string]string{"app": "web"},
+		},
+		HostConfig: models.InspectContainerHostConfig{
+			NetworkMode:   "bridge",
+			PortBindings:  map[string][]models.InspectHostPort{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "8080"}}},
+			RestartPolicy: models.InspectRestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+		},
+		NetworkSettings: models.InspectNetworkSettings{
+			Networks: map[string]models.InspectAdditionalNetwork{"podman": {}, "backend": {}},
+		},
+		Mounts: []models.InspectMount{
+			{Type: "volume", Name: "html", Destination: "/usr/share/nginx/html", RW: true},
+			{Type: "bind", Source: "/etc/web", Destination: "/etc/nginx/conf.d", Options: []string{"rbind"}},
+		},
+	}
+
+	got, err := specFromInspect(ctr, false)
+	if err != nil {
+		t.Fatalf("specFromInspect() unexpected error = %v", err)
+	}
+	tries := uint(3)
+	want := containerSpec{
+		Name:          "web",
+		Image:         "docker.io/library/nginx:1.27",
+		Command:       []string{"nginx", "-g", "daemon off;"},
+		Labels:        map[string]string{"app": "web"},
+		Env:           map[string]string{"MODE": "edge", "EMPTY": ""},
+		RestartPolicy: "on-failure",
+		RestartTries:  &tries,
+		PortMappings:  []portMapping{{HostIP: "0.0.0.0", ContainerPort: 80, HostPort: 8080, Protocol: "tcp"}},
+		Networks:      map[string]networkOptions{"backend": {}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("specFromInspect() = %+v, want %+v", got, want)
+	}
+
+	got, err = specFromInspect(ctr, true)
+	if err != nil {
+		t.Fatalf("specFromInspect() with volumes unexpected error = %v", err)
+	}
+	wantVolumes := []namedVolume{{Name: "html", Dest: "/usr/share/nginx/html"}}
+	wantMounts := []specMount{{Destination: "/etc/nginx/conf.d", Type: "bind", Source: "/etc/web", Options: []string{"rbind", "ro"}}}
+	if !reflect.DeepEqual(got.Volumes, wantVolumes) || !reflect.DeepEqual(got.Mounts, wantMounts) {
+		t.Errorf("specFromInspect() with volumes = %+v %+v, want %+v %+v", got.Volumes, got.Mounts, wantVolumes, wantMounts)
+	}
+	if !reflect.DeepEqual(ctr.Mounts[1].Options, []string{"rbind"}) {
+		t.Errorf("specFromInspect() changed the inspected options to %v", ctr.Mounts[1].Options)
+	}
+
+	ctr.HostConfig.NetworkMode = "host"
+	if got, err := specFromInspect(ctr, false); err != nil || got.NetNS == nil || got.NetNS.NSMode != "host" || got.Networks != nil {
+		t.Errorf("specFromInspect() with host network = %+v, %v, want the host namespace", got.NetNS, err)
+	}
+	ctr.HostConfig.NetworkMode = "container:db"
+	if _, err := specFromInspect(ctr, false); err == nil {
+		t.Error("specFromInspect() expected error for the network of another container, got nil")
+	}
+	ctr.Pod = "shop"
+	if _, err := specFromInspect(ctr, false); err == nil {
+		t.Error("specFromInspect() expected error for a container of a pod, got nil")
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       models.InspectContainerState
+		status      int
+		wantHealthy bool
+	}{
+		{name: "running", state: models.InspectContainerState{Status: "running", Running: true}, wantHealthy: true},
+		{name: "starting health", state: models.InspectContainerState{Status: "running", Running: true, Health: &models.HealthCheckResults{Status: "starting"}}, wantHealthy: true},
+		{name: "unhealthy", state: models.InspectContainerState{Status: "running", Running: true, Health: &models.HealthCheckResults{Status: models.HealthUnhealthy}}},
+		{name: "exited", state: models.InspectContainerState{Status: "exited"}},
+		{name: "missing", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v3.0.0/libpod/containers/web/json" {
+					t.Errorf("request path = %s, want the web inspect", r.URL.Path)
+				}
+				if tt.status != 0 {
+					http.Error(w, `{"message":"no such container"}`, tt.status)
+					return
+				}
+				json.NewEncoder(w).Encode(models.InspectContainerData{Name: "web", ImageName: "nginx", State: tt.state})
+			}))
+			w := &watchdog{name: "web", primary: &watchdogHost{name: "edge1", httpClient: httpClient}}
+
+			healthy, err := w.check(context.Background(), 5*time.Second)
+			if healthy != tt.wantHealthy || (err == nil) != tt.wantHealthy {
+				t.Errorf("check() = %v, %v, want healthy %v", healthy, err, tt.wantHealthy)
+			}
+			if tt.status == 0 && w.spec.Image != "nginx" {
+				t.Errorf("check() spec image = %q, want the inspected one", w.spec.Image)
+			}
+		})
+	}
+}
+
+func TestWatchdogFailover(t *testing.T) {
+	primary := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "host down", http.StatusInternalServerError)
+	}))
+	var requests []string
+	var created containerSpec
+	standby := newTestHTTPClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/v3.0.0/libpod/containers/web/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v3.0.0/libpod/containers/create":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"abc123"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	var out bytes.Buffer
+	w := &watchdog{
+		name:    "web",
+		primary: &watchdogHost{name: "edge1", httpClient: primary},
+		standby: &watchdogHost{name: "edge2", httpClient: standby},
+		out:     &out,
+		spec:    containerSpec{Name: "web", Image: "nginx"},
+	}
+
+	if err := w.failover(context.Background()); err != nil {
+		t.Fatalf("failover() unexpected error = %v", err)
+	}
+	want := []string{
+		"GET /v3.0.0/libpod/containers/web/exists",
+		"DELETE /v3.0.0/libpod/containers/web",
+		"POST /v3.0.0/libpod/containers/create",
+		"POST /v3.0.0/libpod/containers/abc123/start",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("failover() requests = %q, want %q", requests, want)
+	}
+	if created.Name != "web" || created.Image != "nginx" {
+		t.Errorf("failover() created %+v, want the watched spec", created)
+	}
+	// The primary could not be stopped, so only the standby actions show
+	report := out.String()
+	for _, action := range []string{"removed the previous web on edge2", "created web on edge2 from nginx", "started web on edge2"} {
+		if !strings.Contains(report, action) {
+			t.Errorf("failover() report = %q, want %q", report, action)
+		}
+	}
+	if strings.Contains(report, "stopped") {
+		t.Errorf("failover() report = %q, want no stop on the unreachable primary", report)
+	}
+}
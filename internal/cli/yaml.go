@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses a YAML document, such as a Compose file, into maps
+// (map[string]any), lists ([]any) and scalars, which are all kept as
+// strings whatever their type; empty values and null are nil. Aliases
+// are replaced by the node of their anchor and merge keys (<<) by the
+// entries of the merged mappings. Multiple documents are not supported.
+func parseYAML(data []byte) (any, error) {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("line %d: multiple documents are not supported", next.Line)
+	}
+	return yamlValue(&doc)
+}
+
+// yamlValue converts n as parseYAML does.
+func yamlValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil, nil
+		}
+		return n.Value, nil
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		return yamlMapping(n)
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", n.Line)
+}
+
+// yamlMapping converts the mapping n, whose own keys take precedence over
+// the merged ones, and the mappings merged first over those merged later.
+func yamlMapping(n *yaml.Node) (map[string]any, error) {
+	m := map[string]any{}
+	own := map[string]bool{}
+	var merged []map[string]any
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.Tag == "!!merge" {
+			maps, err := yamlMerged(value)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, maps...)
+			continue
+		}
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+		if own[key.Value] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", key.Line, key.Value)
+		}
+		own[key.Value] = true
+		v, err := yamlValue(value)
+		if err != nil {
+			return nil, err
+		}
+		m[key.Value] = v
+	}
+
+	for i := len(merged) - 1; i >= 0; i-- {
+		for k, v := range merged[i] {
+			if !own[k] {
+				m[k] = v
+			}
+		}
+	}
+	return m, nil
+}
+
+// yamlMerged returns the mappings merged by the merge key value n: a
+// mapping or a sequence of mappings, given by aliases or not.
+func yamlMerged(n *yaml.Node) ([]map[string]any, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	nodes := []*yaml.Node{n}
+	if n.Kind == yaml.SequenceNode {
+		nodes = n.Content
+	}
+	var maps []map[string]any
+	for _, node := range nodes {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: << must merge a mapping or a list of mappings", node.Line)
+		}
+		m, err := yamlMapping(node)
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
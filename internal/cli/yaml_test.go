@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# A Compose file
+name: shop
+services:
+  web:
+    image: "nginx:1.25"   # pinned
+    ports:
+    - "8080:80"
+    - 8443:443
+    command: ["nginx", "-g", 'daemon off;']
+    environment:
+      - MODE=production
+      - GREETING=it's # not a comment start
+    labels: {tier: frontend, "team": web}
+  db:
+    image: postgres
+    healthcheck:
+    volumes:
+      -   type: volume
+          source: data
+      - /srv/db:/backup:ro
+    entrypoint: |
+      #!/bin/sh
+      exec postgres
+
+    description: >-
+      folded
+      text
+
+      new paragraph
+empty: ~
+`
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseYAML() unexpected error = %v", err)
+	}
+	want := map[string]any{
+		"name": "shop",
+		"services": map[string]any{
+			"web": map[string]any{
+				"image":       "nginx:1.25",
+				"ports":       []any{"8080:80", "8443:443"},
+				"command":     []any{"nginx", "-g", "daemon off;"},
+				"environment": []any{"MODE=production", "GREETING=it's"},
+				"labels":      map[string]any{"tier": "frontend", "team": "web"},
+			},
+			"db": map[string]any{
+				"image":       "postgres",
+				"healthcheck": nil,
+				"volumes": []any{
+					map[string]any{"type": "volume", "source": "data"},
+					"/srv/db:/backup:ro",
+				},
+				"entrypoint":  "#!/bin/sh\nexec postgres\n",
+				"description": "folded text\nnew paragraph",
+			},
+		},
+		"empty": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	tests := []struct {
+		doc, want string
+	}{
+		{"a: 1\n  b: 2\n", "line 2: mapping values are not allowed"},
+		{"a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"a:\n\t- x\n", "line 2: found character that cannot start any token"},
+		{"a: [1, 2\n", "line 1: did not find expected ',' or ']'"},
+		{"a: \"open\n", "found unexpected end of stream"},
+		{"a: 1\n---\nb: 2\n", "line 2: multiple documents"},
+		{"a: *missing\n", "unknown anchor"},
+		{"a:\n  <<: [x]\n", "line 2: << must merge a mapping"},
+	}
+	for _, tt := range tests {
+		if _, err := parseYAML([]byte(tt.doc)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseYAML(%q) error = %v, want %q", tt.doc, err, tt.want)
+		}
+	}
+}
+
+func TestParseYAML_AnchorsAndQuoting(t *testing.T) {
+	doc := `x-defaults: &defaults
+  restart: always
+  environment:
+    TZ: UTC
+x-logging: &logging
+  restart: "no"
+  logging: json-file
+services:
+  web:
+    <<: [*defaults, *logging]
+    image: "caf\u00e9:1.0"
+    command: "run
+      --verbose"
+    ports: [8080, 443]
+    read_only: true
+  db:
+    <<: *defaults
+    restart: unless-stopped
+    volumes: &volumes
+      - data:/var/lib/db
+  backup:
+    volumes: *volumes
+`
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseYAML() unexpected error = %v", err)
+	}
+	services := got.(map[string]any)["services"]
+	want := map[string]any{
+		"web": map[string]any{
+			"restart":     "always",
+			"environment": map[string]any{"TZ": "UTC"},
+			"logging":     "json-file",
+			"image":       "café:1.0",
+			"command":     "run --verbose",
+			"ports":       []any{"8080", "443"},
+			"read_only":   "true",
+		},
+		"db": map[string]any{
+			"restart":     "unless-stopped",
+			"environment": map[string]any{"TZ": "UTC"},
+			"volumes":     []any{"data:/var/lib/db"},
+		},
+		"backup": map[string]any{
+			"volumes": []any{"data:/var/lib/db"},
+		},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("parseYAML() services = %#v\nwant %#v", services, want)
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentSigners lists the keys held by an ssh-agent, such as FIDO2 security
+// keys (sk-ssh-ed25519@openssh.com) whose private half never leaves the
+// hardware and so cannot be read from a file. The agent is connected on
+// first use and the connection kept for signing, as the SSH handshake may
+// happen long after the client configuration is created, and repeatedly
+// when redialing.
+type agentSigners struct {
+	socket string
+
+	mu     sync.Mutex
+	client agent.ExtendedAgent
+}
+
+// Signers returns the agent's keys, connecting to it if needed.
+func (a *agentSigners) Signers() ([]ssh.Signer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.client == nil {
+		conn, err := net.Dial("unix", a.socket)
+		if err != nil {
+			return nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		a.client = agent.NewClient(conn)
+	}
+
+	signers, err := a.client.Signers()
+	if err != nil {
+		// Reconnect on next use, the agent may have been restarted
+		a.client = nil
+		return nil, fmt.Errorf("ssh-agent: %w", err)
+	}
+	return signers, nil
+}
+
+// identityAgent resolves the IdentityAgent setting of the SSH configuration
+// to the path of the agent socket, or "" if no agent is to be used. As with
+// OpenSSH, "none" disables the agent, and an empty value, "SSH_AUTH_SOCK" or
+// "$SSH_AUTH_SOCK" selects the socket named by $SSH_AUTH_SOCK.
+func identityAgent(setting string, tokens map[byte]string) string {
+	switch {
+	case setting == "none":
+		return ""
+	case setting == "" || setting == "SSH_AUTH_SOCK":
+		return os.Getenv("SSH_AUTH_SOCK")
+	case strings.HasPrefix(setting, "$") && !strings.HasPrefix(setting, "${"):
+		return os.Getenv(setting[1:])
+	}
+	return expandPath(setting, tokens)
+}
+
+// loadCertificate reads the OpenSSH user certificate at path.
+func loadCertificate(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an SSH certificate", path)
+	}
+	return cert, nil
+}
+
+// withCertificate returns signers with, ahead of the signer for the
+// certificate's key, one presenting the certificate, so that servers
+// trusting the certificate authority accept it first.
+func withCertificate(signers []ssh.Signer, cert *ssh.Certificate) []ssh.Signer {
+	if cert == nil {
+		return signers
+	}
+	certKey := cert.Key.Marshal()
+
+	var out []ssh.Signer
+	for _, s := range signers {
+		if bytes.Equal(s.PublicKey().Marshal(), certKey) {
+			if certSigner, err := ssh.NewCertSigner(cert, s); err == nil {
+				out = append(out, certSigner)
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
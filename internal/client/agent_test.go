@@ -0,0 +1,226 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent serves an ssh-agent holding keys and returns its socket.
+func startTestAgent(t *testing.T, keys ...ed25519.PrivateKey) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the agent listens on a Unix socket")
+	}
+	t.Helper()
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatalf("Failed to add key to agent: %v", err)
+		}
+	}
+
+	// A short directory, as socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return socket
+}
+
+// newTestCertificate returns a user certificate for pub signed by ca.
+func newTestCertificate(t *testing.T, ca ssh.Signer, pub ssh.PublicKey) *ssh.Certificate {
+	t.Helper()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"testuser"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	return cert
+}
+
+func TestNewSSHClientConfig_AgentCertificate(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, userKey, _ := ed25519.GenerateKey(rand.Reader)
+	userPub, err := ssh.NewPublicKey(userKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is only held by the agent, as a security key would be
+	socket := startTestAgent(t, userKey)
+	certFile := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	if err := os.WriteFile(certFile, ssh.MarshalAuthorizedKey(newTestCertificate(t, ca, userPub)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server only accepts keys certified by the CA
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+	}
+	connect := func(uc *UserConfig) error {
+		listener, serverConfig, addr := setupTestSSHServer(t)
+		defer listener.Close()
+		serverConfig.NoClientAuth = false
+		serverConfig.PublicKeyCallback = checker.Authenticate
+		startTestSSHServer(t, listener, serverConfig)
+
+		clientConfig, err := NewSSHClientConfig(5*time.Second, true, uc, nil)
+		if err != nil {
+			return err
+		}
+		client, err := NewSSHClient(addr, clientConfig)
+		if err != nil {
+			return err
+		}
+		client.Close()
+		return nil
+	}
+
+	uc := &UserConfig{
+		user:            "testuser",
+		identityFile:    filepath.Join(t.TempDir(), "missing"),
+		certificateFile: certFile,
+		identityAgent:   socket,
+	}
+	if err := connect(uc); err != nil {
+		t.Fatalf("connecting with agent key and certificate: %v", err)
+	}
+
+	uc.certificateFile = ""
+	if err := connect(uc); err == nil {
+		t.Error("connecting without certificate: expected error, got nil")
+	}
+}
+
+func TestNewSSHClientConfig_NoKeyNoAgent(t *testing.T) {
+	uc := &UserConfig{identityFile: filepath.Join(t.TempDir(), "missing")}
+	if _, err := NewSSHClientConfig(time.Second, true, uc, nil); err == nil {
+		t.Error("NewSSHClientConfig() expected error without key or agent, got nil")
+	}
+}
+
+func TestWithCertificate(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := ssh.NewSignerFromKey(caKey)
+	_, key1, _ := ed25519.GenerateKey(rand.Reader)
+	_, key2, _ := ed25519.GenerateKey(rand.Reader)
+	signer1, _ := ssh.NewSignerFromKey(key1)
+	signer2, _ := ssh.NewSignerFromKey(key2)
+	cert := newTestCertificate(t, ca, signer2.PublicKey())
+
+	got := withCertificate([]ssh.Signer{signer1, signer2}, cert)
+	if len(got) != 3 {
+		t.Fatalf("withCertificate() returned %d signers, want 3", len(got))
+	}
+	if _, ok := got[1].PublicKey().(*ssh.Certificate); !ok {
+		t.Errorf("withCertificate()[1] = %s, want the certificate ahead of its key", got[1].PublicKey().Type())
+	}
+	if len(withCertificate([]ssh.Signer{signer1}, nil)) != 1 {
+		t.Error("withCertificate() without certificate changed the signers")
+	}
+}
+
+func TestIdentityAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "/run/agent.sock")
+	t.Setenv("OTHER_AGENT", "/run/other.sock")
+
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"", "/run/agent.sock"},
+		{"SSH_AUTH_SOCK", "/run/agent.sock"},
+		{"$OTHER_AGENT", "/run/other.sock"},
+		{"none", ""},
+		{"/run/%u.sock", filepath.FromSlash("/run/core.sock")},
+	}
+	for _, tt := range tests {
+		if got := identityAgent(tt.setting, map[byte]string{'u': "core"}); got != tt.want {
+			t.Errorf("identityAgent(%q) = %q, want %q", tt.setting, got, tt.want)
+		}
+	}
+}
+
+func TestLoadUserConfig_Certificate(t *testing.T) {
+	tmpDir := t.TempDir()
+	sshDir := filepath.Join(tmpDir, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatalf("Failed to create .ssh directory: %v", err)
+	}
+	configData := `Host default
+  IdentityFile ~/.ssh/id_ed25519
+
+Host explicit
+  IdentityFile ~/.ssh/id_ed25519
+  CertificateFile ~/.ssh/deploy-cert.pub
+  IdentityAgent none
+`
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_ed25519-cert.pub"), nil, 0600); err != nil {
+		t.Fatalf("Failed to write certificate file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+	t.Setenv("SSH_AUTH_SOCK", "/run/agent.sock")
+
+	got, err := NewUserConfig("default")
+	if err != nil {
+		t.Fatalf("NewUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(sshDir, "id_ed25519-cert.pub"); got.certificateFile != want {
+		t.Errorf("NewUserConfig() certificateFile = %q, want %q", got.certificateFile, want)
+	}
+	if got.identityAgent != "/run/agent.sock" {
+		t.Errorf("NewUserConfig() identityAgent = %q, want $SSH_AUTH_SOCK", got.identityAgent)
+	}
+
+	got, err = NewUserConfig("explicit")
+	if err != nil {
+		t.Fatalf("NewUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(sshDir, "deploy-cert.pub"); got.certificateFile != want {
+		t.Errorf("NewUserConfig() certificateFile = %q, want %q", got.certificateFile, want)
+	}
+	if got.identityAgent != "" {
+		t.Errorf("NewUserConfig() identityAgent = %q, want none", got.identityAgent)
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding lists the response encodings DecompressTransport accepts.
+// zstd is not offered, as the standard library has no decoder for it.
+const acceptEncoding = "gzip, deflate"
+
+// DecompressTransport returns an http.RoundTripper that sends requests with
+// rt, offering compressed responses through Accept-Encoding and decoding
+// gzip and deflate response bodies transparently. Requests that already set
+// Accept-Encoding are sent unchanged. The transport underlying rt should
+// have DisableCompression set, so that encodings are negotiated only once.
+func DecompressTransport(rt http.RoundTripper) http.RoundTripper {
+	return &decompressTransport{rt: rt}
+}
+
+type decompressTransport struct {
+	rt http.RoundTripper
+}
+
+func (t *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return t.rt.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		body = &lazyReader{open: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }, src: resp.Body}
+	case "deflate":
+		body = &lazyReader{open: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil }, src: resp.Body}
+	default:
+		return resp, nil
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// lazyReader decodes src with the reader returned by open, created on the
+// first Read so that RoundTrip does not block on the body, as streamed
+// responses may not have sent any of it yet.
+type lazyReader struct {
+	open func(io.Reader) (io.ReadCloser, error)
+	src  io.ReadCloser
+	r    io.ReadCloser
+	err  error
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.r == nil && l.err == nil {
+		l.r, l.err = l.open(l.src)
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.r.Read(p)
+}
+
+func (l *lazyReader) Close() error {
+	if l.r != nil {
+		l.r.Close()
+	}
+	return l.src.Close()
+}
+
+// compressedMagic holds the leading bytes of the compressed formats Podman
+// accepts for image archives and build contexts: gzip, bzip2, xz and zstd.
+var compressedMagic = [][]byte{
+	{0x1f, 0x8b},
+	[]byte("BZh"),
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},
+	{0x28, 0xb5, 0x2f, 0xfd},
+}
+
+// Gzip returns a reader yielding r compressed with gzip, for uploading
+// archives over slow links. Input that is already compressed is returned
+// as is.
+func Gzip(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(6)
+	for _, magic := range compressedMagic {
+		if bytes.HasPrefix(head, magic) {
+			return br
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, br)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompressTransport(t *testing.T) {
+	const payload = `[{"Id":"abc"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.URL.Query().Get("encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			encoding = ""
+		}
+		switch encoding {
+		case "gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(payload))
+			gz.Close()
+		case "deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			fw.Write([]byte(payload))
+			fw.Close()
+		default:
+			w.Write([]byte(payload))
+		}
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: DecompressTransport(&http.Transport{DisableCompression: true})}
+	for _, encoding := range []string{"gzip", "deflate", "identity"} {
+		t.Run(encoding, func(t *testing.T) {
+			resp, err := httpClient.Get(server.URL + "?encoding=" + encoding)
+			if err != nil {
+				t.Fatalf("Get() unexpected error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if string(body) != payload {
+				t.Errorf("body = %q, want %q", body, payload)
+			}
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Errorf("Content-Encoding = %q, want it removed", resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+
+	// Callers negotiating an encoding themselves get the raw body
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?encoding=gzip", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() unexpected error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Error("response to an explicit Accept-Encoding was decoded")
+	}
+}
+
+func TestGzip(t *testing.T) {
+	archive := bytes.Repeat([]byte("layer data "), 1000)
+
+	compressed, err := io.ReadAll(Gzip(bytes.NewReader(archive)))
+	if err != nil {
+		t.Fatalf("reading compressed archive: %v", err)
+	}
+	if len(compressed) >= len(archive) {
+		t.Errorf("Gzip() produced %d bytes from %d", len(compressed), len(archive))
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Gzip() output is not gzip: %v", err)
+	}
+	if got, _ := io.ReadAll(gz); !bytes.Equal(got, archive) {
+		t.Error("Gzip() output does not decompress to the input")
+	}
+
+	// Already compressed input is passed through
+	again, err := io.ReadAll(Gzip(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	if !bytes.Equal(again, compressed) {
+		t.Error("Gzip() compressed an already compressed archive")
+	}
+
+	if got, _ := io.ReadAll(Gzip(strings.NewReader(""))); len(got) == 0 {
+		t.Error("Gzip() of empty input produced no gzip stream")
+	}
+}
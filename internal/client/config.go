@@ -1,106 +1,437 @@
 // Package client provides SSH client configuration and connection management
 // for connecting to remote Podman instances. It handles SSH config file parsing,
-// authentication, and host key verification.
+// authentication, and host key verification. Client combines them into a
+// connection to the Podman API of a host, safe for concurrent use.
 package client
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/kevinburke/ssh_config"
+	"github.com/alexjch/podman-cli/internal/config"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // UserConfig holds the SSH configuration for connecting to a remote host.
 // It stores credentials, connection details, and paths to SSH files.
 type UserConfig struct {
-	user         string
-	port         string
-	hostName     string
-	knownHosts   string
-	identityFile string
+	user            string
+	port            string
+	hostName        string
+	knownHosts      string
+	identityFile    string
+	certificateFile string
+	identityAgent   string
+
+	// readOnlyKnownHosts are the known_hosts files trusted besides
+	// knownHosts, to which no key is added: those UserKnownHostsFile lists
+	// after the first and the system-wide GlobalKnownHostsFile ones
+	readOnlyKnownHosts []string
+
+	strictHostKeyChecking string
+	hashKnownHosts        bool
+}
+
+// Paths overrides the locations of the SSH files read when connecting.
+// Empty fields select the defaults in the user's ~/.ssh directory.
+type Paths struct {
+	Config     string // SSH client configuration file
+	KnownHosts string // known_hosts file used to verify host keys
+}
+
+// Options holds the settings for connecting to hosts that do not come
+// from the SSH configuration. It is the one place callers, such as the
+// command-line interface, describe how to connect.
+type Options struct {
+	Paths
+	Timeout  time.Duration      // SSH connection timeout
+	Insecure bool               // skip host key verification (not recommended)
+	Creds    CredentialProvider // source of the private key or its passphrase, nil to read the identity file
+}
+
+// Target is a host resolved through the SSH configuration, ready to be
+// connected to with NewSSHClientVia.
+type Target struct {
+	Addr   string // host:port to dial
+	Config *ssh.ClientConfig
 }
 
+// Resolve looks host up in the SSH configuration, as LoadUserConfig does,
+// and prepares the client configuration to connect to it with o.
+func (o Options) Resolve(host string) (Target, error) {
+	userConfig, err := LoadUserConfig(host, o.Paths)
+	if err != nil {
+		return Target{}, err
+	}
+	clientConfig, err := NewSSHClientConfig(o.Timeout, o.Insecure, userConfig, o.Creds)
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{Addr: userConfig.Addr(), Config: clientConfig}, nil
+}
+
+// DefaultSocketPath is the location of the rootless Podman API socket on the
+// remote host.
+const DefaultSocketPath = "/run/user/1000/podman/podman.sock"
+
 // sshUserFilePath constructs an absolute path to a file in the user's .ssh directory.
 func sshUserFilePath(fileName string) string {
-	return filepath.Join(os.Getenv("HOME"), ".ssh", fileName)
+	return filepath.Join(config.HomeDir(), ".ssh", fileName)
+}
+
+// defaultIdentityFiles are the private keys in the user's .ssh directory
+// tried, in order, when the SSH configuration names none.
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// defaultIdentityFile returns the first of defaultIdentityFiles that
+// exists, or id_ed25519 if none does.
+func defaultIdentityFile() string {
+	for _, name := range defaultIdentityFiles {
+		path := sshUserFilePath(name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return sshUserFilePath(defaultIdentityFiles[0])
+}
+
+// expandPath expands a file path from the SSH configuration or the command
+// line as OpenSSH does. A leading "~" is replaced with the user's home
+// directory and ${VAR} with the value of an environment variable. Windows
+// style %VAR% references, such as %USERPROFILE%, are expanded when the
+// variable is set, and otherwise the % tokens in tokens, such as %d for the
+// home directory, are replaced; %% is a literal percent sign. Forward
+// slashes are converted to the local separator.
+func expandPath(path string, tokens map[byte]string) string {
+	if path == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		path = config.HomeDir() + path[1:]
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '$' && strings.HasPrefix(path[i+1:], "{") {
+			if end := strings.IndexByte(path[i+2:], '}'); end >= 0 {
+				b.WriteString(os.Getenv(path[i+2 : i+2+end]))
+				i += end + 2
+				continue
+			}
+		}
+		if c == '%' && i+1 < len(path) {
+			if name := envReference(path[i+1:]); name != "" {
+				if value, ok := os.LookupEnv(name); ok {
+					b.WriteString(value)
+					i += len(name) + 1
+					continue
+				}
+			}
+			if path[i+1] == '%' {
+				b.WriteByte('%')
+				i++
+				continue
+			}
+			if value, ok := tokens[path[i+1]]; ok {
+				b.WriteString(value)
+				i++
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return filepath.Clean(filepath.FromSlash(b.String()))
+}
+
+// envReference returns the variable name of a %VAR% reference at the start
+// of s, which follows the opening percent sign, or "" if there is none.
+// Single letter names are left to be read as tokens.
+func envReference(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if i < 2 {
+				return ""
+			}
+			return s[:i]
+		case c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// pathTokens returns the % tokens expanded in the paths of the SSH
+// configuration for a connection as remoteUser to hostName.
+func pathTokens(hostName, remoteUser string) map[byte]string {
+	return map[byte]string{
+		'd': config.HomeDir(),
+		'u': currentUsername(),
+		'h': hostName,
+		'r': remoteUser,
+	}
+}
+
+// currentUsername returns the name of the current user, without the domain
+// part Windows includes, falling back to $USER if the user database is
+// unavailable.
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		name := u.Username
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	return os.Getenv("USER")
 }
 
 // NewSSHClientConfig creates an SSH client configuration from user config.
 // It reads the identity file, sets up authentication, and configures host key verification.
+// The host key algorithms are limited to the types of the keys known_hosts
+// records for the host, if any.
 //
 // Parameters:
 //   - timeout: SSH connection timeout duration
 //   - insecure: if true, skips host key verification (not recommended for production)
 //   - userConfig: user configuration containing SSH details
+//   - creds: optional provider of the private key or its passphrase; when nil
+//     the key is read from the identity file and must not be encrypted
+//
+// The keys of the ssh-agent selected by IdentityAgent are offered after the
+// identity file, which may then be missing or unusable, as for security
+// keys held by the agent. A user certificate, from CertificateFile or next
+// to the identity file, is presented with its key.
 //
 // Returns an ssh.ClientConfig ready for establishing connections.
-func NewSSHClientConfig(timeout time.Duration, insecure bool, userConfig *UserConfig) (*ssh.ClientConfig, error) {
+func NewSSHClientConfig(timeout time.Duration, insecure bool, userConfig *UserConfig, creds CredentialProvider) (*ssh.ClientConfig, error) {
 
 	var hostKeyCallback ssh.HostKeyCallback
 
-	key, err := os.ReadFile(userConfig.identityFile)
-	if err != nil {
-		return nil, err
+	var cert *ssh.Certificate
+	if userConfig.certificateFile != "" {
+		var err error
+		if cert, err = loadCertificate(userConfig.certificateFile); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create the Signer for this private key.
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
+	var signers []ssh.Signer
+	signer, err := loadSigner(userConfig, creds)
+	switch {
+	case err == nil:
+		signers = withCertificate([]ssh.Signer{signer}, cert)
+	case creds != nil || userConfig.identityAgent == "":
 		return nil, err
 	}
 
 	if insecure {
 		hostKeyCallback = ssh.InsecureIgnoreHostKey()
 	} else {
-		hostKeyCallback, err = knownhosts.New(userConfig.knownHosts)
+		hostKeyCallback, err = knownHostsCallback(userConfig)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	auth := ssh.PublicKeys(signers...)
+	if userConfig.identityAgent != "" {
+		// A single method, as the SSH client does not try a second one of
+		// the same type
+		agentKeys := &agentSigners{socket: userConfig.identityAgent}
+		auth = ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			fromAgent, err := agentKeys.Signers()
+			if err != nil && len(signers) == 0 {
+				return nil, err
+			}
+			return append(signers[:len(signers):len(signers)], withCertificate(fromAgent, cert)...), nil
+		})
+	}
+
 	clientConfig := &ssh.ClientConfig{
 		User: userConfig.user,
 		Auth: []ssh.AuthMethod{
 			// Use the PublicKeys method for remote authentication.
-			ssh.PublicKeys(signer),
+			auth,
 		},
 		HostKeyCallback: hostKeyCallback,
 		Timeout:         timeout,
 	}
+	if !insecure {
+		clientConfig.HostKeyAlgorithms = knownHostKeyAlgorithms(userConfig.knownHostsFiles(), userConfig.Addr())
+	}
 
 	return clientConfig, nil
 }
 
-// Addr returns the SSH server address in "host:port" format.
+// loadSigner returns the signer for the private key of userConfig, taking
+// the key or its passphrase from creds when given.
+func loadSigner(userConfig *UserConfig, creds CredentialProvider) (ssh.Signer, error) {
+	var c Credentials
+	if creds != nil {
+		var err error
+		if c, err = creds.Credentials(userConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	key := c.Key
+	if key == nil {
+		var err error
+		if key, err = os.ReadFile(userConfig.identityFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the Signer for this private key.
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && c.Passphrase != nil {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, c.Passphrase)
+	}
+	if err != nil || c.Certificate == nil {
+		return signer, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(c.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("certificate: not an SSH certificate")
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// Addr returns the SSH server address in "host:port" format, with IPv6
+// addresses in brackets, such as "[2001:db8::1]:22".
 func (uc *UserConfig) Addr() string {
-	return fmt.Sprintf("%s:%s", uc.hostName, uc.port)
+	return net.JoinHostPort(uc.hostName, uc.port)
+}
+
+// User returns the remote user to log in as.
+func (uc *UserConfig) User() string {
+	return uc.user
+}
+
+// parseHost splits a host given as [user@]host[:port] into its parts,
+// which are empty if not given. IPv6 addresses take a port only in
+// brackets, such as "[2001:db8::1]" or "[fe80::1%eth0]:2222".
+func parseHost(host string) (user, name, port string, err error) {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		user, host = host[:i], host[i+1:]
+		if user == "" {
+			return "", "", "", fmt.Errorf("invalid host %q: empty user name", "@"+host)
+		}
+	}
+	name, port, err = splitHost(host)
+	return user, name, port, err
+}
+
+// splitHost splits host into the address and port, which is empty if not
+// given. Other than in brackets, addresses with more than one colon are
+// IPv6 addresses without port.
+func splitHost(host string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]"):
+		return host[1 : len(host)-1], "", nil
+	case !strings.HasPrefix(host, "[") && strings.Count(host, ":") != 1:
+		return host, "", nil
+	}
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid host %q: bad port %q", host, port)
+	}
+	return name, port, nil
 }
 
-// NewUserConfig reads SSH configuration from ~/.ssh/config and creates a UserConfig.
+// NewUserConfig reads SSH configuration from ~/.ssh/config and creates a
+// UserConfig, as LoadUserConfig does with default paths.
+func NewUserConfig(host string) (*UserConfig, error) {
+	return LoadUserConfig(host, Paths{})
+}
+
+// LoadUserConfig reads SSH configuration and creates a UserConfig.
 // It parses the SSH config file for the specified host and applies defaults for
 // missing values (port 22, current user, id_ed25519 key).
 //
+// Settings are looked up as OpenSSH does: Host stanzas may use wildcard
+// and negated patterns, Match blocks are evaluated with the criteria
+// described for decodeSSHConfig, and the first value found for a keyword
+// wins.
+//
+// The host may be given as [user@]host[:port], such as
+// "admin@10.0.0.5:2222" or "[2001:db8::1]:2222", for connections not
+// described in the configuration; the user and port given override the
+// configured ones, and the host alone is matched against Host stanzas.
+//
 // The function respects standard SSH config directives including:
-//   - HostName: the actual hostname or IP to connect to
+//   - HostName: the actual hostname or IP to connect to, where %h stands
+//     for host
 //   - Port: SSH port (defaults to 22)
-//   - User: username for authentication (defaults to current USER)
-//   - IdentityFile: path to private key (defaults to ~/.ssh/id_ed25519)
+//   - User: username for authentication (defaults to the current user)
+//   - IdentityFile: path to private key (defaults to the first of
+//     ~/.ssh/id_ed25519, id_ecdsa and id_rsa that exists)
+//   - CertificateFile: user certificate (defaults to the identity file
+//     with a -cert.pub suffix, if it exists)
+//   - IdentityAgent: ssh-agent socket (defaults to $SSH_AUTH_SOCK; "none"
+//     disables the agent)
+//   - UserKnownHostsFile: known_hosts files, separated by spaces (defaults
+//     to ~/.ssh/known_hosts); keys accepted are added to the first
+//   - GlobalKnownHostsFile: system-wide known_hosts files, also trusted
+//     (defaults to /etc/ssh/ssh_known_hosts and ssh_known_hosts2)
+//   - StrictHostKeyChecking: accept-new (or no) adds the keys of unknown
+//     hosts to known_hosts; other values reject them
+//   - HashKnownHosts: yes hashes the host names of the keys added
 //
-// Returns an error if the config file cannot be read or parsed.
-func NewUserConfig(host string) (*UserConfig, error) {
-
-	file, err := os.Open(sshUserFilePath("config"))
+// Paths may start with "~" and use the %d, %u, %h and %r tokens, ${VAR}
+// and, as on Windows, %VAR% environment references such as %USERPROFILE%.
+//
+// The configuration is read from paths.Config and host keys are verified
+// against paths.KnownHosts, which take precedence over the configuration,
+// defaulting to ~/.ssh/config and ~/.ssh/known_hosts, where ~ is the home
+// directory reported by the OS (%USERPROFILE% on Windows). A missing
+// ~/.ssh/config is treated as empty. Returns an error if the config file
+// cannot be read or parsed.
+func LoadUserConfig(host string, paths Paths) (*UserConfig, error) {
+	hostUser, host, hostPort, err := parseHost(host)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	conf, err := ssh_config.Decode(file)
+	configFile := sshUserFilePath("config")
+	if paths.Config != "" {
+		configFile = expandPath(paths.Config, pathTokens(host, ""))
+	}
+
+	// A missing ~/.ssh/config is an empty one, as for ssh; a file given
+	// explicitly must exist
+	var r io.Reader = strings.NewReader("")
+	file, err := os.Open(configFile)
+	if err == nil {
+		defer file.Close()
+		r = file
+	} else if paths.Config != "" || !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	conf, err := decodeSSHConfig(r, host)
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +441,15 @@ func NewUserConfig(host string) (*UserConfig, error) {
 		return nil, err
 	}
 
-	// Default to the provided host if HostName is empty
+	// Default to the provided host if HostName is empty. The host is not
+	// expanded, as the zone of an IPv6 address may contain "%h"
 	if hostName == "" {
 		hostName = host
+	} else {
+		hostName = strings.ReplaceAll(hostName, "%h", host)
+		if name, port, err := splitHost(hostName); err == nil && port == "" {
+			hostName = name
+		}
 	}
 
 	// User
@@ -121,9 +458,12 @@ func NewUserConfig(host string) (*UserConfig, error) {
 		return nil, err
 	}
 
-	// Default to current user
+	// A user given with the host takes precedence; default to current user
+	if hostUser != "" {
+		user = hostUser
+	}
 	if user == "" {
-		user = os.Getenv("USER")
+		user = currentUsername()
 	}
 
 	// Identity file
@@ -132,11 +472,27 @@ func NewUserConfig(host string) (*UserConfig, error) {
 		return nil, err
 	}
 
+	tokens := pathTokens(hostName, user)
 	if idFile == "" {
-		idFile = sshUserFilePath("id_ed25519")
-	} else if strings.HasPrefix(idFile, "~/") {
-		// Expand tilde to HOME directory
-		idFile = filepath.Join(os.Getenv("HOME"), idFile[2:])
+		idFile = defaultIdentityFile()
+	} else {
+		idFile = expandPath(idFile, tokens)
+	}
+
+	// User certificate, by default the one OpenSSH looks for next to the key
+	certFile, err := conf.Get(host, "CertificateFile")
+	if err != nil {
+		return nil, err
+	}
+	if certFile != "" {
+		certFile = expandPath(certFile, tokens)
+	} else if _, err := os.Stat(idFile + "-cert.pub"); err == nil {
+		certFile = idFile + "-cert.pub"
+	}
+
+	agentSetting, err := conf.Get(host, "IdentityAgent")
+	if err != nil {
+		return nil, err
 	}
 
 	port, err := conf.Get(host, "Port")
@@ -144,12 +500,53 @@ func NewUserConfig(host string) (*UserConfig, error) {
 		return nil, err
 	}
 
-	// Default to port 22 if empty
+	// A port given with the host, as in "[2001:db8::1]:2222", takes
+	// precedence; default to port 22 if empty
+	if hostPort != "" {
+		port = hostPort
+	}
 	if port == "" {
 		port = "22"
 	}
 
-	knownHostsFile := sshUserFilePath("known_hosts")
+	// Keys are added to the first user file; the others, and the
+	// system-wide files, are only read
+	userKnownHosts := []string{paths.KnownHosts}
+	if paths.KnownHosts == "" {
+		setting, err := conf.Get(host, "UserKnownHostsFile")
+		if err != nil {
+			return nil, err
+		}
+		userKnownHosts = strings.Fields(setting)
+	}
+	if len(userKnownHosts) == 0 {
+		userKnownHosts = []string{sshUserFilePath("known_hosts")}
+	}
+	globalKnownHosts, err := conf.Get(host, "GlobalKnownHostsFile")
+	if err != nil {
+		return nil, err
+	}
+	globalFiles := defaultGlobalKnownHosts
+	if fields := strings.Fields(globalKnownHosts); len(fields) > 0 {
+		globalFiles = fields
+	}
+	var readOnlyKnownHosts []string
+	for _, f := range append(userKnownHosts[1:], globalFiles...) {
+		if !strings.EqualFold(f, "none") {
+			readOnlyKnownHosts = append(readOnlyKnownHosts, expandPath(f, tokens))
+		}
+	}
+	knownHostsFile := expandPath(userKnownHosts[0], tokens)
+
+	strictHostKeyChecking, err := conf.Get(host, "StrictHostKeyChecking")
+	if err != nil {
+		return nil, err
+	}
+
+	hashKnownHosts, err := conf.Get(host, "HashKnownHosts")
+	if err != nil {
+		return nil, err
+	}
 
 	userConfig := &UserConfig{
 		user:         user,
@@ -157,6 +554,13 @@ func NewUserConfig(host string) (*UserConfig, error) {
 		hostName:     hostName,
 		knownHosts:   knownHostsFile,
 		identityFile: idFile,
+
+		readOnlyKnownHosts: readOnlyKnownHosts,
+		certificateFile:    certFile,
+		identityAgent:      identityAgent(agentSetting, tokens),
+
+		strictHostKeyChecking: strictHostKeyChecking,
+		hashKnownHosts:        strings.EqualFold(hashKnownHosts, "yes"),
 	}
 
 	return userConfig, nil
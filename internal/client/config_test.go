@@ -5,12 +5,23 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
+	"io/fs"
 	"os"
+	"os/user"
 	"path/filepath"
 	"testing"
 	"time"
 )
 
+// setHomeDir makes dir the home directory reported by the OS for the
+// duration of the test: $HOME on Unix and %USERPROFILE% on Windows.
+func setHomeDir(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
+
 func TestUserConfig_Addr(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -47,7 +58,15 @@ func TestUserConfig_Addr(t *testing.T) {
 				hostName: "2001:db8::1",
 				port:     "22",
 			},
-			expected: "2001:db8::1:22",
+			expected: "[2001:db8::1]:22",
+		},
+		{
+			name: "IPv6 address with zone",
+			config: UserConfig{
+				hostName: "fe80::1%eth0",
+				port:     "2222",
+			},
+			expected: "[fe80::1%eth0]:2222",
 		},
 	}
 
@@ -79,9 +98,7 @@ func TestNewUserConfig_ValidConfig(t *testing.T) {
 		t.Fatalf("Failed to write config file: %v", err)
 	}
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	got, err := NewUserConfig("myserver")
 	if err != nil {
@@ -121,14 +138,15 @@ func TestNewUserConfig_DefaultValues(t *testing.T) {
 		t.Fatalf("Failed to write config file: %v", err)
 	}
 
-	oldHome := os.Getenv("HOME")
-	oldUser := os.Getenv("USER")
-	os.Setenv("HOME", tmpDir)
-	os.Setenv("USER", "testuser")
-	defer func() {
-		os.Setenv("HOME", oldHome)
-		os.Setenv("USER", oldUser)
-	}()
+	setHomeDir(t, tmpDir)
+	t.Setenv("USER", "testuser")
+
+	// Like OpenSSH, the default user comes from the user database rather
+	// than $USER
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("user database unavailable: %v", err)
+	}
 
 	got, err := NewUserConfig("webserver")
 	if err != nil {
@@ -143,8 +161,8 @@ func TestNewUserConfig_DefaultValues(t *testing.T) {
 		t.Errorf("NewUserConfig() port = %q, want %q (default)", got.port, "22")
 	}
 
-	if got.user != "testuser" {
-		t.Errorf("NewUserConfig() user = %q, want %q (default)", got.user, "testuser")
+	if got.user != current.Username {
+		t.Errorf("NewUserConfig() user = %q, want %q (default)", got.user, current.Username)
 	}
 
 	wantIdentityFile := filepath.Join(tmpDir, ".ssh", "id_ed25519")
@@ -168,9 +186,7 @@ func TestNewUserConfig_MissingHost(t *testing.T) {
 		t.Fatalf("Failed to write config file: %v", err)
 	}
 
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	setHomeDir(t, tmpDir)
 
 	got, err := NewUserConfig("missinghost")
 	if err != nil {
@@ -187,19 +203,6 @@ func TestNewUserConfig_MissingHost(t *testing.T) {
 	}
 }
 
-func TestNewUserConfig_NoConfigFile(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
-
-	_, err := NewUserConfig("anyhost")
-	if err == nil {
-		t.Error("NewUserConfig() expected error when config file doesn't exist, got nil")
-	}
-}
-
 func TestNewSSHClientConfig_Insecure(t *testing.T) {
 	tmpDir := t.TempDir()
 	sshDir := filepath.Join(tmpDir, ".ssh")
@@ -234,7 +237,7 @@ func TestNewSSHClientConfig_Insecure(t *testing.T) {
 
 	timeout := 30 * time.Second
 	insecure := true
-	clientConfig, err := NewSSHClientConfig(timeout, insecure, userConfig)
+	clientConfig, err := NewSSHClientConfig(timeout, insecure, userConfig, nil)
 	if err != nil {
 		t.Fatalf("NewSSHClientConfig() unexpected error = %v", err)
 	}
@@ -276,7 +279,7 @@ func TestNewSSHClientConfig_InvalidKeyFile(t *testing.T) {
 	}
 
 	timeout := 30 * time.Second
-	_, err := NewSSHClientConfig(timeout, true, userConfig)
+	_, err := NewSSHClientConfig(timeout, true, userConfig, nil)
 	if err == nil {
 		t.Error("NewSSHClientConfig() expected error for nonexistent key file, got nil")
 	}
@@ -303,16 +306,15 @@ func TestNewSSHClientConfig_InvalidKeyFormat(t *testing.T) {
 	}
 
 	timeout := 30 * time.Second
-	_, err := NewSSHClientConfig(timeout, true, userConfig)
+	_, err := NewSSHClientConfig(timeout, true, userConfig, nil)
 	if err == nil {
 		t.Error("NewSSHClientConfig() expected error for invalid key format, got nil")
 	}
 }
 
 func TestSshUserFilePath(t *testing.T) {
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", "/home/testuser")
-	defer os.Setenv("HOME", oldHome)
+	home := filepath.Join(t.TempDir(), "testuser")
+	setHomeDir(t, home)
 
 	tests := []struct {
 		name     string
@@ -322,17 +324,17 @@ func TestSshUserFilePath(t *testing.T) {
 		{
 			name:     "config file",
 			fileName: "config",
-			expected: "/home/testuser/.ssh/config",
+			expected: filepath.Join(home, ".ssh", "config"),
 		},
 		{
 			name:     "known_hosts file",
 			fileName: "known_hosts",
-			expected: "/home/testuser/.ssh/known_hosts",
+			expected: filepath.Join(home, ".ssh", "known_hosts"),
 		},
 		{
 			name:     "identity file",
 			fileName: "id_rsa",
-			expected: "/home/testuser/.ssh/id_rsa",
+			expected: filepath.Join(home, ".ssh", "id_rsa"),
 		},
 	}
 
@@ -345,3 +347,268 @@ func TestSshUserFilePath(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadUserConfig_Paths(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host webserver
+  HostName example.com
+  User deploy
+  IdentityFile ~/keys/deploy
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	setHomeDir(t, tmpDir)
+
+	got, err := LoadUserConfig("webserver", Paths{Config: configFile, KnownHosts: "~/hosts"})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+
+	if got.user != "deploy" {
+		t.Errorf("LoadUserConfig() user = %q, want %q", got.user, "deploy")
+	}
+	if want := filepath.Join(tmpDir, "keys", "deploy"); got.identityFile != want {
+		t.Errorf("LoadUserConfig() identityFile = %q, want %q", got.identityFile, want)
+	}
+	if want := filepath.Join(tmpDir, "hosts"); got.knownHosts != want {
+		t.Errorf("LoadUserConfig() knownHosts = %q, want %q", got.knownHosts, want)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "testuser")
+	setHomeDir(t, home)
+	t.Setenv("PODMAN_CLI_KEYS", filepath.Join(home, "keys"))
+
+	tokens := map[byte]string{'d': home, 'h': "edge1", 'r': "core"}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"empty", "", ""},
+		{"tilde", "~/.ssh/id_rsa", filepath.Join(home, ".ssh", "id_rsa")},
+		{"home token", "%d/.ssh/known_hosts", filepath.Join(home, ".ssh", "known_hosts")},
+		{"host and user tokens", "~/.ssh/%r@%h", filepath.Join(home, ".ssh", "core@edge1")},
+		{"windows variable", "%USERPROFILE%/.ssh/id_ed25519", filepath.Join(home, ".ssh", "id_ed25519")},
+		{"braced variable", "${PODMAN_CLI_KEYS}/deploy", filepath.Join(home, "keys", "deploy")},
+		{"unset variable kept", "/keys/%PODMAN_CLI_UNSET%", filepath.FromSlash("/keys/%PODMAN_CLI_UNSET%")},
+		{"literal percent", "/keys/100%%", filepath.FromSlash("/keys/100%")},
+		{"unknown token kept", "/keys/%x", filepath.FromSlash("/keys/%x")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandPath(tt.path, tokens); got != tt.want {
+				t.Errorf("expandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadUserConfig_DefaultIdentityFallback(t *testing.T) {
+	tmpDir := t.TempDir()
+	sshDir := filepath.Join(tmpDir, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatalf("Failed to create .ssh directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte("Host webserver\n  HostName example.com\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_rsa"), nil, 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	got, err := NewUserConfig("webserver")
+	if err != nil {
+		t.Fatalf("NewUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(sshDir, "id_rsa"); got.identityFile != want {
+		t.Errorf("NewUserConfig() identityFile = %q, want %q", got.identityFile, want)
+	}
+}
+
+func TestLoadUserConfig_MissingDefaultConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+
+	got, err := LoadUserConfig("admin@example.com:2222", Paths{})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() without ~/.ssh/config unexpected error = %v", err)
+	}
+	if got.Addr() != "example.com:2222" || got.user != "admin" {
+		t.Errorf("LoadUserConfig() = %s@%s, want admin@example.com:2222", got.user, got.Addr())
+	}
+	if want := filepath.Join(tmpDir, ".ssh", "known_hosts"); got.knownHosts != want {
+		t.Errorf("LoadUserConfig() knownHosts = %q, want %q", got.knownHosts, want)
+	}
+
+	// A file given explicitly must exist
+	if _, err := LoadUserConfig("example.com", Paths{Config: filepath.Join(tmpDir, "missing")}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadUserConfig() with a missing -ssh-config error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLoadUserConfig_UserKnownHostsFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host webserver
+  HostName example.com
+  UserKnownHostsFile %d/hosts/%h ~/.ssh/known_hosts2
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	got, err := LoadUserConfig("webserver", Paths{Config: configFile})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, "hosts", "example.com"); got.knownHosts != want {
+		t.Errorf("LoadUserConfig() knownHosts = %q, want %q", got.knownHosts, want)
+	}
+
+	// The -known-hosts flag overrides the configuration
+	got, err = LoadUserConfig("webserver", Paths{Config: configFile, KnownHosts: "~/override"})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, "override"); got.knownHosts != want {
+		t.Errorf("LoadUserConfig() knownHosts = %q, want %q", got.knownHosts, want)
+	}
+}
+
+func TestLoadUserConfig_IPv6Host(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host 2001:db8::1
+  Port 2200
+
+Host v6
+  HostName [2001:db8::2]
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"2001:db8::1", "[2001:db8::1]:2200"},
+		{"[2001:db8::1]", "[2001:db8::1]:2200"},
+		{"[2001:db8::1]:2222", "[2001:db8::1]:2222"},
+		{"admin@[2001:db8::1]:2222", "[2001:db8::1]:2222"},
+		{"[fe80::1%eth0]:2222", "[fe80::1%eth0]:2222"},
+		{"fe80::1%hme0", "[fe80::1%hme0]:22"},
+		{"v6", "[2001:db8::2]:22"},
+	}
+	for _, tt := range tests {
+		got, err := LoadUserConfig(tt.host, Paths{Config: configFile})
+		if err != nil {
+			t.Errorf("LoadUserConfig(%q) unexpected error = %v", tt.host, err)
+			continue
+		}
+		if got.Addr() != tt.want {
+			t.Errorf("LoadUserConfig(%q).Addr() = %q, want %q", tt.host, got.Addr(), tt.want)
+		}
+	}
+
+	if _, err := LoadUserConfig("[2001:db8::1]:x:y", Paths{Config: configFile}); err == nil {
+		t.Error("LoadUserConfig() expected error for an invalid bracketed host, got nil")
+	}
+}
+
+func TestLoadUserConfig_InlineUserAndPort(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host 10.0.0.5
+  User deploy
+  Port 2200
+  IdentityFile ~/.ssh/deploy_key
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	tests := []struct {
+		host     string
+		wantUser string
+		wantAddr string
+	}{
+		{"10.0.0.5", "deploy", "10.0.0.5:2200"},
+		{"admin@10.0.0.5", "admin", "10.0.0.5:2200"},
+		{"admin@10.0.0.5:2222", "admin", "10.0.0.5:2222"},
+		{"10.0.0.5:2222", "deploy", "10.0.0.5:2222"},
+		{"ops@example.com", "ops", "example.com:22"},
+	}
+	for _, tt := range tests {
+		got, err := LoadUserConfig(tt.host, Paths{Config: configFile})
+		if err != nil {
+			t.Errorf("LoadUserConfig(%q) unexpected error = %v", tt.host, err)
+			continue
+		}
+		if got.user != tt.wantUser || got.Addr() != tt.wantAddr {
+			t.Errorf("LoadUserConfig(%q) = %s@%s, want %s@%s", tt.host, got.user, got.Addr(), tt.wantUser, tt.wantAddr)
+		}
+	}
+	// Settings other than the user and port still come from the matching
+	// stanza
+	got, err := LoadUserConfig("admin@10.0.0.5:2222", Paths{Config: configFile})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, ".ssh", "deploy_key"); got.identityFile != want {
+		t.Errorf("LoadUserConfig() identityFile = %q, want %q", got.identityFile, want)
+	}
+
+	for _, host := range []string{"@10.0.0.5", "10.0.0.5:0", "10.0.0.5:ssh"} {
+		if _, err := LoadUserConfig(host, Paths{Config: configFile}); err == nil {
+			t.Errorf("LoadUserConfig(%q) expected error, got nil", host)
+		}
+	}
+}
+
+func TestOptions_Resolve(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	keyFile := filepath.Join(tmpDir, "deploy_key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write test key file: %v", err)
+	}
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := "Host webserver\n  HostName example.com\n  Port 2222\n  User deploy\n  IdentityFile " + keyFile + "\n"
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	opts := Options{Paths: Paths{Config: configFile}, Timeout: 5 * time.Second, Insecure: true}
+	target, err := opts.Resolve("webserver")
+	if err != nil {
+		t.Fatalf("Resolve() unexpected error = %v", err)
+	}
+	if target.Addr != "example.com:2222" {
+		t.Errorf("Resolve() addr = %q, want example.com:2222", target.Addr)
+	}
+	if target.Config.User != "deploy" || target.Config.Timeout != 5*time.Second {
+		t.Errorf("Resolve() config user = %q, timeout = %v, want deploy and 5s", target.Config.User, target.Config.Timeout)
+	}
+
+	opts.Paths.Config = filepath.Join(tmpDir, "missing")
+	if _, err := opts.Resolve("webserver"); err == nil {
+		t.Error("Resolve() expected error for a missing SSH config, got nil")
+	}
+}
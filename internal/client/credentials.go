@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Credentials is the key material used to authenticate with a private key.
+type Credentials struct {
+	// Key is the PEM encoded private key. When nil, the key is read from the
+	// identity file.
+	Key []byte
+	// Passphrase decrypts Key, if it is encrypted.
+	Passphrase []byte
+	// Certificate is an OpenSSH user certificate for Key, in authorized_keys
+	// format, presented instead of the bare key.
+	Certificate []byte
+}
+
+// CredentialProvider supplies the private key, or the passphrase protecting
+// it, for a connection, so that key material need not be stored in a
+// plaintext file.
+type CredentialProvider interface {
+	Credentials(userConfig *UserConfig) (Credentials, error)
+}
+
+// Names of the credential helpers accepted by NewCredentialHelper.
+const (
+	CredentialHelperKeychain  = "keychain"
+	CredentialHelperLibsecret = "libsecret"
+)
+
+// credentialService is the service under which passphrases are stored in
+// the macOS Keychain and in libsecret.
+const credentialService = "podman-cli"
+
+// NewCredentialHelper returns the provider for a named credential helper.
+// Both helpers look up the passphrase of the identity file, stored with
+// the "podman-cli" service and the identity file's path as account:
+//
+//   - keychain: the macOS Keychain, added with
+//     security add-generic-password -s podman-cli -a <identity file> -w
+//   - libsecret: the Secret Service of Linux desktops, added with
+//     secret-tool store --label=podman-cli service podman-cli identity <identity file>
+func NewCredentialHelper(name string) (CredentialProvider, error) {
+	switch name {
+	case CredentialHelperKeychain:
+		return passphraseHelper{"security", "find-generic-password", "-s", credentialService, "-w", "-a"}, nil
+	case CredentialHelperLibsecret:
+		return passphraseHelper{"secret-tool", "lookup", "service", credentialService, "identity"}, nil
+	}
+	return nil, fmt.Errorf("unknown credential helper %q (use %s or %s)", name, CredentialHelperKeychain, CredentialHelperLibsecret)
+}
+
+// passphraseHelper runs a program, given the identity file as last
+// argument, that prints the identity file's passphrase.
+type passphraseHelper []string
+
+func (h passphraseHelper) Credentials(userConfig *UserConfig) (Credentials, error) {
+	cmd := exec.Command(h[0], append(h[1:], userConfig.identityFile)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%s: looking up passphrase of %s: %w", h[0], userConfig.identityFile, err)
+	}
+	return Credentials{Passphrase: trimNewline(out)}, nil
+}
+
+// CommandProvider runs a shell command to obtain key material. If the
+// command prints a PEM encoded private key, it is used instead of the
+// identity file; otherwise its output is the identity file's passphrase.
+//
+// The command runs with PODMAN_CLI_IDENTITY_FILE, PODMAN_CLI_SSH_HOST and
+// PODMAN_CLI_SSH_USER set to the identity file, the host name and the
+// remote user of the connection. Its standard input and error are those of
+// podman-cli, so that it can prompt the user.
+type CommandProvider struct {
+	Command string
+}
+
+func (p CommandProvider) Credentials(userConfig *UserConfig) (Credentials, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", p.Command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", p.Command)
+	}
+	cmd.Env = append(os.Environ(),
+		"PODMAN_CLI_IDENTITY_FILE="+userConfig.identityFile,
+		"PODMAN_CLI_SSH_HOST="+userConfig.hostName,
+		"PODMAN_CLI_SSH_USER="+userConfig.user,
+	)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("identity command: %w", err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(out), []byte("-----BEGIN ")) {
+		return Credentials{Key: out}, nil
+	}
+	return Credentials{Passphrase: trimNewline(out)}, nil
+}
+
+// trimNewline removes the line ending printed after a secret.
+func trimNewline(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestKey writes a new ed25519 private key, encrypted with passphrase
+// unless it is empty, and returns its path and public key.
+func writeTestKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("Failed to marshal private key: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write test key file: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert public key: %v", err)
+	}
+	return keyFile, sshPub
+}
+
+type staticProvider Credentials
+
+func (p staticProvider) Credentials(*UserConfig) (Credentials, error) {
+	return Credentials(p), nil
+}
+
+func TestLoadSigner(t *testing.T) {
+	plainFile, plainPub := writeTestKey(t, "")
+	encryptedFile, encryptedPub := writeTestKey(t, "s3cret")
+	plainKey, err := os.ReadFile(plainFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		creds    CredentialProvider
+		wantPub  ssh.PublicKey
+		wantFail bool
+	}{
+		{"identity file", plainFile, nil, plainPub, false},
+		{"encrypted without passphrase", encryptedFile, nil, nil, true},
+		{"encrypted with passphrase", encryptedFile, staticProvider{Passphrase: []byte("s3cret")}, encryptedPub, false},
+		{"wrong passphrase", encryptedFile, staticProvider{Passphrase: []byte("wrong")}, nil, true},
+		{"passphrase for plain key", plainFile, staticProvider{Passphrase: []byte("unused")}, plainPub, false},
+		{"key from provider", filepath.Join(t.TempDir(), "missing"), staticProvider{Key: plainKey}, plainPub, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := loadSigner(&UserConfig{identityFile: tt.file}, tt.creds)
+			if tt.wantFail {
+				if err == nil {
+					t.Fatal("loadSigner() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSigner() unexpected error = %v", err)
+			}
+			if got := signer.PublicKey().Marshal(); string(got) != string(tt.wantPub.Marshal()) {
+				t.Error("loadSigner() returned the wrong key")
+			}
+		})
+	}
+}
+
+func TestCommandProvider_Passphrase(t *testing.T) {
+	got, err := CommandProvider{Command: "echo s3cret"}.Credentials(&UserConfig{})
+	if err != nil {
+		t.Fatalf("Credentials() unexpected error = %v", err)
+	}
+	if got.Key != nil || string(got.Passphrase) != "s3cret" {
+		t.Errorf("Credentials() = %+v, want passphrase %q", got, "s3cret")
+	}
+}
+
+func TestCommandProvider_Key(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command")
+	}
+	keyFile, _ := writeTestKey(t, "")
+	uc := &UserConfig{identityFile: keyFile, hostName: "edge1", user: "core"}
+
+	got, err := CommandProvider{Command: `test "$PODMAN_CLI_SSH_HOST@$PODMAN_CLI_SSH_USER" = edge1@core && cat "$PODMAN_CLI_IDENTITY_FILE"`}.Credentials(uc)
+	if err != nil {
+		t.Fatalf("Credentials() unexpected error = %v", err)
+	}
+	if got.Passphrase != nil {
+		t.Errorf("Credentials() passphrase = %q, want none", got.Passphrase)
+	}
+	if _, err := ssh.ParsePrivateKey(got.Key); err != nil {
+		t.Errorf("Credentials() key does not parse: %v", err)
+	}
+}
+
+func TestCommandProvider_Failure(t *testing.T) {
+	if _, err := (CommandProvider{Command: "exit 3"}).Credentials(&UserConfig{}); err == nil {
+		t.Error("Credentials() expected error for failing command, got nil")
+	}
+}
+
+func TestNewCredentialHelper(t *testing.T) {
+	for _, name := range []string{CredentialHelperKeychain, CredentialHelperLibsecret} {
+		if _, err := NewCredentialHelper(name); err != nil {
+			t.Errorf("NewCredentialHelper(%q) unexpected error = %v", name, err)
+		}
+	}
+	if _, err := NewCredentialHelper("vault"); err == nil {
+		t.Error("NewCredentialHelper() expected error for unknown helper, got nil")
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Exec runs cmd on the remote host in a new SSH session, outside of the
+// Podman API. The session's standard streams are connected to stdin, stdout
+// and stderr; any of them may be nil.
+//
+// A command that runs but exits with a non-zero status returns an
+// *ssh.ExitError.
+func Exec(sshClient *ssh.Client, cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return ExecContext(context.Background(), sshClient, cmd, stdin, stdout, stderr)
+}
+
+// ExecContext is like Exec, but ends the command when ctx is done, asking
+// it to terminate and closing the session, and then returns ctx's error.
+func ExecContext(ctx context.Context, sshClient *ssh.Client, cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Many servers ignore signals, so the session is closed as well
+		session.Signal(ssh.SIGTERM)
+		return ctx.Err()
+	}
+}
+
+// ShellQuote quotes s so that it is interpreted literally by a POSIX shell
+// on the remote host.
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// execHandler implements a remote command for the test server. It returns
+// the command's exit status.
+type execHandler func(cmd string, stdin io.Reader, stdout, stderr io.Writer) uint32
+
+// serveExec accepts newCh, a session channel, and calls run with the
+// command of its exec request, replying with the exit status run returns.
+// Other channel types are rejected.
+func serveExec(newCh ssh.NewChannel, run func(cmd string, ch ssh.Channel) uint32) {
+	if newCh.ChannelType() != "session" {
+		newCh.Reject(ssh.UnknownChannelType, "only sessions are supported")
+		return
+	}
+	ch, requests, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	go func() {
+		defer ch.Close()
+		for req := range requests {
+			if req.Type != "exec" {
+				req.Reply(false, nil)
+				continue
+			}
+			// The payload is a uint32 length-prefixed string
+			cmd := string(req.Payload[4:])
+			req.Reply(true, nil)
+
+			status := run(cmd, ch)
+			ch.CloseWrite()
+			ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+			return
+		}
+	}()
+}
+
+// startExecServer starts a test SSH server running handler for exec
+// requests and returns a connected client.
+func startExecServer(t *testing.T, handler execHandler) *ssh.Client {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	t.Cleanup(func() { listener.Close() })
+	go testserver.ServeSSH(listener, serverConfig, func(_ *ssh.ServerConn, chans <-chan ssh.NewChannel) {
+		for newCh := range chans {
+			serveExec(newCh, func(cmd string, ch ssh.Channel) uint32 {
+				return handler(cmd, ch, ch, ch.Stderr())
+			})
+		}
+	})
+
+	sshClient, err := NewSSHClient(addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("NewSSHClient() unexpected error = %v", err)
+	}
+	t.Cleanup(func() { sshClient.Close() })
+	return sshClient
+}
+
+func TestExec_StreamsAndExitStatus(t *testing.T) {
+	sshClient := startExecServer(t, func(cmd string, stdin io.Reader, stdout, stderr io.Writer) uint32 {
+		switch cmd {
+		case "cat":
+			io.Copy(stdout, stdin)
+			return 0
+		case "fail":
+			io.WriteString(stderr, "boom\n")
+			return 3
+		}
+		return 127
+	})
+
+	var stdout bytes.Buffer
+	if err := Exec(sshClient, "cat", strings.NewReader("hello\n"), &stdout, nil); err != nil {
+		t.Fatalf("Exec(cat) unexpected error = %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("Exec(cat) stdout = %q, want %q", stdout.String(), "hello\n")
+	}
+
+	var stderr bytes.Buffer
+	err := Exec(sshClient, "fail", nil, nil, &stderr)
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("Exec(fail) error = %v, want exit status 3", err)
+	}
+	if stderr.String() != "boom\n" {
+		t.Errorf("Exec(fail) stderr = %q, want %q", stderr.String(), "boom\n")
+	}
+}
+
+func TestExecContext_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	sshClient := startExecServer(t, func(cmd string, stdin io.Reader, stdout, stderr io.Writer) uint32 {
+		io.WriteString(stdout, "started\n")
+		<-release
+		return 0
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := ExecContext(ctx, sshClient, "sleep", nil, io.Discard, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExecContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ExecContext() returned after %v, want it to end with ctx", elapsed)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"simple", "simple"},
+		{"/path/to/file.container", "/path/to/file.container"},
+		{"", "''"},
+		{"with space", "'with space'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	}
+
+	for _, tt := range tests {
+		if got := ShellQuote(tt.in); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
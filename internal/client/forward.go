@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// Forward accepts connections on listener and proxies each one to a new
+// connection obtained from dial, copying data in both directions until either
+// side closes.
+//
+// Forward blocks until ctx is cancelled, in which case it closes the listener
+// and returns nil, or until accepting a connection fails.
+func Forward(ctx context.Context, listener net.Listener, dial func() (net.Conn, error)) error {
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer local.Close()
+
+			remote, err := dial()
+			if err != nil {
+				slog.Error("dial remote socket", "err", err)
+				return
+			}
+			defer remote.Close()
+
+			// Tear down in-flight connections on shutdown
+			stop := context.AfterFunc(ctx, func() {
+				local.Close()
+				remote.Close()
+			})
+			defer stop()
+
+			proxy(local, remote)
+		}()
+	}
+}
+
+// proxy copies data between a and b until both directions are finished.
+// When one direction reaches EOF the write side of the peer is closed, if
+// supported, so half-closed HTTP connections behave correctly.
+func proxy(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	copyAndClose := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	go copyAndClose(a, b)
+	go copyAndClose(b, a)
+	wg.Wait()
+}
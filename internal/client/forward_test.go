@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startEchoServer starts a TCP server that echoes back everything it reads.
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestForward_ProxiesData(t *testing.T) {
+	echo := startEchoServer(t)
+	defer echo.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Forward(ctx, listener, func() (net.Conn, error) {
+			return net.Dial("tcp", echo.Addr().String())
+		})
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial forwarder: %v", err)
+	}
+
+	msg := []byte("GET /_ping HTTP/1.1\r\n\r\n")
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got := make([]byte, len(msg))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("ReadFull() error = %v", err)
+	}
+	if string(got) != string(msg) {
+		t.Errorf("Forward() echoed %q, want %q", got, msg)
+	}
+	conn.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Forward() error = %v, want nil after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Forward() did not return after context was cancelled")
+	}
+}
+
+func TestForward_DialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Forward(ctx, listener, func() (net.Conn, error) {
+		return nil, errors.New("no remote")
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	// The forwarder should close the local connection when the remote dial fails
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() error = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// NewHTTPClient returns an http.Client that sends every request over a
+// connection obtained from dial, typically a Unix socket tunneled through
+// SSH. Request URLs should use "localhost" as the host; the address is only
+// used for the Host header. Compressed responses are negotiated and
+// decoded as described for DecompressTransport.
+func NewHTTPClient(dial func() (net.Conn, error)) *http.Client {
+	return &http.Client{
+		Transport: DecompressTransport(&http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dial()
+			},
+			DisableCompression: true,
+		}),
+	}
+}
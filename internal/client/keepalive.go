@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultKeepAliveInterval is how often keepalive requests are sent when no
+// interval is configured.
+const DefaultKeepAliveInterval = 30 * time.Second
+
+// keepAliveMaxMissed is the number of consecutive unanswered keepalive
+// requests after which the connection is considered dead, matching
+// OpenSSH's default ServerAliveCountMax.
+const keepAliveMaxMissed = 3
+
+// KeepAlive sends "keepalive@openssh.com" global requests on conn every
+// interval until ctx is done or the connection is closed.
+//
+// If keepAliveMaxMissed consecutive requests fail or go unanswered within an
+// interval, the connection is closed so that callers blocked reading from it
+// observe an error and can reconnect. A non-positive interval disables
+// keepalives.
+func KeepAlive(ctx context.Context, conn ssh.Conn, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-reply:
+			if err != nil {
+				missed++
+			} else {
+				missed = 0
+			}
+		case <-time.After(interval):
+			missed++
+		}
+
+		if missed >= keepAliveMaxMissed {
+			conn.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeConn is an ssh.Conn whose global requests always fail.
+type fakeConn struct {
+	ssh.Conn
+
+	mu       sync.Mutex
+	requests int
+	closed   bool
+}
+
+func (c *fakeConn) SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	return false, nil, errors.New("connection lost")
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func TestKeepAlive_ClosesDeadConnection(t *testing.T) {
+	conn := &fakeConn{}
+
+	done := make(chan struct{})
+	go func() {
+		KeepAlive(context.Background(), conn, 10*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("KeepAlive() did not return for a dead connection")
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if !conn.closed {
+		t.Error("KeepAlive() did not close the dead connection")
+	}
+	if conn.requests != keepAliveMaxMissed {
+		t.Errorf("KeepAlive() sent %d requests, want %d", conn.requests, keepAliveMaxMissed)
+	}
+}
+
+func TestKeepAlive_Disabled(t *testing.T) {
+	conn := &fakeConn{}
+
+	done := make(chan struct{})
+	go func() {
+		KeepAlive(context.Background(), conn, 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("KeepAlive() with zero interval did not return immediately")
+	}
+
+	if conn.requests != 0 {
+		t.Errorf("KeepAlive() sent %d requests, want 0", conn.requests)
+	}
+}
+
+func TestKeepAlive_StopsOnCancel(t *testing.T) {
+	conn := &fakeConn{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		KeepAlive(ctx, conn, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("KeepAlive() did not return after context was cancelled")
+	}
+
+	if conn.closed {
+		t.Error("KeepAlive() closed the connection after cancel")
+	}
+}
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/kevinburke/ssh_config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultGlobalKnownHosts are the system-wide known_hosts files read when
+// GlobalKnownHostsFile is not set.
+var defaultGlobalKnownHosts = globalKnownHostsFiles()
+
+// globalKnownHostsFiles returns the system-wide known_hosts files of
+// OpenSSH on this platform.
+func globalKnownHostsFiles() []string {
+	if runtime.GOOS == "windows" {
+		return []string{filepath.Join(os.Getenv("ProgramData"), "ssh", "ssh_known_hosts")}
+	}
+	return []string{"/etc/ssh/ssh_known_hosts", "/etc/ssh/ssh_known_hosts2"}
+}
+
+// knownHostsFiles returns the known_hosts files of userConfig that exist,
+// starting with the one keys are added to. Missing files are skipped, as
+// the system-wide ones usually are, unless none exists, in which case the
+// first is returned for the error to name it.
+func (uc *UserConfig) knownHostsFiles() []string {
+	var files []string
+	for _, f := range append([]string{uc.knownHosts}, uc.readOnlyKnownHosts...) {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return []string{uc.knownHosts}
+	}
+	return files
+}
+
+// knownHostsCallback returns the callback verifying host keys against the
+// known_hosts files of userConfig. Hashed entries (HashKnownHosts yes) and
+// hosts on other ports than 22, recorded as [host]:port, are matched as
+// OpenSSH does. Host certificates signed by a key marked @cert-authority
+// for the host are accepted, and keys marked @revoked rejected.
+//
+// With StrictHostKeyChecking accept-new, or no, the key of a host missing
+// from the file is trusted on first use and appended to it, hashed if
+// HashKnownHosts is set. A key differing from the one recorded for the host
+// is always rejected.
+func knownHostsCallback(userConfig *UserConfig) (ssh.HostKeyCallback, error) {
+	path := userConfig.knownHosts
+	acceptNew := userConfig.acceptNewHostKeys()
+
+	if acceptNew {
+		// The file is created along with the first key accepted
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return nil, err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, err
+			}
+			f.Close()
+		}
+	}
+
+	verify, err := knownhosts.New(userConfig.knownHostsFiles()...)
+	if err != nil || !acceptNew {
+		return verify, err
+	}
+
+	// Keys accepted by this callback, which knownhosts does not see as it
+	// read the file beforehand
+	var mu sync.Mutex
+	accepted := map[string]ssh.PublicKey{}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := verify(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		address := knownhosts.Normalize(hostname)
+		if known, ok := accepted[address]; ok {
+			if string(known.Marshal()) == string(key.Marshal()) {
+				return nil
+			}
+			return fmt.Errorf("host key of %s changed while connecting", address)
+		}
+		if err := appendKnownHost(path, address, key, userConfig.hashKnownHosts); err != nil {
+			return fmt.Errorf("add %s to %s: %w", address, path, err)
+		}
+		accepted[address] = key
+		return nil
+	}, nil
+}
+
+// appendKnownHost adds the key of the host at address, as normalized by
+// knownhosts.Normalize, to the known_hosts file at path, hashing the host
+// name if hash is set.
+func appendKnownHost(path, address string, key ssh.PublicKey, hash bool) error {
+	if hash {
+		address = knownhosts.HashHostname(address)
+	}
+	line := knownhosts.Line([]string{address}, key)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	// Start on a new line if the file does not end with one
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// acceptNewHostKeys reports whether StrictHostKeyChecking lets keys of
+// unknown hosts be added to known_hosts.
+func (uc *UserConfig) acceptNewHostKeys() bool {
+	switch strings.ToLower(uc.strictHostKeyChecking) {
+	case "accept-new", "no", "off":
+		return true
+	}
+	return false
+}
+
+// hostKeyAlgorithms lists, in order of preference, the host key algorithms
+// offered for each type of key recorded in known_hosts.
+var hostKeyAlgorithms = []struct{ keyType, algorithm string }{
+	{ssh.KeyAlgoED25519, ssh.KeyAlgoED25519},
+	{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA256},
+	{ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA384},
+	{ssh.KeyAlgoECDSA521, ssh.KeyAlgoECDSA521},
+	{ssh.KeyAlgoRSA, ssh.KeyAlgoRSASHA512},
+	{ssh.KeyAlgoRSA, ssh.KeyAlgoRSASHA256},
+	{ssh.KeyAlgoRSA, ssh.KeyAlgoRSA},
+}
+
+// knownHostKeyAlgorithms returns the host key algorithms matching the keys
+// recorded for addr in the known_hosts files, so that a server with
+// several host keys presents one that can be verified, as OpenSSH does.
+// Certificate algorithms come first when a @cert-authority entry matches
+// the host. It returns nil, leaving the defaults in place, when no usable
+// key is recorded.
+func knownHostKeyAlgorithms(files []string, addr string) []string {
+	verify, err := knownhosts.New(files...)
+	if err != nil {
+		return nil
+	}
+	// A key no entry matches makes the callback list those of the host
+	var keyErr *knownhosts.KeyError
+	if !errors.As(verify(addr, &net.TCPAddr{IP: net.IPv4zero}, probeKey{}), &keyErr) {
+		return nil
+	}
+	types := map[string]bool{}
+	for _, known := range keyErr.Want {
+		types[known.Key.Type()] = true
+	}
+	var algorithms []string
+	if len(types) > 0 && hasHostAuthority(files, addr) {
+		algorithms = append(algorithms, hostCertAlgorithms...)
+	}
+	for _, a := range hostKeyAlgorithms {
+		if types[a.keyType] {
+			algorithms = append(algorithms, a.algorithm)
+		}
+	}
+	return algorithms
+}
+
+// hostCertAlgorithms are the host certificate algorithms, in order of
+// preference.
+var hostCertAlgorithms = []string{
+	ssh.CertAlgoED25519v01,
+	ssh.CertAlgoECDSA256v01,
+	ssh.CertAlgoECDSA384v01,
+	ssh.CertAlgoECDSA521v01,
+	ssh.CertAlgoRSASHA512v01,
+	ssh.CertAlgoRSASHA256v01,
+}
+
+// hasHostAuthority reports whether a @cert-authority entry of the
+// known_hosts files applies to addr.
+func hasHostAuthority(files []string, addr string) bool {
+	host := knownhosts.Normalize(addr)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		for len(data) > 0 {
+			marker, hosts, _, _, rest, err := ssh.ParseKnownHosts(data)
+			if err != nil {
+				break
+			}
+			data = rest
+			if marker == "cert-authority" && matchKnownHost(hosts, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchKnownHost reports whether host, normalized as by knownhosts.Normalize,
+// matches the host patterns of a known_hosts entry: names with the * and ?
+// wildcards, negated with a leading !, or names hashed as |1|salt|hash.
+func matchKnownHost(patterns []string, host string) bool {
+	var h ssh_config.Host
+	for _, p := range patterns {
+		if salt, hash, ok := strings.Cut(strings.TrimPrefix(p, "|1|"), "|"); ok && strings.HasPrefix(p, "|1|") {
+			if hashedHostMatches(salt, hash, host) {
+				return true
+			}
+			continue
+		}
+		pattern, err := ssh_config.NewPattern(p)
+		if err != nil {
+			continue
+		}
+		h.Patterns = append(h.Patterns, pattern)
+	}
+	return h.Matches(host)
+}
+
+// hashedHostMatches reports whether host hashes to the base64 encoded hash
+// with the base64 encoded salt, as HashKnownHosts records host names.
+func hashedHostMatches(salt, hash, host string) bool {
+	key, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, key)
+	mac.Write([]byte(host))
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// probeKey is a public key matching no known_hosts entry.
+type probeKey struct{}
+
+func (probeKey) Type() string                                 { return "probe" }
+func (probeKey) Marshal() []byte                              { return []byte("probe") }
+func (probeKey) Verify(data []byte, sig *ssh.Signature) error { return errors.ErrUnsupported }
@@ -0,0 +1,343 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	return key
+}
+
+func TestKnownHostsCallback_Hashed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	key := newTestHostKey(t)
+	lines := knownhosts.Line([]string{knownhosts.HashHostname("example.com")}, key) + "\n" +
+		knownhosts.Line([]string{knownhosts.HashHostname("[example.com]:2222")}, key) + "\n"
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	verify, err := knownHostsCallback(&UserConfig{knownHosts: path})
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	for _, host := range []string{"example.com:22", "example.com:2222"} {
+		if err := verify(host, remote, key); err != nil {
+			t.Errorf("verify(%q) unexpected error = %v", host, err)
+		}
+	}
+	if err := verify("example.com:2200", remote, key); err == nil {
+		t.Error("verify() of an unknown port expected an error")
+	}
+}
+
+func TestKnownHostsCallback_AcceptNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	userConfig := &UserConfig{knownHosts: path, strictHostKeyChecking: "accept-new", hashKnownHosts: true}
+	key := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2222}
+
+	verify, err := knownHostsCallback(userConfig)
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	if err := verify("example.com:2222", remote, key); err != nil {
+		t.Fatalf("verify() of a new host unexpected error = %v", err)
+	}
+	// A redial reuses the key accepted, and any other is rejected
+	if err := verify("example.com:2222", remote, key); err != nil {
+		t.Errorf("verify() on redial unexpected error = %v", err)
+	}
+	if err := verify("example.com:2222", remote, newTestHostKey(t)); err == nil {
+		t.Error("verify() of another key expected an error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read known_hosts: %v", err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 1 || !strings.HasPrefix(string(data), "|1|") {
+		t.Fatalf("known_hosts = %q, want a single hashed entry", data)
+	}
+
+	// The entry written verifies the host in later runs
+	userConfig.strictHostKeyChecking = ""
+	verify, err = knownHostsCallback(userConfig)
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	if err := verify("example.com:2222", remote, key); err != nil {
+		t.Errorf("verify() of the added host unexpected error = %v", err)
+	}
+	if err := verify("example.com:22", remote, key); err == nil {
+		t.Error("verify() on the default port expected an error")
+	}
+}
+
+func TestKnownHostsCallback_ChangedKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{"example.com"}, newTestHostKey(t))), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	verify, err := knownHostsCallback(&UserConfig{knownHosts: path, strictHostKeyChecking: "no"})
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	err = verify("example.com:22", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}, newTestHostKey(t))
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		t.Errorf("verify() of a changed key error = %v, want a key mismatch", err)
+	}
+}
+
+func TestKnownHostsCallback_UnknownHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	for _, strict := range []string{"", "ask", "yes"} {
+		verify, err := knownHostsCallback(&UserConfig{knownHosts: path, strictHostKeyChecking: strict})
+		if err != nil {
+			t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+		}
+		if err := verify("example.com:22", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}, newTestHostKey(t)); err == nil {
+			t.Errorf("StrictHostKeyChecking %q: verify() of an unknown host expected an error", strict)
+		}
+	}
+	if data, _ := os.ReadFile(path); len(data) > 0 {
+		t.Errorf("known_hosts = %q, want it unchanged", data)
+	}
+}
+
+func TestLoadUserConfig_HostKeyChecking(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host webserver
+  StrictHostKeyChecking accept-new
+  HashKnownHosts yes
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	got, err := LoadUserConfig("webserver", Paths{Config: configFile})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+	if !got.acceptNewHostKeys() || !got.hashKnownHosts {
+		t.Errorf("LoadUserConfig() strictHostKeyChecking = %q, hashKnownHosts = %v, want accept-new and true",
+			got.strictHostKeyChecking, got.hashKnownHosts)
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaPub, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	lines := knownhosts.Line([]string{"example.com"}, newTestHostKey(t)) + "\n" +
+		knownhosts.Line([]string{"example.com"}, rsaPub) + "\n" +
+		knownhosts.Line([]string{"[legacy.example.com]:2222"}, rsaPub) + "\n"
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"example.com:22", "ssh-ed25519,rsa-sha2-512,rsa-sha2-256,ssh-rsa"},
+		{"legacy.example.com:2222", "rsa-sha2-512,rsa-sha2-256,ssh-rsa"},
+		{"unknown.example.com:22", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(knownHostKeyAlgorithms([]string{path}, tt.addr), ","); got != tt.want {
+			t.Errorf("knownHostKeyAlgorithms(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+	if got := knownHostKeyAlgorithms([]string{filepath.Join(t.TempDir(), "missing")}, "example.com:22"); got != nil {
+		t.Errorf("knownHostKeyAlgorithms() without known_hosts = %q, want nil", got)
+	}
+}
+
+func TestKnownHostKeyAlgorithms_SeveralHostKeys(t *testing.T) {
+	// The server offers an RSA key, which the client prefers by default,
+	// but only its ed25519 key is known
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	serverConfig.AddHostKey(signer)
+	startTestSSHServer(t, listener, serverConfig)
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, signer.PublicKey())
+	if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+	verify, err := knownHostsCallback(&UserConfig{knownHosts: path})
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:              "testuser",
+		Auth:              []ssh.AuthMethod{ssh.Password("testpass")},
+		HostKeyCallback:   verify,
+		HostKeyAlgorithms: knownHostKeyAlgorithms([]string{path}, addr),
+	}
+	client, err := NewSSHClient(addr, clientConfig)
+	if err != nil {
+		t.Fatalf("NewSSHClient() unexpected error = %v", err)
+	}
+	client.Close()
+}
+
+func TestLoadUserConfig_KnownHostsFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	configData := `Host webserver
+  UserKnownHostsFile ~/.ssh/known_hosts ~/.ssh/known_hosts.team
+  GlobalKnownHostsFile /etc/ssh/fleet_known_hosts
+`
+	if err := os.WriteFile(configFile, []byte(configData), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	setHomeDir(t, tmpDir)
+
+	got, err := LoadUserConfig("webserver", Paths{Config: configFile})
+	if err != nil {
+		t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, ".ssh", "known_hosts"); got.knownHosts != want {
+		t.Errorf("LoadUserConfig() knownHosts = %q, want %q", got.knownHosts, want)
+	}
+	want := []string{filepath.Join(tmpDir, ".ssh", "known_hosts.team"), filepath.FromSlash("/etc/ssh/fleet_known_hosts")}
+	if strings.Join(got.readOnlyKnownHosts, "|") != strings.Join(want, "|") {
+		t.Errorf("LoadUserConfig() readOnlyKnownHosts = %q, want %q", got.readOnlyKnownHosts, want)
+	}
+}
+
+func TestKnownHostsCallback_SeveralFiles(t *testing.T) {
+	dir := t.TempDir()
+	team, global := filepath.Join(dir, "team"), filepath.Join(dir, "global")
+	key, revoked := newTestHostKey(t), newTestHostKey(t)
+	if err := os.WriteFile(team, []byte(knownhosts.Line([]string{"example.com"}, key)+"\n"+
+		knownhosts.Line([]string{"legacy.example.com"}, revoked)+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+	if err := os.WriteFile(global, []byte("@revoked * "+strings.TrimSpace(string(ssh.MarshalAuthorizedKey(revoked)))+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	// The user file is missing, as it may be when keys come from elsewhere
+	userConfig := &UserConfig{knownHosts: filepath.Join(dir, "missing"), readOnlyKnownHosts: []string{team, global, filepath.Join(dir, "none")}}
+	verify, err := knownHostsCallback(userConfig)
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	if err := verify("example.com:22", remote, key); err != nil {
+		t.Errorf("verify() of a key in a second file unexpected error = %v", err)
+	}
+	var revokedErr *knownhosts.RevokedError
+	if err := verify("legacy.example.com:22", remote, revoked); !errors.As(err, &revokedErr) {
+		t.Errorf("verify() of a revoked key error = %v, want a RevokedError", err)
+	}
+}
+
+func TestKnownHostsCallback_CertAuthority(t *testing.T) {
+	_, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ca, err := ssh.NewSignerFromKey(caPriv)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	hostKey := newTestHostKey(t)
+	cert := &ssh.Certificate{Key: hostKey, CertType: ssh.HostCert, ValidPrincipals: []string{"web.example.com"}, ValidBefore: ssh.CertTimeInfinity}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("SignCert() unexpected error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	data := "@cert-authority *.example.com,!db.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.PublicKey()))) + "\n" +
+		knownhosts.Line([]string{"web.example.com"}, hostKey) + "\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	verify, err := knownHostsCallback(&UserConfig{knownHosts: path})
+	if err != nil {
+		t.Fatalf("knownHostsCallback() unexpected error = %v", err)
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	if err := verify("web.example.com:22", remote, cert); err != nil {
+		t.Errorf("verify() of a host certificate unexpected error = %v", err)
+	}
+
+	got := knownHostKeyAlgorithms([]string{path}, "web.example.com:22")
+	if len(got) == 0 || got[0] != ssh.CertAlgoED25519v01 || got[len(got)-1] != ssh.KeyAlgoED25519 {
+		t.Errorf("knownHostKeyAlgorithms() = %q, want certificates first, then ssh-ed25519", got)
+	}
+	if hasHostAuthority([]string{path}, "db.example.com:22") {
+		t.Error("hasHostAuthority() matched a negated host")
+	}
+}
+
+func TestMatchKnownHost(t *testing.T) {
+	hashed := knownhosts.HashHostname("[web.example.com]:2222")
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"web.example.com"}, "web.example.com", true},
+		{[]string{"*.example.com"}, "web.example.com", true},
+		{[]string{"*.example.com", "!db.example.com"}, "db.example.com", false},
+		{[]string{"web?.example.com"}, "web1.example.com", true},
+		{[]string{hashed}, "[web.example.com]:2222", true},
+		{[]string{hashed}, "web.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchKnownHost(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("matchKnownHost(%q, %q) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
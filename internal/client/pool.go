@@ -0,0 +1,278 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrPoolClosed is returned by Pool.Get once the pool has been closed.
+var ErrPoolClosed = errors.New("connection pool closed")
+
+// poolCheckTimeout is how long Pool.Get waits for a cached connection to
+// answer its keepalive request before dialing a new one.
+const poolCheckTimeout = 5 * time.Second
+
+// Pool caches SSH connections per destination, so that the commands run by
+// one process against a host share a single handshake. Cached connections
+// are checked with a keepalive request, to be answered within
+// poolCheckTimeout, before being handed out again, and are closed once
+// unused for the idle timeout.
+//
+// A Pool is safe for concurrent use.
+type Pool struct {
+	idleTimeout  time.Duration
+	checkTimeout time.Duration
+
+	mu      sync.Mutex
+	conns   map[string]*pooledConn
+	dialing map[string]*sync.Mutex
+	closed  bool
+}
+
+// pooledConn is a cached connection and the number of leases on it.
+type pooledConn struct {
+	client *ssh.Client
+	refs   int
+	idle   *time.Timer
+}
+
+// stopIdle cancels the idle timeout of pc, if running.
+func (pc *pooledConn) stopIdle() {
+	if pc.idle != nil {
+		pc.idle.Stop()
+		pc.idle = nil
+	}
+}
+
+// NewPool returns an empty Pool closing connections that have not been
+// used for idleTimeout. An idleTimeout of 0 keeps them until Close.
+func NewPool(idleTimeout time.Duration) *Pool {
+	return &Pool{
+		idleTimeout:  idleTimeout,
+		checkTimeout: poolCheckTimeout,
+		conns:        map[string]*pooledConn{},
+		dialing:      map[string]*sync.Mutex{},
+	}
+}
+
+// PoolKey returns the key identifying a destination in a Pool: the remote
+// user and address, and the proxy connected through, if any.
+func PoolKey(user, addr string, proxy *Proxy) string {
+	key := user + "@" + addr
+	if proxy != nil {
+		key += " via " + proxy.String()
+	}
+	return key
+}
+
+// Lease is a connection borrowed from a Pool. Closing it returns the
+// connection to the pool instead of closing it.
+type Lease struct {
+	*ssh.Client
+
+	once    sync.Once
+	release func() error
+	discard func() // drops the connection from its pool, nil outside of one
+	err     error
+}
+
+// NewLease returns a lease on sshClient outside of any pool, whose Close
+// closes the connection.
+func NewLease(sshClient *ssh.Client) *Lease {
+	return &Lease{Client: sshClient, release: sshClient.Close}
+}
+
+// Close returns the connection to the pool. It is safe to call more than
+// once.
+func (l *Lease) Close() error {
+	l.once.Do(func() { l.err = l.release() })
+	return l.err
+}
+
+// Discard closes the connection and drops it from the pool, for one that
+// is stuck, so that the next Get dials a new one; other leases on it fail.
+// The lease is released as by Close.
+func (l *Lease) Discard() error {
+	if l.discard != nil {
+		l.once.Do(func() {
+			l.discard()
+			l.err = l.release()
+		})
+		return l.err
+	}
+	return l.Close()
+}
+
+// Get returns a lease on the cached connection for key, dialing one with
+// dial if there is none or the cached one no longer answers. Concurrent
+// calls for the same key share a single dial.
+//
+// A nil Pool caches nothing: each call dials a connection of its own,
+// closed with its lease.
+func (p *Pool) Get(key string, dial func() (*ssh.Client, error)) (*Lease, error) {
+	if p == nil {
+		sshClient, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		return NewLease(sshClient), nil
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	dialMu := p.dialing[key]
+	if dialMu == nil {
+		dialMu = &sync.Mutex{}
+		p.dialing[key] = dialMu
+	}
+	p.mu.Unlock()
+
+	dialMu.Lock()
+	defer dialMu.Unlock()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	pc := p.conns[key]
+	if pc != nil {
+		pc.refs++
+		pc.stopIdle()
+	}
+	p.mu.Unlock()
+
+	if pc != nil {
+		if answers(pc.client, p.checkTimeout) {
+			return p.lease(key, pc), nil
+		}
+		p.remove(key, pc)
+	}
+
+	sshClient, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		sshClient.Close()
+		return nil, ErrPoolClosed
+	}
+	pc = &pooledConn{client: sshClient, refs: 1}
+	p.conns[key] = pc
+	p.mu.Unlock()
+
+	go func() {
+		sshClient.Wait()
+		p.remove(key, pc)
+	}()
+	return p.lease(key, pc), nil
+}
+
+// answers reports whether conn answers a keepalive request within
+// timeout. The request of a connection that does not is left to end when
+// the caller closes it.
+func answers(conn ssh.Conn, timeout time.Duration) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-timer.C:
+		return false
+	}
+}
+
+// lease returns a Lease on pc, whose reference is already counted.
+func (p *Pool) lease(key string, pc *pooledConn) *Lease {
+	return &Lease{Client: pc.client, release: func() error {
+		p.release(key, pc)
+		return nil
+	}, discard: func() { p.remove(key, pc) }}
+}
+
+// release drops a reference to pc, starting its idle timeout when it was
+// the last one.
+func (p *Pool) release(key string, pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pc.refs--
+	if pc.refs > 0 || p.conns[key] != pc || p.idleTimeout <= 0 {
+		return
+	}
+	pc.idle = time.AfterFunc(p.idleTimeout, func() {
+		p.mu.Lock()
+		expired := pc.refs == 0 && p.conns[key] == pc
+		if expired {
+			delete(p.conns, key)
+		}
+		p.mu.Unlock()
+		if expired {
+			pc.client.Close()
+		}
+	})
+}
+
+// remove closes pc and evicts it from the pool, if it is still cached for
+// key. Leases on pc then fail as their connection is closed.
+func (p *Pool) remove(key string, pc *pooledConn) {
+	p.mu.Lock()
+	if p.conns[key] == pc {
+		delete(p.conns, key)
+	}
+	pc.stopIdle()
+	p.mu.Unlock()
+	pc.client.Close()
+}
+
+// Cached reports whether a connection is cached for key, without checking
+// that it still answers.
+func (p *Pool) Cached(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conns[key] != nil
+}
+
+// Len returns the number of cached connections.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// Close closes all cached connections, including those leased. Subsequent
+// calls to Get return ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = map[string]*pooledConn{}
+	for _, pc := range conns {
+		pc.stopIdle()
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, pc := range conns {
+		if err := pc.client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
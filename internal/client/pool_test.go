@@ -0,0 +1,291 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// countingDial returns a dial function connecting to addr and the number
+// of connections it made.
+func countingDial(addr string) (func() (*ssh.Client, error), *atomic.Int32) {
+	var dials atomic.Int32
+	return func() (*ssh.Client, error) {
+		dials.Add(1)
+		return NewSSHClient(addr, testClientConfig())
+	}, &dials
+}
+
+func TestPool_ReusesConnection(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	p := NewPool(time.Hour)
+	defer p.Close()
+	dial, dials := countingDial(addr)
+
+	var wg sync.WaitGroup
+	leases := make([]*Lease, 8)
+	for i := range leases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease, err := p.Get("edge1", dial)
+			if err != nil {
+				t.Errorf("Get() unexpected error = %v", err)
+				return
+			}
+			leases[i] = lease
+		}()
+	}
+	wg.Wait()
+
+	if n := dials.Load(); n != 1 {
+		t.Errorf("Get() dialed %d times for concurrent callers, want 1", n)
+	}
+	for _, lease := range leases {
+		if lease != nil {
+			lease.Close()
+		}
+	}
+
+	lease, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	defer lease.Close()
+	if n := dials.Load(); n != 1 {
+		t.Errorf("Get() dialed again for a released connection (%d dials)", n)
+	}
+	if p.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", p.Len())
+	}
+}
+
+func TestPool_RedialsDeadConnection(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	conns := make(chan *ssh.ServerConn, 2)
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(conns))
+
+	p := NewPool(time.Hour)
+	defer p.Close()
+	dial, dials := countingDial(addr)
+
+	first, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	first.Close()
+
+	// Drop the connection from the server side
+	(<-conns).Close()
+	first.Wait()
+
+	second, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error after drop = %v", err)
+	}
+	defer second.Close()
+	if second.Client == first.Client || dials.Load() != 2 {
+		t.Error("Get() returned the dropped connection")
+	}
+}
+
+func TestPool_Discard(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	p := NewPool(time.Hour)
+	defer p.Close()
+	dial, dials := countingDial(addr)
+
+	first, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	if err := first.Discard(); err != nil {
+		t.Fatalf("Discard() unexpected error = %v", err)
+	}
+	if p.Cached("edge1") {
+		t.Error("Cached() = true after Discard(), want the connection dropped")
+	}
+
+	second, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error after Discard() = %v", err)
+	}
+	defer second.Close()
+	if second.Client == first.Client || dials.Load() != 2 {
+		t.Error("Get() returned the discarded connection")
+	}
+}
+
+func TestPool_UnansweredCheck(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sconn, chans, _, err := ssh.NewServerConn(conn, serverConfig)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				defer sconn.Close()
+				// Global requests, such as keepalives, are never answered,
+				// as by a host that stopped responding
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+
+	p := NewPool(time.Hour)
+	defer p.Close()
+	p.checkTimeout = 50 * time.Millisecond
+	dial, dials := countingDial(addr)
+
+	first, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	first.Close()
+
+	done := make(chan *Lease)
+	go func() {
+		second, err := p.Get("edge1", dial)
+		if err != nil {
+			t.Errorf("Get() unexpected error = %v", err)
+		}
+		done <- second
+	}()
+	select {
+	case second := <-done:
+		if second != nil {
+			defer second.Close()
+			if second.Client == first.Client || dials.Load() != 2 {
+				t.Error("Get() returned the connection not answering")
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get() hangs on a connection not answering")
+	}
+}
+
+func TestPool_IdleTimeout(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	p := NewPool(20 * time.Millisecond)
+	defer p.Close()
+	dial, _ := countingDial(addr)
+
+	lease, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+
+	// Leased connections are never evicted
+	time.Sleep(50 * time.Millisecond)
+	if p.Len() != 1 {
+		t.Fatal("idle timeout evicted a leased connection")
+	}
+
+	lease.Close()
+	lease.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection was not evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, err := lease.Client.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+		t.Error("evicted connection is still open")
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	p := NewPool(0)
+	dial, _ := countingDial(addr)
+
+	lease, err := p.Get("edge1", dial)
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() unexpected error = %v", err)
+	}
+	if _, _, err := lease.Client.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+		t.Error("Close() left a leased connection open")
+	}
+	if _, err := p.Get("edge1", dial); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Get() after Close() error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPool_DialError(t *testing.T) {
+	p := NewPool(0)
+	defer p.Close()
+
+	want := errors.New("unreachable")
+	if _, err := p.Get("edge1", func() (*ssh.Client, error) { return nil, want }); !errors.Is(err, want) {
+		t.Errorf("Get() error = %v, want %v", err, want)
+	}
+	if p.Len() != 0 {
+		t.Errorf("Len() = %d after failed dial, want 0", p.Len())
+	}
+}
+
+func TestPool_Nil(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	var p *Pool
+	dial, dials := countingDial(addr)
+	for range 2 {
+		lease, err := p.Get("edge1", dial)
+		if err != nil {
+			t.Fatalf("Get() unexpected error = %v", err)
+		}
+		lease.Close()
+		if _, _, err := lease.Client.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+			t.Error("Close() of a lease of a nil Pool left the connection open")
+		}
+	}
+	if n := dials.Load(); n != 2 {
+		t.Errorf("Get() of a nil Pool dialed %d times, want 2", n)
+	}
+}
+
+func TestPoolKey(t *testing.T) {
+	proxy, err := ParseProxy("socks5://127.0.0.1:1080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PoolKey("core", "edge1:22", nil) == PoolKey("core", "edge1:22", proxy) {
+		t.Error("PoolKey() is the same with and without a proxy")
+	}
+	if PoolKey("core", "edge1:22", nil) == PoolKey("root", "edge1:22", nil) {
+		t.Error("PoolKey() is the same for different users")
+	}
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Proxy opens TCP connections through a SOCKS5 or HTTP CONNECT proxy, for
+// networks that do not allow direct outbound SSH connections. A nil Proxy
+// connects directly.
+type Proxy struct {
+	url *url.URL
+}
+
+// ProxyFromEnv returns the proxy URL set by the ALL_PROXY (or all_proxy)
+// environment variable, or "".
+func ProxyFromEnv() string {
+	if v := os.Getenv("ALL_PROXY"); v != "" {
+		return v
+	}
+	return os.Getenv("all_proxy")
+}
+
+// ParseProxy parses a proxy URL: socks5://host:port, where the proxy
+// receives the address resolved locally, socks5h://host:port, where it
+// resolves the host name itself, or http://host:port for an HTTP proxy
+// supporting CONNECT. Credentials may be given as user:password@. Returns
+// nil for an empty URL.
+func ParseProxy(raw string) (*Proxy, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	defaultPort := ""
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		defaultPort = "1080"
+	case "http":
+		defaultPort = "80"
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q (use socks5, socks5h or http)", raw, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return &Proxy{url: u}, nil
+}
+
+// String returns the proxy URL without its password.
+func (p *Proxy) String() string {
+	if p == nil {
+		return "direct"
+	}
+	return p.url.Redacted()
+}
+
+// Dial connects to addr through the proxy, giving up after timeout (0 for
+// no limit), including the proxy handshake.
+func (p *Proxy) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	if p == nil {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+
+	conn, err := net.DialTimeout("tcp", p.url.Host, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", p, err)
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if p.url.Scheme == "http" {
+		conn, err = p.connectHTTP(conn, addr)
+	} else {
+		err = p.connectSOCKS5(conn, addr, timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", p, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// connectHTTP asks the HTTP proxy on conn to tunnel to addr.
+func (p *Proxy) connectHTTP(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := p.url.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return conn, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	// The SSH server may have sent its version along with the response
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// bufferedConn is a connection whose reads go through r, holding data
+// read ahead from it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// SOCKS5 protocol values (RFC 1928 and RFC 1929).
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0
+	socksPasswordAuth = 2
+	socksConnect      = 1
+	socksIPv4         = 1
+	socksDomain       = 3
+	socksIPv6         = 4
+)
+
+// socksReplies describes the SOCKS5 reply codes reporting failures.
+var socksReplies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// connectSOCKS5 asks the SOCKS5 proxy on conn to connect to addr.
+func (p *Proxy) connectSOCKS5(conn net.Conn, addr string, timeout time.Duration) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port in %q", addr)
+	}
+
+	methods := []byte{socksNoAuth}
+	if p.url.User != nil {
+		methods = []byte{socksPasswordAuth}
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return fmt.Errorf("not a SOCKS5 proxy (version %d)", reply[0])
+	}
+	switch reply[1] {
+	case socksNoAuth:
+	case socksPasswordAuth:
+		if err := p.authenticateSOCKS5(conn); err != nil {
+			return err
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	req := []byte{socksVersion, socksConnect, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && p.url.Scheme == "socks5" {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		// Prefer IPv4, as dialing directly would
+		ip = ips[0]
+		for _, candidate := range ips {
+			if candidate.To4() != nil {
+				ip = candidate
+				break
+			}
+		}
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		req = append(req, socksDomain, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, socksIPv4)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, socksIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Version, reply, reserved, then the bound address, which is skipped
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		msg, ok := socksReplies[header[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply %d", header[1])
+		}
+		return fmt.Errorf("connect %s: %s", addr, msg)
+	}
+	var skip int
+	switch header[3] {
+	case socksIPv4:
+		skip = net.IPv4len
+	case socksIPv6:
+		skip = net.IPv6len
+	case socksDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("unknown address type %d in reply", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+// authenticateSOCKS5 sends the proxy URL's credentials on conn.
+func (p *Proxy) authenticateSOCKS5(conn net.Conn) error {
+	user := p.url.User.Username()
+	password, _ := p.url.User.Password()
+	if len(user) > 255 || len(password) > 255 {
+		return errors.New("proxy credentials too long")
+	}
+	req := []byte{1, byte(len(user))}
+	req = append(req, user...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errors.New("authentication failed")
+	}
+	return nil
+}
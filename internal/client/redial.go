@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrRedialerClosed is returned by Redialer methods once it has been closed.
+var ErrRedialerClosed = errors.New("ssh connection closed")
+
+// Redialer maintains an SSH connection to a single host, transparently
+// re-dialing it when it is lost. Each connection is monitored with
+// KeepAlive so that silently dropped links are detected.
+//
+// A Redialer is safe for concurrent use.
+type Redialer struct {
+	dial      func() (*ssh.Client, error)
+	keepAlive time.Duration
+
+	mu      sync.Mutex
+	client  *ssh.Client
+	cancel  context.CancelFunc
+	closed  bool
+	dialing *redialAttempt // the dial in progress, if any
+}
+
+// redialAttempt is a dial of a Redialer, whose outcome the callers of
+// Client waiting for it share.
+type redialAttempt struct {
+	done   chan struct{} // closed once the dial completes
+	client *ssh.Client
+	err    error
+}
+
+// NewRedialer returns a Redialer that establishes connections using dial.
+// No connection is made until Client or Dial is called.
+func NewRedialer(dial func() (*ssh.Client, error), keepAlive time.Duration) *Redialer {
+	return &Redialer{dial: dial, keepAlive: keepAlive}
+}
+
+// Client returns the current SSH connection, dialing a new one if there is
+// no live connection. The dial happens without holding the lock, so that
+// other callers, and Close, are not blocked for its duration; concurrent
+// callers wait for the same dial instead of starting their own.
+func (r *Redialer) Client() (*ssh.Client, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRedialerClosed
+	}
+	if r.client != nil {
+		sshClient := r.client
+		r.mu.Unlock()
+		return sshClient, nil
+	}
+	if attempt := r.dialing; attempt != nil {
+		r.mu.Unlock()
+		<-attempt.done
+		return attempt.client, attempt.err
+	}
+	attempt := &redialAttempt{done: make(chan struct{})}
+	r.dialing = attempt
+	r.mu.Unlock()
+
+	sshClient, err := r.dial()
+
+	r.mu.Lock()
+	r.dialing = nil
+	if err == nil && r.closed {
+		sshClient.Close()
+		sshClient, err = nil, ErrRedialerClosed
+	}
+	if err == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.client = sshClient
+		r.cancel = cancel
+
+		go KeepAlive(ctx, sshClient, r.keepAlive)
+		go func() {
+			sshClient.Wait()
+			cancel()
+			r.mu.Lock()
+			if r.client == sshClient {
+				r.client = nil
+			}
+			r.mu.Unlock()
+		}()
+	}
+	r.mu.Unlock()
+
+	attempt.client, attempt.err = sshClient, err
+	close(attempt.done)
+	return sshClient, err
+}
+
+// Dial opens a connection to addr on the given network through the SSH
+// connection. If the current connection turns out to be dead, it is
+// discarded and a single new connection is attempted.
+func (r *Redialer) Dial(network, addr string) (net.Conn, error) {
+	sshClient, err := r.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sshClient.Dial(network, addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// Dial errors on a healthy connection (e.g. a missing socket) are not
+	// retried; only re-dial when the transport itself has gone away.
+	if _, _, pingErr := sshClient.SendRequest("keepalive@openssh.com", true, nil); pingErr == nil {
+		return nil, err
+	}
+
+	r.reset(sshClient)
+	if sshClient, err = r.Client(); err != nil {
+		return nil, err
+	}
+	return sshClient.Dial(network, addr)
+}
+
+// reset discards sshClient if it is still the current connection.
+func (r *Redialer) reset(sshClient *ssh.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client == sshClient {
+		r.cancel()
+		r.client.Close()
+		r.client = nil
+	}
+}
+
+// Close closes the current connection, if any, and the one being dialed
+// once the dial completes. Subsequent calls to Client or Dial return
+// ErrRedialerClosed.
+func (r *Redialer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.client == nil {
+		return nil
+	}
+	r.cancel()
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
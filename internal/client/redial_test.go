@@ -0,0 +1,154 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// rejectChannels returns a connection handler for testserver.ServeSSH
+// rejecting all channels. Accepted server connections are sent on conns,
+// if not nil, so tests can drop them.
+func rejectChannels(conns chan<- *ssh.ServerConn) func(*ssh.ServerConn, <-chan ssh.NewChannel) {
+	return func(sconn *ssh.ServerConn, chans <-chan ssh.NewChannel) {
+		if conns != nil {
+			conns <- sconn
+		}
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "no channels")
+		}
+	}
+}
+
+func testClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "testuser",
+		Auth:            []ssh.AuthMethod{ssh.Password("testpass")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	}
+}
+
+func TestRedialer_ReusesConnection(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	r := NewRedialer(func() (*ssh.Client, error) {
+		return NewSSHClient(addr, testClientConfig())
+	}, time.Hour)
+	defer r.Close()
+
+	first, err := r.Client()
+	if err != nil {
+		t.Fatalf("Client() unexpected error = %v", err)
+	}
+	second, err := r.Client()
+	if err != nil {
+		t.Fatalf("Client() unexpected error = %v", err)
+	}
+	if first != second {
+		t.Error("Client() dialed a new connection while the first was alive")
+	}
+}
+
+func TestRedialer_ReconnectsAfterDrop(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+
+	conns := make(chan *ssh.ServerConn, 2)
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(conns))
+
+	r := NewRedialer(func() (*ssh.Client, error) {
+		return NewSSHClient(addr, testClientConfig())
+	}, time.Hour)
+	defer r.Close()
+
+	first, err := r.Client()
+	if err != nil {
+		t.Fatalf("Client() unexpected error = %v", err)
+	}
+
+	// Drop the connection from the server side
+	(<-conns).Close()
+	first.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		second, err := r.Client()
+		if err != nil {
+			t.Fatalf("Client() unexpected error after drop = %v", err)
+		}
+		if second != first {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Client() kept returning the dropped connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedialer_Closed(t *testing.T) {
+	r := NewRedialer(func() (*ssh.Client, error) {
+		return NewSSHClient("127.0.0.1:1", testClientConfig())
+	}, time.Hour)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() unexpected error = %v", err)
+	}
+
+	if _, err := r.Client(); !errors.Is(err, ErrRedialerClosed) {
+		t.Errorf("Client() error = %v, want ErrRedialerClosed", err)
+	}
+	if _, err := r.Dial("unix", "/tmp/sock"); !errors.Is(err, ErrRedialerClosed) {
+		t.Errorf("Dial() error = %v, want ErrRedialerClosed", err)
+	}
+}
+
+func TestRedialer_DialsWithoutLock(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	go testserver.ServeSSH(listener, serverConfig, rejectChannels(nil))
+
+	release := make(chan struct{})
+	dials := 0
+	r := NewRedialer(func() (*ssh.Client, error) {
+		dials++
+		<-release
+		return NewSSHClient(addr, testClientConfig())
+	}, time.Hour)
+
+	results := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := r.Client()
+			results <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Close does not wait for the dial in progress
+	closed := make(chan error)
+	go func() { closed <- r.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close() unexpected error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked by the dial in progress")
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-results; !errors.Is(err, ErrRedialerClosed) {
+			t.Errorf("Client() error = %v, want ErrRedialerClosed once closed during the dial", err)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("dials = %d, want concurrent callers to share one", dials)
+	}
+}
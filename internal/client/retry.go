@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// maxRetryDelay caps the delay between attempts when backing off.
+const maxRetryDelay = 30 * time.Second
+
+// RetryPolicy controls how operations failing with transient errors are
+// retried. The zero value performs a single attempt.
+type RetryPolicy struct {
+	Retries int           // number of additional attempts after the first
+	Delay   time.Duration // delay before the first retry, doubled after each attempt
+}
+
+// Do calls fn until it succeeds, fails with an error that is not transient,
+// or the retry budget is exhausted. It returns the last error from fn, or
+// ctx.Err() if ctx is cancelled while waiting between attempts.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	delay := p.Delay
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Retries || !IsTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// IsTransient reports whether err is a connection failure that may succeed
+// if retried, such as a refused or reset connection, a timeout, or an EOF
+// during the SSH handshake of a host that is still booting.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
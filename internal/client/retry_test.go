@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"handshake EOF", fmt.Errorf("ssh: handshake failed: %w", io.EOF), true},
+		{"timeout", &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, true},
+		{"auth failure", errors.New("ssh: unable to authenticate"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	policy := RetryPolicy{Retries: 3, Delay: time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return syscall.ECONNREFUSED
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Do() unexpected error = %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Do() made %d attempts, want 3", attempts)
+	}
+}
+
+func TestRetryPolicy_GivesUp(t *testing.T) {
+	policy := RetryPolicy{Retries: 2, Delay: time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func() error {
+		attempts++
+		return syscall.ECONNREFUSED
+	})
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Do() error = %v, want ECONNREFUSED", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Do() made %d attempts, want 3", attempts)
+	}
+}
+
+func TestRetryPolicy_PermanentError(t *testing.T) {
+	policy := RetryPolicy{Retries: 5, Delay: time.Millisecond}
+
+	attempts := 0
+	permanent := errors.New("ssh: unable to authenticate")
+	err := policy.Do(context.Background(), func() error {
+		attempts++
+		return permanent
+	})
+
+	if err != permanent {
+		t.Errorf("Do() error = %v, want %v", err, permanent)
+	}
+	if attempts != 1 {
+		t.Errorf("Do() made %d attempts, want 1", attempts)
+	}
+}
+
+func TestRetryPolicy_ContextCancelled(t *testing.T) {
+	policy := RetryPolicy{Retries: 5, Delay: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := policy.Do(ctx, func() error {
+		return syscall.ECONNREFUSED
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serviceStartTimeout is how long StartService waits for the socket to
+// accept connections.
+const serviceStartTimeout = 15 * time.Second
+
+// IsSocketMissing reports whether err, returned when dialing the Podman
+// socket through an SSH connection, means that the socket does not exist,
+// as when the API service is not enabled on the remote host.
+func IsSocketMissing(err error) bool {
+	var openErr *ssh.OpenChannelError
+	return errors.As(err, &openErr) && strings.Contains(strings.ToLower(openErr.Message), "no such file")
+}
+
+// StartService starts the Podman API service listening on socketPath on the
+// remote host, through the podman.socket systemd unit of the user's manager
+// (or of the system manager for sockets outside /run/user), falling back
+// to running "podman system service" in the background where that fails.
+// It then waits until the socket accepts connections. Errors of the
+// fallback are written to stderr.
+func StartService(ctx context.Context, sshClient *ssh.Client, socketPath string, stderr io.Writer) error {
+	if err := Exec(sshClient, serviceCommand(socketPath), nil, nil, stderr); err != nil {
+		return fmt.Errorf("start podman service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, serviceStartTimeout)
+	defer cancel()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		conn, err := sshClient.Dial("unix", socketPath)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("podman service did not create %s: %w", socketPath, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// serviceCommand returns the shell command starting the service for
+// socketPath. The fallback service runs detached from the SSH session, with
+// no inactivity timeout.
+func serviceCommand(socketPath string) string {
+	systemctl := "systemctl start podman.socket"
+	if strings.HasPrefix(socketPath, "/run/user/") {
+		systemctl = "systemctl --user start podman.socket"
+	}
+	service := "podman system service --time=0 " + ShellQuote("unix://"+socketPath)
+	return systemctl + " 2>/dev/null || { nohup " + service + " </dev/null >/dev/null 2>&1 & }"
+}
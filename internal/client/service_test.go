@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// serviceHandler returns a connection handler for testserver.ServeSSH
+// rejecting connections to the Podman socket as missing until a command
+// has been run, which is sent to commands.
+func serviceHandler(commands chan<- string) func(*ssh.ServerConn, <-chan ssh.NewChannel) {
+	var mu sync.Mutex
+	started := false
+	return func(_ *ssh.ServerConn, chans <-chan ssh.NewChannel) {
+		for newCh := range chans {
+			if newCh.ChannelType() != "direct-streamlocal@openssh.com" {
+				serveExec(newCh, func(cmd string, ch ssh.Channel) uint32 {
+					mu.Lock()
+					started = true
+					mu.Unlock()
+					commands <- cmd
+					return 0
+				})
+				continue
+			}
+			mu.Lock()
+			up := started
+			mu.Unlock()
+			if !up {
+				newCh.Reject(ssh.ConnectionFailed, "open failed: No such file or directory")
+				continue
+			}
+			ch, requests, _ := newCh.Accept()
+			go ssh.DiscardRequests(requests)
+			ch.Close()
+		}
+	}
+}
+
+func TestStartService(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	commands := make(chan string, 1)
+	go testserver.ServeSSH(listener, serverConfig, serviceHandler(commands))
+
+	sshClient, err := NewSSHClient(addr, testClientConfig())
+	if err != nil {
+		t.Fatalf("NewSSHClient() unexpected error = %v", err)
+	}
+	defer sshClient.Close()
+
+	_, err = sshClient.Dial("unix", DefaultSocketPath)
+	if !IsSocketMissing(err) {
+		t.Fatalf("IsSocketMissing(%v) = false, want true", err)
+	}
+
+	if err := StartService(context.Background(), sshClient, DefaultSocketPath, nil); err != nil {
+		t.Fatalf("StartService() unexpected error = %v", err)
+	}
+	want := "systemctl --user start podman.socket 2>/dev/null || { nohup podman system service --time=0 unix:///run/user/1000/podman/podman.sock </dev/null >/dev/null 2>&1 & }"
+	if got := <-commands; got != want {
+		t.Errorf("StartService() ran %q, want %q", got, want)
+	}
+}
+
+func TestServiceCommand_Rootful(t *testing.T) {
+	want := "systemctl start podman.socket 2>/dev/null || { nohup podman system service --time=0 unix:///run/podman/podman.sock </dev/null >/dev/null 2>&1 & }"
+	if got := serviceCommand("/run/podman/podman.sock"); got != want {
+		t.Errorf("serviceCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestIsSocketMissing(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "open failed: No such file or directory"}, true},
+		{&ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "open failed: Permission denied"}, false},
+		{&net.OpError{Op: "dial", Err: &ssh.OpenChannelError{Message: "no such file or directory"}}, true},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsSocketMissing(tt.err); got != tt.want {
+			t.Errorf("IsSocketMissing(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -15,3 +15,21 @@ import (
 func NewSSHClient(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
 	return ssh.Dial("tcp", addr, config)
 }
+
+// NewSSHClientVia is NewSSHClient connecting through proxy, or directly if
+// proxy is nil.
+func NewSSHClientVia(proxy *Proxy, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if proxy == nil {
+		return NewSSHClient(addr, config)
+	}
+	conn, err := proxy.Dial(addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
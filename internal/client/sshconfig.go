@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// ConfiguredHosts returns the hosts named by the Host lines of the SSH
+// client configuration file of paths, ~/.ssh/config by default, in order
+// and without duplicates. Patterns with wildcards and negated patterns are
+// skipped, as they name no single host; a missing file has no hosts.
+func ConfiguredHosts(paths Paths) ([]string, error) {
+	configFile := sshUserFilePath("config")
+	if paths.Config != "" {
+		configFile = expandPath(paths.Config, pathTokens("", ""))
+	}
+	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// The keyword may be separated from its value by "=" as well
+		fields := strings.FieldsFunc(scanner.Text(), func(r rune) bool { return unicode.IsSpace(r) || r == '=' })
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "Host") {
+			continue
+		}
+		for _, pattern := range fields[1:] {
+			if strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			hosts = append(hosts, pattern)
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// decodeSSHConfig parses the SSH client configuration in r for connections
+// to host. Host stanzas are matched against host by the ssh_config package,
+// with wildcards and negated patterns, the first value found for a keyword
+// taking precedence, as with OpenSSH.
+//
+// The package cannot parse Match blocks, so they are evaluated here first,
+// in order, against the host name and user configured by the lines before
+// them: each Match line is replaced with a Host line that matches host
+// exactly when the Match criteria do. The supported criteria are all,
+// final, host, originalhost, user and localuser, each of which may be
+// negated with "!"; exec and canonical are rejected.
+func decodeSSHConfig(r io.Reader, host string) (*ssh_config.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "Match") {
+			out.WriteString(line + "\n")
+			continue
+		}
+
+		// Resolve the settings so far, as the criteria are evaluated
+		// against them
+		conf, err := ssh_config.DecodeBytes(out.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		matched, err := evalMatch(conf, host, fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("ssh_config: line %d: %w", n, err)
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if matched {
+			out.WriteString(indent + "Host " + host + "\n")
+		} else {
+			out.WriteString(indent + "Host !*\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ssh_config.DecodeBytes(out.Bytes())
+}
+
+// evalMatch reports whether the criteria of a Match line hold for a
+// connection to host with the settings of conf.
+func evalMatch(conf *ssh_config.Config, host string, criteria []string) (bool, error) {
+	if len(criteria) == 0 {
+		return false, fmt.Errorf("Match requires criteria")
+	}
+
+	for i := 0; i < len(criteria); i++ {
+		name := strings.ToLower(criteria[i])
+		negated := strings.HasPrefix(name, "!")
+		name = strings.TrimPrefix(name, "!")
+
+		var ok bool
+		switch name {
+		case "all", "final":
+			// Configurations are evaluated in a single pass, which is the
+			// final one
+			ok = true
+		case "host", "originalhost", "user", "localuser":
+			if i+1 >= len(criteria) {
+				return false, fmt.Errorf("Match %s requires an argument", name)
+			}
+			i++
+			value, err := matchValue(conf, host, name)
+			if err != nil {
+				return false, err
+			}
+			if ok, err = matchPatterns(criteria[i], value); err != nil {
+				return false, err
+			}
+		default:
+			return false, fmt.Errorf("Match %s is not supported", name)
+		}
+
+		if ok == negated {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchValue returns the value a Match criterion is compared with: the host
+// name or remote user configured so far for host, host itself for
+// originalhost, or the local user.
+func matchValue(conf *ssh_config.Config, host, criterion string) (string, error) {
+	switch criterion {
+	case "host":
+		hostName, err := conf.Get(host, "HostName")
+		if err != nil || hostName == "" {
+			return host, err
+		}
+		return strings.ReplaceAll(hostName, "%h", host), nil
+	case "user":
+		user, err := conf.Get(host, "User")
+		if err != nil || user == "" {
+			return currentUsername(), err
+		}
+		return user, nil
+	case "localuser":
+		return currentUsername(), nil
+	}
+	return host, nil
+}
+
+// matchPatterns reports whether value matches the comma separated pattern
+// list, where a matching negated pattern excludes the value whatever the
+// other patterns.
+func matchPatterns(list, value string) (bool, error) {
+	var h ssh_config.Host
+	for _, p := range strings.Split(list, ",") {
+		pattern, err := ssh_config.NewPattern(p)
+		if err != nil {
+			return false, err
+		}
+		h.Patterns = append(h.Patterns, pattern)
+	}
+	return h.Matches(value), nil
+}
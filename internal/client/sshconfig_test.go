@@ -0,0 +1,139 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// patternConfig exercises wildcard, negated and Match stanzas. The first
+// value obtained for a keyword wins, as with OpenSSH.
+const patternConfig = `Host bastion.edge.example.com
+  User admin
+
+Host *.edge.example.com !legacy.edge.example.com
+  User core
+  Port 2222
+
+Host legacy.edge.example.com
+  Port 22022
+
+Host edge?
+  HostName %h.edge.example.com
+
+Match host *.edge.example.com user core
+  IdentityFile ~/.ssh/edge_core
+
+Match !host *.edge.example.com
+  IdentityFile ~/.ssh/other
+
+Match originalhost edge1,edge2
+  Port 2200
+
+Host *
+  User fallback
+  Port 22
+`
+
+func TestLoadUserConfig_Patterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	if err := os.WriteFile(configFile, []byte(patternConfig), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	key := func(name string) string { return filepath.Join(tmpDir, ".ssh", name) }
+
+	tests := []struct {
+		host         string
+		wantAddr     string
+		wantUser     string
+		wantIdentity string
+	}{
+		// An exact stanza before the wildcard takes precedence for User,
+		// the wildcard still supplies the Port; Match user core fails
+		{"bastion.edge.example.com", "bastion.edge.example.com:2222", "admin", key("id_ed25519")},
+		{"node7.edge.example.com", "node7.edge.example.com:2222", "core", key("edge_core")},
+		// Excluded from the wildcard by negation, so Match user core fails
+		{"legacy.edge.example.com", "legacy.edge.example.com:22022", "fallback", key("id_ed25519")},
+		// Match host compares the HostName configured before it, and
+		// Match originalhost the name given
+		{"edge1", "edge1.edge.example.com:2200", "fallback", key("id_ed25519")},
+		{"db.example.org", "db.example.org:22", "fallback", key("other")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := LoadUserConfig(tt.host, Paths{Config: configFile})
+			if err != nil {
+				t.Fatalf("LoadUserConfig() unexpected error = %v", err)
+			}
+			if got.Addr() != tt.wantAddr {
+				t.Errorf("LoadUserConfig() addr = %q, want %q", got.Addr(), tt.wantAddr)
+			}
+			if got.user != tt.wantUser {
+				t.Errorf("LoadUserConfig() user = %q, want %q", got.user, tt.wantUser)
+			}
+			if got.identityFile != tt.wantIdentity {
+				t.Errorf("LoadUserConfig() identityFile = %q, want %q", got.identityFile, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestConfiguredHosts(t *testing.T) {
+	tmpDir := t.TempDir()
+	setHomeDir(t, tmpDir)
+	configFile := filepath.Join(tmpDir, "ssh_config")
+	if err := os.WriteFile(configFile, []byte(patternConfig+"Host=bastion.edge.example.com db\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	got, err := ConfiguredHosts(Paths{Config: configFile})
+	if err != nil {
+		t.Fatalf("ConfiguredHosts() unexpected error = %v", err)
+	}
+	want := []string{"bastion.edge.example.com", "legacy.edge.example.com", "db"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("ConfiguredHosts() = %q, want %q", got, want)
+	}
+
+	// No configuration, no hosts
+	if got, err := ConfiguredHosts(Paths{}); err != nil || len(got) != 0 {
+		t.Errorf("ConfiguredHosts() without a config = %q, %v, want none", got, err)
+	}
+}
+
+func TestDecodeSSHConfig_Match(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantPort  string
+		wantError string
+	}{
+		{"all", "Match all\n  Port 2000\n", "2000", ""},
+		{"negated criterion", "Match !user no-such-user\n  Port 2001\n", "2001", ""},
+		{"negated pattern", "Match host *.example.com,!web.example.com\n  Port 2002\n", "", ""},
+		{"unmatched user", "User deploy\nMatch user core\n  Port 2003\n", "", ""},
+		{"later stanza", "Match host nothing\n  Port 2004\nHost *\n  Port 2005\n", "2005", ""},
+		{"exec", "Match exec \"true\"\n  Port 2006\n", "", "line 1: Match exec is not supported"},
+		{"missing argument", "Match host\n", "", "requires an argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := decodeSSHConfig(strings.NewReader(tt.config), "web.example.com")
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("decodeSSHConfig() error = %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeSSHConfig() unexpected error = %v", err)
+			}
+			if got, _ := conf.Get("web.example.com", "Port"); got != tt.wantPort {
+				t.Errorf("Port = %q, want %q", got, tt.wantPort)
+			}
+		})
+	}
+}
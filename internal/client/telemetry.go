@@ -0,0 +1,414 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telemetry records OpenTelemetry spans for a single CLI invocation: a
+// root span for the command, with child spans for the SSH dial, socket
+// dials and each API request. The spans are exported in one batch by
+// Shutdown, using the OTLP/HTTP protocol with its JSON encoding, which
+// OpenTelemetry collectors accept on their HTTP receiver; the SDK is not
+// used so as to keep the binary small.
+//
+// A nil *Telemetry records nothing, so callers need not check whether
+// tracing is enabled. A Telemetry is safe for concurrent use.
+type Telemetry struct {
+	endpoint string
+	headers  http.Header
+	service  string
+	client   *http.Client
+	traceID  [16]byte
+	root     *Span
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Span is an operation recorded by Telemetry. A nil *Span ignores all
+// calls.
+type Span struct {
+	t      *Telemetry
+	name   string
+	kind   int
+	id     [8]byte
+	parent [8]byte
+	start  time.Time
+	end    time.Time
+	attrs  []spanAttr
+	err    error
+}
+
+type spanAttr struct {
+	key   string
+	value any
+}
+
+// Span kinds, as numbered by OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// NewTelemetryFromEnv returns a Telemetry exporting to the endpoint set by
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, used as is, or
+// OTEL_EXPORTER_OTLP_ENDPOINT, to which /v1/traces is appended. It returns
+// nil if neither is set. The root span is named after command and joins
+// the trace given by a W3C TRACEPARENT variable, so that the automation
+// running the CLI can link its spans to its own.
+//
+// OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME and
+// OTEL_EXPORTER_OTLP_TIMEOUT are honored as well; protocols other than
+// http/json are rejected.
+func NewTelemetryFromEnv(command string) (*Telemetry, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: want an http or https URL", endpoint)
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (only http/json is supported)", protocol)
+	}
+
+	headers := http.Header{}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		if err := parseOTLPHeaders(os.Getenv(name), headers); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	timeout := 10 * time.Second
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_TIMEOUT %q: want milliseconds", v)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "podman-cli"
+	}
+
+	t := &Telemetry{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  service,
+		client:   &http.Client{Timeout: timeout},
+	}
+	var parent [8]byte
+	if traceID, spanID, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		t.traceID, parent = traceID, spanID
+	} else {
+		rand.Read(t.traceID[:])
+	}
+	t.root = t.newSpan(command, spanKindInternal, parent)
+	return t, nil
+}
+
+// parseOTLPHeaders adds the headers of a comma separated list of
+// URL-encoded key=value pairs to h.
+func parseOTLPHeaders(list string, h http.Header) error {
+	if list == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("want key=value pairs, got %q", pair)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		h.Set(key, decoded)
+	}
+	return nil
+}
+
+// parseTraceparent parses a W3C traceparent value such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(s string) (traceID [16]byte, spanID [8]byte, ok bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return traceID, spanID, false
+	}
+	if n, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || n != len(traceID) || traceID == [16]byte{} {
+		return traceID, spanID, false
+	}
+	if n, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil || n != len(spanID) || spanID == [8]byte{} {
+		return traceID, spanID, false
+	}
+	return traceID, spanID, true
+}
+
+// newSpan starts a span with the given parent and records it for export.
+func (t *Telemetry) newSpan(name string, kind int, parent [8]byte) *Span {
+	s := &Span{t: t, name: name, kind: kind, parent: parent, start: time.Now()}
+	rand.Read(s.id[:])
+
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return s
+}
+
+type spanKey struct{}
+
+// Start starts a span named name, child of the span in ctx or of the root
+// span, and returns a context carrying it.
+func (t *Telemetry) Start(ctx context.Context, name string) (context.Context, *Span) {
+	return t.start(ctx, name, spanKindInternal)
+}
+
+func (t *Telemetry) start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent := t.root
+	if s, ok := ctx.Value(spanKey{}).(*Span); ok {
+		parent = s
+	}
+	s := t.newSpan(name, kind, parent.id)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttr sets an attribute of the span. Values are strings, integers or
+// booleans.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.attrs = append(s.attrs, spanAttr{key, value})
+}
+
+// End ends the span, marking it as failed if err is not nil.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.end.IsZero() {
+		s.end, s.err = time.Now(), err
+	}
+}
+
+// Root returns the span of the command, nil if t is nil.
+func (t *Telemetry) Root() *Span {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+// Dial wraps dial, which opens connections to the named remote socket, so
+// that each dial is recorded as a span.
+func (t *Telemetry) Dial(socket string, dial func() (net.Conn, error)) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		_, span := t.start(context.Background(), "socket.dial", spanKindClient)
+		span.SetAttr("network.transport", "unix")
+		span.SetAttr("server.address", socket)
+		conn, err := dial()
+		span.End(err)
+		return conn, err
+	}
+}
+
+// Transport returns an http.RoundTripper that sends requests with rt,
+// recording each of them as a span until its response headers arrive. The
+// W3C traceparent header is added to the requests.
+func (t *Telemetry) Transport(rt http.RoundTripper) http.RoundTripper {
+	return &telemetryTransport{t: t, rt: rt}
+}
+
+type telemetryTransport struct {
+	t  *Telemetry
+	rt http.RoundTripper
+}
+
+func (tt *telemetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := tt.t.start(req.Context(), req.Method, spanKindClient)
+	if span == nil {
+		return tt.rt.RoundTrip(req)
+	}
+	span.SetAttr("http.request.method", req.Method)
+	span.SetAttr("url.path", req.URL.Path)
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Traceparent", "00-"+hex.EncodeToString(tt.t.traceID[:])+"-"+hex.EncodeToString(span.id[:])+"-01")
+
+	resp, err := tt.rt.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	span.SetAttr("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		err = errors.New(resp.Status)
+	}
+	span.End(err)
+	return resp, nil
+}
+
+// Shutdown ends the root span, along with any span left open, and exports
+// all spans. It does nothing if t is nil.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.root.End(nil)
+
+	t.mu.Lock()
+	body, err := json.Marshal(t.export())
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range t.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("export spans: %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below are the parts of the OTLP JSON encoding of
+// ExportTraceServiceRequest that are sent. IDs are hex encoded and
+// timestamps and integers are decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpSpan struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		Name         string     `json:"name"`
+		Kind         int        `json:"kind"`
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []otlpAttr `json:"attributes,omitempty"`
+		Status       otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// export returns the export request for the recorded spans, ending those
+// still open. t.mu must be held.
+func (t *Telemetry) export() otlpRequest {
+	scope := otlpScopeSpans{}
+	scope.Scope.Name = "github.com/alexjch/podman-cli"
+
+	now := time.Now()
+	for _, s := range t.spans {
+		if s.end.IsZero() {
+			s.end = now
+		}
+		span := otlpSpan{
+			TraceID: hex.EncodeToString(t.traceID[:]),
+			SpanID:  hex.EncodeToString(s.id[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, otlpAttribute(a.key, a.value))
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttr{otlpAttribute("service.name", t.service)}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+// otlpAttribute encodes an attribute as an OTLP key and AnyValue.
+func otlpAttribute(key string, value any) otlpAttr {
+	var v map[string]any
+	switch value := value.(type) {
+	case string:
+		v = map[string]any{"stringValue": value}
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(value)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+	case bool:
+		v = map[string]any{"boolValue": value}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttr{Key: key, Value: v}
+}
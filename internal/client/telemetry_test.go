@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewTelemetryFromEnv_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	tel, err := NewTelemetryFromEnv("podman-cli ps")
+	if err != nil || tel != nil {
+		t.Fatalf("NewTelemetryFromEnv() = %v, %v, want nil, nil", tel, err)
+	}
+
+	// A nil Telemetry and its spans ignore all calls
+	ctx, span := tel.Start(context.Background(), "noop")
+	span.SetAttr("key", "value")
+	span.End(nil)
+	if ctx == nil || tel.Root() != nil || tel.Shutdown(context.Background()) != nil {
+		t.Error("nil Telemetry did not ignore calls")
+	}
+}
+
+func TestNewTelemetryFromEnv_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		wantE string
+	}{
+		{"grpc", map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, "only http/json"},
+		{"headers", map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "api-key"}, "OTEL_EXPORTER_OTLP_HEADERS"},
+		{"timeout", map[string]string{"OTEL_EXPORTER_OTLP_TIMEOUT": "5s"}, "want milliseconds"},
+		{"endpoint", map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "collector:4318"}, "invalid OTLP endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := NewTelemetryFromEnv("podman-cli ps"); err == nil || !strings.Contains(err.Error(), tt.wantE) {
+				t.Errorf("NewTelemetryFromEnv() error = %v, want %q", err, tt.wantE)
+			}
+		})
+	}
+}
+
+func TestTelemetry_Export(t *testing.T) {
+	var gotPath, gotAuth string
+	var got otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	var gotTraceparent string
+	podman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer podman.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_SERVICE_NAME", "fleet-rollout")
+	t.Setenv("TRACEPARENT", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	tel, err := NewTelemetryFromEnv("podman-cli inspect")
+	if err != nil {
+		t.Fatalf("NewTelemetryFromEnv() unexpected error = %v", err)
+	}
+
+	ctx, dialSpan := tel.Start(context.Background(), "ssh.dial")
+	dialSpan.SetAttr("podman_cli.attempts", 2)
+	dialSpan.End(errors.New("connection refused"))
+
+	httpClient := NewHTTPClient(tel.Dial("/run/podman/podman.sock", func() (net.Conn, error) {
+		return net.Dial("tcp", podman.Listener.Addr().String())
+	}))
+	httpClient.Transport = tel.Transport(httpClient.Transport)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/v3.0.0/libpod/containers/web/json", nil)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() unexpected error = %v", err)
+	}
+	resp.Body.Close()
+
+	if err := tel.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() unexpected error = %v", err)
+	}
+
+	if gotPath != "/v1/traces" || gotAuth != "Bearer token" {
+		t.Errorf("export sent to %s with Authorization %q", gotPath, gotAuth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("export = %+v, want one resource and scope", got)
+	}
+	if attr := got.ResourceSpans[0].Resource.Attributes; len(attr) != 1 || attr[0].Value["stringValue"] != "fleet-rollout" {
+		t.Errorf("export resource = %+v, want service.name fleet-rollout", attr)
+	}
+
+	spans := map[string]otlpSpan{}
+	for _, s := range got.ResourceSpans[0].ScopeSpans[0].Spans {
+		if s.TraceID != traceID {
+			t.Errorf("span %s trace ID = %s, want %s", s.Name, s.TraceID, traceID)
+		}
+		spans[s.Name] = s
+	}
+	root, dial, socket, request := spans["podman-cli inspect"], spans["ssh.dial"], spans["socket.dial"], spans["GET"]
+	if root.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("root parent = %q, want the TRACEPARENT span", root.ParentSpanID)
+	}
+	if dial.ParentSpanID != root.SpanID || socket.ParentSpanID != root.SpanID || request.ParentSpanID != dial.SpanID {
+		t.Errorf("spans not linked: root %s, dial parent %s, socket parent %s, request parent %s", root.SpanID, dial.ParentSpanID, socket.ParentSpanID, request.ParentSpanID)
+	}
+	if dial.Status.Code != 2 || dial.Status.Message != "connection refused" || root.Status.Code != 0 {
+		t.Errorf("statuses = %+v / %+v, want an error on ssh.dial only", dial.Status, root.Status)
+	}
+	if request.Status.Code != 2 || request.Kind != spanKindClient {
+		t.Errorf("request span = %+v, want a failed client span", request)
+	}
+	if want := "00-" + traceID + "-" + request.SpanID + "-01"; gotTraceparent != want {
+		t.Errorf("Traceparent = %q, want %q", gotTraceparent, want)
+	}
+
+	attrs := map[string]map[string]any{}
+	for _, a := range request.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["url.path"]["stringValue"] != "/v3.0.0/libpod/containers/web/json" || attrs["http.response.status_code"]["intValue"] != "404" {
+		t.Errorf("request attributes = %v", attrs)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	if _, _, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"); !ok {
+		t.Error("parseTraceparent() rejected a valid value")
+	}
+	for _, s := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f35-00f067aa0ba902b7-01",
+	} {
+		if _, _, ok := parseTraceparent(s); ok {
+			t.Errorf("parseTraceparent(%q) ok = true, want false", s)
+		}
+	}
+}
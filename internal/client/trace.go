@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Tracer writes a curl-style trace of SSH connection setup and of the HTTP
+// requests sent over it, with the time taken by each phase, to help
+// diagnose slow or failing remote hosts. Informational lines start with
+// "* ", request lines with "> " and response lines with "< ".
+//
+// A Tracer is safe for concurrent use.
+type Tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTracer returns a Tracer writing to w.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// Printf writes an informational trace line.
+func (t *Tracer) Printf(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "* "+format+"\n", args...)
+}
+
+// dump writes each line of data preceded by prefix.
+func (t *Tracer) dump(prefix string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fmt.Fprintf(t.w, "%s%s\n", prefix, bytes.TrimRight(scanner.Bytes(), "\r"))
+	}
+}
+
+// DialSSH connects to addr like NewSSHClientVia, tracing the TCP
+// connection, the key exchange including the server's host key, and
+// authentication.
+func (t *Tracer) DialSSH(proxy *Proxy, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if proxy != nil {
+		t.Printf("ssh: dialing tcp %s via proxy %s", addr, proxy)
+	} else {
+		t.Printf("ssh: dialing tcp %s", addr)
+	}
+	start := time.Now()
+	conn, err := proxy.Dial(addr, config.Timeout)
+	if err != nil {
+		t.Printf("ssh: dial failed after %s: %v", since(start), err)
+		return nil, err
+	}
+	t.Printf("ssh: connected to %s in %s", conn.RemoteAddr(), since(start))
+
+	traced := *config
+	kexStart := time.Now()
+	authStart := kexStart
+	traced.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		t.Printf("ssh: key exchange completed in %s", since(kexStart))
+		t.Printf("ssh: server host key %s %s", key.Type(), ssh.FingerprintSHA256(key))
+		if err := config.HostKeyCallback(hostname, remote, key); err != nil {
+			t.Printf("ssh: host key rejected: %v", err)
+			return err
+		}
+		t.Printf("ssh: host key accepted")
+		authStart = time.Now()
+		return nil
+	}
+	traced.BannerCallback = func(message string) error {
+		t.Printf("ssh: server banner: %q", message)
+		if config.BannerCallback != nil {
+			return config.BannerCallback(message)
+		}
+		return nil
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &traced)
+	if err != nil {
+		conn.Close()
+		t.Printf("ssh: handshake failed after %s: %v", since(kexStart), err)
+		return nil, err
+	}
+	t.Printf("ssh: authenticated as %s in %s (server %s)", config.User, since(authStart), c.ServerVersion())
+	t.Printf("ssh: connection established in %s", since(start))
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Dial wraps dial, which opens connections to the named remote socket, so
+// that the time taken by each dial is traced.
+func (t *Tracer) Dial(socket string, dial func() (net.Conn, error)) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial()
+		if err != nil {
+			t.Printf("socket: dial %s failed after %s: %v", socket, since(start), err)
+			return nil, err
+		}
+		t.Printf("socket: connected to %s in %s", socket, since(start))
+		return conn, nil
+	}
+}
+
+// Transport returns an http.RoundTripper that sends requests with rt,
+// tracing the request line and headers, the response status and headers,
+// and the time until the request was written and the first response byte
+// arrived. Bodies are not traced.
+func (t *Tracer) Transport(rt http.RoundTripper) http.RoundTripper {
+	return &traceTransport{tracer: t, rt: rt}
+}
+
+type traceTransport struct {
+	tracer *Tracer
+	rt     http.RoundTripper
+}
+
+func (tt *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := tt.tracer
+
+	var mu sync.Mutex
+	var wrote, firstByte time.Duration
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wrote = time.Since(start)
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			firstByte = time.Since(start)
+			mu.Unlock()
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	if dump, err := httputil.DumpRequest(req, false); err == nil {
+		t.dump("> ", dump)
+	}
+
+	resp, err := tt.rt.RoundTrip(req)
+	if err != nil {
+		t.Printf("request: failed after %s: %v", since(start), err)
+		return nil, err
+	}
+
+	if dump, err := httputil.DumpResponse(resp, false); err == nil {
+		t.dump("< ", dump)
+	}
+	mu.Lock()
+	t.Printf("request: written in %s, first byte after %s, headers after %s", wrote.Round(time.Microsecond), firstByte.Round(time.Microsecond), since(start))
+	mu.Unlock()
+	return resp, nil
+}
+
+// since returns the time elapsed since start, rounded for display.
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestTracer_Transport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "4.9.3")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	tracer := NewTracer(&out)
+	httpClient := NewHTTPClient(tracer.Dial("/run/podman/podman.sock", func() (net.Conn, error) {
+		return net.Dial("tcp", server.Listener.Addr().String())
+	}))
+	httpClient.Transport = tracer.Transport(httpClient.Transport)
+
+	resp, err := httpClient.Get("http://localhost/v3.0.0/containers/json")
+	if err != nil {
+		t.Fatalf("Get() unexpected error = %v", err)
+	}
+	resp.Body.Close()
+
+	for _, want := range []string{
+		"* socket: connected to /run/podman/podman.sock in ",
+		"> GET /v3.0.0/containers/json HTTP/1.1\n",
+		"> Host: localhost\n",
+		"< HTTP/1.1 200 OK\n",
+		"< Api-Version: 4.9.3\n",
+		"* request: written in ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("trace missing %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTracer_DialSSH(t *testing.T) {
+	listener, serverConfig, addr := setupTestSSHServer(t)
+	defer listener.Close()
+	startTestSSHServer(t, listener, serverConfig)
+
+	var out strings.Builder
+	sshClient, err := NewTracer(&out).DialSSH(nil, addr, &ssh.ClientConfig{
+		User:            "testuser",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("DialSSH() unexpected error = %v", err)
+	}
+	sshClient.Close()
+
+	for _, want := range []string{
+		"* ssh: dialing tcp " + addr,
+		"* ssh: server host key ssh-rsa SHA256:",
+		"* ssh: host key accepted",
+		"* ssh: authenticated as testuser in ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("trace missing %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTracer_DialSSH_Refused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var out strings.Builder
+	if _, err := NewTracer(&out).DialSSH(nil, addr, &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()}); err == nil {
+		t.Fatal("DialSSH() expected error for refused connection, got nil")
+	}
+	if !strings.Contains(out.String(), "* ssh: dial failed after ") {
+		t.Errorf("trace missing dial failure, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultVaultMount is the path at which Vault's SSH secrets engine is
+// usually mounted.
+const DefaultVaultMount = "ssh"
+
+// vaultTimeout bounds the request for a certificate.
+const vaultTimeout = 30 * time.Second
+
+// VaultProvider obtains a short-lived user certificate from the SSH secrets
+// engine of HashiCorp Vault. Each connection uses a new ed25519 key, kept
+// in memory only, whose public half Vault signs for the remote user with
+// the given role, so that no static key needs to be stored locally.
+type VaultProvider struct {
+	Addr  string // Vault server address, such as https://vault.example.com:8200
+	Mount string // mount path of the SSH secrets engine
+	Role  string // signing role
+	Token string // Vault token authorizing the request
+}
+
+// VaultToken returns the Vault token from $VAULT_TOKEN or, as the vault
+// command stores it after login, from ~/.vault-token.
+func VaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	data, err := os.ReadFile(filepath.Join(config.HomeDir(), ".vault-token"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("vault: no token (set VAULT_TOKEN or run vault login)")
+		}
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// vaultResponse is the part of Vault's reply used here.
+type vaultResponse struct {
+	Data struct {
+		SignedKey string `json:"signed_key"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (v VaultProvider) Credentials(userConfig *UserConfig) (Credentials, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Credentials{}, err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return Credentials{}, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"public_key":       string(ssh.MarshalAuthorizedKey(sshPub)),
+		"valid_principals": userConfig.user,
+		"cert_type":        "user",
+	})
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	mount := v.Mount
+	if mount == "" {
+		mount = DefaultVaultMount
+	}
+	endpoint := strings.TrimRight(v.Addr, "/") + "/v1/" + strings.Trim(mount, "/") + "/sign/" + url.PathEscape(v.Role)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := (&http.Client{Timeout: vaultTimeout}).Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var reply vaultResponse
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vault: %w", err)
+	}
+	if err := json.Unmarshal(data, &reply); err != nil && resp.StatusCode == http.StatusOK {
+		return Credentials{}, fmt.Errorf("vault: invalid response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(reply.Errors) > 0 {
+			return Credentials{}, fmt.Errorf("vault: signing with role %q: %s", v.Role, strings.Join(reply.Errors, "; "))
+		}
+		return Credentials{}, fmt.Errorf("vault: signing with role %q: %s", v.Role, resp.Status)
+	}
+	if reply.Data.SignedKey == "" {
+		return Credentials{}, errors.New("vault: response has no signed key")
+	}
+
+	return Credentials{
+		Key:         pem.EncodeToMemory(block),
+		Certificate: []byte(reply.Data.SignedKey),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startTestVault serves the sign endpoint of an SSH secrets engine mounted
+// at "ssh", signing keys for role "edge" with ca.
+func startTestVault(t *testing.T, ca ssh.Signer) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/ssh/sign/edge" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req["public_key"]))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cert := newTestCertificate(t, ca, pub)
+		cert.ValidPrincipals = strings.Split(req["valid_principals"], ",")
+		if err := cert.SignCert(rand.Reader, ca); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]string{"signed_key": string(ssh.MarshalAuthorizedKey(cert))},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultProvider(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := startTestVault(t, ca)
+
+	provider := VaultProvider{Addr: vault.URL + "/", Role: "edge", Token: "s.token"}
+	signer, err := loadSigner(&UserConfig{user: "core"}, provider)
+	if err != nil {
+		t.Fatalf("loadSigner() unexpected error = %v", err)
+	}
+	cert, ok := signer.PublicKey().(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("loadSigner() key type = %s, want a certificate", signer.PublicKey().Type())
+	}
+	if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "core" {
+		t.Errorf("certificate principals = %v, want [core]", cert.ValidPrincipals)
+	}
+	if string(cert.SignatureKey.Marshal()) != string(ca.PublicKey().Marshal()) {
+		t.Error("certificate is not signed by the Vault CA")
+	}
+}
+
+func TestVaultProvider_Errors(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := ssh.NewSignerFromKey(caKey)
+	vault := startTestVault(t, ca)
+
+	tests := []struct {
+		name     string
+		provider VaultProvider
+		want     string
+	}{
+		{"bad token", VaultProvider{Addr: vault.URL, Role: "edge", Token: "wrong"}, "permission denied"},
+		{"unknown role", VaultProvider{Addr: vault.URL, Role: "other", Token: "s.token"}, "404"},
+		{"unknown mount", VaultProvider{Addr: vault.URL, Mount: "ssh-client-signer", Role: "edge", Token: "s.token"}, "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.provider.Credentials(&UserConfig{user: "core"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Credentials() error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultToken(t *testing.T) {
+	home := t.TempDir()
+	setHomeDir(t, home)
+
+	t.Setenv("VAULT_TOKEN", "s.env")
+	if got, err := VaultToken(); err != nil || got != "s.env" {
+		t.Errorf("VaultToken() = %q, %v, want $VAULT_TOKEN", got, err)
+	}
+
+	t.Setenv("VAULT_TOKEN", "")
+	if _, err := VaultToken(); err == nil {
+		t.Error("VaultToken() expected error without token, got nil")
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".vault-token"), []byte("s.file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := VaultToken(); err != nil || got != "s.file" {
+		t.Errorf("VaultToken() = %q, %v, want ~/.vault-token", got, err)
+	}
+}
@@ -4,15 +4,25 @@ package commands
 
 // Command represents a Podman API endpoint with its HTTP method and path.
 type Command struct {
-	Path   string // API endpoint path (e.g., "/v3.0.0/containers/json")
-	Method string // HTTP method (e.g., "GET", "POST")
+	Path        string // API endpoint path (e.g., "/v3.0.0/containers/json")
+	Method      string // HTTP method (e.g., "GET", "POST")
+	Description string // One-line description shown in help output
+	Filters     bool   // Whether the endpoint accepts -filter, as list endpoints do
 }
 
 // commands is the internal registry of available commands.
 var commands = map[string]Command{
 	"list_containers": {
-		Path:   "/v3.0.0/containers/json",
-		Method: "GET",
+		Path:        "/v3.0.0/containers/json",
+		Method:      "GET",
+		Description: "List running containers as raw JSON",
+		Filters:     true,
+	},
+	"list_images": {
+		Path:        "/v3.0.0/images/json",
+		Method:      "GET",
+		Description: "List images as raw JSON",
+		Filters:     true,
 	},
 }
 
@@ -0,0 +1,237 @@
+// Package config loads the podman-cli configuration file. The file provides
+// defaults for the global command-line flags, either directly or grouped
+// into named profiles, so that common flag combinations need not be
+// repeated on every invocation:
+//
+//	host = "myserver"
+//	timeout = "10s"
+//
+//	[profiles.prod]
+//	host = "production"
+//	socket = "/run/podman/podman.sock"
+//	format = "json"
+//	audit_log = "/var/log/podman-cli/audit.jsonl"
+//
+// Named groups of hosts can be given to the commands operating on several
+// hosts as @<group>:
+//
+//	[groups]
+//	fleet = ["edge1", "edge2", "edge3"]
+//
+// Aliases name a command, optionally followed by arguments, to run in
+// place of the alias:
+//
+//	[aliases]
+//	lsi = "list_images"
+//	tail = "logs -f -tail 50"
+//
+// Hooks are notified when a command completes, by a POST request to a URL
+// or by running a shell command, with a JSON summary of the command:
+//
+//	[[hooks]]
+//	url = "https://hooks.slack.com/services/T000/B000/XXXX"
+//	commands = ["run", "rm", "compose"]
+//	on = "failure"
+//
+//	[[hooks]]
+//	command = "/usr/local/bin/cmdb-update"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Settings holds the values that may be set at the top level of the file
+// or within a profile. Zero values are unset.
+type Settings struct {
+	Host    string        `toml:"host"`
+	Timeout time.Duration `toml:"timeout"`
+	Format  string        `toml:"format"`
+	Socket  string        `toml:"socket"`
+	// AuditLog is the file to which the commands changing a remote host
+	// are appended
+	AuditLog string `toml:"audit_log"`
+}
+
+// File holds the contents of a configuration file.
+type File struct {
+	Settings
+	Profiles map[string]Settings `toml:"profiles"`
+	Groups   map[string][]string `toml:"groups"`
+	Aliases  map[string]string   `toml:"aliases"`
+	Hooks    []Hook              `toml:"hooks"`
+}
+
+// Outcomes of a command on which a hook is notified.
+const (
+	HookAlways  = "always"
+	HookSuccess = "success"
+	HookFailure = "failure"
+)
+
+// Hook is notified when a command completes. Exactly one of URL and
+// Command is set.
+type Hook struct {
+	URL      string            `toml:"url"`      // receives the summary in a POST request
+	Headers  map[string]string `toml:"headers"`  // headers of the request, such as Authorization
+	Command  string            `toml:"command"`  // shell command reading the summary on stdin
+	Commands []string          `toml:"commands"` // commands notifying the hook; all if empty
+	On       string            `toml:"on"`       // HookAlways (the default), HookSuccess or HookFailure
+	Timeout  time.Duration     `toml:"timeout"`  // limit of the notification, 0 for the default
+}
+
+// Matches reports whether the hook is notified of the named command
+// completing with or without success.
+func (h Hook) Matches(command string, success bool) bool {
+	switch h.On {
+	case HookSuccess:
+		if !success {
+			return false
+		}
+	case HookFailure:
+		if success {
+			return false
+		}
+	}
+	if len(h.Commands) == 0 {
+		return true
+	}
+	for _, c := range h.Commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) validate() error {
+	if (h.URL == "") == (h.Command == "") {
+		return errors.New("exactly one of url and command must be set")
+	}
+	if h.URL != "" {
+		// The URL is not quoted, as it often holds a secret token
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an http or https URL")
+		}
+	}
+	if len(h.Headers) > 0 && h.URL == "" {
+		return errors.New("headers require url")
+	}
+	switch h.On {
+	case "", HookAlways, HookSuccess, HookFailure:
+	default:
+		return fmt.Errorf("invalid on %q (use %s, %s or %s)", h.On, HookAlways, HookSuccess, HookFailure)
+	}
+	if h.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// DefaultPath returns the path of the configuration file,
+// podman-cli/config.toml under ConfigHome.
+func DefaultPath() string {
+	return filepath.Join(ConfigHome(), "podman-cli", "config.toml")
+}
+
+// HomeDir returns the current user's home directory, taken from the
+// environment ($HOME, or %USERPROFILE% on Windows) or, when that is unset
+// as it may be for systemd services and cron jobs, from the user database.
+// It returns "" if neither is available.
+func HomeDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	if u, err := user.Current(); err == nil {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// ConfigHome returns the base directory for user configuration files:
+// $XDG_CONFIG_HOME if it is set to an absolute path, as required by the
+// XDG Base Directory specification, and ~/.config otherwise.
+func ConfigHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(HomeDir(), ".config")
+}
+
+// Load reads the configuration file at path. A missing file yields an
+// empty File.
+func Load(path string) (*File, error) {
+	f := &File{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := toml.Decode(string(data), f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("parse %s: unknown setting %q", path, undecoded[0].String())
+	}
+	for i, h := range f.Hooks {
+		if err := h.validate(); err != nil {
+			return nil, fmt.Errorf("parse %s: hooks[%d]: %w", path, i, err)
+		}
+	}
+	return f, nil
+}
+
+// Resolve returns the top-level settings overridden by those of the named
+// profile. An empty profile name selects the top-level settings only.
+func (f *File) Resolve(profile string) (Settings, error) {
+	s := f.Settings
+	if profile == "" {
+		return s, nil
+	}
+
+	p, ok := f.Profiles[profile]
+	if !ok {
+		return Settings{}, fmt.Errorf("unknown profile %q", profile)
+	}
+	if p.Host != "" {
+		s.Host = p.Host
+	}
+	if p.Timeout != 0 {
+		s.Timeout = p.Timeout
+	}
+	if p.Format != "" {
+		s.Format = p.Format
+	}
+	if p.Socket != "" {
+		s.Socket = p.Socket
+	}
+	if p.AuditLog != "" {
+		s.AuditLog = p.AuditLog
+	}
+	return s, nil
+}
+
+// Group returns the hosts of the named group.
+func (f *File) Group(name string) ([]string, error) {
+	hosts, ok := f.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown host group %q", name)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("host group %q is empty", name)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Resolve(t *testing.T) {
+	path := writeConfig(t, `
+host = "myserver"
+timeout = "10s"
+format = "json"
+
+[profiles.prod]
+host = "production"
+socket = "/run/podman/podman.sock"
+audit_log = "/var/log/podman-cli/audit.jsonl"
+`)
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+
+	got, err := f.Resolve("")
+	if err != nil {
+		t.Fatalf("Resolve() unexpected error = %v", err)
+	}
+	want := Settings{Host: "myserver", Timeout: 10 * time.Second, Format: "json"}
+	if got != want {
+		t.Errorf("Resolve(\"\") = %+v, want %+v", got, want)
+	}
+
+	got, err = f.Resolve("prod")
+	if err != nil {
+		t.Fatalf("Resolve(prod) unexpected error = %v", err)
+	}
+	want = Settings{Host: "production", Timeout: 10 * time.Second, Format: "json", Socket: "/run/podman/podman.sock", AuditLog: "/var/log/podman-cli/audit.jsonl"}
+	if got != want {
+		t.Errorf("Resolve(prod) = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolve_UnknownProfile(t *testing.T) {
+	f := &File{}
+	if _, err := f.Resolve("staging"); err == nil {
+		t.Error("Resolve() expected error for unknown profile, got nil")
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	f, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if f.Host != "" || len(f.Profiles) != 0 {
+		t.Errorf("Load() = %+v, want empty config", f)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"syntax", `host = `, "parse"},
+		{"unknown setting", `hots = "myserver"`, `unknown setting "hots"`},
+		{"bad duration", `timeout = "soon"`, "parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigHome(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "testuser")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	xdg := filepath.Join(t.TempDir(), "xdg-user")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if got := ConfigHome(); got != xdg {
+		t.Errorf("ConfigHome() = %q, want $XDG_CONFIG_HOME", got)
+	}
+	if got, want := DefaultPath(), filepath.Join(xdg, "podman-cli", "config.toml"); got != want {
+		t.Errorf("DefaultPath() = %q, want %q", got, want)
+	}
+
+	// Relative values are invalid per the XDG specification and ignored
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	if got, want := ConfigHome(), filepath.Join(home, ".config"); got != want {
+		t.Errorf("ConfigHome() = %q, want %q", got, want)
+	}
+}
+
+func TestHomeDir_Unset(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("user database unavailable: %v", err)
+	}
+
+	// Services started without $HOME still find the home directory
+	t.Setenv("HOME", "")
+	t.Setenv("USERPROFILE", "")
+	if got := HomeDir(); got != current.HomeDir {
+		t.Errorf("HomeDir() = %q, want %q", got, current.HomeDir)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	path := writeConfig(t, `
+[groups]
+fleet = ["edge1", "edge2"]
+empty = []
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	hosts, err := f.Group("fleet")
+	if err != nil || strings.Join(hosts, ",") != "edge1,edge2" {
+		t.Errorf("Group(fleet) = %v, %v, want edge1,edge2", hosts, err)
+	}
+	for _, name := range []string{"empty", "staging"} {
+		if _, err := f.Group(name); err == nil {
+			t.Errorf("Group(%s) error = nil, want an error", name)
+		}
+	}
+}
+
+func TestLoad_Aliases(t *testing.T) {
+	path := writeConfig(t, `
+[aliases]
+tail = "logs -f -tail 50"
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if got := f.Aliases["tail"]; got != "logs -f -tail 50" {
+		t.Errorf("Aliases[tail] = %q, want the command line", got)
+	}
+}
+
+func TestLoad_Hooks(t *testing.T) {
+	path := writeConfig(t, `
+[[hooks]]
+url = "https://hooks.example.com/T000/XXXX"
+headers = { Authorization = "Bearer token" }
+commands = ["run", "rm"]
+on = "failure"
+timeout = "5s"
+
+[[hooks]]
+command = "/usr/local/bin/cmdb-update"
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if len(f.Hooks) != 2 {
+		t.Fatalf("Load() = %d hooks, want 2", len(f.Hooks))
+	}
+	h := f.Hooks[0]
+	if h.Headers["Authorization"] != "Bearer token" || h.Timeout != 5*time.Second {
+		t.Errorf("Hooks[0] = %+v", h)
+	}
+
+	tests := []struct {
+		command string
+		success bool
+		want    bool
+	}{
+		{"run", false, true},
+		{"rm", false, true},
+		{"run", true, false},
+		{"ps", false, false},
+	}
+	for _, tt := range tests {
+		if got := h.Matches(tt.command, tt.success); got != tt.want {
+			t.Errorf("Matches(%s, %v) = %v, want %v", tt.command, tt.success, got, tt.want)
+		}
+	}
+	if !f.Hooks[1].Matches("ps", true) || !f.Hooks[1].Matches("ps", false) {
+		t.Errorf("Hooks[1] does not match every command, want it to")
+	}
+}
+
+func TestLoad_InvalidHooks(t *testing.T) {
+	tests := []struct {
+		name string
+		hook string
+		want string
+	}{
+		{"neither", `on = "always"`, "exactly one of url and command"},
+		{"both", `url = "https://example.com"` + "\n" + `command = "true"`, "exactly one of url and command"},
+		{"bad url", `url = "ftp://example.com/secret"`, "http or https URL"},
+		{"headers without url", `command = "true"` + "\n" + `headers = { A = "b" }`, "headers require url"},
+		{"bad on", `command = "true"` + "\n" + `on = "sometimes"`, `invalid on "sometimes"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, "[[hooks]]\n"+tt.hook+"\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want %q", err, tt.want)
+			}
+			if err != nil && strings.Contains(err.Error(), "secret") {
+				t.Errorf("Load() error = %v, want the URL left out", err)
+			}
+		})
+	}
+}
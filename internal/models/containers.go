@@ -0,0 +1,215 @@
+// Package models holds the types of the Podman API responses decoded by
+// podman-cli, such as container and image lists and inspect data. Field
+// names follow the API's JSON keys, so that a response decodes into them
+// as is; only the fields podman-cli or its library users need are
+// included, and others are ignored when decoding.
+//
+// The list types come in two flavors where the endpoints differ: the
+// libpod endpoints (/libpod/containers/json) and their Docker compatible
+// counterparts (/containers/json), whose human readable Status, such as
+// "Up 5 minutes (healthy)", the tables show.
+package models
+
+import "time"
+
+// Health states reported by Podman for containers with a healthcheck.
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthStarting  = "starting"
+)
+
+// ListContainer is a container listed by the libpod containers endpoint.
+type ListContainer struct {
+	ID        string            `json:"Id"`
+	Names     []string          `json:"Names"`
+	Image     string            `json:"Image"`
+	ImageID   string            `json:"ImageID"`
+	Command   []string          `json:"Command"`
+	Created   time.Time         `json:"Created"`
+	StartedAt int64             `json:"StartedAt"`
+	State     string            `json:"State"`
+	Status    string            `json:"Status"`
+	Exited    bool              `json:"Exited"`
+	ExitCode  int               `json:"ExitCode"`
+	Labels    map[string]string `json:"Labels"`
+	Mounts    []string          `json:"Mounts"`
+	Ports     []PortMapping     `json:"Ports"`
+	Networks  []string          `json:"Networks"`
+	Pod       string            `json:"Pod"`
+	PodName   string            `json:"PodName"`
+	IsInfra   bool              `json:"IsInfra"`
+}
+
+// PortMapping is a container port, or range of ports, published on the
+// host, as listed by the libpod endpoints.
+type PortMapping struct {
+	HostIP        string `json:"host_ip"`
+	ContainerPort uint16 `json:"container_port"`
+	HostPort      uint16 `json:"host_port"`
+	Range         uint16 `json:"range"`
+	Protocol      string `json:"protocol"`
+}
+
+// ContainerSummary is a container listed by the Docker compatible
+// containers endpoint. Its names start with a slash.
+type ContainerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []Port            `json:"Ports"`
+}
+
+// Port is a port of a container listed by the Docker compatible
+// endpoint, published on the host if PublicPort is set.
+type Port struct {
+	IP          string `json:"IP,omitempty"`
+	PrivatePort uint16 `json:"PrivatePort"`
+	PublicPort  uint16 `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+// InspectContainerData is the inspect data of a container returned by the
+// libpod endpoint.
+type InspectContainerData struct {
+	ID              string                     `json:"Id"`
+	Created         time.Time                  `json:"Created"`
+	Path            string                     `json:"Path"`
+	Args            []string                   `json:"Args"`
+	State           InspectContainerState      `json:"State"`
+	Image           string                     `json:"Image"`
+	ImageName       string                     `json:"ImageName"`
+	Name            string                     `json:"Name"`
+	RestartCount    int32                      `json:"RestartCount"`
+	Pod             string                     `json:"Pod"`
+	Mounts          []InspectMount             `json:"Mounts"`
+	Config          InspectContainerConfig     `json:"Config"`
+	HostConfig      InspectContainerHostConfig `json:"HostConfig"`
+	NetworkSettings InspectNetworkSettings     `json:"NetworkSettings"`
+}
+
+// InspectContainerState is the state of an inspected container.
+type InspectContainerState struct {
+	Status     string              `json:"Status"`
+	Running    bool                `json:"Running"`
+	Paused     bool                `json:"Paused"`
+	Restarting bool                `json:"Restarting"`
+	OOMKilled  bool                `json:"OOMKilled"`
+	Dead       bool                `json:"Dead"`
+	Pid        int                 `json:"Pid"`
+	ExitCode   int                 `json:"ExitCode"`
+	Error      string              `json:"Error"`
+	StartedAt  time.Time           `json:"StartedAt"`
+	FinishedAt time.Time           `json:"FinishedAt"`
+	Health     *HealthCheckResults `json:"Health,omitempty"`
+}
+
+// HealthCheckResults is the outcome of the healthchecks of a container,
+// as returned by the libpod healthcheck endpoint and in its inspect data.
+type HealthCheckResults struct {
+	Status        string           `json:"Status"`
+	FailingStreak int              `json:"FailingStreak"`
+	Log           []HealthCheckLog `json:"Log"`
+}
+
+// HealthCheckLog is a healthcheck run.
+type HealthCheckLog struct {
+	Start    string `json:"Start"`
+	End      string `json:"End"`
+	ExitCode int    `json:"ExitCode"`
+	Output   string `json:"Output"`
+}
+
+// InspectMount is a mount of an inspected container: a named volume, when
+// Type is "volume", or a bind mount of Source.
+type InspectMount struct {
+	Type        string   `json:"Type"`
+	Name        string   `json:"Name,omitempty"`
+	Source      string   `json:"Source"`
+	Destination string   `json:"Destination"`
+	Options     []string `json:"Options"`
+	RW          bool     `json:"RW"`
+}
+
+// InspectContainerConfig is the configuration of an inspected container.
+type InspectContainerConfig struct {
+	Hostname    string            `json:"Hostname"`
+	User        string            `json:"User"`
+	Env         []string          `json:"Env"`
+	Cmd         []string          `json:"Cmd"`
+	Image       string            `json:"Image"`
+	WorkingDir  string            `json:"WorkingDir"`
+	Entrypoint  []string          `json:"Entrypoint"`
+	Labels      map[string]string `json:"Labels"`
+	StopSignal  string            `json:"StopSignal"`
+	StopTimeout uint              `json:"StopTimeout"`
+	Tty         bool              `json:"Tty"`
+	OpenStdin   bool              `json:"OpenStdin"`
+}
+
+// InspectContainerHostConfig is the host configuration of an inspected
+// container. PortBindings is keyed by container port and protocol, such
+// as "80/tcp".
+type InspectContainerHostConfig struct {
+	NetworkMode   string                       `json:"NetworkMode"`
+	PortBindings  map[string][]InspectHostPort `json:"PortBindings"`
+	RestartPolicy InspectRestartPolicy         `json:"RestartPolicy"`
+	AutoRemove    bool                         `json:"AutoRemove"`
+}
+
+// InspectHostPort is the host address of a published container port.
+type InspectHostPort struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// InspectRestartPolicy is the restart policy of a container.
+type InspectRestartPolicy struct {
+	Name              string `json:"Name"`
+	MaximumRetryCount uint   `json:"MaximumRetryCount"`
+}
+
+// InspectNetworkSettings is the network configuration of an inspected
+// container. Ports is keyed as InspectContainerHostConfig.PortBindings is,
+// with the ports actually published.
+type InspectNetworkSettings struct {
+	IPAddress string                              `json:"IPAddress"`
+	Ports     map[string][]InspectHostPort        `json:"Ports"`
+	Networks  map[string]InspectAdditionalNetwork `json:"Networks,omitempty"`
+}
+
+// InspectAdditionalNetwork is the configuration of a container on one of
+// the named networks it is attached to.
+type InspectAdditionalNetwork struct {
+	NetworkID string   `json:"NetworkID"`
+	IPAddress string   `json:"IPAddress"`
+	Aliases   []string `json:"Aliases"`
+}
+
+// ContainerStats is the resource usage of a container, as returned by the
+// libpod stats endpoint.
+type ContainerStats struct {
+	ContainerID string  `json:"ContainerID"`
+	Name        string  `json:"Name"`
+	CPU         float64 `json:"CPU"`
+	MemUsage    int64   `json:"MemUsage"`
+	MemLimit    int64   `json:"MemLimit"`
+	MemPerc     float64 `json:"MemPerc"`
+	NetInput    int64   `json:"NetInput"`
+	NetOutput   int64   `json:"NetOutput"`
+	BlockInput  int64   `json:"BlockInput"`
+	BlockOutput int64   `json:"BlockOutput"`
+	PIDs        int64   `json:"PIDs"`
+}
+
+// ContainerStatsReport is a response of the libpod stats endpoint.
+type ContainerStatsReport struct {
+	Error any              `json:"Error"`
+	Stats []ContainerStats `json:"Stats"`
+}
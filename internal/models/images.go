@@ -0,0 +1,41 @@
+package models
+
+// ImageSummary is an image listed by the libpod or Docker compatible
+// images endpoints. Created is a Unix time in seconds; Names and Dangling
+// are only set by libpod.
+type ImageSummary struct {
+	ID          string            `json:"Id"`
+	ParentID    string            `json:"ParentId"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Names       []string          `json:"Names,omitempty"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	SharedSize  int64             `json:"SharedSize"`
+	VirtualSize int64             `json:"VirtualSize"`
+	Labels      map[string]string `json:"Labels"`
+	Containers  int               `json:"Containers"`
+	Dangling    bool              `json:"Dangling,omitempty"`
+}
+
+// ImageData is the inspect data of an image returned by the libpod
+// endpoint.
+type ImageData struct {
+	ID          string      `json:"Id"`
+	Digest      string      `json:"Digest"`
+	RepoTags    []string    `json:"RepoTags"`
+	RepoDigests []string    `json:"RepoDigests"`
+	Size        int64       `json:"Size"`
+	Config      ImageConfig `json:"Config"`
+}
+
+// ImageConfig holds the defaults an image gives its containers.
+type ImageConfig struct {
+	User         string              `json:"User"`
+	Env          []string            `json:"Env"`
+	Cmd          []string            `json:"Cmd"`
+	Entrypoint   []string            `json:"Entrypoint"`
+	WorkingDir   string              `json:"WorkingDir"`
+	Labels       map[string]string   `json:"Labels"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+}
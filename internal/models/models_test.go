@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestListContainer_Decode(t *testing.T) {
+	data := `[{"AutoRemove":false,"Command":["nginx","-g","daemon off;"],"Created":"2024-05-01T12:00:00.123456789Z",
+		"Exited":false,"ExitCode":0,"Id":"aaaabbbbcccc","Image":"docker.io/library/nginx:latest","Labels":{"app":"web"},
+		"Names":["web"],"Ports":[{"host_ip":"","container_port":80,"host_port":8080,"range":1,"protocol":"tcp"}],
+		"Pod":"","PodName":"","StartedAt":1714564800,"State":"running","Status":""}]`
+	var list []ListContainer
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatalf("Unmarshal() unexpected error = %v", err)
+	}
+	c := list[0]
+	if c.ID != "aaaabbbbcccc" || c.Names[0] != "web" || c.State != "running" || c.Labels["app"] != "web" {
+		t.Errorf("ListContainer = %+v", c)
+	}
+	if want := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC); !c.Created.Equal(want) {
+		t.Errorf("Created = %v, want %v", c.Created, want)
+	}
+	if p := c.Ports[0]; p.ContainerPort != 80 || p.HostPort != 8080 || p.Protocol != "tcp" {
+		t.Errorf("Ports[0] = %+v, want 8080 -> 80/tcp", p)
+	}
+}
+
+func TestInspectContainerData_Decode(t *testing.T) {
+	data := `{"Id":"aaaabbbbcccc","Name":"web","ImageName":"nginx:latest",
+		"State":{"Status":"running","Running":true,"ExitCode":0,"StartedAt":"2024-05-01T12:00:00Z","FinishedAt":"0001-01-01T00:00:00Z",
+			"Health":{"Status":"unhealthy","FailingStreak":3,"Log":[{"ExitCode":1,"Output":"connection refused"}]}},
+		"Mounts":[{"Type":"volume","Name":"data","Source":"/var/lib/containers/storage/volumes/data/_data","Destination":"/data","RW":true}],
+		"Config":{"Env":["PATH=/usr/bin"],"Cmd":["nginx"],"Labels":{"app":"web"},"Tty":true,"OpenStdin":true},
+		"HostConfig":{"PortBindings":{"80/tcp":[{"HostIp":"","HostPort":"8080"}]},"RestartPolicy":{"Name":"always"}},
+		"NetworkSettings":{"Ports":{"80/tcp":[{"HostIp":"0.0.0.0","HostPort":"8080"}]}}}`
+	var c InspectContainerData
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("Unmarshal() unexpected error = %v", err)
+	}
+	if h := c.State.Health; h == nil || h.Status != HealthUnhealthy || h.Log[0].Output != "connection refused" {
+		t.Errorf("State.Health = %+v, want unhealthy", h)
+	}
+	if !c.State.Running || !c.Config.Tty || c.Mounts[0].Name != "data" || c.HostConfig.RestartPolicy.Name != "always" {
+		t.Errorf("InspectContainerData = %+v", c)
+	}
+	if got := c.NetworkSettings.Ports["80/tcp"][0]; got.HostIP != "0.0.0.0" || got.HostPort != "8080" {
+		t.Errorf("NetworkSettings.Ports = %+v", c.NetworkSettings.Ports)
+	}
+}
+
+func TestListPodsReport_Decode(t *testing.T) {
+	data := `[{"Cgroup":"user.slice","Containers":[{"Id":"1111","Names":"web-infra","Status":"running"},{"Id":"2222","Names":"web","Status":"running"}],
+		"Created":"2024-05-01T12:00:00Z","Id":"pod1","InfraId":"1111","Name":"web","Namespace":"","Networks":["podman"],"Status":"Running","Labels":{}}]`
+	var pods []ListPodsReport
+	if err := json.Unmarshal([]byte(data), &pods); err != nil {
+		t.Fatalf("Unmarshal() unexpected error = %v", err)
+	}
+	if p := pods[0]; p.Name != "web" || p.InfraID != "1111" || len(p.Containers) != 2 || p.Containers[1].Names != "web" {
+		t.Errorf("ListPodsReport = %+v", p)
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ListPodsReport is a pod listed by the libpod pods endpoint.
+type ListPodsReport struct {
+	ID         string              `json:"Id"`
+	Name       string              `json:"Name"`
+	Namespace  string              `json:"Namespace"`
+	Status     string              `json:"Status"`
+	Created    time.Time           `json:"Created"`
+	InfraID    string              `json:"InfraId"`
+	Cgroup     string              `json:"Cgroup"`
+	Labels     map[string]string   `json:"Labels"`
+	Networks   []string            `json:"Networks"`
+	Containers []*ListPodContainer `json:"Containers"`
+}
+
+// ListPodContainer is a container of a listed pod.
+type ListPodContainer struct {
+	ID     string `json:"Id"`
+	Names  string `json:"Names"`
+	Status string `json:"Status"`
+}
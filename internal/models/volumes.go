@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// VolumeConfigResponse is a volume listed or inspected through the libpod
+// volumes endpoints.
+type VolumeConfigResponse struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  time.Time         `json:"CreatedAt"`
+	Labels     map[string]string `json:"Labels"`
+	Scope      string            `json:"Scope"`
+	Options    map[string]string `json:"Options"`
+	UID        int               `json:"UID"`
+	GID        int               `json:"GID"`
+}
@@ -0,0 +1,128 @@
+// Package signature verifies the signatures of container images against
+// a signature policy in the containers-policy.json format used by Podman,
+// Buildah and Skopeo, on the machine running podman-cli rather than on
+// the remote host, whose Podman API does not accept a policy.
+//
+// Signatures stored in the registry by cosign (sigstoreSigned
+// requirements with public keys) are verified; requirements that cannot
+// be checked here, such as GPG signatures (signedBy) or keyless Fulcio
+// certificates, fail verification rather than being skipped.
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Types of policy requirements.
+const (
+	requireAnything = "insecureAcceptAnything"
+	requireReject   = "reject"
+	requireGPG      = "signedBy"
+	requireSigstore = "sigstoreSigned"
+)
+
+// dockerTransport is the transport of the policy applying to images
+// pulled from registries.
+const dockerTransport = "docker"
+
+// Policy is a containers-policy.json file: the requirements images must
+// meet, by transport and scope, with a default for other images.
+type Policy struct {
+	Default    []Requirement                       `json:"default"`
+	Transports map[string]map[string][]Requirement `json:"transports"`
+
+	// dir is the directory of the policy file, against which relative
+	// key paths are resolved.
+	dir string
+}
+
+// Requirement is a requirement of a policy. Which fields apply depends on
+// its type.
+type Requirement struct {
+	Type               string          `json:"type"`
+	KeyType            string          `json:"keyType,omitempty"`
+	KeyPath            string          `json:"keyPath,omitempty"`
+	KeyPaths           []string        `json:"keyPaths,omitempty"`
+	KeyData            []byte          `json:"keyData,omitempty"`
+	KeyDatas           [][]byte        `json:"keyDatas,omitempty"`
+	Fulcio             json.RawMessage `json:"fulcio,omitempty"`
+	PKI                json.RawMessage `json:"pki,omitempty"`
+	RekorPublicKeyPath string          `json:"rekorPublicKeyPath,omitempty"`
+	RekorPublicKeyData []byte          `json:"rekorPublicKeyData,omitempty"`
+	SignedIdentity     *SignedIdentity `json:"signedIdentity,omitempty"`
+}
+
+// SignedIdentity says which image reference a signature must name for a
+// signedBy or sigstoreSigned requirement.
+type SignedIdentity struct {
+	Type             string `json:"type"`
+	DockerReference  string `json:"dockerReference,omitempty"`
+	DockerRepository string `json:"dockerRepository,omitempty"`
+}
+
+// LoadPolicy reads the policy file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Default == nil {
+		return nil, fmt.Errorf("%s: the default requirements are missing", path)
+	}
+	check := func(scope string, reqs []Requirement) error {
+		if len(reqs) == 0 {
+			return fmt.Errorf("%s: no requirements for %s", path, scope)
+		}
+		for _, req := range reqs {
+			switch req.Type {
+			case requireAnything, requireReject, requireGPG, requireSigstore:
+			default:
+				return fmt.Errorf("%s: unknown requirement type %q for %s", path, req.Type, scope)
+			}
+		}
+		return nil
+	}
+	if err := check("default", p.Default); err != nil {
+		return nil, err
+	}
+	for transport, scopes := range p.Transports {
+		for scope, reqs := range scopes {
+			if err := check(fmt.Sprintf("%s scope %q", transport, scope), reqs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.dir = filepath.Dir(path)
+	return &p, nil
+}
+
+// requirements returns the requirements of the most specific scope of the
+// docker transport matching ref, or else those of the transport's default
+// scope, or else the policy's default, with the scope they come from.
+func (p *Policy) requirements(ref Reference) ([]Requirement, string) {
+	scopes := p.Transports[dockerTransport]
+	for _, scope := range ref.scopes() {
+		if reqs, ok := scopes[scope]; ok {
+			return reqs, scope
+		}
+	}
+	if reqs, ok := scopes[""]; ok {
+		return reqs, dockerTransport + " default"
+	}
+	return p.Default, "default"
+}
+
+// keyPath resolves a key path of the policy.
+func (p *Policy) keyPath(path string) string {
+	if filepath.IsAbs(path) || p.dir == "" {
+		return path
+	}
+	return filepath.Join(p.dir, path)
+}
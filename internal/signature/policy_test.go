@@ -0,0 +1,73 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicy(t *testing.T, dir, policy string) string {
+	t.Helper()
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPolicy_Requirements(t *testing.T) {
+	path := writePolicy(t, t.TempDir(), `{
+		"default": [{"type": "reject"}],
+		"transports": {
+			"docker": {
+				"quay.io/team": [{"type": "sigstoreSigned", "keyPath": "team.pub"}],
+				"quay.io/team/tools": [{"type": "insecureAcceptAnything"}],
+				"*.example.com": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": "/etc/pki/gpg"}]
+			}
+		}
+	}`)
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		image, wantType, wantScope string
+	}{
+		{"quay.io/team/app:v1", requireSigstore, "quay.io/team"},
+		{"quay.io/team/tools:v1", requireAnything, "quay.io/team/tools"},
+		{"registry.example.com/app", requireGPG, "*.example.com"},
+		{"nginx", requireReject, "default"},
+	}
+	for _, tt := range tests {
+		ref, err := ParseReference(tt.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs, scope := p.requirements(ref)
+		if len(reqs) != 1 || reqs[0].Type != tt.wantType || scope != tt.wantScope {
+			t.Errorf("requirements(%s) = %+v from %q, want %s from %q", tt.image, reqs, scope, tt.wantType, tt.wantScope)
+		}
+	}
+
+	if got, want := p.keyPath("team.pub"), filepath.Join(filepath.Dir(path), "team.pub"); got != want {
+		t.Errorf("keyPath() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no default":       `{"transports": {}}`,
+		"empty scope":      `{"default": [{"type": "reject"}], "transports": {"docker": {"quay.io": []}}}`,
+		"unknown type":     `{"default": [{"type": "acceptSomething"}]}`,
+		"malformed policy": `{"default": [`,
+	}
+	for name, policy := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadPolicy(writePolicy(t, t.TempDir(), policy)); err == nil || !strings.Contains(err.Error(), "policy.json") {
+				t.Errorf("LoadPolicy() error = %v, want an error naming the file", err)
+			}
+		})
+	}
+}
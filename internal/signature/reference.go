@@ -0,0 +1,111 @@
+package signature
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alexjch/podman-cli/internal/auth"
+)
+
+// digestPattern matches the digests of image manifests.
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Reference is a fully qualified image reference, as Podman resolves short
+// names: "nginx" is docker.io/library/nginx:latest.
+type Reference struct {
+	Registry string // registry host, with its port if any
+	Path     string // repository path within the registry
+	Tag      string // tag, "" if the reference only has a digest
+	Digest   string // manifest digest, "" if not given
+}
+
+// ParseReference parses an image reference, filling in the default
+// registry, the library namespace of docker.io and the latest tag.
+func ParseReference(image string) (Reference, error) {
+	var ref Reference
+	name := image
+	if before, digest, ok := strings.Cut(name, "@"); ok {
+		if !digestPattern.MatchString(digest) {
+			return Reference{}, fmt.Errorf("invalid image reference %q: unsupported digest", image)
+		}
+		name, ref.Digest = before, digest
+	}
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	ref.Registry = auth.RegistryFromImage(name)
+	ref.Path = name
+	if first, rest, ok := strings.Cut(name, "/"); ok && first == ref.Registry {
+		ref.Path = rest
+	}
+	if ref.Registry == auth.DefaultRegistry && !strings.Contains(ref.Path, "/") {
+		ref.Path = "library/" + ref.Path
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if ref.Path == "" || ref.Path != strings.ToLower(ref.Path) || strings.Contains(ref.Path, "//") || strings.HasPrefix(ref.Path, "/") || strings.HasSuffix(ref.Path, "/") {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	return ref, nil
+}
+
+// Repository returns the repository of the reference, such as
+// docker.io/library/nginx.
+func (r Reference) Repository() string {
+	return r.Registry + "/" + r.Path
+}
+
+// String returns the reference as repository:tag, repository@digest, or
+// repository:tag@digest.
+func (r Reference) String() string {
+	s := r.Repository()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// host returns the address of the registry's API.
+func (r Reference) host() string {
+	if r.Registry == auth.DefaultRegistry {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
+
+// scopes returns the scopes of the docker transport of a policy that apply
+// to the reference, from the most to the least specific: the reference
+// itself, its repository, its namespaces, its registry, and wildcards of
+// the registry's parent domains.
+func (r Reference) scopes() []string {
+	scopes := []string{r.String()}
+	if r.Tag != "" && r.Digest != "" {
+		// A policy names either the tag or the digest of an image
+		scopes = []string{r.Repository() + ":" + r.Tag, r.Repository() + "@" + r.Digest}
+	}
+	for path := r.Path; path != ""; {
+		scopes = append(scopes, r.Registry+"/"+path)
+		i := strings.LastIndexByte(path, '/')
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	scopes = append(scopes, r.Registry)
+	host, _, _ := strings.Cut(r.Registry, ":")
+	for {
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		scopes = append(scopes, "*."+parent)
+		host = parent
+	}
+	return scopes
+}
@@ -0,0 +1,59 @@
+package signature
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		image string
+		want  Reference
+	}{
+		{"nginx", Reference{Registry: "docker.io", Path: "library/nginx", Tag: "latest"}},
+		{"docker.io/nginx:1.27", Reference{Registry: "docker.io", Path: "library/nginx", Tag: "1.27"}},
+		{"quay.io/team/app:v1", Reference{Registry: "quay.io", Path: "team/app", Tag: "v1"}},
+		{"registry.local:5000/app", Reference{Registry: "registry.local:5000", Path: "app", Tag: "latest"}},
+		{"quay.io/team/app@" + digest, Reference{Registry: "quay.io", Path: "team/app", Digest: digest}},
+		{"quay.io/team/app:v1@" + digest, Reference{Registry: "quay.io", Path: "team/app", Tag: "v1", Digest: digest}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseReference(tt.image)
+		if err != nil {
+			t.Errorf("ParseReference(%q) unexpected error = %v", tt.image, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestParseReference_Invalid(t *testing.T) {
+	for _, image := range []string{"quay.io/Team/app", "quay.io/app@sha256:abc", "quay.io//app", "quay.io/"} {
+		if _, err := ParseReference(image); err == nil {
+			t.Errorf("ParseReference(%q) error = nil, want an error", image)
+		}
+	}
+}
+
+func TestReference_Scopes(t *testing.T) {
+	ref, err := ParseReference("registry.example.com:5000/team/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"registry.example.com:5000/team/app:v1",
+		"registry.example.com:5000/team/app",
+		"registry.example.com:5000/team",
+		"registry.example.com:5000",
+		"*.example.com",
+		"*.com",
+	}
+	if got := ref.scopes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("scopes() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+package signature
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxManifestSize and maxPayloadSize bound what is read of manifests and
+// signature payloads, which are small.
+const (
+	maxManifestSize = 4 << 20
+	maxPayloadSize  = 1 << 20
+)
+
+// manifestTypes are the manifest media types accepted from registries.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// errNotFound is returned for manifests and blobs missing in the registry.
+var errNotFound = errors.New("not found")
+
+// Registry reads manifests and blobs from the registries of images
+// through the OCI distribution API, authenticating with the token or basic
+// authentication schemes the registry asks for.
+type Registry struct {
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+
+	// Username and Password are sent to the registry, or to its token
+	// service, when it requires authentication.
+	Username string
+	Password string
+
+	authorization string
+}
+
+// manifest returns the manifest of the repository of ref named by
+// reference, a tag or digest, with its digest. A manifest requested by
+// digest must match it.
+func (r *Registry) manifest(ctx context.Context, ref Reference, reference string) ([]byte, string, error) {
+	resp, err := r.get(ctx, ref, "manifests/"+reference, strings.Join(manifestTypes, ", "))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) > maxManifestSize {
+		return nil, "", fmt.Errorf("manifest %s of %s is too large", reference, ref.Repository())
+	}
+	sum := sha256.Sum256(body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(reference, "sha256:") && digest != reference {
+		return nil, "", fmt.Errorf("manifest %s of %s does not match its digest", reference, ref.Repository())
+	}
+	return body, digest, nil
+}
+
+// blob returns the blob of the repository of ref with the given digest,
+// of at most limit bytes.
+func (r *Registry) blob(ctx context.Context, ref Reference, digest string, limit int64) ([]byte, error) {
+	if !digestPattern.MatchString(digest) {
+		return nil, fmt.Errorf("unsupported blob digest %q", digest)
+	}
+	resp, err := r.get(ctx, ref, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("blob %s of %s is too large", digest, ref.Repository())
+	}
+	if sum := sha256.Sum256(data); "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("blob %s of %s does not match its digest", digest, ref.Repository())
+	}
+	return data, nil
+}
+
+// get sends a GET request for path under the repository of ref in the
+// registry API, authenticating once if the registry asks for it.
+func (r *Registry) get(ctx context.Context, ref Reference, path, accept string) (*http.Response, error) {
+	uri := "https://" + ref.host() + "/v2/" + ref.Path + "/" + path
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if r.authorization != "" {
+			req.Header.Set("Authorization", r.authorization)
+		}
+		resp, err := r.client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return resp, nil
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := r.authenticate(ctx, challenge, ref); err != nil {
+				return nil, fmt.Errorf("%s: %w", ref.Registry, err)
+			}
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s of %s: %w", path, ref.Repository(), errNotFound)
+		}
+		return nil, fmt.Errorf("%s of %s: registry returned %s", path, ref.Repository(), resp.Status)
+	}
+}
+
+// authenticate sets the authorization of the following requests from the
+// challenge of a 401 response: the credentials themselves for the basic
+// scheme, or a token of the registry's token service for the bearer
+// scheme, requested with the credentials if any.
+func (r *Registry) authenticate(ctx context.Context, challenge string, ref Reference) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if r.Username == "" {
+			return errors.New("authentication required (log in to the registry first)")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(r.Username, r.Password)
+		r.authorization = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" && realm.Scheme != "http" {
+		return fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", "repository:"+ref.Path+":pull")
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token service returned %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPayloadSize)).Decode(&token); err != nil {
+		return fmt.Errorf("token service: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return errors.New("token service returned no token")
+	}
+	r.authorization = "Bearer " + token.Token
+	return nil
+}
+
+func (r *Registry) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// parameters, as in: Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			end := strings.IndexByte(value[1:], '"')
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key], rest = value[1:end+1], value[end+2:]
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+		rest = strings.TrimLeft(strings.TrimSpace(rest), ",")
+	}
+	return scheme, params
+}
@@ -0,0 +1,291 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cosignSignatureAnnotation is the annotation of the layers of a cosign
+// signature manifest carrying the signature of the layer, the payload.
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// cosignPayloadType is the type of the payloads signed by cosign.
+const cosignPayloadType = "cosign container image signature"
+
+// Types of signed identities.
+const (
+	identityMatchExact             = "matchExact"
+	identityMatchRepoDigestOrExact = "matchRepoDigestOrExact"
+	identityMatchRepository        = "matchRepository"
+	identityExactReference         = "exactReference"
+	identityExactRepository        = "exactRepository"
+)
+
+// sigstoreSignature is a signature stored by cosign in the registry: a
+// payload naming the image and its signature.
+type sigstoreSignature struct {
+	payload   []byte
+	signature []byte
+}
+
+// sigstorePayload is the part of a cosign payload that is verified.
+type sigstorePayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// Verify checks that image may be pulled under the policy, reading its
+// manifest and signatures from its registry with reg, and returns the
+// image's reference pinned to the digest that was verified, so that
+// exactly that image can be pulled even if its tag moves meanwhile.
+// All requirements of the scope applying to the image must be met.
+func (p *Policy) Verify(ctx context.Context, reg *Registry, image string) (Reference, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return Reference{}, err
+	}
+	reqs, scope := p.requirements(ref)
+	for _, req := range reqs {
+		switch {
+		case req.Type == requireReject:
+			return Reference{}, fmt.Errorf("%s is rejected by the policy (scope %s)", ref, scope)
+		case req.Type == requireGPG:
+			return Reference{}, fmt.Errorf("%s requires GPG signatures (scope %s), which cannot be verified here", ref, scope)
+		case len(req.Fulcio) > 0 || len(req.PKI) > 0 || req.RekorPublicKeyPath != "" || len(req.RekorPublicKeyData) > 0:
+			return Reference{}, fmt.Errorf("%s requires Fulcio, PKI or Rekor verification (scope %s), which is not supported here", ref, scope)
+		}
+	}
+
+	manifestRef := ref.Digest
+	if manifestRef == "" {
+		manifestRef = ref.Tag
+	}
+	_, digest, err := reg.manifest(ctx, ref, manifestRef)
+	if err != nil {
+		return Reference{}, err
+	}
+	pinned := ref
+	pinned.Digest = digest
+
+	var signatures []sigstoreSignature
+	fetched := false
+	for _, req := range reqs {
+		if req.Type != requireSigstore {
+			continue
+		}
+		keys, err := p.publicKeys(req)
+		if err != nil {
+			return Reference{}, err
+		}
+		if !fetched {
+			if signatures, err = sigstoreSignatures(ctx, reg, ref, digest); err != nil {
+				return Reference{}, err
+			}
+			fetched = true
+		}
+		if len(signatures) == 0 {
+			return Reference{}, fmt.Errorf("%s is not signed (scope %s requires a sigstore signature)", ref, scope)
+		}
+		var errs []error
+		for _, sig := range signatures {
+			err := verifySigstore(sig, keys, digest, ref, req.SignedIdentity)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err)
+		}
+		if errs != nil {
+			return Reference{}, fmt.Errorf("no valid signature of %s for scope %s: %w", ref, scope, errors.Join(errs...))
+		}
+	}
+	return pinned, nil
+}
+
+// publicKeys returns the public keys of a sigstoreSigned requirement.
+func (p *Policy) publicKeys(req Requirement) ([]crypto.PublicKey, error) {
+	data := append([][]byte(nil), req.KeyDatas...)
+	if req.KeyData != nil {
+		data = append(data, req.KeyData)
+	}
+	paths := append([]string(nil), req.KeyPaths...)
+	if req.KeyPath != "" {
+		paths = append(paths, req.KeyPath)
+	}
+	for _, path := range paths {
+		pem, err := os.ReadFile(p.keyPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("policy key: %w", err)
+		}
+		data = append(data, pem)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("policy: a sigstoreSigned requirement has no public key")
+	}
+
+	var keys []crypto.PublicKey
+	for _, d := range data {
+		block, _ := pem.Decode(d)
+		if block == nil || block.Type != "PUBLIC KEY" {
+			return nil, errors.New("policy: a key is not a PEM encoded public key")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("policy key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sigstoreSignatures returns the signatures cosign stored in the
+// repository of ref for the manifest digest, under the tag
+// sha256-<hex>.sig.
+func sigstoreSignatures(ctx context.Context, reg *Registry, ref Reference, digest string) ([]sigstoreSignature, error) {
+	body, _, err := reg.manifest(ctx, ref, strings.Replace(digest, ":", "-", 1)+".sig")
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signatures: %w", err)
+	}
+	var manifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("signatures: %w", err)
+	}
+
+	var signatures []sigstoreSignature
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		payload, err := reg.blob(ctx, ref, layer.Digest, maxPayloadSize)
+		if err != nil {
+			return nil, fmt.Errorf("signature payload: %w", err)
+		}
+		signatures = append(signatures, sigstoreSignature{payload: payload, signature: sig})
+	}
+	return signatures, nil
+}
+
+// verifySigstore checks that sig is a signature by one of keys naming the
+// manifest digest and an identity of ref allowed by identity.
+func verifySigstore(sig sigstoreSignature, keys []crypto.PublicKey, digest string, ref Reference, identity *SignedIdentity) error {
+	verified := false
+	for _, key := range keys {
+		if verifyWithKey(key, sig.payload, sig.signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return errors.New("signature does not match the policy keys")
+	}
+
+	var payload sigstorePayload
+	if err := json.Unmarshal(sig.payload, &payload); err != nil {
+		return fmt.Errorf("signature payload: %w", err)
+	}
+	if payload.Critical.Type != cosignPayloadType {
+		return fmt.Errorf("signature payload of unsupported type %q", payload.Critical.Type)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for another image, %s", payload.Critical.Image.DockerManifestDigest)
+	}
+	return matchIdentity(identity, ref, payload.Critical.Identity.DockerReference)
+}
+
+// verifyWithKey reports whether signature is the signature of payload by
+// key, with SHA-256 for ECDSA and RSA keys, as cosign signs.
+func verifyWithKey(key crypto.PublicKey, payload, signature []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil ||
+			rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	}
+	return false
+}
+
+// matchIdentity checks that signed, the reference named by a signature,
+// is allowed for ref by identity, matchRepoDigestOrExact if nil.
+func matchIdentity(identity *SignedIdentity, ref Reference, signed string) error {
+	signedRef, err := ParseReference(signed)
+	if err != nil {
+		return fmt.Errorf("signature identity: %w", err)
+	}
+	// Signatures naming a repository only, as cosign writes them, name
+	// no tag rather than latest
+	if !strings.ContainsAny(signed[strings.LastIndexByte(signed, '/')+1:], ":@") {
+		signedRef.Tag = ""
+	}
+
+	kind := identityMatchRepoDigestOrExact
+	if identity != nil {
+		kind = identity.Type
+	}
+	ok := false
+	switch kind {
+	case identityMatchExact:
+		ok = signedRef.String() == ref.String()
+	case identityMatchRepoDigestOrExact:
+		if ref.Digest != "" {
+			ok = signedRef.Repository() == ref.Repository()
+		} else {
+			ok = signedRef.String() == ref.String()
+		}
+	case identityMatchRepository:
+		ok = signedRef.Repository() == ref.Repository()
+	case identityExactReference:
+		want, err := ParseReference(identity.DockerReference)
+		if err != nil {
+			return fmt.Errorf("policy signedIdentity: %w", err)
+		}
+		ok = signedRef.String() == want.String()
+	case identityExactRepository:
+		want, err := ParseReference(identity.DockerRepository)
+		if err != nil {
+			return fmt.Errorf("policy signedIdentity: %w", err)
+		}
+		ok = signedRef.Repository() == want.Repository()
+	default:
+		return fmt.Errorf("policy signedIdentity %q is not supported here", kind)
+	}
+	if !ok {
+		return fmt.Errorf("signature names %s, which the policy does not accept for %s (%s)", signed, ref, kind)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package signature
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRegistry is a registry serving one image, team/app:v1, and the
+// cosign signatures added to it, behind token authentication.
+type testRegistry struct {
+	server    *httptest.Server
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	digest    string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	r.server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`)
+	r.digest = digestOf(manifest)
+	r.manifests["v1"] = manifest
+	r.manifests[r.digest] = manifest
+	return r
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if req.URL.Query().Get("scope") != "repository:team/app:pull" {
+			http.Error(w, "bad scope", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token":"secret"}`))
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if name, ok := strings.CutPrefix(req.URL.Path, "/v2/team/app/manifests/"); ok {
+		if data, ok := r.manifests[name]; ok {
+			w.Write(data)
+			return
+		}
+	}
+	if name, ok := strings.CutPrefix(req.URL.Path, "/v2/team/app/blobs/"); ok {
+		if data, ok := r.blobs[name]; ok {
+			w.Write(data)
+			return
+		}
+	}
+	http.NotFound(w, req)
+}
+
+// image returns the reference of the registry's image with the given tag
+// or digest suffix.
+func (r *testRegistry) image(suffix string) string {
+	return strings.TrimPrefix(r.server.URL, "https://") + "/team/app" + suffix
+}
+
+// sign adds a cosign signature by key of a payload naming identity and
+// digest.
+func (r *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, identity, digest string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, identity, digest))
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.blobs[digestOf(payload)] = payload
+
+	tag := strings.Replace(r.digest, ":", "-", 1) + ".sig"
+	var manifest struct {
+		Layers []map[string]any `json:"layers"`
+	}
+	if data, ok := r.manifests[tag]; ok {
+		json.Unmarshal(data, &manifest)
+	}
+	manifest.Layers = append(manifest.Layers, map[string]any{
+		"digest":      digestOf(payload),
+		"annotations": map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	})
+	data, _ := json.Marshal(manifest)
+	r.manifests[tag] = data
+}
+
+// newTestPolicy writes the public key of key and a policy requiring
+// sigstore signatures by it for the registry, with the given signed
+// identity, returning the loaded policy.
+func newTestPolicy(t *testing.T, r *testRegistry, key *ecdsa.PrivateKey, identity string) *Policy {
+	t.Helper()
+	dir := t.TempDir()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cosign.pub"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry := strings.TrimPrefix(r.server.URL, "https://")
+	policy := fmt.Sprintf(`{"default":[{"type":"reject"}],"transports":{"docker":{%q:[{"type":"sigstoreSigned","keyPath":"cosign.pub"%s}]}}}`, registry, identity)
+	p, err := LoadPolicy(writePolicy(t, dir, policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestVerify_Signed(t *testing.T) {
+	r := newTestRegistry(t)
+	key := newTestKey(t)
+	r.sign(t, key, r.image(""), r.digest)
+	p := newTestPolicy(t, r, key, `,"signedIdentity":{"type":"matchRepository"}`)
+
+	ref, err := p.Verify(context.Background(), &Registry{Client: r.server.Client()}, r.image(":v1"))
+	if err != nil {
+		t.Fatalf("Verify() unexpected error = %v", err)
+	}
+	if ref.Digest != r.digest || ref.Tag != "v1" {
+		t.Errorf("Verify() = %s, want the tag v1 pinned to %s", ref, r.digest)
+	}
+
+	// The default identity needs a signature naming the tag, or a digest
+	if _, err := p.Verify(context.Background(), &Registry{Client: r.server.Client()}, r.image("@"+r.digest)); err != nil {
+		t.Errorf("Verify() by digest unexpected error = %v", err)
+	}
+}
+
+func TestVerify_Refused(t *testing.T) {
+	tests := []struct {
+		name     string
+		sign     func(r *testRegistry, key *ecdsa.PrivateKey)
+		identity string
+		want     string
+	}{
+		{
+			name: "unsigned",
+			sign: func(*testRegistry, *ecdsa.PrivateKey) {},
+			want: "is not signed",
+		},
+		{
+			name: "other key",
+			sign: func(r *testRegistry, _ *ecdsa.PrivateKey) { r.sign(t, newTestKey(t), r.image(":v1"), r.digest) },
+			want: "does not match the policy keys",
+		},
+		{
+			name: "other image",
+			sign: func(r *testRegistry, key *ecdsa.PrivateKey) {
+				r.sign(t, key, r.image(":v1"), "sha256:"+strings.Repeat("0", 64))
+			},
+			want: "for another image",
+		},
+		{
+			name: "other tag",
+			sign: func(r *testRegistry, key *ecdsa.PrivateKey) { r.sign(t, key, r.image(":v2"), r.digest) },
+			want: "does not accept",
+		},
+		{
+			name:     "keyless",
+			sign:     func(r *testRegistry, key *ecdsa.PrivateKey) { r.sign(t, key, r.image(":v1"), r.digest) },
+			identity: `,"fulcio":{"caPath":"fulcio.pem"}`,
+			want:     "not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t)
+			key := newTestKey(t)
+			tt.sign(r, key)
+			p := newTestPolicy(t, r, key, tt.identity)
+
+			_, err := p.Verify(context.Background(), &Registry{Client: r.server.Client()}, r.image(":v1"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify_Reject(t *testing.T) {
+	r := newTestRegistry(t)
+	p := newTestPolicy(t, r, newTestKey(t), "")
+
+	_, err := p.Verify(context.Background(), &Registry{Client: r.server.Client()}, "quay.io/team/app:v1")
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Verify() error = %v, want the default reject", err)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io", scope="repository:library/nginx:pull,push"`)
+	if scheme != "Bearer" {
+		t.Errorf("parseChallenge() scheme = %q, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("parseChallenge() %s = %q, want %q", key, params[key], value)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+// Package testserver implements an in-memory subset of the Podman API, for
+// end-to-end tests of the CLI and for trying it without a remote host. A
+// Server is seeded with fixtures and keeps the changes made through the API,
+// such as containers created, started or removed, for as long as it runs.
+//
+// The server answers both the compat and libpod forms of these endpoints,
+// with or without a version prefix:
+//
+//   - GET _ping, version and libpod/version
+//   - GET containers/json and libpod/containers/json, with the all and
+//     filters (id, label, name, status) parameters
+//   - GET containers/{name}/json
+//   - POST libpod/containers/create
+//   - POST containers/{name}/start, stop, restart, kill, pause and unpause
+//   - DELETE containers/{name}, with the force parameter
+//   - GET images/json
+//   - GET libpod/pods/json, which lists no pods
+//   - POST libpod/system/reset
+//
+// Other requests get a 404 response with a Podman error body.
+package testserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Container states, as reported in the State field of container lists.
+const (
+	StateCreated = "created"
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateExited  = "exited"
+)
+
+// Container is a container of the fixtures.
+type Container struct {
+	ID       string            `json:"id,omitempty"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	State    string            `json:"state,omitempty"` // defaults to created
+	ExitCode int               `json:"exitCode,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created,omitempty"`
+}
+
+// Image is an image of the fixtures.
+type Image struct {
+	ID      string    `json:"id,omitempty"`
+	Tags    []string  `json:"tags"`
+	Size    int64     `json:"size,omitempty"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+// Fixtures is the initial state of a Server.
+type Fixtures struct {
+	Version    string      `json:"version,omitempty"` // Podman version reported; defaults to DefaultVersion
+	Containers []Container `json:"containers,omitempty"`
+	Images     []Image     `json:"images,omitempty"`
+}
+
+// DefaultVersion is the Podman version reported when the fixtures do not
+// set one.
+const DefaultVersion = "4.9.3"
+
+// LoadFixtures reads fixtures from the JSON file at path.
+func LoadFixtures(path string) (Fixtures, error) {
+	var f Fixtures
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("fixtures %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// DemoFixtures returns a few containers and images to explore the CLI
+// with.
+func DemoFixtures() Fixtures {
+	return Fixtures{
+		Containers: []Container{
+			{Name: "web", Image: "docker.io/library/nginx:latest", State: StateRunning, Labels: map[string]string{"app": "web", "tier": "frontend"}},
+			{Name: "db", Image: "docker.io/library/postgres:16", State: StateRunning, Labels: map[string]string{"app": "db", "tier": "backend"}},
+			{Name: "migrate", Image: "docker.io/library/postgres:16", State: StateExited, Labels: map[string]string{"app": "db"}},
+		},
+		Images: []Image{
+			{Tags: []string{"docker.io/library/nginx:latest"}, Size: 192 << 20},
+			{Tags: []string{"docker.io/library/postgres:16"}, Size: 438 << 20},
+			{Tags: []string{"docker.io/library/alpine:latest"}, Size: 8 << 20},
+		},
+	}
+}
+
+// Server is an http.Handler answering Podman API requests from its
+// in-memory state. It is safe for concurrent use.
+type Server struct {
+	mu         sync.Mutex
+	version    string
+	containers []*Container
+	images     []Image
+}
+
+// New returns a server seeded with f. Containers and images without an ID
+// are given a random one, and containers without a state are created.
+func New(f Fixtures) *Server {
+	s := &Server{version: f.Version}
+	if s.version == "" {
+		s.version = DefaultVersion
+	}
+	now := time.Now()
+	for _, c := range f.Containers {
+		if c.ID == "" {
+			c.ID = newID()
+		}
+		if c.State == "" {
+			c.State = StateCreated
+		}
+		if c.Created.IsZero() {
+			c.Created = now
+		}
+		s.containers = append(s.containers, &c)
+	}
+	for _, img := range f.Images {
+		if img.ID == "" {
+			img.ID = newID()
+		}
+		if img.Created.IsZero() {
+			img.Created = now
+		}
+		s.images = append(s.images, img)
+	}
+	return s
+}
+
+// Serve answers API requests on connections accepted by l, such as a Unix
+// socket listener, until l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	return (&http.Server{Handler: s}).Serve(l)
+}
+
+// Container returns the current state of the container with the given
+// name or ID.
+func (s *Server) Container(name string) (Container, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.lookup(name); c != nil {
+		return *c, true
+	}
+	return Container{}, false
+}
+
+// versionPrefix matches the optional API version at the start of request
+// paths, such as /v3.0.0 or /v1.41.
+var versionPrefix = regexp.MustCompile(`^/v[0-9][0-9.]*`)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := versionPrefix.ReplaceAllString(r.URL.Path, "")
+	path, libpod := strings.CutPrefix(path, "/libpod")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && path == "/_ping":
+		w.Header().Set("Libpod-Api-Version", s.version)
+		w.Write([]byte("OK"))
+	case r.Method == http.MethodGet && path == "/version":
+		s.serveVersion(w)
+	case r.Method == http.MethodGet && path == "/containers/json":
+		s.listContainers(w, r, libpod)
+	case r.Method == http.MethodPost && path == "/containers/create" && libpod:
+		s.createContainer(w, r)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "containers" && parts[2] == "json":
+		s.inspectContainer(w, parts[1])
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "containers":
+		s.containerAction(w, parts[1], parts[2])
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "containers":
+		s.removeContainer(w, r, parts[1])
+	case r.Method == http.MethodGet && path == "/images/json":
+		s.listImages(w)
+	case r.Method == http.MethodGet && path == "/pods/json" && libpod:
+		writeJSON(w, http.StatusOK, []any{})
+	case r.Method == http.MethodPost && path == "/system/reset" && libpod:
+		s.containers, s.images = nil, nil
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s is not implemented by the mock server", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) serveVersion(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"Version":       s.version,
+		"ApiVersion":    "1.41",
+		"MinAPIVersion": "1.24",
+		"Os":            runtime.GOOS,
+		"Arch":          runtime.GOARCH,
+		"Components":    []map[string]string{{"Name": "Podman Engine", "Version": s.version}},
+	})
+}
+
+func (s *Server) listContainers(w http.ResponseWriter, r *http.Request, libpod bool) {
+	filters := map[string][]string{}
+	if f := r.URL.Query().Get("filters"); f != "" {
+		if err := json.Unmarshal([]byte(f), &filters); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to parse filters: "+err.Error())
+			return
+		}
+	}
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+
+	list := []map[string]any{}
+	for _, c := range s.containers {
+		if !all && len(filters["status"]) == 0 && c.State != StateRunning {
+			continue
+		}
+		if !matchFilters(c, filters) {
+			continue
+		}
+		// libpod names have no leading slash and creation times are
+		// RFC 3339 rather than Unix times
+		var names []string
+		var created any
+		if libpod {
+			names, created = []string{c.Name}, c.Created.Format(time.RFC3339Nano)
+		} else {
+			names, created = []string{"/" + c.Name}, c.Created.Unix()
+		}
+		list = append(list, map[string]any{
+			"Id":      c.ID,
+			"Names":   names,
+			"Image":   c.Image,
+			"State":   c.State,
+			"Status":  status(c),
+			"Labels":  c.Labels,
+			"Created": created,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// matchFilters reports whether c matches filters, which it must do for
+// every key and for at least one value of each, as Podman filters do.
+func matchFilters(c *Container, filters map[string][]string) bool {
+	for key, values := range filters {
+		match := false
+		for _, v := range values {
+			switch key {
+			case "id":
+				match = match || strings.HasPrefix(c.ID, v)
+			case "name":
+				match = match || strings.Contains(c.Name, v)
+			case "status":
+				match = match || c.State == v
+			case "label":
+				k, want, hasValue := strings.Cut(v, "=")
+				got, ok := c.Labels[k]
+				match = match || ok && (!hasValue || got == want)
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// status returns the human-readable status of c, as in compat container
+// lists.
+func status(c *Container) string {
+	switch c.State {
+	case StateRunning:
+		return "Up"
+	case StatePaused:
+		return "Up (Paused)"
+	case StateExited:
+		return fmt.Sprintf("Exited (%d)", c.ExitCode)
+	}
+	return "Created"
+}
+
+func (s *Server) inspectContainer(w http.ResponseWriter, name string) {
+	c := s.lookup(name)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "no such container "+name)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"Id":      c.ID,
+		"Name":    c.Name,
+		"Image":   c.Image,
+		"Created": c.Created.Format(time.RFC3339Nano),
+		"Config":  map[string]any{"Image": c.Image, "Labels": c.Labels},
+		"State": map[string]any{
+			"Status":   c.State,
+			"Running":  c.State == StateRunning || c.State == StatePaused,
+			"Paused":   c.State == StatePaused,
+			"ExitCode": c.ExitCode,
+		},
+		"NetworkSettings": map[string]any{"Ports": map[string]any{}},
+	})
+}
+
+func (s *Server) createContainer(w http.ResponseWriter, r *http.Request) {
+	var spec struct {
+		Name   string            `json:"name"`
+		Image  string            `json:"image"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeError(w, http.StatusInternalServerError, "decode container spec: "+err.Error())
+		return
+	}
+	if s.lookupImage(spec.Image) == nil {
+		writeError(w, http.StatusNotFound, spec.Image+": image not known")
+		return
+	}
+	if spec.Name == "" {
+		spec.Name = "mock_" + newID()[:8]
+	}
+	if s.lookup(spec.Name) != nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("the container name %q is already in use", spec.Name))
+		return
+	}
+
+	c := &Container{ID: newID(), Name: spec.Name, Image: spec.Image, State: StateCreated, Labels: spec.Labels, Created: time.Now()}
+	s.containers = append(s.containers, c)
+	writeJSON(w, http.StatusCreated, map[string]any{"Id": c.ID, "Warnings": []string{}})
+}
+
+// containerAction changes the state of the named container. As with
+// Podman, the response is 304 when the container already is in the
+// requested state.
+func (s *Server) containerAction(w http.ResponseWriter, name, action string) {
+	c := s.lookup(name)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "no such container "+name)
+		return
+	}
+
+	running := c.State == StateRunning || c.State == StatePaused
+	switch action {
+	case "start":
+		if c.State == StateRunning {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.State, c.ExitCode = StateRunning, 0
+	case "stop", "kill":
+		if !running {
+			if action == "stop" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			writeError(w, http.StatusConflict, "can only kill running containers. "+c.Name+" is in state "+c.State)
+			return
+		}
+		c.State, c.ExitCode = StateExited, 137
+		if action == "stop" {
+			c.ExitCode = 0
+		}
+	case "restart":
+		c.State, c.ExitCode = StateRunning, 0
+	case "pause":
+		if c.State != StateRunning {
+			writeError(w, http.StatusConflict, c.Name+" is not running, can't pause")
+			return
+		}
+		c.State = StatePaused
+	case "unpause":
+		if c.State != StatePaused {
+			writeError(w, http.StatusConflict, c.Name+" is not paused, can't unpause")
+			return
+		}
+		c.State = StateRunning
+	default:
+		writeError(w, http.StatusNotFound, "unknown container action "+action)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeContainer(w http.ResponseWriter, r *http.Request, name string) {
+	c := s.lookup(name)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "no such container "+name)
+		return
+	}
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if (c.State == StateRunning || c.State == StatePaused) && !force {
+		writeError(w, http.StatusConflict, "cannot remove container "+c.ID+" as it is "+c.State+" - running or paused containers cannot be removed without force")
+		return
+	}
+	for i, other := range s.containers {
+		if other == c {
+			s.containers = append(s.containers[:i], s.containers[i+1:]...)
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, []map[string]any{{"Id": c.ID}})
+}
+
+func (s *Server) listImages(w http.ResponseWriter) {
+	list := []map[string]any{}
+	for _, img := range s.images {
+		list = append(list, map[string]any{
+			"Id":       "sha256:" + img.ID,
+			"RepoTags": img.Tags,
+			"Size":     img.Size,
+			"Created":  img.Created.Unix(),
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// lookup returns the container with the given name, ID or ID prefix, or
+// nil.
+func (s *Server) lookup(name string) *Container {
+	for _, c := range s.containers {
+		if c.Name == name || c.ID == name {
+			return c
+		}
+	}
+	var match *Container
+	for _, c := range s.containers {
+		if strings.HasPrefix(c.ID, name) {
+			if match != nil {
+				return nil
+			}
+			match = c
+		}
+	}
+	return match
+}
+
+// lookupImage returns the image with the given tag, short name or ID, or
+// nil.
+func (s *Server) lookupImage(name string) *Image {
+	for i, img := range s.images {
+		if img.ID == name || strings.HasPrefix(img.ID, name) && len(name) >= 12 {
+			return &s.images[i]
+		}
+		for _, tag := range img.Tags {
+			short := tag[strings.LastIndex(tag, "/")+1:]
+			if tag == name || short == name || strings.TrimSuffix(short, ":latest") == name {
+				return &s.images[i]
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response in Podman's format.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]any{"cause": msg, "message": msg, "response": code})
+}
+
+// newID returns a random 64 hex digit ID, as Podman gives containers and
+// images.
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,179 @@
+package testserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// do sends a request to s and returns the response code and body.
+func do(t *testing.T, s *Server, method, uri, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, uri, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+// names returns the sorted names of the containers listed at uri.
+func names(t *testing.T, s *Server, uri string) []string {
+	t.Helper()
+	code, body := do(t, s, http.MethodGet, uri, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", uri, code, body)
+	}
+	var list []struct {
+		Names []string `json:"Names"`
+	}
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatalf("GET %s: %v", uri, err)
+	}
+	var got []string
+	for _, c := range list {
+		got = append(got, c.Names...)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestServer_ListContainers(t *testing.T) {
+	s := New(DemoFixtures())
+	filters := func(f string) string { return "&filters=" + url.QueryEscape(f) }
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"/v3.0.0/containers/json", "/db,/web"},
+		{"/v3.0.0/containers/json?all=true", "/db,/migrate,/web"},
+		{"/v3.0.0/libpod/containers/json?all=true", "db,migrate,web"},
+		{"/containers/json?all=1" + filters(`{"label":["app=db"]}`), "/db,/migrate"},
+		{"/containers/json?all=1" + filters(`{"label":["tier"],"status":["running"]}`), "/db,/web"},
+		{"/containers/json?" + filters(`{"status":["exited","created"]}`), "/migrate"},
+		{"/containers/json?all=1" + filters(`{"name":["igr"]}`), "/migrate"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(names(t, s, tt.uri), ","); got != tt.want {
+			t.Errorf("GET %s = %s, want %s", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestServer_ContainerLifecycle(t *testing.T) {
+	s := New(DemoFixtures())
+
+	code, body := do(t, s, http.MethodPost, "/v3.0.0/libpod/containers/create", `{"name":"cache","image":"alpine","labels":{"app":"cache"}}`)
+	if code != http.StatusCreated || !strings.Contains(body, `"Id"`) {
+		t.Fatalf("create = %d %s, want 201 with an Id", code, body)
+	}
+
+	steps := []struct {
+		method, uri string
+		code        int
+		state       string
+	}{
+		{http.MethodPost, "/v3.0.0/libpod/containers/cache/start", http.StatusNoContent, StateRunning},
+		{http.MethodPost, "/v3.0.0/libpod/containers/cache/start", http.StatusNotModified, StateRunning},
+		{http.MethodPost, "/v3.0.0/libpod/containers/cache/pause", http.StatusNoContent, StatePaused},
+		{http.MethodDelete, "/v3.0.0/libpod/containers/cache", http.StatusConflict, StatePaused},
+		{http.MethodPost, "/v3.0.0/libpod/containers/cache/unpause", http.StatusNoContent, StateRunning},
+		{http.MethodPost, "/v3.0.0/containers/cache/stop", http.StatusNoContent, StateExited},
+		{http.MethodPost, "/v3.0.0/containers/cache/stop", http.StatusNotModified, StateExited},
+		{http.MethodPost, "/v3.0.0/libpod/containers/cache/kill", http.StatusConflict, StateExited},
+	}
+	for _, step := range steps {
+		if code, body := do(t, s, step.method, step.uri, ""); code != step.code {
+			t.Errorf("%s %s = %d %s, want %d", step.method, step.uri, code, body, step.code)
+		}
+		if c, _ := s.Container("cache"); c.State != step.state {
+			t.Errorf("after %s %s state = %q, want %q", step.method, step.uri, c.State, step.state)
+		}
+	}
+
+	code, body = do(t, s, http.MethodGet, "/v3.0.0/libpod/containers/cache/json", "")
+	if code != http.StatusOK || !strings.Contains(body, `"Status":"exited"`) || !strings.Contains(body, `"app":"cache"`) {
+		t.Errorf("inspect = %d %s", code, body)
+	}
+
+	if code, _ := do(t, s, http.MethodDelete, "/v3.0.0/libpod/containers/cache", ""); code != http.StatusOK {
+		t.Errorf("DELETE = %d, want 200", code)
+	}
+	if _, ok := s.Container("cache"); ok {
+		t.Error("container still exists after DELETE")
+	}
+	if code, _ := do(t, s, http.MethodDelete, "/v3.0.0/libpod/containers/web?force=true", ""); code != http.StatusOK {
+		t.Errorf("DELETE running with force = %d, want 200", code)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	s := New(DemoFixtures())
+	tests := []struct {
+		method, uri, body string
+		code              int
+	}{
+		{http.MethodPost, "/v3.0.0/libpod/containers/create", `{"name":"x","image":"busybox"}`, http.StatusNotFound},
+		{http.MethodPost, "/v3.0.0/libpod/containers/create", `{"name":"web","image":"alpine"}`, http.StatusConflict},
+		{http.MethodPost, "/v3.0.0/libpod/containers/missing/start", "", http.StatusNotFound},
+		{http.MethodGet, "/v3.0.0/libpod/containers/missing/json", "", http.StatusNotFound},
+		{http.MethodGet, "/v3.0.0/libpod/volumes/json", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		code, body := do(t, s, tt.method, tt.uri, tt.body)
+		var apiErr struct {
+			Message  string `json:"message"`
+			Response int    `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(body), &apiErr); err != nil || apiErr.Message == "" || apiErr.Response != tt.code {
+			t.Errorf("%s %s body = %s, want a Podman error", tt.method, tt.uri, body)
+		}
+		if code != tt.code {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.uri, code, tt.code)
+		}
+	}
+}
+
+func TestServer_SystemReset(t *testing.T) {
+	s := New(DemoFixtures())
+	if code, _ := do(t, s, http.MethodPost, "/v3.0.0/libpod/system/reset", ""); code != http.StatusOK {
+		t.Fatalf("reset = %d, want 200", code)
+	}
+	if got := names(t, s, "/containers/json?all=true"); len(got) != 0 {
+		t.Errorf("containers after reset = %q, want none", got)
+	}
+	if _, body := do(t, s, http.MethodGet, "/images/json", ""); body != "[]\n" {
+		t.Errorf("images after reset = %s, want none", body)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	data := `{"version":"5.0.0","containers":[{"name":"api","image":"alpine","state":"running"}],"images":[{"tags":["docker.io/library/alpine:latest"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatalf("LoadFixtures() unexpected error = %v", err)
+	}
+	s := New(f)
+	if _, body := do(t, s, http.MethodGet, "/v3.0.0/libpod/version", ""); !strings.Contains(body, `"Version":"5.0.0"`) {
+		t.Errorf("version = %s, want 5.0.0", body)
+	}
+	if c, ok := s.Container("api"); !ok || len(c.ID) != 64 || c.State != StateRunning {
+		t.Errorf("Container() = %+v, %v, want a running container with an ID", c, ok)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixtures(path); err == nil || !strings.Contains(err.Error(), "fixtures.json") {
+		t.Errorf("LoadFixtures() error = %v, want it to name the file", err)
+	}
+}
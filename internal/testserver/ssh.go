@@ -0,0 +1,143 @@
+package testserver
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHServer serves an http.Handler to SSH clients over
+// "direct-streamlocal@openssh.com" channels, which is how the CLI reaches
+// the Podman socket of a remote host through sshd. Other channels, such as
+// sessions running commands, are rejected.
+type SSHServer struct {
+	Handler http.Handler
+	Config  *ssh.ServerConfig
+
+	// SocketPath, if set, is the only socket path channels may be opened
+	// to; others fail as for a missing socket. Any path is accepted
+	// otherwise.
+	SocketPath string
+}
+
+// Serve accepts SSH connections on l until it is closed.
+func (s *SSHServer) Serve(l net.Listener) error {
+	channels := &channelListener{conns: make(chan net.Conn), done: make(chan struct{}), addr: l.Addr()}
+	defer channels.Close()
+	go (&http.Server{Handler: s.Handler}).Serve(channels)
+
+	return ServeSSH(l, s.Config, func(sconn *ssh.ServerConn, chans <-chan ssh.NewChannel) {
+		s.serveChannels(sconn, chans, channels)
+	})
+}
+
+func (s *SSHServer) serveChannels(sconn *ssh.ServerConn, chans <-chan ssh.NewChannel, channels *channelListener) {
+	for newCh := range chans {
+		if newCh.ChannelType() != "direct-streamlocal@openssh.com" {
+			newCh.Reject(ssh.Prohibited, "the mock server only forwards the Podman socket")
+			continue
+		}
+		var target struct {
+			SocketPath string
+			Reserved0  string
+			Reserved1  uint32
+		}
+		if err := ssh.Unmarshal(newCh.ExtraData(), &target); err != nil {
+			newCh.Reject(ssh.ConnectionFailed, "invalid channel data")
+			continue
+		}
+		if s.SocketPath != "" && target.SocketPath != s.SocketPath {
+			newCh.Reject(ssh.ConnectionFailed, "open failed: No such file or directory")
+			continue
+		}
+
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go ssh.DiscardRequests(chReqs)
+		if !channels.deliver(&channelConn{Channel: ch, local: sconn.LocalAddr(), remote: sconn.RemoteAddr()}) {
+			ch.Close()
+		}
+	}
+}
+
+// ServeSSH accepts SSH connections on l until it is closed, and calls
+// handle with each of them once its handshake is done. handle receives
+// the channels opened by the client until the connection is closed, and
+// the connection is closed once it returns. Global requests, such as
+// keepalives, are acknowledged.
+func ServeSSH(l net.Listener, config *ssh.ServerConfig, handle func(conn *ssh.ServerConn, chans <-chan ssh.NewChannel)) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			defer sconn.Close()
+			go func() {
+				for req := range reqs {
+					req.Reply(true, nil)
+				}
+			}()
+			handle(sconn, chans)
+		}()
+	}
+}
+
+// channelListener is a net.Listener accepting the channels opened to the
+// socket, so that a single http.Server answers all of them.
+type channelListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+	addr  net.Addr
+}
+
+// deliver hands conn to Accept, and reports false if the listener is
+// closed.
+func (l *channelListener) deliver(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *channelListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *channelListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *channelListener) Addr() net.Addr { return l.addr }
+
+// channelConn adapts an SSH channel to net.Conn. Deadlines are not
+// supported and are ignored.
+type channelConn struct {
+	ssh.Channel
+	local, remote net.Addr
+}
+
+func (c *channelConn) LocalAddr() net.Addr                { return c.local }
+func (c *channelConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *channelConn) SetDeadline(t time.Time) error      { return nil }
+func (c *channelConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *channelConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,83 @@
+package testserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startSSH serves s over SSH on a local port, accepting any client, and
+// returns a connected client.
+func startSSH(t *testing.T, s *SSHServer) *ssh.Client {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Config = &ssh.ServerConfig{NoClientAuth: true}
+	s.Config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go s.Serve(listener)
+
+	sshClient, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "podman",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Dial() unexpected error = %v", err)
+	}
+	t.Cleanup(func() { sshClient.Close() })
+	return sshClient
+}
+
+func TestSSHServer(t *testing.T) {
+	const socket = "/run/user/1000/podman/podman.sock"
+	sshClient := startSSH(t, &SSHServer{Handler: New(DemoFixtures()), SocketPath: socket})
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sshClient.Dial("unix", socket)
+		},
+	}}
+	// Several requests, over kept-alive and new channels
+	for i := 0; i < 3; i++ {
+		resp, err := httpClient.Get("http://d/v3.0.0/containers/json")
+		if err != nil {
+			t.Fatalf("GET unexpected error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"/web"`) {
+			t.Errorf("GET = %d %s, want the running containers", resp.StatusCode, body)
+		}
+		httpClient.CloseIdleConnections()
+	}
+
+	_, err := sshClient.Dial("unix", "/var/run/docker.sock")
+	var openErr *ssh.OpenChannelError
+	if !errors.As(err, &openErr) || !strings.Contains(openErr.Message, "No such file") {
+		t.Errorf("Dial(other socket) error = %v, want a missing socket", err)
+	}
+	if _, err := sshClient.NewSession(); err == nil {
+		t.Error("NewSession() error = nil, want sessions rejected")
+	}
+}
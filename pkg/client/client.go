@@ -0,0 +1,226 @@
+// Package client sends requests to the Podman API of remote hosts over
+// SSH, with podman-cli's connection handling, to programs using it as a
+// library. The connection settings are aliases of the types of the
+// internal client package, documented there.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// Connection settings.
+type (
+	Options            = client.Options
+	Paths              = client.Paths
+	Proxy              = client.Proxy
+	CredentialProvider = client.CredentialProvider
+	Credentials        = client.Credentials
+	UserConfig         = client.UserConfig
+)
+
+const (
+	// DefaultSocketPath is the Podman API socket used when
+	// ClientOptions.Socket is empty.
+	DefaultSocketPath = client.DefaultSocketPath
+	// DefaultKeepAliveInterval is the keepalive interval used when
+	// ClientOptions.KeepAlive is 0.
+	DefaultKeepAliveInterval = client.DefaultKeepAliveInterval
+)
+
+// ParseProxy parses a proxy URL, as given to -proxy, returning nil for an
+// empty one.
+func ParseProxy(raw string) (*Proxy, error) {
+	return client.ParseProxy(raw)
+}
+
+var (
+	// ErrNotConnected is returned by Client methods called before Connect.
+	ErrNotConnected = errors.New("client not connected")
+	// ErrClientClosed is returned by Client methods once it has been closed.
+	ErrClientClosed = errors.New("client closed")
+)
+
+// ClientOptions holds the settings of a Client: those of Options, used to
+// resolve the host through the SSH configuration, and those of the
+// connection to its Podman API.
+type ClientOptions struct {
+	Options
+	Socket    string        // Podman API socket on the host, DefaultSocketPath if empty
+	KeepAlive time.Duration // interval of SSH keepalive requests, DefaultKeepAliveInterval if 0, none if negative
+	Proxy     *Proxy        // proxy of the SSH connection, nil to connect directly
+}
+
+// Client sends requests to the Podman API of a remote host, for programs
+// embedding podman-cli's connection handling, such as servers managing a
+// fleet of hosts.
+//
+// A Client keeps a single SSH connection to its host, opened by Connect
+// and re-dialed when it is lost. Each request in flight
+// tunnels to the API socket through a channel of its own on that
+// connection, and channels are reused by later requests, so a Client is
+// safe for concurrent use by multiple goroutines and is meant to be
+// shared: servers should create one per host and call Close when done
+// with it.
+type Client struct {
+	host string
+	opts ClientOptions
+
+	mu       sync.Mutex
+	redialer *client.Redialer
+	http     *http.Client
+	closed   bool
+}
+
+// NewClient returns a Client for host, as given on the command line or
+// named in the SSH configuration. No connection is made until Connect is
+// called.
+func NewClient(host string, opts ClientOptions) *Client {
+	if opts.Socket == "" {
+		opts.Socket = DefaultSocketPath
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = DefaultKeepAliveInterval
+	}
+	return &Client{host: host, opts: opts}
+}
+
+// Connect resolves the host through the SSH configuration and opens the
+// SSH connection to it, giving up when ctx is done. It does nothing if the
+// client is already connected. The socket is not dialed; use Ping to check
+// that the API answers.
+//
+// The client is not locked while dialing, so that requests and Close are
+// not held up by a slow handshake.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	closed, connected := c.closed, c.redialer != nil
+	c.mu.Unlock()
+	if closed {
+		return ErrClientClosed
+	}
+	if connected {
+		return nil
+	}
+
+	target, err := c.opts.Resolve(c.host)
+	if err != nil {
+		return err
+	}
+	redialer := client.NewRedialer(func() (*ssh.Client, error) {
+		return client.NewSSHClientVia(c.opts.Proxy, target.Addr, target.Config)
+	}, c.opts.KeepAlive)
+
+	// The SSH handshake does not take a context, so it is left to finish
+	// in the background when ctx is done first
+	done := make(chan error, 1)
+	go func() {
+		_, err := redialer.Client()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			redialer.Close()
+			return err
+		}
+	case <-ctx.Done():
+		go func() {
+			<-done
+			redialer.Close()
+		}()
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		redialer.Close()
+		return ErrClientClosed
+	}
+	if c.redialer != nil {
+		// A concurrent Connect finished first
+		redialer.Close()
+		return nil
+	}
+	c.redialer = redialer
+	c.http = client.NewHTTPClient(func() (net.Conn, error) {
+		return redialer.Dial("unix", c.opts.Socket)
+	})
+	return nil
+}
+
+// Do sends req to the Podman API and returns its response, as
+// http.Client.Do does: a response with an error status is not an error.
+// Only the path and query of the request URL are used. The caller must
+// close the response body, which releases the channel for other requests.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	httpClient, closed := c.http, c.closed
+	c.mu.Unlock()
+
+	if closed {
+		return nil, ErrClientClosed
+	}
+	if httpClient == nil {
+		return nil, ErrNotConnected
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", "localhost"
+	req.Host = ""
+	return httpClient.Do(req)
+}
+
+// RoundTrip sends req as Do does, so that a Client can serve as the
+// Transport of an http.Client.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.Do(req)
+}
+
+// Ping checks that the Podman API of the host answers, returning an error
+// if it cannot be reached or does not reply OK.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping %s: unexpected status %s", c.host, resp.Status)
+	}
+	return nil
+}
+
+// Close closes the SSH connection, failing the requests still in flight.
+// Subsequent calls to Connect, Do and Ping return ErrClientClosed. Closing
+// a closed client does nothing.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.redialer == nil {
+		return nil
+	}
+	c.http = nil
+	return c.redialer.Close()
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexjch/podman-cli/internal/testserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestKey writes an unencrypted private key in a temporary directory,
+// which is also made the home directory, and returns its path.
+func writeTestKey(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile
+}
+
+// newTestClient returns a Client for a mock Podman server reached over
+// SSH, and the server.
+func newTestClient(t *testing.T) (*Client, *testserver.Server) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := testserver.New(testserver.DemoFixtures())
+	go (&testserver.SSHServer{Handler: server, Config: serverConfig, SocketPath: DefaultSocketPath}).Serve(listener)
+
+	keyFile := writeTestKey(t)
+	dir := filepath.Dir(keyFile)
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	configFile := filepath.Join(dir, "ssh_config")
+	data := fmt.Sprintf("Host podman\n  HostName %s\n  Port %s\n  User core\n  IdentityFile %s\n", host, port, keyFile)
+	if err := os.WriteFile(configFile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient("podman", ClientOptions{Options: Options{
+		Paths:    Paths{Config: configFile, KnownHosts: filepath.Join(dir, "known_hosts")},
+		Timeout:  5 * time.Second,
+		Insecure: true,
+	}})
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func TestClient(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Ping() before Connect error = %v, want ErrNotConnected", err)
+	}
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect() unexpected error = %v", err)
+	}
+	if err := c.Connect(ctx); err != nil {
+		t.Errorf("second Connect() unexpected error = %v", err)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping() unexpected error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/v3.0.0/libpod/containers/json?all=true", nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				errs <- fmt.Errorf("status %s", resp.Status)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent Do() error = %v", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() unexpected error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close() unexpected error = %v", err)
+	}
+	if err := c.Ping(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Ping() after Close error = %v, want ErrClientClosed", err)
+	}
+	if err := c.Connect(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Connect() after Close error = %v, want ErrClientClosed", err)
+	}
+}
+
+// newStuckClient returns a Client for a server accepting connections
+// without ever completing the handshake, and a channel receiving a value
+// when a connection is accepted.
+func newStuckClient(t *testing.T) (*Client, <-chan struct{}) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan struct{}, 1)
+	var conns []net.Conn
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			select {
+			case accepted <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	keyFile := writeTestKey(t)
+	dir := filepath.Dir(keyFile)
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	configFile := filepath.Join(dir, "ssh_config")
+	data := fmt.Sprintf("Host stuck\n  HostName %s\n  Port %s\n  IdentityFile %s\n", host, port, keyFile)
+	if err := os.WriteFile(configFile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("stuck", ClientOptions{Options: Options{Paths: Paths{Config: configFile}, Timeout: 5 * time.Second, Insecure: true}})
+	t.Cleanup(func() { c.Close() })
+	return c, accepted
+}
+
+func TestClient_ConnectCanceled(t *testing.T) {
+	c, _ := newStuckClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Connect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Connect() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClient_NotLockedWhileConnecting(t *testing.T) {
+	c, accepted := newStuckClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	connected := make(chan error, 1)
+	go func() { connected <- c.Connect(ctx) }()
+	<-accepted
+
+	// The handshake is stuck, yet the client answers
+	pinged := make(chan error, 1)
+	go func() { pinged <- c.Ping(context.Background()) }()
+	select {
+	case err := <-pinged:
+		if !errors.Is(err, ErrNotConnected) {
+			t.Errorf("Ping() while connecting error = %v, want ErrNotConnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Ping() blocked by the handshake of Connect")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() while connecting unexpected error = %v", err)
+	}
+
+	cancel()
+	if err := <-connected; !errors.Is(err, context.Canceled) {
+		t.Errorf("Connect() error = %v, want context.Canceled", err)
+	}
+}
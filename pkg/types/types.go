@@ -0,0 +1,48 @@
+// Package types exposes the Podman API response types decoded by
+// podman-cli to programs using it as a library. They are aliases of the
+// types of the internal models package, documented there.
+package types
+
+import "github.com/alexjch/podman-cli/internal/models"
+
+// Health states reported by Podman for containers with a healthcheck.
+const (
+	HealthHealthy   = models.HealthHealthy
+	HealthUnhealthy = models.HealthUnhealthy
+	HealthStarting  = models.HealthStarting
+)
+
+// Containers.
+type (
+	ListContainer              = models.ListContainer
+	PortMapping                = models.PortMapping
+	ContainerSummary           = models.ContainerSummary
+	Port                       = models.Port
+	InspectContainerData       = models.InspectContainerData
+	InspectContainerState      = models.InspectContainerState
+	HealthCheckResults         = models.HealthCheckResults
+	HealthCheckLog             = models.HealthCheckLog
+	InspectMount               = models.InspectMount
+	InspectContainerConfig     = models.InspectContainerConfig
+	InspectContainerHostConfig = models.InspectContainerHostConfig
+	InspectHostPort            = models.InspectHostPort
+	InspectRestartPolicy       = models.InspectRestartPolicy
+	InspectNetworkSettings     = models.InspectNetworkSettings
+	InspectAdditionalNetwork   = models.InspectAdditionalNetwork
+	ContainerStats             = models.ContainerStats
+	ContainerStatsReport       = models.ContainerStatsReport
+)
+
+// Images.
+type (
+	ImageSummary = models.ImageSummary
+	ImageData    = models.ImageData
+	ImageConfig  = models.ImageConfig
+)
+
+// Pods and volumes.
+type (
+	ListPodsReport       = models.ListPodsReport
+	ListPodContainer     = models.ListPodContainer
+	VolumeConfigResponse = models.VolumeConfigResponse
+)